@@ -0,0 +1,50 @@
+-- =================================================================
+-- Sensor Registry Schema (sensor-manager)
+-- =================================================================
+
+-- Registration metadata reported by sensors
+ALTER TABLE sensors
+    ADD COLUMN IF NOT EXISTS description TEXT,
+    ADD COLUMN IF NOT EXISTS platform VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS profile VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS network_interfaces TEXT[] DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS tags TEXT[] DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS last_health JSONB DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS registered_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS decommissioned_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_sensors_tenant_status ON sensors(tenant_id, status) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_sensors_tags ON sensors USING GIN(tags);
+
+-- Pending sensor registrations (registration keys issued from the UI)
+CREATE TABLE IF NOT EXISTS pending_sensors (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    registration_key VARCHAR(255) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    ip_address INET NOT NULL,
+    tags TEXT[] DEFAULT '{}',
+    profile VARCHAR(50) NOT NULL,
+    network_interfaces TEXT[] DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, used, expired
+    sensor_id UUID REFERENCES sensors(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT valid_pending_status CHECK (status IN ('pending', 'used', 'expired'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_sensors_tenant ON pending_sensors(tenant_id, status);
+
+-- Registration settings managed by administrators (single row)
+CREATE TABLE IF NOT EXISTS sensor_manager_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    key_expiration_minutes INTEGER NOT NULL DEFAULT 60,
+    max_pending_sensors INTEGER NOT NULL DEFAULT 50,
+    require_ip_validation BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO sensor_manager_settings (id) VALUES (true) ON CONFLICT (id) DO NOTHING;
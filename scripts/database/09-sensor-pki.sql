@@ -0,0 +1,31 @@
+-- =================================================================
+-- Sensor PKI Schema (sensor-manager internal CA)
+-- =================================================================
+
+-- One long-lived CA per tenant. The private key is encrypted at rest with
+-- AES-256-GCM using the sensor-manager CA encryption key.
+CREATE TABLE IF NOT EXISTS sensor_certificate_authorities (
+    tenant_id UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    key_algorithm VARCHAR(20) NOT NULL, -- 'ecdsa-p256', 'ed25519'
+    certificate_pem TEXT NOT NULL,
+    encrypted_private_key BYTEA NOT NULL, -- nonce || ciphertext
+    not_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    not_after TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Client certificates issued to sensors, used for revocation and the CRL
+CREATE TABLE IF NOT EXISTS sensor_certificates (
+    serial_number VARCHAR(64) PRIMARY KEY, -- lowercase hex
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    fingerprint_sha256 VARCHAR(64) NOT NULL,
+    not_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    not_after TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revocation_reason INTEGER, -- RFC 5280 CRLReason
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_certificates_sensor ON sensor_certificates(sensor_id);
+CREATE INDEX IF NOT EXISTS idx_sensor_certificates_revoked ON sensor_certificates(tenant_id) WHERE revoked_at IS NOT NULL;
@@ -0,0 +1,51 @@
+-- =================================================================
+-- Sensor Command Queue Schema (sensor-manager)
+-- =================================================================
+
+-- Commands issued to sensors and their lifecycle:
+-- queued -> delivered -> acknowledged -> succeeded / failed / expired
+CREATE TABLE IF NOT EXISTS sensor_commands (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    batch_id UUID, -- shared by commands enqueued for a group of sensors
+    command_type VARCHAR(50) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0, -- higher is delivered first
+    payload JSONB DEFAULT '{}',
+    requires_ack BOOLEAN NOT NULL DEFAULT false,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 3,
+    ack_timeout_seconds INTEGER NOT NULL DEFAULT 120, -- redeliver if no response within this time
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    acknowledged_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    result JSONB,
+    error TEXT,
+    created_by VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT valid_command_status CHECK (
+        status IN ('queued', 'delivered', 'acknowledged', 'succeeded', 'failed', 'expired')
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_commands_deliverable ON sensor_commands(sensor_id, priority DESC, created_at)
+    WHERE status IN ('queued', 'delivered');
+CREATE INDEX IF NOT EXISTS idx_sensor_commands_sensor ON sensor_commands(sensor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sensor_commands_tenant ON sensor_commands(tenant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sensor_commands_batch ON sensor_commands(batch_id) WHERE batch_id IS NOT NULL;
+
+-- Every state transition of a command, for the command history
+CREATE TABLE IF NOT EXISTS sensor_command_events (
+    id BIGSERIAL PRIMARY KEY,
+    command_id UUID NOT NULL REFERENCES sensor_commands(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 0,
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_command_events_command ON sensor_command_events(command_id, id);
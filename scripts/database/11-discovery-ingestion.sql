@@ -0,0 +1,56 @@
+-- =================================================================
+-- Discovery Ingestion Schema (sensor-manager)
+-- =================================================================
+
+-- Discovery batches submitted by sensors. A batch is stored once per
+-- (sensor, batch_id), which makes retried submissions idempotent, and is
+-- then turned into inventory records by the ingestion worker:
+-- pending -> processing -> completed / failed
+CREATE TABLE IF NOT EXISTS discovery_batches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    batch_id VARCHAR(100) NOT NULL, -- sensor-generated, doubles as idempotency key
+    source VARCHAR(20) NOT NULL DEFAULT 'batch', -- batch or export (air-gapped)
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    discovery_count INTEGER NOT NULL DEFAULT 0,
+    accepted_count INTEGER NOT NULL DEFAULT 0,
+    rejected_count INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    payload JSONB, -- submitted discoveries, cleared once the batch is completed
+    rejections JSONB DEFAULT '[]', -- discoveries that failed validation and why
+    last_error TEXT,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT unique_batch_per_sensor UNIQUE (sensor_id, batch_id),
+    CONSTRAINT valid_batch_status CHECK (status IN ('pending', 'processing', 'completed', 'failed')),
+    CONSTRAINT valid_batch_source CHECK (source IN ('batch', 'export'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_discovery_batches_claimable ON discovery_batches(received_at)
+    WHERE status IN ('pending', 'processing');
+CREATE INDEX IF NOT EXISTS idx_discovery_batches_sensor ON discovery_batches(sensor_id, received_at DESC);
+
+-- Crypto implementations are observed per service port, so a host serving
+-- TLS on several ports gets one implementation per port
+ALTER TABLE crypto_implementations
+    ADD COLUMN IF NOT EXISTS port INTEGER;
+
+UPDATE crypto_implementations ci
+SET port = na.port
+FROM network_assets na
+WHERE ci.asset_id = na.id AND ci.port IS NULL AND na.port IS NOT NULL;
+
+-- Identity of an implementation for ingestion upserts:
+-- (asset, port, protocol, version, cipher)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_crypto_implementations_identity ON crypto_implementations (
+    tenant_id, asset_id, (COALESCE(port, 0)), protocol,
+    (COALESCE(protocol_version, '')), (COALESCE(cipher_suite, ''))
+) WHERE deleted_at IS NULL;
+
+-- Asset resolution by address and name
+CREATE INDEX IF NOT EXISTS idx_network_assets_tenant_ip ON network_assets(tenant_id, ip_address) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_network_assets_tenant_hostname ON network_assets(tenant_id, LOWER(hostname)) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_crypto_implementations_source_sensor ON crypto_implementations(source_sensor_id) WHERE deleted_at IS NULL;
@@ -0,0 +1,47 @@
+-- =================================================================
+-- Tenant-Scoped Registration Keys (sensor-manager)
+-- =================================================================
+
+-- Registration keys are stored as SHA-256 hashes; the plaintext key is only
+-- shown once when it is issued. A key may register up to max_sensors
+-- sensors from addresses inside allowed_cidr and can be revoked.
+ALTER TABLE pending_sensors
+    ADD COLUMN IF NOT EXISTS key_hash VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS key_prefix VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS allowed_cidr CIDR,
+    ADD COLUMN IF NOT EXISTS max_sensors INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS used_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP WITH TIME ZONE;
+
+-- Existing keys: hash the plaintext and bind them to their single address
+UPDATE pending_sensors
+SET key_hash = encode(sha256(convert_to(registration_key, 'UTF8')), 'hex'),
+    key_prefix = LEFT(registration_key, 26),
+    allowed_cidr = network(set_masklen(ip_address, CASE WHEN family(ip_address) = 4 THEN 32 ELSE 128 END)),
+    used_count = CASE WHEN status = 'used' THEN 1 ELSE 0 END
+WHERE key_hash IS NULL AND registration_key IS NOT NULL;
+
+-- Sensors now reference the key that registered them, since a key can
+-- register several sensors
+ALTER TABLE sensors
+    ADD COLUMN IF NOT EXISTS registration_key_id UUID REFERENCES pending_sensors(id) ON DELETE SET NULL;
+
+UPDATE sensors s
+SET registration_key_id = ps.id
+FROM pending_sensors ps
+WHERE ps.sensor_id = s.id AND s.registration_key_id IS NULL;
+
+ALTER TABLE pending_sensors
+    DROP COLUMN IF EXISTS registration_key,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS sensor_id,
+    ALTER COLUMN key_hash SET NOT NULL,
+    ALTER COLUMN key_prefix SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS valid_max_sensors,
+    ADD CONSTRAINT valid_max_sensors CHECK (max_sensors BETWEEN 1 AND 10000),
+    DROP CONSTRAINT IF EXISTS valid_used_count,
+    ADD CONSTRAINT valid_used_count CHECK (used_count BETWEEN 0 AND max_sensors);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pending_sensors_key_hash ON pending_sensors(key_hash);
+CREATE INDEX IF NOT EXISTS idx_sensors_registration_key ON sensors(registration_key_id) WHERE registration_key_id IS NOT NULL;
@@ -0,0 +1,49 @@
+-- =================================================================
+-- Registration Network Binding and Audit (sensor-manager)
+-- =================================================================
+
+-- A registration key may be bound to several networks, e.g. the sensors'
+-- own subnet and the NAT range their requests leave through
+ALTER TABLE pending_sensors
+    ADD COLUMN IF NOT EXISTS allowed_cidrs CIDR[] NOT NULL DEFAULT '{}';
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'pending_sensors' AND column_name = 'allowed_cidr') THEN
+        UPDATE pending_sensors SET allowed_cidrs = ARRAY[allowed_cidr]
+        WHERE allowed_cidr IS NOT NULL AND allowed_cidrs = '{}';
+        ALTER TABLE pending_sensors DROP COLUMN allowed_cidr;
+    END IF;
+END $$;
+
+-- Proxies and load balancers in front of the sensor-manager whose
+-- X-Forwarded-For header is trusted to carry the client address
+ALTER TABLE sensor_manager_settings
+    ADD COLUMN IF NOT EXISTS trusted_proxies CIDR[] NOT NULL DEFAULT '{}';
+
+-- Every registration attempt with the address the sensor declared, the
+-- address the request was observed from and, when rejected, why
+CREATE TABLE IF NOT EXISTS sensor_registration_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE, -- NULL when the key was unknown
+    registration_key_id UUID REFERENCES pending_sensors(id) ON DELETE SET NULL,
+    sensor_id UUID REFERENCES sensors(id) ON DELETE SET NULL,
+    key_prefix VARCHAR(64),
+    sensor_name VARCHAR(255),
+    declared_ip INET,
+    client_ip INET,
+    remote_addr INET,
+    forwarded_for TEXT,
+    allowed_cidrs CIDR[] NOT NULL DEFAULT '{}',
+    outcome VARCHAR(20) NOT NULL,
+    reason VARCHAR(50),
+    detail TEXT,
+    attempted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT valid_registration_outcome CHECK (outcome IN ('accepted', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_registration_attempts_tenant ON sensor_registration_attempts(tenant_id, attempted_at DESC);
+CREATE INDEX IF NOT EXISTS idx_registration_attempts_key ON sensor_registration_attempts(registration_key_id, attempted_at DESC)
+    WHERE registration_key_id IS NOT NULL;
@@ -0,0 +1,71 @@
+-- =================================================================
+-- Sensor Groups and Configuration Profiles (sensor-manager)
+-- =================================================================
+
+-- Tenant-defined configuration profiles. Every change creates a new,
+-- immutable version; current_version points at the latest one.
+CREATE TABLE IF NOT EXISTS sensor_config_profiles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    current_version INTEGER NOT NULL DEFAULT 1,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT unique_config_profile_name UNIQUE (tenant_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS sensor_config_profile_versions (
+    profile_id UUID NOT NULL REFERENCES sensor_config_profiles(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    settings JSONB NOT NULL DEFAULT '{}', -- interfaces, port map, features, intervals, storage limits
+    change_note TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (profile_id, version)
+);
+
+-- Sensor groups inherit a profile, either following its current version or
+-- pinned to a specific one
+CREATE TABLE IF NOT EXISTS sensor_groups (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    profile_id UUID REFERENCES sensor_config_profiles(id) ON DELETE RESTRICT,
+    profile_version INTEGER, -- NULL follows the profile's current version
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT unique_sensor_group_name UNIQUE (tenant_id, name),
+    CONSTRAINT pinned_version_needs_profile CHECK (profile_version IS NULL OR profile_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_groups_profile ON sensor_groups(profile_id) WHERE profile_id IS NOT NULL;
+
+-- Sensors belong to at most one group and may override individual settings.
+-- config_version is bumped whenever the configuration served to the sensor
+-- changes; applied_config_version is what the sensor last reported.
+ALTER TABLE sensors
+    ADD COLUMN IF NOT EXISTS group_id UUID REFERENCES sensor_groups(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS config_overrides JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS config_version INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS applied_config_version INTEGER,
+    ADD COLUMN IF NOT EXISTS config_applied_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE sensors SET config_version = 1
+WHERE config_version = 0 AND configuration IS NOT NULL AND configuration <> '{}'::jsonb;
+
+CREATE INDEX IF NOT EXISTS idx_sensors_group ON sensors(group_id) WHERE group_id IS NOT NULL;
+
+-- Registration keys may place the sensors they register in a group
+ALTER TABLE pending_sensors
+    ADD COLUMN IF NOT EXISTS group_id UUID REFERENCES sensor_groups(id) ON DELETE SET NULL;
+
+CREATE TRIGGER update_sensor_config_profiles_updated_at BEFORE UPDATE ON sensor_config_profiles
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_sensor_groups_updated_at BEFORE UPDATE ON sensor_groups
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,50 @@
+-- =================================================================
+-- Fleet Health Monitoring and Alert Webhooks (sensor-manager)
+-- =================================================================
+
+-- Health state derived by the fleet health evaluator from heartbeats and
+-- the metrics sensors report, with the reasons for the current state
+ALTER TABLE sensors
+    ADD COLUMN IF NOT EXISTS health_state VARCHAR(20) NOT NULL DEFAULT 'unknown'
+        CHECK (health_state IN ('unknown', 'healthy', 'degraded', 'offline')),
+    ADD COLUMN IF NOT EXISTS health_reasons JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS health_changed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_sensors_health_state ON sensors(tenant_id, health_state)
+    WHERE deleted_at IS NULL AND decommissioned_at IS NULL;
+
+-- Every health state change of a sensor
+CREATE TABLE IF NOT EXISTS sensor_health_transitions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    from_state VARCHAR(20) NOT NULL,
+    to_state VARCHAR(20) NOT NULL,
+    reasons JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_health_transitions_sensor ON sensor_health_transitions(sensor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sensor_health_transitions_tenant ON sensor_health_transitions(tenant_id, created_at DESC);
+
+-- Tenant webhooks notified of sensor events, optionally for a single
+-- sensor. The secret signs payloads and so is kept in the clear.
+CREATE TABLE IF NOT EXISTS sensor_webhooks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    sensor_id UUID REFERENCES sensors(id) ON DELETE CASCADE, -- NULL covers every sensor
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    webhook_url TEXT NOT NULL,
+    secret TEXT,
+    events TEXT[] NOT NULL DEFAULT '{}', -- empty subscribes to every event
+    retry_count INTEGER NOT NULL DEFAULT 3 CHECK (retry_count BETWEEN 0 AND 10),
+    timeout_seconds INTEGER NOT NULL DEFAULT 10 CHECK (timeout_seconds BETWEEN 1 AND 60),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_webhooks_tenant ON sensor_webhooks(tenant_id) WHERE enabled;
+
+CREATE TRIGGER update_sensor_webhooks_updated_at BEFORE UPDATE ON sensor_webhooks
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,82 @@
+-- =================================================================
+-- Webhook Delivery (sensor-manager)
+-- =================================================================
+
+-- Webhooks receive every kind of tenant event, not only sensor events
+ALTER TABLE IF EXISTS sensor_webhooks RENAME TO webhook_endpoints;
+ALTER INDEX IF EXISTS idx_sensor_webhooks_tenant RENAME TO idx_webhook_endpoints_tenant;
+ALTER TRIGGER update_sensor_webhooks_updated_at ON webhook_endpoints RENAME TO update_webhook_endpoints_updated_at;
+
+ALTER TABLE webhook_endpoints
+    ADD COLUMN IF NOT EXISTS description TEXT;
+
+-- Outbox of events to deliver. Any service sharing the database publishes
+-- an event by inserting it here, ideally in the transaction that caused it;
+-- the sensor-manager's dispatcher fans it out to the subscribed endpoints.
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    event_type VARCHAR(100) NOT NULL, -- e.g. sensor.offline, crypto.weak_detected, certificate.expiring
+    sensor_id UUID REFERENCES sensors(id) ON DELETE SET NULL, -- sensor the event concerns, if any
+    payload JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    dispatched_at TIMESTAMP WITH TIME ZONE -- set once deliveries have been created
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_undispatched ON webhook_events(created_at) WHERE dispatched_at IS NULL;
+
+CREATE OR REPLACE FUNCTION publish_webhook_event(p_tenant_id UUID, p_event_type TEXT, p_payload JSONB, p_sensor_id UUID DEFAULT NULL)
+RETURNS UUID AS $$
+    INSERT INTO webhook_events (tenant_id, event_type, sensor_id, payload)
+    VALUES (p_tenant_id, p_event_type, p_sensor_id, p_payload)
+    RETURNING id;
+$$ LANGUAGE sql;
+
+-- One delivery per event and endpoint. Failed deliveries are retried with
+-- backoff; those that run out of attempts stay as dead letters until they
+-- are redelivered or purged.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES webhook_events(id) ON DELETE CASCADE,
+    event_type VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'delivering', 'retrying', 'succeeded', 'dead_letter')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 4,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE, -- a delivering row past this is claimed again
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    dead_lettered_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT unique_webhook_delivery UNIQUE (endpoint_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at)
+    WHERE status IN ('pending', 'retrying');
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_delivering ON webhook_deliveries(locked_until)
+    WHERE status = 'delivering';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_tenant ON webhook_deliveries(tenant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries(event_id);
+
+-- Every attempt made for a delivery
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER,
+    error TEXT,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    attempted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id, attempted_at);
+
+CREATE TRIGGER update_webhook_deliveries_updated_at BEFORE UPDATE ON webhook_deliveries
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
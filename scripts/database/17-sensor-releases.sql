@@ -0,0 +1,78 @@
+-- =================================================================
+-- Sensor Releases and Rollouts (sensor-manager)
+-- =================================================================
+
+-- Sensor software releases. The catalog is shared by all tenants; releases
+-- are published by the build pipeline, never edited, and withdrawn by
+-- yanking them.
+CREATE TABLE IF NOT EXISTS sensor_releases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    version VARCHAR(50) NOT NULL UNIQUE,
+    notes TEXT,
+    published_by VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    yanked_at TIMESTAMP WITH TIME ZONE,
+    yank_reason TEXT
+);
+
+-- One signed binary per release and platform (GOOS-GOARCH). The signature
+-- is an Ed25519 signature over the version, platform and digest, made with
+-- an offline release key the sensors pin.
+CREATE TABLE IF NOT EXISTS sensor_release_artifacts (
+    release_id UUID NOT NULL REFERENCES sensor_releases(id) ON DELETE CASCADE,
+    platform VARCHAR(50) NOT NULL, -- e.g. linux-amd64
+    size_bytes BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    signature TEXT NOT NULL, -- base64
+    storage_key VARCHAR(255) NOT NULL, -- file name in the release store
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (release_id, platform)
+);
+
+-- Staged rollouts of a release to a tenant's sensors. Stages are upgraded
+-- one after the other; a rollout pauses by itself once more than
+-- max_failures of its sensors failed to upgrade.
+CREATE TABLE IF NOT EXISTS sensor_rollouts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    release_id UUID NOT NULL REFERENCES sensor_releases(id) ON DELETE RESTRICT,
+    status VARCHAR(20) NOT NULL DEFAULT 'running'
+        CHECK (status IN ('running', 'paused', 'completed', 'cancelled')),
+    stages JSONB NOT NULL DEFAULT '[]', -- stage definitions as requested
+    current_stage INTEGER NOT NULL DEFAULT 0,
+    max_failures INTEGER NOT NULL DEFAULT 0,
+    soak_seconds INTEGER NOT NULL DEFAULT 0, -- wait after a stage before starting the next
+    pause_reason TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    stage_completed_at TIMESTAMP WITH TIME ZONE, -- when the current stage finished
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_rollouts_tenant ON sensor_rollouts(tenant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sensor_rollouts_active ON sensor_rollouts(status) WHERE status IN ('running', 'paused');
+
+-- The sensors of a rollout, resolved when it is created, and the upgrade
+-- command sent to each
+CREATE TABLE IF NOT EXISTS sensor_rollout_targets (
+    rollout_id UUID NOT NULL REFERENCES sensor_rollouts(id) ON DELETE CASCADE,
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    stage INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'upgrading', 'succeeded', 'failed', 'skipped')),
+    command_id UUID REFERENCES sensor_commands(id) ON DELETE SET NULL,
+    from_version VARCHAR(50),
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+
+    PRIMARY KEY (rollout_id, sensor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_rollout_targets_sensor ON sensor_rollout_targets(sensor_id);
+CREATE INDEX IF NOT EXISTS idx_sensor_rollout_targets_command ON sensor_rollout_targets(command_id) WHERE command_id IS NOT NULL;
+
+CREATE TRIGGER update_sensor_rollouts_updated_at BEFORE UPDATE ON sensor_rollouts
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
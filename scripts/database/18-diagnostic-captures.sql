@@ -0,0 +1,46 @@
+-- =================================================================
+-- Diagnostic Packet Captures (sensor-manager)
+-- =================================================================
+
+-- On-demand packet captures requested from a sensor. The sensor keeps only
+-- protocol handshakes, strips every payload byte after them and seals the
+-- pcap to the sensor-manager's capture key before uploading it. The
+-- capture is delivered as a diagnostic_capture command; its state follows
+-- the command until the sealed pcap is uploaded.
+CREATE TABLE IF NOT EXISTS diagnostic_captures (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    command_id UUID REFERENCES sensor_commands(id) ON DELETE SET NULL,
+    interface_name VARCHAR(100), -- empty for the sensor's first capture interface
+    bpf_filter TEXT NOT NULL,
+    duration_seconds INTEGER NOT NULL,
+    max_bytes BIGINT NOT NULL,
+    reason TEXT,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    storage_key VARCHAR(255), -- file name in the capture store, set on upload
+    size_bytes BIGINT,
+    sha256 CHAR(64), -- of the sealed file
+    packets INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    uploaded_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL -- uploaded pcaps are deleted afterwards
+);
+
+CREATE INDEX IF NOT EXISTS idx_diagnostic_captures_tenant ON diagnostic_captures(tenant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_diagnostic_captures_sensor ON diagnostic_captures(sensor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_diagnostic_captures_expiry ON diagnostic_captures(expires_at)
+    WHERE storage_key IS NOT NULL;
+
+-- Requesting and downloading captures needs its own permission: even
+-- redacted, a pcap shows who talks to whom on the network
+INSERT INTO tenant_permissions (name, resource, action, scope, description) VALUES
+('sensors.diagnostics', 'sensors', 'manage', 'tenant', 'Request and download diagnostic packet captures')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO tenant_role_permissions (role_id, permission_id)
+SELECT tr.id, tp.id
+FROM tenant_roles tr
+JOIN tenant_permissions tp ON tp.name = 'sensors.diagnostics'
+WHERE tr.name IN ('tenant_owner', 'tenant_admin')
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -0,0 +1,75 @@
+-- =================================================================
+-- Network Topology and Sensor Coverage (sensor-manager)
+-- =================================================================
+
+-- What each sensor observes, as reported in periodic topology windows.
+-- Rows are upserted per window: counters add up, last_seen_at moves on.
+-- Observations not seen again for 30 days are removed when the sensor
+-- reports next.
+
+-- Subnets on a sensor's capture interfaces, seen in its traffic
+-- (aggregated to /24 and /64) or configured. vlan_id 0 is untagged.
+CREATE TABLE IF NOT EXISTS sensor_subnets (
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    cidr CIDR NOT NULL,
+    vlan_id INTEGER NOT NULL DEFAULT 0,
+    source VARCHAR(20) NOT NULL, -- interface, observed
+    interface_name VARCHAR(100),
+    hosts INTEGER NOT NULL DEFAULT 0, -- most distinct hosts seen in a window
+    packets BIGINT NOT NULL DEFAULT 0,
+    first_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (sensor_id, cidr, vlan_id, source)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_subnets_tenant ON sensor_subnets(tenant_id, last_seen_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sensor_subnets_cidr ON sensor_subnets USING gist (cidr inet_ops);
+
+-- VLAN tags seen in a sensor's traffic or configured on it
+CREATE TABLE IF NOT EXISTS sensor_vlans (
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    vlan_id INTEGER NOT NULL CHECK (vlan_id BETWEEN 1 AND 4094),
+    source VARCHAR(20) NOT NULL, -- observed, configured
+    packets BIGINT NOT NULL DEFAULT 0,
+    first_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (sensor_id, vlan_id, source)
+);
+
+-- Gateways next to a sensor: from its routing table, configured, or
+-- observed in traffic, then known by MAC address only. Empty strings stand
+-- for an unknown address so they can be part of the key.
+CREATE TABLE IF NOT EXISTS sensor_gateways (
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    mac_address VARCHAR(17) NOT NULL DEFAULT '',
+    vlan_id INTEGER NOT NULL DEFAULT 0,
+    source VARCHAR(20) NOT NULL, -- route, configured, observed
+    interface_name VARCHAR(100),
+    subnets INTEGER NOT NULL DEFAULT 0, -- source subnets forwarded
+    first_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (sensor_id, ip_address, mac_address, vlan_id, source),
+    CONSTRAINT gateway_identifier CHECK (ip_address <> '' OR mac_address <> '')
+);
+
+-- Encrypted traffic a sensor saw from one subnet to a service port in
+-- another
+CREATE TABLE IF NOT EXISTS sensor_flows (
+    sensor_id UUID NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    src_cidr CIDR NOT NULL,
+    dst_cidr CIDR NOT NULL,
+    protocol VARCHAR(20) NOT NULL,
+    port INTEGER NOT NULL CHECK (port BETWEEN 1 AND 65535),
+    vlan_id INTEGER NOT NULL DEFAULT 0,
+    packets BIGINT NOT NULL DEFAULT 0,
+    first_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (sensor_id, src_cidr, dst_cidr, protocol, port, vlan_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensor_flows_tenant ON sensor_flows(tenant_id, last_seen_at DESC);
@@ -0,0 +1,20 @@
+-- =================================================================
+-- Certificate Inventory (inventory-service)
+-- =================================================================
+
+-- Key identifiers link a certificate to the one that issued it: a
+-- certificate's authority key identifier is its issuer's subject key
+-- identifier. Both are lowercase hex, NULL when the extension is absent.
+-- Rows stored before these columns existed are completed at read time from
+-- their PEM.
+ALTER TABLE certificates
+    ADD COLUMN IF NOT EXISTS subject_key_id VARCHAR(128),
+    ADD COLUMN IF NOT EXISTS authority_key_id VARCHAR(128);
+
+CREATE INDEX IF NOT EXISTS idx_certificates_tenant_ski ON certificates(tenant_id, subject_key_id) WHERE subject_key_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_certificates_tenant_subject ON certificates(tenant_id, md5(subject_dn));
+CREATE INDEX IF NOT EXISTS idx_certificates_tenant_issuer ON certificates(tenant_id, md5(issuer_dn));
+
+-- Endpoints presenting a certificate
+CREATE INDEX IF NOT EXISTS idx_crypto_implementations_tenant_certificate
+    ON crypto_implementations(tenant_id, certificate_id) WHERE deleted_at IS NULL AND certificate_id IS NOT NULL;
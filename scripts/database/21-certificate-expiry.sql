@@ -0,0 +1,73 @@
+-- =================================================================
+-- Certificate Expiry Reminders and Renewal Tracking (sensor-manager)
+-- =================================================================
+
+-- Every leaf certificate an endpoint (asset and port, 0 when unknown) was
+-- seen presenting. Crypto implementations only keep the latest
+-- certificate, so this is what tells a renewal apart from a new endpoint.
+CREATE TABLE IF NOT EXISTS certificate_endpoints (
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    certificate_id UUID NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    asset_id UUID NOT NULL REFERENCES network_assets(id) ON DELETE CASCADE,
+    port INTEGER NOT NULL DEFAULT 0,
+    first_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (certificate_id, asset_id, port)
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificate_endpoints_endpoint ON certificate_endpoints(asset_id, port, last_seen_at DESC);
+
+INSERT INTO certificate_endpoints (tenant_id, certificate_id, asset_id, port, first_seen_at, last_seen_at)
+SELECT tenant_id, certificate_id, asset_id, COALESCE(port, 0), MIN(first_discovered_at), MAX(last_verified_at)
+FROM crypto_implementations
+WHERE certificate_id IS NOT NULL AND deleted_at IS NULL
+GROUP BY tenant_id, certificate_id, asset_id, COALESCE(port, 0)
+ON CONFLICT DO NOTHING;
+
+-- One reminder per certificate and endpoint serving it, opened when the
+-- certificate crosses its first expiry threshold. threshold_days is the
+-- last threshold notified, 0 once expired; expired_in_use is set when the
+-- certificate is still served after it expired. Reminders close when the
+-- certificate is renewed on the endpoint or no longer served there.
+CREATE TABLE IF NOT EXISTS certificate_expiry_reminders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    certificate_id UUID NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    asset_id UUID NOT NULL REFERENCES network_assets(id) ON DELETE CASCADE,
+    port INTEGER NOT NULL DEFAULT 0,
+    owner_email VARCHAR(255), -- asset owner when the reminder was last notified
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed')),
+    threshold_days INTEGER NOT NULL CHECK (threshold_days >= 0),
+    expired_in_use BOOLEAN NOT NULL DEFAULT FALSE,
+    notified_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMP WITH TIME ZONE,
+    close_reason VARCHAR(30) CHECK (close_reason IN ('renewed', 'no_longer_served')),
+    renewed_by_certificate_id UUID REFERENCES certificates(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_certificate_expiry_reminders_open
+    ON certificate_expiry_reminders(certificate_id, asset_id, port) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_certificate_expiry_reminders_tenant
+    ON certificate_expiry_reminders(tenant_id, status, threshold_days);
+
+CREATE TRIGGER update_certificate_expiry_reminders_updated_at BEFORE UPDATE ON certificate_expiry_reminders
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Lifecycle events of a certificate: expiry notices, use after expiry,
+-- renewals (recorded on both certificates) and endpoints dropping it
+CREATE TABLE IF NOT EXISTS certificate_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    certificate_id UUID NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+    event_type VARCHAR(30) NOT NULL
+        CHECK (event_type IN ('expiry_notified', 'expired_in_use', 'renewed', 'renewal_of', 'no_longer_served')),
+    related_certificate_id UUID REFERENCES certificates(id) ON DELETE SET NULL, -- the other certificate of a renewal
+    asset_id UUID REFERENCES network_assets(id) ON DELETE SET NULL,
+    port INTEGER,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificate_history_certificate ON certificate_history(certificate_id, created_at DESC);
@@ -0,0 +1,52 @@
+-- =================================================================
+-- Risk Rules (inventory-service)
+-- =================================================================
+
+-- Versioned risk rule overrides of each tenant. A tenant's rules are the
+-- platform's default rules, built into the inventory service, adjusted by
+-- its latest version here. document is the validated override document
+-- stored as JSON; source is the document as submitted, YAML or JSON.
+CREATE TABLE IF NOT EXISTS risk_rule_sets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    document JSONB NOT NULL,
+    source TEXT NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    comment TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT valid_risk_rule_format CHECK (format IN ('yaml', 'json')),
+    CONSTRAINT unique_risk_rule_version UNIQUE (tenant_id, version)
+);
+
+-- risk_breakdown lists the rules that make up risk_score. risk_rule_version
+-- identifies the rules it was computed with, such as default-v1/tenant-v3;
+-- scores computed with other rules, or before the implementation last
+-- changed, are recomputed.
+ALTER TABLE crypto_implementations
+    ADD COLUMN IF NOT EXISTS risk_breakdown JSONB,
+    ADD COLUMN IF NOT EXISTS risk_rule_version VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS risk_evaluated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_crypto_implementations_risk_version
+    ON crypto_implementations(tenant_id, risk_rule_version) WHERE deleted_at IS NULL;
+
+-- Recomputing a score does not change an implementation: updated_at, and
+-- with it the ETag clients hold, only moves when other columns change
+CREATE OR REPLACE FUNCTION update_crypto_implementations_updated_at()
+RETURNS TRIGGER AS $$
+DECLARE
+    risk_columns TEXT[] := ARRAY['risk_score', 'risk_breakdown', 'risk_rule_version', 'risk_evaluated_at', 'updated_at'];
+BEGIN
+    IF (to_jsonb(NEW) - risk_columns) IS DISTINCT FROM (to_jsonb(OLD) - risk_columns) THEN
+        NEW.updated_at = NOW();
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS update_crypto_implementations_updated_at ON crypto_implementations;
+CREATE TRIGGER update_crypto_implementations_updated_at BEFORE UPDATE ON crypto_implementations
+    FOR EACH ROW EXECUTE FUNCTION update_crypto_implementations_updated_at();
@@ -0,0 +1,37 @@
+-- =================================================================
+-- Scan Result Imports (inventory-service)
+-- =================================================================
+
+-- Results of external scanners (nmap, sslscan, testssl.sh, sslyze)
+-- uploaded to seed the inventory. An upload is stored as a job and imported
+-- by a background worker: pending -> processing -> completed / failed.
+-- Every host and port of the scan is a row; rows that cannot be imported
+-- are listed in row_errors without failing the job. A dry run reports what
+-- the import would create without writing to the inventory.
+CREATE TABLE IF NOT EXISTS scan_imports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    format VARCHAR(20) NOT NULL,
+    filename VARCHAR(255),
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    row_count INTEGER NOT NULL DEFAULT 0,
+    imported_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    summary JSONB NOT NULL DEFAULT '{}', -- assets, certificates and implementations created or matched
+    row_errors JSONB NOT NULL DEFAULT '[]', -- rows that were not imported and why
+    payload BYTEA, -- uploaded scan, cleared once the import is completed
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT valid_scan_import_status CHECK (status IN ('pending', 'processing', 'completed', 'failed')),
+    CONSTRAINT valid_scan_import_format CHECK (format IN ('nmap', 'sslscan', 'testssl', 'sslyze'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_imports_claimable ON scan_imports(created_at)
+    WHERE status IN ('pending', 'processing');
+CREATE INDEX IF NOT EXISTS idx_scan_imports_tenant ON scan_imports(tenant_id, created_at DESC);
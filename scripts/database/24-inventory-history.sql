@@ -0,0 +1,131 @@
+-- =================================================================
+-- Inventory Change History (inventory-service, sensor-manager)
+-- =================================================================
+
+-- Every version of the tenant's assets, crypto implementations and
+-- certificates. changes holds the fields a version changed as
+-- {"field": {"old": ..., "new": ...}}, every set field for the first
+-- version; state is the record as of the version. Columns that move with
+-- every observation, such as last_seen_at, are not tracked.
+-- source is who made the change: a user through the API, a sensor, an
+-- import or the system, such as risk recomputation after a rule change.
+-- reference names what the change came from, such as sensor:<id>,
+-- scan_import:<id> or cbom:<serial number>.
+CREATE TABLE IF NOT EXISTS inventory_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    entity_type VARCHAR(30) NOT NULL,
+    entity_id UUID NOT NULL,
+    asset_id UUID, -- the asset, or the implementation's asset; kept after the asset is gone
+    version INTEGER NOT NULL,
+    operation VARCHAR(20) NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    state JSONB NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reference TEXT,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT valid_change_entity CHECK (entity_type IN ('asset', 'crypto_implementation', 'certificate')),
+    CONSTRAINT valid_change_operation CHECK (operation IN ('created', 'updated', 'deleted', 'restored')),
+    CONSTRAINT valid_change_source CHECK (source IN ('user', 'sensor', 'import', 'system')),
+    CONSTRAINT unique_change_version UNIQUE (entity_type, entity_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_changes_tenant ON inventory_changes(tenant_id, changed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_inventory_changes_asset ON inventory_changes(asset_id, changed_at DESC) WHERE asset_id IS NOT NULL;
+
+-- Records a version of the row. The first trigger argument is the entity
+-- type, the others the columns not tracked. Writers name the source of a
+-- transaction's changes with
+--   set_config('inventory.change_source', 'sensor', true)
+-- and likewise inventory.changed_by (a user ID) and
+-- inventory.change_reference; changes without a source are the system's.
+-- Updates that only change untracked columns are not recorded. Rows are never hard
+-- deleted other than with their tenant, so deletes are not recorded.
+CREATE OR REPLACE FUNCTION record_inventory_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    entity TEXT := TG_ARGV[0];
+    untracked TEXT[] := ARRAY['id', 'tenant_id', 'created_at', 'updated_at'] || TG_ARGV[1:TG_NARGS - 1];
+    old_row JSONB := '{}';
+    new_row JSONB := to_jsonb(NEW) - untracked;
+    diff JSONB := '{}';
+    field TEXT;
+    change_operation TEXT := 'created';
+    change_source TEXT := COALESCE(NULLIF(current_setting('inventory.change_source', true), ''), 'system');
+    next_version INTEGER;
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        old_row := to_jsonb(OLD) - untracked;
+        change_operation := CASE
+            WHEN old_row->>'deleted_at' IS NULL AND new_row->>'deleted_at' IS NOT NULL THEN 'deleted'
+            WHEN old_row->>'deleted_at' IS NOT NULL AND new_row->>'deleted_at' IS NULL THEN 'restored'
+            ELSE 'updated'
+        END;
+    END IF;
+
+    -- A first version lists its fields that are set
+    FOR field IN SELECT jsonb_object_keys(new_row) LOOP
+        CONTINUE WHEN (new_row->field) = COALESCE(old_row->field, 'null'::jsonb);
+        diff := diff || jsonb_build_object(field, jsonb_build_object('old', COALESCE(old_row->field, 'null'::jsonb), 'new', new_row->field));
+    END LOOP;
+    IF TG_OP = 'UPDATE' AND diff = '{}' THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT COALESCE(MAX(version), 0) + 1 INTO next_version
+    FROM inventory_changes
+    WHERE entity_type = entity AND entity_id = NEW.id;
+
+    INSERT INTO inventory_changes (tenant_id, entity_type, entity_id, asset_id, version, operation, changes, state,
+                                   source, changed_by, reference)
+    VALUES (
+        NEW.tenant_id, entity, NEW.id,
+        CASE entity WHEN 'asset' THEN NEW.id WHEN 'crypto_implementation' THEN (to_jsonb(NEW)->>'asset_id')::uuid END,
+        next_version, change_operation, diff, new_row,
+        CASE WHEN change_source IN ('user', 'sensor', 'import') THEN change_source ELSE 'system' END,
+        NULLIF(current_setting('inventory.changed_by', true), '')::uuid,
+        NULLIF(current_setting('inventory.change_reference', true), '')
+    );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS record_network_assets_change ON network_assets;
+CREATE TRIGGER record_network_assets_change AFTER INSERT OR UPDATE ON network_assets
+    FOR EACH ROW EXECUTE FUNCTION record_inventory_change('asset', 'first_discovered_at', 'last_seen_at');
+
+DROP TRIGGER IF EXISTS record_crypto_implementations_change ON crypto_implementations;
+CREATE TRIGGER record_crypto_implementations_change AFTER INSERT OR UPDATE ON crypto_implementations
+    FOR EACH ROW EXECUTE FUNCTION record_inventory_change('crypto_implementation',
+        'first_discovered_at', 'last_verified_at', 'raw_data', 'source_sensor_id',
+        'risk_breakdown', 'risk_rule_version', 'risk_evaluated_at');
+
+DROP TRIGGER IF EXISTS record_certificates_change ON certificates;
+CREATE TRIGGER record_certificates_change AFTER INSERT OR UPDATE ON certificates
+    FOR EACH ROW EXECUTE FUNCTION record_inventory_change('certificate', 'certificate_pem');
+
+-- Records that existed before the history start with a version as of
+-- their creation
+INSERT INTO inventory_changes (tenant_id, entity_type, entity_id, asset_id, version, operation, state, source, reference, changed_at)
+SELECT tenant_id, 'asset', id, id, 1, 'created',
+       to_jsonb(a) - ARRAY['id', 'tenant_id', 'created_at', 'updated_at', 'first_discovered_at', 'last_seen_at'],
+       'system', 'baseline', COALESCE(created_at, NOW())
+FROM network_assets a
+ON CONFLICT DO NOTHING;
+
+INSERT INTO inventory_changes (tenant_id, entity_type, entity_id, asset_id, version, operation, state, source, reference, changed_at)
+SELECT tenant_id, 'crypto_implementation', id, asset_id, 1, 'created',
+       to_jsonb(ci) - ARRAY['id', 'tenant_id', 'created_at', 'updated_at', 'first_discovered_at', 'last_verified_at',
+                            'raw_data', 'source_sensor_id', 'risk_breakdown', 'risk_rule_version', 'risk_evaluated_at'],
+       'system', 'baseline', COALESCE(created_at, NOW())
+FROM crypto_implementations ci
+ON CONFLICT DO NOTHING;
+
+INSERT INTO inventory_changes (tenant_id, entity_type, entity_id, version, operation, state, source, reference, changed_at)
+SELECT tenant_id, 'certificate', id, 1, 'created',
+       to_jsonb(c) - ARRAY['id', 'tenant_id', 'created_at', 'updated_at', 'certificate_pem'],
+       'system', 'baseline', COALESCE(created_at, NOW())
+FROM certificates c
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,27 @@
+-- =================================================================
+-- Inventory Snapshots (inventory-service)
+-- =================================================================
+
+-- Named points in time of a tenant's inventory, such as the end of a
+-- quarter for a compliance attestation. The inventory as of taken_at is
+-- read from inventory_changes, so snapshots store no copy of it; summary
+-- holds its counts as of taken_at.
+CREATE TABLE IF NOT EXISTS inventory_snapshots (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    summary JSONB NOT NULL DEFAULT '{}',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT unique_snapshot_name UNIQUE (tenant_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_snapshots_tenant ON inventory_snapshots(tenant_id, taken_at DESC);
+
+-- The inventory as of a time is the latest version of each record changed
+-- by then
+CREATE INDEX IF NOT EXISTS idx_inventory_changes_versions
+    ON inventory_changes(tenant_id, entity_type, entity_id, version DESC);
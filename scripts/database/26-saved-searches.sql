@@ -0,0 +1,22 @@
+-- =================================================================
+-- Saved Searches (inventory-service)
+-- =================================================================
+
+-- Inventory queries saved by a user under a name, such as
+-- `protocol:TLS AND version<1.2 AND env:production`. Saved searches are
+-- private to the user who saved them.
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT unique_saved_search_name UNIQUE (tenant_id, user_id, name)
+);
+
+CREATE TRIGGER update_saved_searches_updated_at BEFORE UPDATE ON saved_searches
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
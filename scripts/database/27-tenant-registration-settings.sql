@@ -0,0 +1,30 @@
+-- =================================================================
+-- Per-Tenant Registration Settings (sensor-manager)
+-- =================================================================
+
+-- Registration settings were a single row shared by every tenant. Each
+-- tenant now keeps its own; tenants without a row use the defaults.
+CREATE TABLE IF NOT EXISTS sensor_registration_settings (
+    tenant_id UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    key_expiration_minutes INTEGER NOT NULL DEFAULT 60,
+    max_pending_sensors INTEGER NOT NULL DEFAULT 50,
+    require_ip_validation BOOLEAN NOT NULL DEFAULT true,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Carry the shared settings over to every existing tenant. Trusted
+-- proxies describe the deployment rather than a tenant and are configured
+-- with TRUSTED_PROXIES instead.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'sensor_manager_settings') THEN
+        INSERT INTO sensor_registration_settings (tenant_id, key_expiration_minutes, max_pending_sensors,
+                                                  require_ip_validation)
+        SELECT t.id, s.key_expiration_minutes, s.max_pending_sensors, s.require_ip_validation
+        FROM tenants t CROSS JOIN sensor_manager_settings s
+        WHERE s.id
+        ON CONFLICT (tenant_id) DO NOTHING;
+        DROP TABLE sensor_manager_settings;
+    END IF;
+END $$;
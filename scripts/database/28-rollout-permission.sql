@@ -0,0 +1,17 @@
+-- =================================================================
+-- Staged Rollout Permission (sensor-manager)
+-- =================================================================
+
+-- Starting, pausing, resuming and cancelling rollouts needs its own
+-- permission: a rollout replaces the binary running on every sensor it
+-- reaches
+INSERT INTO tenant_permissions (name, resource, action, scope, description) VALUES
+('sensors.rollouts', 'sensors', 'manage', 'tenant', 'Start and control staged rollouts of sensor releases')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO tenant_role_permissions (role_id, permission_id)
+SELECT tr.id, tp.id
+FROM tenant_roles tr
+JOIN tenant_permissions tp ON tp.name = 'sensors.rollouts'
+WHERE tr.name IN ('tenant_owner', 'tenant_admin')
+ON CONFLICT (role_id, permission_id) DO NOTHING;
@@ -1,82 +1,146 @@
+//go:build ignore
+
 package main
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
-// RegistrationKey represents a sensor registration key
-type RegistrationKey struct {
-	Key         string    `json:"key"`
-	TenantID    string    `json:"tenant_id"`
-	Description string    `json:"description"`
-	ExpiresAt   time.Time `json:"expires_at"`
-	CreatedAt   time.Time `json:"created_at"`
-	MaxSensors  int       `json:"max_sensors"`
-	UsedCount   int       `json:"used_count"`
+// createKeyRequest is the body of POST /api/v1/sensors/pending
+type createKeyRequest struct {
+	Name              string   `json:"name"`
+	Description       string   `json:"description,omitempty"`
+	AllowedCIDRs      []string `json:"allowed_cidrs,omitempty"`
+	MaxSensors        int      `json:"max_sensors"`
+	ExpiresInMinutes  int      `json:"expires_in_minutes,omitempty"`
+	Profile           string   `json:"profile"`
+	Tags              []string `json:"tags,omitempty"`
+	NetworkInterfaces []string `json:"network_interfaces,omitempty"`
+	GroupID           string   `json:"group_id,omitempty"`
+}
+
+// createKeyResponse is the part of the sensor-manager response this tool uses
+type createKeyResponse struct {
+	PendingSensor struct {
+		ID              string    `json:"id"`
+		TenantID        string    `json:"tenant_id"`
+		RegistrationKey string    `json:"registration_key"`
+		AllowedCIDRs    []string  `json:"allowed_cidrs"`
+		MaxSensors      int       `json:"max_sensors"`
+		ExpiresAt       time.Time `json:"expires_at"`
+	} `json:"pending_sensor"`
+	Error string `json:"error"`
 }
 
+// Registration keys are issued by the sensor-manager for the tenant of the
+// authenticated user; the key is hashed server-side and only shown here once.
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run generate-registration-key.go <tenant-id> [description] [max-sensors]")
-		fmt.Println("Example: go run generate-registration-key.go tenant-123 'Datacenter Sensors' 10")
+	url := flag.String("url", "http://localhost:8085", "sensor-manager base URL")
+	token := flag.String("token", os.Getenv("CRYPTO_INVENTORY_TOKEN"), "access token (defaults to $CRYPTO_INVENTORY_TOKEN)")
+	name := flag.String("name", "", "key name (required)")
+	description := flag.String("description", "", "key description")
+	cidr := flag.String("cidr", "", "comma-separated networks sensors must register from, e.g. 10.0.1.0/24,203.0.113.0/28")
+	maxSensors := flag.Int("max-sensors", 1, "number of sensors the key may register")
+	expires := flag.Duration("expires", 0, "key lifetime, e.g. 72h (defaults to the configured lifetime)")
+	profile := flag.String("profile", "datacenter_host", "deployment profile")
+	tags := flag.String("tags", "", "comma-separated tags applied to registered sensors")
+	interfaces := flag.String("interfaces", "", "comma-separated network interfaces to monitor")
+	group := flag.String("group", "", "sensor group ID registered sensors join")
+	flag.Parse()
+
+	if *name == "" || *token == "" {
+		fmt.Println("Usage: go run generate-registration-key.go -token <access-token> -name <name> [-cidr 10.0.1.0/24] [-max-sensors 10] [-expires 72h]")
+		fmt.Println("Example: go run generate-registration-key.go -token $TOKEN -name 'Datacenter Sensors' -cidr 10.0.1.0/24 -max-sensors 10")
 		os.Exit(1)
 	}
 
-	tenantID := os.Args[1]
-	description := "Sensor Registration Key"
-	maxSensors := 1
-
-	if len(os.Args) > 2 {
-		description = os.Args[2]
+	body, err := json.Marshal(createKeyRequest{
+		Name:              *name,
+		Description:       *description,
+		AllowedCIDRs:      splitList(*cidr),
+		MaxSensors:        *maxSensors,
+		ExpiresInMinutes:  int(expires.Minutes()),
+		Profile:           *profile,
+		Tags:              splitList(*tags),
+		NetworkInterfaces: splitList(*interfaces),
+		GroupID:           *group,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to encode request: %v\n", err)
+		os.Exit(1)
 	}
-	if len(os.Args) > 3 {
-		fmt.Sscanf(os.Args[3], "%d", &maxSensors)
+
+	req, err := http.NewRequest("POST", strings.TrimRight(*url, "/")+"/api/v1/sensors/pending", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid URL: %v\n", err)
+		os.Exit(1)
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+*token)
 
-	// Generate random key components
-	keyBytes := make([]byte, 8)
-	rand.Read(keyBytes)
-	keySuffix := hex.EncodeToString(keyBytes)[:6]
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Request failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
 
-	// Create registration key
-	now := time.Now()
-	key := fmt.Sprintf("REG-%s-%s-%s",
-		tenantID[:8],
-		now.Format("20060102"),
-		keySuffix)
+	var result createKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid response (HTTP %d): %v\n", resp.StatusCode, err)
+		os.Exit(1)
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create registration key (HTTP %d): %s\n", resp.StatusCode, result.Error)
+		os.Exit(1)
+	}
 
-	regKey := RegistrationKey{
-		Key:         key,
-		TenantID:    tenantID,
-		Description: description,
-		ExpiresAt:   now.Add(30 * 24 * time.Hour), // 30 days
-		CreatedAt:   now,
-		MaxSensors:  maxSensors,
-		UsedCount:   0,
+	key := result.PendingSensor
+	allowed := strings.Join(key.AllowedCIDRs, ", ")
+	if allowed == "" {
+		allowed = "any"
 	}
 
 	// Output registration key
 	fmt.Printf("🔑 Sensor Registration Key Generated\n")
 	fmt.Printf("=====================================\n")
-	fmt.Printf("Key: %s\n", regKey.Key)
-	fmt.Printf("Tenant ID: %s\n", regKey.TenantID)
-	fmt.Printf("Description: %s\n", regKey.Description)
-	fmt.Printf("Expires: %s\n", regKey.ExpiresAt.Format("2006-01-02 15:04:05"))
-	fmt.Printf("Max Sensors: %d\n", regKey.MaxSensors)
+	fmt.Printf("Key: %s\n", key.RegistrationKey)
+	fmt.Printf("Key ID: %s\n", key.ID)
+	fmt.Printf("Tenant ID: %s\n", key.TenantID)
+	fmt.Printf("Allowed Networks: %s\n", allowed)
+	fmt.Printf("Expires: %s\n", key.ExpiresAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Max Sensors: %d\n", key.MaxSensors)
+	fmt.Printf("\n")
+	fmt.Printf("⚠️  Store the key now, it cannot be retrieved again.\n")
 	fmt.Printf("\n")
 	fmt.Printf("📋 Installation Command:\n")
-	fmt.Printf("curl -X POST http://localhost:8085/api/v1/sensors/register \\\n")
+	fmt.Printf("curl -X POST %s/api/v1/sensors/register \\\n", strings.TrimRight(*url, "/"))
 	fmt.Printf("  -H \"Content-Type: application/json\" \\\n")
 	fmt.Printf("  -d '{\n")
-	fmt.Printf("    \"registration_key\": \"%s\",\n", regKey.Key)
+	fmt.Printf("    \"registration_key\": \"%s\",\n", key.RegistrationKey)
 	fmt.Printf("    \"name\": \"sensor-<hostname>\",\n")
+	fmt.Printf("    \"ip_address\": \"<sensor-ip>\",\n")
 	fmt.Printf("    \"platform\": \"linux\",\n")
 	fmt.Printf("    \"version\": \"1.0.0\",\n")
-	fmt.Printf("    \"profile\": \"datacenter_host\",\n")
+	fmt.Printf("    \"profile\": \"%s\",\n", *profile)
 	fmt.Printf("    \"network_interfaces\": [\"eth0\", \"eth1\"]\n")
 	fmt.Printf("  }'\n")
 }
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,172 @@
+//go:build ignore
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scanImport is the part of an inventory-service import job this tool uses
+type scanImport struct {
+	ID            string `json:"id"`
+	Format        string `json:"format"`
+	DryRun        bool   `json:"dry_run"`
+	Status        string `json:"status"`
+	RowCount      int    `json:"row_count"`
+	ImportedCount int    `json:"imported_count"`
+	FailedCount   int    `json:"failed_count"`
+	Summary       struct {
+		AssetsCreated           int `json:"assets_created"`
+		AssetsMatched           int `json:"assets_matched"`
+		CertificatesCreated     int `json:"certificates_created"`
+		CertificatesMatched     int `json:"certificates_matched"`
+		ImplementationsCreated  int `json:"implementations_created"`
+		ImplementationsExisting int `json:"implementations_existing"`
+	} `json:"summary"`
+	Errors []struct {
+		Row    int    `json:"row"`
+		Target string `json:"target"`
+		Reason string `json:"reason"`
+	} `json:"errors"`
+	LastError string `json:"last_error"`
+}
+
+type importResponse struct {
+	Import  scanImport `json:"import"`
+	Error   string     `json:"error"`
+	Details string     `json:"details"`
+}
+
+// Scan reports are uploaded to the inventory-service, which imports them in
+// the background; with -wait the tool follows the job and prints its
+// summary and the rows that were not imported.
+func main() {
+	baseURL := flag.String("url", "http://localhost:8082", "inventory-service base URL")
+	token := flag.String("token", os.Getenv("CRYPTO_INVENTORY_TOKEN"), "access token (defaults to $CRYPTO_INVENTORY_TOKEN)")
+	file := flag.String("file", "", "scan report to import, - for stdin (required)")
+	format := flag.String("format", "", "nmap, sslscan, testssl or sslyze (detected from the report when omitted)")
+	dryRun := flag.Bool("dry-run", false, "report what the import would change without changing the inventory")
+	wait := flag.Bool("wait", true, "wait for the import to finish and print its report")
+	timeout := flag.Duration("timeout", 30*time.Minute, "how long -wait waits")
+	flag.Parse()
+
+	if *file == "" || *token == "" {
+		fmt.Println("Usage: go run import-scan-results.go -token <access-token> -file <report> [-format nmap|sslscan|testssl|sslyze] [-dry-run]")
+		fmt.Println("Example: nmap -p 443,22 --script ssl-enum-ciphers,ssl-cert,ssh2-enum-algos -oX scan.xml 10.0.1.0/24")
+		fmt.Println("         go run import-scan-results.go -token $TOKEN -file scan.xml -dry-run")
+		os.Exit(1)
+	}
+
+	var report io.Reader = os.Stdin
+	name := ""
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		report, name = f, filepath.Base(*file)
+	}
+
+	query := url.Values{}
+	if *format != "" {
+		query.Set("format", *format)
+	}
+	if *dryRun {
+		query.Set("dry_run", "true")
+	}
+	if name != "" {
+		query.Set("filename", name)
+	}
+	base := strings.TrimRight(*baseURL, "/") + "/api/v1/imports"
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	job, err := call(client, "POST", base+"?"+query.Encode(), *token, report, http.StatusAccepted)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to upload scan report: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("📤 Uploaded %s report as import %s (%d rows)\n", job.Format, job.ID, job.RowCount)
+	if !*wait {
+		return
+	}
+
+	deadline := time.Now().Add(*timeout)
+	for job.Status == "pending" || job.Status == "processing" {
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "❌ Import %s still %s after %s\n", job.ID, job.Status, *timeout)
+			os.Exit(1)
+		}
+		time.Sleep(2 * time.Second)
+		if job, err = call(client, "GET", base+"/"+job.ID, *token, nil, http.StatusOK); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to read import: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if job.Status == "failed" {
+		fmt.Fprintf(os.Stderr, "❌ Import %s failed: %s\n", job.ID, job.LastError)
+		os.Exit(1)
+	}
+
+	// Output import report
+	title := "📥 Scan Import Completed"
+	if job.DryRun {
+		title = "🔍 Scan Import Dry Run (inventory unchanged)"
+	}
+	s := job.Summary
+	fmt.Printf("%s\n", title)
+	fmt.Printf("=====================================\n")
+	fmt.Printf("Rows: %d imported, %d failed\n", job.ImportedCount, job.FailedCount)
+	fmt.Printf("Assets: %d new, %d existing\n", s.AssetsCreated, s.AssetsMatched)
+	fmt.Printf("Certificates: %d new, %d existing\n", s.CertificatesCreated, s.CertificatesMatched)
+	fmt.Printf("Crypto implementations: %d new, %d existing\n", s.ImplementationsCreated, s.ImplementationsExisting)
+	if len(job.Errors) > 0 {
+		fmt.Printf("\n")
+		fmt.Printf("⚠️  Rows not imported:\n")
+		for _, rowError := range job.Errors {
+			fmt.Printf("  row %d %s: %s\n", rowError.Row, rowError.Target, rowError.Reason)
+		}
+		os.Exit(2)
+	}
+}
+
+// call sends a request to the import API and decodes the job it returns
+func call(client *http.Client, method, target, token string, body io.Reader, want int) (*scanImport, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result importResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid response (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if resp.StatusCode != want {
+		if result.Details != "" {
+			return nil, fmt.Errorf("HTTP %d: %s: %s", resp.StatusCode, result.Error, result.Details)
+		}
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, result.Error)
+	}
+	return &result.Import, nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/config"
+	"github.com/democorp/crypto-inventory/sensor/internal/status"
+)
+
+// Exit codes of the subcommands; status exits with cliUnhealthy so it can
+// serve as a health check
+const (
+	cliOK        = 0
+	cliUnhealthy = 1
+	cliFailed    = 2
+)
+
+const cliUsage = `Usage: crypto-sensor [flags] <command>
+
+Commands talk to the running sensor through its local status API:
+  status [-json] [-q]            Show the sensor's state; exits 1 when unhealthy
+  discoveries tail [-n N] [-f]   Show recent discoveries, -f to follow
+  flush                          Upload the discovery backlog and send a heartbeat now
+  config show                    Show the configuration in effect, secrets removed
+
+Each command accepts -addr to override STATUS_LISTEN.
+`
+
+// runCLI runs a subcommand against the running sensor and returns its exit
+// code
+func runCLI(args []string) int {
+	var err error
+	code := cliOK
+	switch {
+	case args[0] == "status":
+		code, err = cliStatus(args[1:])
+	case args[0] == "flush":
+		err = cliFlush(args[1:])
+	case args[0] == "discoveries" && len(args) > 1 && args[1] == "tail":
+		err = cliDiscoveriesTail(args[2:])
+	case args[0] == "config" && len(args) > 1 && args[1] == "show":
+		err = cliConfigShow(args[2:])
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return cliFailed
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "crypto-sensor: %v\n", err)
+		return cliFailed
+	}
+	return code
+}
+
+// cliFlags creates the flag set of a subcommand with its -addr flag
+func cliFlags(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	addr := flags.String("addr", "", "Status API address (default: STATUS_LISTEN)")
+	return flags, addr
+}
+
+// cliClient connects to the status API at addr, or where the sensor's
+// configuration says it listens
+func cliClient(addr string) (*status.Client, error) {
+	if addr == "" {
+		addr = config.Load().StatusListen
+	}
+	if addr == "" || addr == "off" {
+		return nil, fmt.Errorf("the status API is disabled (STATUS_LISTEN=off)")
+	}
+	return status.NewClient(addr)
+}
+
+func cliStatus(args []string) (int, error) {
+	flags, addr := cliFlags("status")
+	asJSON := flags.Bool("json", false, "Print the full status as JSON")
+	quiet := flags.Bool("q", false, "Print nothing; only set the exit code")
+	if err := flags.Parse(args); err != nil {
+		return cliFailed, err
+	}
+	client, err := cliClient(*addr)
+	if err != nil {
+		return cliFailed, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report, err := client.Status(ctx)
+	if err != nil {
+		return cliFailed, err
+	}
+
+	code := cliOK
+	if !report.Healthy {
+		code = cliUnhealthy
+	}
+	switch {
+	case *quiet:
+	case *asJSON:
+		printJSON(report)
+	default:
+		printStatus(os.Stdout, report)
+	}
+	return code, nil
+}
+
+func printStatus(out io.Writer, report *status.Report) {
+	health := "healthy"
+	if !report.Healthy {
+		health = "UNHEALTHY"
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Sensor:\t%s (%s)\n", report.SensorID, report.Name)
+	fmt.Fprintf(w, "Health:\t%s\n", health)
+	for _, problem := range report.Problems {
+		fmt.Fprintf(w, "\t- %s\n", problem)
+	}
+	fmt.Fprintf(w, "Version:\t%s (%s)\n", report.Version, report.Platform)
+	fmt.Fprintf(w, "Uptime:\t%s\n", time.Duration(report.UptimeSeconds)*time.Second)
+	fmt.Fprintf(w, "Registered:\t%t\n", report.Registered)
+	fmt.Fprintf(w, "Profile:\t%s (config version %d)\n", report.Profile, report.ConfigVersion)
+	if report.LastHeartbeat != nil {
+		fmt.Fprintf(w, "Last heartbeat:\t%s (%s ago)\n", report.LastHeartbeat.Format(time.RFC3339),
+			time.Since(*report.LastHeartbeat).Round(time.Second))
+	} else {
+		fmt.Fprintf(w, "Last heartbeat:\tnever\n")
+	}
+	if report.LastError != "" {
+		fmt.Fprintf(w, "Last error:\t%s\n", report.LastError)
+	}
+	fmt.Fprintf(w, "Capturing:\t%t\n", report.Capturing)
+	fmt.Fprintf(w, "Packets:\t%d received, %d dropped\n", report.Capture.PacketsReceived, report.Capture.PacketsDropped)
+	fmt.Fprintf(w, "Discoveries:\t%d awaiting upload, %d dropped\n", report.Backlog, report.Capture.DiscoveriesDropped)
+	fmt.Fprintf(w, "Storage used:\t%d bytes\n", report.StorageUsedBytes)
+	w.Flush()
+
+	if len(report.Interfaces) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INTERFACE\tCAPTURING\tRECEIVED\tDROPPED")
+	for _, iface := range report.Interfaces {
+		fmt.Fprintf(w, "%s\t%t\t%d\t%d\n", iface.Interface, iface.Capturing, iface.PacketsReceived, iface.PacketsDropped)
+	}
+	w.Flush()
+}
+
+func cliDiscoveriesTail(args []string) error {
+	flags, addr := cliFlags("discoveries tail")
+	count := flags.Int("n", 20, "Number of recent discoveries to show")
+	follow := flags.Bool("f", false, "Keep showing new discoveries")
+	asJSON := flags.Bool("json", false, "Print one JSON object per discovery")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *count < 1 || *count > 1000 {
+		return fmt.Errorf("-n must be between 1 and 1000")
+	}
+	client, err := cliClient(*addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var after uint64
+	limit := *count
+	for {
+		callCtx, callCancel := context.WithTimeout(ctx, 10*time.Second)
+		discoveries, err := client.Discoveries(callCtx, after, limit)
+		callCancel()
+		if err != nil {
+			return err
+		}
+		for _, item := range discoveries {
+			printDiscovery(item, *asJSON)
+			after = item.Seq
+		}
+		if !*follow {
+			return nil
+		}
+		// Once caught up, show everything that arrives
+		limit = 1000
+		time.Sleep(time.Second)
+	}
+}
+
+func printDiscovery(item status.Discovery, asJSON bool) {
+	d := item.Discovery
+	if asJSON {
+		data, _ := json.Marshal(d)
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("%s  %-4s %s -> %s:%d  %s %s (confidence %.2f)\n",
+		d.Timestamp.Format(time.RFC3339), d.Protocol, d.SourceIP, d.DestIP, d.Port,
+		d.Version, d.CipherSuite, d.Confidence)
+}
+
+func cliFlush(args []string) error {
+	flags, addr := cliFlags("flush")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	client, err := cliClient(*addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	result, err := client.Flush(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Submitted %d discoveries, %d awaiting upload\n", result.Submitted, result.Backlog)
+	if result.Error != "" {
+		return fmt.Errorf("flush incomplete: %s", result.Error)
+	}
+	return nil
+}
+
+func cliConfigShow(args []string) error {
+	flags, addr := cliFlags("config show")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	client, err := cliClient(*addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg, err := client.Config(ctx)
+	if err != nil {
+		return err
+	}
+	printJSON(cfg)
+	return nil
+}
+
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
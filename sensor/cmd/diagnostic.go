@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/capture"
+	"github.com/democorp/crypto-inventory/sensor/internal/models"
+)
+
+const (
+	// maxDiagnosticDuration and maxDiagnosticBytes bound a diagnostic
+	// capture regardless of what the control plane asks for
+	maxDiagnosticDuration = 5 * time.Minute
+	maxDiagnosticBytes    = 50 << 20
+)
+
+// errCommandRunning is returned by handlers of commands that finish in the
+// background; they report the command themselves
+var errCommandRunning = errors.New("command running")
+
+// diagnosticPayload is the payload of a diagnostic_capture command
+type diagnosticPayload struct {
+	CaptureID       string `json:"capture_id"`
+	Interface       string `json:"interface"`
+	BPFFilter       string `json:"bpf_filter"`
+	DurationSeconds int    `json:"duration_seconds"`
+	MaxBytes        int64  `json:"max_bytes"`
+	PublicKey       string `json:"public_key"` // capture key of the control plane
+}
+
+// handleDiagnosticCaptureCommand starts a redacted packet capture that is
+// sealed to the control plane's capture key and uploaded. The capture runs
+// next to the regular one, in the background; one diagnostic capture runs at
+// a time.
+func (s *Sensor) handleDiagnosticCaptureCommand(command models.Command) (map[string]interface{}, error) {
+	if s.diagnosticCapture == command.ID {
+		// Redelivered while still running
+		return nil, errCommandRunning
+	}
+	if s.diagnosticCapture != "" {
+		return nil, errors.New("another diagnostic capture is running")
+	}
+
+	data, err := json.Marshal(command.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid diagnostic_capture payload: %v", err)
+	}
+	var payload diagnosticPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid diagnostic_capture payload: %v", err)
+	}
+	opts, err := s.diagnosticOptions(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔬 Starting diagnostic capture %s on %s for %s: %s",
+		payload.CaptureID, opts.Interface, opts.Duration, opts.Filter)
+	s.diagnosticCapture = command.ID
+	go s.runDiagnosticCapture(command, payload, opts)
+	return nil, errCommandRunning
+}
+
+// diagnosticOptions validates a capture request against the sensor's limits
+// and capture interfaces
+func (s *Sensor) diagnosticOptions(payload *diagnosticPayload) (capture.DiagnosticOptions, error) {
+	opts := capture.DiagnosticOptions{
+		Interface: payload.Interface,
+		Filter:    payload.BPFFilter,
+		Duration:  time.Duration(payload.DurationSeconds) * time.Second,
+		MaxBytes:  payload.MaxBytes,
+	}
+	if payload.CaptureID == "" || payload.PublicKey == "" {
+		return opts, errors.New("diagnostic_capture payload has no capture_id or public_key")
+	}
+	if opts.Filter == "" {
+		return opts, errors.New("diagnostic_capture payload has no bpf_filter")
+	}
+	if opts.Duration <= 0 || opts.Duration > maxDiagnosticDuration {
+		return opts, fmt.Errorf("capture duration must be between 1s and %s", maxDiagnosticDuration)
+	}
+	if opts.MaxBytes <= 0 || opts.MaxBytes > maxDiagnosticBytes {
+		return opts, fmt.Errorf("capture byte limit must be between 1 and %d", maxDiagnosticBytes)
+	}
+
+	s.mu.RLock()
+	interfaces := s.config.Capture.Interfaces
+	s.mu.RUnlock()
+	if len(interfaces) == 0 {
+		return opts, errors.New("no capture interfaces configured")
+	}
+	if opts.Interface == "" {
+		opts.Interface = interfaces[0]
+		return opts, nil
+	}
+	for _, iface := range interfaces {
+		if iface == opts.Interface {
+			return opts, nil
+		}
+	}
+	return opts, fmt.Errorf("interface %s is not a capture interface of this sensor", opts.Interface)
+}
+
+// runDiagnosticCapture takes, seals and uploads a diagnostic capture, then
+// reports its command. The pcap is only held in memory.
+func (s *Sensor) runDiagnosticCapture(command models.Command, payload diagnosticPayload, opts capture.DiagnosticOptions) {
+	output, err := s.takeDiagnosticCapture(payload, opts)
+
+	result := s.newCommandResult(command, models.CommandStatusSucceeded)
+	result.Result = output
+	if err != nil {
+		log.Printf("❌ Diagnostic capture %s failed: %v", payload.CaptureID, err)
+		result.Status = models.CommandStatusFailed
+		result.Error = err.Error()
+	}
+
+	s.commandMu.Lock()
+	defer s.commandMu.Unlock()
+	s.diagnosticCapture = ""
+	s.rememberCommand(result)
+	s.reportCommand(result)
+}
+
+func (s *Sensor) takeDiagnosticCapture(payload diagnosticPayload, opts capture.DiagnosticOptions) (map[string]interface{}, error) {
+	var pcap bytes.Buffer
+	stats, err := capture.Diagnostic(s.ctx, opts, &pcap)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := capture.Seal(payload.PublicKey, payload.CaptureID, pcap.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apiClient.UploadCapture(s.ctx, payload.CaptureID, sealed, stats.Packets); err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ Diagnostic capture %s uploaded: %d packets, %d bytes, %d payload bytes stripped",
+		payload.CaptureID, stats.Packets, stats.Bytes, stats.StrippedBytes)
+	return map[string]interface{}{
+		"capture_id":     payload.CaptureID,
+		"packets":        stats.Packets,
+		"bytes":          stats.Bytes,
+		"stripped_bytes": stats.StrippedBytes,
+		"limit_reached":  stats.LimitReached,
+	}, nil
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"reflect"
 	"runtime"
 	"sync"
 	"syscall"
@@ -15,18 +19,60 @@ import (
 	"github.com/democorp/crypto-inventory/sensor/internal/capture"
 	"github.com/democorp/crypto-inventory/sensor/internal/config"
 	"github.com/democorp/crypto-inventory/sensor/internal/models"
+	"github.com/democorp/crypto-inventory/sensor/internal/status"
 	"github.com/democorp/crypto-inventory/sensor/internal/storage"
+	"github.com/democorp/crypto-inventory/sensor/internal/update"
 )
 
-const Version = "1.0.0"
+// Version is the sensor version; release builds set it with
+// -ldflags "-X main.Version=<version>"
+var Version = "1.0.0"
 
 type Sensor struct {
 	config        *config.Config
 	packetCapture *capture.PacketCapture
 	storage       *storage.EncryptedStorage
-	apiClient     *api.OutboundClient
+	apiClient     *api.Client
 	discoveries   []*models.CryptoDiscovery
-	mu            sync.RWMutex
+	mu            sync.RWMutex // guards discoveries and configuration updates
+	commandMu     sync.Mutex   // serializes commands arriving via heartbeat and long-poll
+	ctx           context.Context
+	cancel        context.CancelFunc
+
+	// Results of recently executed commands, guarded by commandMu
+	completedCommands map[string]*models.CommandResult
+	completedOrder    []string
+
+	// intervalChanged tells the main loop about a new reporting interval
+	intervalChanged chan time.Duration
+
+	// Capture counters at the previous heartbeat, so each heartbeat reports
+	// the packets of its own interval. Only used by processDiscoveries.
+	lastCaptureStats capture.Stats
+
+	// installer swaps in upgraded binaries; nil when the executable cannot
+	// be located
+	installer *update.Installer
+	// pendingUpgrade is the upgrade the sensor started with, to be
+	// confirmed or reported as rolled back
+	pendingUpgrade *update.Marker
+	// restartRequested tells the main loop to restart into a new binary
+	restartRequested chan struct{}
+
+	// diagnosticCapture is the command ID of the running diagnostic
+	// capture, guarded by commandMu
+	diagnosticCapture string
+
+	// startedAt is when the sensor process started
+	startedAt time.Time
+	// Outcome of the reporting cycles, guarded by mu, for the status API
+	lastHeartbeat time.Time
+	lastError     string
+	// recentDiscoveries keeps the last discoveries for the status API
+	recentDiscoveries *status.Recent
+	// flushRequested asks the main loop for a reporting cycle now
+	flushRequested chan chan *status.FlushResult
+	statusServer   *status.Server
 }
 
 func main() {
@@ -50,6 +96,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Subcommands talk to the running sensor through its status API
+	if flag.NArg() > 0 {
+		os.Exit(runCLI(flag.Args()))
+	}
+
 	// Initialize logging
 	if *verbose {
 		log.SetFlags(log.LstdFlags | log.Lshortfile)
@@ -61,12 +112,31 @@ func main() {
 
 	// Load configuration
 	cfg := config.Load()
+	// The running binary is the authority on the version it reports
+	cfg.Version = Version
 	log.Printf("Configuration loaded")
 
 	// Create sensor instance
+	ctx, cancel := context.WithCancel(context.Background())
 	sensor := &Sensor{
-		config:      cfg,
-		discoveries: make([]*models.CryptoDiscovery, 0),
+		config:            cfg,
+		discoveries:       make([]*models.CryptoDiscovery, 0),
+		ctx:               ctx,
+		cancel:            cancel,
+		completedCommands: make(map[string]*models.CommandResult),
+		intervalChanged:   make(chan time.Duration, 1),
+		restartRequested:  make(chan struct{}, 1),
+		startedAt:         time.Now(),
+		recentDiscoveries: status.NewRecent(recentDiscoveries),
+		flushRequested:    make(chan chan *status.FlushResult),
+	}
+
+	installer, err := update.NewInstaller()
+	if err != nil {
+		log.Printf("⚠️ Upgrades unavailable: %v", err)
+	} else {
+		sensor.installer = installer
+		sensor.resumeUpgrade()
 	}
 
 	// Initialize components
@@ -74,8 +144,10 @@ func main() {
 		log.Fatalf("Failed to initialize sensor: %v", err)
 	}
 
-	// Register with control plane if requested
-	if *register || cfg.RegistrationKey != "" {
+	// Register with control plane if requested and not registered before
+	if sensor.apiClient.Registered() {
+		log.Printf("🔑 Using stored identity %s", cfg.SensorID)
+	} else if *register || cfg.RegistrationKey != "" {
 		if err := sensor.register(); err != nil {
 			log.Fatalf("Failed to register sensor: %v", err)
 		}
@@ -86,6 +158,9 @@ func main() {
 		log.Fatalf("Failed to start sensor: %v", err)
 	}
 
+	// Serve the local status API
+	sensor.startStatusServer()
+
 	// Handle graceful shutdown
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
@@ -94,6 +169,15 @@ func main() {
 	ticker := time.NewTicker(cfg.ReportingInterval)
 	defer ticker.Stop()
 
+	// Long-poll for commands so they take effect within seconds
+	go sensor.watchCommands()
+
+	// Confirm or report an upgrade the sensor started with
+	go sensor.confirmUpgrade()
+
+	// Report the observed network topology
+	go sensor.reportTopology()
+
 	log.Println("✅ Sensor started successfully")
 	log.Println("📡 Monitoring network traffic for cryptographic implementations...")
 
@@ -101,6 +185,10 @@ func main() {
 		select {
 		case <-ticker.C:
 			sensor.processDiscoveries()
+		case reply := <-sensor.flushRequested:
+			reply <- sensor.processDiscoveries()
+		case interval := <-sensor.intervalChanged:
+			ticker.Reset(interval)
 		case discovery := <-sensor.packetCapture.GetDiscoveries():
 			sensor.handleDiscovery(discovery)
 		case err := <-sensor.packetCapture.GetErrors():
@@ -109,6 +197,9 @@ func main() {
 			log.Printf("Received signal %v, shutting down...", sig)
 			sensor.cleanup()
 			return
+		case <-sensor.restartRequested:
+			sensor.cleanup()
+			sensor.restart()
 		}
 	}
 }
@@ -129,7 +220,10 @@ func (s *Sensor) initialize() error {
 	s.packetCapture = packetCapture
 
 	// Initialize outbound-only API client
-	apiClient := api.NewOutboundClient(s.config)
+	apiClient, err := api.NewClient(s.config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize control plane client: %v", err)
+	}
 	s.apiClient = apiClient
 
 	log.Println("✅ Sensor components initialized")
@@ -140,12 +234,15 @@ func (s *Sensor) initialize() error {
 func (s *Sensor) register() error {
 	log.Println("📝 Registering with control plane...")
 
-	config, err := s.apiClient.Register()
+	config, err := s.apiClient.Register(s.ctx)
 	if err != nil {
 		return fmt.Errorf("registration failed: %v", err)
 	}
 
 	// Update sensor configuration with received config
+	if err := validateConfigUpdate(config); err != nil {
+		return fmt.Errorf("invalid configuration from control plane: %v", err)
+	}
 	s.updateConfig(config)
 
 	log.Printf("✅ Sensor registered successfully with ID: %s", s.config.SensorID)
@@ -172,6 +269,7 @@ func (s *Sensor) handleDiscovery(discovery *models.CryptoDiscovery) {
 
 	// Add to in-memory list
 	s.discoveries = append(s.discoveries, discovery)
+	s.recentDiscoveries.Add(discovery)
 
 	// Store in encrypted storage
 	if err := s.storage.StoreDiscovery(discovery); err != nil {
@@ -183,53 +281,199 @@ func (s *Sensor) handleDiscovery(discovery *models.CryptoDiscovery) {
 }
 
 // processDiscoveries processes and sends discoveries to control plane
-func (s *Sensor) processDiscoveries() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *Sensor) processDiscoveries() *status.FlushResult {
+	// Bound each reporting cycle so retries never stall the main loop for
+	// longer than one interval
+	s.mu.RLock()
+	interval, configVersion := s.config.ReportingInterval, s.config.ConfigVersion
+	s.mu.RUnlock()
 
-	if len(s.discoveries) == 0 {
-		return
-	}
+	ctx, cancel := context.WithTimeout(s.ctx, interval)
+	defer cancel()
 
-	// Send discoveries to control plane
-	if err := s.apiClient.SubmitDiscoveries(s.discoveries); err != nil {
-		log.Printf("❌ Failed to submit discoveries: %v", err)
-		return
+	// Take the pending batch so capture can keep appending while we upload
+	s.mu.Lock()
+	pending := s.discoveries
+	s.discoveries = make([]*models.CryptoDiscovery, 0, len(pending))
+	s.mu.Unlock()
+
+	result := &status.FlushResult{}
+	var cycleErr error
+	if len(pending) > 0 {
+		// Send discoveries to control plane
+		if err := s.apiClient.SubmitDiscoveries(ctx, pending); err != nil {
+			log.Printf("❌ Failed to submit discoveries: %v", err)
+			cycleErr = fmt.Errorf("failed to submit discoveries: %v", err)
+
+			// Put the batch back in front of anything captured meanwhile
+			s.mu.Lock()
+			s.discoveries = append(pending, s.discoveries...)
+			s.mu.Unlock()
+		} else {
+			log.Printf("📤 Submitted %d discoveries to control plane", len(pending))
+			result.Submitted = len(pending)
+		}
 	}
 
-	log.Printf("📤 Submitted %d discoveries to control plane", len(s.discoveries))
-
-	// Clear discoveries after successful submission
-	s.discoveries = s.discoveries[:0]
+	// Report capture counters for this interval and what is still waiting
+	// to be uploaded, from which the control plane judges the sensor's health
+	stats := s.packetCapture.Stats()
+	received := stats.PacketsReceived - s.lastCaptureStats.PacketsReceived
+	dropped := stats.PacketsDropped - s.lastCaptureStats.PacketsDropped
+	discoveriesDropped := stats.DiscoveriesDropped - s.lastCaptureStats.DiscoveriesDropped
+	s.lastCaptureStats = stats
+
+	s.mu.RLock()
+	backlog := len(s.discoveries)
+	s.mu.RUnlock()
+	result.Backlog = backlog
+
+	metrics := map[string]interface{}{
+		// Lets the control plane re-push configuration this sensor missed
+		"config_version":      configVersion,
+		"sensor_version":      Version,
+		"packets_received":    received,
+		"packets_dropped":     dropped,
+		"discoveries_dropped": discoveriesDropped,
+		"discovery_backlog":   backlog,
+	}
+	if used, err := s.storage.Usage(); err == nil {
+		metrics["storage_used_bytes"] = used
+	}
 
 	// Send heartbeat and receive commands
 	health := &models.SensorHealth{
 		SensorID:        s.config.SensorID,
 		Status:          "active",
 		LastHeartbeat:   time.Now(),
-		Uptime:          int64(time.Since(time.Now()).Seconds()),
+		Uptime:          int64(time.Since(s.startedAt).Seconds()),
 		MemoryUsage:     getMemoryUsage(),
 		CPUUsage:        getCPUUsage(),
-		PacketsCaptured: 0, // TODO: Track actual packet count
-		DiscoveriesMade: int64(len(s.discoveries)),
+		PacketsCaptured: received,
+		DiscoveriesMade: int64(len(pending)),
 		Errors:          0, // TODO: Track actual error count
-		Metrics:         make(map[string]interface{}),
+		Metrics:         metrics,
 		Timestamp:       time.Now(),
 	}
 
-	commands, err := s.apiClient.Heartbeat(health)
+	commands, err := s.apiClient.Heartbeat(ctx, health)
 	if err != nil {
 		log.Printf("❌ Failed to send heartbeat: %v", err)
-	} else {
+		if cycleErr == nil {
+			cycleErr = fmt.Errorf("failed to send heartbeat: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.lastHeartbeat = time.Now()
+	}
+	s.lastError = ""
+	if cycleErr != nil {
+		s.lastError = cycleErr.Error()
+		result.Error = s.lastError
+	}
+	s.mu.Unlock()
+
+	if err == nil {
 		// Process received commands
 		s.processCommands(commands)
 	}
+	return result
+}
+
+// watchCommands keeps a long-poll request open against the control plane so
+// commands such as stop_capture are received within seconds rather than on
+// the next heartbeat, which can be up to an hour away for some profiles.
+// All connections are initiated by the sensor.
+func (s *Sensor) watchCommands() {
+	wait := s.config.CommandPollWait
+	if wait <= 0 {
+		log.Println("Command long-polling disabled, commands arrive with heartbeats")
+		return
+	}
+
+	const errorDelay = 15 * time.Second
+	for {
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		// Nothing to poll for until the sensor has an identity
+		if s.apiClient.SensorID() == "" {
+			if !s.sleep(errorDelay) {
+				return
+			}
+			continue
+		}
+
+		commands, err := s.apiClient.WaitForCommands(s.ctx, wait)
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			log.Printf("❌ Command long-poll failed: %v", err)
+			if !s.sleep(errorDelay) {
+				return
+			}
+			continue
+		}
+
+		s.processCommands(commands)
+	}
 }
 
-// updateConfig updates sensor configuration
-func (s *Sensor) updateConfig(config *models.SensorConfig) {
+// reportTopology periodically reports the subnets, VLANs, gateways and
+// flows the sensor observed. A window that cannot be delivered is dropped;
+// the subnets still in use show up again in the next one.
+func (s *Sensor) reportTopology() {
+	interval := s.config.TopologyInterval
+	if interval <= 0 {
+		log.Println("Topology reports disabled")
+		return
+	}
+
+	for s.sleep(interval) {
+		if s.apiClient.SensorID() == "" {
+			continue
+		}
+		report := s.packetCapture.Topology()
+		if err := s.apiClient.SubmitTopology(s.ctx, report); err != nil {
+			if s.ctx.Err() == nil {
+				log.Printf("❌ Failed to submit topology: %v", err)
+			}
+			continue
+		}
+		log.Printf("🗺️ Reported topology: %d subnets, %d gateways, %d flows",
+			len(report.Subnets), len(report.Gateways), len(report.Flows))
+	}
+}
+
+// sleep waits for d or until the sensor shuts down; it reports whether the
+// sensor is still running
+func (s *Sensor) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// updateConfig applies a configuration issued by the control plane. Maps
+// and slices are replaced rather than modified, so a copy of the previous
+// Config taken under s.mu can be restored as a whole.
+func (s *Sensor) updateConfig(config *models.ConfigUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Update reporting interval
-	s.config.ReportingInterval = time.Duration(config.ReportingInterval) * time.Second
+	if config.ReportingInterval > 0 {
+		s.config.ReportingInterval = time.Duration(config.ReportingInterval) * time.Second
+	}
 
 	// Update storage config
 	s.config.Storage.MaxStorageSize = config.StorageConfig.MaxStorageSize
@@ -237,14 +481,34 @@ func (s *Sensor) updateConfig(config *models.SensorConfig) {
 	s.config.Storage.RetentionDays = config.StorageConfig.RetentionDays
 
 	// Update capture config
+	if len(config.CaptureConfig.Interfaces) > 0 {
+		s.config.Capture.Interfaces = append([]string(nil), config.CaptureConfig.Interfaces...)
+	}
 	s.config.Capture.ActiveProbing = config.CaptureConfig.ActiveProbing
 	s.config.Capture.NetworkDiscovery = config.CaptureConfig.NetworkDiscovery
 	s.config.Capture.MaxConnections = config.CaptureConfig.MaxConnections
 	s.config.Capture.TimeoutSeconds = config.CaptureConfig.TimeoutSeconds
 
+	if len(config.PortMap) > 0 {
+		portMap := make(map[string][]int, len(config.PortMap))
+		for protocol, ports := range config.PortMap {
+			portMap[protocol] = append([]int(nil), ports...)
+		}
+		s.config.PortMap = portMap
+	}
+
 	// Update features
+	features := make(map[string]bool, len(s.config.Features))
+	for feature, enabled := range s.config.Features {
+		features[feature] = enabled
+	}
 	for feature, enabled := range config.Features {
-		s.config.Features[feature] = enabled
+		features[feature] = enabled
+	}
+	s.config.Features = features
+
+	if config.ConfigVersion > 0 {
+		s.config.ConfigVersion = config.ConfigVersion
 	}
 }
 
@@ -252,17 +516,24 @@ func (s *Sensor) updateConfig(config *models.SensorConfig) {
 func (s *Sensor) cleanup() {
 	log.Println("🧹 Performing cleanup...")
 
+	s.stopStatusServer()
+
 	// Stop packet capture
 	if s.packetCapture != nil {
 		s.packetCapture.Stop()
 	}
 
-	// Submit remaining discoveries
+	// Cancel in-flight control plane calls
+	s.cancel()
+
+	// Submit remaining discoveries with a short, independent deadline
 	if len(s.discoveries) > 0 {
 		log.Printf("📤 Submitting %d remaining discoveries...", len(s.discoveries))
-		if err := s.apiClient.SubmitDiscoveries(s.discoveries); err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := s.apiClient.SubmitDiscoveries(ctx, s.discoveries); err != nil {
 			log.Printf("❌ Failed to submit remaining discoveries: %v", err)
 		}
+		cancel()
 	}
 
 	// Close storage
@@ -279,6 +550,9 @@ func (s *Sensor) processCommands(commands *models.SensorCommands) {
 		return
 	}
 
+	s.commandMu.Lock()
+	defer s.commandMu.Unlock()
+
 	log.Printf("📋 Processing %d commands from control plane", len(commands.Commands))
 
 	for _, command := range commands.Commands {
@@ -286,69 +560,261 @@ func (s *Sensor) processCommands(commands *models.SensorCommands) {
 	}
 }
 
-// processCommand processes a single command
+// maxCompletedCommands bounds how many command results are remembered for
+// answering redeliveries
+const maxCompletedCommands = 256
+
+// processCommand processes a single command. Commands the control plane
+// redelivers because a report was lost are not executed again; the stored
+// result is reported instead.
 func (s *Sensor) processCommand(command models.Command) {
+	if result, ok := s.completedCommands[command.ID]; ok {
+		log.Printf("🔁 Command %s already executed, re-reporting result", command.ID)
+		s.reportCommand(result)
+		return
+	}
+
 	log.Printf("🔧 Processing command: %s (type: %s, priority: %d)",
 		command.ID, command.Type, command.Priority)
 
+	// Acknowledge receipt before executing if required
+	if command.RequiresAck {
+		s.reportCommand(s.newCommandResult(command, models.CommandStatusAcknowledged))
+	}
+
+	var output map[string]interface{}
+	var err error
 	switch command.Type {
 	case "update_config":
-		s.handleUpdateConfigCommand(command)
+		output, err = s.handleUpdateConfigCommand(command)
 	case "restart":
-		s.handleRestartCommand(command)
+		output, err = s.handleRestartCommand(command)
 	case "stop":
-		s.handleStopCommand(command)
+		output, err = s.handleStopCommand(command)
 	case "start_capture":
-		s.handleStartCaptureCommand(command)
+		output, err = s.handleStartCaptureCommand(command)
 	case "stop_capture":
-		s.handleStopCaptureCommand(command)
+		output, err = s.handleStopCaptureCommand(command)
+	case "upgrade":
+		output, err = s.handleUpgradeCommand(command)
+	case "diagnostic_capture":
+		output, err = s.handleDiagnosticCaptureCommand(command)
 	default:
 		log.Printf("⚠️ Unknown command type: %s", command.Type)
+		err = fmt.Errorf("unknown command type: %s", command.Type)
 	}
 
-	// Acknowledge command if required
-	if command.RequiresAck {
-		s.acknowledgeCommand(command)
+	if err == errRestartRequired {
+		// The new binary reports the command once it passed its health check
+		s.requestRestart()
+		return
+	}
+	if err == errCommandRunning {
+		return
+	}
+
+	result := s.newCommandResult(command, models.CommandStatusSucceeded)
+	result.Result = output
+	if err != nil {
+		result.Status = models.CommandStatusFailed
+		result.Error = err.Error()
+	}
+
+	s.rememberCommand(result)
+	s.reportCommand(result)
+}
+
+// handleUpdateConfigCommand applies a configuration version pushed by the
+// control plane. Versions at or below the one in effect are ignored, so
+// redelivered or reordered pushes never roll the configuration back. When
+// interfaces or ports change, a running capture is restarted with them; if
+// that fails the previous configuration is restored.
+func (s *Sensor) handleUpdateConfigCommand(command models.Command) (map[string]interface{}, error) {
+	update, err := parseConfigUpdate(command.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	previous := *s.config
+	s.mu.RUnlock()
+
+	if update.ConfigVersion <= previous.ConfigVersion {
+		log.Printf("📝 Config version %d already superseded by %d", update.ConfigVersion, previous.ConfigVersion)
+		return map[string]interface{}{"config_version": previous.ConfigVersion, "applied": false}, nil
+	}
+
+	log.Printf("📝 Applying config version %d", update.ConfigVersion)
+	s.updateConfig(update)
+
+	s.mu.RLock()
+	current := *s.config
+	s.mu.RUnlock()
+
+	restartCapture := s.packetCapture.IsRunning() &&
+		(!reflect.DeepEqual(previous.Capture.Interfaces, current.Capture.Interfaces) ||
+			!reflect.DeepEqual(previous.PortMap, current.PortMap))
+	if restartCapture {
+		s.packetCapture.Stop()
+		if err := s.packetCapture.Start(); err != nil {
+			log.Printf("❌ Capture failed with config version %d, restoring version %d: %v",
+				update.ConfigVersion, previous.ConfigVersion, err)
+			s.mu.Lock()
+			*s.config = previous
+			s.mu.Unlock()
+			if restartErr := s.packetCapture.Start(); restartErr != nil {
+				log.Printf("❌ Failed to restart packet capture: %v", restartErr)
+			}
+			return nil, fmt.Errorf("capture failed to start with the new configuration: %v", err)
+		}
+	}
+
+	if current.ReportingInterval != previous.ReportingInterval {
+		s.signalInterval(current.ReportingInterval)
+	}
+
+	log.Printf("✅ Config version %d applied", update.ConfigVersion)
+	return map[string]interface{}{
+		"config_version":    update.ConfigVersion,
+		"applied":           true,
+		"capture_restarted": restartCapture,
+	}, nil
+}
+
+// parseConfigUpdate decodes and validates the payload of an update_config
+// command
+func parseConfigUpdate(payload map[string]interface{}) (*models.ConfigUpdate, error) {
+	raw, ok := payload["config"]
+	if !ok {
+		return nil, errors.New("update_config payload has no config")
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config payload: %v", err)
+	}
+	var update models.ConfigUpdate
+	if err := json.Unmarshal(data, &update); err != nil {
+		return nil, fmt.Errorf("invalid config payload: %v", err)
+	}
+
+	// The version is carried next to the config as well
+	if version, ok := payload["config_version"].(float64); ok {
+		if update.ConfigVersion == 0 {
+			update.ConfigVersion = int(version)
+		} else if update.ConfigVersion != int(version) {
+			return nil, fmt.Errorf("config version mismatch: %d and %d", update.ConfigVersion, int(version))
+		}
+	}
+	if update.ConfigVersion < 1 {
+		return nil, errors.New("update_config payload has no config_version")
+	}
+
+	if err := validateConfigUpdate(&update); err != nil {
+		return nil, err
 	}
+	return &update, nil
 }
 
-// handleUpdateConfigCommand handles configuration update commands
-func (s *Sensor) handleUpdateConfigCommand(command models.Command) {
-	if configData, ok := command.Payload["config"].(map[string]interface{}); ok {
-		_ = configData
-		// TODO: Parse and apply configuration updates
-		log.Printf("📝 Configuration update command received")
+// validateConfigUpdate rejects configuration the sensor cannot run with
+func validateConfigUpdate(update *models.ConfigUpdate) error {
+	if update.ReportingInterval < 0 {
+		return fmt.Errorf("invalid reporting interval %d", update.ReportingInterval)
+	}
+
+	seen := make(map[int]string)
+	for protocol, ports := range update.PortMap {
+		if protocol != "tls" && protocol != "ssh" {
+			return fmt.Errorf("unknown protocol %q in port map", protocol)
+		}
+		for _, port := range ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("invalid port %d in port map", port)
+			}
+			if other, ok := seen[port]; ok && other != protocol {
+				return fmt.Errorf("port %d is assigned to both %s and %s", port, other, protocol)
+			}
+			seen[port] = protocol
+		}
+	}
+	return nil
+}
+
+// signalInterval hands a new reporting interval to the main loop, replacing
+// one it has not picked up yet
+func (s *Sensor) signalInterval(interval time.Duration) {
+	for {
+		select {
+		case s.intervalChanged <- interval:
+			return
+		default:
+		}
+		select {
+		case <-s.intervalChanged:
+		default:
+		}
 	}
 }
 
 // handleRestartCommand handles restart commands
-func (s *Sensor) handleRestartCommand(command models.Command) {
+func (s *Sensor) handleRestartCommand(command models.Command) (map[string]interface{}, error) {
 	log.Printf("🔄 Restart command received, scheduling restart...")
 	// TODO: Implement graceful restart
+	return nil, errors.New("restart is not supported yet")
 }
 
 // handleStopCommand handles stop commands
-func (s *Sensor) handleStopCommand(command models.Command) {
+func (s *Sensor) handleStopCommand(command models.Command) (map[string]interface{}, error) {
 	log.Printf("🛑 Stop command received, scheduling shutdown...")
 	// TODO: Implement graceful shutdown
+	return nil, errors.New("stop is not supported yet")
 }
 
 // handleStartCaptureCommand handles start capture commands
-func (s *Sensor) handleStartCaptureCommand(command models.Command) {
+func (s *Sensor) handleStartCaptureCommand(command models.Command) (map[string]interface{}, error) {
 	log.Printf("▶️ Start capture command received")
-	// TODO: Start packet capture if not already running
+	if err := s.packetCapture.Start(); err != nil {
+		log.Printf("❌ Failed to start packet capture: %v", err)
+		return nil, err
+	}
+	return map[string]interface{}{"capturing": s.packetCapture.IsRunning()}, nil
 }
 
 // handleStopCaptureCommand handles stop capture commands
-func (s *Sensor) handleStopCaptureCommand(command models.Command) {
+func (s *Sensor) handleStopCaptureCommand(command models.Command) (map[string]interface{}, error) {
 	log.Printf("⏹️ Stop capture command received")
-	// TODO: Stop packet capture
+	s.packetCapture.Stop()
+	return map[string]interface{}{"capturing": s.packetCapture.IsRunning()}, nil
 }
 
-// acknowledgeCommand acknowledges a command to the control plane
-func (s *Sensor) acknowledgeCommand(command models.Command) {
-	// TODO: Send acknowledgment to control plane
-	log.Printf("✅ Acknowledged command: %s", command.ID)
+// newCommandResult creates a report for a command
+func (s *Sensor) newCommandResult(command models.Command, status string) *models.CommandResult {
+	return &models.CommandResult{
+		CommandID: command.ID,
+		SensorID:  s.config.SensorID,
+		Status:    status,
+		Timestamp: time.Now(),
+	}
+}
+
+// rememberCommand stores the result of an executed command so a redelivery
+// is answered without executing it twice
+func (s *Sensor) rememberCommand(result *models.CommandResult) {
+	if len(s.completedOrder) >= maxCompletedCommands {
+		delete(s.completedCommands, s.completedOrder[0])
+		s.completedOrder = s.completedOrder[1:]
+	}
+	s.completedCommands[result.CommandID] = result
+	s.completedOrder = append(s.completedOrder, result.CommandID)
+}
+
+// reportCommand reports a command status to the control plane
+func (s *Sensor) reportCommand(result *models.CommandResult) {
+	if err := s.apiClient.ReportCommand(s.ctx, result); err != nil {
+		log.Printf("❌ Failed to report command %s as %s: %v", result.CommandID, result.Status, err)
+		return
+	}
+
+	log.Printf("✅ Reported command %s: %s", result.CommandID, result.Status)
 }
 
 // Helper functions for system metrics
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/config"
+	"github.com/democorp/crypto-inventory/sensor/internal/status"
+)
+
+const (
+	// recentDiscoveries is how many discoveries the status API can show
+	recentDiscoveries = 200
+	// redacted replaces secrets in the configuration shown by the status API
+	redacted = "[redacted]"
+)
+
+// startStatusServer serves the local status API unless it is disabled. A
+// sensor whose status API cannot listen keeps running without it.
+func (s *Sensor) startStatusServer() {
+	addr := s.config.StatusListen
+	if addr == "" || addr == "off" {
+		return
+	}
+	server := status.NewServer(s)
+	if err := server.Start(addr); err != nil {
+		log.Printf("⚠️ Status API unavailable: %v", err)
+		return
+	}
+	s.statusServer = server
+	log.Printf("🩺 Status API listening on %s", addr)
+}
+
+func (s *Sensor) stopStatusServer() {
+	if s.statusServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.statusServer.Shutdown(ctx)
+	s.statusServer = nil
+}
+
+// Status implements status.Provider
+func (s *Sensor) Status() *status.Report {
+	s.mu.RLock()
+	report := &status.Report{
+		SensorID:          s.config.SensorID,
+		Name:              s.config.Name,
+		Version:           Version,
+		Platform:          s.config.Platform,
+		Profile:           s.config.Profile,
+		StartedAt:         s.startedAt,
+		UptimeSeconds:     int64(time.Since(s.startedAt).Seconds()),
+		ConfigVersion:     s.config.ConfigVersion,
+		ReportingInterval: int64(s.config.ReportingInterval.Seconds()),
+		Backlog:           len(s.discoveries),
+		LastError:         s.lastError,
+	}
+	if !s.lastHeartbeat.IsZero() {
+		last := s.lastHeartbeat
+		report.LastHeartbeat = &last
+	}
+	s.mu.RUnlock()
+
+	report.Registered = s.apiClient.Registered()
+	report.Capturing = s.packetCapture.IsRunning()
+	report.Interfaces = s.packetCapture.InterfaceStats()
+	report.Capture = s.packetCapture.Stats()
+	if used, err := s.storage.Usage(); err == nil {
+		report.StorageUsedBytes = used
+	}
+	return report
+}
+
+// Config implements status.Provider
+func (s *Sensor) Config() *config.Config {
+	s.mu.RLock()
+	cfg := *s.config
+	s.mu.RUnlock()
+
+	if cfg.RegistrationKey != "" {
+		cfg.RegistrationKey = redacted
+	}
+	if cfg.Storage.EncryptionKey != "" {
+		cfg.Storage.EncryptionKey = redacted
+	}
+	if cfg.ProxyURL != "" {
+		cfg.ProxyURL = redactProxyURL(cfg.ProxyURL)
+	}
+	return &cfg
+}
+
+// redactProxyURL replaces the credentials of a proxy URL. A URL that does
+// not parse is redacted as a whole, since it may still hold credentials.
+func redactProxyURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "://", "://"+redacted+"@", 1)
+}
+
+// Discoveries implements status.Provider
+func (s *Sensor) Discoveries(after uint64, limit int) []status.Discovery {
+	return s.recentDiscoveries.After(after, limit)
+}
+
+// Flush implements status.Provider. The reporting cycle runs on the main
+// loop, like the periodic ones.
+func (s *Sensor) Flush(ctx context.Context) (*status.FlushResult, error) {
+	reply := make(chan *status.FlushResult, 1)
+	select {
+	case s.flushRequested <- reply:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case result := <-reply:
+		return result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/models"
+	"github.com/democorp/crypto-inventory/sensor/internal/update"
+)
+
+const (
+	// maxUpgradeStarts is how often a new binary may start without passing
+	// its health check, e.g. because it crashes, before it is rolled back
+	maxUpgradeStarts = 3
+	// upgradeHealthTimeout bounds the health check of a new binary
+	upgradeHealthTimeout = 2 * time.Minute
+)
+
+// errRestartRequired is returned by the upgrade handler once a new binary
+// is installed; the command is reported by the new binary
+var errRestartRequired = errors.New("restart required")
+
+// upgradePayload is the payload of an upgrade command: the release and its
+// binaries, of which the sensor picks the one for its platform
+type upgradePayload struct {
+	ReleaseID string                      `json:"release_id"`
+	Version   string                      `json:"version"`
+	Artifacts map[string]*update.Artifact `json:"artifacts"`
+}
+
+// handleUpgradeCommand downloads, verifies and installs a release binary.
+// The binary must be signed with one of the pinned release keys and start
+// and report its version before it replaces the running one.
+func (s *Sensor) handleUpgradeCommand(command models.Command) (map[string]interface{}, error) {
+	data, err := json.Marshal(command.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upgrade payload: %v", err)
+	}
+	var payload upgradePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid upgrade payload: %v", err)
+	}
+	if payload.Version == "" {
+		return nil, errors.New("upgrade payload has no version")
+	}
+	if payload.Version == Version {
+		log.Printf("⬆️ Already running version %s", Version)
+		return map[string]interface{}{"version": Version, "upgraded": false}, nil
+	}
+
+	platform := update.Platform()
+	artifact, ok := payload.Artifacts[platform]
+	if !ok || artifact == nil {
+		return nil, fmt.Errorf("release %s has no binary for %s", payload.Version, platform)
+	}
+	artifact.Version = payload.Version
+	artifact.Platform = platform
+
+	if s.installer == nil {
+		return nil, errors.New("upgrades are not available: executable path unknown")
+	}
+	keys, err := update.ParsePublicKeys(s.config.Security.ReleasePublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	// Refuse an unsigned release before downloading it
+	if err := artifact.VerifySignature(keys); err != nil {
+		return nil, err
+	}
+
+	log.Printf("⬆️ Upgrading from %s to %s (%s, %d bytes)", Version, payload.Version, platform, artifact.Size)
+
+	staging := s.installer.StagingPath()
+	installed := false
+	defer func() {
+		if !installed {
+			os.Remove(staging)
+		}
+	}()
+
+	file, err := os.OpenFile(staging, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging file: %v", err)
+	}
+	err = s.apiClient.DownloadRelease(s.ctx, payload.Version, platform, file, update.MaxBinarySize)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := update.Verify(staging, artifact, keys); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(staging, 0755); err != nil {
+		return nil, err
+	}
+	if err := update.CheckBinary(s.ctx, staging, payload.Version); err != nil {
+		return nil, err
+	}
+
+	marker := &update.Marker{
+		CommandID:   command.ID,
+		FromVersion: Version,
+		ToVersion:   payload.Version,
+		StartedAt:   time.Now(),
+	}
+	if err := s.installer.SaveMarker(marker); err != nil {
+		return nil, fmt.Errorf("failed to record upgrade: %v", err)
+	}
+	if err := s.installer.Swap(); err != nil {
+		s.installer.RemoveMarker()
+		return nil, err
+	}
+	installed = true
+
+	log.Printf("✅ Installed version %s, restarting", payload.Version)
+	return map[string]interface{}{"version": payload.Version, "previous_version": Version}, errRestartRequired
+}
+
+// resumeUpgrade picks up an upgrade in progress when the sensor starts. A
+// new binary that keeps failing to start is rolled back right away; the
+// outcome of any other upgrade is reported by confirmUpgrade once the
+// sensor runs.
+func (s *Sensor) resumeUpgrade() {
+	if s.installer == nil {
+		return
+	}
+	marker, err := s.installer.LoadMarker()
+	if err != nil {
+		log.Printf("❌ Failed to load upgrade marker: %v", err)
+		s.installer.RemoveMarker()
+		return
+	}
+	if marker == nil {
+		return
+	}
+
+	switch {
+	case marker.RolledBack && marker.FromVersion == Version:
+		s.pendingUpgrade = marker
+	case !marker.RolledBack && marker.ToVersion == Version:
+		marker.Starts++
+		if marker.Starts > maxUpgradeStarts {
+			s.rollbackUpgrade(marker, fmt.Sprintf("version %s failed to start %d times", Version, maxUpgradeStarts))
+			s.restart()
+		}
+		if err := s.installer.SaveMarker(marker); err != nil {
+			log.Printf("❌ Failed to record upgrade start: %v", err)
+		}
+		s.pendingUpgrade = marker
+	default:
+		// Left behind by an upgrade this binary is not part of
+		s.installer.RemoveMarker()
+	}
+}
+
+// confirmUpgrade reports the outcome of the upgrade the sensor started
+// with. A new binary must capture and reach the control plane within
+// upgradeHealthTimeout, or the previous binary is restored.
+func (s *Sensor) confirmUpgrade() {
+	marker := s.pendingUpgrade
+	if marker == nil {
+		return
+	}
+
+	if marker.RolledBack {
+		log.Printf("⚠️ Upgrade to %s was rolled back: %s", marker.ToVersion, marker.Error)
+		result := s.newCommandResult(models.Command{ID: marker.CommandID}, models.CommandStatusFailed)
+		result.Error = marker.Error
+		result.Result = map[string]interface{}{"version": Version, "attempted_version": marker.ToVersion}
+		s.reportCommand(result)
+		s.installer.RemoveMarker()
+		return
+	}
+
+	if err := s.checkHealth(); err != nil {
+		s.rollbackUpgrade(marker, fmt.Sprintf("version %s failed its health check: %v", Version, err))
+		s.requestRestart()
+		return
+	}
+
+	log.Printf("✅ Upgrade from %s to %s confirmed", marker.FromVersion, Version)
+	result := s.newCommandResult(models.Command{ID: marker.CommandID}, models.CommandStatusSucceeded)
+	result.Result = map[string]interface{}{"version": Version, "previous_version": marker.FromVersion}
+	s.reportCommand(result)
+	if err := s.installer.RemoveMarker(); err != nil {
+		log.Printf("❌ Failed to clear upgrade marker: %v", err)
+	}
+}
+
+// checkHealth waits for packet capture to run and the control plane to
+// answer
+func (s *Sensor) checkHealth() error {
+	ctx, cancel := context.WithTimeout(s.ctx, upgradeHealthTimeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		if !s.packetCapture.IsRunning() {
+			lastErr = errors.New("packet capture is not running")
+		} else if _, err := s.apiClient.GetConfig(ctx); err != nil {
+			lastErr = fmt.Errorf("control plane unreachable: %v", err)
+		} else {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return lastErr
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// rollbackUpgrade restores the previous binary and records why, so the
+// previous binary reports the upgrade as failed
+func (s *Sensor) rollbackUpgrade(marker *update.Marker, reason string) {
+	log.Printf("❌ Rolling back to %s: %s", marker.FromVersion, reason)
+	marker.RolledBack = true
+	marker.Error = reason
+	if err := s.installer.SaveMarker(marker); err != nil {
+		log.Printf("❌ Failed to record rollback: %v", err)
+	}
+	if err := s.installer.Rollback(); err != nil {
+		log.Fatalf("Failed to roll back upgrade: %v", err)
+	}
+}
+
+// requestRestart asks the main loop to restart the sensor
+func (s *Sensor) requestRestart() {
+	select {
+	case s.restartRequested <- struct{}{}:
+	default:
+	}
+}
+
+// restart replaces the process with the installed binary, keeping its
+// arguments and environment. If that fails the sensor exits and relies on
+// its service manager to start it again.
+func (s *Sensor) restart() {
+	executable := os.Args[0]
+	if s.installer != nil {
+		executable = s.installer.Executable
+	}
+	log.Printf("🔄 Restarting %s", executable)
+	err := syscall.Exec(executable, os.Args, os.Environ())
+	log.Fatalf("Failed to restart sensor: %v", err)
+}
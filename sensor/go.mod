@@ -4,5 +4,6 @@ go 1.21
 
 require (
 	github.com/google/gopacket v1.1.19
+	github.com/klauspost/compress v1.17.0
 	golang.org/x/sys v0.0.0-20190412213103-97732733099d
 )
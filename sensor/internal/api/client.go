@@ -0,0 +1,439 @@
+// Package api provides the sensor's outbound-only channel to the control plane.
+// Every request is initiated by the sensor, so no inbound firewall rules are
+// required. Calls are context-aware and retried with jittered backoff when the
+// control plane or the network path is temporarily unavailable.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/config"
+	"github.com/democorp/crypto-inventory/sensor/internal/models"
+)
+
+// Client handles all communication between the sensor and the sensor manager
+type Client struct {
+	config      *config.Config
+	baseURL     string
+	retry       RetryPolicy
+	compression string
+
+	mu         sync.RWMutex // guards httpClient and sensorID, both change on registration
+	httpClient *http.Client
+	sensorID   string
+}
+
+// NewClient creates a new control plane client from the sensor configuration
+func NewClient(cfg *config.Config) (*Client, error) {
+	compression := cfg.Compression
+	if compression == "" {
+		compression = EncodingNone
+	}
+	if !isSupportedEncoding(compression) {
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+
+	c := &Client{
+		config:      cfg,
+		baseURL:     cfg.ControlPlaneURL,
+		retry:       NewRetryPolicy(cfg),
+		compression: compression,
+		sensorID:    cfg.SensorID,
+	}
+
+	// A sensor registered before keeps its identity across restarts,
+	// including the restart into an upgraded binary
+	if c.sensorID == "" {
+		if err := c.loadIdentity(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.reloadTransport(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Registered reports whether the sensor has an identity, either configured
+// or stored by an earlier registration
+func (c *Client) Registered() bool {
+	return c.SensorID() != ""
+}
+
+// SensorID returns the identifier the client currently uses in request paths
+func (c *Client) SensorID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sensorID
+}
+
+// Register registers the sensor with the control plane. On success the issued
+// client certificate is written to the data directory and the transport is
+// rebuilt so subsequent calls authenticate with it. A registration consumes
+// a use of the key and the sensor's name, so it is not retried once it may
+// have reached the control plane.
+func (c *Client) Register(ctx context.Context) (*models.ConfigUpdate, error) {
+	registration := models.SensorRegistration{
+		RegistrationKey:   c.config.RegistrationKey,
+		Name:              c.config.Name,
+		Description:       c.config.Description,
+		Platform:          c.config.Platform,
+		Version:           c.config.Version,
+		Profile:           c.config.Profile,
+		NetworkInterfaces: c.config.Capture.Interfaces,
+	}
+
+	var registrationResp struct {
+		SensorID     string              `json:"sensor_id"`
+		ClientCert   string              `json:"client_cert"`
+		ClientKey    string              `json:"client_key"`
+		ServerCACert string              `json:"server_ca_cert"`
+		Config       models.ConfigUpdate `json:"config"`
+	}
+
+	req := &request{
+		op:           "registration",
+		method:       http.MethodPost,
+		path:         "/api/v1/sensors/register",
+		body:         registration,
+		unrepeatable: true,
+	}
+	if err := c.do(ctx, req, &registrationResp); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.sensorID = registrationResp.SensorID
+	c.mu.Unlock()
+	c.config.SensorID = registrationResp.SensorID
+
+	if registrationResp.ClientCert != "" && registrationResp.ClientKey != "" {
+		if err := c.storeCredentials(registrationResp.ClientCert, registrationResp.ClientKey, registrationResp.ServerCACert); err != nil {
+			return nil, fmt.Errorf("failed to store issued credentials: %v", err)
+		}
+		if err := c.reloadTransport(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &registrationResp.Config, nil
+}
+
+// Heartbeat sends a heartbeat and receives any pending commands
+func (c *Client) Heartbeat(ctx context.Context, health *models.SensorHealth) (*models.SensorCommands, error) {
+	var commands models.SensorCommands
+	req := &request{
+		op:     "heartbeat",
+		method: http.MethodPost,
+		path:   c.sensorPath("heartbeat"),
+		body:   health,
+	}
+	if err := c.do(ctx, req, &commands); err != nil {
+		return nil, err
+	}
+	return &commands, nil
+}
+
+// SubmitDiscoveries submits a batch of discoveries. The batch is compressed
+// with the configured encoding and carries its batch ID as an idempotency key,
+// so a retried submission is never ingested twice.
+func (c *Client) SubmitDiscoveries(ctx context.Context, discoveries []*models.CryptoDiscovery) error {
+	if len(discoveries) == 0 {
+		return nil
+	}
+
+	batch := models.DiscoveryBatch{
+		SensorID:    c.SensorID(),
+		Discoveries: make([]models.CryptoDiscovery, len(discoveries)),
+		BatchID:     generateBatchID(),
+		Timestamp:   time.Now(),
+		Count:       len(discoveries),
+	}
+
+	// Convert pointers to values
+	for i, discovery := range discoveries {
+		batch.Discoveries[i] = *discovery
+	}
+
+	req := &request{
+		op:             "discovery submission",
+		method:         http.MethodPost,
+		path:           c.sensorPath("discoveries"),
+		body:           batch,
+		encoding:       c.compression,
+		idempotencyKey: batch.BatchID,
+	}
+	return c.do(ctx, req, nil)
+}
+
+// PollCommands polls the control plane for pending commands
+func (c *Client) PollCommands(ctx context.Context) (*models.SensorCommands, error) {
+	var commands models.SensorCommands
+	req := &request{
+		op:     "command poll",
+		method: http.MethodGet,
+		path:   c.sensorPath("commands"),
+	}
+	if err := c.do(ctx, req, &commands); err != nil {
+		return nil, err
+	}
+	return &commands, nil
+}
+
+// WaitForCommands long-polls the control plane for commands. The request is
+// held open by the server until a command is queued or wait elapses, so the
+// sensor learns about commands within seconds while staying outbound-only.
+func (c *Client) WaitForCommands(ctx context.Context, wait time.Duration) (*models.SensorCommands, error) {
+	var commands models.SensorCommands
+	req := &request{
+		op:      "command long-poll",
+		method:  http.MethodGet,
+		path:    c.sensorPath("commands"),
+		query:   url.Values{"wait": {wait.String()}}.Encode(),
+		timeout: wait + c.retry.RequestTimeout,
+	}
+	if err := c.do(ctx, req, &commands); err != nil {
+		return nil, err
+	}
+	return &commands, nil
+}
+
+// ReportCommand reports that a command was acknowledged, succeeded or
+// failed. Reports are idempotent on the control plane, so retries are safe.
+func (c *Client) ReportCommand(ctx context.Context, result *models.CommandResult) error {
+	req := &request{
+		op:     "command " + result.Status + " report",
+		method: http.MethodPost,
+		path:   c.sensorPath("commands", result.CommandID, "ack"),
+		body:   result,
+	}
+	return c.do(ctx, req, nil)
+}
+
+// SubmitTopology reports the networks the sensor observed during a window
+func (c *Client) SubmitTopology(ctx context.Context, report *models.TopologyReport) error {
+	report.SensorID = c.SensorID()
+	req := &request{
+		op:     "topology report",
+		method: http.MethodPost,
+		path:   c.sensorPath("topology"),
+		body:   report,
+	}
+	return c.do(ctx, req, nil)
+}
+
+// GetConfig retrieves the sensor configuration from the control plane
+func (c *Client) GetConfig(ctx context.Context) (*models.SensorConfig, error) {
+	var cfg models.SensorConfig
+	req := &request{
+		op:     "config request",
+		method: http.MethodGet,
+		path:   c.sensorPath("config"),
+	}
+	if err := c.do(ctx, req, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// GetWebhookConfig retrieves the webhook configuration for the sensor
+func (c *Client) GetWebhookConfig(ctx context.Context) (*models.WebhookConfig, error) {
+	var webhookConfig models.WebhookConfig
+	req := &request{
+		op:     "webhook config request",
+		method: http.MethodGet,
+		path:   c.sensorPath("webhook-config"),
+	}
+	if err := c.do(ctx, req, &webhookConfig); err != nil {
+		return nil, err
+	}
+	return &webhookConfig, nil
+}
+
+// ReportHealth reports sensor health through the legacy health endpoint
+func (c *Client) ReportHealth(ctx context.Context, health *models.SensorHealth) error {
+	req := &request{
+		op:     "health report",
+		method: http.MethodPost,
+		path:   c.sensorPath("health"),
+		body:   health,
+	}
+	return c.do(ctx, req, nil)
+}
+
+// SubmitAirGappedExport uploads an air-gapped export once connectivity exists
+func (c *Client) SubmitAirGappedExport(ctx context.Context, export *models.AirGappedExport) error {
+	req := &request{
+		op:             "export submission",
+		method:         http.MethodPost,
+		path:           c.sensorPath("exports"),
+		body:           export,
+		encoding:       c.compression,
+		idempotencyKey: export.ExportID,
+	}
+	return c.do(ctx, req, nil)
+}
+
+// releaseDownloadTimeout bounds each attempt to download a release binary,
+// which is far larger than any other response
+const releaseDownloadTimeout = 10 * time.Minute
+
+// DownloadRelease downloads the release binary of a version for a platform
+// into file, replacing its contents on every attempt. Bodies larger than
+// maxSize are rejected.
+func (c *Client) DownloadRelease(ctx context.Context, version, platform string, file *os.File, maxSize int64) error {
+	req := &request{
+		op:      "release download",
+		method:  http.MethodGet,
+		path:    c.sensorPath("releases", version, platform),
+		timeout: releaseDownloadTimeout,
+		download: func(body io.Reader) error {
+			if err := file.Truncate(0); err != nil {
+				return err
+			}
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			written, err := io.Copy(file, io.LimitReader(body, maxSize+1))
+			if err != nil {
+				return err
+			}
+			if written > maxSize {
+				return fmt.Errorf("release binary exceeds %d bytes", maxSize)
+			}
+			return file.Sync()
+		},
+	}
+	return c.do(ctx, req, nil)
+}
+
+// captureUploadTimeout bounds each attempt to upload a diagnostic capture
+const captureUploadTimeout = 5 * time.Minute
+
+// UploadCapture uploads the sealed pcap of a diagnostic capture. A capture
+// the control plane already holds, e.g. after a lost response, counts as
+// uploaded.
+func (c *Client) UploadCapture(ctx context.Context, captureID string, sealed []byte, packets int) error {
+	req := &request{
+		op:      "capture upload",
+		method:  http.MethodPut,
+		path:    c.sensorPath("captures", captureID),
+		upload:  sealed,
+		header:  http.Header{"X-Capture-Packets": {strconv.Itoa(packets)}},
+		timeout: captureUploadTimeout,
+	}
+	err := c.do(ctx, req, nil)
+	if statusErr, ok := err.(*StatusError); ok && statusErr.StatusCode == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+// sensorPath builds a path below /api/v1/sensors/:sensor_id
+func (c *Client) sensorPath(elems ...string) string {
+	path := "/api/v1/sensors/" + url.PathEscape(c.SensorID())
+	for _, elem := range elems {
+		path += "/" + url.PathEscape(elem)
+	}
+	return path
+}
+
+// storeCredentials writes the issued certificate material to the data
+// directory and points the security configuration at the written files
+func (c *Client) storeCredentials(certPEM, keyPEM, caPEM string) error {
+	dir := filepath.Join(c.config.Storage.DataPath, "certs")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	certPath := filepath.Join(dir, "client.crt")
+	keyPath := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certPath, []byte(certPEM), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, []byte(keyPEM), 0600); err != nil {
+		return err
+	}
+	c.config.Security.ClientCert = certPath
+	c.config.Security.ClientKey = keyPath
+
+	if caPEM != "" {
+		caPath := filepath.Join(dir, "server-ca.crt")
+		if err := os.WriteFile(caPath, []byte(caPEM), 0644); err != nil {
+			return err
+		}
+		c.config.Security.ServerCACert = caPath
+	}
+
+	c.config.Security.UseTLS = true
+
+	// Written last: it marks the credentials above as complete
+	return os.WriteFile(filepath.Join(dir, identityFile), []byte(c.SensorID()), 0600)
+}
+
+// identityFile holds the sensor ID next to the issued credentials
+const identityFile = "sensor-id"
+
+// loadIdentity restores the sensor ID and credentials stored by an earlier
+// registration, if there was one
+func (c *Client) loadIdentity() error {
+	dir := filepath.Join(c.config.Storage.DataPath, "certs")
+	data, err := os.ReadFile(filepath.Join(dir, identityFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read stored sensor identity: %v", err)
+	}
+
+	sensorID := strings.TrimSpace(string(data))
+	if sensorID == "" {
+		return nil
+	}
+	c.sensorID = sensorID
+	c.config.SensorID = sensorID
+	c.config.Security.ClientCert = filepath.Join(dir, "client.crt")
+	c.config.Security.ClientKey = filepath.Join(dir, "client.key")
+	if caPath := filepath.Join(dir, "server-ca.crt"); fileExists(caPath) {
+		c.config.Security.ServerCACert = caPath
+	}
+	c.config.Security.UseTLS = true
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// generateBatchID generates a random batch ID that doubles as idempotency key
+func generateBatchID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("batch-%d", time.Now().UnixNano())
+	}
+	return "batch-" + hex.EncodeToString(b)
+}
+
+// decodeJSON decodes a JSON response body into out
+func decodeJSON(data []byte, out interface{}) error {
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
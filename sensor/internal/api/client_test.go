@@ -0,0 +1,514 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/config"
+	"github.com/democorp/crypto-inventory/sensor/internal/models"
+	"github.com/klauspost/compress/zstd"
+)
+
+// testConfig returns the configuration of a registered sensor talking to
+// baseURL, with retries fast enough for tests
+func testConfig(t *testing.T, baseURL string) *config.Config {
+	return &config.Config{
+		SensorID:        "sensor-1",
+		Name:            "edge-1",
+		Platform:        "linux-amd64",
+		Version:         "1.0.0",
+		Profile:         "standard",
+		ControlPlaneURL: baseURL,
+		RegistrationKey: "REG-test",
+		Compression:     EncodingGzip,
+		RequestTimeout:  5 * time.Second,
+		MaxRetries:      3,
+		RetryBaseDelay:  time.Millisecond,
+		RetryMaxDelay:   10 * time.Millisecond,
+		Storage:         config.StorageConfig{DataPath: t.TempDir()},
+	}
+}
+
+func newTestClient(t *testing.T, cfg *config.Config) *Client {
+	t.Helper()
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+// clientCall is a Client method with the request it makes and the body it
+// is answered with
+type clientCall struct {
+	name   string
+	method string
+	path   string
+	body   string
+	call   func(t *testing.T, ctx context.Context, c *Client) error
+}
+
+func clientCalls() []clientCall {
+	return []clientCall{
+		{
+			name: "Register", method: http.MethodPost, path: "/api/v1/sensors/register",
+			body: `{"sensor_id":"sensor-2","config":{"config_version":3}}`,
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				update, err := c.Register(ctx)
+				if err == nil {
+					if c.SensorID() != "sensor-2" {
+						t.Errorf("sensor ID = %q, want sensor-2", c.SensorID())
+					}
+					if update.ConfigVersion != 3 {
+						t.Errorf("config version = %d, want 3", update.ConfigVersion)
+					}
+				}
+				return err
+			},
+		},
+		{
+			name: "Heartbeat", method: http.MethodPost, path: "/api/v1/sensors/sensor-1/heartbeat",
+			body: `{"commands":[]}`,
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				_, err := c.Heartbeat(ctx, &models.SensorHealth{})
+				return err
+			},
+		},
+		{
+			name: "SubmitDiscoveries", method: http.MethodPost, path: "/api/v1/sensors/sensor-1/discoveries",
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				return c.SubmitDiscoveries(ctx, []*models.CryptoDiscovery{{}})
+			},
+		},
+		{
+			name: "PollCommands", method: http.MethodGet, path: "/api/v1/sensors/sensor-1/commands",
+			body: `{"commands":[]}`,
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				_, err := c.PollCommands(ctx)
+				return err
+			},
+		},
+		{
+			name: "WaitForCommands", method: http.MethodGet, path: "/api/v1/sensors/sensor-1/commands",
+			body: `{"commands":[]}`,
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				_, err := c.WaitForCommands(ctx, time.Second)
+				return err
+			},
+		},
+		{
+			name: "ReportCommand", method: http.MethodPost, path: "/api/v1/sensors/sensor-1/commands/cmd-1/ack",
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				return c.ReportCommand(ctx, &models.CommandResult{CommandID: "cmd-1", Status: "succeeded"})
+			},
+		},
+		{
+			name: "SubmitTopology", method: http.MethodPost, path: "/api/v1/sensors/sensor-1/topology",
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				return c.SubmitTopology(ctx, &models.TopologyReport{})
+			},
+		},
+		{
+			name: "GetConfig", method: http.MethodGet, path: "/api/v1/sensors/sensor-1/config",
+			body: `{"reporting_interval":60}`,
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				_, err := c.GetConfig(ctx)
+				return err
+			},
+		},
+		{
+			name: "GetWebhookConfig", method: http.MethodGet, path: "/api/v1/sensors/sensor-1/webhook-config",
+			body: `{"enabled":false}`,
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				_, err := c.GetWebhookConfig(ctx)
+				return err
+			},
+		},
+		{
+			name: "ReportHealth", method: http.MethodPost, path: "/api/v1/sensors/sensor-1/health",
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				return c.ReportHealth(ctx, &models.SensorHealth{})
+			},
+		},
+		{
+			name: "SubmitAirGappedExport", method: http.MethodPost, path: "/api/v1/sensors/sensor-1/exports",
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				return c.SubmitAirGappedExport(ctx, &models.AirGappedExport{ExportID: "export-1"})
+			},
+		},
+		{
+			name: "DownloadRelease", method: http.MethodGet, path: "/api/v1/sensors/sensor-1/releases/1.2.0/linux-amd64",
+			body: "release binary",
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				file, err := os.Create(filepath.Join(t.TempDir(), "sensor"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+				if err := c.DownloadRelease(ctx, "1.2.0", "linux-amd64", file, 1024); err != nil {
+					return err
+				}
+				data, _ := os.ReadFile(file.Name())
+				if string(data) != "release binary" {
+					t.Errorf("downloaded %q, want the release binary", data)
+				}
+				return nil
+			},
+		},
+		{
+			name: "UploadCapture", method: http.MethodPut, path: "/api/v1/sensors/sensor-1/captures/cap-1",
+			call: func(t *testing.T, ctx context.Context, c *Client) error {
+				return c.UploadCapture(ctx, "cap-1", []byte("sealed"), 12)
+			},
+		},
+	}
+}
+
+// scriptedServer answers the requests of a call with the given statuses in
+// turn, then with 200 and the call's body, and counts them
+func scriptedServer(t *testing.T, call clientCall, retryAfter string, statuses ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if r.Method != call.method || r.URL.Path != call.path {
+			t.Errorf("request %s %s, want %s %s", r.Method, r.URL.Path, call.method, call.path)
+		}
+		if n <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, http.StatusText(statuses[n-1]), statuses[n-1])
+			return
+		}
+		io.WriteString(w, call.body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestClientCallsSucceed(t *testing.T) {
+	for _, call := range clientCalls() {
+		call := call
+		t.Run(call.name, func(t *testing.T) {
+			t.Parallel()
+			server, requests := scriptedServer(t, call, "")
+			client := newTestClient(t, testConfig(t, server.URL))
+
+			if err := call.call(t, context.Background(), client); err != nil {
+				t.Fatalf("%s: %v", call.name, err)
+			}
+			if n := atomic.LoadInt32(requests); n != 1 {
+				t.Errorf("%d requests, want 1", n)
+			}
+		})
+	}
+}
+
+func TestClientCallsRetryUnavailableAfterRetryAfter(t *testing.T) {
+	for _, call := range clientCalls() {
+		call := call
+		t.Run(call.name, func(t *testing.T) {
+			t.Parallel()
+			server, requests := scriptedServer(t, call, "1", http.StatusServiceUnavailable)
+			client := newTestClient(t, testConfig(t, server.URL))
+
+			start := time.Now()
+			if err := call.call(t, context.Background(), client); err != nil {
+				t.Fatalf("%s: %v", call.name, err)
+			}
+			if n := atomic.LoadInt32(requests); n != 2 {
+				t.Errorf("%d requests, want 2", n)
+			}
+			if elapsed := time.Since(start); elapsed < time.Second {
+				t.Errorf("retried after %v, before Retry-After elapsed", elapsed)
+			}
+		})
+	}
+}
+
+func TestClientCallsDoNotRetryClientErrors(t *testing.T) {
+	for _, call := range clientCalls() {
+		call := call
+		t.Run(call.name, func(t *testing.T) {
+			t.Parallel()
+			server, requests := scriptedServer(t, call, "", http.StatusBadRequest, http.StatusBadRequest)
+			client := newTestClient(t, testConfig(t, server.URL))
+
+			err := call.call(t, context.Background(), client)
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+				t.Fatalf("error = %v, want a 400 status error", err)
+			}
+			if n := atomic.LoadInt32(requests); n != 1 {
+				t.Errorf("%d requests, want 1", n)
+			}
+		})
+	}
+}
+
+func TestUploadCaptureTreatsConflictAsUploaded(t *testing.T) {
+	calls := clientCalls()
+	call := calls[len(calls)-1]
+	server, requests := scriptedServer(t, call, "", http.StatusConflict)
+	client := newTestClient(t, testConfig(t, server.URL))
+
+	if err := call.call(t, context.Background(), client); err != nil {
+		t.Fatalf("UploadCapture: %v", err)
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestRegisterIsNotRetriedOnceProcessed(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout} {
+		call := clientCalls()[0]
+		server, requests := scriptedServer(t, call, "", status, status)
+		client := newTestClient(t, testConfig(t, server.URL))
+
+		if _, err := client.Register(context.Background()); err == nil {
+			t.Fatalf("status %d: registration succeeded", status)
+		}
+		if n := atomic.LoadInt32(requests); n != 1 {
+			t.Errorf("status %d: %d requests, want 1", status, n)
+		}
+	}
+}
+
+func TestRegisterIsRetriedWhenUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	cfg := testConfig(t, url)
+	cfg.MaxRetries = 2
+	client := newTestClient(t, cfg)
+
+	_, err := client.Register(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("error = %v, want three failed attempts", err)
+	}
+}
+
+// decodeBody returns a request body decoded according to its
+// Content-Encoding
+func decodeBody(t *testing.T, r *http.Request) []byte {
+	t.Helper()
+	var reader io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "":
+	case EncodingGzip:
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		reader = zr
+	case EncodingZstd:
+		zr, err := zstd.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("zstd: %v", err)
+		}
+		defer zr.Close()
+		reader = zr
+	default:
+		t.Fatalf("unexpected Content-Encoding %q", r.Header.Get("Content-Encoding"))
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return data
+}
+
+func TestBatchCompression(t *testing.T) {
+	calls := map[string]func(ctx context.Context, c *Client) error{
+		"SubmitDiscoveries": func(ctx context.Context, c *Client) error {
+			return c.SubmitDiscoveries(ctx, []*models.CryptoDiscovery{{}, {}})
+		},
+		"SubmitAirGappedExport": func(ctx context.Context, c *Client) error {
+			return c.SubmitAirGappedExport(ctx, &models.AirGappedExport{ExportID: "export-1"})
+		},
+	}
+	encodings := map[string]string{EncodingGzip: EncodingGzip, EncodingZstd: EncodingZstd, EncodingNone: ""}
+
+	for name, call := range calls {
+		for compression, contentEncoding := range encodings {
+			t.Run(name+"/"+compression, func(t *testing.T) {
+				var body map[string]interface{}
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if got := r.Header.Get("Content-Encoding"); got != contentEncoding {
+						t.Errorf("Content-Encoding = %q, want %q", got, contentEncoding)
+					}
+					if err := json.Unmarshal(decodeBody(t, r), &body); err != nil {
+						t.Errorf("body is not JSON: %v", err)
+					}
+				}))
+				defer server.Close()
+				cfg := testConfig(t, server.URL)
+				cfg.Compression = compression
+				client := newTestClient(t, cfg)
+
+				if err := call(context.Background(), client); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if body["batch_id"] == nil && body["export_id"] != "export-1" {
+					t.Errorf("body %v is not the batch", body)
+				}
+			})
+		}
+	}
+}
+
+func TestUnsupportedCompressionIsRejected(t *testing.T) {
+	cfg := testConfig(t, "http://127.0.0.1:1")
+	cfg.Compression = "brotli"
+	if _, err := NewClient(cfg); err == nil {
+		t.Fatal("NewClient accepted an unsupported compression")
+	}
+}
+
+func TestBatchIdempotencyKey(t *testing.T) {
+	t.Run("SubmitDiscoveries", func(t *testing.T) {
+		var mu sync.Mutex
+		var keys, batchIDs []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var batch struct {
+				BatchID string `json:"batch_id"`
+			}
+			json.Unmarshal(decodeBody(t, r), &batch)
+			mu.Lock()
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			batchIDs = append(batchIDs, batch.BatchID)
+			first := len(keys) == 1
+			mu.Unlock()
+			if first {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+		client := newTestClient(t, testConfig(t, server.URL))
+
+		if err := client.SubmitDiscoveries(context.Background(), []*models.CryptoDiscovery{{}}); err != nil {
+			t.Fatalf("SubmitDiscoveries: %v", err)
+		}
+		if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+			t.Fatalf("Idempotency-Key of the attempts = %q, want the same key twice", keys)
+		}
+		if keys[0] != batchIDs[0] {
+			t.Errorf("Idempotency-Key %q differs from batch ID %q", keys[0], batchIDs[0])
+		}
+
+		// Another batch gets another key
+		if err := client.SubmitDiscoveries(context.Background(), []*models.CryptoDiscovery{{}}); err != nil {
+			t.Fatalf("SubmitDiscoveries: %v", err)
+		}
+		if keys[2] == keys[0] {
+			t.Errorf("two batches share the Idempotency-Key %q", keys[0])
+		}
+	})
+
+	t.Run("SubmitAirGappedExport", func(t *testing.T) {
+		var key string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key = r.Header.Get("Idempotency-Key")
+		}))
+		defer server.Close()
+		client := newTestClient(t, testConfig(t, server.URL))
+
+		if err := client.SubmitAirGappedExport(context.Background(), &models.AirGappedExport{ExportID: "export-1"}); err != nil {
+			t.Fatalf("SubmitAirGappedExport: %v", err)
+		}
+		if key != "export-1" {
+			t.Errorf("Idempotency-Key = %q, want the export ID", key)
+		}
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		key := "unset"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key = r.Header.Get("Idempotency-Key")
+			io.WriteString(w, `{}`)
+		}))
+		defer server.Close()
+		client := newTestClient(t, testConfig(t, server.URL))
+
+		if _, err := client.Heartbeat(context.Background(), &models.SensorHealth{}); err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+		if key != "" {
+			t.Errorf("heartbeat sent Idempotency-Key %q", key)
+		}
+	})
+}
+
+func TestProxySelection(t *testing.T) {
+	t.Run("configured proxy", func(t *testing.T) {
+		var proxied int32
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&proxied, 1)
+			if r.URL.Host != "control-plane.test:8085" || r.URL.Path != "/api/v1/sensors/sensor-1/heartbeat" {
+				t.Errorf("proxy received %s, want the control plane heartbeat", r.URL)
+			}
+			io.WriteString(w, `{"commands":[]}`)
+		}))
+		defer proxy.Close()
+		cfg := testConfig(t, "http://control-plane.test:8085")
+		cfg.ProxyURL = proxy.URL
+		client := newTestClient(t, cfg)
+
+		if _, err := client.Heartbeat(context.Background(), &models.SensorHealth{}); err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+		if n := atomic.LoadInt32(&proxied); n != 1 {
+			t.Errorf("%d requests through the proxy, want 1", n)
+		}
+	})
+
+	t.Run("configured proxy takes precedence over the environment", func(t *testing.T) {
+		cfg := testConfig(t, "https://control-plane.test")
+		cfg.ProxyURL = "http://egress.test:3128"
+		httpClient, err := newHTTPClient(cfg)
+		if err != nil {
+			t.Fatalf("newHTTPClient: %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "https://control-plane.test/api/v1/sensors/register", nil)
+		proxyURL, err := httpClient.Transport.(*http.Transport).Proxy(req)
+		if err != nil || proxyURL == nil || proxyURL.Host != "egress.test:3128" {
+			t.Errorf("proxy = %v, %v, want egress.test:3128", proxyURL, err)
+		}
+	})
+
+	t.Run("environment without configured proxy", func(t *testing.T) {
+		cfg := testConfig(t, "https://control-plane.test")
+		httpClient, err := newHTTPClient(cfg)
+		if err != nil {
+			t.Fatalf("newHTTPClient: %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "https://control-plane.test/api/v1/sensors/register", nil)
+		got, gotErr := httpClient.Transport.(*http.Transport).Proxy(req)
+		want, wantErr := http.ProxyFromEnvironment(req)
+		if fmt.Sprint(got) != fmt.Sprint(want) || (gotErr == nil) != (wantErr == nil) {
+			t.Errorf("proxy = %v, want %v from the environment", got, want)
+		}
+	})
+
+	t.Run("invalid proxy", func(t *testing.T) {
+		cfg := testConfig(t, "https://control-plane.test")
+		cfg.ProxyURL = "http://[::1"
+		if _, err := NewClient(cfg); err == nil {
+			t.Fatal("NewClient accepted an invalid proxy URL")
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported request body encodings for batch uploads
+const (
+	EncodingNone = "none"
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+func isSupportedEncoding(encoding string) bool {
+	switch encoding {
+	case EncodingNone, EncodingGzip, EncodingZstd:
+		return true
+	default:
+		return false
+	}
+}
+
+// compress encodes data with the given encoding. The returned content
+// encoding is empty when the body is sent uncompressed.
+func compress(data []byte, encoding string) ([]byte, string, error) {
+	switch encoding {
+	case EncodingGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, "", fmt.Errorf("failed to gzip body: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, "", fmt.Errorf("failed to gzip body: %v", err)
+		}
+		return buf.Bytes(), EncodingGzip, nil
+	case EncodingZstd:
+		zw, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create zstd encoder: %v", err)
+		}
+		defer zw.Close()
+		return zw.EncodeAll(data, nil), EncodingZstd, nil
+	default:
+		return data, "", nil
+	}
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/config"
+)
+
+// maxResponseSize caps how much of a response body is read into memory
+const maxResponseSize = 10 * 1024 * 1024
+
+// RetryPolicy controls how failed control plane calls are retried
+type RetryPolicy struct {
+	MaxRetries     int           // retries after the first attempt
+	BaseDelay      time.Duration // backoff ceiling for the first retry
+	MaxDelay       time.Duration // upper bound for any single backoff
+	RequestTimeout time.Duration // deadline for each individual attempt
+}
+
+// NewRetryPolicy creates a retry policy from the sensor configuration
+func NewRetryPolicy(cfg *config.Config) RetryPolicy {
+	policy := RetryPolicy{
+		MaxRetries:     cfg.MaxRetries,
+		BaseDelay:      cfg.RetryBaseDelay,
+		MaxDelay:       cfg.RetryMaxDelay,
+		RequestTimeout: cfg.RequestTimeout,
+	}
+	if policy.MaxRetries < 0 {
+		policy.MaxRetries = 0
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = time.Second
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	if policy.RequestTimeout <= 0 {
+		policy.RequestTimeout = 30 * time.Second
+	}
+	return policy
+}
+
+// backoff returns a "full jitter" delay for the given retry attempt: a random
+// duration between zero and an exponentially growing ceiling. Spreading
+// retries out keeps a fleet of sensors from hammering a recovering server.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	if attempt < 32 {
+		if d := p.BaseDelay << uint(attempt-1); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// StatusError is returned when the control plane answers with a non-2xx status
+type StatusError struct {
+	Op         string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Op, e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the request may succeed if retried
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= 500
+}
+
+// request describes a single control plane call
+type request struct {
+	op             string      // human-readable operation name for errors
+	method         string      // HTTP method
+	path           string      // path below the control plane base URL
+	query          string      // encoded query string, if any
+	body           interface{} // JSON request body, if any
+	encoding       string      // body compression, empty for none
+	idempotencyKey string      // sent as Idempotency-Key when set
+	timeout        time.Duration
+
+	// unrepeatable marks calls the control plane cannot deduplicate. They
+	// are only retried when the previous attempt cannot have been acted
+	// on: the connection was never established or the server refused it
+	// unprocessed with 429 or 503.
+	unrepeatable bool
+
+	// upload, when set, is sent as an application/octet-stream body
+	// instead of a JSON body
+	upload []byte
+	header http.Header // additional request headers
+
+	// download, when set, receives the body of a successful response
+	// instead of it being read into memory. It is called again on retry and
+	// must start over each time.
+	download func(io.Reader) error
+}
+
+// do executes a request, retrying transient failures with jittered backoff.
+// The body is encoded once so every attempt sends identical bytes and the
+// same idempotency key. The response body is decoded into out when non-nil.
+func (c *Client) do(ctx context.Context, req *request, out interface{}) error {
+	var payload []byte
+	var contentEncoding string
+	if req.body != nil {
+		data, err := json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %v", req.op, err)
+		}
+		payload, contentEncoding, err = compress(data, req.encoding)
+		if err != nil {
+			return err
+		}
+	} else if req.upload != nil {
+		payload = req.upload
+	}
+
+	timeout := req.timeout
+	if timeout <= 0 {
+		timeout = c.retry.RequestTimeout
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.retry.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := c.retry.backoff(attempt)
+			if statusErr, ok := lastErr.(*StatusError); ok && statusErr.RetryAfter > delay {
+				delay = statusErr.RetryAfter
+			}
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("%s canceled: %v (last error: %v)", req.op, ctx.Err(), lastErr)
+			case <-timer.C:
+			}
+		}
+
+		body, err := c.attempt(ctx, req, payload, contentEncoding, timeout)
+		if err == nil {
+			if err := decodeJSON(body, out); err != nil {
+				return fmt.Errorf("failed to decode %s response: %v", req.op, err)
+			}
+			return nil
+		}
+
+		lastErr = err
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s canceled: %v", req.op, ctx.Err())
+		}
+		if statusErr, ok := err.(*StatusError); ok && !statusErr.Temporary() {
+			return err
+		}
+		if req.unrepeatable && !notProcessed(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%s failed after %d attempts: %v", req.op, c.retry.MaxRetries+1, lastErr)
+}
+
+// attempt performs one HTTP round trip under its own deadline
+func (c *Client) attempt(ctx context.Context, req *request, payload []byte, contentEncoding string, timeout time.Duration) ([]byte, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	url := c.baseURL + req.path
+	if req.query != "" {
+		url += "?" + req.query
+	}
+
+	var bodyReader io.Reader
+	if payload != nil {
+		bodyReader = bytes.NewReader(payload)
+	}
+
+	httpReq, err := http.NewRequestWithContext(attemptCtx, req.method, url, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %v", req.op, err)
+	}
+
+	if req.download != nil {
+		httpReq.Header.Set("Accept", "application/octet-stream")
+	} else {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	if req.upload != nil {
+		httpReq.Header.Set("Content-Type", "application/octet-stream")
+	} else if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	if contentEncoding != "" {
+		httpReq.Header.Set("Content-Encoding", contentEncoding)
+	}
+	if req.idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.idempotencyKey)
+	}
+	if sensorID := c.SensorID(); sensorID != "" {
+		httpReq.Header.Set("X-Sensor-ID", sensorID)
+	}
+
+	resp, err := c.currentHTTPClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", req.op, err)
+	}
+	defer resp.Body.Close()
+
+	if req.download != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := req.download(resp.Body); err != nil {
+			return nil, fmt.Errorf("failed to download %s: %v", req.op, err)
+		}
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %v", req.op, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{
+			Op:         req.op,
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return body, nil
+}
+
+// notProcessed reports whether a failed attempt certainly never reached the
+// control plane's handler, so even a call it cannot deduplicate may be
+// retried
+func notProcessed(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests ||
+			statusErr.StatusCode == http.StatusServiceUnavailable
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/config"
+)
+
+// reloadTransport rebuilds the HTTP client from the current security and
+// proxy configuration. It is called at startup and after registration.
+func (c *Client) reloadTransport() error {
+	httpClient, err := newHTTPClient(c.config)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	old := c.httpClient
+	c.httpClient = httpClient
+	c.mu.Unlock()
+
+	if old != nil {
+		old.CloseIdleConnections()
+	}
+	return nil
+}
+
+// currentHTTPClient returns the HTTP client in use
+func (c *Client) currentHTTPClient() *http.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.httpClient
+}
+
+// newHTTPClient configures an HTTP client with mTLS and egress proxy support.
+// Per-request deadlines are applied through contexts rather than a global
+// client timeout so long-running calls can set their own limits.
+func newHTTPClient(cfg *config.Config) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %v", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   15 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if cfg.Security.UseTLS {
+		tlsConfig, err := newTLSConfig(cfg.Security)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newTLSConfig loads the client certificate and the control plane CA
+func newTLSConfig(sec config.SecurityConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if sec.ClientCert != "" && sec.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(sec.ClientCert, sec.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if sec.ServerCACert != "" {
+		caPEM, err := os.ReadFile(sec.ServerCACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read server CA certificate: %v", err)
+		}
+
+		// Trust the system roots as well so a publicly signed control plane
+		// certificate keeps working next to the platform CA
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", sec.ServerCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
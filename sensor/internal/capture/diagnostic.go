@@ -0,0 +1,299 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket/pcapgo"
+)
+
+const (
+	// diagnosticSnaplen is the snapshot length of diagnostic captures
+	diagnosticSnaplen = 65535
+	// diagnosticReadTimeout bounds each read, so the capture notices its
+	// deadline on a quiet interface
+	diagnosticReadTimeout = 500 * time.Millisecond
+	// maxHandshakeBytes caps the payload kept per flow direction
+	maxHandshakeBytes = 64 << 10
+	// maxDiagnosticFlows bounds the flow table; payload of flows beyond it
+	// is stripped
+	maxDiagnosticFlows = 10000
+
+	// pcap file and record header sizes, counted against the byte limit
+	pcapFileHeaderSize   = 24
+	pcapRecordHeaderSize = 16
+)
+
+// DiagnosticOptions describes a diagnostic capture
+type DiagnosticOptions struct {
+	Interface string
+	Filter    string // BPF expression
+	Duration  time.Duration
+	MaxBytes  int64 // size limit of the written pcap
+}
+
+// DiagnosticResult summarizes a finished diagnostic capture
+type DiagnosticResult struct {
+	Packets       int   // packets written
+	Bytes         int64 // size of the written pcap
+	StrippedBytes int64 // payload bytes left out
+	LimitReached  bool  // the capture stopped at the byte limit
+}
+
+// Diagnostic captures packets matching a BPF expression on an interface
+// into a pcap written to w, until the duration elapses, the byte limit is
+// reached or ctx is done. It keeps packet headers and the TLS and SSH
+// handshakes of TCP flows; every payload byte after a handshake, and all
+// payload of other traffic, is left out by truncating the packet, so the
+// pcap reveals no application data.
+func Diagnostic(ctx context.Context, opts DiagnosticOptions, w io.Writer) (*DiagnosticResult, error) {
+	handle, err := pcap.OpenLive(opts.Interface, diagnosticSnaplen, true, diagnosticReadTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open interface %s: %v", opts.Interface, err)
+	}
+	defer handle.Close()
+
+	if err := handle.SetBPFFilter(opts.Filter); err != nil {
+		return nil, fmt.Errorf("invalid BPF expression: %v", err)
+	}
+
+	writer := pcapgo.NewWriter(w)
+	if err := writer.WriteFileHeader(diagnosticSnaplen, handle.LinkType()); err != nil {
+		return nil, err
+	}
+
+	result := &DiagnosticResult{Bytes: pcapFileHeaderSize}
+	redactor := newRedactor()
+	deadline := time.Now().Add(opts.Duration)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		data, ci, err := handle.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("capture failed: %v", err)
+		}
+
+		packet := gopacket.NewPacket(data, handle.LinkType(), gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+		keep := redactor.keep(packet)
+		size := int64(pcapRecordHeaderSize + keep)
+		if result.Bytes+size > opts.MaxBytes {
+			result.LimitReached = true
+			break
+		}
+
+		// The original length is kept, so tools show the packets as truncated
+		ci.CaptureLength = keep
+		if err := writer.WritePacket(ci, data[:keep]); err != nil {
+			return nil, err
+		}
+		result.Packets++
+		result.Bytes += size
+		result.StrippedBytes += int64(len(data) - keep)
+	}
+	return result, nil
+}
+
+// streamProtocol is the handshake protocol recognized in a TCP stream
+type streamProtocol int
+
+const (
+	streamUnknown streamProtocol = iota
+	streamTLS
+	streamSSH
+)
+
+// TLS record types and SSH message numbers marking the end of a handshake
+const (
+	tlsChangeCipherSpec = 0x14
+	tlsApplicationData  = 0x17
+	sshMsgNewKeys       = 21
+	sshMaxPacketLength  = 35000
+)
+
+// streamState follows the handshake of one direction of a TCP flow. Records
+// are tracked across segments; anything that does not parse as a handshake
+// ends it, so the redaction fails closed when segments are lost or
+// reordered.
+type streamState struct {
+	protocol streamProtocol
+	done     bool
+	banner   bool // SSH identification line complete
+	pending  int  // bytes of the current record still to come
+	kept     int
+}
+
+// flowKey identifies one direction of a TCP flow
+type flowKey struct {
+	network, transport gopacket.Flow
+}
+
+// redactor decides how many bytes of each packet a diagnostic capture keeps
+type redactor struct {
+	streams map[flowKey]*streamState
+}
+
+func newRedactor() *redactor {
+	return &redactor{streams: make(map[flowKey]*streamState)}
+}
+
+// keep returns how many leading bytes of a packet to keep: its headers up to
+// the transport layer, plus TCP payload still belonging to a handshake
+func (r *redactor) keep(packet gopacket.Packet) int {
+	data := packet.Data()
+	transport := packet.TransportLayer()
+
+	headers := 0
+	for _, layer := range packet.Layers() {
+		switch layer.LayerType() {
+		case gopacket.LayerTypePayload, gopacket.LayerTypeFragment, gopacket.LayerTypeDecodeFailure:
+			return headers
+		}
+		headers += len(layer.LayerContents())
+		if layer == transport {
+			break
+		}
+	}
+	if headers > len(data) {
+		headers = len(data)
+	}
+
+	tcp, ok := transport.(*layers.TCP)
+	network := packet.NetworkLayer()
+	if !ok || network == nil {
+		return headers
+	}
+
+	key := flowKey{network: network.NetworkFlow(), transport: tcp.TransportFlow()}
+	if tcp.SYN || tcp.FIN || tcp.RST {
+		delete(r.streams, key)
+	}
+	if tcp.FIN || tcp.RST {
+		return headers
+	}
+
+	state, ok := r.streams[key]
+	if !ok {
+		if len(r.streams) >= maxDiagnosticFlows {
+			return headers
+		}
+		state = &streamState{}
+		r.streams[key] = state
+	}
+
+	keep := headers + state.keep(tcp.LayerPayload())
+	if keep > len(data) {
+		keep = len(data)
+	}
+	return keep
+}
+
+// keep returns how many leading bytes of a segment's payload belong to the
+// handshake
+func (s *streamState) keep(payload []byte) int {
+	if s.done || len(payload) == 0 {
+		return 0
+	}
+	if s.protocol == streamUnknown {
+		switch {
+		case payload[0] == 0x16: // TLS handshake record
+			s.protocol = streamTLS
+		case bytes.HasPrefix(payload, []byte("SSH-")):
+			s.protocol = streamSSH
+		default:
+			s.done = true
+			return 0
+		}
+	}
+
+	var n int
+	if s.protocol == streamTLS {
+		n = s.scanTLS(payload)
+	} else {
+		n = s.scanSSH(payload)
+	}
+	if s.kept+n > maxHandshakeBytes {
+		n = maxHandshakeBytes - s.kept
+		s.done = true
+	}
+	s.kept += n
+	return n
+}
+
+// scanTLS keeps plaintext records up to and including ChangeCipherSpec
+func (s *streamState) scanTLS(payload []byte) int {
+	i := s.skipPending(payload)
+	for i < len(payload) {
+		if len(payload)-i < 5 {
+			s.done = true
+			return i
+		}
+		recordType := payload[i]
+		if recordType < tlsChangeCipherSpec || recordType >= tlsApplicationData || payload[i+1] != 0x03 {
+			s.done = true
+			return i
+		}
+		end := i + 5 + int(binary.BigEndian.Uint16(payload[i+3:]))
+		if recordType == tlsChangeCipherSpec {
+			s.done = true
+			return min(end, len(payload))
+		}
+		if end > len(payload) {
+			s.pending = end - len(payload)
+			return len(payload)
+		}
+		i = end
+	}
+	return i
+}
+
+// scanSSH keeps the identification line and the binary packets of the key
+// exchange up to and including NEWKEYS
+func (s *streamState) scanSSH(payload []byte) int {
+	i := 0
+	if !s.banner {
+		newline := bytes.IndexByte(payload, '\n')
+		if newline < 0 {
+			return len(payload)
+		}
+		s.banner = true
+		i = newline + 1
+	}
+	i += s.skipPending(payload[i:])
+	for i < len(payload) {
+		if len(payload)-i < 6 {
+			s.done = true
+			return i
+		}
+		length := int(binary.BigEndian.Uint32(payload[i:]))
+		if length < 2 || length > sshMaxPacketLength {
+			s.done = true
+			return i
+		}
+		end := i + 4 + length
+		if payload[i+5] == sshMsgNewKeys {
+			s.done = true
+			return min(end, len(payload))
+		}
+		if end > len(payload) {
+			s.pending = end - len(payload)
+			return len(payload)
+		}
+		i = end
+	}
+	return i
+}
+
+// skipPending consumes the rest of a record started in an earlier segment
+func (s *streamState) skipPending(payload []byte) int {
+	n := min(s.pending, len(payload))
+	s.pending -= n
+	return n
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"log"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/democorp/crypto-inventory/sensor/internal/config"
@@ -19,30 +23,64 @@ type PacketCapture struct {
 	config      *config.Config
 	interfaces  []string
 	handles     []*pcap.Handle
+	handleNames []string // interface of each handle
 	ctx         context.Context
 	cancel      context.CancelFunc
 	wg          sync.WaitGroup
+	mu          sync.Mutex // serializes Start/Stop, which may be driven by commands
+	running     bool
+	protocols   atomic.Pointer[map[int]string] // port -> protocol, replaced on Start
 	discoveries chan *models.CryptoDiscovery
 	errors      chan error
+	topology    *topology
+
+	closedStats        Stats        // counters of handles closed by Stop, guarded by mu
+	discoveriesDropped atomic.Int64 // discoveries lost to a full channel
+}
+
+// Stats are packet capture counters accumulated since the sensor started
+type Stats struct {
+	PacketsReceived    int64 `json:"packets_received"`    // packets that passed the capture filter
+	PacketsDropped     int64 `json:"packets_dropped"`     // packets dropped by the kernel or the interface
+	DiscoveriesDropped int64 `json:"discoveries_dropped"` // discoveries lost because the channel was full
+}
+
+// InterfaceStats are the counters of one configured capture interface
+type InterfaceStats struct {
+	Interface       string `json:"interface"`
+	Capturing       bool   `json:"capturing"` // false when the interface could not be opened
+	PacketsReceived int64  `json:"packets_received"`
+	PacketsDropped  int64  `json:"packets_dropped"`
 }
 
 // NewPacketCapture creates a new packet capture instance
 func NewPacketCapture(cfg *config.Config) *PacketCapture {
-	ctx, cancel := context.WithCancel(context.Background())
-
 	return &PacketCapture{
 		config:      cfg,
-		interfaces:  cfg.Capture.Interfaces,
-		ctx:         ctx,
-		cancel:      cancel,
 		discoveries: make(chan *models.CryptoDiscovery, 1000),
 		errors:      make(chan error, 100),
+		topology:    newTopology(),
 	}
 }
 
-// Start begins packet capture on all configured interfaces
+// Start begins packet capture on all configured interfaces. Capture can be
+// stopped and started again; the discovery and error channels stay valid.
+// Interfaces and the port map are read from the configuration on each
+// start, so a restart picks up configuration changes.
 func (pc *PacketCapture) Start() error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.running {
+		return nil
+	}
+
+	pc.interfaces = append([]string(nil), pc.config.Capture.Interfaces...)
+	protocols := portProtocols(pc.config.PortMap)
+	pc.protocols.Store(&protocols)
+
 	log.Printf("Starting packet capture on interfaces: %v", pc.interfaces)
+	pc.ctx, pc.cancel = context.WithCancel(context.Background())
 
 	for _, iface := range pc.interfaces {
 		if err := pc.startInterfaceCapture(iface); err != nil {
@@ -52,6 +90,7 @@ func (pc *PacketCapture) Start() error {
 	}
 
 	if len(pc.handles) == 0 {
+		pc.cancel()
 		return fmt.Errorf("no interfaces available for capture")
 	}
 
@@ -61,26 +100,94 @@ func (pc *PacketCapture) Start() error {
 		go pc.processPackets()
 	}
 
+	pc.running = true
 	log.Printf("Packet capture started on %d interfaces", len(pc.handles))
 	return nil
 }
 
 // Stop stops packet capture
 func (pc *PacketCapture) Stop() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if !pc.running {
+		return
+	}
+
 	log.Println("Stopping packet capture...")
 	pc.cancel()
 
-	// Close all handles
+	// Close all handles, keeping their counters
 	for _, handle := range pc.handles {
+		addHandleStats(&pc.closedStats, handle)
 		handle.Close()
 	}
 
 	pc.wg.Wait()
-	close(pc.discoveries)
-	close(pc.errors)
+	pc.handles = nil
+	pc.handleNames = nil
+	pc.running = false
 	log.Println("Packet capture stopped")
 }
 
+// IsRunning reports whether packet capture is active
+func (pc *PacketCapture) IsRunning() bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.running
+}
+
+// Stats returns the capture counters accumulated since the sensor started,
+// across restarts of the capture
+func (pc *PacketCapture) Stats() Stats {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	stats := pc.closedStats
+	for _, handle := range pc.handles {
+		addHandleStats(&stats, handle)
+	}
+	stats.DiscoveriesDropped = pc.discoveriesDropped.Load()
+	return stats
+}
+
+// InterfaceStats returns the counters of each interface of the running
+// capture since it was started
+func (pc *PacketCapture) InterfaceStats() []InterfaceStats {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if !pc.running {
+		return []InterfaceStats{}
+	}
+	stats := make([]InterfaceStats, 0, len(pc.interfaces))
+	for _, iface := range pc.interfaces {
+		entry := InterfaceStats{Interface: iface}
+		for i, name := range pc.handleNames {
+			if name != iface {
+				continue
+			}
+			var handleStats Stats
+			addHandleStats(&handleStats, pc.handles[i])
+			entry.Capturing = true
+			entry.PacketsReceived = handleStats.PacketsReceived
+			entry.PacketsDropped = handleStats.PacketsDropped
+		}
+		stats = append(stats, entry)
+	}
+	return stats
+}
+
+// addHandleStats adds the counters libpcap keeps for a handle
+func addHandleStats(stats *Stats, handle *pcap.Handle) {
+	handleStats, err := handle.Stats()
+	if err != nil {
+		return
+	}
+	stats.PacketsReceived += int64(handleStats.PacketsReceived)
+	stats.PacketsDropped += int64(handleStats.PacketsDropped + handleStats.PacketsIfDropped)
+}
+
 // GetDiscoveries returns the discoveries channel
 func (pc *PacketCapture) GetDiscoveries() <-chan *models.CryptoDiscovery {
 	return pc.discoveries
@@ -118,12 +225,13 @@ func (pc *PacketCapture) startInterfaceCapture(iface string) error {
 	}
 
 	// Set BPF filter for crypto-related traffic
-	filter := "tcp port 443 or tcp port 22 or tcp port 993 or tcp port 995 or tcp port 465 or tcp port 587"
+	filter := bpfFilter(*pc.protocols.Load())
 	if err := handle.SetBPFFilter(filter); err != nil {
 		log.Printf("Warning: Failed to set BPF filter on %s: %v", iface, err)
 	}
 
 	pc.handles = append(pc.handles, handle)
+	pc.handleNames = append(pc.handleNames, iface)
 
 	// Start capture goroutine for this interface
 	pc.wg.Add(1)
@@ -189,12 +297,13 @@ func (pc *PacketCapture) analyzePacket(packet gopacket.Packet, iface string) {
 	dstPort := transportLayer.TransportFlow().Dst().String()
 
 	// Analyze based on port
-	port := getPortNumber(dstPort)
-	protocol := getProtocolFromPort(port)
+	port, _ := strconv.Atoi(dstPort)
+	protocol := (*pc.protocols.Load())[port]
 
 	if protocol == "" {
 		return
 	}
+	pc.topology.observe(packet, iface, protocol, port)
 
 	// Create discovery record
 	discovery := &models.CryptoDiscovery{
@@ -223,6 +332,7 @@ func (pc *PacketCapture) analyzePacket(packet gopacket.Packet, iface string) {
 	case pc.discoveries <- discovery:
 	default:
 		// Channel is full, log warning
+		pc.discoveriesDropped.Add(1)
 		log.Printf("Warning: Discovery channel full, dropping discovery")
 	}
 }
@@ -323,36 +433,31 @@ func (pc *PacketCapture) analyzeCertificate(payload []byte, discovery *models.Cr
 	discovery.Confidence = 0.95
 }
 
-// Helper functions
-func getPortNumber(portStr string) int {
-	// Simple port parsing - in real implementation, use strconv.Atoi
-	switch portStr {
-	case "443":
-		return 443
-	case "22":
-		return 22
-	case "993":
-		return 993
-	case "995":
-		return 995
-	case "465":
-		return 465
-	case "587":
-		return 587
-	default:
-		return 0
+// portProtocols maps each configured port to the protocol name used in
+// discoveries
+func portProtocols(portMap map[string][]int) map[int]string {
+	protocols := make(map[int]string)
+	for protocol, ports := range portMap {
+		for _, port := range ports {
+			protocols[port] = strings.ToUpper(protocol)
+		}
 	}
+	return protocols
 }
 
-func getProtocolFromPort(port int) string {
-	switch port {
-	case 443, 993, 995, 465, 587:
-		return "TLS"
-	case 22:
-		return "SSH"
-	default:
-		return ""
+// bpfFilter builds a capture filter matching TCP traffic on the given ports
+func bpfFilter(protocols map[int]string) string {
+	ports := make([]int, 0, len(protocols))
+	for port := range protocols {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	clauses := make([]string, len(ports))
+	for i, port := range ports {
+		clauses[i] = fmt.Sprintf("tcp port %d", port)
 	}
+	return strings.Join(clauses, " or ")
 }
 
 func getTLSVersion(version uint16) string {
@@ -0,0 +1,64 @@
+package capture
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// sealContext prefixes the key derivation input; the control plane uses
+// the same
+const sealContext = "crypto-sensor-capture:v1"
+
+// Seal encrypts a diagnostic pcap to the control plane's base64 X25519
+// capture key. The result is an ephemeral public key, a nonce and the
+// AES-256-GCM ciphertext, authenticated with the capture ID so it cannot
+// be passed off as another capture. Only the holder of the capture key can
+// open it.
+func Seal(recipientKey, captureID string, pcap []byte) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(recipientKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture key: %v", err)
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture key: %v", err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture key: %v", err)
+	}
+
+	kdf := sha256.New()
+	kdf.Write([]byte(sealContext))
+	kdf.Write(shared)
+	kdf.Write(ephemeral.PublicKey().Bytes())
+	kdf.Write(recipient.Bytes())
+
+	block, err := aes.NewCipher(kdf.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, 0, len(ephemeral.PublicKey().Bytes())+len(nonce)+len(pcap)+aead.Overhead())
+	sealed = append(sealed, ephemeral.PublicKey().Bytes()...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, pcap, []byte(captureID)), nil
+}
@@ -0,0 +1,369 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/models"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	// Prefix lengths addresses seen in traffic are aggregated to
+	topologyIPv4Prefix = 24
+	topologyIPv6Prefix = 64
+
+	// Bounds of one topology window; observations beyond them are not
+	// recorded, so a scan across many networks cannot exhaust memory
+	maxTopologySubnets = 4096
+	maxTopologyFlows   = 10000
+	maxTopologyMACs    = 1024
+	maxSubnetHosts     = 1024 // distinct hosts counted per subnet
+	maxGatewaySubnets  = 256  // source subnets counted per MAC
+
+	// gatewayMinSubnets is how many source subnets a MAC must send from
+	// before it is taken for a gateway
+	gatewayMinSubnets = 3
+
+	// routeTable is the Linux IPv4 routing table
+	routeTable = "/proc/net/route"
+)
+
+type topologySubnetKey struct {
+	cidr string
+	vlan int
+}
+
+type topologySubnet struct {
+	iface   string
+	hosts   map[string]struct{}
+	packets int64
+}
+
+type topologyMACKey struct {
+	mac  string
+	vlan int
+}
+
+type topologyFlowKey struct {
+	src, dst string
+	protocol string
+	port     int
+	vlan     int
+}
+
+// topology accumulates the subnets, VLANs, gateways and flows seen in
+// captured traffic during one reporting window
+type topology struct {
+	mu      sync.Mutex
+	start   time.Time
+	subnets map[topologySubnetKey]*topologySubnet
+	vlans   map[int]int64
+	macs    map[topologyMACKey]map[string]struct{} // source subnets per MAC
+	flows   map[topologyFlowKey]int64
+}
+
+func newTopology() *topology {
+	t := &topology{}
+	t.reset(time.Now())
+	return t
+}
+
+func (t *topology) reset(now time.Time) {
+	t.start = now
+	t.subnets = make(map[topologySubnetKey]*topologySubnet)
+	t.vlans = make(map[int]int64)
+	t.macs = make(map[topologyMACKey]map[string]struct{})
+	t.flows = make(map[topologyFlowKey]int64)
+}
+
+// observe records a captured packet of a service on protocol and port
+func (t *topology) observe(packet gopacket.Packet, iface, protocol string, port int) {
+	network := packet.NetworkLayer()
+	if network == nil {
+		return
+	}
+	srcIP := net.IP(network.NetworkFlow().Src().Raw())
+	dstIP := net.IP(network.NetworkFlow().Dst().Raw())
+	src, dst := subnetOf(srcIP), subnetOf(dstIP)
+
+	vlan := 0
+	if layer, ok := packet.Layer(layers.LayerTypeDot1Q).(*layers.Dot1Q); ok {
+		vlan = int(layer.VLANIdentifier)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if vlan != 0 {
+		t.vlans[vlan]++
+	}
+	t.addHost(src, vlan, iface, srcIP)
+	t.addHost(dst, vlan, iface, dstIP)
+
+	if src == "" || dst == "" {
+		return
+	}
+	key := topologyFlowKey{src: src, dst: dst, protocol: protocol, port: port, vlan: vlan}
+	if _, ok := t.flows[key]; ok || len(t.flows) < maxTopologyFlows {
+		t.flows[key]++
+	}
+
+	if ethernet, ok := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); ok {
+		macKey := topologyMACKey{mac: ethernet.SrcMAC.String(), vlan: vlan}
+		sources, ok := t.macs[macKey]
+		if !ok {
+			if len(t.macs) >= maxTopologyMACs {
+				return
+			}
+			sources = make(map[string]struct{})
+			t.macs[macKey] = sources
+		}
+		if len(sources) < maxGatewaySubnets {
+			sources[src] = struct{}{}
+		}
+	}
+}
+
+func (t *topology) addHost(cidr string, vlan int, iface string, ip net.IP) {
+	if cidr == "" {
+		return
+	}
+	key := topologySubnetKey{cidr: cidr, vlan: vlan}
+	subnet, ok := t.subnets[key]
+	if !ok {
+		if len(t.subnets) >= maxTopologySubnets {
+			return
+		}
+		subnet = &topologySubnet{iface: iface, hosts: make(map[string]struct{})}
+		t.subnets[key] = subnet
+	}
+	subnet.packets++
+	if len(subnet.hosts) < maxSubnetHosts {
+		subnet.hosts[ip.String()] = struct{}{}
+	}
+}
+
+// snapshot returns what was observed since the last snapshot and starts a
+// new window
+func (t *topology) snapshot(now time.Time) *models.TopologyReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := &models.TopologyReport{
+		WindowStart: t.start,
+		WindowEnd:   now,
+		Subnets:     make([]models.ObservedSubnet, 0, len(t.subnets)),
+		VLANs:       make([]models.ObservedVLAN, 0, len(t.vlans)),
+		Gateways:    []models.ObservedGateway{},
+		Flows:       make([]models.ObservedFlow, 0, len(t.flows)),
+	}
+	for key, subnet := range t.subnets {
+		report.Subnets = append(report.Subnets, models.ObservedSubnet{
+			CIDR:      key.cidr,
+			VLAN:      key.vlan,
+			Source:    models.TopologySourceObserved,
+			Interface: subnet.iface,
+			Hosts:     len(subnet.hosts),
+			Packets:   subnet.packets,
+		})
+	}
+	for id, packets := range t.vlans {
+		report.VLANs = append(report.VLANs, models.ObservedVLAN{ID: id, Source: models.TopologySourceObserved, Packets: packets})
+	}
+	for key, sources := range t.macs {
+		if len(sources) < gatewayMinSubnets {
+			continue
+		}
+		report.Gateways = append(report.Gateways, models.ObservedGateway{
+			MAC:     key.mac,
+			VLAN:    key.vlan,
+			Source:  models.TopologySourceObserved,
+			Subnets: len(sources),
+		})
+	}
+	for key, packets := range t.flows {
+		report.Flows = append(report.Flows, models.ObservedFlow{
+			SrcCIDR:  key.src,
+			DstCIDR:  key.dst,
+			Protocol: key.protocol,
+			Port:     key.port,
+			VLAN:     key.vlan,
+			Packets:  packets,
+		})
+	}
+
+	t.reset(now)
+	return report
+}
+
+// Topology returns the topology observed since the previous call, together
+// with the subnets of the capture interfaces, the configured VLANs and
+// gateways and the default gateways of the host
+func (pc *PacketCapture) Topology() *models.TopologyReport {
+	report := pc.topology.snapshot(time.Now())
+
+	pc.mu.Lock()
+	interfaces := append([]string(nil), pc.interfaces...)
+	pc.mu.Unlock()
+	if len(interfaces) == 0 {
+		interfaces = pc.config.Capture.Interfaces
+	}
+
+	report.Subnets = append(report.Subnets, interfaceSubnets(interfaces)...)
+	report.Gateways = append(report.Gateways, routeGateways(routeTable, interfaces)...)
+
+	for _, value := range pc.config.Network.VLANs {
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || id < 1 || id > 4094 {
+			continue
+		}
+		report.VLANs = append(report.VLANs, models.ObservedVLAN{ID: id, Source: models.TopologySourceConfigured})
+	}
+	for _, value := range pc.config.Network.Gateways {
+		ip := net.ParseIP(strings.TrimSpace(value))
+		if ip == nil {
+			continue
+		}
+		report.Gateways = append(report.Gateways, models.ObservedGateway{IP: ip.String(), Source: models.TopologySourceConfigured})
+	}
+
+	sortTopology(report)
+	return report
+}
+
+// subnetOf returns the subnet an address is aggregated to, or "" for
+// addresses that do not belong to a network such as loopback and multicast
+func subnetOf(ip net.IP) string {
+	if !ip.IsGlobalUnicast() {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(topologyIPv4Prefix, 32)), Mask: net.CIDRMask(topologyIPv4Prefix, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(topologyIPv6Prefix, 128)), Mask: net.CIDRMask(topologyIPv6Prefix, 128)}).String()
+}
+
+// interfaceSubnets returns the networks addressed on the given interfaces
+func interfaceSubnets(interfaces []string) []models.ObservedSubnet {
+	var subnets []models.ObservedSubnet
+	for _, name := range interfaces {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+			network := &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}
+			subnets = append(subnets, models.ObservedSubnet{
+				CIDR:      network.String(),
+				Source:    models.TopologySourceInterface,
+				Interface: name,
+			})
+		}
+	}
+	return subnets
+}
+
+// routeGateways returns the IPv4 default gateways of the given interfaces
+// from a Linux routing table; elsewhere it finds none
+func routeGateways(path string, interfaces []string) []models.ObservedGateway {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	wanted := make(map[string]bool, len(interfaces))
+	for _, iface := range interfaces {
+		wanted[iface] = true
+	}
+
+	const rtfGateway = 0x2
+	var gateways []models.ObservedGateway
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags ..., addresses in host byte order
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !wanted[fields[0]] || fields[1] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		gateways = append(gateways, models.ObservedGateway{
+			IP:        ip.String(),
+			Source:    models.TopologySourceRoute,
+			Interface: fields[0],
+		})
+	}
+	return gateways
+}
+
+// sortTopology orders a report so consecutive reports are easy to compare
+func sortTopology(report *models.TopologyReport) {
+	sort.Slice(report.Subnets, func(i, j int) bool {
+		a, b := report.Subnets[i], report.Subnets[j]
+		if a.CIDR != b.CIDR {
+			return a.CIDR < b.CIDR
+		}
+		if a.VLAN != b.VLAN {
+			return a.VLAN < b.VLAN
+		}
+		return a.Source < b.Source
+	})
+	sort.Slice(report.VLANs, func(i, j int) bool {
+		a, b := report.VLANs[i], report.VLANs[j]
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Source < b.Source
+	})
+	sort.Slice(report.Gateways, func(i, j int) bool {
+		a, b := report.Gateways[i], report.Gateways[j]
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+		return a.MAC < b.MAC
+	})
+	sort.Slice(report.Flows, func(i, j int) bool {
+		a, b := report.Flows[i], report.Flows[j]
+		if a.SrcCIDR != b.SrcCIDR {
+			return a.SrcCIDR < b.SrcCIDR
+		}
+		if a.DstCIDR != b.DstCIDR {
+			return a.DstCIDR < b.DstCIDR
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.VLAN < b.VLAN
+	})
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,6 +22,18 @@ type Config struct {
 	// Control plane connection
 	ControlPlaneURL string `json:"control_plane_url"`
 	RegistrationKey string `json:"registration_key"`
+	ProxyURL        string `json:"proxy_url"`   // explicit egress proxy; empty falls back to HTTPS_PROXY/NO_PROXY
+	Compression     string `json:"compression"` // batch encoding: gzip, zstd or none
+
+	// CommandPollWait is how long each command long-poll is held open by the
+	// control plane; zero disables long-polling and commands arrive with heartbeats
+	CommandPollWait time.Duration `json:"command_poll_wait"`
+
+	// Retry configuration for control plane calls
+	RequestTimeout time.Duration `json:"request_timeout"`
+	MaxRetries     int           `json:"max_retries"`
+	RetryBaseDelay time.Duration `json:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `json:"retry_max_delay"`
 
 	// Reporting configuration
 	ReportingInterval time.Duration `json:"reporting_interval"`
@@ -31,14 +45,29 @@ type Config struct {
 	// Capture configuration
 	Capture CaptureConfig `json:"capture"`
 
+	// PortMap assigns the ports to capture to protocols (tls, ssh)
+	PortMap map[string][]int `json:"port_map"`
+
+	// ConfigVersion is the control plane configuration version in effect;
+	// zero until one has been received
+	ConfigVersion int `json:"config_version"`
+
 	// Network configuration
 	Network NetworkConfig `json:"network"`
 
+	// TopologyInterval is how often the sensor reports the subnets, VLANs,
+	// gateways and flows it observed; zero disables topology reports
+	TopologyInterval time.Duration `json:"topology_interval"`
+
 	// Security configuration
 	Security SecurityConfig `json:"security"`
 
 	// Features
 	Features map[string]bool `json:"features"`
+
+	// StatusListen is where the local status API listens: a Unix socket
+	// (unix:/path) or a loopback address (127.0.0.1:port); "off" disables it
+	StatusListen string `json:"status_listen"`
 }
 
 // StorageConfig represents storage configuration
@@ -73,6 +102,10 @@ type SecurityConfig struct {
 	ClientKey    string `json:"client_key"`
 	ServerCACert string `json:"server_ca_cert"`
 	UseTLS       bool   `json:"use_tls"`
+
+	// ReleasePublicKeys are the base64 Ed25519 keys release binaries must
+	// be signed with; upgrades are refused while none is configured
+	ReleasePublicKeys []string `json:"release_public_keys"`
 }
 
 // Load loads configuration from environment variables and defaults
@@ -87,7 +120,15 @@ func Load() *Config {
 		Profile:           getEnv("SENSOR_PROFILE", "datacenter_host"),
 		ControlPlaneURL:   getEnv("CONTROL_PLANE_URL", "http://localhost:8085"),
 		RegistrationKey:   getEnv("REGISTRATION_KEY", ""),
+		ProxyURL:          getEnv("CONTROL_PLANE_PROXY", ""),
+		Compression:       getEnv("COMPRESSION", "gzip"),
+		CommandPollWait:   getDurationEnv("COMMAND_POLL_WAIT", 30*time.Second),
+		RequestTimeout:    getDurationEnv("REQUEST_TIMEOUT", 30*time.Second),
+		MaxRetries:        getIntEnv("MAX_RETRIES", 5),
+		RetryBaseDelay:    getDurationEnv("RETRY_BASE_DELAY", 1*time.Second),
+		RetryMaxDelay:     getDurationEnv("RETRY_MAX_DELAY", 2*time.Minute),
 		ReportingInterval: getDurationEnv("REPORTING_INTERVAL", 30*time.Second),
+		TopologyInterval:  getDurationEnv("TOPOLOGY_INTERVAL", 15*time.Minute),
 		BatchSize:         getIntEnv("BATCH_SIZE", 100),
 		Storage: StorageConfig{
 			MaxStorageSize: getInt64Env("MAX_STORAGE_SIZE", 100*1024*1024), // 100MB
@@ -104,6 +145,7 @@ func Load() *Config {
 			TimeoutSeconds:   getIntEnv("TIMEOUT_SECONDS", 30),
 			BufferSize:       getIntEnv("BUFFER_SIZE", 1024*1024), // 1MB
 		},
+		PortMap: DefaultPortMap(),
 		Network: NetworkConfig{
 			Interfaces: getStringSliceEnv("NETWORK_INTERFACES", []string{"eth0"}),
 			VLANs:      getStringSliceEnv("VLANS", []string{}),
@@ -114,6 +156,8 @@ func Load() *Config {
 			ClientKey:    getEnv("CLIENT_KEY", ""),
 			ServerCACert: getEnv("SERVER_CA_CERT", ""),
 			UseTLS:       getBoolEnv("USE_TLS", false),
+
+			ReleasePublicKeys: getListEnv("RELEASE_PUBLIC_KEYS"),
 		},
 		Features: map[string]bool{
 			"tls_analysis":         getBoolEnv("FEATURE_TLS_ANALYSIS", true),
@@ -124,10 +168,20 @@ func Load() *Config {
 			"air_gapped_export":    getBoolEnv("FEATURE_AIR_GAPPED_EXPORT", false),
 		},
 	}
+	cfg.StatusListen = getEnv("STATUS_LISTEN", "unix:"+filepath.Join(cfg.Storage.DataPath, "status.sock"))
 
 	return cfg
 }
 
+// DefaultPortMap returns the ports captured until the control plane sends a
+// port map
+func DefaultPortMap() map[string][]int {
+	return map[string][]int{
+		"tls": {443, 465, 587, 993, 995},
+		"ssh": {22},
+	}
+}
+
 // Helper functions for environment variable parsing
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -180,3 +234,14 @@ func getStringSliceEnv(key string, defaultValue []string) []string {
 	}
 	return defaultValue
 }
+
+// getListEnv parses a comma-separated list, dropping empty entries
+func getListEnv(key string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Command result statuses reported to the control plane
+const (
+	CommandStatusAcknowledged = "acknowledged"
+	CommandStatusSucceeded    = "succeeded"
+	CommandStatusFailed       = "failed"
+)
+
+// CommandResult reports that a command was received (acknowledged) or
+// executed (succeeded or failed, with an optional result)
+type CommandResult struct {
+	CommandID string                 `json:"command_id"`
+	SensorID  string                 `json:"sensor_id"`
+	Status    string                 `json:"status"`
+	Result    map[string]interface{} `json:"result,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
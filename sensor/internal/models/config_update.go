@@ -0,0 +1,10 @@
+package models
+
+// ConfigUpdate is a versioned configuration issued by the control plane at
+// registration and pushed with update_config commands. PortMap assigns the
+// ports to capture to protocols (tls, ssh).
+type ConfigUpdate struct {
+	SensorConfig
+	PortMap       map[string][]int `json:"port_map"`
+	ConfigVersion int              `json:"config_version"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Sources of topology observations
+const (
+	TopologySourceInterface  = "interface"  // address of a capture interface
+	TopologySourceObserved   = "observed"   // seen in captured traffic
+	TopologySourceConfigured = "configured" // from the sensor configuration
+	TopologySourceRoute      = "route"      // from the host routing table
+)
+
+// TopologyReport describes the networks a sensor sees during one reporting
+// window: subnets, VLAN tags, gateways and the flows between subnets.
+// Counters cover the window only.
+type TopologyReport struct {
+	SensorID    string            `json:"sensor_id"`
+	WindowStart time.Time         `json:"window_start"`
+	WindowEnd   time.Time         `json:"window_end"`
+	Subnets     []ObservedSubnet  `json:"subnets"`
+	VLANs       []ObservedVLAN    `json:"vlans"`
+	Gateways    []ObservedGateway `json:"gateways"`
+	Flows       []ObservedFlow    `json:"flows"`
+}
+
+// ObservedSubnet is a subnet on a capture interface or seen in traffic.
+// Traffic is aggregated to /24 (IPv4) and /64 (IPv6) subnets.
+type ObservedSubnet struct {
+	CIDR      string `json:"cidr"`
+	VLAN      int    `json:"vlan,omitempty"` // 802.1Q tag, 0 when untagged
+	Source    string `json:"source"`
+	Interface string `json:"interface,omitempty"`
+	Hosts     int    `json:"hosts"` // distinct addresses seen
+	Packets   int64  `json:"packets"`
+}
+
+// ObservedVLAN is an 802.1Q tag seen in traffic or configured
+type ObservedVLAN struct {
+	ID      int    `json:"id"`
+	Source  string `json:"source"`
+	Packets int64  `json:"packets"`
+}
+
+// ObservedGateway is a router next to the sensor. Gateways observed in
+// traffic are known by MAC address only: a MAC sending packets from several
+// subnets forwards them.
+type ObservedGateway struct {
+	IP        string `json:"ip,omitempty"`
+	MAC       string `json:"mac,omitempty"`
+	VLAN      int    `json:"vlan,omitempty"`
+	Source    string `json:"source"`
+	Interface string `json:"interface,omitempty"`
+	Subnets   int    `json:"subnets,omitempty"` // source subnets forwarded
+}
+
+// ObservedFlow is encrypted traffic from one subnet to a service port in
+// another
+type ObservedFlow struct {
+	SrcCIDR  string `json:"src_cidr"`
+	DstCIDR  string `json:"dst_cidr"`
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+	VLAN     int    `json:"vlan,omitempty"`
+	Packets  int64  `json:"packets"`
+}
@@ -0,0 +1,108 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Client talks to the status API of a sensor running on the same host
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client for the status API listening on addr
+func NewClient(addr string) (*Client, error) {
+	network, address, err := parseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := &http.Transport{
+		// Requests are made to a fixed host; the dialer picks the endpoint
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+		Proxy: nil,
+	}
+	return &Client{http: &http.Client{Transport: transport}}, nil
+}
+
+// Status returns the state of the sensor. A sensor that is not healthy is
+// not an error; check Report.Healthy.
+func (c *Client) Status(ctx context.Context) (*Report, error) {
+	var report Report
+	if err := c.do(ctx, http.MethodGet, "/v1/status", &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Config returns the configuration in effect, with secrets removed, as JSON
+func (c *Client) Config(ctx context.Context) (json.RawMessage, error) {
+	var config json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/v1/config", &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Discoveries returns up to limit recent discoveries with a sequence number
+// above after, oldest first
+func (c *Client) Discoveries(ctx context.Context, after uint64, limit int) ([]Discovery, error) {
+	query := url.Values{}
+	query.Set("after", strconv.FormatUint(after, 10))
+	query.Set("limit", strconv.Itoa(limit))
+
+	var response struct {
+		Discoveries []Discovery `json:"discoveries"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/discoveries?"+query.Encode(), &response); err != nil {
+		return nil, err
+	}
+	return response.Discoveries, nil
+}
+
+// Flush makes the sensor upload its discovery backlog and send a heartbeat
+// now
+func (c *Client) Flush(ctx context.Context) (*FlushResult, error) {
+	var result FlushResult
+	if err := c.do(ctx, http.MethodPost, "/v1/flush", &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://sensor"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("sensor is not reachable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("sensor returned %d: %s", resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("sensor returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,64 @@
+package status
+
+import (
+	"sync"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/models"
+)
+
+// Discovery is a recent discovery with its sequence number, which callers
+// pass back to receive only newer ones
+type Discovery struct {
+	Seq       uint64                  `json:"seq"`
+	Discovery *models.CryptoDiscovery `json:"discovery"`
+}
+
+// Recent keeps the last discoveries of a sensor in memory, independent of
+// whether they were uploaded yet
+type Recent struct {
+	mu    sync.Mutex
+	items []Discovery // ring buffer, next is the oldest once full
+	next  int
+	seq   uint64
+}
+
+// NewRecent creates a buffer holding the last size discoveries
+func NewRecent(size int) *Recent {
+	return &Recent{items: make([]Discovery, 0, size)}
+}
+
+// Add records a discovery
+func (r *Recent) Add(discovery *models.CryptoDiscovery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	item := Discovery{Seq: r.seq, Discovery: discovery}
+	if len(r.items) < cap(r.items) {
+		r.items = append(r.items, item)
+		return
+	}
+	r.items[r.next] = item
+	r.next = (r.next + 1) % len(r.items)
+}
+
+// After returns, oldest first, the newest limit discoveries with a sequence
+// number above after
+func (r *Recent) After(after uint64, limit int) []Discovery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]Discovery, 0, min(limit, len(r.items)))
+	// Walk back from the newest so only the last limit are kept
+	for i := 0; i < len(r.items) && len(result) < limit; i++ {
+		item := r.items[(r.next+len(r.items)-1-i)%len(r.items)]
+		if item.Seq <= after {
+			break
+		}
+		result = append(result, item)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
@@ -0,0 +1,292 @@
+// Package status serves the local status API of a running sensor: its
+// state, configuration, capture counters, upload backlog and recent
+// discoveries. The API only listens on a Unix socket or a loopback address,
+// so it is reachable from the sensor's host and never from the network. The
+// sensor's CLI subcommands and service health checks use it.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/democorp/crypto-inventory/sensor/internal/capture"
+	"github.com/democorp/crypto-inventory/sensor/internal/config"
+)
+
+const (
+	// unhealthyHeartbeats is how many reporting intervals may pass without
+	// a successful heartbeat before the sensor reports itself unhealthy
+	unhealthyHeartbeats = 3
+	// maxDiscoveryLimit bounds the discoveries returned by one call
+	maxDiscoveryLimit = 1000
+	// flushTimeout bounds a flush requested through the API
+	flushTimeout = 2 * time.Minute
+)
+
+// Report is the state of a running sensor
+type Report struct {
+	SensorID          string                   `json:"sensor_id"`
+	Name              string                   `json:"name"`
+	Version           string                   `json:"version"`
+	Platform          string                   `json:"platform"`
+	Profile           string                   `json:"profile"`
+	StartedAt         time.Time                `json:"started_at"`
+	UptimeSeconds     int64                    `json:"uptime_seconds"`
+	Registered        bool                     `json:"registered"`
+	ConfigVersion     int                      `json:"config_version"`
+	ReportingInterval int64                    `json:"reporting_interval_seconds"`
+	Capturing         bool                     `json:"capturing"`
+	Interfaces        []capture.InterfaceStats `json:"interfaces"`
+	Capture           capture.Stats            `json:"capture"`
+	Backlog           int                      `json:"discovery_backlog"` // discoveries awaiting upload
+	StorageUsedBytes  int64                    `json:"storage_used_bytes"`
+	LastHeartbeat     *time.Time               `json:"last_heartbeat,omitempty"` // last successful one
+	LastError         string                   `json:"last_error,omitempty"`     // of the last reporting cycle
+	Healthy           bool                     `json:"healthy"`
+	Problems          []string                 `json:"problems,omitempty"`
+}
+
+// evaluate decides whether the sensor is healthy: it is capturing, has an
+// identity and reached the control plane recently
+func (r *Report) evaluate(now time.Time) {
+	r.Problems = nil
+	if !r.Capturing {
+		r.Problems = append(r.Problems, "packet capture is not running")
+	}
+	if !r.Registered {
+		r.Problems = append(r.Problems, "sensor is not registered")
+	}
+
+	window := time.Duration(unhealthyHeartbeats*r.ReportingInterval) * time.Second
+	last := r.StartedAt
+	if r.LastHeartbeat != nil {
+		last = *r.LastHeartbeat
+	}
+	if window > 0 && now.Sub(last) > window {
+		r.Problems = append(r.Problems, fmt.Sprintf("no successful heartbeat for %s", now.Sub(last).Round(time.Second)))
+	}
+	r.Healthy = len(r.Problems) == 0
+}
+
+// FlushResult is the outcome of an immediate reporting cycle
+type FlushResult struct {
+	Submitted int    `json:"submitted"` // discoveries uploaded
+	Backlog   int    `json:"discovery_backlog"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Provider exposes the state of the running sensor to the status API
+type Provider interface {
+	// Status returns the current state; health is evaluated by the server
+	Status() *Report
+	// Config returns the configuration in effect with secrets removed
+	Config() *config.Config
+	// Discoveries returns up to limit recent discoveries after a sequence
+	// number
+	Discoveries(after uint64, limit int) []Discovery
+	// Flush uploads the discovery backlog and sends a heartbeat now
+	Flush(ctx context.Context) (*FlushResult, error)
+}
+
+// Server serves the status API
+type Server struct {
+	provider Provider
+	server   *http.Server
+}
+
+// NewServer creates a status API server for a sensor
+func NewServer(provider Provider) *Server {
+	s := &Server{provider: provider}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/v1/status", s.handleStatus)
+	mux.HandleFunc("/v1/config", s.handleConfig)
+	mux.HandleFunc("/v1/discoveries", s.handleDiscoveries)
+	mux.HandleFunc("/v1/flush", s.handleFlush)
+
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start listens on addr and serves in the background until Shutdown
+func (s *Server) Start(addr string) error {
+	listener, err := Listen(addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("❌ Status API stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// Listen opens the status API listener: a Unix socket only its owner can
+// connect to, or a TCP listener on a loopback address
+func Listen(addr string) (net.Listener, error) {
+	network, address, err := parseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "tcp" {
+		return net.Listen(network, address)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(address), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create status socket directory: %v", err)
+	}
+	// A socket left behind by a previous run would fail the bind
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale status socket: %v", err)
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(address, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict status socket: %v", err)
+	}
+	return listener, nil
+}
+
+// parseAddr splits a status address into network and address. TCP
+// addresses must be loopback addresses.
+func parseAddr(addr string) (string, string, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if path == "" {
+			return "", "", errors.New("status socket path is empty")
+		}
+		return "unix", path, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid status address %q: %v", addr, err)
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return "", "", fmt.Errorf("status address %q is not a loopback address", addr)
+		}
+	}
+	return "tcp", addr, nil
+}
+
+func (s *Server) report() *Report {
+	report := s.provider.Status()
+	report.evaluate(time.Now())
+	return report
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	report := s.report()
+	code := http.StatusOK
+	if !report.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]interface{}{
+		"healthy":  report.Healthy,
+		"problems": report.Problems,
+	})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.report())
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.provider.Config())
+}
+
+func (s *Server) handleDiscoveries(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	var after uint64
+	if value := query.Get("after"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "after must be a sequence number")
+			return
+		}
+		after = parsed
+	}
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxDiscoveryLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxDiscoveryLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"discoveries": s.provider.Discoveries(after, limit),
+	})
+}
+
+func (s *Server) handleFlush(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), flushTimeout)
+	defer cancel()
+	result, err := s.provider.Flush(ctx)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(body)
+}
@@ -187,6 +187,27 @@ func (es *EncryptedStorage) Cleanup() error {
 	return nil
 }
 
+// Usage returns the size in bytes of the discovery files on disk
+func (es *EncryptedStorage) Usage() (int64, error) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	files, err := filepath.Glob(filepath.Join(es.config.Storage.DataPath, "discoveries_*.enc"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list files: %v", err)
+	}
+
+	var total int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
 // Close closes the storage
 func (es *EncryptedStorage) Close() error {
 	es.mu.Lock()
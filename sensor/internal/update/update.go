@@ -0,0 +1,300 @@
+// Package update installs new sensor binaries pushed by the control plane.
+// Release binaries are signed offline with Ed25519 release keys whose public
+// halves are pinned in the sensor configuration, so neither the control
+// plane nor anything on the network path can substitute a binary. A
+// verified binary is first run with -version, then swapped in atomically
+// next to the running executable, which is kept as <binary>.previous. A
+// marker file records the upgrade in progress: the new binary confirms it
+// once it passes its health check, and otherwise the previous binary is
+// restored.
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// MaxBinarySize caps the size of a downloaded release binary
+const MaxBinarySize = 256 << 20
+
+// signatureContext prefixes every signed release message, so a release
+// signature cannot be mistaken for a signature over anything else
+const signatureContext = "crypto-sensor-release:v1"
+
+// Platform returns the platform of the running binary as used in the
+// release catalog, e.g. linux-amd64
+func Platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// Artifact describes the release binary for one platform
+type Artifact struct {
+	Version   string `json:"version"`
+	Platform  string `json:"platform"`
+	SHA256    string `json:"sha256"` // hex digest of the binary
+	Size      int64  `json:"size"`
+	Signature string `json:"signature"` // base64 Ed25519 signature of SignedMessage
+}
+
+// SignedMessage returns the message release keys sign for a binary. It
+// binds the digest to the version and platform, so a validly signed binary
+// cannot be offered as another version or for another platform.
+func SignedMessage(version, platform, digest string) []byte {
+	return []byte(signatureContext + "\n" + version + "\n" + platform + "\n" + strings.ToLower(digest))
+}
+
+// ParsePublicKeys decodes base64 Ed25519 public keys
+func ParsePublicKeys(encoded []string) ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(encoded))
+	for _, value := range encoded {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid release public key %q", value)
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return keys, nil
+}
+
+// VerifySignature checks that one of the release keys signed the artifact
+func (a *Artifact) VerifySignature(keys []ed25519.PublicKey) error {
+	if len(keys) == 0 {
+		return errors.New("no release public keys configured")
+	}
+	signature, err := base64.StdEncoding.DecodeString(a.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return errors.New("invalid release signature encoding")
+	}
+	message := SignedMessage(a.Version, a.Platform, a.SHA256)
+	for _, key := range keys {
+		if ed25519.Verify(key, message, signature) {
+			return nil
+		}
+	}
+	return errors.New("release signature does not match any release public key")
+}
+
+// Verify checks a downloaded binary against its artifact: size, digest and
+// signature
+func Verify(path string, artifact *Artifact, keys []ed25519.PublicKey) error {
+	if err := artifact.VerifySignature(keys); err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return fmt.Errorf("failed to hash binary: %v", err)
+	}
+	if size != artifact.Size {
+		return fmt.Errorf("binary is %d bytes, expected %d", size, artifact.Size)
+	}
+	if digest := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(digest, artifact.SHA256) {
+		return fmt.Errorf("binary digest %s does not match %s", digest, artifact.SHA256)
+	}
+	return nil
+}
+
+// CheckBinary runs a binary with -version and checks that it starts and
+// reports the expected version
+func CheckBinary(ctx context.Context, path, version string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "-version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("new binary failed to run: %v", err)
+	}
+	reported := reportedVersion(string(output))
+	if reported == "" {
+		return fmt.Errorf("new binary does not report a version")
+	}
+	if reported != version {
+		return fmt.Errorf("new binary reports version %s, expected %s", reported, version)
+	}
+	return nil
+}
+
+// reportedVersion returns the version in -version output, the first word
+// of the form v<digit>... with the v removed
+func reportedVersion(output string) string {
+	for _, word := range strings.Fields(output) {
+		if len(word) > 1 && word[0] == 'v' && word[1] >= '0' && word[1] <= '9' {
+			return word[1:]
+		}
+	}
+	return ""
+}
+
+// Marker records an upgrade in progress across the restart into the new
+// binary
+type Marker struct {
+	CommandID   string    `json:"command_id"`
+	FromVersion string    `json:"from_version"`
+	ToVersion   string    `json:"to_version"`
+	Starts      int       `json:"starts"` // launches of the new binary so far
+	RolledBack  bool      `json:"rolled_back"`
+	Error       string    `json:"error,omitempty"` // why the upgrade was rolled back
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// Installer swaps the running executable. All files it writes live next to
+// the executable, so the swap is a rename within one filesystem.
+type Installer struct {
+	Executable string
+}
+
+// NewInstaller creates an installer for the running executable
+func NewInstaller() (*Installer, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate executable: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	return &Installer{Executable: executable}, nil
+}
+
+// StagingPath is where a new binary is downloaded to
+func (i *Installer) StagingPath() string {
+	return i.Executable + ".new"
+}
+
+// PreviousPath is where the replaced binary is kept for rollback
+func (i *Installer) PreviousPath() string {
+	return i.Executable + ".previous"
+}
+
+func (i *Installer) markerPath() string {
+	return i.Executable + ".upgrade.json"
+}
+
+// Swap keeps a copy of the running binary as the previous one and renames
+// the staged binary over the executable. The executable is replaced in a
+// single rename, so it is never missing or half-written.
+func (i *Installer) Swap() error {
+	if err := copyFile(i.Executable, i.PreviousPath()); err != nil {
+		return fmt.Errorf("failed to keep previous binary: %v", err)
+	}
+	if err := os.Rename(i.StagingPath(), i.Executable); err != nil {
+		return fmt.Errorf("failed to install new binary: %v", err)
+	}
+	return syncDir(filepath.Dir(i.Executable))
+}
+
+// Rollback restores the previous binary
+func (i *Installer) Rollback() error {
+	if err := os.Rename(i.PreviousPath(), i.Executable); err != nil {
+		return fmt.Errorf("failed to restore previous binary: %v", err)
+	}
+	return syncDir(filepath.Dir(i.Executable))
+}
+
+// LoadMarker returns the upgrade in progress, or nil when there is none
+func (i *Installer) LoadMarker() (*Marker, error) {
+	data, err := os.ReadFile(i.markerPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var marker Marker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return nil, fmt.Errorf("invalid upgrade marker: %v", err)
+	}
+	return &marker, nil
+}
+
+// SaveMarker atomically writes the upgrade marker
+func (i *Installer) SaveMarker(marker *Marker) error {
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+	tmp := i.markerPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, i.markerPath())
+}
+
+// RemoveMarker clears the upgrade marker
+func (i *Installer) RemoveMarker() error {
+	if err := os.Remove(i.markerPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// copyFile copies src to dst through a temporary file, keeping the mode
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// syncDir flushes a directory so renames within it survive a crash
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil && runtime.GOOS != "windows" {
+		return err
+	}
+	return nil
+}
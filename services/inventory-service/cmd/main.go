@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"inventory-service/internal/config"
 	"inventory-service/internal/database"
 	"inventory-service/internal/handlers"
@@ -25,10 +26,32 @@ func main() {
 	defer db.Close()
 
 	// Initialize services
-	assetService := services.NewAssetService(db)
+	riskService := services.NewRiskService(db)
+	assetService := services.NewAssetService(db, riskService)
+	permissionService := services.NewPermissionService(db)
+	certificateService := services.NewCertificateService(db)
+	cbomService := services.NewCBOMService(db, assetService)
+	scanImportService := services.NewScanImportService(db, assetService)
+	historyService := services.NewHistoryService(db)
+	snapshotService := services.NewSnapshotService(db)
+	searchService := services.NewSearchService(db, assetService)
+
+	// Keep stored risk scores current with rule changes and new discoveries
+	go riskService.Run(context.Background(), cfg.Risk.RecomputeInterval)
+
+	// Import uploaded scan reports in the background
+	go scanImportService.Run(context.Background())
 
 	// Initialize handlers
 	assetHandler := handlers.NewAssetHandler(assetService)
+	certificateHandler := handlers.NewCertificateHandler(certificateService)
+	riskHandler := handlers.NewRiskHandler(riskService)
+	algorithmHandler := handlers.NewAlgorithmHandler()
+	cbomHandler := handlers.NewCBOMHandler(cbomService)
+	scanImportHandler := handlers.NewScanImportHandler(scanImportService)
+	historyHandler := handlers.NewHistoryHandler(historyService)
+	snapshotHandler := handlers.NewSnapshotHandler(snapshotService)
+	searchHandler := handlers.NewSearchHandler(searchService)
 
 	// Setup Gin router
 	r := gin.Default()
@@ -37,8 +60,8 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:3001", "http://localhost:3002"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "ETag", "Location"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -55,9 +78,75 @@ func main() {
 		api.GET("/assets/search", assetHandler.SearchAssets)
 		api.GET("/assets/:id", assetHandler.GetAssetByID)
 		api.GET("/assets/:id/crypto", assetHandler.GetAssetCrypto)
+		api.GET("/assets/:id/crypto/:crypto_id", assetHandler.GetCryptoImplementation)
+		api.GET("/assets/:id/crypto/:crypto_id/risk", riskHandler.GetCryptoRisk)
+		api.GET("/assets/:id/history", historyHandler.GetAssetHistory)
+
+		// Asset write endpoints; PUT, PATCH and DELETE require If-Match
+		create := handlers.RequirePermission(permissionService, handlers.PermissionAssetsCreate)
+		update := handlers.RequirePermission(permissionService, handlers.PermissionAssetsUpdate)
+		remove := handlers.RequirePermission(permissionService, handlers.PermissionAssetsDelete)
+		api.POST("/assets", create, assetHandler.CreateAsset)
+		api.POST("/assets/bulk", create, update, assetHandler.BulkUpsertAssets)
+		api.PUT("/assets/:id", update, assetHandler.ReplaceAsset)
+		api.PATCH("/assets/:id", update, assetHandler.PatchAsset)
+		api.DELETE("/assets/:id", remove, assetHandler.DeleteAsset)
+		api.POST("/assets/:id/crypto", update, assetHandler.CreateCryptoImplementation)
+		api.PUT("/assets/:id/crypto/:crypto_id", update, assetHandler.ReplaceCryptoImplementation)
+		api.PATCH("/assets/:id/crypto/:crypto_id", update, assetHandler.PatchCryptoImplementation)
+		api.DELETE("/assets/:id/crypto/:crypto_id", update, assetHandler.DeleteCryptoImplementation)
 
-		// Risk endpoints
+		// Certificate endpoints
+		api.GET("/certificates", certificateHandler.GetCertificates)
+		api.GET("/certificates/summary", certificateHandler.GetCertificateSummary)
+		api.GET("/certificates/reminders", certificateHandler.GetCertificateReminders)
+		api.GET("/certificates/:id", certificateHandler.GetCertificate)
+		api.GET("/certificates/:id/chain", certificateHandler.GetCertificateChain)
+		api.GET("/certificates/:id/history", certificateHandler.GetCertificateHistory)
+
+		// Risk endpoints; rule changes require settings.update
+		settings := handlers.RequirePermission(permissionService, handlers.PermissionSettingsUpdate)
 		api.GET("/risk/summary", assetHandler.GetRiskSummary)
+		api.GET("/risk/rules", riskHandler.GetRiskRules)
+		api.PUT("/risk/rules", settings, riskHandler.PutRiskRules)
+		api.GET("/risk/rules/versions", riskHandler.GetRiskRuleVersions)
+		api.GET("/risk/rules/versions/:version", riskHandler.GetRiskRuleVersion)
+		api.POST("/risk/rules/versions/:version/restore", settings, riskHandler.RestoreRiskRuleVersion)
+
+		// Algorithm knowledge base endpoints
+		api.GET("/algorithms", algorithmHandler.GetAlgorithms)
+		api.GET("/algorithms/lookup", algorithmHandler.LookupAlgorithm)
+		api.POST("/algorithms/resolve", algorithmHandler.ResolveAlgorithms)
+
+		// CBOM endpoints; imports create and update assets
+		api.GET("/cbom", cbomHandler.ExportCBOM)
+		api.POST("/cbom/import", create, update, cbomHandler.ImportCBOM)
+
+		// Scan import endpoints; imports create and update assets
+		api.GET("/imports", scanImportHandler.GetScanImports)
+		api.GET("/imports/:id", scanImportHandler.GetScanImport)
+		api.POST("/imports", create, update, scanImportHandler.CreateScanImport)
+
+		// Change history endpoints
+		api.GET("/changes", historyHandler.GetChanges)
+
+		// Snapshot endpoints; snapshots are reports
+		createReport := handlers.RequirePermission(permissionService, handlers.PermissionReportsCreate)
+		deleteReport := handlers.RequirePermission(permissionService, handlers.PermissionReportsDelete)
+		api.GET("/snapshots", snapshotHandler.GetSnapshots)
+		api.GET("/snapshots/diff", snapshotHandler.GetDiff)
+		api.GET("/snapshots/:id", snapshotHandler.GetSnapshot)
+		api.POST("/snapshots", createReport, snapshotHandler.CreateSnapshot)
+		api.DELETE("/snapshots/:id", deleteReport, snapshotHandler.DeleteSnapshot)
+
+		// Search endpoints; saved searches are the user's own
+		api.GET("/search", searchHandler.Search)
+		api.GET("/search/fields", searchHandler.GetSearchFields)
+		api.GET("/searches", searchHandler.GetSavedSearches)
+		api.GET("/searches/:id", searchHandler.GetSavedSearch)
+		api.POST("/searches", searchHandler.CreateSavedSearch)
+		api.PUT("/searches/:id", searchHandler.ReplaceSavedSearch)
+		api.DELETE("/searches/:id", searchHandler.DeleteSavedSearch)
 	}
 
 	// Start server
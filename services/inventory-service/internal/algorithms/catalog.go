@@ -0,0 +1,294 @@
+// Package algorithms is the platform's knowledge base of cryptographic
+// algorithms: IANA TLS cipher suites, named groups and signature schemes,
+// SSH key exchange, host key, cipher and MAC names, and hash functions.
+// Each entry is decomposed into its key exchange, authentication, bulk
+// cipher, mode, MAC and PRF and carries its security strength and flags,
+// so inventory, risk scoring and reporting all judge an algorithm the same
+// way whichever spelling a sensor or scanner reported.
+package algorithms
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Algorithm kinds
+const (
+	KindTLSCipherSuite  = "tls_cipher_suite"
+	KindNamedGroup      = "named_group"
+	KindSignatureScheme = "signature_scheme"
+	KindSSHKex          = "ssh_kex"
+	KindSSHHostKey      = "ssh_host_key"
+	KindSSHCipher       = "ssh_cipher"
+	KindSSHMAC          = "ssh_mac"
+	KindHash            = "hash"
+)
+
+// Kinds lists the algorithm kinds in catalog order
+var Kinds = []string{
+	KindTLSCipherSuite, KindNamedGroup, KindSignatureScheme,
+	KindSSHKex, KindSSHHostKey, KindSSHCipher, KindSSHMAC, KindHash,
+}
+
+// Entry is a normalized algorithm. StrengthBits is the security strength
+// of the algorithm as negotiated: for cipher suites the effective strength
+// of the bulk cipher (0 when it gives no confidentiality, as with NULL,
+// RC4 and anonymous suites), and for RSA and finite field algorithms, whose
+// strength depends on a key or group size the name does not give, the
+// strength of the smallest approved size.
+type Entry struct {
+	Kind           string   `json:"kind"`
+	Name           string   `json:"name"`
+	Codepoint      string   `json:"codepoint,omitempty"` // 0x13,0x01 for cipher suites, 0x001D for groups and schemes
+	Aliases        []string `json:"aliases,omitempty"`
+	KeyExchange    string   `json:"key_exchange,omitempty"`
+	Authentication string   `json:"authentication,omitempty"`
+	BulkCipher     string   `json:"bulk_cipher,omitempty"`
+	Mode           string   `json:"mode,omitempty"`
+	MAC            string   `json:"mac,omitempty"`
+	PRF            string   `json:"prf,omitempty"`
+	Hash           string   `json:"hash,omitempty"`
+	StrengthBits   int      `json:"strength_bits"`
+
+	Deprecated        bool `json:"deprecated"`
+	FIPSApproved      bool `json:"fips_approved"`
+	CNSA2             bool `json:"cnsa2_compliant"`
+	QuantumVulnerable bool `json:"quantum_vulnerable"`
+
+	// Registered is false for cipher suites decomposed from a well-formed
+	// name the catalog does not list
+	Registered bool `json:"registered"`
+}
+
+// Filters select catalog entries; nil flags match any entry
+type Filters struct {
+	Kind              string
+	Query             string // part of the name or an alias
+	Deprecated        *bool
+	FIPSApproved      *bool
+	CNSA2             *bool
+	QuantumVulnerable *bool
+}
+
+var (
+	catalog     []*Entry
+	byName      map[string][]*Entry
+	byCodepoint map[string][]*Entry
+	kindOrders  map[string]int
+)
+
+func init() {
+	catalog = append(catalog, cipherSuites()...)
+	catalog = append(catalog, namedGroups...)
+	catalog = append(catalog, signatureSchemes...)
+	catalog = append(catalog, sshAlgorithms...)
+	catalog = append(catalog, hashes...)
+
+	kindOrders = make(map[string]int, len(Kinds))
+	for i, kind := range Kinds {
+		kindOrders[kind] = i
+	}
+	byName = make(map[string][]*Entry)
+	byCodepoint = make(map[string][]*Entry)
+	for _, entry := range catalog {
+		entry.Registered = true
+		for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+			key := normalize(name)
+			if !contains(byName[key], entry) {
+				byName[key] = append(byName[key], entry)
+			}
+		}
+		if codepoint, ok := parseCodepoint(entry.Codepoint); ok {
+			byCodepoint[codepoint] = append(byCodepoint[codepoint], entry)
+		}
+	}
+}
+
+// List returns the catalog entries matching the filters, by kind and name
+func List(filters Filters) []Entry {
+	query := normalize(filters.Query)
+	entries := []Entry{}
+	for _, entry := range catalog {
+		switch {
+		case filters.Kind != "" && entry.Kind != filters.Kind,
+			filters.Deprecated != nil && entry.Deprecated != *filters.Deprecated,
+			filters.FIPSApproved != nil && entry.FIPSApproved != *filters.FIPSApproved,
+			filters.CNSA2 != nil && entry.CNSA2 != *filters.CNSA2,
+			filters.QuantumVulnerable != nil && entry.QuantumVulnerable != *filters.QuantumVulnerable,
+			query != "" && !entry.mentions(query):
+			continue
+		}
+		entries = append(entries, entry.clone())
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return kindOrders[entries[i].Kind] < kindOrders[entries[j].Kind]
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// Lookup resolves an algorithm name, alias or codepoint, ignoring case and
+// the separators that vary between tools, so ECDHE-RSA-AES128-GCM-SHA256,
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 and 0xC0,0x2F are the same suite.
+// Kinds restrict the lookup; without them a name matching several kinds
+// resolves to the first in catalog order. Well-formed TLS cipher suite
+// names the catalog does not list are decomposed from the name.
+func Lookup(name string, kinds ...string) (Entry, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Entry{}, false
+	}
+
+	var candidates []*Entry
+	if codepoint, ok := parseCodepoint(name); ok {
+		candidates = byCodepoint[codepoint]
+	} else {
+		candidates = byName[normalize(name)]
+		// Java and old OpenSSL releases spell TLS suites with SSL_
+		if len(candidates) == 0 && hasPrefixFold(name, "SSL_") {
+			candidates = byName[normalize("TLS_"+name[4:])]
+		}
+	}
+	if entry, ok := first(candidates, kinds); ok {
+		return entry.clone(), true
+	}
+
+	if len(kinds) == 0 || containsKind(kinds, KindTLSCipherSuite) {
+		if hasPrefixFold(name, "SSL_") {
+			name = "TLS_" + name[4:]
+		}
+		if entry, ok := describeSuite(strings.ToUpper(name)); ok {
+			return *entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Resolve looks up several names at once, keyed by the name as given;
+// names that do not resolve map to nil
+func Resolve(names []string, kinds ...string) map[string]*Entry {
+	resolved := make(map[string]*Entry, len(names))
+	for _, name := range names {
+		if _, done := resolved[name]; done {
+			continue
+		}
+		if entry, ok := Lookup(name, kinds...); ok {
+			resolved[name] = &entry
+		} else {
+			resolved[name] = nil
+		}
+	}
+	return resolved
+}
+
+// ValidKind reports whether kind is an algorithm kind
+func ValidKind(kind string) bool {
+	_, ok := kindOrders[kind]
+	return ok
+}
+
+func first(candidates []*Entry, kinds []string) (*Entry, bool) {
+	var best *Entry
+	for _, entry := range candidates {
+		if len(kinds) > 0 && !containsKind(kinds, entry.Kind) {
+			continue
+		}
+		if best == nil || kindOrders[entry.Kind] < kindOrders[best.Kind] {
+			best = entry
+		}
+	}
+	return best, best != nil
+}
+
+func (e *Entry) mentions(query string) bool {
+	if strings.Contains(normalize(e.Name), query) {
+		return true
+	}
+	for _, alias := range e.Aliases {
+		if strings.Contains(normalize(alias), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entry) clone() Entry {
+	entry := *e
+	entry.Aliases = append([]string(nil), e.Aliases...)
+	return entry
+}
+
+// normalize lowercases a name and drops '-', '_', ' ' and '/', which tools
+// use interchangeably
+func normalize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', ' ', '/':
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, strings.TrimSpace(name))
+}
+
+// parseCodepoint reads a hexadecimal codepoint, 0x1301 or 0x13,0x01, and
+// returns it as four lowercase digits
+func parseCodepoint(text string) (string, bool) {
+	text = strings.ToLower(strings.ReplaceAll(text, " ", ""))
+	var value uint64
+	if parts := strings.Split(text, ","); len(parts) == 2 {
+		high, okHigh := hexByte(parts[0])
+		low, okLow := hexByte(parts[1])
+		if !okHigh || !okLow {
+			return "", false
+		}
+		value = uint64(high)<<8 | uint64(low)
+	} else {
+		if !strings.HasPrefix(text, "0x") || len(text) < 3 || len(text) > 6 {
+			return "", false
+		}
+		parsed, err := strconv.ParseUint(text[2:], 16, 16)
+		if err != nil {
+			return "", false
+		}
+		value = parsed
+	}
+	return formatCodepoint(uint16(value)), true
+}
+
+func hexByte(text string) (uint8, bool) {
+	if !strings.HasPrefix(text, "0x") || len(text) < 3 || len(text) > 4 {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(text[2:], 16, 8)
+	return uint8(value), err == nil
+}
+
+func formatCodepoint(value uint16) string {
+	return "0x" + strconv.FormatUint(uint64(value)|0x10000, 16)[1:]
+}
+
+func hasPrefixFold(text, prefix string) bool {
+	return len(text) >= len(prefix) && strings.EqualFold(text[:len(prefix)], prefix)
+}
+
+func contains(entries []*Entry, entry *Entry) bool {
+	for _, candidate := range entries {
+		if candidate == entry {
+			return true
+		}
+	}
+	return false
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, candidate := range kinds {
+		if candidate == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package algorithms
+
+// namedGroups are the TLS supported groups in use, with the curve names of
+// NIST, SECG and OpenSSL as aliases
+var namedGroups = []*Entry{
+	{Kind: KindNamedGroup, Name: "secp192r1", Codepoint: "0x0013", Aliases: []string{"P-192", "prime192v1", "nistp192"},
+		KeyExchange: "ECDHE", StrengthBits: 96, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "secp224r1", Codepoint: "0x0015", Aliases: []string{"P-224", "nistp224"},
+		KeyExchange: "ECDHE", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "secp256k1", Codepoint: "0x0016",
+		KeyExchange: "ECDHE", StrengthBits: 128, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "secp256r1", Codepoint: "0x0017", Aliases: []string{"P-256", "prime256v1", "nistp256"},
+		KeyExchange: "ECDHE", StrengthBits: 128, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "secp384r1", Codepoint: "0x0018", Aliases: []string{"P-384", "nistp384"},
+		KeyExchange: "ECDHE", StrengthBits: 192, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "secp521r1", Codepoint: "0x0019", Aliases: []string{"P-521", "nistp521"},
+		KeyExchange: "ECDHE", StrengthBits: 256, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "brainpoolP256r1", Codepoint: "0x001A",
+		KeyExchange: "ECDHE", StrengthBits: 128, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "brainpoolP384r1", Codepoint: "0x001B",
+		KeyExchange: "ECDHE", StrengthBits: 192, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "brainpoolP512r1", Codepoint: "0x001C",
+		KeyExchange: "ECDHE", StrengthBits: 256, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "x25519", Codepoint: "0x001D", Aliases: []string{"X25519", "curve25519"},
+		KeyExchange: "ECDHE", StrengthBits: 128, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "x448", Codepoint: "0x001E", Aliases: []string{"X448", "curve448"},
+		KeyExchange: "ECDHE", StrengthBits: 224, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "brainpoolP256r1tls13", Codepoint: "0x001F",
+		KeyExchange: "ECDHE", StrengthBits: 128, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "brainpoolP384r1tls13", Codepoint: "0x0020",
+		KeyExchange: "ECDHE", StrengthBits: 192, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "brainpoolP512r1tls13", Codepoint: "0x0021",
+		KeyExchange: "ECDHE", StrengthBits: 256, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "ffdhe2048", Codepoint: "0x0100",
+		KeyExchange: "DHE", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "ffdhe3072", Codepoint: "0x0101",
+		KeyExchange: "DHE", StrengthBits: 128, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "ffdhe4096", Codepoint: "0x0102",
+		KeyExchange: "DHE", StrengthBits: 152, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "ffdhe6144", Codepoint: "0x0103",
+		KeyExchange: "DHE", StrengthBits: 176, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "ffdhe8192", Codepoint: "0x0104",
+		KeyExchange: "DHE", StrengthBits: 192, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindNamedGroup, Name: "MLKEM512", Codepoint: "0x0200", Aliases: []string{"ML-KEM-512"},
+		KeyExchange: "ML-KEM", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindNamedGroup, Name: "MLKEM768", Codepoint: "0x0201", Aliases: []string{"ML-KEM-768"},
+		KeyExchange: "ML-KEM", StrengthBits: 192, FIPSApproved: true},
+	{Kind: KindNamedGroup, Name: "MLKEM1024", Codepoint: "0x0202", Aliases: []string{"ML-KEM-1024"},
+		KeyExchange: "ML-KEM", StrengthBits: 256, FIPSApproved: true, CNSA2: true},
+	// Hybrids are rated at the strength of their classical half; the
+	// ML-KEM half keeps them safe against a quantum adversary
+	{Kind: KindNamedGroup, Name: "SecP256r1MLKEM768", Codepoint: "0x11EB",
+		KeyExchange: "ECDHE+ML-KEM", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindNamedGroup, Name: "X25519MLKEM768", Codepoint: "0x11EC",
+		KeyExchange: "ECDHE+ML-KEM", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindNamedGroup, Name: "SecP384r1MLKEM1024", Codepoint: "0x11ED",
+		KeyExchange: "ECDHE+ML-KEM", StrengthBits: 192, FIPSApproved: true, CNSA2: true},
+	{Kind: KindNamedGroup, Name: "X25519Kyber768Draft00", Codepoint: "0x6399",
+		KeyExchange: "ECDHE+Kyber", StrengthBits: 128, Deprecated: true},
+}
+
+// signatureSchemes are the TLS signature schemes, with the names X.509
+// libraries give the matching certificate signature algorithms as aliases.
+// RSA schemes are rated at the 2048-bit minimum key size.
+var signatureSchemes = []*Entry{
+	// TLS 1.2 hash and signature pair, kept for certificates still signed
+	// with MD5
+	{Kind: KindSignatureScheme, Name: "rsa_pkcs1_md5", Codepoint: "0x0101",
+		Aliases:        []string{"MD5-RSA", "MD5withRSA", "md5WithRSAEncryption", "RSA-MD5"},
+		Authentication: "RSA", Hash: "MD5", StrengthBits: 0, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pkcs1_sha1", Codepoint: "0x0201",
+		Aliases:        []string{"SHA1-RSA", "SHA1withRSA", "sha1WithRSAEncryption", "RSA-SHA1"},
+		Authentication: "RSA", Hash: "SHA1", StrengthBits: 63, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "dsa_sha1", Codepoint: "0x0202",
+		Aliases:        []string{"DSA-SHA1", "SHA1withDSA", "dsaWithSHA1"},
+		Authentication: "DSA", Hash: "SHA1", StrengthBits: 63, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "ecdsa_sha1", Codepoint: "0x0203",
+		Aliases:        []string{"ECDSA-SHA1", "SHA1withECDSA", "ecdsa-with-SHA1"},
+		Authentication: "ECDSA", Hash: "SHA1", StrengthBits: 63, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pkcs1_sha256", Codepoint: "0x0401",
+		Aliases:        []string{"SHA256-RSA", "SHA256withRSA", "sha256WithRSAEncryption", "RSA-SHA256"},
+		Authentication: "RSA", Hash: "SHA256", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "dsa_sha256", Codepoint: "0x0402",
+		Aliases:        []string{"DSA-SHA256", "SHA256withDSA", "dsa_with_SHA256"},
+		Authentication: "DSA", Hash: "SHA256", StrengthBits: 112, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "ecdsa_secp256r1_sha256", Codepoint: "0x0403",
+		Aliases:        []string{"ECDSA-SHA256", "SHA256withECDSA", "ecdsa-with-SHA256"},
+		Authentication: "ECDSA", Hash: "SHA256", StrengthBits: 128, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pkcs1_sha384", Codepoint: "0x0501",
+		Aliases:        []string{"SHA384-RSA", "SHA384withRSA", "sha384WithRSAEncryption", "RSA-SHA384"},
+		Authentication: "RSA", Hash: "SHA384", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "ecdsa_secp384r1_sha384", Codepoint: "0x0503",
+		Aliases:        []string{"ECDSA-SHA384", "SHA384withECDSA", "ecdsa-with-SHA384"},
+		Authentication: "ECDSA", Hash: "SHA384", StrengthBits: 192, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pkcs1_sha512", Codepoint: "0x0601",
+		Aliases:        []string{"SHA512-RSA", "SHA512withRSA", "sha512WithRSAEncryption", "RSA-SHA512"},
+		Authentication: "RSA", Hash: "SHA512", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "ecdsa_secp521r1_sha512", Codepoint: "0x0603",
+		Aliases:        []string{"ECDSA-SHA512", "SHA512withECDSA", "ecdsa-with-SHA512"},
+		Authentication: "ECDSA", Hash: "SHA512", StrengthBits: 256, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pss_rsae_sha256", Codepoint: "0x0804",
+		Aliases:        []string{"SHA256-RSAPSS", "SHA256withRSA/PSS", "RSASSA-PSS"},
+		Authentication: "RSA-PSS", Hash: "SHA256", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pss_rsae_sha384", Codepoint: "0x0805",
+		Aliases:        []string{"SHA384-RSAPSS", "SHA384withRSA/PSS"},
+		Authentication: "RSA-PSS", Hash: "SHA384", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pss_rsae_sha512", Codepoint: "0x0806",
+		Aliases:        []string{"SHA512-RSAPSS", "SHA512withRSA/PSS"},
+		Authentication: "RSA-PSS", Hash: "SHA512", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "ed25519", Codepoint: "0x0807", Aliases: []string{"Ed25519", "EdDSA25519"},
+		Authentication: "EdDSA", Hash: "SHA512", StrengthBits: 128, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "ed448", Codepoint: "0x0808", Aliases: []string{"Ed448", "EdDSA448"},
+		Authentication: "EdDSA", Hash: "SHAKE256", StrengthBits: 224, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pss_pss_sha256", Codepoint: "0x0809",
+		Authentication: "RSA-PSS", Hash: "SHA256", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pss_pss_sha384", Codepoint: "0x080A",
+		Authentication: "RSA-PSS", Hash: "SHA384", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "rsa_pss_pss_sha512", Codepoint: "0x080B",
+		Authentication: "RSA-PSS", Hash: "SHA512", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSignatureScheme, Name: "mldsa44", Codepoint: "0x0904", Aliases: []string{"ML-DSA-44"},
+		Authentication: "ML-DSA", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindSignatureScheme, Name: "mldsa65", Codepoint: "0x0905", Aliases: []string{"ML-DSA-65"},
+		Authentication: "ML-DSA", StrengthBits: 192, FIPSApproved: true},
+	{Kind: KindSignatureScheme, Name: "mldsa87", Codepoint: "0x0906", Aliases: []string{"ML-DSA-87"},
+		Authentication: "ML-DSA", StrengthBits: 256, FIPSApproved: true, CNSA2: true},
+}
+
+// hashes are rated by collision resistance, which is what signatures and
+// certificates depend on
+var hashes = []*Entry{
+	{Kind: KindHash, Name: "MD4", Hash: "MD4", Deprecated: true},
+	{Kind: KindHash, Name: "MD5", Hash: "MD5", Deprecated: true},
+	{Kind: KindHash, Name: "SHA1", Aliases: []string{"SHA-1", "SHA"}, Hash: "SHA1", StrengthBits: 63, Deprecated: true},
+	{Kind: KindHash, Name: "RIPEMD160", Aliases: []string{"RIPEMD-160"}, Hash: "RIPEMD160", StrengthBits: 80, Deprecated: true},
+	{Kind: KindHash, Name: "SHA224", Aliases: []string{"SHA-224", "SHA2-224"}, Hash: "SHA224", StrengthBits: 112, FIPSApproved: true},
+	{Kind: KindHash, Name: "SHA256", Aliases: []string{"SHA-256", "SHA2-256"}, Hash: "SHA256", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindHash, Name: "SHA384", Aliases: []string{"SHA-384", "SHA2-384"}, Hash: "SHA384", StrengthBits: 192, FIPSApproved: true, CNSA2: true},
+	{Kind: KindHash, Name: "SHA512", Aliases: []string{"SHA-512", "SHA2-512"}, Hash: "SHA512", StrengthBits: 256, FIPSApproved: true, CNSA2: true},
+	{Kind: KindHash, Name: "SHA512/256", Aliases: []string{"SHA-512/256"}, Hash: "SHA512/256", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindHash, Name: "SHA3-256", Aliases: []string{"SHA3_256"}, Hash: "SHA3-256", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindHash, Name: "SHA3-384", Aliases: []string{"SHA3_384"}, Hash: "SHA3-384", StrengthBits: 192, FIPSApproved: true},
+	{Kind: KindHash, Name: "SHA3-512", Aliases: []string{"SHA3_512"}, Hash: "SHA3-512", StrengthBits: 256, FIPSApproved: true},
+	{Kind: KindHash, Name: "SM3", Hash: "SM3", StrengthBits: 128},
+}
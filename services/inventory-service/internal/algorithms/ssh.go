@@ -0,0 +1,112 @@
+package algorithms
+
+// sshAlgorithms are the SSH key exchange, host key, cipher and MAC names of
+// the IANA SSH registries and OpenSSH. Finite field and group exchange
+// methods are rated at the size of their group, or at the minimum RFC 8270
+// allows when the group is negotiated.
+var sshAlgorithms = []*Entry{
+	// Key exchange
+	{Kind: KindSSHKex, Name: "curve25519-sha256", Aliases: []string{"curve25519-sha256@libssh.org"},
+		KeyExchange: "ECDH", PRF: "SHA256", StrengthBits: 128, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "curve448-sha512",
+		KeyExchange: "ECDH", PRF: "SHA512", StrengthBits: 224, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "ecdh-sha2-nistp256",
+		KeyExchange: "ECDH", PRF: "SHA256", StrengthBits: 128, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "ecdh-sha2-nistp384",
+		KeyExchange: "ECDH", PRF: "SHA384", StrengthBits: 192, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "ecdh-sha2-nistp521",
+		KeyExchange: "ECDH", PRF: "SHA512", StrengthBits: 256, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group1-sha1",
+		KeyExchange: "DH", PRF: "SHA1", StrengthBits: 80, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group14-sha1",
+		KeyExchange: "DH", PRF: "SHA1", StrengthBits: 112, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group14-sha256",
+		KeyExchange: "DH", PRF: "SHA256", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group15-sha512",
+		KeyExchange: "DH", PRF: "SHA512", StrengthBits: 128, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group16-sha512",
+		KeyExchange: "DH", PRF: "SHA512", StrengthBits: 152, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group17-sha512",
+		KeyExchange: "DH", PRF: "SHA512", StrengthBits: 176, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group18-sha512",
+		KeyExchange: "DH", PRF: "SHA512", StrengthBits: 192, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group-exchange-sha1",
+		KeyExchange: "DH", PRF: "SHA1", StrengthBits: 80, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "diffie-hellman-group-exchange-sha256",
+		KeyExchange: "DH", PRF: "SHA256", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "rsa1024-sha1",
+		KeyExchange: "RSA", PRF: "SHA1", StrengthBits: 80, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "rsa2048-sha256",
+		KeyExchange: "RSA", PRF: "SHA256", StrengthBits: 112, QuantumVulnerable: true},
+	{Kind: KindSSHKex, Name: "sntrup761x25519-sha512", Aliases: []string{"sntrup761x25519-sha512@openssh.com"},
+		KeyExchange: "ECDH+NTRU", PRF: "SHA512", StrengthBits: 128},
+	{Kind: KindSSHKex, Name: "mlkem768x25519-sha256",
+		KeyExchange: "ECDH+ML-KEM", PRF: "SHA256", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindSSHKex, Name: "mlkem768nistp256-sha256",
+		KeyExchange: "ECDH+ML-KEM", PRF: "SHA256", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindSSHKex, Name: "mlkem1024nistp384-sha384",
+		KeyExchange: "ECDH+ML-KEM", PRF: "SHA384", StrengthBits: 192, FIPSApproved: true, CNSA2: true},
+
+	// Host keys and their signatures
+	{Kind: KindSSHHostKey, Name: "ssh-ed25519", Aliases: []string{"ssh-ed25519-cert-v01@openssh.com"},
+		Authentication: "EdDSA", Hash: "SHA512", StrengthBits: 128, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "ssh-ed448",
+		Authentication: "EdDSA", Hash: "SHAKE256", StrengthBits: 224, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "sk-ssh-ed25519@openssh.com",
+		Authentication: "EdDSA", Hash: "SHA512", StrengthBits: 128, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "ecdsa-sha2-nistp256", Aliases: []string{"ecdsa-sha2-nistp256-cert-v01@openssh.com"},
+		Authentication: "ECDSA", Hash: "SHA256", StrengthBits: 128, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "ecdsa-sha2-nistp384", Aliases: []string{"ecdsa-sha2-nistp384-cert-v01@openssh.com"},
+		Authentication: "ECDSA", Hash: "SHA384", StrengthBits: 192, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "ecdsa-sha2-nistp521", Aliases: []string{"ecdsa-sha2-nistp521-cert-v01@openssh.com"},
+		Authentication: "ECDSA", Hash: "SHA512", StrengthBits: 256, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "sk-ecdsa-sha2-nistp256@openssh.com",
+		Authentication: "ECDSA", Hash: "SHA256", StrengthBits: 128, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "rsa-sha2-256", Aliases: []string{"rsa-sha2-256-cert-v01@openssh.com"},
+		Authentication: "RSA", Hash: "SHA256", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "rsa-sha2-512", Aliases: []string{"rsa-sha2-512-cert-v01@openssh.com"},
+		Authentication: "RSA", Hash: "SHA512", StrengthBits: 112, FIPSApproved: true, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "ssh-rsa", Aliases: []string{"ssh-rsa-cert-v01@openssh.com"},
+		Authentication: "RSA", Hash: "SHA1", StrengthBits: 63, Deprecated: true, QuantumVulnerable: true},
+	{Kind: KindSSHHostKey, Name: "ssh-dss", Aliases: []string{"ssh-dss-cert-v01@openssh.com"},
+		Authentication: "DSA", Hash: "SHA1", StrengthBits: 63, Deprecated: true, QuantumVulnerable: true},
+
+	// Ciphers
+	{Kind: KindSSHCipher, Name: "aes128-ctr", BulkCipher: "AES_128", Mode: "CTR", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindSSHCipher, Name: "aes192-ctr", BulkCipher: "AES_192", Mode: "CTR", StrengthBits: 192, FIPSApproved: true},
+	{Kind: KindSSHCipher, Name: "aes256-ctr", BulkCipher: "AES_256", Mode: "CTR", StrengthBits: 256, FIPSApproved: true, CNSA2: true},
+	{Kind: KindSSHCipher, Name: "aes128-cbc", BulkCipher: "AES_128", Mode: "CBC", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindSSHCipher, Name: "aes192-cbc", BulkCipher: "AES_192", Mode: "CBC", StrengthBits: 192, FIPSApproved: true},
+	{Kind: KindSSHCipher, Name: "aes256-cbc", Aliases: []string{"rijndael-cbc@lysator.liu.se"},
+		BulkCipher: "AES_256", Mode: "CBC", StrengthBits: 256, FIPSApproved: true, CNSA2: true},
+	{Kind: KindSSHCipher, Name: "aes128-gcm@openssh.com", Aliases: []string{"AEAD_AES_128_GCM"},
+		BulkCipher: "AES_128", Mode: "GCM", MAC: "AEAD", StrengthBits: 128, FIPSApproved: true},
+	{Kind: KindSSHCipher, Name: "aes256-gcm@openssh.com", Aliases: []string{"AEAD_AES_256_GCM"},
+		BulkCipher: "AES_256", Mode: "GCM", MAC: "AEAD", StrengthBits: 256, FIPSApproved: true, CNSA2: true},
+	{Kind: KindSSHCipher, Name: "chacha20-poly1305@openssh.com",
+		BulkCipher: "CHACHA20", Mode: "POLY1305", MAC: "AEAD", StrengthBits: 256},
+	{Kind: KindSSHCipher, Name: "3des-cbc", BulkCipher: "3DES_EDE", Mode: "CBC", StrengthBits: 112, Deprecated: true},
+	{Kind: KindSSHCipher, Name: "blowfish-cbc", BulkCipher: "BLOWFISH", Mode: "CBC", StrengthBits: 64, Deprecated: true},
+	{Kind: KindSSHCipher, Name: "cast128-cbc", BulkCipher: "CAST128", Mode: "CBC", StrengthBits: 64, Deprecated: true},
+	{Kind: KindSSHCipher, Name: "arcfour", BulkCipher: "RC4", Mode: "STREAM", Deprecated: true},
+	{Kind: KindSSHCipher, Name: "arcfour128", BulkCipher: "RC4", Mode: "STREAM", Deprecated: true},
+	{Kind: KindSSHCipher, Name: "arcfour256", BulkCipher: "RC4", Mode: "STREAM", Deprecated: true},
+	{Kind: KindSSHCipher, Name: "none", BulkCipher: "NULL", Deprecated: true},
+
+	// MACs
+	{Kind: KindSSHMAC, Name: "hmac-sha2-256", MAC: "HMAC_SHA256", StrengthBits: 256, FIPSApproved: true},
+	{Kind: KindSSHMAC, Name: "hmac-sha2-512", MAC: "HMAC_SHA512", StrengthBits: 256, FIPSApproved: true, CNSA2: true},
+	{Kind: KindSSHMAC, Name: "hmac-sha2-256-etm@openssh.com", MAC: "HMAC_SHA256", StrengthBits: 256, FIPSApproved: true},
+	{Kind: KindSSHMAC, Name: "hmac-sha2-512-etm@openssh.com", MAC: "HMAC_SHA512", StrengthBits: 256, FIPSApproved: true, CNSA2: true},
+	{Kind: KindSSHMAC, Name: "hmac-sha1", MAC: "HMAC_SHA1", StrengthBits: 160, FIPSApproved: true},
+	{Kind: KindSSHMAC, Name: "hmac-sha1-etm@openssh.com", MAC: "HMAC_SHA1", StrengthBits: 160, FIPSApproved: true},
+	{Kind: KindSSHMAC, Name: "hmac-sha1-96", MAC: "HMAC_SHA1", StrengthBits: 96, Deprecated: true},
+	{Kind: KindSSHMAC, Name: "hmac-md5", MAC: "HMAC_MD5", StrengthBits: 128, Deprecated: true},
+	{Kind: KindSSHMAC, Name: "hmac-md5-etm@openssh.com", MAC: "HMAC_MD5", StrengthBits: 128, Deprecated: true},
+	{Kind: KindSSHMAC, Name: "hmac-md5-96", MAC: "HMAC_MD5", StrengthBits: 96, Deprecated: true},
+	{Kind: KindSSHMAC, Name: "hmac-ripemd160", Aliases: []string{"hmac-ripemd160@openssh.com"},
+		MAC: "HMAC_RIPEMD160", StrengthBits: 160, Deprecated: true},
+	{Kind: KindSSHMAC, Name: "umac-64@openssh.com", Aliases: []string{"umac-64-etm@openssh.com"}, MAC: "UMAC", StrengthBits: 64},
+	{Kind: KindSSHMAC, Name: "umac-128@openssh.com", Aliases: []string{"umac-128-etm@openssh.com"}, MAC: "UMAC", StrengthBits: 128},
+	{Kind: KindSSHMAC, Name: "none", MAC: "NULL", Deprecated: true},
+}
@@ -0,0 +1,512 @@
+package algorithms
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// suite is a registered cipher suite: its IANA codepoint and name, and the
+// names OpenSSL gives it
+type suite struct {
+	codepoint uint16
+	name      string
+	openssl   []string
+}
+
+// suites are the IANA TLS cipher suites, less the signalling values and the
+// GOST, ECCPWD and rarely deployed ARIA and Camellia variants, which still
+// resolve by name
+var suites = []suite{
+	{0x0000, "TLS_NULL_WITH_NULL_NULL", nil},
+	{0x0001, "TLS_RSA_WITH_NULL_MD5", []string{"NULL-MD5"}},
+	{0x0002, "TLS_RSA_WITH_NULL_SHA", []string{"NULL-SHA"}},
+	{0x0003, "TLS_RSA_EXPORT_WITH_RC4_40_MD5", []string{"EXP-RC4-MD5"}},
+	{0x0004, "TLS_RSA_WITH_RC4_128_MD5", []string{"RC4-MD5"}},
+	{0x0005, "TLS_RSA_WITH_RC4_128_SHA", []string{"RC4-SHA"}},
+	{0x0006, "TLS_RSA_EXPORT_WITH_RC2_CBC_40_MD5", []string{"EXP-RC2-CBC-MD5"}},
+	{0x0007, "TLS_RSA_WITH_IDEA_CBC_SHA", []string{"IDEA-CBC-SHA"}},
+	{0x0008, "TLS_RSA_EXPORT_WITH_DES40_CBC_SHA", []string{"EXP-DES-CBC-SHA"}},
+	{0x0009, "TLS_RSA_WITH_DES_CBC_SHA", []string{"DES-CBC-SHA"}},
+	{0x000A, "TLS_RSA_WITH_3DES_EDE_CBC_SHA", []string{"DES-CBC3-SHA"}},
+	{0x000B, "TLS_DH_DSS_EXPORT_WITH_DES40_CBC_SHA", nil},
+	{0x000C, "TLS_DH_DSS_WITH_DES_CBC_SHA", nil},
+	{0x000D, "TLS_DH_DSS_WITH_3DES_EDE_CBC_SHA", nil},
+	{0x000E, "TLS_DH_RSA_EXPORT_WITH_DES40_CBC_SHA", nil},
+	{0x000F, "TLS_DH_RSA_WITH_DES_CBC_SHA", nil},
+	{0x0010, "TLS_DH_RSA_WITH_3DES_EDE_CBC_SHA", nil},
+	{0x0011, "TLS_DHE_DSS_EXPORT_WITH_DES40_CBC_SHA", []string{"EXP-EDH-DSS-DES-CBC-SHA"}},
+	{0x0012, "TLS_DHE_DSS_WITH_DES_CBC_SHA", []string{"EDH-DSS-DES-CBC-SHA"}},
+	{0x0013, "TLS_DHE_DSS_WITH_3DES_EDE_CBC_SHA", []string{"EDH-DSS-DES-CBC3-SHA", "DHE-DSS-DES-CBC3-SHA"}},
+	{0x0014, "TLS_DHE_RSA_EXPORT_WITH_DES40_CBC_SHA", []string{"EXP-EDH-RSA-DES-CBC-SHA"}},
+	{0x0015, "TLS_DHE_RSA_WITH_DES_CBC_SHA", []string{"EDH-RSA-DES-CBC-SHA"}},
+	{0x0016, "TLS_DHE_RSA_WITH_3DES_EDE_CBC_SHA", []string{"EDH-RSA-DES-CBC3-SHA", "DHE-RSA-DES-CBC3-SHA"}},
+	{0x0017, "TLS_DH_anon_EXPORT_WITH_RC4_40_MD5", []string{"EXP-ADH-RC4-MD5"}},
+	{0x0018, "TLS_DH_anon_WITH_RC4_128_MD5", []string{"ADH-RC4-MD5"}},
+	{0x0019, "TLS_DH_anon_EXPORT_WITH_DES40_CBC_SHA", []string{"EXP-ADH-DES-CBC-SHA"}},
+	{0x001A, "TLS_DH_anon_WITH_DES_CBC_SHA", []string{"ADH-DES-CBC-SHA"}},
+	{0x001B, "TLS_DH_anon_WITH_3DES_EDE_CBC_SHA", []string{"ADH-DES-CBC3-SHA"}},
+	{0x001E, "TLS_KRB5_WITH_DES_CBC_SHA", nil},
+	{0x001F, "TLS_KRB5_WITH_3DES_EDE_CBC_SHA", nil},
+	{0x0020, "TLS_KRB5_WITH_RC4_128_SHA", nil},
+	{0x0021, "TLS_KRB5_WITH_IDEA_CBC_SHA", nil},
+	{0x0022, "TLS_KRB5_WITH_DES_CBC_MD5", nil},
+	{0x0023, "TLS_KRB5_WITH_3DES_EDE_CBC_MD5", nil},
+	{0x0024, "TLS_KRB5_WITH_RC4_128_MD5", nil},
+	{0x0025, "TLS_KRB5_WITH_IDEA_CBC_MD5", nil},
+	{0x0026, "TLS_KRB5_EXPORT_WITH_DES_CBC_40_SHA", nil},
+	{0x0027, "TLS_KRB5_EXPORT_WITH_RC2_CBC_40_SHA", nil},
+	{0x0028, "TLS_KRB5_EXPORT_WITH_RC4_40_SHA", nil},
+	{0x0029, "TLS_KRB5_EXPORT_WITH_DES_CBC_40_MD5", nil},
+	{0x002A, "TLS_KRB5_EXPORT_WITH_RC2_CBC_40_MD5", nil},
+	{0x002B, "TLS_KRB5_EXPORT_WITH_RC4_40_MD5", nil},
+	{0x002C, "TLS_PSK_WITH_NULL_SHA", []string{"PSK-NULL-SHA"}},
+	{0x002D, "TLS_DHE_PSK_WITH_NULL_SHA", []string{"DHE-PSK-NULL-SHA"}},
+	{0x002E, "TLS_RSA_PSK_WITH_NULL_SHA", []string{"RSA-PSK-NULL-SHA"}},
+	{0x002F, "TLS_RSA_WITH_AES_128_CBC_SHA", []string{"AES128-SHA"}},
+	{0x0030, "TLS_DH_DSS_WITH_AES_128_CBC_SHA", nil},
+	{0x0031, "TLS_DH_RSA_WITH_AES_128_CBC_SHA", nil},
+	{0x0032, "TLS_DHE_DSS_WITH_AES_128_CBC_SHA", []string{"DHE-DSS-AES128-SHA"}},
+	{0x0033, "TLS_DHE_RSA_WITH_AES_128_CBC_SHA", []string{"DHE-RSA-AES128-SHA"}},
+	{0x0034, "TLS_DH_anon_WITH_AES_128_CBC_SHA", []string{"ADH-AES128-SHA"}},
+	{0x0035, "TLS_RSA_WITH_AES_256_CBC_SHA", []string{"AES256-SHA"}},
+	{0x0036, "TLS_DH_DSS_WITH_AES_256_CBC_SHA", nil},
+	{0x0037, "TLS_DH_RSA_WITH_AES_256_CBC_SHA", nil},
+	{0x0038, "TLS_DHE_DSS_WITH_AES_256_CBC_SHA", []string{"DHE-DSS-AES256-SHA"}},
+	{0x0039, "TLS_DHE_RSA_WITH_AES_256_CBC_SHA", []string{"DHE-RSA-AES256-SHA"}},
+	{0x003A, "TLS_DH_anon_WITH_AES_256_CBC_SHA", []string{"ADH-AES256-SHA"}},
+	{0x003B, "TLS_RSA_WITH_NULL_SHA256", []string{"NULL-SHA256"}},
+	{0x003C, "TLS_RSA_WITH_AES_128_CBC_SHA256", []string{"AES128-SHA256"}},
+	{0x003D, "TLS_RSA_WITH_AES_256_CBC_SHA256", []string{"AES256-SHA256"}},
+	{0x003E, "TLS_DH_DSS_WITH_AES_128_CBC_SHA256", nil},
+	{0x003F, "TLS_DH_RSA_WITH_AES_128_CBC_SHA256", nil},
+	{0x0040, "TLS_DHE_DSS_WITH_AES_128_CBC_SHA256", []string{"DHE-DSS-AES128-SHA256"}},
+	{0x0041, "TLS_RSA_WITH_CAMELLIA_128_CBC_SHA", []string{"CAMELLIA128-SHA"}},
+	{0x0042, "TLS_DH_DSS_WITH_CAMELLIA_128_CBC_SHA", nil},
+	{0x0043, "TLS_DH_RSA_WITH_CAMELLIA_128_CBC_SHA", nil},
+	{0x0044, "TLS_DHE_DSS_WITH_CAMELLIA_128_CBC_SHA", []string{"DHE-DSS-CAMELLIA128-SHA"}},
+	{0x0045, "TLS_DHE_RSA_WITH_CAMELLIA_128_CBC_SHA", []string{"DHE-RSA-CAMELLIA128-SHA"}},
+	{0x0046, "TLS_DH_anon_WITH_CAMELLIA_128_CBC_SHA", []string{"ADH-CAMELLIA128-SHA"}},
+	{0x0067, "TLS_DHE_RSA_WITH_AES_128_CBC_SHA256", []string{"DHE-RSA-AES128-SHA256"}},
+	{0x0068, "TLS_DH_DSS_WITH_AES_256_CBC_SHA256", nil},
+	{0x0069, "TLS_DH_RSA_WITH_AES_256_CBC_SHA256", nil},
+	{0x006A, "TLS_DHE_DSS_WITH_AES_256_CBC_SHA256", []string{"DHE-DSS-AES256-SHA256"}},
+	{0x006B, "TLS_DHE_RSA_WITH_AES_256_CBC_SHA256", []string{"DHE-RSA-AES256-SHA256"}},
+	{0x006C, "TLS_DH_anon_WITH_AES_128_CBC_SHA256", []string{"ADH-AES128-SHA256"}},
+	{0x006D, "TLS_DH_anon_WITH_AES_256_CBC_SHA256", []string{"ADH-AES256-SHA256"}},
+	{0x0084, "TLS_RSA_WITH_CAMELLIA_256_CBC_SHA", []string{"CAMELLIA256-SHA"}},
+	{0x0085, "TLS_DH_DSS_WITH_CAMELLIA_256_CBC_SHA", nil},
+	{0x0086, "TLS_DH_RSA_WITH_CAMELLIA_256_CBC_SHA", nil},
+	{0x0087, "TLS_DHE_DSS_WITH_CAMELLIA_256_CBC_SHA", []string{"DHE-DSS-CAMELLIA256-SHA"}},
+	{0x0088, "TLS_DHE_RSA_WITH_CAMELLIA_256_CBC_SHA", []string{"DHE-RSA-CAMELLIA256-SHA"}},
+	{0x0089, "TLS_DH_anon_WITH_CAMELLIA_256_CBC_SHA", []string{"ADH-CAMELLIA256-SHA"}},
+	{0x008A, "TLS_PSK_WITH_RC4_128_SHA", []string{"PSK-RC4-SHA"}},
+	{0x008B, "TLS_PSK_WITH_3DES_EDE_CBC_SHA", []string{"PSK-3DES-EDE-CBC-SHA"}},
+	{0x008C, "TLS_PSK_WITH_AES_128_CBC_SHA", []string{"PSK-AES128-CBC-SHA"}},
+	{0x008D, "TLS_PSK_WITH_AES_256_CBC_SHA", []string{"PSK-AES256-CBC-SHA"}},
+	{0x008E, "TLS_DHE_PSK_WITH_RC4_128_SHA", nil},
+	{0x008F, "TLS_DHE_PSK_WITH_3DES_EDE_CBC_SHA", []string{"DHE-PSK-3DES-EDE-CBC-SHA"}},
+	{0x0090, "TLS_DHE_PSK_WITH_AES_128_CBC_SHA", []string{"DHE-PSK-AES128-CBC-SHA"}},
+	{0x0091, "TLS_DHE_PSK_WITH_AES_256_CBC_SHA", []string{"DHE-PSK-AES256-CBC-SHA"}},
+	{0x0092, "TLS_RSA_PSK_WITH_RC4_128_SHA", nil},
+	{0x0093, "TLS_RSA_PSK_WITH_3DES_EDE_CBC_SHA", []string{"RSA-PSK-3DES-EDE-CBC-SHA"}},
+	{0x0094, "TLS_RSA_PSK_WITH_AES_128_CBC_SHA", []string{"RSA-PSK-AES128-CBC-SHA"}},
+	{0x0095, "TLS_RSA_PSK_WITH_AES_256_CBC_SHA", []string{"RSA-PSK-AES256-CBC-SHA"}},
+	{0x0096, "TLS_RSA_WITH_SEED_CBC_SHA", []string{"SEED-SHA"}},
+	{0x0097, "TLS_DH_DSS_WITH_SEED_CBC_SHA", nil},
+	{0x0098, "TLS_DH_RSA_WITH_SEED_CBC_SHA", nil},
+	{0x0099, "TLS_DHE_DSS_WITH_SEED_CBC_SHA", []string{"DHE-DSS-SEED-SHA"}},
+	{0x009A, "TLS_DHE_RSA_WITH_SEED_CBC_SHA", []string{"DHE-RSA-SEED-SHA"}},
+	{0x009B, "TLS_DH_anon_WITH_SEED_CBC_SHA", []string{"ADH-SEED-SHA"}},
+	{0x009C, "TLS_RSA_WITH_AES_128_GCM_SHA256", []string{"AES128-GCM-SHA256"}},
+	{0x009D, "TLS_RSA_WITH_AES_256_GCM_SHA384", []string{"AES256-GCM-SHA384"}},
+	{0x009E, "TLS_DHE_RSA_WITH_AES_128_GCM_SHA256", []string{"DHE-RSA-AES128-GCM-SHA256"}},
+	{0x009F, "TLS_DHE_RSA_WITH_AES_256_GCM_SHA384", []string{"DHE-RSA-AES256-GCM-SHA384"}},
+	{0x00A0, "TLS_DH_RSA_WITH_AES_128_GCM_SHA256", nil},
+	{0x00A1, "TLS_DH_RSA_WITH_AES_256_GCM_SHA384", nil},
+	{0x00A2, "TLS_DHE_DSS_WITH_AES_128_GCM_SHA256", []string{"DHE-DSS-AES128-GCM-SHA256"}},
+	{0x00A3, "TLS_DHE_DSS_WITH_AES_256_GCM_SHA384", []string{"DHE-DSS-AES256-GCM-SHA384"}},
+	{0x00A4, "TLS_DH_DSS_WITH_AES_128_GCM_SHA256", nil},
+	{0x00A5, "TLS_DH_DSS_WITH_AES_256_GCM_SHA384", nil},
+	{0x00A6, "TLS_DH_anon_WITH_AES_128_GCM_SHA256", []string{"ADH-AES128-GCM-SHA256"}},
+	{0x00A7, "TLS_DH_anon_WITH_AES_256_GCM_SHA384", []string{"ADH-AES256-GCM-SHA384"}},
+	{0x00A8, "TLS_PSK_WITH_AES_128_GCM_SHA256", []string{"PSK-AES128-GCM-SHA256"}},
+	{0x00A9, "TLS_PSK_WITH_AES_256_GCM_SHA384", []string{"PSK-AES256-GCM-SHA384"}},
+	{0x00AA, "TLS_DHE_PSK_WITH_AES_128_GCM_SHA256", []string{"DHE-PSK-AES128-GCM-SHA256"}},
+	{0x00AB, "TLS_DHE_PSK_WITH_AES_256_GCM_SHA384", []string{"DHE-PSK-AES256-GCM-SHA384"}},
+	{0x00AC, "TLS_RSA_PSK_WITH_AES_128_GCM_SHA256", []string{"RSA-PSK-AES128-GCM-SHA256"}},
+	{0x00AD, "TLS_RSA_PSK_WITH_AES_256_GCM_SHA384", []string{"RSA-PSK-AES256-GCM-SHA384"}},
+	{0x00AE, "TLS_PSK_WITH_AES_128_CBC_SHA256", []string{"PSK-AES128-CBC-SHA256"}},
+	{0x00AF, "TLS_PSK_WITH_AES_256_CBC_SHA384", []string{"PSK-AES256-CBC-SHA384"}},
+	{0x00B0, "TLS_PSK_WITH_NULL_SHA256", []string{"PSK-NULL-SHA256"}},
+	{0x00B1, "TLS_PSK_WITH_NULL_SHA384", []string{"PSK-NULL-SHA384"}},
+	{0x00B2, "TLS_DHE_PSK_WITH_AES_128_CBC_SHA256", []string{"DHE-PSK-AES128-CBC-SHA256"}},
+	{0x00B3, "TLS_DHE_PSK_WITH_AES_256_CBC_SHA384", []string{"DHE-PSK-AES256-CBC-SHA384"}},
+	{0x00B4, "TLS_DHE_PSK_WITH_NULL_SHA256", []string{"DHE-PSK-NULL-SHA256"}},
+	{0x00B5, "TLS_DHE_PSK_WITH_NULL_SHA384", []string{"DHE-PSK-NULL-SHA384"}},
+	{0x00B6, "TLS_RSA_PSK_WITH_AES_128_CBC_SHA256", []string{"RSA-PSK-AES128-CBC-SHA256"}},
+	{0x00B7, "TLS_RSA_PSK_WITH_AES_256_CBC_SHA384", []string{"RSA-PSK-AES256-CBC-SHA384"}},
+	{0x00B8, "TLS_RSA_PSK_WITH_NULL_SHA256", []string{"RSA-PSK-NULL-SHA256"}},
+	{0x00B9, "TLS_RSA_PSK_WITH_NULL_SHA384", []string{"RSA-PSK-NULL-SHA384"}},
+	{0x00BA, "TLS_RSA_WITH_CAMELLIA_128_CBC_SHA256", []string{"CAMELLIA128-SHA256"}},
+	{0x00BB, "TLS_DH_DSS_WITH_CAMELLIA_128_CBC_SHA256", nil},
+	{0x00BC, "TLS_DH_RSA_WITH_CAMELLIA_128_CBC_SHA256", nil},
+	{0x00BD, "TLS_DHE_DSS_WITH_CAMELLIA_128_CBC_SHA256", []string{"DHE-DSS-CAMELLIA128-SHA256"}},
+	{0x00BE, "TLS_DHE_RSA_WITH_CAMELLIA_128_CBC_SHA256", []string{"DHE-RSA-CAMELLIA128-SHA256"}},
+	{0x00BF, "TLS_DH_anon_WITH_CAMELLIA_128_CBC_SHA256", []string{"ADH-CAMELLIA128-SHA256"}},
+	{0x00C0, "TLS_RSA_WITH_CAMELLIA_256_CBC_SHA256", []string{"CAMELLIA256-SHA256"}},
+	{0x00C1, "TLS_DH_DSS_WITH_CAMELLIA_256_CBC_SHA256", nil},
+	{0x00C2, "TLS_DH_RSA_WITH_CAMELLIA_256_CBC_SHA256", nil},
+	{0x00C3, "TLS_DHE_DSS_WITH_CAMELLIA_256_CBC_SHA256", []string{"DHE-DSS-CAMELLIA256-SHA256"}},
+	{0x00C4, "TLS_DHE_RSA_WITH_CAMELLIA_256_CBC_SHA256", []string{"DHE-RSA-CAMELLIA256-SHA256"}},
+	{0x00C5, "TLS_DH_anon_WITH_CAMELLIA_256_CBC_SHA256", []string{"ADH-CAMELLIA256-SHA256"}},
+	{0x00C6, "TLS_SM4_GCM_SM3", nil},
+	{0x00C7, "TLS_SM4_CCM_SM3", nil},
+	{0x1301, "TLS_AES_128_GCM_SHA256", nil},
+	{0x1302, "TLS_AES_256_GCM_SHA384", nil},
+	{0x1303, "TLS_CHACHA20_POLY1305_SHA256", nil},
+	{0x1304, "TLS_AES_128_CCM_SHA256", nil},
+	{0x1305, "TLS_AES_128_CCM_8_SHA256", nil},
+	{0xC001, "TLS_ECDH_ECDSA_WITH_NULL_SHA", []string{"ECDH-ECDSA-NULL-SHA"}},
+	{0xC002, "TLS_ECDH_ECDSA_WITH_RC4_128_SHA", []string{"ECDH-ECDSA-RC4-SHA"}},
+	{0xC003, "TLS_ECDH_ECDSA_WITH_3DES_EDE_CBC_SHA", []string{"ECDH-ECDSA-DES-CBC3-SHA"}},
+	{0xC004, "TLS_ECDH_ECDSA_WITH_AES_128_CBC_SHA", []string{"ECDH-ECDSA-AES128-SHA"}},
+	{0xC005, "TLS_ECDH_ECDSA_WITH_AES_256_CBC_SHA", []string{"ECDH-ECDSA-AES256-SHA"}},
+	{0xC006, "TLS_ECDHE_ECDSA_WITH_NULL_SHA", []string{"ECDHE-ECDSA-NULL-SHA"}},
+	{0xC007, "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA", []string{"ECDHE-ECDSA-RC4-SHA"}},
+	{0xC008, "TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA", []string{"ECDHE-ECDSA-DES-CBC3-SHA"}},
+	{0xC009, "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA", []string{"ECDHE-ECDSA-AES128-SHA"}},
+	{0xC00A, "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA", []string{"ECDHE-ECDSA-AES256-SHA"}},
+	{0xC00B, "TLS_ECDH_RSA_WITH_NULL_SHA", []string{"ECDH-RSA-NULL-SHA"}},
+	{0xC00C, "TLS_ECDH_RSA_WITH_RC4_128_SHA", []string{"ECDH-RSA-RC4-SHA"}},
+	{0xC00D, "TLS_ECDH_RSA_WITH_3DES_EDE_CBC_SHA", []string{"ECDH-RSA-DES-CBC3-SHA"}},
+	{0xC00E, "TLS_ECDH_RSA_WITH_AES_128_CBC_SHA", []string{"ECDH-RSA-AES128-SHA"}},
+	{0xC00F, "TLS_ECDH_RSA_WITH_AES_256_CBC_SHA", []string{"ECDH-RSA-AES256-SHA"}},
+	{0xC010, "TLS_ECDHE_RSA_WITH_NULL_SHA", []string{"ECDHE-RSA-NULL-SHA"}},
+	{0xC011, "TLS_ECDHE_RSA_WITH_RC4_128_SHA", []string{"ECDHE-RSA-RC4-SHA"}},
+	{0xC012, "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA", []string{"ECDHE-RSA-DES-CBC3-SHA"}},
+	{0xC013, "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA", []string{"ECDHE-RSA-AES128-SHA"}},
+	{0xC014, "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA", []string{"ECDHE-RSA-AES256-SHA"}},
+	{0xC015, "TLS_ECDH_anon_WITH_NULL_SHA", []string{"AECDH-NULL-SHA"}},
+	{0xC016, "TLS_ECDH_anon_WITH_RC4_128_SHA", []string{"AECDH-RC4-SHA"}},
+	{0xC017, "TLS_ECDH_anon_WITH_3DES_EDE_CBC_SHA", []string{"AECDH-DES-CBC3-SHA"}},
+	{0xC018, "TLS_ECDH_anon_WITH_AES_128_CBC_SHA", []string{"AECDH-AES128-SHA"}},
+	{0xC019, "TLS_ECDH_anon_WITH_AES_256_CBC_SHA", []string{"AECDH-AES256-SHA"}},
+	{0xC01A, "TLS_SRP_SHA_WITH_3DES_EDE_CBC_SHA", []string{"SRP-3DES-EDE-CBC-SHA"}},
+	{0xC01B, "TLS_SRP_SHA_RSA_WITH_3DES_EDE_CBC_SHA", []string{"SRP-RSA-3DES-EDE-CBC-SHA"}},
+	{0xC01C, "TLS_SRP_SHA_DSS_WITH_3DES_EDE_CBC_SHA", []string{"SRP-DSS-3DES-EDE-CBC-SHA"}},
+	{0xC01D, "TLS_SRP_SHA_WITH_AES_128_CBC_SHA", []string{"SRP-AES-128-CBC-SHA"}},
+	{0xC01E, "TLS_SRP_SHA_RSA_WITH_AES_128_CBC_SHA", []string{"SRP-RSA-AES-128-CBC-SHA"}},
+	{0xC01F, "TLS_SRP_SHA_DSS_WITH_AES_128_CBC_SHA", []string{"SRP-DSS-AES-128-CBC-SHA"}},
+	{0xC020, "TLS_SRP_SHA_WITH_AES_256_CBC_SHA", []string{"SRP-AES-256-CBC-SHA"}},
+	{0xC021, "TLS_SRP_SHA_RSA_WITH_AES_256_CBC_SHA", []string{"SRP-RSA-AES-256-CBC-SHA"}},
+	{0xC022, "TLS_SRP_SHA_DSS_WITH_AES_256_CBC_SHA", []string{"SRP-DSS-AES-256-CBC-SHA"}},
+	{0xC023, "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256", []string{"ECDHE-ECDSA-AES128-SHA256"}},
+	{0xC024, "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384", []string{"ECDHE-ECDSA-AES256-SHA384"}},
+	{0xC025, "TLS_ECDH_ECDSA_WITH_AES_128_CBC_SHA256", []string{"ECDH-ECDSA-AES128-SHA256"}},
+	{0xC026, "TLS_ECDH_ECDSA_WITH_AES_256_CBC_SHA384", []string{"ECDH-ECDSA-AES256-SHA384"}},
+	{0xC027, "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256", []string{"ECDHE-RSA-AES128-SHA256"}},
+	{0xC028, "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384", []string{"ECDHE-RSA-AES256-SHA384"}},
+	{0xC029, "TLS_ECDH_RSA_WITH_AES_128_CBC_SHA256", []string{"ECDH-RSA-AES128-SHA256"}},
+	{0xC02A, "TLS_ECDH_RSA_WITH_AES_256_CBC_SHA384", []string{"ECDH-RSA-AES256-SHA384"}},
+	{0xC02B, "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", []string{"ECDHE-ECDSA-AES128-GCM-SHA256"}},
+	{0xC02C, "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", []string{"ECDHE-ECDSA-AES256-GCM-SHA384"}},
+	{0xC02D, "TLS_ECDH_ECDSA_WITH_AES_128_GCM_SHA256", []string{"ECDH-ECDSA-AES128-GCM-SHA256"}},
+	{0xC02E, "TLS_ECDH_ECDSA_WITH_AES_256_GCM_SHA384", []string{"ECDH-ECDSA-AES256-GCM-SHA384"}},
+	{0xC02F, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", []string{"ECDHE-RSA-AES128-GCM-SHA256"}},
+	{0xC030, "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", []string{"ECDHE-RSA-AES256-GCM-SHA384"}},
+	{0xC031, "TLS_ECDH_RSA_WITH_AES_128_GCM_SHA256", []string{"ECDH-RSA-AES128-GCM-SHA256"}},
+	{0xC032, "TLS_ECDH_RSA_WITH_AES_256_GCM_SHA384", []string{"ECDH-RSA-AES256-GCM-SHA384"}},
+	{0xC033, "TLS_ECDHE_PSK_WITH_RC4_128_SHA", nil},
+	{0xC034, "TLS_ECDHE_PSK_WITH_3DES_EDE_CBC_SHA", []string{"ECDHE-PSK-3DES-EDE-CBC-SHA"}},
+	{0xC035, "TLS_ECDHE_PSK_WITH_AES_128_CBC_SHA", []string{"ECDHE-PSK-AES128-CBC-SHA"}},
+	{0xC036, "TLS_ECDHE_PSK_WITH_AES_256_CBC_SHA", []string{"ECDHE-PSK-AES256-CBC-SHA"}},
+	{0xC037, "TLS_ECDHE_PSK_WITH_AES_128_CBC_SHA256", []string{"ECDHE-PSK-AES128-CBC-SHA256"}},
+	{0xC038, "TLS_ECDHE_PSK_WITH_AES_256_CBC_SHA384", []string{"ECDHE-PSK-AES256-CBC-SHA384"}},
+	{0xC039, "TLS_ECDHE_PSK_WITH_NULL_SHA", []string{"ECDHE-PSK-NULL-SHA"}},
+	{0xC03A, "TLS_ECDHE_PSK_WITH_NULL_SHA256", []string{"ECDHE-PSK-NULL-SHA256"}},
+	{0xC03B, "TLS_ECDHE_PSK_WITH_NULL_SHA384", []string{"ECDHE-PSK-NULL-SHA384"}},
+	{0xC03C, "TLS_RSA_WITH_ARIA_128_CBC_SHA256", nil},
+	{0xC03D, "TLS_RSA_WITH_ARIA_256_CBC_SHA384", nil},
+	{0xC048, "TLS_ECDHE_ECDSA_WITH_ARIA_128_CBC_SHA256", nil},
+	{0xC049, "TLS_ECDHE_ECDSA_WITH_ARIA_256_CBC_SHA384", nil},
+	{0xC04C, "TLS_ECDHE_RSA_WITH_ARIA_128_CBC_SHA256", nil},
+	{0xC04D, "TLS_ECDHE_RSA_WITH_ARIA_256_CBC_SHA384", nil},
+	{0xC050, "TLS_RSA_WITH_ARIA_128_GCM_SHA256", []string{"ARIA128-GCM-SHA256"}},
+	{0xC051, "TLS_RSA_WITH_ARIA_256_GCM_SHA384", []string{"ARIA256-GCM-SHA384"}},
+	{0xC052, "TLS_DHE_RSA_WITH_ARIA_128_GCM_SHA256", []string{"DHE-RSA-ARIA128-GCM-SHA256"}},
+	{0xC053, "TLS_DHE_RSA_WITH_ARIA_256_GCM_SHA384", []string{"DHE-RSA-ARIA256-GCM-SHA384"}},
+	{0xC05C, "TLS_ECDHE_ECDSA_WITH_ARIA_128_GCM_SHA256", []string{"ECDHE-ECDSA-ARIA128-GCM-SHA256"}},
+	{0xC05D, "TLS_ECDHE_ECDSA_WITH_ARIA_256_GCM_SHA384", []string{"ECDHE-ECDSA-ARIA256-GCM-SHA384"}},
+	{0xC060, "TLS_ECDHE_RSA_WITH_ARIA_128_GCM_SHA256", []string{"ECDHE-ARIA128-GCM-SHA256"}},
+	{0xC061, "TLS_ECDHE_RSA_WITH_ARIA_256_GCM_SHA384", []string{"ECDHE-ARIA256-GCM-SHA384"}},
+	{0xC072, "TLS_ECDHE_ECDSA_WITH_CAMELLIA_128_CBC_SHA256", []string{"ECDHE-ECDSA-CAMELLIA128-SHA256"}},
+	{0xC073, "TLS_ECDHE_ECDSA_WITH_CAMELLIA_256_CBC_SHA384", []string{"ECDHE-ECDSA-CAMELLIA256-SHA384"}},
+	{0xC076, "TLS_ECDHE_RSA_WITH_CAMELLIA_128_CBC_SHA256", []string{"ECDHE-RSA-CAMELLIA128-SHA256"}},
+	{0xC077, "TLS_ECDHE_RSA_WITH_CAMELLIA_256_CBC_SHA384", []string{"ECDHE-RSA-CAMELLIA256-SHA384"}},
+	{0xC07A, "TLS_RSA_WITH_CAMELLIA_128_GCM_SHA256", nil},
+	{0xC07B, "TLS_RSA_WITH_CAMELLIA_256_GCM_SHA384", nil},
+	{0xC07C, "TLS_DHE_RSA_WITH_CAMELLIA_128_GCM_SHA256", nil},
+	{0xC07D, "TLS_DHE_RSA_WITH_CAMELLIA_256_GCM_SHA384", nil},
+	{0xC086, "TLS_ECDHE_ECDSA_WITH_CAMELLIA_128_GCM_SHA256", nil},
+	{0xC087, "TLS_ECDHE_ECDSA_WITH_CAMELLIA_256_GCM_SHA384", nil},
+	{0xC08A, "TLS_ECDHE_RSA_WITH_CAMELLIA_128_GCM_SHA256", nil},
+	{0xC08B, "TLS_ECDHE_RSA_WITH_CAMELLIA_256_GCM_SHA384", nil},
+	{0xC09C, "TLS_RSA_WITH_AES_128_CCM", []string{"AES128-CCM"}},
+	{0xC09D, "TLS_RSA_WITH_AES_256_CCM", []string{"AES256-CCM"}},
+	{0xC09E, "TLS_DHE_RSA_WITH_AES_128_CCM", []string{"DHE-RSA-AES128-CCM"}},
+	{0xC09F, "TLS_DHE_RSA_WITH_AES_256_CCM", []string{"DHE-RSA-AES256-CCM"}},
+	{0xC0A0, "TLS_RSA_WITH_AES_128_CCM_8", []string{"AES128-CCM8"}},
+	{0xC0A1, "TLS_RSA_WITH_AES_256_CCM_8", []string{"AES256-CCM8"}},
+	{0xC0A2, "TLS_DHE_RSA_WITH_AES_128_CCM_8", []string{"DHE-RSA-AES128-CCM8"}},
+	{0xC0A3, "TLS_DHE_RSA_WITH_AES_256_CCM_8", []string{"DHE-RSA-AES256-CCM8"}},
+	{0xC0A4, "TLS_PSK_WITH_AES_128_CCM", []string{"PSK-AES128-CCM"}},
+	{0xC0A5, "TLS_PSK_WITH_AES_256_CCM", []string{"PSK-AES256-CCM"}},
+	{0xC0A6, "TLS_DHE_PSK_WITH_AES_128_CCM", []string{"DHE-PSK-AES128-CCM"}},
+	{0xC0A7, "TLS_DHE_PSK_WITH_AES_256_CCM", []string{"DHE-PSK-AES256-CCM"}},
+	{0xC0A8, "TLS_PSK_WITH_AES_128_CCM_8", []string{"PSK-AES128-CCM8"}},
+	{0xC0A9, "TLS_PSK_WITH_AES_256_CCM_8", []string{"PSK-AES256-CCM8"}},
+	{0xC0AA, "TLS_PSK_DHE_WITH_AES_128_CCM_8", []string{"DHE-PSK-AES128-CCM8"}},
+	{0xC0AB, "TLS_PSK_DHE_WITH_AES_256_CCM_8", []string{"DHE-PSK-AES256-CCM8"}},
+	{0xC0AC, "TLS_ECDHE_ECDSA_WITH_AES_128_CCM", []string{"ECDHE-ECDSA-AES128-CCM"}},
+	{0xC0AD, "TLS_ECDHE_ECDSA_WITH_AES_256_CCM", []string{"ECDHE-ECDSA-AES256-CCM"}},
+	{0xC0AE, "TLS_ECDHE_ECDSA_WITH_AES_128_CCM_8", []string{"ECDHE-ECDSA-AES128-CCM8"}},
+	{0xC0AF, "TLS_ECDHE_ECDSA_WITH_AES_256_CCM_8", []string{"ECDHE-ECDSA-AES256-CCM8"}},
+	{0xC0B4, "TLS_SHA256_SHA256", nil},
+	{0xC0B5, "TLS_SHA384_SHA384", nil},
+	{0xCCA8, "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256", []string{"ECDHE-RSA-CHACHA20-POLY1305"}},
+	{0xCCA9, "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256", []string{"ECDHE-ECDSA-CHACHA20-POLY1305"}},
+	{0xCCAA, "TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256", []string{"DHE-RSA-CHACHA20-POLY1305"}},
+	{0xCCAB, "TLS_PSK_WITH_CHACHA20_POLY1305_SHA256", []string{"PSK-CHACHA20-POLY1305"}},
+	{0xCCAC, "TLS_ECDHE_PSK_WITH_CHACHA20_POLY1305_SHA256", []string{"ECDHE-PSK-CHACHA20-POLY1305"}},
+	{0xCCAD, "TLS_DHE_PSK_WITH_CHACHA20_POLY1305_SHA256", []string{"DHE-PSK-CHACHA20-POLY1305"}},
+	{0xCCAE, "TLS_RSA_PSK_WITH_CHACHA20_POLY1305_SHA256", []string{"RSA-PSK-CHACHA20-POLY1305"}},
+	{0xD001, "TLS_ECDHE_PSK_WITH_AES_128_GCM_SHA256", nil},
+	{0xD002, "TLS_ECDHE_PSK_WITH_AES_256_GCM_SHA384", nil},
+	{0xD003, "TLS_ECDHE_PSK_WITH_AES_128_CCM_8_SHA256", nil},
+	{0xD005, "TLS_ECDHE_PSK_WITH_AES_128_CCM_SHA256", nil},
+}
+
+// bulkCipher describes the cipher part of a suite name
+type bulkCipher struct {
+	cipher     string
+	mode       string
+	strength   int
+	aead       bool
+	fips       bool
+	deprecated bool
+}
+
+// bulkCiphers are keyed by their spelling in suite names
+var bulkCiphers = map[string]bulkCipher{
+	"NULL":              {cipher: "NULL", deprecated: true},
+	"RC4_40":            {cipher: "RC4", mode: "STREAM", deprecated: true},
+	"RC4_128":           {cipher: "RC4", mode: "STREAM", deprecated: true},
+	"RC2_CBC_40":        {cipher: "RC2", mode: "CBC", strength: 40, deprecated: true},
+	"DES40_CBC":         {cipher: "DES", mode: "CBC", strength: 40, deprecated: true},
+	"DES_CBC_40":        {cipher: "DES", mode: "CBC", strength: 40, deprecated: true},
+	"DES_CBC":           {cipher: "DES", mode: "CBC", strength: 56, deprecated: true},
+	"3DES_EDE_CBC":      {cipher: "3DES_EDE", mode: "CBC", strength: 112, deprecated: true},
+	"IDEA_CBC":          {cipher: "IDEA", mode: "CBC", strength: 128, deprecated: true},
+	"SEED_CBC":          {cipher: "SEED", mode: "CBC", strength: 128},
+	"AES_128_CBC":       {cipher: "AES_128", mode: "CBC", strength: 128, fips: true},
+	"AES_256_CBC":       {cipher: "AES_256", mode: "CBC", strength: 256, fips: true},
+	"AES_128_GCM":       {cipher: "AES_128", mode: "GCM", strength: 128, aead: true, fips: true},
+	"AES_256_GCM":       {cipher: "AES_256", mode: "GCM", strength: 256, aead: true, fips: true},
+	"AES_128_CCM":       {cipher: "AES_128", mode: "CCM", strength: 128, aead: true, fips: true},
+	"AES_256_CCM":       {cipher: "AES_256", mode: "CCM", strength: 256, aead: true, fips: true},
+	"AES_128_CCM_8":     {cipher: "AES_128", mode: "CCM_8", strength: 128, aead: true, fips: true},
+	"AES_256_CCM_8":     {cipher: "AES_256", mode: "CCM_8", strength: 256, aead: true, fips: true},
+	"CAMELLIA_128_CBC":  {cipher: "CAMELLIA_128", mode: "CBC", strength: 128},
+	"CAMELLIA_256_CBC":  {cipher: "CAMELLIA_256", mode: "CBC", strength: 256},
+	"CAMELLIA_128_GCM":  {cipher: "CAMELLIA_128", mode: "GCM", strength: 128, aead: true},
+	"CAMELLIA_256_GCM":  {cipher: "CAMELLIA_256", mode: "GCM", strength: 256, aead: true},
+	"ARIA_128_CBC":      {cipher: "ARIA_128", mode: "CBC", strength: 128},
+	"ARIA_256_CBC":      {cipher: "ARIA_256", mode: "CBC", strength: 256},
+	"ARIA_128_GCM":      {cipher: "ARIA_128", mode: "GCM", strength: 128, aead: true},
+	"ARIA_256_GCM":      {cipher: "ARIA_256", mode: "GCM", strength: 256, aead: true},
+	"CHACHA20_POLY1305": {cipher: "CHACHA20", mode: "POLY1305", strength: 256, aead: true},
+	"SM4_GCM":           {cipher: "SM4", mode: "GCM", strength: 128, aead: true},
+	"SM4_CCM":           {cipher: "SM4", mode: "CCM", strength: 128, aead: true},
+}
+
+// bulkCipherNames are the bulk cipher spellings, longest first so
+// AES_128_CCM_8 is not read as AES_128_CCM
+var bulkCipherNames = func() []string {
+	names := make([]string, 0, len(bulkCiphers))
+	for name := range bulkCiphers {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}()
+
+// keyExchanges maps the part of a suite name before _WITH_ to its key
+// exchange and authentication. Anonymous suites use ephemeral keys despite
+// their DH_anon and ECDH_anon names.
+var keyExchanges = map[string][2]string{
+	"NULL":        {"NULL", "NULL"},
+	"RSA":         {"RSA", "RSA"},
+	"DH_DSS":      {"DH", "DSS"},
+	"DH_RSA":      {"DH", "RSA"},
+	"DHE_DSS":     {"DHE", "DSS"},
+	"DHE_RSA":     {"DHE", "RSA"},
+	"DH_ANON":     {"DHE", "anon"},
+	"ECDH_ECDSA":  {"ECDH", "ECDSA"},
+	"ECDH_RSA":    {"ECDH", "RSA"},
+	"ECDHE_ECDSA": {"ECDHE", "ECDSA"},
+	"ECDHE_RSA":   {"ECDHE", "RSA"},
+	"ECDH_ANON":   {"ECDHE", "anon"},
+	"KRB5":        {"KRB5", "KRB5"},
+	"PSK":         {"PSK", "PSK"},
+	"DHE_PSK":     {"DHE", "PSK"},
+	"PSK_DHE":     {"DHE", "PSK"},
+	"RSA_PSK":     {"RSA", "PSK"},
+	"ECDHE_PSK":   {"ECDHE", "PSK"},
+	"SRP_SHA":     {"SRP", "SRP"},
+	"SRP_SHA_RSA": {"SRP", "RSA"},
+	"SRP_SHA_DSS": {"SRP", "DSS"},
+}
+
+// macNames are the MACs of suite names, keyed by their spelling
+var macNames = map[string]string{
+	"NULL":   "NULL",
+	"MD5":    "HMAC_MD5",
+	"SHA":    "HMAC_SHA1",
+	"SHA256": "HMAC_SHA256",
+	"SHA384": "HMAC_SHA384",
+}
+
+// prfHashes are the hashes AEAD suites name for their PRF
+var prfHashes = map[string]bool{"SHA256": true, "SHA384": true, "SM3": true}
+
+// Key exchanges and authentications of FIPS 140-3 approved suites; ANY is
+// the TLS 1.3 negotiation, judged by the named group and signature scheme
+var (
+	fipsKeyExchanges    = map[string]bool{"ANY": true, "RSA": true, "DH": true, "DHE": true, "ECDH": true, "ECDHE": true, "PSK": true}
+	fipsAuthentications = map[string]bool{"ANY": true, "RSA": true, "ECDSA": true, "PSK": true}
+)
+
+// Key exchanges and authentications Shor's algorithm breaks
+var (
+	classicalKeyExchanges    = map[string]bool{"RSA": true, "DH": true, "DHE": true, "ECDH": true, "ECDHE": true, "SRP": true}
+	classicalAuthentications = map[string]bool{"RSA": true, "DSS": true, "ECDSA": true}
+)
+
+func cipherSuites() []*Entry {
+	entries := make([]*Entry, 0, len(suites))
+	for _, s := range suites {
+		entry, ok := describeSuite(strings.ToUpper(s.name))
+		if !ok {
+			panic(fmt.Sprintf("algorithms: cannot decompose cipher suite %s", s.name))
+		}
+		entry.Name = s.name
+		entry.Codepoint = fmt.Sprintf("0x%02X,0x%02X", s.codepoint>>8, s.codepoint&0xFF)
+		entry.Aliases = s.openssl
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// describeSuite decomposes an uppercase IANA cipher suite name: the TLS 1.2
+// form TLS_<key exchange>_WITH_<cipher>_<MAC> and the TLS 1.3 form
+// TLS_<AEAD>_<hash>, which leaves the key exchange to the named group
+func describeSuite(name string) (*Entry, bool) {
+	if !strings.HasPrefix(name, "TLS_") {
+		return nil, false
+	}
+	body := name[len("TLS_"):]
+
+	entry := &Entry{Kind: KindTLSCipherSuite, Name: name}
+	export := false
+	cipherPart := body
+	if exchange, rest, found := strings.Cut(body, "_WITH_"); found {
+		for _, suffix := range []string{"_EXPORT1024", "_EXPORT"} {
+			if strings.HasSuffix(exchange, suffix) {
+				exchange, export = strings.TrimSuffix(exchange, suffix), true
+				break
+			}
+		}
+		parts, ok := keyExchanges[exchange]
+		if !ok {
+			return nil, false
+		}
+		entry.KeyExchange, entry.Authentication = parts[0], parts[1]
+		cipherPart = rest
+	} else {
+		// TLS 1.3 suites name only the AEAD and the HKDF hash
+		entry.KeyExchange, entry.Authentication = "ANY", "ANY"
+		if hash, integrityOnly := map[string]string{
+			"SHA256_SHA256": "SHA256", "SHA384_SHA384": "SHA384",
+		}[body]; integrityOnly {
+			entry.BulkCipher, entry.MAC, entry.PRF = "NULL", "HMAC_"+hash, hash
+			return entry, true
+		}
+	}
+
+	var bulk bulkCipher
+	var mac string
+	found := false
+	for _, candidate := range bulkCipherNames {
+		if cipherPart == candidate {
+			bulk, found = bulkCiphers[candidate], true
+			break
+		}
+		if strings.HasPrefix(cipherPart, candidate+"_") {
+			bulk, mac, found = bulkCiphers[candidate], cipherPart[len(candidate)+1:], true
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	tls13 := entry.KeyExchange == "ANY"
+
+	entry.BulkCipher, entry.Mode = bulk.cipher, bulk.mode
+	switch {
+	case bulk.aead:
+		// AEAD suites name the PRF hash; CCM suites of RFC 6655 default
+		// to SHA-256
+		entry.MAC, entry.PRF = "AEAD", mac
+		if mac == "" {
+			entry.PRF = "SHA256"
+		}
+		if !prfHashes[entry.PRF] {
+			return nil, false
+		}
+	case tls13:
+		return nil, false
+	default:
+		hmac, ok := macNames[mac]
+		if !ok {
+			return nil, false
+		}
+		entry.MAC, entry.PRF = hmac, "SHA256"
+		if mac == "SHA384" {
+			entry.PRF = "SHA384"
+		}
+	}
+
+	anonymous := entry.Authentication == "anon" || entry.KeyExchange == "NULL"
+	entry.StrengthBits = bulk.strength
+	switch {
+	case anonymous:
+		entry.StrengthBits = 0
+	case export && entry.StrengthBits > 40:
+		entry.StrengthBits = 40
+	}
+	entry.Deprecated = bulk.deprecated || export || anonymous || entry.MAC == "HMAC_MD5"
+	entry.FIPSApproved = bulk.fips && !export && !anonymous &&
+		entry.MAC != "HMAC_MD5" && entry.PRF != "SM3" &&
+		fipsKeyExchanges[entry.KeyExchange] && fipsAuthentications[entry.Authentication]
+	// CNSA 2.0 allows only TLS 1.3 with AES-256-GCM and SHA-384, the key
+	// exchange and signatures being ML-KEM-1024 and ML-DSA-87
+	entry.CNSA2 = tls13 && bulk.cipher == "AES_256" && bulk.mode == "GCM" && entry.PRF == "SHA384"
+	entry.QuantumVulnerable = classicalKeyExchanges[entry.KeyExchange] || classicalAuthentications[entry.Authentication]
+	return entry, true
+}
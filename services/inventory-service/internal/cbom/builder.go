@@ -0,0 +1,561 @@
+package cbom
+
+import (
+	"inventory-service/internal/algorithms"
+	"inventory-service/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Algorithm primitives of CycloneDX
+const (
+	PrimitiveAE           = "ae"
+	PrimitiveBlockCipher  = "block-cipher"
+	PrimitiveStreamCipher = "stream-cipher"
+	PrimitiveHash         = "hash"
+	PrimitiveMAC          = "mac"
+	PrimitiveSignature    = "signature"
+	PrimitiveKeyAgree     = "key-agree"
+	PrimitiveKEM          = "kem"
+	PrimitivePKE          = "pke"
+)
+
+// Asset is a network asset with the endpoints its crypto implementations
+// were found on
+type Asset struct {
+	models.Asset
+	Endpoints []Endpoint
+}
+
+// Endpoint is a crypto implementation with the port offering it
+type Endpoint struct {
+	models.CryptoImplementation
+	Port *int
+}
+
+// AssetRef, ProtocolRef, CertificateRef and KeyRef are the bom-refs of the
+// components of inventory records
+func AssetRef(id uuid.UUID) string       { return "asset:" + id.String() }
+func ProtocolRef(id uuid.UUID) string    { return "protocol:" + id.String() }
+func CertificateRef(id uuid.UUID) string { return "certificate:" + id.String() }
+func KeyRef(id uuid.UUID) string         { return "key:" + id.String() }
+
+// Builder turns inventory records into CBOM components and dependencies.
+// Algorithms are shared by the implementations and certificates using them:
+// Algorithms returns each once, however often it was referenced.
+type Builder struct {
+	algorithms map[string]*Component
+	order      []string
+}
+
+func NewBuilder() *Builder {
+	return &Builder{algorithms: make(map[string]*Component)}
+}
+
+// Algorithms returns the algorithm components referenced so far, in the
+// order they were first referenced
+func (b *Builder) Algorithms() []Component {
+	components := make([]Component, 0, len(b.order))
+	for _, ref := range b.order {
+		components = append(components, *b.algorithms[ref])
+	}
+	return components
+}
+
+// AssetComponents returns the device component of an asset followed by a
+// protocol component per endpoint
+func (b *Builder) AssetComponents(asset *Asset) []Component {
+	components := []Component{deviceComponent(asset)}
+	for i := range asset.Endpoints {
+		protocol, _ := b.protocol(&asset.Endpoints[i])
+		components = append(components, protocol)
+	}
+	return components
+}
+
+// AssetDependencies returns the dependencies of an asset on its protocols
+// and of each protocol on its algorithms and certificate
+func (b *Builder) AssetDependencies(asset *Asset) []Dependency {
+	device := Dependency{Ref: AssetRef(asset.ID), DependsOn: []string{}}
+	dependencies := make([]Dependency, 1, len(asset.Endpoints)+1)
+	for i := range asset.Endpoints {
+		protocol, uses := b.protocol(&asset.Endpoints[i])
+		device.DependsOn = append(device.DependsOn, protocol.BOMRef)
+		dependencies = append(dependencies, Dependency{Ref: protocol.BOMRef, DependsOn: uses})
+	}
+	dependencies[0] = device
+	return dependencies
+}
+
+// CertificateComponents returns the component of a certificate and, when
+// its key algorithm is known, of its public key
+func (b *Builder) CertificateComponents(certificate *models.Certificate) []Component {
+	components, _ := b.certificate(certificate)
+	return components
+}
+
+// CertificateDependencies returns the dependencies of a certificate on its
+// signature algorithm and public key, and of the key on its algorithm
+func (b *Builder) CertificateDependencies(certificate *models.Certificate) []Dependency {
+	_, dependencies := b.certificate(certificate)
+	return dependencies
+}
+
+func deviceComponent(asset *Asset) Component {
+	name := ""
+	switch {
+	case asset.Hostname != nil:
+		name = *asset.Hostname
+	case asset.IPAddress != nil:
+		name = *asset.IPAddress
+	}
+	component := Component{Type: ComponentDevice, BOMRef: AssetRef(asset.ID), Name: name}
+	if asset.Description != nil {
+		component.Description = *asset.Description
+	}
+	component.Properties = inventoryProperties(
+		"asset_id", asset.ID.String(),
+		"hostname", text(asset.Hostname),
+		"ip_address", text(asset.IPAddress),
+		"port", number(asset.Port),
+		"asset_type", asset.AssetType,
+		"operating_system", text(asset.OperatingSystem),
+		"environment", text(asset.Environment),
+		"business_unit", text(asset.BusinessUnit),
+		"owner_email", text(asset.OwnerEmail),
+	)
+	return component
+}
+
+// protocol returns the protocol component of an endpoint and the refs of
+// the algorithms and certificate it uses
+func (b *Builder) protocol(endpoint *Endpoint) (Component, []string) {
+	implementation := &endpoint.CryptoImplementation
+	name := implementation.Protocol
+	if implementation.ProtocolVersion != nil {
+		name += " " + *implementation.ProtocolVersion
+	}
+	properties := &ProtocolProperties{Type: protocolType(implementation.Protocol), Version: text(implementation.ProtocolVersion)}
+
+	var uses []string
+	if implementation.CipherSuite != nil {
+		suite := b.cipherSuite(implementation.Protocol, *implementation.CipherSuite)
+		properties.CipherSuites = []CipherSuite{suite}
+		uses = append(uses, suite.Algorithms...)
+	}
+	for _, ref := range b.implementationAlgorithms(implementation) {
+		properties.CryptoRefArray = appendUnique(properties.CryptoRefArray, ref)
+	}
+	if implementation.CertificateID != nil {
+		properties.CryptoRefArray = appendUnique(properties.CryptoRefArray, CertificateRef(*implementation.CertificateID))
+	}
+	for _, ref := range properties.CryptoRefArray {
+		uses = appendUnique(uses, ref)
+	}
+
+	component := Component{
+		Type:             ComponentCryptographicAsset,
+		BOMRef:           ProtocolRef(implementation.ID),
+		Name:             name,
+		CryptoProperties: &CryptoProperties{AssetType: AssetTypeProtocol, ProtocolProperties: properties},
+		Properties: inventoryProperties(
+			"crypto_implementation_id", implementation.ID.String(),
+			"port", number(endpoint.Port),
+			"discovery_method", implementation.DiscoveryMethod,
+			"confidence_score", strconv.FormatFloat(implementation.ConfidenceScore, 'f', -1, 64),
+			"risk_score", strconv.Itoa(implementation.RiskScore),
+		),
+	}
+	if uses == nil {
+		uses = []string{}
+	}
+	return component, uses
+}
+
+// protocolType maps an inventory protocol to a CycloneDX protocol type
+func protocolType(protocol string) string {
+	switch protocol {
+	case "TLS":
+		return "tls"
+	case "SSH":
+		return "ssh"
+	case "IPSec":
+		return "ipsec"
+	}
+	return "other"
+}
+
+// cipherSuite describes a negotiated cipher suite. TLS suites the
+// knowledge base decomposes list the algorithms they are made of and their
+// codepoint; other suites are the cipher they name.
+func (b *Builder) cipherSuite(protocol, name string) CipherSuite {
+	suite := CipherSuite{Name: name}
+	if protocol == "SSH" {
+		entry, ok := algorithms.Lookup(name, algorithms.KindSSHCipher)
+		suite.Algorithms = []string{b.symmetric(name, entry, ok)}
+		return suite
+	}
+
+	entry, ok := algorithms.Lookup(name, algorithms.KindTLSCipherSuite)
+	if !ok {
+		return suite
+	}
+	suite.Name = entry.Name
+	if entry.Codepoint != "" {
+		suite.Identifiers = strings.Split(entry.Codepoint, ",")
+	}
+
+	quantumBroken := 0
+	switch entry.KeyExchange {
+	case "RSA":
+		suite.Algorithms = append(suite.Algorithms, b.algorithm(PrimitivePKE, "RSA", &AlgorithmProperties{NISTQuantumSecurityLevel: &quantumBroken}, nil))
+	case "DH", "DHE", "ECDH", "ECDHE", "SRP":
+		suite.Algorithms = append(suite.Algorithms, b.algorithm(PrimitiveKeyAgree, entry.KeyExchange, &AlgorithmProperties{NISTQuantumSecurityLevel: &quantumBroken}, nil))
+	}
+	switch entry.Authentication {
+	case "RSA", "ECDSA":
+		suite.Algorithms = append(suite.Algorithms, b.algorithm(PrimitiveSignature, entry.Authentication, &AlgorithmProperties{NISTQuantumSecurityLevel: &quantumBroken}, nil))
+	case "DSS":
+		suite.Algorithms = append(suite.Algorithms, b.algorithm(PrimitiveSignature, "DSA", &AlgorithmProperties{NISTQuantumSecurityLevel: &quantumBroken}, nil))
+	}
+	if entry.BulkCipher != "" && entry.BulkCipher != "NULL" {
+		name := strings.ReplaceAll(entry.BulkCipher, "_", "-")
+		if entry.Mode != "" && entry.Mode != "STREAM" {
+			name += "-" + strings.ReplaceAll(entry.Mode, "_", "-")
+		}
+		primitive := PrimitiveBlockCipher
+		switch {
+		case entry.MAC == "AEAD":
+			primitive = PrimitiveAE
+		case entry.Mode == "STREAM":
+			primitive = PrimitiveStreamCipher
+		}
+		properties := &AlgorithmProperties{Mode: cipherMode(entry.Mode)}
+		// An anonymous suite's strength is that of its missing authentication
+		if entry.Authentication != "anon" && entry.KeyExchange != "NULL" {
+			properties.ClassicalSecurityLevel = intPointer(entry.StrengthBits)
+			properties.NISTQuantumSecurityLevel = symmetricQuantumLevel(entry.StrengthBits)
+		}
+		suite.Algorithms = append(suite.Algorithms, b.algorithm(primitive, name, properties, nil))
+	}
+	if strings.HasPrefix(entry.MAC, "HMAC_") {
+		suite.Algorithms = append(suite.Algorithms, b.algorithm(PrimitiveMAC, strings.ReplaceAll(entry.MAC, "_", "-"), &AlgorithmProperties{}, nil))
+	}
+	if entry.PRF != "" {
+		hash, ok := algorithms.Lookup(entry.PRF, algorithms.KindHash)
+		suite.Algorithms = append(suite.Algorithms, b.catalogAlgorithm(PrimitiveHash, entry.PRF, hash, ok))
+	}
+	return suite
+}
+
+// implementationAlgorithms returns the refs of the algorithms an
+// implementation records beside its cipher suite
+func (b *Builder) implementationAlgorithms(implementation *models.CryptoImplementation) []string {
+	var kinds map[string][]string
+	switch implementation.Protocol {
+	case "SSH":
+		kinds = map[string][]string{"kex": {algorithms.KindSSHKex}, "signature": {algorithms.KindSSHHostKey}}
+	case "TLS":
+		kinds = map[string][]string{"kex": {algorithms.KindNamedGroup}, "signature": {algorithms.KindSignatureScheme}}
+	default:
+		kinds = map[string][]string{
+			"kex":       {algorithms.KindNamedGroup, algorithms.KindSSHKex},
+			"signature": {algorithms.KindSignatureScheme, algorithms.KindSSHHostKey},
+		}
+	}
+
+	var refs []string
+	if name := implementation.KeyExchangeAlgorithm; name != nil {
+		entry, ok := algorithms.Lookup(*name, kinds["kex"]...)
+		primitive := PrimitiveKeyAgree
+		if normalized := strings.ToUpper(entry.Name + *name); strings.Contains(normalized, "MLKEM") || strings.Contains(normalized, "ML-KEM") || strings.Contains(normalized, "KYBER") {
+			primitive = PrimitiveKEM
+		}
+		refs = append(refs, b.catalogAlgorithm(primitive, *name, entry, ok))
+	}
+	if name := implementation.SignatureAlgorithm; name != nil {
+		entry, ok := algorithms.Lookup(*name, kinds["signature"]...)
+		refs = append(refs, b.catalogAlgorithm(PrimitiveSignature, *name, entry, ok))
+	}
+	if name := implementation.SymmetricEncryption; name != nil {
+		entry, ok := algorithms.Lookup(*name, algorithms.KindSSHCipher)
+		refs = append(refs, b.symmetric(*name, entry, ok))
+	}
+	if name := implementation.HashAlgorithm; name != nil {
+		entry, ok := algorithms.Lookup(*name, algorithms.KindHash)
+		refs = append(refs, b.catalogAlgorithm(PrimitiveHash, *name, entry, ok))
+	}
+	return refs
+}
+
+// symmetric adds a symmetric cipher named outside of a TLS cipher suite,
+// judging its primitive and mode from the name
+func (b *Builder) symmetric(name string, entry algorithms.Entry, ok bool) string {
+	lower := strings.ToLower(name)
+	primitive := PrimitiveBlockCipher
+	switch {
+	case strings.Contains(lower, "gcm"), strings.Contains(lower, "ccm"), strings.Contains(lower, "poly1305"):
+		primitive = PrimitiveAE
+	case strings.Contains(lower, "rc4"), strings.Contains(lower, "arcfour"):
+		primitive = PrimitiveStreamCipher
+	}
+	properties := &AlgorithmProperties{}
+	for _, mode := range []string{"gcm", "ccm", "cbc", "ctr", "cfb", "ofb", "ecb"} {
+		if strings.Contains(lower, mode) {
+			properties.Mode = mode
+			break
+		}
+	}
+	if ok {
+		name = entry.Name
+		properties.ClassicalSecurityLevel = intPointer(entry.StrengthBits)
+		properties.NISTQuantumSecurityLevel = symmetricQuantumLevel(entry.StrengthBits)
+	}
+	return b.algorithm(primitive, name, properties, flags(entry, ok))
+}
+
+// catalogAlgorithm adds an algorithm described by its knowledge base entry
+// when it resolved, and by name only otherwise. Shor's algorithm breaks
+// the quantum-vulnerable ones, NIST quantum security level 0.
+func (b *Builder) catalogAlgorithm(primitive, name string, entry algorithms.Entry, ok bool) string {
+	properties := &AlgorithmProperties{}
+	if ok {
+		name = entry.Name
+		if entry.StrengthBits > 0 || entry.Deprecated {
+			properties.ClassicalSecurityLevel = intPointer(entry.StrengthBits)
+		}
+		if entry.QuantumVulnerable {
+			properties.NISTQuantumSecurityLevel = intPointer(0)
+		}
+	}
+	return b.algorithm(primitive, name, properties, flags(entry, ok))
+}
+
+// algorithm adds an algorithm component unless one with the same primitive
+// and name was added before, and returns its ref
+func (b *Builder) algorithm(primitive, name string, properties *AlgorithmProperties, extra []Property) string {
+	ref := "algorithm:" + primitive + ":" + name
+	if _, exists := b.algorithms[ref]; exists {
+		return ref
+	}
+	properties.Primitive = primitive
+	properties.CryptoFunctions = cryptoFunctions[primitive]
+	b.algorithms[ref] = &Component{
+		Type:             ComponentCryptographicAsset,
+		BOMRef:           ref,
+		Name:             name,
+		CryptoProperties: &CryptoProperties{AssetType: AssetTypeAlgorithm, AlgorithmProperties: properties},
+		Properties:       extra,
+	}
+	b.order = append(b.order, ref)
+	return ref
+}
+
+// cryptoFunctions are what each primitive is used for
+var cryptoFunctions = map[string][]string{
+	PrimitiveAE:           {"encrypt", "decrypt", "tag"},
+	PrimitiveBlockCipher:  {"encrypt", "decrypt"},
+	PrimitiveStreamCipher: {"encrypt", "decrypt"},
+	PrimitiveHash:         {"digest"},
+	PrimitiveMAC:          {"tag"},
+	PrimitiveSignature:    {"sign", "verify"},
+	PrimitiveKeyAgree:     {"keygen", "keyderive"},
+	PrimitiveKEM:          {"keygen", "encapsulate", "decapsulate"},
+	PrimitivePKE:          {"encrypt", "decrypt"},
+}
+
+// cipherMode maps a suite's mode to a CycloneDX block cipher mode
+func cipherMode(mode string) string {
+	switch mode {
+	case "CBC":
+		return "cbc"
+	case "GCM":
+		return "gcm"
+	case "CCM", "CCM_8":
+		return "ccm"
+	}
+	return ""
+}
+
+// symmetricQuantumLevel is the NIST post-quantum security category a
+// symmetric cipher of the given strength meets: categories 1, 3 and 5 are
+// as hard to break as AES-128, AES-192 and AES-256
+func symmetricQuantumLevel(strength int) *int {
+	switch {
+	case strength >= 256:
+		return intPointer(5)
+	case strength >= 192:
+		return intPointer(3)
+	case strength >= 128:
+		return intPointer(1)
+	}
+	return intPointer(0)
+}
+
+// flags are the knowledge base's judgement of an algorithm as properties
+func flags(entry algorithms.Entry, ok bool) []Property {
+	if !ok {
+		return nil
+	}
+	return inventoryProperties(
+		"deprecated", strconv.FormatBool(entry.Deprecated),
+		"fips_approved", strconv.FormatBool(entry.FIPSApproved),
+		"cnsa2_compliant", strconv.FormatBool(entry.CNSA2),
+	)
+}
+
+// certificate returns the components of a certificate and its public key
+// and their dependencies
+func (b *Builder) certificate(certificate *models.Certificate) ([]Component, []Dependency) {
+	name := certificate.SubjectDN
+	if certificate.CommonName != nil {
+		name = *certificate.CommonName
+	}
+	properties := &CertificateProperties{
+		SubjectName:       certificate.SubjectDN,
+		IssuerName:        certificate.IssuerDN,
+		NotValidBefore:    timestamp(certificate.NotBefore),
+		NotValidAfter:     timestamp(certificate.NotAfter),
+		CertificateFormat: "X.509",
+	}
+	component := Component{
+		Type:             ComponentCryptographicAsset,
+		BOMRef:           CertificateRef(certificate.ID),
+		Name:             name,
+		Hashes:           []Hash{{Algorithm: "SHA-256", Content: strings.ToLower(certificate.FingerprintSHA256)}},
+		CryptoProperties: &CryptoProperties{AssetType: AssetTypeCertificate, CertificateProperties: properties},
+		Properties: inventoryProperties(
+			"certificate_id", certificate.ID.String(),
+			"serial_number", text(certificate.SerialNumber),
+			"status", certificate.Status,
+			"is_self_signed", strconv.FormatBool(certificate.IsSelfSigned),
+			"is_ca_certificate", strconv.FormatBool(certificate.IsCA),
+		),
+	}
+	if certificate.FingerprintSHA1 != nil {
+		component.Hashes = append(component.Hashes, Hash{Algorithm: "SHA-1", Content: strings.ToLower(*certificate.FingerprintSHA1)})
+	}
+
+	dependency := Dependency{Ref: component.BOMRef, DependsOn: []string{}}
+	if certificate.SignatureAlgorithm != nil {
+		entry, ok := algorithms.Lookup(*certificate.SignatureAlgorithm, algorithms.KindSignatureScheme)
+		properties.SignatureAlgorithmRef = b.catalogAlgorithm(PrimitiveSignature, *certificate.SignatureAlgorithm, entry, ok)
+		dependency.DependsOn = append(dependency.DependsOn, properties.SignatureAlgorithmRef)
+	}
+	if certificate.PublicKeyAlgorithm == nil {
+		return []Component{component}, []Dependency{dependency}
+	}
+
+	keyAlgorithm := b.publicKeyAlgorithm(*certificate.PublicKeyAlgorithm, certificate.PublicKeySize)
+	key := Component{
+		Type:   ComponentCryptographicAsset,
+		BOMRef: KeyRef(certificate.ID),
+		Name:   *certificate.PublicKeyAlgorithm + " public key of " + name,
+		CryptoProperties: &CryptoProperties{
+			AssetType: AssetTypeRelatedCryptoMaterial,
+			RelatedCryptoMaterialProperties: &RelatedCryptoMaterialProperties{
+				Type:         "public-key",
+				ID:           text(certificate.SubjectKeyID),
+				AlgorithmRef: keyAlgorithm,
+				Size:         certificate.PublicKeySize,
+			},
+		},
+	}
+	properties.SubjectPublicKeyRef = key.BOMRef
+	dependency.DependsOn = append(dependency.DependsOn, key.BOMRef)
+	return []Component{component, key}, []Dependency{dependency, {Ref: key.BOMRef, DependsOn: []string{keyAlgorithm}}}
+}
+
+// publicKeyAlgorithm adds the algorithm of a certificate key with its size
+// as parameter set, RSA-2048 say, rated per NIST SP 800-57
+func (b *Builder) publicKeyAlgorithm(name string, size *int) string {
+	upper := strings.ToUpper(name)
+	primitive := PrimitiveSignature
+	switch {
+	case strings.Contains(upper, "ML-KEM"), strings.Contains(upper, "MLKEM"):
+		primitive = PrimitiveKEM
+	case strings.Contains(upper, "X25519"), strings.Contains(upper, "X448"), upper == "DH", upper == "ECDH":
+		primitive = PrimitiveKeyAgree
+	}
+
+	properties := &AlgorithmProperties{}
+	classical := strings.Contains(upper, "RSA") || strings.Contains(upper, "DSA") || strings.Contains(upper, "EC") ||
+		strings.Contains(upper, "ED25519") || strings.Contains(upper, "ED448") || primitive == PrimitiveKeyAgree
+	if classical {
+		properties.NISTQuantumSecurityLevel = intPointer(0)
+	}
+	if size != nil {
+		properties.ParameterSetIdentifier = strconv.Itoa(*size)
+		name += "-" + properties.ParameterSetIdentifier
+		properties.ClassicalSecurityLevel = keyStrength(upper, *size)
+	}
+	return b.algorithm(primitive, name, properties, nil)
+}
+
+// keyStrength is the security strength of a public key: finite field and
+// RSA keys per NIST SP 800-57 table 2, elliptic curve keys half their size
+func keyStrength(algorithm string, size int) *int {
+	switch {
+	case strings.Contains(algorithm, "EC"), strings.Contains(algorithm, "ED25519"), strings.Contains(algorithm, "ED448"):
+		return intPointer(size / 2)
+	case strings.Contains(algorithm, "RSA"), strings.Contains(algorithm, "DSA"), algorithm == "DH":
+		for _, level := range []struct{ size, strength int }{
+			{15360, 256}, {7680, 192}, {3072, 128}, {2048, 112}, {1024, 80},
+		} {
+			if size >= level.size {
+				return intPointer(level.strength)
+			}
+		}
+		return intPointer(0)
+	}
+	return nil
+}
+
+// inventoryProperties builds the inventory properties of a component from
+// name and value pairs, leaving out empty values
+func inventoryProperties(pairs ...string) []Property {
+	var result []Property
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			result = append(result, Property{Name: PropertyPrefix + pairs[i], Value: pairs[i+1]})
+		}
+	}
+	return result
+}
+
+func text(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func number(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func timestamp(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
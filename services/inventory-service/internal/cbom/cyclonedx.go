@@ -0,0 +1,184 @@
+// Package cbom reads and writes Cryptography Bills of Materials: CycloneDX
+// 1.6 documents whose cryptographic-asset components describe the
+// algorithms, protocols, certificates and keys in use, and whose
+// dependencies tie them to the network assets using them.
+package cbom
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Document format of the exported CBOM
+const (
+	BOMFormat   = "CycloneDX"
+	SpecVersion = "1.6"
+	MediaType   = "application/vnd.cyclonedx+json; version=1.6"
+	schemaURL   = "http://cyclonedx.org/schema/bom-1.6.schema.json"
+)
+
+// Component types
+const (
+	ComponentDevice             = "device"
+	ComponentCryptographicAsset = "cryptographic-asset"
+)
+
+// Asset types of cryptographic-asset components
+const (
+	AssetTypeAlgorithm             = "algorithm"
+	AssetTypeCertificate           = "certificate"
+	AssetTypeProtocol              = "protocol"
+	AssetTypeRelatedCryptoMaterial = "related-crypto-material"
+)
+
+// PropertyPrefix namespaces the properties the inventory adds to
+// components, such as crypto-inventory:ip_address
+const PropertyPrefix = "crypto-inventory:"
+
+// BOM is a CycloneDX document. Only the parts the inventory writes or reads
+// are modelled; anything else in an imported document is ignored.
+type BOM struct {
+	Schema       string       `json:"$schema,omitempty"`
+	BOMFormat    string       `json:"bomFormat"`
+	SpecVersion  string       `json:"specVersion"`
+	SerialNumber string       `json:"serialNumber,omitempty"`
+	Version      int          `json:"version"`
+	Metadata     *Metadata    `json:"metadata,omitempty"`
+	Components   []Component  `json:"components,omitempty"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// Metadata describes when and by what a document was produced
+type Metadata struct {
+	Timestamp  string     `json:"timestamp,omitempty"`
+	Tools      *Tools     `json:"tools,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+// Tools lists the software that produced a document
+type Tools struct {
+	Components []Component `json:"components,omitempty"`
+}
+
+// UnmarshalJSON also accepts the tool array of CycloneDX 1.4, which is
+// dropped
+func (t *Tools) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return nil
+	}
+	type tools Tools
+	return json.Unmarshal(data, (*tools)(t))
+}
+
+// Component is a network asset or a cryptographic asset. Nested components
+// are only read.
+type Component struct {
+	Type             string            `json:"type"`
+	BOMRef           string            `json:"bom-ref,omitempty"`
+	Name             string            `json:"name"`
+	Version          string            `json:"version,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	Hashes           []Hash            `json:"hashes,omitempty"`
+	CryptoProperties *CryptoProperties `json:"cryptoProperties,omitempty"`
+	Properties       []Property        `json:"properties,omitempty"`
+	Components       []Component       `json:"components,omitempty"`
+}
+
+// Property returns the value of the component's property with the given
+// name, or "" when it has none
+func (c *Component) Property(name string) string {
+	for _, property := range c.Properties {
+		if property.Name == name {
+			return property.Value
+		}
+	}
+	return ""
+}
+
+// Hash returns the component's hash computed with alg, such as SHA-256, or
+// "" when it has none
+func (c *Component) Hash(alg string) string {
+	for _, hash := range c.Hashes {
+		if hash.Algorithm == alg {
+			return hash.Content
+		}
+	}
+	return ""
+}
+
+// Hash is a digest of a component
+type Hash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// Property is a name-value pair attached to a component or document
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CryptoProperties describe a cryptographic asset; the properties of its
+// asset type are set
+type CryptoProperties struct {
+	AssetType                       string                           `json:"assetType"`
+	AlgorithmProperties             *AlgorithmProperties             `json:"algorithmProperties,omitempty"`
+	CertificateProperties           *CertificateProperties           `json:"certificateProperties,omitempty"`
+	RelatedCryptoMaterialProperties *RelatedCryptoMaterialProperties `json:"relatedCryptoMaterialProperties,omitempty"`
+	ProtocolProperties              *ProtocolProperties              `json:"protocolProperties,omitempty"`
+	OID                             string                           `json:"oid,omitempty"`
+}
+
+// AlgorithmProperties describe a cryptographic algorithm
+type AlgorithmProperties struct {
+	Primitive                string   `json:"primitive,omitempty"`
+	ParameterSetIdentifier   string   `json:"parameterSetIdentifier,omitempty"`
+	Mode                     string   `json:"mode,omitempty"`
+	CryptoFunctions          []string `json:"cryptoFunctions,omitempty"`
+	ClassicalSecurityLevel   *int     `json:"classicalSecurityLevel,omitempty"`
+	NISTQuantumSecurityLevel *int     `json:"nistQuantumSecurityLevel,omitempty"`
+}
+
+// CertificateProperties describe a certificate; the references point to
+// the components of its signature algorithm and public key
+type CertificateProperties struct {
+	SubjectName           string `json:"subjectName,omitempty"`
+	IssuerName            string `json:"issuerName,omitempty"`
+	NotValidBefore        string `json:"notValidBefore,omitempty"`
+	NotValidAfter         string `json:"notValidAfter,omitempty"`
+	SignatureAlgorithmRef string `json:"signatureAlgorithmRef,omitempty"`
+	SubjectPublicKeyRef   string `json:"subjectPublicKeyRef,omitempty"`
+	CertificateFormat     string `json:"certificateFormat,omitempty"`
+	CertificateExtension  string `json:"certificateExtension,omitempty"`
+}
+
+// RelatedCryptoMaterialProperties describe a key or other material
+type RelatedCryptoMaterialProperties struct {
+	Type         string `json:"type,omitempty"`
+	ID           string `json:"id,omitempty"`
+	AlgorithmRef string `json:"algorithmRef,omitempty"`
+	Size         *int   `json:"size,omitempty"`
+	Format       string `json:"format,omitempty"`
+}
+
+// ProtocolProperties describe a protocol as an endpoint offers it
+type ProtocolProperties struct {
+	Type           string        `json:"type,omitempty"`
+	Version        string        `json:"version,omitempty"`
+	CipherSuites   []CipherSuite `json:"cipherSuites,omitempty"`
+	CryptoRefArray []string      `json:"cryptoRefArray,omitempty"`
+}
+
+// CipherSuite is a cipher suite of a protocol with the algorithms it is
+// made of and its codepoint bytes
+type CipherSuite struct {
+	Name        string   `json:"name,omitempty"`
+	Algorithms  []string `json:"algorithms,omitempty"`
+	Identifiers []string `json:"identifiers,omitempty"`
+}
+
+// Dependency lists the components a component uses
+type Dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
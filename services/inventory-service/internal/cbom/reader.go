@@ -0,0 +1,406 @@
+package cbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"inventory-service/internal/algorithms"
+	"inventory-service/internal/models"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// specVersions are the CycloneDX versions an import reads. Cryptographic
+// assets are standard since 1.6; earlier versions carry them as the
+// cryptoProperties extension of the CBOM proposal.
+var specVersions = []string{"1.4", "1.5", "1.6"}
+
+var (
+	sha256Pattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	sha1Pattern   = regexp.MustCompile(`^[a-f0-9]{40}$`)
+)
+
+// Read decodes a CycloneDX JSON document
+func Read(in io.Reader) (*BOM, error) {
+	var bom BOM
+	if err := json.NewDecoder(in).Decode(&bom); err != nil {
+		return nil, fmt.Errorf("invalid CycloneDX JSON: %w", err)
+	}
+	if bom.BOMFormat != BOMFormat {
+		return nil, fmt.Errorf("bomFormat must be %s", BOMFormat)
+	}
+	supported := false
+	for _, version := range specVersions {
+		supported = supported || bom.SpecVersion == version
+	}
+	if !supported {
+		return nil, fmt.Errorf("specVersion must be one of %s", strings.Join(specVersions, ", "))
+	}
+	return &bom, nil
+}
+
+// Plan is what an imported CBOM holds for the inventory: the network
+// assets with the implementations of the protocols they depend on,
+// implementations of protocols no asset depends on, and certificates
+type Plan struct {
+	Assets       []PlannedAsset
+	Unattached   []PlannedImplementation
+	Certificates []PlannedCertificate
+	Skipped      []models.CBOMSkippedComponent
+}
+
+// PlannedAsset is a device component as an asset
+type PlannedAsset struct {
+	Ref             string
+	Input           models.AssetInput
+	Implementations []PlannedImplementation
+}
+
+// PlannedImplementation is a protocol component, or one of its cipher
+// suites, as a crypto implementation. CertificateRef is the bom-ref of the
+// certificate it presents.
+type PlannedImplementation struct {
+	Ref            string
+	Input          models.CryptoImplementationInput
+	CertificateRef string
+}
+
+// PlannedCertificate is a certificate component as an inventory certificate
+type PlannedCertificate struct {
+	Ref                string
+	SubjectDN          string
+	IssuerDN           string
+	CommonName         *string
+	SerialNumber       *string
+	SignatureAlgorithm *string
+	PublicKeyAlgorithm *string
+	PublicKeySize      *int
+	NotBefore          *time.Time
+	NotAfter           *time.Time
+	FingerprintSHA256  string
+	FingerprintSHA1    *string
+}
+
+// NewPlan maps the components of a document to inventory records. Nested
+// components are read as if they were top-level; components the inventory
+// has no place for, such as libraries, are ignored.
+func NewPlan(bom *BOM) *Plan {
+	index := make(map[string]*Component)
+	var components []*Component
+	var walk func([]Component)
+	walk = func(nested []Component) {
+		for i := range nested {
+			component := &nested[i]
+			components = append(components, component)
+			if component.BOMRef != "" {
+				index[component.BOMRef] = component
+			}
+			walk(component.Components)
+		}
+	}
+	walk(bom.Components)
+
+	dependsOn := make(map[string][]string, len(bom.Dependencies))
+	for _, dependency := range bom.Dependencies {
+		dependsOn[dependency.Ref] = append(dependsOn[dependency.Ref], dependency.DependsOn...)
+	}
+
+	plan := &Plan{Skipped: []models.CBOMSkippedComponent{}}
+	reader := &planReader{plan: plan, index: index, dependsOn: dependsOn, serialNumber: bom.SerialNumber}
+	attached := make(map[string]bool)
+	for _, component := range components {
+		if component.Type != ComponentDevice {
+			continue
+		}
+		asset := PlannedAsset{Ref: component.BOMRef, Input: assetInput(component)}
+		for _, ref := range dependsOn[component.BOMRef] {
+			if protocol := index[ref]; protocol != nil && cryptoAssetType(protocol) == AssetTypeProtocol {
+				attached[ref] = true
+				asset.Implementations = append(asset.Implementations, reader.implementations(protocol)...)
+			}
+		}
+		plan.Assets = append(plan.Assets, asset)
+	}
+	for _, component := range components {
+		switch cryptoAssetType(component) {
+		case AssetTypeProtocol:
+			if component.BOMRef == "" || !attached[component.BOMRef] {
+				plan.Unattached = append(plan.Unattached, reader.implementations(component)...)
+			}
+		case AssetTypeCertificate:
+			if certificate, ok := reader.certificate(component); ok {
+				plan.Certificates = append(plan.Certificates, certificate)
+			}
+		}
+	}
+	return plan
+}
+
+// Skip records a component that is not stored
+func (p *Plan) Skip(ref, reason string) {
+	p.Skipped = append(p.Skipped, models.CBOMSkippedComponent{BOMRef: ref, Reason: reason})
+}
+
+type planReader struct {
+	plan         *Plan
+	index        map[string]*Component
+	dependsOn    map[string][]string
+	serialNumber string
+}
+
+func cryptoAssetType(component *Component) string {
+	if component.Type != ComponentCryptographicAsset || component.CryptoProperties == nil {
+		return ""
+	}
+	return component.CryptoProperties.AssetType
+}
+
+// componentRef names a component in skip reports
+func componentRef(component *Component) string {
+	if component.BOMRef != "" {
+		return component.BOMRef
+	}
+	return component.Name
+}
+
+// assetInput reads a device component written by the inventory from its
+// properties, and one written by another tool from its name
+func assetInput(component *Component) models.AssetInput {
+	property := func(name string) *string {
+		if value := component.Property(PropertyPrefix + name); value != "" {
+			return &value
+		}
+		return nil
+	}
+	input := models.AssetInput{
+		Hostname:        property("hostname"),
+		IPAddress:       property("ip_address"),
+		AssetType:       "server",
+		OperatingSystem: property("operating_system"),
+		Environment:     property("environment"),
+		BusinessUnit:    property("business_unit"),
+		OwnerEmail:      property("owner_email"),
+		Metadata:        map[string]interface{}{"imported_from": "cbom", "bom_ref": component.BOMRef},
+	}
+	if assetType := property("asset_type"); assetType != nil {
+		input.AssetType = *assetType
+	}
+	if port := property("port"); port != nil {
+		if value, err := strconv.Atoi(*port); err == nil {
+			input.Port = &value
+		}
+	}
+	if component.Description != "" {
+		input.Description = &component.Description
+	}
+	if input.Hostname == nil && input.IPAddress == nil && component.Name != "" {
+		name := component.Name
+		if net.ParseIP(name) != nil {
+			input.IPAddress = &name
+		} else {
+			input.Hostname = &name
+		}
+	}
+	return input
+}
+
+// implementations reads a protocol component as an implementation per
+// cipher suite, or a single one when it lists none. The key exchange,
+// signature, cipher and hash come from the algorithms the protocol refers
+// to outside of its cipher suites.
+func (r *planReader) implementations(protocol *Component) []PlannedImplementation {
+	properties := protocol.CryptoProperties.ProtocolProperties
+	if properties == nil {
+		properties = &ProtocolProperties{}
+	}
+	name := inventoryProtocol(properties.Type, protocol.Name)
+	if name == "" {
+		r.plan.Skip(componentRef(protocol), fmt.Sprintf("unsupported protocol type %q", properties.Type))
+		return nil
+	}
+
+	rawData, _ := json.Marshal(map[string]string{
+		"source": "cbom", "serial_number": r.serialNumber, "bom_ref": protocol.BOMRef,
+	})
+	base := models.CryptoImplementationInput{
+		Protocol:        name,
+		DiscoveryMethod: "integration",
+		RawData:         rawData,
+	}
+	if properties.Version != "" {
+		version := properties.Version
+		base.ProtocolVersion = &version
+	}
+	if value := protocol.Property(PropertyPrefix + "confidence_score"); value != "" {
+		if confidence, err := strconv.ParseFloat(value, 64); err == nil {
+			base.ConfidenceScore = &confidence
+		}
+	}
+
+	suiteAlgorithms := make(map[string]bool)
+	for _, suite := range properties.CipherSuites {
+		for _, ref := range suite.Algorithms {
+			suiteAlgorithms[ref] = true
+		}
+	}
+	certificateRef := ""
+	for _, ref := range append(append([]string{}, properties.CryptoRefArray...), r.dependsOn[protocol.BOMRef]...) {
+		component := r.index[ref]
+		if component == nil || suiteAlgorithms[ref] {
+			continue
+		}
+		switch cryptoAssetType(component) {
+		case AssetTypeCertificate:
+			if certificateRef == "" {
+				certificateRef = ref
+			}
+		case AssetTypeAlgorithm:
+			setAlgorithm(&base, component)
+		}
+	}
+
+	var implementations []PlannedImplementation
+	for _, suite := range properties.CipherSuites {
+		suiteName := suite.Name
+		if suiteName == "" && len(suite.Identifiers) > 0 {
+			if entry, ok := algorithms.Lookup(strings.Join(suite.Identifiers, ","), algorithms.KindTLSCipherSuite); ok {
+				suiteName = entry.Name
+			}
+		}
+		if suiteName == "" {
+			r.plan.Skip(componentRef(protocol), "cipher suite without a name or known identifiers")
+			continue
+		}
+		input := base
+		input.CipherSuite = &suiteName
+		implementations = append(implementations, PlannedImplementation{Ref: protocol.BOMRef, Input: input, CertificateRef: certificateRef})
+	}
+	if len(properties.CipherSuites) == 0 {
+		implementations = append(implementations, PlannedImplementation{Ref: protocol.BOMRef, Input: base, CertificateRef: certificateRef})
+	}
+	return implementations
+}
+
+// inventoryProtocol maps a CycloneDX protocol type to an inventory
+// protocol, falling back to the component name when the type is missing
+func inventoryProtocol(protocolType, name string) string {
+	switch strings.ToLower(protocolType) {
+	case "tls":
+		return "TLS"
+	case "ssh":
+		return "SSH"
+	case "ipsec", "ike":
+		return "IPSec"
+	case "":
+		upper := strings.ToUpper(name)
+		switch {
+		case strings.HasPrefix(upper, "TLS"), strings.HasPrefix(upper, "SSL"):
+			return "TLS"
+		case strings.HasPrefix(upper, "SSH"):
+			return "SSH"
+		}
+	}
+	return ""
+}
+
+// setAlgorithm fills the field of an implementation an algorithm's
+// primitive belongs to, unless an earlier algorithm filled it
+func setAlgorithm(input *models.CryptoImplementationInput, algorithm *Component) {
+	properties := algorithm.CryptoProperties.AlgorithmProperties
+	if properties == nil || algorithm.Name == "" {
+		return
+	}
+	var field **string
+	switch properties.Primitive {
+	case PrimitiveKeyAgree, PrimitiveKEM:
+		field = &input.KeyExchangeAlgorithm
+	case PrimitiveSignature:
+		field = &input.SignatureAlgorithm
+	case PrimitiveAE, PrimitiveBlockCipher, PrimitiveStreamCipher:
+		field = &input.SymmetricEncryption
+	case PrimitiveHash:
+		field = &input.HashAlgorithm
+	default:
+		return
+	}
+	if *field == nil {
+		name := algorithm.Name
+		*field = &name
+	}
+}
+
+// certificate reads a certificate component. Certificates are keyed by
+// their SHA-256 fingerprint, so components without one are skipped.
+func (r *planReader) certificate(component *Component) (PlannedCertificate, bool) {
+	ref := componentRef(component)
+	properties := component.CryptoProperties.CertificateProperties
+	if properties == nil || properties.SubjectName == "" || properties.IssuerName == "" {
+		r.plan.Skip(ref, "certificate without subject and issuer names")
+		return PlannedCertificate{}, false
+	}
+	fingerprint := strings.ToLower(strings.ReplaceAll(component.Hash("SHA-256"), ":", ""))
+	if !sha256Pattern.MatchString(fingerprint) {
+		r.plan.Skip(ref, "certificate without a SHA-256 hash")
+		return PlannedCertificate{}, false
+	}
+
+	certificate := PlannedCertificate{
+		Ref:               component.BOMRef,
+		SubjectDN:         properties.SubjectName,
+		IssuerDN:          properties.IssuerName,
+		FingerprintSHA256: fingerprint,
+	}
+	if component.Name != "" && component.Name != properties.SubjectName {
+		name := component.Name
+		certificate.CommonName = &name
+	}
+	if serial := component.Property(PropertyPrefix + "serial_number"); serial != "" {
+		certificate.SerialNumber = &serial
+	}
+	if sha1 := strings.ToLower(strings.ReplaceAll(component.Hash("SHA-1"), ":", "")); sha1Pattern.MatchString(sha1) {
+		certificate.FingerprintSHA1 = &sha1
+	}
+	for _, validity := range []struct {
+		text  string
+		field **time.Time
+	}{
+		{properties.NotValidBefore, &certificate.NotBefore},
+		{properties.NotValidAfter, &certificate.NotAfter},
+	} {
+		if validity.text == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, validity.text)
+		if err != nil {
+			r.plan.Skip(ref, "certificate validity is not an RFC 3339 date-time")
+			return PlannedCertificate{}, false
+		}
+		*validity.field = &parsed
+	}
+
+	if signature := r.index[properties.SignatureAlgorithmRef]; signature != nil && signature.Name != "" {
+		name := signature.Name
+		certificate.SignatureAlgorithm = &name
+	}
+	key := r.index[properties.SubjectPublicKeyRef]
+	if key != nil && cryptoAssetType(key) == AssetTypeRelatedCryptoMaterial && key.CryptoProperties.RelatedCryptoMaterialProperties != nil {
+		material := key.CryptoProperties.RelatedCryptoMaterialProperties
+		certificate.PublicKeySize = material.Size
+		key = r.index[material.AlgorithmRef]
+	}
+	if key != nil && cryptoAssetType(key) == AssetTypeAlgorithm && key.Name != "" {
+		// Key algorithms carry their size as parameter set, RSA-2048 say
+		name := key.Name
+		if parameters := key.CryptoProperties.AlgorithmProperties; parameters != nil && parameters.ParameterSetIdentifier != "" {
+			name = strings.TrimSuffix(name, "-"+parameters.ParameterSetIdentifier)
+			if size, err := strconv.Atoi(parameters.ParameterSetIdentifier); err == nil && certificate.PublicKeySize == nil {
+				certificate.PublicKeySize = &size
+			}
+		}
+		certificate.PublicKeyAlgorithm = &name
+	}
+	return certificate, true
+}
@@ -0,0 +1,120 @@
+package cbom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// Sections of a streamed document
+const (
+	sectionStart = iota
+	sectionComponents
+	sectionDependencies
+	sectionClosed
+)
+
+// Writer streams a CBOM, so the inventory of a large tenant is written
+// without holding the document in memory. All components must be written
+// before the first dependency.
+type Writer struct {
+	out      *bufio.Writer
+	metadata *Metadata
+	section  int
+	empty    bool // no entry written to the open array yet
+}
+
+// NewWriter returns a writer of a document with a new serial number
+func NewWriter(out io.Writer, metadata *Metadata) *Writer {
+	return &Writer{out: bufio.NewWriterSize(out, 64<<10), metadata: metadata}
+}
+
+// WriteComponent appends a component to the document
+func (w *Writer) WriteComponent(component *Component) error {
+	if w.section > sectionComponents {
+		return fmt.Errorf("component %s written after the dependencies", component.BOMRef)
+	}
+	if err := w.advance(sectionComponents); err != nil {
+		return err
+	}
+	return w.entry(component)
+}
+
+// WriteDependency appends a dependency to the document
+func (w *Writer) WriteDependency(dependency *Dependency) error {
+	if err := w.advance(sectionDependencies); err != nil {
+		return err
+	}
+	return w.entry(dependency)
+}
+
+// Close ends the document and flushes it; it does not close the
+// underlying writer
+func (w *Writer) Close() error {
+	if err := w.advance(sectionClosed); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// advance closes the open sections up to the given one and opens it
+func (w *Writer) advance(section int) error {
+	for w.section < section {
+		var err error
+		switch w.section {
+		case sectionStart:
+			err = w.header()
+		case sectionComponents:
+			_, err = w.out.WriteString(`],"dependencies":[`)
+		case sectionDependencies:
+			_, err = w.out.WriteString("]}\n")
+		}
+		if err != nil {
+			return err
+		}
+		w.section++
+		w.empty = true
+	}
+	return nil
+}
+
+// header writes the document properties and opens the components; the
+// document object is left open for the arrays
+func (w *Writer) header() error {
+	header, err := json.Marshal(BOM{
+		Schema:       schemaURL,
+		BOMFormat:    BOMFormat,
+		SpecVersion:  SpecVersion,
+		SerialNumber: "urn:uuid:" + uuid.New().String(),
+		Version:      1,
+		Metadata:     w.metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode CBOM header: %w", err)
+	}
+	header = bytes.TrimSuffix(header, []byte("}"))
+	if _, err := w.out.Write(header); err != nil {
+		return err
+	}
+	_, err = w.out.WriteString(`,"components":[`)
+	return err
+}
+
+func (w *Writer) entry(value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode CBOM entry: %w", err)
+	}
+	if !w.empty {
+		if err := w.out.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	w.empty = false
+	_, err = w.out.Write(encoded)
+	return err
+}
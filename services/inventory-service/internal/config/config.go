@@ -3,12 +3,14 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	Risk     RiskConfig
 }
 
 type ServerConfig struct {
@@ -29,6 +31,12 @@ type JWTConfig struct {
 	Secret string
 }
 
+type RiskConfig struct {
+	// RecomputeInterval is how often stale risk scores, of implementations
+	// discovered or changed since they were scored, are recomputed
+	RecomputeInterval time.Duration
+}
+
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
@@ -46,6 +54,9 @@ func Load() *Config {
 		JWT: JWTConfig{
 			Secret: getEnv("JWT_SECRET", "your-secret-key"),
 		},
+		Risk: RiskConfig{
+			RecomputeInterval: getEnvAsDuration("RISK_RECOMPUTE_INTERVAL", time.Minute),
+		},
 	}
 }
 
@@ -64,3 +75,12 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+			return duration
+		}
+	}
+	return defaultValue
+}
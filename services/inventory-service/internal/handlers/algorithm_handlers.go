@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"inventory-service/internal/algorithms"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxResolveNames bounds the names of one resolve request
+const maxResolveNames = 1000
+
+type AlgorithmHandler struct{}
+
+func NewAlgorithmHandler() *AlgorithmHandler {
+	return &AlgorithmHandler{}
+}
+
+type algorithmQuery struct {
+	Kind              string `form:"kind"`
+	Query             string `form:"q"`
+	Deprecated        *bool  `form:"deprecated"`
+	FIPSApproved      *bool  `form:"fips_approved"`
+	CNSA2             *bool  `form:"cnsa2_compliant"`
+	QuantumVulnerable *bool  `form:"quantum_vulnerable"`
+}
+
+type resolveAlgorithmsRequest struct {
+	Names []string `json:"names" binding:"required"`
+	Kinds []string `json:"kinds"`
+}
+
+// GetAlgorithms handles GET /api/v1/algorithms
+func (h *AlgorithmHandler) GetAlgorithms(c *gin.Context) {
+	var query algorithmQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	if query.Kind != "" && !validKinds(c, []string{query.Kind}) {
+		return
+	}
+
+	entries := algorithms.List(algorithms.Filters{
+		Kind:              query.Kind,
+		Query:             query.Query,
+		Deprecated:        query.Deprecated,
+		FIPSApproved:      query.FIPSApproved,
+		CNSA2:             query.CNSA2,
+		QuantumVulnerable: query.QuantumVulnerable,
+	})
+	c.JSON(http.StatusOK, gin.H{"algorithms": entries, "total": len(entries)})
+}
+
+// LookupAlgorithm handles GET /api/v1/algorithms/lookup?name=. Repeated
+// kind parameters restrict the lookup.
+func (h *AlgorithmHandler) LookupAlgorithm(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	kinds := c.QueryArray("kind")
+	if !validKinds(c, kinds) {
+		return
+	}
+
+	entry, ok := algorithms.Lookup(name, kinds...)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Algorithm not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"algorithm": entry})
+}
+
+// ResolveAlgorithms handles POST /api/v1/algorithms/resolve, looking up
+// several names at once; names that do not resolve map to null
+func (h *AlgorithmHandler) ResolveAlgorithms(c *gin.Context) {
+	var req resolveAlgorithmsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if len(req.Names) > maxResolveNames {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Too many names", "max_names": maxResolveNames})
+		return
+	}
+	if !validKinds(c, req.Kinds) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"algorithms": algorithms.Resolve(req.Names, req.Kinds...)})
+}
+
+func validKinds(c *gin.Context, kinds []string) bool {
+	for _, kind := range kinds {
+		if !algorithms.ValidKind(kind) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid algorithm kind",
+				"details": fmt.Sprintf("kind must be one of %v", algorithms.Kinds),
+			})
+			return false
+		}
+	}
+	return true
+}
@@ -102,6 +102,7 @@ func (h *AssetHandler) GetAssetByID(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", models.ETag(asset.UpdatedAt))
 	c.JSON(http.StatusOK, gin.H{"asset": asset})
 }
 
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"errors"
+	"inventory-service/internal/models"
+	"inventory-service/internal/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateAsset handles POST /api/v1/assets
+func (h *AssetHandler) CreateAsset(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+
+	var input models.AssetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	assetID, err := h.assetService.CreateAsset(tenantUUID, &input, source)
+	if err != nil {
+		respondWriteError(c, err, "Failed to create asset")
+		return
+	}
+
+	c.Header("Location", "/api/v1/assets/"+assetID.String())
+	h.respondAsset(c, http.StatusCreated, tenantUUID, assetID)
+}
+
+// ReplaceAsset handles PUT /api/v1/assets/:id
+func (h *AssetHandler) ReplaceAsset(c *gin.Context) {
+	tenantUUID, assetID, ifMatch, ok := assetWriteRequest(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+
+	var input models.AssetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	if err := h.assetService.ReplaceAsset(tenantUUID, assetID, ifMatch, &input, source); err != nil {
+		respondWriteError(c, err, "Failed to update asset")
+		return
+	}
+	h.respondAsset(c, http.StatusOK, tenantUUID, assetID)
+}
+
+// PatchAsset handles PATCH /api/v1/assets/:id
+func (h *AssetHandler) PatchAsset(c *gin.Context) {
+	tenantUUID, assetID, ifMatch, ok := assetWriteRequest(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	if err := h.assetService.PatchAsset(tenantUUID, assetID, ifMatch, patch, source); err != nil {
+		respondWriteError(c, err, "Failed to update asset")
+		return
+	}
+	h.respondAsset(c, http.StatusOK, tenantUUID, assetID)
+}
+
+// DeleteAsset handles DELETE /api/v1/assets/:id
+func (h *AssetHandler) DeleteAsset(c *gin.Context) {
+	tenantUUID, assetID, ifMatch, ok := assetWriteRequest(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+
+	if err := h.assetService.DeleteAsset(tenantUUID, assetID, ifMatch, source); err != nil {
+		respondWriteError(c, err, "Failed to delete asset")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// BulkUpsertAssets handles POST /api/v1/assets/bulk. Items are stored one by
+// one; the response reports each item's outcome and is 207 Multi-Status
+// when any failed.
+func (h *AssetHandler) BulkUpsertAssets(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+
+	var req models.BulkAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if len(req.Assets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one asset is required"})
+		return
+	}
+	if len(req.Assets) > models.MaxBulkAssets {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Too many assets", "max_assets": models.MaxBulkAssets})
+		return
+	}
+
+	response := h.assetService.BulkUpsertAssets(tenantUUID, req.Assets, source)
+	status := http.StatusOK
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, response)
+}
+
+// CreateCryptoImplementation handles POST /api/v1/assets/:id/crypto
+func (h *AssetHandler) CreateCryptoImplementation(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+	assetID, ok := pathUUID(c, "id", "Invalid asset ID")
+	if !ok {
+		return
+	}
+
+	var input models.CryptoImplementationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	cryptoID, err := h.assetService.CreateCryptoImplementation(tenantUUID, assetID, &input, source)
+	if err != nil {
+		respondWriteError(c, err, "Failed to create crypto implementation")
+		return
+	}
+
+	c.Header("Location", "/api/v1/assets/"+assetID.String()+"/crypto/"+cryptoID.String())
+	h.respondCryptoImplementation(c, http.StatusCreated, tenantUUID, assetID, cryptoID)
+}
+
+// GetCryptoImplementation handles GET /api/v1/assets/:id/crypto/:crypto_id
+func (h *AssetHandler) GetCryptoImplementation(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	assetID, ok := pathUUID(c, "id", "Invalid asset ID")
+	if !ok {
+		return
+	}
+	cryptoID, ok := pathUUID(c, "crypto_id", "Invalid crypto implementation ID")
+	if !ok {
+		return
+	}
+
+	h.respondCryptoImplementation(c, http.StatusOK, tenantUUID, assetID, cryptoID)
+}
+
+// ReplaceCryptoImplementation handles PUT /api/v1/assets/:id/crypto/:crypto_id
+func (h *AssetHandler) ReplaceCryptoImplementation(c *gin.Context) {
+	tenantUUID, assetID, cryptoID, ifMatch, ok := cryptoWriteRequest(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+
+	var input models.CryptoImplementationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	if err := h.assetService.ReplaceCryptoImplementation(tenantUUID, assetID, cryptoID, ifMatch, &input, source); err != nil {
+		respondWriteError(c, err, "Failed to update crypto implementation")
+		return
+	}
+	h.respondCryptoImplementation(c, http.StatusOK, tenantUUID, assetID, cryptoID)
+}
+
+// PatchCryptoImplementation handles PATCH /api/v1/assets/:id/crypto/:crypto_id
+func (h *AssetHandler) PatchCryptoImplementation(c *gin.Context) {
+	tenantUUID, assetID, cryptoID, ifMatch, ok := cryptoWriteRequest(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	if err := h.assetService.PatchCryptoImplementation(tenantUUID, assetID, cryptoID, ifMatch, patch, source); err != nil {
+		respondWriteError(c, err, "Failed to update crypto implementation")
+		return
+	}
+	h.respondCryptoImplementation(c, http.StatusOK, tenantUUID, assetID, cryptoID)
+}
+
+// DeleteCryptoImplementation handles DELETE /api/v1/assets/:id/crypto/:crypto_id
+func (h *AssetHandler) DeleteCryptoImplementation(c *gin.Context) {
+	tenantUUID, assetID, cryptoID, ifMatch, ok := cryptoWriteRequest(c)
+	if !ok {
+		return
+	}
+	source, ok := requestChange(c)
+	if !ok {
+		return
+	}
+
+	if err := h.assetService.DeleteCryptoImplementation(tenantUUID, assetID, cryptoID, ifMatch, source); err != nil {
+		respondWriteError(c, err, "Failed to delete crypto implementation")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondAsset responds with an asset as stored, with its ETag
+func (h *AssetHandler) respondAsset(c *gin.Context, status int, tenantID, assetID uuid.UUID) {
+	asset, err := h.assetService.GetAssetByID(tenantID, assetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve asset", "details": err.Error()})
+		return
+	}
+	c.Header("ETag", models.ETag(asset.UpdatedAt))
+	c.JSON(status, gin.H{"asset": asset})
+}
+
+// respondCryptoImplementation responds with a crypto implementation as
+// stored, with its ETag
+func (h *AssetHandler) respondCryptoImplementation(c *gin.Context, status int, tenantID, assetID, cryptoID uuid.UUID) {
+	impl, err := h.assetService.GetCryptoImplementation(tenantID, assetID, cryptoID)
+	if err != nil {
+		respondWriteError(c, err, "Failed to retrieve crypto implementation")
+		return
+	}
+	c.Header("ETag", models.ETag(impl.UpdatedAt))
+	c.JSON(status, gin.H{"crypto_implementation": impl})
+}
+
+// respondWriteError maps errors of write operations to responses
+func respondWriteError(c *gin.Context, err error, message string) {
+	var validation *models.ValidationError
+	var duplicate *services.DuplicateCryptoImplementationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "field": validation.Field, "details": validation.Message})
+	case errors.As(err, &duplicate):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":                    "The asset already has a crypto implementation with this port, protocol, version and cipher suite",
+			"crypto_implementation_id": duplicate.ExistingID,
+		})
+	case errors.Is(err, services.ErrAssetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+	case errors.Is(err, services.ErrCryptoImplementationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crypto implementation not found"})
+	case errors.Is(err, services.ErrPreconditionFailed):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Record was modified; fetch it again and retry with its ETag"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+// assetWriteRequest returns the tenant, asset ID and If-Match precondition
+// of a request changing an asset
+func assetWriteRequest(c *gin.Context) (uuid.UUID, uuid.UUID, string, bool) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	assetID, ok := pathUUID(c, "id", "Invalid asset ID")
+	if !ok {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	ifMatch, ok := requireIfMatch(c)
+	return tenantUUID, assetID, ifMatch, ok
+}
+
+// cryptoWriteRequest returns the tenant, asset and implementation IDs and
+// If-Match precondition of a request changing a crypto implementation
+func cryptoWriteRequest(c *gin.Context) (uuid.UUID, uuid.UUID, uuid.UUID, string, bool) {
+	tenantUUID, assetID, ifMatch, ok := assetWriteRequest(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, "", false
+	}
+	cryptoID, ok := pathUUID(c, "crypto_id", "Invalid crypto implementation ID")
+	return tenantUUID, assetID, cryptoID, ifMatch, ok
+}
+
+// requireIfMatch returns the ETag of the If-Match header, which changes must
+// carry so concurrent edits are not lost. "*" matches any version and is
+// returned as "".
+func requireIfMatch(c *gin.Context) (string, bool) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the record's ETag required"})
+		return "", false
+	}
+	if ifMatch == "*" {
+		return "", true
+	}
+	return strings.TrimPrefix(ifMatch, "W/"), true
+}
+
+// requestTenant returns the tenant of the authenticated user
+func requestTenant(c *gin.Context) (uuid.UUID, bool) {
+	tenantID, exists := c.Get("tenant_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Tenant ID not found"})
+		return uuid.Nil, false
+	}
+
+	tenantUUID, ok := tenantID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return uuid.Nil, false
+	}
+	return tenantUUID, true
+}
+
+// requestChange returns the source of the changes a request makes, for the
+// inventory history
+func requestChange(c *gin.Context) (models.ChangeSource, bool) {
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return models.ChangeSource{}, false
+	}
+	return models.UserChange(userUUID), true
+}
+
+// pathUUID parses a UUID path parameter
+func pathUUID(c *gin.Context, name, message string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return uuid.Nil, false
+	}
+	return id, true
+}
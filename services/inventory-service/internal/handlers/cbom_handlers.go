@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"inventory-service/internal/cbom"
+	"inventory-service/internal/models"
+	"inventory-service/internal/services"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxCBOMImportSize bounds the size of an imported CBOM
+const maxCBOMImportSize = 64 << 20
+
+type CBOMHandler struct {
+	cbomService *services.CBOMService
+}
+
+func NewCBOMHandler(cbomService *services.CBOMService) *CBOMHandler {
+	return &CBOMHandler{cbomService: cbomService}
+}
+
+// ExportCBOM handles GET /api/v1/cbom, streaming the tenant's CycloneDX
+// 1.6 CBOM. Repeated business_unit and environment parameters select the
+// assets it covers.
+func (h *CBOMHandler) ExportCBOM(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+
+	var filters models.CBOMFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	for _, environment := range filters.Environment {
+		if !isEnvironment(environment) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid environment",
+				"details": "environment must be one of " + strings.Join(models.EnvironmentTypes, ", "),
+			})
+			return
+		}
+	}
+
+	stream := &cbomStream{c: c, filename: fmt.Sprintf("cbom-%s.json", time.Now().UTC().Format("20060102"))}
+	if err := h.cbomService.ExportCBOM(c.Request.Context(), tenantUUID, filters, stream); err != nil {
+		if !stream.started {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export CBOM", "details": err.Error()})
+			return
+		}
+		// The status is sent; the truncated document tells the client
+		log.Printf("❌ CBOM export of tenant %s failed while streaming: %v", tenantUUID, err)
+	}
+}
+
+// ImportCBOM handles POST /api/v1/cbom/import with a CycloneDX JSON body.
+// Protocols no device component depends on are recorded on the asset of
+// the asset_id parameter, and skipped without it.
+func (h *CBOMHandler) ImportCBOM(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	source := models.ChangeSource{Source: models.ChangeSourceImport, UserID: &userUUID}
+
+	var fallbackAssetID *uuid.UUID
+	if value := c.Query("asset_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid asset ID"})
+			return
+		}
+		fallbackAssetID = &id
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCBOMImportSize)
+	document, err := cbom.Read(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "CBOM too large", "max_bytes": maxCBOMImportSize})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CBOM", "details": err.Error()})
+		return
+	}
+
+	if document.SerialNumber != "" {
+		source.Reference = "cbom:" + document.SerialNumber
+	}
+	result, err := h.cbomService.ImportCBOM(tenantUUID, document, fallbackAssetID, source)
+	if err != nil {
+		if errors.Is(err, services.ErrAssetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import CBOM", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// cbomStream writes an exported CBOM to the response, sending the headers
+// with the first bytes so an export failing before then can still answer
+// with an error
+type cbomStream struct {
+	c        *gin.Context
+	filename string
+	started  bool
+}
+
+func (s *cbomStream) Write(data []byte) (int, error) {
+	if !s.started {
+		s.started = true
+		s.c.Header("Content-Type", cbom.MediaType)
+		s.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, s.filename))
+		s.c.Status(http.StatusOK)
+	}
+	return s.c.Writer.Write(data)
+}
+
+func isEnvironment(value string) bool {
+	for _, environment := range models.EnvironmentTypes {
+		if environment == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"inventory-service/internal/models"
+	"inventory-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxCertificatePageSize bounds the page size of certificate listings
+const maxCertificatePageSize = 500
+
+type CertificateHandler struct {
+	certificateService *services.CertificateService
+}
+
+func NewCertificateHandler(certificateService *services.CertificateService) *CertificateHandler {
+	return &CertificateHandler{
+		certificateService: certificateService,
+	}
+}
+
+// GetCertificates handles GET /api/v1/certificates
+func (h *CertificateHandler) GetCertificates(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+
+	var filters models.CertificateFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	if filters.ExpiringWithinDays != nil && *filters.ExpiringWithinDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": "expiring_within_days must not be negative"})
+		return
+	}
+
+	if filters.Page == 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize == 0 {
+		filters.PageSize = 20
+	}
+	if filters.PageSize > maxCertificatePageSize {
+		filters.PageSize = maxCertificatePageSize
+	}
+
+	certificates, total, err := h.certificateService.ListCertificates(tenantID, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve certificates", "details": err.Error()})
+		return
+	}
+
+	totalPages := (total + filters.PageSize - 1) / filters.PageSize
+	c.JSON(http.StatusOK, gin.H{
+		"certificates": certificates,
+		"pagination": gin.H{
+			"page":        filters.Page,
+			"page_size":   filters.PageSize,
+			"total":       total,
+			"total_pages": totalPages,
+			"has_next":    filters.Page < totalPages,
+			"has_prev":    filters.Page > 1,
+		},
+	})
+}
+
+// GetCertificateSummary handles GET /api/v1/certificates/summary
+func (h *CertificateHandler) GetCertificateSummary(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.certificateService.GetCertificateSummary(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve certificate summary", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"summary": summary})
+}
+
+// GetCertificate handles GET /api/v1/certificates/:id
+func (h *CertificateHandler) GetCertificate(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	certificateID, ok := pathUUID(c, "id", "Invalid certificate ID")
+	if !ok {
+		return
+	}
+
+	certificate, err := h.certificateService.GetCertificate(tenantID, certificateID)
+	if err != nil {
+		respondCertificateError(c, err, "Failed to retrieve certificate")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"certificate": certificate})
+}
+
+// GetCertificateChain handles GET /api/v1/certificates/:id/chain
+func (h *CertificateHandler) GetCertificateChain(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	certificateID, ok := pathUUID(c, "id", "Invalid certificate ID")
+	if !ok {
+		return
+	}
+
+	chain, err := h.certificateService.GetCertificateChain(tenantID, certificateID)
+	if err != nil {
+		respondCertificateError(c, err, "Failed to retrieve certificate chain")
+		return
+	}
+
+	c.JSON(http.StatusOK, chain)
+}
+
+// GetCertificateReminders handles GET /api/v1/certificates/reminders
+func (h *CertificateHandler) GetCertificateReminders(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+
+	var filters models.CertificateReminderFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	switch filters.Status {
+	case "", models.ReminderStatusOpen, models.ReminderStatusClosed, "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": "status must be open, closed or all"})
+		return
+	}
+	for name, value := range map[string]string{"asset_id": filters.AssetID, "certificate_id": filters.CertificateID} {
+		if _, err := uuid.Parse(value); value != "" && err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": name + " must be a UUID"})
+			return
+		}
+	}
+
+	if filters.Page == 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize == 0 {
+		filters.PageSize = 20
+	}
+	if filters.PageSize > maxCertificatePageSize {
+		filters.PageSize = maxCertificatePageSize
+	}
+
+	reminders, total, err := h.certificateService.ListReminders(tenantID, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve certificate reminders", "details": err.Error()})
+		return
+	}
+
+	totalPages := (total + filters.PageSize - 1) / filters.PageSize
+	c.JSON(http.StatusOK, gin.H{
+		"reminders": reminders,
+		"pagination": gin.H{
+			"page":        filters.Page,
+			"page_size":   filters.PageSize,
+			"total":       total,
+			"total_pages": totalPages,
+			"has_next":    filters.Page < totalPages,
+			"has_prev":    filters.Page > 1,
+		},
+	})
+}
+
+// GetCertificateHistory handles GET /api/v1/certificates/:id/history
+func (h *CertificateHandler) GetCertificateHistory(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	certificateID, ok := pathUUID(c, "id", "Invalid certificate ID")
+	if !ok {
+		return
+	}
+
+	history, err := h.certificateService.GetCertificateHistory(tenantID, certificateID)
+	if err != nil {
+		respondCertificateError(c, err, "Failed to retrieve certificate history")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+func respondCertificateError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrCertificateNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"inventory-service/internal/models"
+	"inventory-service/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxChangePageSize bounds the page size of change listings
+const maxChangePageSize = 100
+
+type HistoryHandler struct {
+	historyService *services.HistoryService
+}
+
+func NewHistoryHandler(historyService *services.HistoryService) *HistoryHandler {
+	return &HistoryHandler{historyService: historyService}
+}
+
+// GetAssetHistory handles GET /api/v1/assets/:id/history: the changes of
+// the asset and its crypto implementations, the most recent first
+func (h *HistoryHandler) GetAssetHistory(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	assetID, ok := pathUUID(c, "id", "Invalid asset ID")
+	if !ok {
+		return
+	}
+	filters, ok := changeFilters(c)
+	if !ok {
+		return
+	}
+
+	changes, total, err := h.historyService.AssetHistory(tenantUUID, assetID, filters)
+	if err != nil {
+		if errors.Is(err, services.ErrAssetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve asset history", "details": err.Error()})
+		return
+	}
+	respondChanges(c, changes, total, filters)
+}
+
+// GetChanges handles GET /api/v1/changes, the tenant's change feed
+func (h *HistoryHandler) GetChanges(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	filters, ok := changeFilters(c)
+	if !ok {
+		return
+	}
+
+	changes, total, err := h.historyService.ListChanges(tenantUUID, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve changes", "details": err.Error()})
+		return
+	}
+	respondChanges(c, changes, total, filters)
+}
+
+// changeFilters binds and checks the filters of a change listing
+func changeFilters(c *gin.Context) (models.ChangeFilters, bool) {
+	var filters models.ChangeFilters
+	invalid := func(details string) (models.ChangeFilters, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": details})
+		return filters, false
+	}
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		return invalid(err.Error())
+	}
+
+	switch filters.EntityType {
+	case "", models.EntityAsset, models.EntityCryptoImplementation, models.EntityCertificate:
+	default:
+		return invalid("entity_type must be asset, crypto_implementation or certificate")
+	}
+	switch filters.Source {
+	case "", models.ChangeSourceUser, models.ChangeSourceSensor, models.ChangeSourceImport, models.ChangeSourceSystem:
+	default:
+		return invalid("source must be user, sensor, import or system")
+	}
+	for name, value := range map[string]string{"entity_id": filters.EntityID, "asset_id": filters.AssetID} {
+		if _, err := uuid.Parse(value); value != "" && err != nil {
+			return invalid(name + " must be a UUID")
+		}
+	}
+	for name, value := range map[string]string{"since": filters.Since, "until": filters.Until} {
+		if _, err := time.Parse(time.RFC3339, value); value != "" && err != nil {
+			return invalid(name + " must be an RFC 3339 timestamp")
+		}
+	}
+
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+	if filters.PageSize > maxChangePageSize {
+		filters.PageSize = maxChangePageSize
+	}
+	return filters, true
+}
+
+func respondChanges(c *gin.Context, changes []models.InventoryChange, total int, filters models.ChangeFilters) {
+	totalPages := (total + filters.PageSize - 1) / filters.PageSize
+	c.JSON(http.StatusOK, gin.H{
+		"changes": changes,
+		"pagination": gin.H{
+			"page":        filters.Page,
+			"page_size":   filters.PageSize,
+			"total":       total,
+			"total_pages": totalPages,
+			"has_next":    filters.Page < totalPages,
+			"has_prev":    filters.Page > 1,
+		},
+	})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"inventory-service/internal/config"
+	"inventory-service/internal/services"
 	"net/http"
 	"strings"
 
@@ -77,3 +78,46 @@ func JWTMiddleware(cfg *config.Config) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// Permissions of the RBAC schema required by write endpoints
+const (
+	PermissionAssetsCreate = "assets.create"
+	PermissionAssetsUpdate = "assets.update"
+	PermissionAssetsDelete = "assets.delete"
+
+	// PermissionSettingsUpdate is required to change the tenant's risk rules
+	PermissionSettingsUpdate = "settings.update"
+
+	// Inventory snapshots are reports of the inventory at a point in time
+	PermissionReportsCreate = "reports.create"
+	PermissionReportsDelete = "reports.delete"
+)
+
+// RequirePermission rejects requests of users without the given tenant
+// permission. It runs after JWTMiddleware.
+func RequirePermission(permissions *services.PermissionService, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("user_id")
+		tenantID, _ := c.Get("tenant_id")
+		userUUID, userOK := userID.(uuid.UUID)
+		tenantUUID, tenantOK := tenantID.(uuid.UUID)
+		if !userOK || !tenantOK {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User or tenant ID not found"})
+			c.Abort()
+			return
+		}
+
+		granted, err := permissions.UserHasPermission(userUUID, tenantUUID, permission)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions", "details": err.Error()})
+			c.Abort()
+			return
+		}
+		if !granted {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission " + permission + " required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
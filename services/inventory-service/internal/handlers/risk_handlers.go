@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"inventory-service/internal/risk"
+	"inventory-service/internal/services"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRiskRulesSize bounds the size of a risk rule document
+const maxRiskRulesSize = 1 << 20
+
+type RiskHandler struct {
+	riskService *services.RiskService
+}
+
+func NewRiskHandler(riskService *services.RiskService) *RiskHandler {
+	return &RiskHandler{
+		riskService: riskService,
+	}
+}
+
+// GetRiskRules handles GET /api/v1/risk/rules
+func (h *RiskHandler) GetRiskRules(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+
+	rules, err := h.riskService.GetRules(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve risk rules", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// PutRiskRules handles PUT /api/v1/risk/rules. The body is the tenant's
+// override document, in YAML when the content type is YAML and in JSON
+// otherwise; it is stored as the tenant's next version.
+func (h *RiskHandler) PutRiskRules(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+
+	format := risk.FormatJSON
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		format = risk.FormatYAML
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRiskRulesSize)
+	source, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	var comment *string
+	if text := c.Query("comment"); text != "" {
+		comment = &text
+	}
+
+	ruleSet, err := h.riskService.PublishRules(tenantID, userID, source, format, comment)
+	if err != nil {
+		respondRiskError(c, err, "Failed to store risk rules")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"version": ruleSet})
+}
+
+// GetRiskRuleVersions handles GET /api/v1/risk/rules/versions
+func (h *RiskHandler) GetRiskRuleVersions(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.riskService.ListRuleVersions(tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve risk rule versions", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// GetRiskRuleVersion handles GET /api/v1/risk/rules/versions/:version
+func (h *RiskHandler) GetRiskRuleVersion(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	version, ok := ruleVersionParam(c)
+	if !ok {
+		return
+	}
+
+	ruleSet, err := h.riskService.GetRuleVersion(tenantID, version)
+	if err != nil {
+		respondRiskError(c, err, "Failed to retrieve risk rule version")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"version": ruleSet})
+}
+
+// RestoreRiskRuleVersion handles POST /api/v1/risk/rules/versions/:version/restore
+func (h *RiskHandler) RestoreRiskRuleVersion(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	version, ok := ruleVersionParam(c)
+	if !ok {
+		return
+	}
+
+	ruleSet, err := h.riskService.RestoreRuleVersion(tenantID, userID, version)
+	if err != nil {
+		respondRiskError(c, err, "Failed to restore risk rule version")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"version": ruleSet})
+}
+
+// GetCryptoRisk handles GET /api/v1/assets/:id/crypto/:crypto_id/risk
+func (h *RiskHandler) GetCryptoRisk(c *gin.Context) {
+	tenantID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	assetID, ok := pathUUID(c, "id", "Invalid asset ID")
+	if !ok {
+		return
+	}
+	cryptoID, ok := pathUUID(c, "crypto_id", "Invalid crypto implementation ID")
+	if !ok {
+		return
+	}
+
+	breakdown, err := h.riskService.GetBreakdown(tenantID, assetID, cryptoID)
+	if err != nil {
+		respondRiskError(c, err, "Failed to retrieve risk breakdown")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"risk": breakdown})
+}
+
+func ruleVersionParam(c *gin.Context) (int, bool) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid risk rule version"})
+		return 0, false
+	}
+	return version, true
+}
+
+func respondRiskError(c *gin.Context, err error, message string) {
+	var validation *risk.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid risk rules", "details": validation.Error()})
+	case errors.Is(err, services.ErrRiskRuleVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Risk rule version not found"})
+	case errors.Is(err, services.ErrRiskRuleVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Another risk rule version was published; fetch the rules again and retry"})
+	case errors.Is(err, services.ErrCryptoImplementationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Crypto implementation not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+func requestUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, _ := c.Get("user_id")
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return uuid.Nil, false
+	}
+	return userUUID, true
+}
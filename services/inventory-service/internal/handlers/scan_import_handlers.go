@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"inventory-service/internal/models"
+	"inventory-service/internal/services"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxScanImportSize bounds the size of an uploaded scan report
+	maxScanImportSize = 64 << 20
+	// maxScanImportPageSize bounds the page size of import listings
+	maxScanImportPageSize = 100
+)
+
+type ScanImportHandler struct {
+	scanImportService *services.ScanImportService
+}
+
+func NewScanImportHandler(scanImportService *services.ScanImportService) *ScanImportHandler {
+	return &ScanImportHandler{scanImportService: scanImportService}
+}
+
+// CreateScanImport handles POST /api/v1/imports with a scanner report as
+// the body. The format parameter names the scanner, and is detected from
+// the report without it; dry_run=true reports what the import would do
+// without changing the inventory. The import runs in the background.
+func (h *ScanImportHandler) CreateScanImport(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+
+	format := strings.ToLower(c.Query("format"))
+	if format != "" && !isScanImportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format",
+			"details": "format must be one of " + strings.Join(models.ScanImportFormats, ", "),
+		})
+		return
+	}
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run", "details": err.Error()})
+			return
+		}
+		dryRun = parsed
+	}
+	var filename *string
+	if value := filepath.Base(c.Query("filename")); value != "." && value != "/" {
+		if len(value) > 255 {
+			value = value[:255]
+		}
+		filename = &value
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxScanImportSize)
+	payload, err := c.GetRawData()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Scan report too large", "max_bytes": maxScanImportSize})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if len(payload) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan report", "details": "request body is empty"})
+		return
+	}
+
+	job, err := h.scanImportService.Submit(tenantUUID, userUUID, format, filename, dryRun, payload)
+	if err != nil {
+		respondScanImportError(c, err, "Failed to create scan import")
+		return
+	}
+	c.Header("Location", "/api/v1/imports/"+job.ID.String())
+	c.JSON(http.StatusAccepted, gin.H{"import": job})
+}
+
+// GetScanImports handles GET /api/v1/imports
+func (h *ScanImportHandler) GetScanImports(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+
+	var filters models.ScanImportFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	switch filters.Status {
+	case "", models.ScanImportPending, models.ScanImportProcessing, models.ScanImportCompleted, models.ScanImportFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": "status must be pending, processing, completed or failed"})
+		return
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+	if filters.PageSize > maxScanImportPageSize {
+		filters.PageSize = maxScanImportPageSize
+	}
+
+	jobs, total, err := h.scanImportService.ListImports(tenantUUID, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve scan imports", "details": err.Error()})
+		return
+	}
+
+	totalPages := (total + filters.PageSize - 1) / filters.PageSize
+	c.JSON(http.StatusOK, gin.H{
+		"imports": jobs,
+		"pagination": gin.H{
+			"page":        filters.Page,
+			"page_size":   filters.PageSize,
+			"total":       total,
+			"total_pages": totalPages,
+			"has_next":    filters.Page < totalPages,
+			"has_prev":    filters.Page > 1,
+		},
+	})
+}
+
+// GetScanImport handles GET /api/v1/imports/:id, with the row errors
+func (h *ScanImportHandler) GetScanImport(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	importID, ok := pathUUID(c, "id", "Invalid import ID")
+	if !ok {
+		return
+	}
+
+	job, err := h.scanImportService.GetImport(tenantUUID, importID)
+	if err != nil {
+		respondScanImportError(c, err, "Failed to retrieve scan import")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"import": job})
+}
+
+func respondScanImportError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidScan):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan report", "details": err.Error()})
+	case errors.Is(err, services.ErrScanImportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scan import not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+func isScanImportFormat(value string) bool {
+	for _, format := range models.ScanImportFormats {
+		if format == value {
+			return true
+		}
+	}
+	return false
+}
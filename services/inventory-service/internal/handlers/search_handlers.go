@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"inventory-service/internal/models"
+	"inventory-service/internal/search"
+	"inventory-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchPageSize bounds the page size of search results
+const maxSearchPageSize = 100
+
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// Search handles GET /api/v1/search?q=: the assets matching an inventory
+// query with their facet counts
+func (h *SearchHandler) Search(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	var filters models.SearchFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+	if filters.PageSize > maxSearchPageSize {
+		filters.PageSize = maxSearchPageSize
+	}
+
+	assets, total, facets, err := h.searchService.Search(tenantUUID, filters)
+	if err != nil {
+		respondSearchError(c, err, "Search failed")
+		return
+	}
+	if assets == nil {
+		assets = []models.Asset{}
+	}
+	totalPages := (total + filters.PageSize - 1) / filters.PageSize
+	c.JSON(http.StatusOK, gin.H{
+		"query":  filters.Query,
+		"assets": assets,
+		"facets": facets,
+		"pagination": gin.H{
+			"page":        filters.Page,
+			"page_size":   filters.PageSize,
+			"total":       total,
+			"total_pages": totalPages,
+			"has_next":    filters.Page < totalPages,
+			"has_prev":    filters.Page > 1,
+		},
+	})
+}
+
+// GetSearchFields handles GET /api/v1/search/fields, the fields queries
+// can test
+func (h *SearchHandler) GetSearchFields(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"fields": search.Fields()})
+}
+
+// GetSavedSearches handles GET /api/v1/searches, the user's saved searches
+func (h *SearchHandler) GetSavedSearches(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+
+	searches, err := h.searchService.ListSavedSearches(tenantUUID, userUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve saved searches", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"saved_searches": searches})
+}
+
+// GetSavedSearch handles GET /api/v1/searches/:id
+func (h *SearchHandler) GetSavedSearch(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	searchID, ok := pathUUID(c, "id", "Invalid saved search ID")
+	if !ok {
+		return
+	}
+
+	saved, err := h.searchService.GetSavedSearch(tenantUUID, userUUID, searchID)
+	if err != nil {
+		respondSearchError(c, err, "Failed to retrieve saved search")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"saved_search": saved})
+}
+
+// CreateSavedSearch handles POST /api/v1/searches
+func (h *SearchHandler) CreateSavedSearch(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	var input models.SavedSearchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	saved, err := h.searchService.CreateSavedSearch(tenantUUID, userUUID, &input)
+	if err != nil {
+		respondSearchError(c, err, "Failed to save search")
+		return
+	}
+	c.Header("Location", "/api/v1/searches/"+saved.ID.String())
+	c.JSON(http.StatusCreated, gin.H{"saved_search": saved})
+}
+
+// ReplaceSavedSearch handles PUT /api/v1/searches/:id
+func (h *SearchHandler) ReplaceSavedSearch(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	searchID, ok := pathUUID(c, "id", "Invalid saved search ID")
+	if !ok {
+		return
+	}
+	var input models.SavedSearchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	saved, err := h.searchService.ReplaceSavedSearch(tenantUUID, userUUID, searchID, &input)
+	if err != nil {
+		respondSearchError(c, err, "Failed to update saved search")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"saved_search": saved})
+}
+
+// DeleteSavedSearch handles DELETE /api/v1/searches/:id
+func (h *SearchHandler) DeleteSavedSearch(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	searchID, ok := pathUUID(c, "id", "Invalid saved search ID")
+	if !ok {
+		return
+	}
+
+	if err := h.searchService.DeleteSavedSearch(tenantUUID, userUUID, searchID); err != nil {
+		respondSearchError(c, err, "Failed to delete saved search")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondSearchError maps errors of search operations to responses
+func respondSearchError(c *gin.Context, err error, message string) {
+	var queryErr *search.Error
+	switch {
+	case errors.As(err, &queryErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query", "details": queryErr.Message, "position": queryErr.Position})
+	case errors.Is(err, services.ErrSavedSearchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+	case errors.Is(err, services.ErrSavedSearchExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A saved search with this name already exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"inventory-service/internal/models"
+	"inventory-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSnapshotPageSize bounds the page size of snapshot listings
+const maxSnapshotPageSize = 100
+
+type SnapshotHandler struct {
+	snapshotService *services.SnapshotService
+}
+
+func NewSnapshotHandler(snapshotService *services.SnapshotService) *SnapshotHandler {
+	return &SnapshotHandler{snapshotService: snapshotService}
+}
+
+// CreateSnapshot handles POST /api/v1/snapshots
+func (h *SnapshotHandler) CreateSnapshot(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	userUUID, ok := requestUser(c)
+	if !ok {
+		return
+	}
+	var input models.SnapshotInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	snapshot, err := h.snapshotService.CreateSnapshot(tenantUUID, userUUID, &input)
+	if err != nil {
+		respondSnapshotError(c, err, "Failed to create snapshot")
+		return
+	}
+	c.Header("Location", "/api/v1/snapshots/"+snapshot.ID.String())
+	c.JSON(http.StatusCreated, gin.H{"snapshot": snapshot})
+}
+
+// GetSnapshots handles GET /api/v1/snapshots, the latest first
+func (h *SnapshotHandler) GetSnapshots(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	var filters models.SnapshotFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+	if filters.PageSize > maxSnapshotPageSize {
+		filters.PageSize = maxSnapshotPageSize
+	}
+
+	snapshots, total, err := h.snapshotService.ListSnapshots(tenantUUID, filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve snapshots", "details": err.Error()})
+		return
+	}
+	totalPages := (total + filters.PageSize - 1) / filters.PageSize
+	c.JSON(http.StatusOK, gin.H{
+		"snapshots": snapshots,
+		"pagination": gin.H{
+			"page":        filters.Page,
+			"page_size":   filters.PageSize,
+			"total":       total,
+			"total_pages": totalPages,
+			"has_next":    filters.Page < totalPages,
+			"has_prev":    filters.Page > 1,
+		},
+	})
+}
+
+// GetSnapshot handles GET /api/v1/snapshots/:id
+func (h *SnapshotHandler) GetSnapshot(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	snapshotID, ok := pathUUID(c, "id", "Invalid snapshot ID")
+	if !ok {
+		return
+	}
+
+	snapshot, err := h.snapshotService.GetSnapshot(tenantUUID, snapshotID)
+	if err != nil {
+		respondSnapshotError(c, err, "Failed to retrieve snapshot")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshot": snapshot})
+}
+
+// DeleteSnapshot handles DELETE /api/v1/snapshots/:id
+func (h *SnapshotHandler) DeleteSnapshot(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+	snapshotID, ok := pathUUID(c, "id", "Invalid snapshot ID")
+	if !ok {
+		return
+	}
+
+	if err := h.snapshotService.DeleteSnapshot(tenantUUID, snapshotID); err != nil {
+		respondSnapshotError(c, err, "Failed to delete snapshot")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetDiff handles GET /api/v1/snapshots/diff?from=&to=: what changed in
+// the inventory between two snapshots or timestamps, to now by default
+func (h *SnapshotHandler) GetDiff(c *gin.Context) {
+	tenantUUID, ok := requestTenant(c)
+	if !ok {
+		return
+	}
+
+	diff, err := h.snapshotService.Diff(tenantUUID, c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondSnapshotError(c, err, "Failed to compare inventory")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"diff": diff})
+}
+
+// respondSnapshotError maps errors of snapshot operations to responses
+func respondSnapshotError(c *gin.Context, err error, message string) {
+	var validation *models.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "field": validation.Field, "details": validation.Message})
+	case errors.Is(err, services.ErrSnapshotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+	case errors.Is(err, services.ErrSnapshotExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A snapshot with this name already exists"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Values of the database enums writable through the API
+var (
+	AssetTypes       = []string{"server", "endpoint", "service", "appliance"}
+	EnvironmentTypes = []string{"production", "staging", "development", "test"}
+	ProtocolTypes    = []string{"TLS", "SSH", "IPSec", "VPN", "Database", "API"}
+	DiscoveryMethods = []string{"passive", "active", "manual", "integration"}
+)
+
+// MaxBulkAssets is the most assets one bulk upsert accepts
+const MaxBulkAssets = 1000
+
+// ownerEmailPattern mirrors the valid_owner_email constraint of network_assets
+var ownerEmailPattern = regexp.MustCompile(`^[^@]+@[^@]+\.[^@]+$`)
+
+// ValidationError reports an invalid field of a write request
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// AssetInput holds the writable fields of an asset. A create or PUT sets all
+// of them; a PATCH applies a partial document on top of the stored values.
+type AssetInput struct {
+	Hostname        *string                `json:"hostname"`
+	IPAddress       *string                `json:"ip_address"`
+	Port            *int                   `json:"port"`
+	AssetType       string                 `json:"asset_type"`
+	OperatingSystem *string                `json:"operating_system"`
+	Environment     *string                `json:"environment"`
+	BusinessUnit    *string                `json:"business_unit"`
+	OwnerEmail      *string                `json:"owner_email"`
+	Description     *string                `json:"description"`
+	Tags            map[string]interface{} `json:"tags"`
+	Metadata        map[string]interface{} `json:"metadata"`
+}
+
+// Normalize trims text fields, turns empty ones into nil and drops tags and
+// metadata keys set to null, as a PATCH does to remove them
+func (in *AssetInput) Normalize() {
+	for _, field := range []**string{&in.Hostname, &in.IPAddress, &in.OperatingSystem, &in.Environment,
+		&in.BusinessUnit, &in.OwnerEmail, &in.Description} {
+		*field = trimmedText(*field)
+	}
+	in.AssetType = strings.TrimSpace(in.AssetType)
+	in.Tags = withoutNullValues(in.Tags)
+	in.Metadata = withoutNullValues(in.Metadata)
+}
+
+// Validate checks the input against the constraints of network_assets
+func (in *AssetInput) Validate() error {
+	if in.Hostname == nil && in.IPAddress == nil {
+		return &ValidationError{Field: "hostname", Message: "hostname or ip_address is required"}
+	}
+	if in.Hostname != nil && len(*in.Hostname) > 255 {
+		return &ValidationError{Field: "hostname", Message: "must be at most 255 characters"}
+	}
+	if in.IPAddress != nil {
+		ip := net.ParseIP(*in.IPAddress)
+		if ip == nil {
+			return &ValidationError{Field: "ip_address", Message: "must be an IPv4 or IPv6 address"}
+		}
+		normalized := ip.String()
+		in.IPAddress = &normalized
+	}
+	if in.Port != nil && (*in.Port < 1 || *in.Port > 65535) {
+		return &ValidationError{Field: "port", Message: "must be between 1 and 65535"}
+	}
+	if !isOneOf(AssetTypes, in.AssetType) {
+		return &ValidationError{Field: "asset_type", Message: "must be one of " + strings.Join(AssetTypes, ", ")}
+	}
+	if in.Environment != nil && !isOneOf(EnvironmentTypes, *in.Environment) {
+		return &ValidationError{Field: "environment", Message: "must be one of " + strings.Join(EnvironmentTypes, ", ")}
+	}
+	if in.OperatingSystem != nil && len(*in.OperatingSystem) > 100 {
+		return &ValidationError{Field: "operating_system", Message: "must be at most 100 characters"}
+	}
+	if in.BusinessUnit != nil && len(*in.BusinessUnit) > 100 {
+		return &ValidationError{Field: "business_unit", Message: "must be at most 100 characters"}
+	}
+	if in.OwnerEmail != nil && (len(*in.OwnerEmail) > 255 || !ownerEmailPattern.MatchString(*in.OwnerEmail)) {
+		return &ValidationError{Field: "owner_email", Message: "must be an email address"}
+	}
+	return nil
+}
+
+// CryptoImplementationInput holds the writable fields of a crypto
+// implementation. The asset it belongs to is given by the route.
+type CryptoImplementationInput struct {
+	Protocol             string          `json:"protocol"`
+	ProtocolVersion      *string         `json:"protocol_version"`
+	CipherSuite          *string         `json:"cipher_suite"`
+	KeyExchangeAlgorithm *string         `json:"key_exchange_algorithm"`
+	SignatureAlgorithm   *string         `json:"signature_algorithm"`
+	SymmetricEncryption  *string         `json:"symmetric_encryption"`
+	HashAlgorithm        *string         `json:"hash_algorithm"`
+	KeySize              *int            `json:"key_size"`
+	CertificateID        *uuid.UUID      `json:"certificate_id"`
+	DiscoveryMethod      string          `json:"discovery_method"`
+	ConfidenceScore      *float64        `json:"confidence_score"`
+	SourceSensorID       *uuid.UUID      `json:"source_sensor_id"`
+	RawData              json.RawMessage `json:"raw_data"`
+	RiskScore            *int            `json:"risk_score"` // ignored: computed by the risk rules
+	ComplianceStatus     json.RawMessage `json:"compliance_status"`
+}
+
+// Normalize trims text fields, turns empty ones into nil and applies the
+// defaults of implementations recorded by hand
+func (in *CryptoImplementationInput) Normalize() {
+	for _, field := range []**string{&in.ProtocolVersion, &in.CipherSuite, &in.KeyExchangeAlgorithm,
+		&in.SignatureAlgorithm, &in.SymmetricEncryption, &in.HashAlgorithm} {
+		*field = trimmedText(*field)
+	}
+	in.Protocol = strings.TrimSpace(in.Protocol)
+	in.DiscoveryMethod = strings.TrimSpace(in.DiscoveryMethod)
+	if in.DiscoveryMethod == "" {
+		in.DiscoveryMethod = "manual"
+	}
+	if isJSONNull(in.RawData) {
+		in.RawData = nil
+	}
+	if isJSONNull(in.ComplianceStatus) {
+		in.ComplianceStatus = json.RawMessage(`{}`)
+	}
+}
+
+// Validate checks the input against the constraints of crypto_implementations
+func (in *CryptoImplementationInput) Validate() error {
+	if !isOneOf(ProtocolTypes, in.Protocol) {
+		return &ValidationError{Field: "protocol", Message: "must be one of " + strings.Join(ProtocolTypes, ", ")}
+	}
+	if !isOneOf(DiscoveryMethods, in.DiscoveryMethod) {
+		return &ValidationError{Field: "discovery_method", Message: "must be one of " + strings.Join(DiscoveryMethods, ", ")}
+	}
+	if in.ProtocolVersion != nil && len(*in.ProtocolVersion) > 20 {
+		return &ValidationError{Field: "protocol_version", Message: "must be at most 20 characters"}
+	}
+	if in.CipherSuite != nil && len(*in.CipherSuite) > 255 {
+		return &ValidationError{Field: "cipher_suite", Message: "must be at most 255 characters"}
+	}
+	for field, value := range map[string]*string{
+		"key_exchange_algorithm": in.KeyExchangeAlgorithm,
+		"signature_algorithm":    in.SignatureAlgorithm,
+		"symmetric_encryption":   in.SymmetricEncryption,
+		"hash_algorithm":         in.HashAlgorithm,
+	} {
+		if value != nil && len(*value) > 100 {
+			return &ValidationError{Field: field, Message: "must be at most 100 characters"}
+		}
+	}
+	if in.KeySize != nil && *in.KeySize <= 0 {
+		return &ValidationError{Field: "key_size", Message: "must be positive"}
+	}
+	if in.ConfidenceScore != nil && (*in.ConfidenceScore < 0 || *in.ConfidenceScore > 1) {
+		return &ValidationError{Field: "confidence_score", Message: "must be between 0 and 1"}
+	}
+	if in.RiskScore != nil && (*in.RiskScore < 0 || *in.RiskScore > 100) {
+		return &ValidationError{Field: "risk_score", Message: "must be between 0 and 100"}
+	}
+	if len(in.RawData) > 0 && !json.Valid(in.RawData) {
+		return &ValidationError{Field: "raw_data", Message: "must be JSON"}
+	}
+	if len(in.ComplianceStatus) > 0 && !isJSONObject(in.ComplianceStatus) {
+		return &ValidationError{Field: "compliance_status", Message: "must be a JSON object"}
+	}
+	return nil
+}
+
+// BulkAssetItem is one asset of a bulk upsert. Items with an ID update that
+// asset; others update the asset with the same address or hostname and
+// port, or create one. An ETag makes the update conditional.
+type BulkAssetItem struct {
+	ID   *uuid.UUID `json:"id"`
+	ETag string     `json:"etag"`
+	AssetInput
+}
+
+// BulkAssetRequest is the body of a bulk upsert
+type BulkAssetRequest struct {
+	Assets []json.RawMessage `json:"assets" binding:"required"`
+}
+
+// Bulk upsert outcomes of an item
+const (
+	BulkCreated = "created"
+	BulkUpdated = "updated"
+	BulkFailed  = "failed"
+)
+
+// BulkAssetResult is the outcome of one item of a bulk upsert
+type BulkAssetResult struct {
+	Index  int        `json:"index"`
+	Status string     `json:"status"`
+	ID     *uuid.UUID `json:"id,omitempty"`
+	ETag   string     `json:"etag,omitempty"`
+	Error  string     `json:"error,omitempty"`
+	Field  string     `json:"field,omitempty"`
+}
+
+// BulkAssetResponse sums up a bulk upsert
+type BulkAssetResponse struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Results []BulkAssetResult `json:"results"`
+}
+
+// ETag returns the entity tag of a record last updated at updatedAt.
+// Timestamps are stored with microsecond precision.
+func ETag(updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d"`, updatedAt.UnixMicro())
+}
+
+func trimmedText(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	text := strings.TrimSpace(*value)
+	if text == "" {
+		return nil
+	}
+	return &text
+}
+
+func withoutNullValues(values map[string]interface{}) map[string]interface{} {
+	cleaned := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if value != nil {
+			cleaned[key] = value
+		}
+	}
+	return cleaned
+}
+
+func isOneOf(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || strings.TrimSpace(string(raw)) == "null"
+}
+
+func isJSONObject(raw json.RawMessage) bool {
+	var object map[string]interface{}
+	return json.Unmarshal(raw, &object) == nil && object != nil
+}
@@ -0,0 +1,34 @@
+package models
+
+// CBOMFilters select the assets a CBOM export covers; certificates are
+// those the selected assets present, or all of the tenant's without filters
+type CBOMFilters struct {
+	BusinessUnit []string `form:"business_unit"`
+	Environment  []string `form:"environment"`
+}
+
+// Empty reports whether the filters select every asset
+func (f *CBOMFilters) Empty() bool {
+	return len(f.BusinessUnit) == 0 && len(f.Environment) == 0
+}
+
+// CBOMSkippedComponent is a component of an imported CBOM that was not
+// stored, with why
+type CBOMSkippedComponent struct {
+	BOMRef string `json:"bom_ref"`
+	Reason string `json:"reason"`
+}
+
+// CBOMImportResult sums up the import of a CBOM. Assets and certificates
+// already in the inventory are matched rather than created, and
+// implementations it already records are left as they are.
+type CBOMImportResult struct {
+	SerialNumber            string                 `json:"serial_number,omitempty"`
+	AssetsCreated           int                    `json:"assets_created"`
+	AssetsMatched           int                    `json:"assets_matched"`
+	CertificatesCreated     int                    `json:"certificates_created"`
+	CertificatesMatched     int                    `json:"certificates_matched"`
+	ImplementationsCreated  int                    `json:"implementations_created"`
+	ImplementationsExisting int                    `json:"implementations_existing"`
+	Skipped                 []CBOMSkippedComponent `json:"skipped"`
+}
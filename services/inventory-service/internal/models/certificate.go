@@ -0,0 +1,220 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Certificate lifecycle states, derived from the validity period
+const (
+	CertificateStatusValid       = "valid"
+	CertificateStatusExpiring    = "expiring" // expires within CertificateExpiringDays
+	CertificateStatusExpired     = "expired"
+	CertificateStatusNotYetValid = "not_yet_valid"
+	CertificateStatusUnknown     = "unknown" // validity period not recorded
+)
+
+// CertificateExpiringDays is how close to its expiry a certificate counts
+// as expiring
+const CertificateExpiringDays = 30
+
+// Certificate is an X.509 certificate presented by assets of a tenant
+type Certificate struct {
+	ID                      uuid.UUID  `json:"id"`
+	TenantID                uuid.UUID  `json:"tenant_id"`
+	SerialNumber            *string    `json:"serial_number,omitempty"`
+	SubjectDN               string     `json:"subject_dn"`
+	IssuerDN                string     `json:"issuer_dn"`
+	CommonName              *string    `json:"common_name,omitempty"`
+	SubjectAlternativeNames []string   `json:"subject_alternative_names"`
+	SignatureAlgorithm      *string    `json:"signature_algorithm,omitempty"`
+	PublicKeyAlgorithm      *string    `json:"public_key_algorithm,omitempty"`
+	PublicKeySize           *int       `json:"public_key_size,omitempty"`
+	NotBefore               *time.Time `json:"not_before,omitempty"`
+	NotAfter                *time.Time `json:"not_after,omitempty"`
+	FingerprintSHA1         *string    `json:"fingerprint_sha1,omitempty"`
+	FingerprintSHA256       string     `json:"fingerprint_sha256"`
+	SubjectKeyID            *string    `json:"subject_key_id,omitempty"`
+	AuthorityKeyID          *string    `json:"authority_key_id,omitempty"`
+	IsSelfSigned            bool       `json:"is_self_signed"`
+	IsCA                    bool       `json:"is_ca_certificate"`
+	KeyUsage                []string   `json:"key_usage"`
+	ExtendedKeyUsage        []string   `json:"extended_key_usage"`
+	PEM                     *string    `json:"certificate_pem,omitempty"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at"`
+
+	Status          string `json:"status"`
+	DaysUntilExpiry *int   `json:"days_until_expiry,omitempty"`
+	EndpointCount   int    `json:"endpoint_count"` // endpoints presenting the certificate
+}
+
+// SetStatus derives the certificate's status and days until expiry at now
+func (c *Certificate) SetStatus(now time.Time) {
+	c.DaysUntilExpiry = nil
+	switch {
+	case c.NotAfter == nil:
+		c.Status = CertificateStatusUnknown
+		return
+	case !c.NotAfter.After(now):
+		c.Status = CertificateStatusExpired
+	case c.NotBefore != nil && c.NotBefore.After(now):
+		c.Status = CertificateStatusNotYetValid
+	case c.NotAfter.Sub(now) <= CertificateExpiringDays*24*time.Hour:
+		c.Status = CertificateStatusExpiring
+	default:
+		c.Status = CertificateStatusValid
+	}
+	days := int(c.NotAfter.Sub(now).Hours() / 24)
+	c.DaysUntilExpiry = &days
+}
+
+// CertificateFilters selects certificates of the inventory
+type CertificateFilters struct {
+	Search             string   `form:"search"` // common name, subject or SAN
+	Issuer             string   `form:"issuer"` // substring of the issuer DN
+	KeyAlgorithm       []string `form:"key_algorithm"`
+	KeySize            []int    `form:"key_size"`
+	MinKeySize         int      `form:"min_key_size"`
+	MaxKeySize         int      `form:"max_key_size"`
+	SignatureAlgorithm []string `form:"signature_algorithm"`
+	SelfSigned         *bool    `form:"self_signed"`
+	CA                 *bool    `form:"ca"`
+	ExpiringWithinDays *int     `form:"expiring_within_days"` // not yet expired, expiring within N days
+	Expired            *bool    `form:"expired"`
+	Page               int      `form:"page"`
+	PageSize           int      `form:"page_size"`
+	SortBy             string   `form:"sort_by"`
+	SortOrder          string   `form:"sort_order"`
+}
+
+// CertificateEndpoint is an asset port presenting a certificate
+type CertificateEndpoint struct {
+	AssetID          uuid.UUID `json:"asset_id"`
+	Hostname         *string   `json:"hostname,omitempty"`
+	IPAddress        *string   `json:"ip_address,omitempty"`
+	Port             *int      `json:"port,omitempty"`
+	Environment      *string   `json:"environment,omitempty"`
+	OwnerEmail       *string   `json:"owner_email,omitempty"`
+	ImplementationID uuid.UUID `json:"crypto_implementation_id"`
+	Protocol         string    `json:"protocol"`
+	ProtocolVersion  *string   `json:"protocol_version,omitempty"`
+	LastVerifiedAt   time.Time `json:"last_verified_at"`
+	ExpiredInUse     bool      `json:"expired_in_use"` // seen presenting the certificate after it expired
+}
+
+// CertificateDetail is a certificate with the endpoints presenting it
+type CertificateDetail struct {
+	Certificate
+	Endpoints []CertificateEndpoint `json:"endpoints"`
+}
+
+// Positions of a certificate in its chain
+const (
+	ChainPositionLeaf         = "leaf"
+	ChainPositionIntermediate = "intermediate"
+	ChainPositionRoot         = "root"
+)
+
+// How a certificate was linked to the certificate it issued
+const (
+	ChainLinkKeyID    = "key_id"    // issuer's subject key identifier is the authority key identifier
+	ChainLinkIssuerDN = "issuer_dn" // issuer's subject DN is the issuer DN
+)
+
+// ChainLink is one certificate of a chain
+type ChainLink struct {
+	Position    string       `json:"position"`
+	LinkedBy    string       `json:"linked_by,omitempty"` // how it was found as the issuer of the previous link
+	Certificate *Certificate `json:"certificate"`
+}
+
+// Reasons a chain ends before a root
+const (
+	ChainIssuerNotFound = "issuer_not_found"
+	ChainLoop           = "loop"
+	ChainTooLong        = "too_long"
+)
+
+// CertificateChain is the chain from a certificate up to its root, as far
+// as the issuing certificates are in the inventory
+type CertificateChain struct {
+	Chain    []ChainLink `json:"chain"`
+	Complete bool        `json:"complete"`         // ends in a self-signed root
+	Broken   string      `json:"broken,omitempty"` // why it is incomplete
+}
+
+// CertificateSummary counts a tenant's certificates for audits
+type CertificateSummary struct {
+	Total                int            `json:"total"`
+	Expired              int            `json:"expired"`
+	ExpiredInUse         int            `json:"expired_in_use"` // expired and still presented by an endpoint
+	ExpiringWithin7Days  int            `json:"expiring_within_7_days"`
+	ExpiringWithin30Days int            `json:"expiring_within_30_days"`
+	ExpiringWithin60Days int            `json:"expiring_within_60_days"`
+	ExpiringWithin90Days int            `json:"expiring_within_90_days"`
+	SelfSigned           int            `json:"self_signed"`
+	CA                   int            `json:"ca"`
+	WeakKeys             int            `json:"weak_keys"`       // RSA and DSA keys under 2048 bits
+	WeakSignatures       int            `json:"weak_signatures"` // deprecated signature algorithms, such as MD5 and SHA-1
+	ByKeyAlgorithm       map[string]int `json:"by_key_algorithm"`
+	BySignatureAlgorithm map[string]int `json:"by_signature_algorithm"`
+	ByIssuer             map[string]int `json:"by_issuer"` // ten most common issuers
+	GeneratedAt          time.Time      `json:"generated_at"`
+}
+
+// Expiry reminder statuses
+const (
+	ReminderStatusOpen   = "open"
+	ReminderStatusClosed = "closed"
+)
+
+// CertificateReminder is the expiry reminder of a certificate served on an
+// endpoint, opened by the expiry scheduler as the certificate crosses its
+// first threshold
+type CertificateReminder struct {
+	ID                     uuid.UUID  `json:"id"`
+	CertificateID          uuid.UUID  `json:"certificate_id"`
+	CommonName             *string    `json:"common_name,omitempty"`
+	FingerprintSHA256      string     `json:"fingerprint_sha256"`
+	NotAfter               *time.Time `json:"not_after,omitempty"`
+	DaysUntilExpiry        *int       `json:"days_until_expiry,omitempty"`
+	AssetID                uuid.UUID  `json:"asset_id"`
+	Hostname               *string    `json:"hostname,omitempty"`
+	IPAddress              *string    `json:"ip_address,omitempty"`
+	Port                   int        `json:"port"`
+	OwnerEmail             *string    `json:"owner_email,omitempty"`
+	Status                 string     `json:"status"`
+	ThresholdDays          int        `json:"threshold_days"` // last threshold notified, 0 once expired
+	ExpiredInUse           bool       `json:"expired_in_use"` // served after it expired
+	NotifiedAt             time.Time  `json:"notified_at"`
+	ClosedAt               *time.Time `json:"closed_at,omitempty"`
+	CloseReason            *string    `json:"close_reason,omitempty"` // renewed or no_longer_served
+	RenewedByCertificateID *uuid.UUID `json:"renewed_by_certificate_id,omitempty"`
+	CreatedAt              time.Time  `json:"created_at"`
+}
+
+// CertificateReminderFilters selects expiry reminders
+type CertificateReminderFilters struct {
+	Status        string `form:"status"` // open (default), closed or all
+	OwnerEmail    string `form:"owner_email"`
+	AssetID       string `form:"asset_id"`
+	CertificateID string `form:"certificate_id"`
+	ExpiredInUse  *bool  `form:"expired_in_use"`
+	Page          int    `form:"page"`
+	PageSize      int    `form:"page_size"`
+}
+
+// CertificateHistoryEntry is a lifecycle event of a certificate
+type CertificateHistoryEntry struct {
+	ID                   uuid.UUID              `json:"id"`
+	CertificateID        uuid.UUID              `json:"certificate_id"`
+	EventType            string                 `json:"event_type"`
+	RelatedCertificateID *uuid.UUID             `json:"related_certificate_id,omitempty"` // the other certificate of a renewal
+	RelatedFingerprint   *string                `json:"related_fingerprint_sha256,omitempty"`
+	AssetID              *uuid.UUID             `json:"asset_id,omitempty"`
+	Port                 *int                   `json:"port,omitempty"`
+	Details              map[string]interface{} `json:"details"`
+	CreatedAt            time.Time              `json:"created_at"`
+}
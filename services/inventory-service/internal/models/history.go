@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources of inventory changes
+const (
+	ChangeSourceUser   = "user"
+	ChangeSourceSensor = "sensor"
+	ChangeSourceImport = "import"
+	ChangeSourceSystem = "system"
+)
+
+// Entity types of the change history
+const (
+	EntityAsset                = "asset"
+	EntityCryptoImplementation = "crypto_implementation"
+	EntityCertificate          = "certificate"
+)
+
+// ChangeSource is who makes a change to the inventory, as the history
+// records it. Reference names what the change came from, such as
+// scan_import:<id>.
+type ChangeSource struct {
+	Source    string
+	UserID    *uuid.UUID
+	Reference string
+}
+
+// UserChange is the source of a change a user makes through the API
+func UserChange(userID uuid.UUID) ChangeSource {
+	return ChangeSource{Source: ChangeSourceUser, UserID: &userID}
+}
+
+// InventoryChange is a version of an asset, crypto implementation or
+// certificate. Changes holds the fields the version changed, and every set
+// field for the created version.
+type InventoryChange struct {
+	ID         uuid.UUID              `json:"id"`
+	EntityType string                 `json:"entity_type"`
+	EntityID   uuid.UUID              `json:"entity_id"`
+	AssetID    *uuid.UUID             `json:"asset_id,omitempty"`
+	Version    int                    `json:"version"`
+	Operation  string                 `json:"operation"` // created, updated, deleted or restored
+	Changes    map[string]FieldChange `json:"changes"`
+	Source     string                 `json:"source"`
+	ChangedBy  *uuid.UUID             `json:"changed_by,omitempty"`
+	Reference  *string                `json:"reference,omitempty"`
+	ChangedAt  time.Time              `json:"changed_at"`
+}
+
+// FieldChange is the value of a field before and after a change
+type FieldChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+// ChangeFilters selects inventory changes
+type ChangeFilters struct {
+	EntityType string `form:"entity_type"`
+	EntityID   string `form:"entity_id"`
+	AssetID    string `form:"asset_id"` // changes of the asset and its implementations
+	Source     string `form:"source"`
+	Field      string `form:"field"` // changes of the field
+	Since      string `form:"since"` // RFC 3339
+	Until      string `form:"until"` // RFC 3339
+	Page       int    `form:"page"`
+	PageSize   int    `form:"page_size"`
+}
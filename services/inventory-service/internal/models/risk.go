@@ -0,0 +1,41 @@
+package models
+
+import (
+	"inventory-service/internal/risk"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RiskRules are the rules a tenant's implementations are scored with: the
+// default rules adjusted by the tenant's latest override version
+type RiskRules struct {
+	RuleVersion    string       `json:"rule_version"` // default-v1, or default-v1/tenant-v3
+	DefaultVersion int          `json:"default_version"`
+	TenantVersion  *int         `json:"tenant_version,omitempty"`
+	Rules          []risk.Rule  `json:"rules"`
+	Overrides      *RiskRuleSet `json:"overrides,omitempty"`
+}
+
+// RiskRuleSet is a version of a tenant's risk rule overrides
+type RiskRuleSet struct {
+	Version   int               `json:"version"`
+	Format    string            `json:"format"`
+	Source    string            `json:"source,omitempty"`   // the document as submitted
+	Document  *risk.TenantRules `json:"document,omitempty"` // the document as validated
+	Comment   *string           `json:"comment,omitempty"`
+	CreatedBy *uuid.UUID        `json:"created_by,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// RiskBreakdown is the stored score of an implementation and the rules
+// that make it up
+type RiskBreakdown struct {
+	CryptoImplementationID uuid.UUID     `json:"crypto_implementation_id"`
+	RiskScore              int           `json:"risk_score"`
+	RiskLevel              string        `json:"risk_level"`
+	RuleVersion            *string       `json:"rule_version,omitempty"`
+	EvaluatedAt            *time.Time    `json:"evaluated_at,omitempty"`
+	Stale                  bool          `json:"stale"` // scored with older rules, or before the implementation changed
+	Factors                []risk.Factor `json:"factors"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScanImportFormats are the scanner outputs an import reads
+var ScanImportFormats = []string{"nmap", "sslscan", "testssl", "sslyze"}
+
+// Scan import job statuses
+const (
+	ScanImportPending    = "pending"
+	ScanImportProcessing = "processing"
+	ScanImportCompleted  = "completed"
+	ScanImportFailed     = "failed"
+)
+
+// ScanImport is an uploaded scan and the outcome of importing it. Each host
+// and port of the scan is a row; rows listed in Errors were not imported.
+type ScanImport struct {
+	ID            uuid.UUID            `json:"id"`
+	Format        string               `json:"format"`
+	Filename      *string              `json:"filename,omitempty"`
+	DryRun        bool                 `json:"dry_run"`
+	Status        string               `json:"status"`
+	Attempts      int                  `json:"attempts"`
+	RowCount      int                  `json:"row_count"`
+	ImportedCount int                  `json:"imported_count"`
+	FailedCount   int                  `json:"failed_count"`
+	Summary       ScanImportSummary    `json:"summary"`
+	Errors        []ScanImportRowError `json:"errors"`
+	LastError     *string              `json:"last_error,omitempty"`
+	CreatedBy     *uuid.UUID           `json:"created_by,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	StartedAt     *time.Time           `json:"started_at,omitempty"`
+	CompletedAt   *time.Time           `json:"completed_at,omitempty"`
+
+	TenantID uuid.UUID `json:"-"`
+	Payload  []byte    `json:"-"`
+}
+
+// ScanImportSummary counts what an import created and what it found
+// already in the inventory. For a dry run these are what it would create.
+type ScanImportSummary struct {
+	AssetsCreated           int `json:"assets_created"`
+	AssetsMatched           int `json:"assets_matched"`
+	CertificatesCreated     int `json:"certificates_created"`
+	CertificatesMatched     int `json:"certificates_matched"`
+	ImplementationsCreated  int `json:"implementations_created"`
+	ImplementationsExisting int `json:"implementations_existing"`
+}
+
+// ScanImportRowError is a row of a scan that was not imported, with why.
+// Row is its position in the scan, from 1; Target is the host and port.
+type ScanImportRowError struct {
+	Row    int    `json:"row"`
+	Target string `json:"target,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ScanImportFilters select and page the import jobs of a tenant
+type ScanImportFilters struct {
+	Status   string `form:"status"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
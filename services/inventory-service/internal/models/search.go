@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchFilters is an inventory query (see package search) with the page
+// of matching assets to return
+type SearchFilters struct {
+	Query     string `form:"q"`
+	Page      int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+	SortBy    string `form:"sort_by"`
+	SortOrder string `form:"sort_order"`
+}
+
+// FacetCount is the number of matching assets with a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets count the assets matching a query by asset_type,
+// environment, business_unit, risk_level and protocol
+type SearchFacets map[string][]FacetCount
+
+// SavedSearch is a query saved by a user
+type SavedSearch struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	Query       string    `json:"query"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SavedSearchInput creates or replaces a saved search
+type SavedSearchInput struct {
+	Name        string  `json:"name" binding:"required,max=255"`
+	Description *string `json:"description"`
+	Query       string  `json:"query" binding:"required"`
+}
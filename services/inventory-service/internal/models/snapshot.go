@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InventorySnapshot names the tenant's inventory as of a point in time.
+// The inventory of any time is kept by the change history, so a snapshot
+// stores no copy of it; Summary counts it as of TakenAt.
+type InventorySnapshot struct {
+	ID          uuid.UUID       `json:"id"`
+	Name        string          `json:"name"`
+	Description *string         `json:"description,omitempty"`
+	TakenAt     time.Time       `json:"taken_at"`
+	Summary     SnapshotSummary `json:"summary"`
+	CreatedBy   *uuid.UUID      `json:"created_by,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// SnapshotSummary counts the inventory as of a snapshot. Risk levels are
+// those of the risk summary: high from 70, medium from 40, low from 1.
+type SnapshotSummary struct {
+	Assets                    int `json:"assets"`
+	CryptoImplementations     int `json:"crypto_implementations"`
+	HighRiskImplementations   int `json:"high_risk_implementations"`
+	MediumRiskImplementations int `json:"medium_risk_implementations"`
+	LowRiskImplementations    int `json:"low_risk_implementations"`
+	WeakAlgorithms            int `json:"weak_algorithms"`
+	Certificates              int `json:"certificates"`
+	ExpiredCertificates       int `json:"expired_certificates"`
+}
+
+// SnapshotInput creates a snapshot. TakenAt defaults to now and cannot be
+// in the future.
+type SnapshotInput struct {
+	Name        string     `json:"name" binding:"required,max=255"`
+	Description *string    `json:"description"`
+	TakenAt     *time.Time `json:"taken_at"`
+}
+
+// SnapshotFilters pages through snapshots
+type SnapshotFilters struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size"`
+}
+
+// InventoryDiff is what changed in the inventory between two points in
+// time
+type InventoryDiff struct {
+	From                  DiffPoint          `json:"from"`
+	To                    DiffPoint          `json:"to"`
+	Assets                AssetDiff          `json:"assets"`
+	CryptoImplementations ImplementationDiff `json:"crypto_implementations"`
+	NewWeakAlgorithms     []WeakAlgorithm    `json:"new_weak_algorithms"`
+	Certificates          CertificateDiff    `json:"certificates"`
+}
+
+// DiffPoint is a side of a diff: a snapshot or a timestamp
+type DiffPoint struct {
+	SnapshotID *uuid.UUID `json:"snapshot_id,omitempty"`
+	Name       *string    `json:"name,omitempty"`
+	At         time.Time  `json:"at"`
+}
+
+// AssetDiff lists assets added and removed
+type AssetDiff struct {
+	Added   []DiffAsset `json:"added"`
+	Removed []DiffAsset `json:"removed"`
+}
+
+// ImplementationDiff lists implementations added and removed, and those
+// present on both sides whose risk score went up or down
+type ImplementationDiff struct {
+	Added         []DiffImplementation `json:"added"`
+	Removed       []DiffImplementation `json:"removed"`
+	RiskIncreased []DiffImplementation `json:"risk_increased"`
+	RiskDecreased []DiffImplementation `json:"risk_decreased"`
+}
+
+// CertificateDiff lists certificates added, and certificates that expired
+// in between
+type CertificateDiff struct {
+	Added   []DiffCertificate `json:"added"`
+	Expired []DiffCertificate `json:"expired"`
+}
+
+// DiffAsset is an asset as of the side of the diff it is listed for
+type DiffAsset struct {
+	ID           uuid.UUID `json:"id"`
+	Hostname     *string   `json:"hostname,omitempty"`
+	IPAddress    *string   `json:"ip_address,omitempty"`
+	Port         *int      `json:"port,omitempty"`
+	AssetType    *string   `json:"asset_type,omitempty"`
+	Environment  *string   `json:"environment,omitempty"`
+	BusinessUnit *string   `json:"business_unit,omitempty"`
+}
+
+// DiffImplementation is a crypto implementation as of the side of the diff
+// it is listed for. PreviousRiskScore is set for risk changes.
+type DiffImplementation struct {
+	ID                uuid.UUID `json:"id"`
+	AssetID           uuid.UUID `json:"asset_id"`
+	Port              *int      `json:"port,omitempty"`
+	Protocol          string    `json:"protocol"`
+	ProtocolVersion   *string   `json:"protocol_version,omitempty"`
+	CipherSuite       *string   `json:"cipher_suite,omitempty"`
+	RiskScore         int       `json:"risk_score"`
+	PreviousRiskScore *int      `json:"previous_risk_score,omitempty"`
+}
+
+// WeakAlgorithm is an algorithm the knowledge base deprecates, with the
+// implementations and assets using it
+type WeakAlgorithm struct {
+	Kind            string `json:"kind"`
+	Name            string `json:"name"`
+	Implementations int    `json:"implementations"`
+	Assets          int    `json:"assets"`
+}
+
+// DiffCertificate is a certificate of the diff
+type DiffCertificate struct {
+	ID                uuid.UUID  `json:"id"`
+	CommonName        *string    `json:"common_name,omitempty"`
+	SubjectDN         string     `json:"subject_dn"`
+	IssuerDN          string     `json:"issuer_dn"`
+	NotAfter          *time.Time `json:"not_after,omitempty"`
+	FingerprintSHA256 string     `json:"fingerprint_sha256"`
+}
@@ -0,0 +1,417 @@
+package risk
+
+import (
+	"inventory-service/internal/algorithms"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Subject holds the fields of an implementation rules test; nil fields
+// have no value
+type Subject struct {
+	Protocol             string
+	ProtocolVersion      *string
+	CipherSuite          *string
+	KeyExchangeAlgorithm *string
+	SignatureAlgorithm   *string
+	SymmetricEncryption  *string
+	HashAlgorithm        *string
+	KeySize              *int
+	DiscoveryMethod      string
+	ConfidenceScore      float64
+
+	// resolved caches the knowledge base entries of catalog fields
+	resolved map[string]*algorithms.Entry
+}
+
+// Factor is a rule an implementation matched
+type Factor struct {
+	RuleID      string `json:"rule_id"`
+	Title       string `json:"title"`
+	Explanation string `json:"explanation,omitempty"`
+	Weight      int    `json:"weight"`
+	Source      string `json:"source"`
+}
+
+// Result is the score of an implementation and the rules that make it up
+type Result struct {
+	Score   int      `json:"score"`
+	Factors []Factor `json:"factors"`
+}
+
+// Evaluate scores a subject with merged rules
+func Evaluate(rules []Rule, subject *Subject) Result {
+	result := Result{Factors: []Factor{}}
+	for i := range rules {
+		rule := &rules[i]
+		if !matches(&rule.When, subject) {
+			continue
+		}
+		result.Score += rule.Weight
+		result.Factors = append(result.Factors, Factor{
+			RuleID:      rule.ID,
+			Title:       rule.Title,
+			Explanation: rule.Explanation,
+			Weight:      rule.Weight,
+			Source:      rule.Source,
+		})
+	}
+	if result.Score > MaxScore {
+		result.Score = MaxScore
+	}
+	return result
+}
+
+func matches(condition *Condition, subject *Subject) bool {
+	switch {
+	case condition.All != nil:
+		for i := range condition.All {
+			if !matches(&condition.All[i], subject) {
+				return false
+			}
+		}
+		return true
+	case condition.Any != nil:
+		for i := range condition.Any {
+			if matches(&condition.Any[i], subject) {
+				return true
+			}
+		}
+		return false
+	case condition.Not != nil:
+		return !matches(condition.Not, subject)
+	}
+
+	value, ok := subject.field(condition.Field)
+	switch condition.Op {
+	case "exists":
+		return ok
+	case "missing":
+		return !ok
+	}
+	if !ok {
+		// Only ne and not_in hold for a missing value
+		return condition.Op == "ne" || condition.Op == "not_in"
+	}
+
+	switch condition.Op {
+	case "eq":
+		return equal(value, condition.Value)
+	case "ne":
+		return !equal(value, condition.Value)
+	case "in", "not_in":
+		found := false
+		for _, candidate := range condition.Value.([]interface{}) {
+			if equal(value, candidate) {
+				found = true
+				break
+			}
+		}
+		return found == (condition.Op == "in")
+	case "token":
+		tokens := tokenize(value)
+		for _, candidate := range condition.Value.([]interface{}) {
+			text, _ := scalar(candidate)
+			if tokens[strings.ToUpper(text)] {
+				return true
+			}
+		}
+		return false
+	case "matches":
+		return compiled(condition.Value.(string)).MatchString(value)
+	case "lt", "lte", "gt", "gte":
+		actual, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		limit, _ := number(condition.Value)
+		return compare(actual, limit, condition.Op)
+	case "version_lt", "version_lte", "version_gt", "version_gte":
+		actual, ok := parseVersion(value)
+		if !ok {
+			return false
+		}
+		text, _ := scalar(condition.Value)
+		limit, _ := parseVersion(text)
+		return compare(float64(compareVersions(actual, limit)), 0, strings.TrimPrefix(condition.Op, "version_"))
+	}
+	return false
+}
+
+// field returns a subject field as text, and whether it has a value
+func (s *Subject) field(name string) (string, bool) {
+	text := func(value *string) (string, bool) {
+		if value == nil || strings.TrimSpace(*value) == "" {
+			return "", false
+		}
+		return strings.TrimSpace(*value), true
+	}
+	switch name {
+	case "protocol":
+		return s.Protocol, s.Protocol != ""
+	case "protocol_version":
+		return text(s.ProtocolVersion)
+	case "cipher_suite":
+		return text(s.CipherSuite)
+	case "key_exchange_algorithm":
+		return text(s.KeyExchangeAlgorithm)
+	case "signature_algorithm":
+		return text(s.SignatureAlgorithm)
+	case "symmetric_encryption":
+		return text(s.SymmetricEncryption)
+	case "hash_algorithm":
+		return text(s.HashAlgorithm)
+	case "key_size":
+		if s.KeySize == nil {
+			return "", false
+		}
+		return strconv.Itoa(*s.KeySize), true
+	case "discovery_method":
+		return s.DiscoveryMethod, s.DiscoveryMethod != ""
+	case "confidence_score":
+		return strconv.FormatFloat(s.ConfidenceScore, 'f', -1, 64), true
+	}
+
+	if algorithm, attribute, found := strings.Cut(name, "."); found {
+		if entry := s.Algorithm(algorithm); entry != nil {
+			return catalogAttribute(entry, attribute)
+		}
+	}
+	return "", false
+}
+
+// Algorithm resolves one of the subject's algorithms, cipher,
+// key_exchange, signature or hash, in the knowledge base, among the kinds
+// that fit its protocol. It returns nil for an algorithm the subject does
+// not name or the knowledge base does not know.
+func (s *Subject) Algorithm(name string) *algorithms.Entry {
+	if entry, done := s.resolved[name]; done {
+		return entry
+	}
+
+	ssh := strings.EqualFold(s.Protocol, "SSH")
+	tls := strings.EqualFold(s.Protocol, "TLS")
+	var values []*string
+	var kinds []string
+	switch name {
+	case "cipher":
+		values = []*string{s.CipherSuite}
+		switch {
+		case ssh:
+			values, kinds = append(values, s.SymmetricEncryption), []string{algorithms.KindSSHCipher}
+		case tls:
+			kinds = []string{algorithms.KindTLSCipherSuite}
+		default:
+			kinds = []string{algorithms.KindTLSCipherSuite, algorithms.KindSSHCipher}
+		}
+	case "key_exchange":
+		values = []*string{s.KeyExchangeAlgorithm}
+		switch {
+		case ssh:
+			kinds = []string{algorithms.KindSSHKex}
+		case tls:
+			kinds = []string{algorithms.KindNamedGroup}
+		default:
+			kinds = []string{algorithms.KindNamedGroup, algorithms.KindSSHKex}
+		}
+	case "signature":
+		values = []*string{s.SignatureAlgorithm}
+		switch {
+		case ssh:
+			kinds = []string{algorithms.KindSSHHostKey}
+		case tls:
+			kinds = []string{algorithms.KindSignatureScheme}
+		default:
+			kinds = []string{algorithms.KindSignatureScheme, algorithms.KindSSHHostKey}
+		}
+	case "hash":
+		values, kinds = []*string{s.HashAlgorithm}, []string{algorithms.KindHash}
+	}
+
+	var resolved *algorithms.Entry
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		if entry, ok := algorithms.Lookup(*value, kinds...); ok {
+			resolved = &entry
+			break
+		}
+	}
+	if s.resolved == nil {
+		s.resolved = make(map[string]*algorithms.Entry)
+	}
+	s.resolved[name] = resolved
+	return resolved
+}
+
+// catalogAttribute returns a knowledge base attribute as text, and whether
+// it has a value
+func catalogAttribute(entry *algorithms.Entry, attribute string) (string, bool) {
+	text := func(value string) (string, bool) { return value, value != "" }
+	switch attribute {
+	case "name":
+		return entry.Name, true
+	case "kind":
+		return entry.Kind, true
+	case "key_exchange":
+		return text(entry.KeyExchange)
+	case "authentication":
+		return text(entry.Authentication)
+	case "bulk_cipher":
+		return text(entry.BulkCipher)
+	case "mode":
+		return text(entry.Mode)
+	case "mac":
+		return text(entry.MAC)
+	case "prf":
+		return text(entry.PRF)
+	case "hash":
+		return text(entry.Hash)
+	case "strength_bits":
+		return strconv.Itoa(entry.StrengthBits), true
+	case "deprecated":
+		return strconv.FormatBool(entry.Deprecated), true
+	case "fips_approved":
+		return strconv.FormatBool(entry.FIPSApproved), true
+	case "cnsa2_compliant":
+		return strconv.FormatBool(entry.CNSA2), true
+	case "quantum_vulnerable":
+		return strconv.FormatBool(entry.QuantumVulnerable), true
+	}
+	return "", false
+}
+
+func compare(actual, limit float64, op string) bool {
+	switch op {
+	case "lt":
+		return actual < limit
+	case "lte":
+		return actual <= limit
+	case "gt":
+		return actual > limit
+	case "gte":
+		return actual >= limit
+	}
+	return false
+}
+
+// equal compares a field with a rule value, numerically when both are
+// numbers and otherwise as normalized text
+func equal(value string, expected interface{}) bool {
+	if limit, ok := number(expected); ok {
+		if actual, err := strconv.ParseFloat(value, 64); err == nil {
+			return actual == limit
+		}
+	}
+	text, _ := scalar(expected)
+	return normalize(value) == normalize(text)
+}
+
+// normalize uppercases text and drops the separators that vary between
+// sources, so SHA-1, sha_1 and SHA1 are equal
+func normalize(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', ' ':
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, text)
+}
+
+// tokenize splits text into its uppercased runs of letters and digits
+func tokenize(text string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, token := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		tokens[strings.ToUpper(token)] = true
+	}
+	return tokens
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// parseVersion extracts the first dotted number of a version such as 1.2,
+// TLSv1.3 or SSH-2.0
+func parseVersion(text string) ([]int, bool) {
+	match := versionPattern.FindString(text)
+	if match == "" {
+		return nil, false
+	}
+	parts := strings.Split(match, ".")
+	version := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+// compareVersions returns -1, 0 or 1; missing components are zero
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// scalar renders a decoded string, number or boolean as text
+func scalar(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// number returns a decoded number; YAML decodes integers as int and JSON
+// as float64
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, !math.IsNaN(v)
+	}
+	return 0, false
+}
+
+var patterns sync.Map
+
+// compiled returns the case-insensitive regular expression of a validated
+// pattern
+func compiled(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile("(?i)" + pattern)
+	patterns.Store(pattern, re)
+	return re
+}
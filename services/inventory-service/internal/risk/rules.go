@@ -0,0 +1,384 @@
+// Package risk scores crypto implementations with declarative rules. A rule
+// set lists weighted rules whose conditions test implementation fields; the
+// score of an implementation is the sum of the weights of the rules it
+// matches, capped at 100, and its breakdown lists those rules with their
+// explanations. Tenants adjust the platform's default rules with an
+// override document that reweights or disables default rules and adds or
+// replaces rules of their own.
+package risk
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule set document formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Rule sources reported in breakdowns
+const (
+	SourceDefault = "default"
+	SourceTenant  = "tenant"
+)
+
+// MaxScore is the highest risk score
+const MaxScore = 100
+
+//go:embed default_rules.yaml
+var defaultRulesYAML []byte
+
+// Rule is a weighted condition on implementation fields
+type Rule struct {
+	ID          string    `json:"id" yaml:"id"`
+	Title       string    `json:"title" yaml:"title"`
+	Explanation string    `json:"explanation" yaml:"explanation"`
+	Weight      int       `json:"weight" yaml:"weight"`
+	When        Condition `json:"when" yaml:"when"`
+
+	// Source is set when rule sets are merged
+	Source string `json:"source,omitempty" yaml:"-"`
+}
+
+// Condition tests implementation fields. Exactly one of All, Any, Not or
+// Field is set; Field conditions compare the field with Value using Op.
+//
+// Operators:
+//   - eq, ne, in, not_in: text equality ignoring case, '-', '_' and
+//     spaces, so SHA-1 equals sha1; numbers compare numerically
+//   - token: the field, split at every character that is not a letter or
+//     digit, contains one of the values as a whole token, so the 3DES of
+//     TLS_RSA_WITH_3DES_EDE_CBC_SHA is not the token DES
+//   - matches: the field matches the regular expression, case-insensitively
+//   - lt, lte, gt, gte: numeric comparison
+//   - version_lt, version_lte, version_gt, version_gte: dotted version
+//     comparison, component by component, so 1.10 is after 1.2
+//   - exists, missing: the field has or has no value
+//
+// Besides the implementation fields, conditions can test what the algorithm
+// knowledge base knows of an implementation's algorithms: see
+// CatalogAlgorithms.
+type Condition struct {
+	All   []Condition `json:"all,omitempty" yaml:"all,omitempty"`
+	Any   []Condition `json:"any,omitempty" yaml:"any,omitempty"`
+	Not   *Condition  `json:"not,omitempty" yaml:"not,omitempty"`
+	Field string      `json:"field,omitempty" yaml:"field,omitempty"`
+	Op    string      `json:"op,omitempty" yaml:"op,omitempty"`
+	Value interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// RuleSet is a versioned list of rules
+type RuleSet struct {
+	Version int    `json:"version" yaml:"version"`
+	Rules   []Rule `json:"rules" yaml:"rules"`
+}
+
+// Override reweights or disables a default rule
+type Override struct {
+	Rule    string `json:"rule" yaml:"rule"`
+	Weight  *int   `json:"weight,omitempty" yaml:"weight,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// TenantRules is a tenant's override document. Rules with the ID of a
+// default rule replace it; others are added.
+type TenantRules struct {
+	Overrides []Override `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+	Rules     []Rule     `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// Fields are the implementation fields rules can test
+var Fields = map[string]bool{
+	"protocol":               true,
+	"protocol_version":       true,
+	"cipher_suite":           true,
+	"key_exchange_algorithm": true,
+	"signature_algorithm":    true,
+	"symmetric_encryption":   true,
+	"hash_algorithm":         true,
+	"key_size":               true,
+	"discovery_method":       true,
+	"confidence_score":       true,
+}
+
+// CatalogAlgorithms are the implementation algorithms rules can test through
+// the algorithm knowledge base, as <algorithm>.<attribute> fields such as
+// cipher.strength_bits or signature.deprecated. The algorithm is resolved
+// among the kinds that fit the implementation's protocol; a field whose
+// algorithm the knowledge base does not know has no value.
+var CatalogAlgorithms = map[string]bool{
+	"cipher":       true, // cipher_suite, or symmetric_encryption for SSH
+	"key_exchange": true, // key_exchange_algorithm: a named group or SSH key exchange
+	"signature":    true, // signature_algorithm: a signature scheme or SSH host key
+	"hash":         true, // hash_algorithm
+}
+
+// CatalogAttributes are the knowledge base attributes catalog fields test
+var CatalogAttributes = map[string]bool{
+	"name":               true,
+	"kind":               true,
+	"key_exchange":       true,
+	"authentication":     true,
+	"bulk_cipher":        true,
+	"mode":               true,
+	"mac":                true,
+	"prf":                true,
+	"hash":               true,
+	"strength_bits":      true,
+	"deprecated":         true,
+	"fips_approved":      true,
+	"cnsa2_compliant":    true,
+	"quantum_vulnerable": true,
+}
+
+var ruleIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// ValidationError reports an invalid rule document
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// DefaultRules returns the platform's default rule set
+func DefaultRules() *RuleSet {
+	rules, err := ParseRuleSet(defaultRulesYAML, FormatYAML)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default risk rules: %v", err))
+	}
+	return rules
+}
+
+// ParseRuleSet decodes and validates a rule set document
+func ParseRuleSet(document []byte, format string) (*RuleSet, error) {
+	var rules RuleSet
+	if err := decode(document, format, &rules); err != nil {
+		return nil, err
+	}
+	if err := validateRules(rules.Rules, "rules"); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// ParseTenantRules decodes a tenant's override document and validates it
+// against the default rules it adjusts
+func ParseTenantRules(document []byte, format string, defaults *RuleSet) (*TenantRules, error) {
+	var tenant TenantRules
+	if err := decode(document, format, &tenant); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(defaults.Rules))
+	for _, rule := range defaults.Rules {
+		known[rule.ID] = true
+	}
+	overridden := make(map[string]bool, len(tenant.Overrides))
+	for i, override := range tenant.Overrides {
+		path := fmt.Sprintf("overrides[%d]", i)
+		switch {
+		case !known[override.Rule]:
+			return nil, &ValidationError{Path: path + ".rule", Message: fmt.Sprintf("unknown default rule %q", override.Rule)}
+		case overridden[override.Rule]:
+			return nil, &ValidationError{Path: path + ".rule", Message: fmt.Sprintf("rule %q is overridden twice", override.Rule)}
+		case override.Weight == nil && override.Enabled == nil:
+			return nil, &ValidationError{Path: path, Message: "weight or enabled is required"}
+		case override.Weight != nil && (*override.Weight < 0 || *override.Weight > MaxScore):
+			return nil, &ValidationError{Path: path + ".weight", Message: fmt.Sprintf("must be between 0 and %d", MaxScore)}
+		}
+		overridden[override.Rule] = true
+	}
+	if err := validateRules(tenant.Rules, "rules"); err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// Merge applies a tenant's overrides to the default rules. A nil tenant
+// leaves them unchanged.
+func Merge(defaults *RuleSet, tenant *TenantRules) []Rule {
+	overrides := make(map[string]Override)
+	replacements := make(map[string]Rule)
+	var added []Rule
+	if tenant != nil {
+		for _, override := range tenant.Overrides {
+			overrides[override.Rule] = override
+		}
+		known := make(map[string]bool, len(defaults.Rules))
+		for _, rule := range defaults.Rules {
+			known[rule.ID] = true
+		}
+		for _, rule := range tenant.Rules {
+			rule.Source = SourceTenant
+			if known[rule.ID] {
+				replacements[rule.ID] = rule
+			} else {
+				added = append(added, rule)
+			}
+		}
+	}
+
+	merged := make([]Rule, 0, len(defaults.Rules)+len(added))
+	for _, rule := range defaults.Rules {
+		rule.Source = SourceDefault
+		if replacement, ok := replacements[rule.ID]; ok {
+			rule = replacement
+		}
+		if override, ok := overrides[rule.ID]; ok {
+			if override.Enabled != nil && !*override.Enabled {
+				continue
+			}
+			if override.Weight != nil {
+				rule.Weight = *override.Weight
+			}
+		}
+		merged = append(merged, rule)
+	}
+	return append(merged, added...)
+}
+
+func decode(document []byte, format string, out interface{}) error {
+	switch format {
+	case FormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(document))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(out); err != nil {
+			return &ValidationError{Message: "invalid YAML: " + err.Error()}
+		}
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(document))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(out); err != nil {
+			return &ValidationError{Message: "invalid JSON: " + err.Error()}
+		}
+	default:
+		return &ValidationError{Message: fmt.Sprintf("unsupported format %q", format)}
+	}
+	return nil
+}
+
+func validateRules(rules []Rule, path string) error {
+	seen := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		rulePath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case !ruleIDPattern.MatchString(rule.ID):
+			return &ValidationError{Path: rulePath + ".id", Message: "must be lowercase letters, digits, '.', '_' or '-'"}
+		case seen[rule.ID]:
+			return &ValidationError{Path: rulePath + ".id", Message: fmt.Sprintf("duplicate rule %q", rule.ID)}
+		case strings.TrimSpace(rule.Title) == "":
+			return &ValidationError{Path: rulePath + ".title", Message: "is required"}
+		case rule.Weight < 0 || rule.Weight > MaxScore:
+			return &ValidationError{Path: rulePath + ".weight", Message: fmt.Sprintf("must be between 0 and %d", MaxScore)}
+		}
+		seen[rule.ID] = true
+		if err := validateCondition(&rule.When, rulePath+".when"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateCondition(condition *Condition, path string) error {
+	kinds := 0
+	for _, set := range []bool{condition.All != nil, condition.Any != nil, condition.Not != nil, condition.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return &ValidationError{Path: path, Message: "exactly one of all, any, not or field is required"}
+	}
+
+	switch {
+	case condition.All != nil || condition.Any != nil:
+		children, name := condition.All, "all"
+		if condition.Any != nil {
+			children, name = condition.Any, "any"
+		}
+		if len(children) == 0 {
+			return &ValidationError{Path: path + "." + name, Message: "must not be empty"}
+		}
+		for i := range children {
+			if err := validateCondition(&children[i], fmt.Sprintf("%s.%s[%d]", path, name, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case condition.Not != nil:
+		return validateCondition(condition.Not, path+".not")
+	}
+
+	if !Fields[condition.Field] && !catalogField(condition.Field) {
+		return &ValidationError{Path: path + ".field", Message: fmt.Sprintf("unknown field %q", condition.Field)}
+	}
+	if err := compileValue(condition); err != nil {
+		return &ValidationError{Path: path + ".value", Message: err.Error()}
+	}
+	return nil
+}
+
+func catalogField(field string) bool {
+	algorithm, attribute, found := strings.Cut(field, ".")
+	return found && CatalogAlgorithms[algorithm] && CatalogAttributes[attribute]
+}
+
+// compileValue checks that a field condition's value suits its operator
+func compileValue(condition *Condition) error {
+	switch condition.Op {
+	case "exists", "missing":
+		if condition.Value != nil {
+			return fmt.Errorf("%s takes no value", condition.Op)
+		}
+	case "eq", "ne":
+		if _, ok := scalar(condition.Value); !ok {
+			return fmt.Errorf("%s takes a string or number", condition.Op)
+		}
+	case "in", "not_in", "token":
+		values, ok := condition.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("%s takes a non-empty list", condition.Op)
+		}
+		for _, value := range values {
+			if _, ok := scalar(value); !ok {
+				return fmt.Errorf("%s takes a list of strings or numbers", condition.Op)
+			}
+		}
+	case "matches":
+		pattern, ok := condition.Value.(string)
+		if !ok {
+			return fmt.Errorf("matches takes a regular expression")
+		}
+		if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+			return fmt.Errorf("invalid regular expression: %v", err)
+		}
+	case "lt", "lte", "gt", "gte":
+		if _, ok := number(condition.Value); !ok {
+			return fmt.Errorf("%s takes a number", condition.Op)
+		}
+	case "version_lt", "version_lte", "version_gt", "version_gte":
+		// A version must be quoted: YAML reads 1.10 as the number 1.1
+		text, ok := condition.Value.(string)
+		if _, valid := parseVersion(text); !ok || !valid {
+			return fmt.Errorf("%s takes a quoted version such as \"1.2\"", condition.Op)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", condition.Op)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package scans
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// signatureAlgorithms maps Go signature algorithm names onto the naming
+// used in the inventory
+var signatureAlgorithms = map[x509.SignatureAlgorithm]string{
+	x509.MD5WithRSA:       "MD5withRSA",
+	x509.SHA1WithRSA:      "SHA1withRSA",
+	x509.SHA256WithRSA:    "SHA256withRSA",
+	x509.SHA384WithRSA:    "SHA384withRSA",
+	x509.SHA512WithRSA:    "SHA512withRSA",
+	x509.SHA256WithRSAPSS: "RSA-PSS",
+	x509.SHA384WithRSAPSS: "RSA-PSS",
+	x509.SHA512WithRSAPSS: "RSA-PSS",
+	x509.ECDSAWithSHA1:    "SHA1withECDSA",
+	x509.ECDSAWithSHA256:  "SHA256withECDSA",
+	x509.ECDSAWithSHA384:  "SHA384withECDSA",
+	x509.ECDSAWithSHA512:  "SHA512withECDSA",
+	x509.PureEd25519:      "Ed25519",
+}
+
+var keyUsages = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+var extKeyUsages = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "any",
+	x509.ExtKeyUsageServerAuth:      "serverAuth",
+	x509.ExtKeyUsageClientAuth:      "clientAuth",
+	x509.ExtKeyUsageCodeSigning:     "codeSigning",
+	x509.ExtKeyUsageEmailProtection: "emailProtection",
+	x509.ExtKeyUsageTimeStamping:    "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:     "OCSPSigning",
+}
+
+// Certificate holds the inventory fields of a certificate presented by a
+// scanned service
+type Certificate struct {
+	SerialNumber            string
+	SubjectDN               string
+	IssuerDN                string
+	CommonName              string
+	SubjectAlternativeNames []string
+	SignatureAlgorithm      string
+	PublicKeyAlgorithm      string
+	PublicKeySize           int
+	NotBefore               time.Time
+	NotAfter                time.Time
+	FingerprintSHA1         string
+	FingerprintSHA256       string
+	SubjectKeyID            string
+	AuthorityKeyID          string
+	PEM                     string
+	IsSelfSigned            bool
+	IsCA                    bool
+	KeyUsage                []string
+	ExtendedKeyUsage        []string
+}
+
+// parsePEM reads the certificates of a PEM text. Scanners flatten PEM in
+// their reports, some joining the lines with spaces or literal "\n", so the
+// base64 body is reassembled between the armor lines before decoding.
+func parsePEM(text string) ([]*Certificate, error) {
+	const begin, end = "-----BEGIN CERTIFICATE-----", "-----END CERTIFICATE-----"
+
+	var certificates []*Certificate
+	for {
+		start := strings.Index(text, begin)
+		if start < 0 {
+			break
+		}
+		stop := strings.Index(text[start:], end)
+		if stop < 0 {
+			return nil, errors.New("certificate PEM is truncated")
+		}
+		body := text[start+len(begin) : start+stop]
+		text = text[start+stop+len(end):]
+
+		body = strings.ReplaceAll(body, `\n`, "")
+		body = strings.Join(strings.Fields(body), "")
+		der, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate PEM: %v", err)
+		}
+		certificate, err := parseDER(der)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("no PEM certificate")
+	}
+	return certificates, nil
+}
+
+// parseDER reads a DER encoded certificate
+func parseDER(der []byte) (*Certificate, error) {
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+
+	sha1Sum := sha1.Sum(certificate.Raw)
+	sha256Sum := sha256.Sum256(certificate.Raw)
+	result := &Certificate{
+		SerialNumber:       serialNumber(certificate),
+		SubjectDN:          certificate.Subject.String(),
+		IssuerDN:           certificate.Issuer.String(),
+		CommonName:         certificate.Subject.CommonName,
+		PublicKeyAlgorithm: certificate.PublicKeyAlgorithm.String(),
+		PublicKeySize:      publicKeySize(certificate.PublicKey),
+		NotBefore:          certificate.NotBefore,
+		NotAfter:           certificate.NotAfter,
+		FingerprintSHA1:    hex.EncodeToString(sha1Sum[:]),
+		FingerprintSHA256:  hex.EncodeToString(sha256Sum[:]),
+		SubjectKeyID:       hex.EncodeToString(certificate.SubjectKeyId),
+		AuthorityKeyID:     hex.EncodeToString(certificate.AuthorityKeyId),
+		PEM:                string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})),
+		IsCA:               certificate.IsCA,
+	}
+
+	if name, ok := signatureAlgorithms[certificate.SignatureAlgorithm]; ok {
+		result.SignatureAlgorithm = name
+	} else {
+		result.SignatureAlgorithm = certificate.SignatureAlgorithm.String()
+	}
+
+	result.SubjectAlternativeNames = append(result.SubjectAlternativeNames, certificate.DNSNames...)
+	for _, ip := range certificate.IPAddresses {
+		result.SubjectAlternativeNames = append(result.SubjectAlternativeNames, ip.String())
+	}
+	result.SubjectAlternativeNames = append(result.SubjectAlternativeNames, certificate.EmailAddresses...)
+	for _, uri := range certificate.URIs {
+		result.SubjectAlternativeNames = append(result.SubjectAlternativeNames, uri.String())
+	}
+
+	// CheckSignatureFrom would require the CA flag, which self-signed leaf
+	// certificates usually lack
+	result.IsSelfSigned = bytes.Equal(certificate.RawSubject, certificate.RawIssuer) &&
+		certificate.CheckSignature(certificate.SignatureAlgorithm, certificate.RawTBSCertificate, certificate.Signature) == nil
+
+	for _, usage := range keyUsages {
+		if certificate.KeyUsage&usage.usage != 0 {
+			result.KeyUsage = append(result.KeyUsage, usage.name)
+		}
+	}
+	for _, usage := range certificate.ExtKeyUsage {
+		if name, ok := extKeyUsages[usage]; ok {
+			result.ExtendedKeyUsage = append(result.ExtendedKeyUsage, name)
+		}
+	}
+	return result, nil
+}
+
+// serialNumber formats a serial number as colon-separated hex bytes
+func serialNumber(certificate *x509.Certificate) string {
+	raw := certificate.SerialNumber.Bytes()
+	parts := make([]string, len(raw))
+	for i, b := range raw {
+		parts[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.Join(parts, ":")
+}
+
+// publicKeySize returns the key size in bits, or 0 when unknown
+func publicKeySize(key interface{}) int {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
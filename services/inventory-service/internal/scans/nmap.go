@@ -0,0 +1,190 @@
+package scans
+
+import (
+	"encoding/xml"
+	"fmt"
+	"inventory-service/internal/models"
+	"strings"
+)
+
+// nmap scripts read by an import
+const (
+	nmapSSLEnumCiphers = "ssl-enum-ciphers"
+	nmapSSHEnumAlgos   = "ssh2-enum-algos"
+	nmapSSLCert        = "ssl-cert"
+)
+
+// nmapRun is the part of an nmap XML report (-oX) an import reads
+type nmapRun struct {
+	XMLName xml.Name   `xml:"nmaprun"`
+	Hosts   []nmapHost `xml:"host"`
+}
+
+type nmapHost struct {
+	Addresses []struct {
+		Addr     string `xml:"addr,attr"`
+		AddrType string `xml:"addrtype,attr"`
+	} `xml:"address"`
+	Hostnames []struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"hostnames>hostname"`
+	Ports []nmapPort `xml:"ports>port"`
+}
+
+type nmapPort struct {
+	PortID int `xml:"portid,attr"`
+	State  struct {
+		State string `xml:"state,attr"`
+	} `xml:"state"`
+	Service struct {
+		ExtraInfo string `xml:"extrainfo,attr"`
+	} `xml:"service"`
+	Scripts []nmapTable `xml:"script"`
+}
+
+// nmapTable is a script result or one of its tables: structured script
+// output nests keyed tables and elements
+type nmapTable struct {
+	ID     string      `xml:"id,attr"`
+	Key    string      `xml:"key,attr"`
+	Tables []nmapTable `xml:"table"`
+	Elems  []struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"elem"`
+}
+
+// table returns the nested table with a key
+func (t *nmapTable) table(key string) *nmapTable {
+	for i := range t.Tables {
+		if t.Tables[i].Key == key {
+			return &t.Tables[i]
+		}
+	}
+	return nil
+}
+
+// elem returns the value of the element with a key
+func (t *nmapTable) elem(key string) string {
+	for _, elem := range t.Elems {
+		if elem.Key == key {
+			return strings.TrimSpace(elem.Value)
+		}
+	}
+	return ""
+}
+
+// values returns the unkeyed elements of a table, as nmap lists algorithms
+func (t *nmapTable) values() []string {
+	if t == nil {
+		return nil
+	}
+	var values []string
+	for _, elem := range t.Elems {
+		if value := strings.TrimSpace(elem.Value); value != "" && elem.Key == "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// parseNmap reads an nmap XML report. Every open port with results of the
+// ssl-enum-ciphers or ssh2-enum-algos script is a row, with the
+// certificate of the ssl-cert script when it ran.
+func parseNmap(data []byte) ([]Row, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("invalid nmap XML: %w", err)
+	}
+
+	var rows []Row
+	for _, host := range run.Hosts {
+		var hostname, ip string
+		for _, address := range host.Addresses {
+			if address.AddrType == "ipv4" || address.AddrType == "ipv6" {
+				ip = address.Addr
+				break
+			}
+		}
+		// The name given on the command line wins over a reverse lookup
+		for _, name := range host.Hostnames {
+			if hostname == "" || name.Type == "user" {
+				hostname = strings.TrimSuffix(name.Name, ".")
+			}
+		}
+
+		for _, port := range host.Ports {
+			if port.State.State != "open" {
+				continue
+			}
+			row := Row{Hostname: hostname, IPAddress: ip, Port: port.PortID}
+			scanned := false
+			for i := range port.Scripts {
+				script := &port.Scripts[i]
+				switch script.ID {
+				case nmapSSLEnumCiphers:
+					scanned = true
+					row.Implementations = append(row.Implementations, nmapTLSImplementations(script)...)
+				case nmapSSHEnumAlgos:
+					scanned = true
+					row.Implementations = append(row.Implementations, sshImplementations(FormatNmap,
+						nmapSSHVersion(port.Service.ExtraInfo),
+						script.table("kex_algorithms").values(),
+						script.table("server_host_key_algorithms").values(),
+						script.table("encryption_algorithms").values(),
+						script.table("mac_algorithms").values(),
+					)...)
+				case nmapSSLCert:
+					if encoded := script.elem("pem"); encoded != "" {
+						certificates, err := parsePEM(encoded)
+						if err != nil && row.Err == nil {
+							row.Err = err
+						}
+						row.Certificates = append(row.Certificates, certificates...)
+					}
+				}
+			}
+			if scanned {
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// nmapTLSImplementations reads the cipher suites ssl-enum-ciphers found
+// per protocol version
+func nmapTLSImplementations(script *nmapTable) []models.CryptoImplementationInput {
+	var implementations []models.CryptoImplementationInput
+	for i := range script.Tables {
+		version := &script.Tables[i]
+		if !strings.HasPrefix(version.Key, "TLS") && !strings.HasPrefix(version.Key, "SSL") {
+			continue
+		}
+		ciphers := version.table("ciphers")
+		if ciphers == nil || len(ciphers.Tables) == 0 {
+			implementations = append(implementations, tlsImplementation(FormatNmap, version.Key, "", ""))
+			continue
+		}
+		for j := range ciphers.Tables {
+			cipher := &ciphers.Tables[j]
+			// nmap names TLS 1.3 suites TLS_AKE_WITH_<AEAD>_<hash>
+			name := strings.Replace(cipher.elem("name"), "TLS_AKE_WITH_", "TLS_", 1)
+			implementations = append(implementations,
+				tlsImplementation(FormatNmap, version.Key, name, cipher.elem("kex_info")))
+		}
+	}
+	return implementations
+}
+
+// nmapSSHVersion reads the protocol version from the service detection
+// info, such as "protocol 2.0" or "Ubuntu Linux; protocol 2.0"
+func nmapSSHVersion(extraInfo string) string {
+	for _, part := range strings.Split(extraInfo, ";") {
+		if version, found := strings.CutPrefix(strings.TrimSpace(part), "protocol "); found {
+			return version
+		}
+	}
+	return ""
+}
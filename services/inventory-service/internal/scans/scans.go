@@ -0,0 +1,292 @@
+// Package scans reads the results of external TLS and SSH scanners (nmap
+// with the ssl-enum-ciphers, ssh2-enum-algos and ssl-cert scripts, sslscan
+// XML, testssl.sh JSON and sslyze JSON) as inventory rows. A row is one
+// host and port: the asset it belongs to, the crypto implementations
+// offered there and the certificates presented. Rows that cannot be read
+// carry the reason instead, so one bad host does not reject a whole scan.
+package scans
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inventory-service/internal/algorithms"
+	"inventory-service/internal/models"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Formats of scanner output
+const (
+	FormatNmap    = "nmap"
+	FormatSSLScan = "sslscan"
+	FormatTestSSL = "testssl"
+	FormatSSLyze  = "sslyze"
+)
+
+// DiscoveryMethod is how implementations found by a scanner were
+// discovered: the scanner connected to the service
+const DiscoveryMethod = "active"
+
+// Row is one host and port of a scan. Err is why the row cannot be
+// imported; the other fields are then incomplete.
+type Row struct {
+	Hostname        string
+	IPAddress       string
+	Port            int
+	Implementations []models.CryptoImplementationInput
+	Certificates    []*Certificate // leaf first
+	Err             error
+}
+
+// Target names the row's host and port for error reports
+func (r *Row) Target() string {
+	host := r.IPAddress
+	if host == "" {
+		host = r.Hostname
+	}
+	if r.Port == 0 {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(r.Port))
+}
+
+// Asset returns the inventory asset of the row's host. Assets are hosts,
+// so the port is recorded on the implementations instead.
+func (r *Row) Asset(source string) models.AssetInput {
+	input := models.AssetInput{
+		AssetType: "server",
+		Metadata:  map[string]interface{}{"imported_from": source},
+	}
+	if r.Hostname != "" {
+		hostname := r.Hostname
+		input.Hostname = &hostname
+	}
+	if r.IPAddress != "" {
+		ip := r.IPAddress
+		input.IPAddress = &ip
+	}
+	return input
+}
+
+// Parse reads a scan in the given format. It fails only when the document
+// as a whole cannot be read.
+func Parse(format string, data []byte) ([]Row, error) {
+	var rows []Row
+	var err error
+	switch format {
+	case FormatNmap:
+		rows, err = parseNmap(data)
+	case FormatSSLScan:
+		rows, err = parseSSLScan(data)
+	case FormatTestSSL:
+		rows, err = parseTestSSL(data)
+	case FormatSSLyze:
+		rows, err = parseSSLyze(data)
+	default:
+		return nil, fmt.Errorf("unsupported scan format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		if rows[i].Err == nil {
+			rows[i].Err = rows[i].validate()
+		}
+	}
+	return rows, nil
+}
+
+// DetectFormat recognizes the format of a scan from its content
+func DetectFormat(data []byte) (string, error) {
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	head = bytes.TrimSpace(head)
+	switch {
+	case bytes.Contains(head, []byte("<nmaprun")):
+		return FormatNmap, nil
+	case bytes.HasPrefix(head, []byte("<")) && bytes.Contains(bytes.ToLower(head), []byte("sslscan")):
+		return FormatSSLScan, nil
+	case bytes.HasPrefix(head, []byte("[")):
+		// testssl.sh writes a flat list of findings with --jsonfile
+		return FormatTestSSL, nil
+	case bytes.HasPrefix(head, []byte("{")):
+		var probe struct {
+			ServerScanResults json.RawMessage `json:"server_scan_results"`
+			ScanResult        json.RawMessage `json:"scanResult"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return "", fmt.Errorf("invalid JSON: %w", err)
+		}
+		switch {
+		case probe.ServerScanResults != nil:
+			return FormatSSLyze, nil
+		case probe.ScanResult != nil:
+			return FormatTestSSL, nil
+		}
+	}
+	return "", errors.New("not an nmap, sslscan, testssl.sh or sslyze report")
+}
+
+// validate checks a row against the inventory's constraints, so a bad
+// value is reported for its row rather than failing the import
+func (r *Row) validate() error {
+	if r.Hostname == "" && r.IPAddress == "" {
+		return errors.New("no host address or name")
+	}
+	if r.Port < 0 || r.Port > 65535 {
+		return fmt.Errorf("invalid port %d", r.Port)
+	}
+	if len(r.Implementations) == 0 {
+		return errors.New("no TLS or SSH results")
+	}
+	asset := r.Asset("")
+	asset.Normalize()
+	if err := asset.Validate(); err != nil {
+		return err
+	}
+	for i := range r.Implementations {
+		r.Implementations[i].Normalize()
+		if err := r.Implementations[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tlsImplementation describes a cipher suite offered with a TLS or SSL
+// version. Suites are stored under their IANA name whichever spelling the
+// scanner used; group is the key exchange group it negotiated, if known.
+func tlsImplementation(source, version, suite, group string) models.CryptoImplementationInput {
+	input := models.CryptoImplementationInput{
+		Protocol:        "TLS",
+		DiscoveryMethod: DiscoveryMethod,
+		RawData:         rawData(source, nil),
+	}
+	if version = tlsVersion(version); version != "" {
+		input.ProtocolVersion = &version
+	}
+	if suite = strings.TrimSpace(suite); suite != "" {
+		if entry, ok := algorithms.Lookup(suite, algorithms.KindTLSCipherSuite); ok {
+			suite = entry.Name
+		}
+		input.CipherSuite = &suite
+	}
+	if group = namedGroup(group); group != "" {
+		input.KeyExchangeAlgorithm = &group
+	}
+	return input
+}
+
+// tlsVersion converts the version spellings of the scanners to the short
+// form stored in the inventory: TLSv1.2, TLS 1.2, tls1_2 and TLS1_2 are
+// "1.2", and SSLv3 is "SSL 3.0"
+func tlsVersion(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+	ssl := strings.HasPrefix(version, "ssl")
+	version = strings.TrimLeft(strings.TrimPrefix(strings.TrimPrefix(version, "ssl"), "tls"), "v _")
+	version = strings.ReplaceAll(version, "_", ".")
+	if version == "" {
+		return ""
+	}
+	if !strings.Contains(version, ".") {
+		version += ".0"
+	}
+	if ssl {
+		return "SSL " + version
+	}
+	return version
+}
+
+// namedGroup resolves a key exchange group as reported by a scanner
+// ("ecdh_x25519", "25519", "secp256r1", "P-256") to its IANA name
+func namedGroup(group string) string {
+	group = strings.TrimSpace(group)
+	for _, prefix := range []string{"ecdh_", "dh_"} {
+		group = strings.TrimPrefix(group, prefix)
+	}
+	if group == "" {
+		return ""
+	}
+	for _, candidate := range []string{group, "x" + group} {
+		if entry, ok := algorithms.Lookup(candidate, algorithms.KindNamedGroup); ok {
+			return entry.Name
+		}
+	}
+	return ""
+}
+
+// sshImplementations describes the SSH algorithms offered by a server:
+// one implementation per encryption algorithm, with the server's preferred
+// key exchange, host key and MAC. The full lists offered are kept in the
+// raw data.
+func sshImplementations(source, version string, kex, hostKeys, ciphers, macs []string) []models.CryptoImplementationInput {
+	offered := map[string]interface{}{
+		"kex_algorithms":        kex,
+		"host_key_algorithms":   hostKeys,
+		"encryption_algorithms": ciphers,
+		"mac_algorithms":        macs,
+	}
+	base := models.CryptoImplementationInput{
+		Protocol:             "SSH",
+		DiscoveryMethod:      DiscoveryMethod,
+		RawData:              rawData(source, offered),
+		KeyExchangeAlgorithm: firstOf(kex),
+		SignatureAlgorithm:   firstOf(hostKeys),
+	}
+	if version = strings.TrimPrefix(strings.TrimSpace(version), "SSH-"); version != "" {
+		base.ProtocolVersion = &version
+	}
+
+	implementations := make([]models.CryptoImplementationInput, 0, len(ciphers))
+	for _, cipher := range ciphers {
+		cipher := cipher
+		input := base
+		input.CipherSuite = &cipher
+		input.SymmetricEncryption = &cipher
+		// AEAD ciphers authenticate the data themselves
+		if entry, ok := algorithms.Lookup(cipher, algorithms.KindSSHCipher); !ok || entry.MAC != "AEAD" {
+			input.HashAlgorithm = firstOf(macs)
+		}
+		implementations = append(implementations, input)
+	}
+	return implementations
+}
+
+// rawData records which scanner reported an implementation
+func rawData(source string, extra map[string]interface{}) json.RawMessage {
+	data := map[string]interface{}{"source": source}
+	for key, value := range extra {
+		data[key] = value
+	}
+	encoded, _ := json.Marshal(data)
+	return encoded
+}
+
+func firstOf(values []string) *string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return &value
+		}
+	}
+	return nil
+}
+
+// splitHost separates an address from a name: scanners report either, or
+// both as "name/address"
+func splitHost(host string) (hostname, ip string) {
+	host = strings.TrimSpace(host)
+	if name, address, found := strings.Cut(host, "/"); found {
+		if parsed := net.ParseIP(address); parsed != nil {
+			return strings.TrimSuffix(name, "."), parsed.String()
+		}
+	}
+	if parsed := net.ParseIP(strings.Trim(host, "[]")); parsed != nil {
+		return "", parsed.String()
+	}
+	return strings.TrimSuffix(host, "."), ""
+}
@@ -0,0 +1,80 @@
+package scans
+
+import (
+	"encoding/xml"
+	"fmt"
+	"inventory-service/internal/algorithms"
+	"strings"
+)
+
+// sslscanDocument is an sslscan XML report (--xml)
+type sslscanDocument struct {
+	XMLName xml.Name      `xml:"document"`
+	Tests   []sslscanTest `xml:"ssltest"`
+}
+
+type sslscanTest struct {
+	Host      string `xml:"host,attr"`
+	Port      int    `xml:"port,attr"`
+	Protocols []struct {
+		Type    string `xml:"type,attr"`
+		Version string `xml:"version,attr"`
+		Enabled string `xml:"enabled,attr"`
+	} `xml:"protocol"`
+	Ciphers []struct {
+		SSLVersion string `xml:"sslversion,attr"`
+		Cipher     string `xml:"cipher,attr"`
+		ID         string `xml:"id,attr"`
+		Curve      string `xml:"curve,attr"`
+	} `xml:"cipher"`
+	// The PEM is only reported with --show-certificate
+	Blobs []string `xml:"certificates>certificate>certificate-blob"`
+}
+
+// parseSSLScan reads an sslscan XML report; every tested host and port is
+// a row
+func parseSSLScan(data []byte) ([]Row, error) {
+	var document sslscanDocument
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid sslscan XML: %w", err)
+	}
+
+	rows := make([]Row, 0, len(document.Tests))
+	for _, test := range document.Tests {
+		row := Row{Port: test.Port}
+		row.Hostname, row.IPAddress = splitHost(test.Host)
+
+		withCiphers := make(map[string]bool)
+		for _, cipher := range test.Ciphers {
+			// sslscan names suites the OpenSSL way; the codepoint gives the
+			// IANA name when the catalog knows it
+			suite := cipher.Cipher
+			if entry, ok := algorithms.Lookup(cipher.ID, algorithms.KindTLSCipherSuite); ok {
+				suite = entry.Name
+			}
+			withCiphers[tlsVersion(cipher.SSLVersion)] = true
+			row.Implementations = append(row.Implementations,
+				tlsImplementation(FormatSSLScan, cipher.SSLVersion, suite, cipher.Curve))
+		}
+		for _, protocol := range test.Protocols {
+			version := protocol.Type + protocol.Version
+			if protocol.Enabled == "1" && !withCiphers[tlsVersion(version)] {
+				row.Implementations = append(row.Implementations, tlsImplementation(FormatSSLScan, version, "", ""))
+			}
+		}
+
+		for _, blob := range test.Blobs {
+			if strings.TrimSpace(blob) == "" {
+				continue
+			}
+			certificates, err := parsePEM(blob)
+			if err != nil {
+				row.Err = err
+				break
+			}
+			row.Certificates = append(row.Certificates, certificates...)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,139 @@
+package scans
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// sslyzeCipherCommands are the cipher suite scan commands of sslyze, one
+// per protocol version
+var sslyzeCipherCommands = []string{
+	"ssl_2_0_cipher_suites", "ssl_3_0_cipher_suites", "tls_1_0_cipher_suites",
+	"tls_1_1_cipher_suites", "tls_1_2_cipher_suites", "tls_1_3_cipher_suites",
+}
+
+// sslyzeReport is an sslyze JSON report (--json_out). sslyze 5 and later
+// wrap each command result with its status in scan_result; sslyze 4 lists
+// the results in scan_commands_results.
+type sslyzeReport struct {
+	ServerScanResults []struct {
+		ServerLocation *sslyzeLocation `json:"server_location"`
+		ServerInfo     *struct {
+			ServerLocation sslyzeLocation `json:"server_location"`
+		} `json:"server_info"`
+		ScanStatus string `json:"scan_status"`
+		ScanResult map[string]struct {
+			Status string          `json:"status"`
+			Result json.RawMessage `json:"result"`
+		} `json:"scan_result"`
+		ScanCommandsResults map[string]json.RawMessage `json:"scan_commands_results"`
+	} `json:"server_scan_results"`
+}
+
+type sslyzeLocation struct {
+	Hostname  string `json:"hostname"`
+	Port      int    `json:"port"`
+	IPAddress string `json:"ip_address"`
+}
+
+type sslyzeCipherSuites struct {
+	TLSVersionUsed       string `json:"tls_version_used"`
+	AcceptedCipherSuites []struct {
+		CipherSuite struct {
+			Name string `json:"name"`
+		} `json:"cipher_suite"`
+		EphemeralKey *struct {
+			CurveName string `json:"curve_name"`
+		} `json:"ephemeral_key"`
+	} `json:"accepted_cipher_suites"`
+}
+
+type sslyzeCertificateInfo struct {
+	CertificateDeployments []struct {
+		ReceivedCertificateChain []struct {
+			PEM string `json:"as_pem"`
+		} `json:"received_certificate_chain"`
+	} `json:"certificate_deployments"`
+}
+
+// parseSSLyze reads an sslyze JSON report; every scanned server is a row
+func parseSSLyze(data []byte) ([]Row, error) {
+	var report sslyzeReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid sslyze JSON: %w", err)
+	}
+
+	rows := make([]Row, 0, len(report.ServerScanResults))
+	for _, server := range report.ServerScanResults {
+		location := server.ServerLocation
+		if location == nil && server.ServerInfo != nil {
+			location = &server.ServerInfo.ServerLocation
+		}
+		row := Row{}
+		if location != nil {
+			row.Hostname, _ = splitHost(location.Hostname)
+			_, row.IPAddress = splitHost(location.IPAddress)
+			row.Port = location.Port
+		}
+		if server.ScanStatus != "" && server.ScanStatus != "COMPLETED" {
+			row.Err = fmt.Errorf("sslyze scan status %s", server.ScanStatus)
+			rows = append(rows, row)
+			continue
+		}
+
+		results := server.ScanCommandsResults
+		if results == nil {
+			results = make(map[string]json.RawMessage, len(server.ScanResult))
+			for command, result := range server.ScanResult {
+				// Commands that failed or were not run leave no result
+				if result.Status == "COMPLETED" {
+					results[command] = result.Result
+				}
+			}
+		}
+
+		for _, command := range sslyzeCipherCommands {
+			raw, ok := results[command]
+			if !ok {
+				continue
+			}
+			var suites sslyzeCipherSuites
+			if err := json.Unmarshal(raw, &suites); err != nil {
+				row.Err = fmt.Errorf("invalid %s result: %v", command, err)
+				break
+			}
+			version := suites.TLSVersionUsed
+			if version == "" {
+				version = strings.TrimSuffix(command, "_cipher_suites")
+			}
+			for _, accepted := range suites.AcceptedCipherSuites {
+				group := ""
+				if accepted.EphemeralKey != nil {
+					group = accepted.EphemeralKey.CurveName
+				}
+				row.Implementations = append(row.Implementations,
+					tlsImplementation(FormatSSLyze, version, accepted.CipherSuite.Name, group))
+			}
+		}
+
+		if raw, ok := results["certificate_info"]; ok && row.Err == nil {
+			var info sslyzeCertificateInfo
+			if err := json.Unmarshal(raw, &info); err != nil {
+				row.Err = fmt.Errorf("invalid certificate_info result: %v", err)
+			}
+			for _, deployment := range info.CertificateDeployments {
+				for _, entry := range deployment.ReceivedCertificateChain {
+					certificates, err := parsePEM(entry.PEM)
+					if err != nil {
+						row.Err = err
+						break
+					}
+					row.Certificates = append(row.Certificates, certificates...)
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
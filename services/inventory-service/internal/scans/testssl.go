@@ -0,0 +1,214 @@
+package scans
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inventory-service/internal/algorithms"
+	"inventory-service/internal/models"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// testssl.sh finding IDs an import reads
+var (
+	// cipher-tls1_2_xc02f, written per cipher with -E / --cipher-per-proto
+	testsslCipherID = regexp.MustCompile(`^cipher-(\w+?)_x([0-9a-fA-F]+)$`)
+	// cipherorder_TLSv1_2 and supportedciphers_TLSv1_2 list suite names
+	testsslCipherListID = regexp.MustCompile(`^(?:cipherorder|supportedciphers)_(\w+)$`)
+	// cert and, for servers with several keys, cert <cert#1>
+	testsslCertID = regexp.MustCompile(`^cert(?: <cert#\d+>)?$`)
+)
+
+// testsslProtocols are the protocol finding IDs with their versions
+var testsslProtocols = map[string]string{
+	"SSLv2": "SSLv2", "SSLv3": "SSLv3", "TLS1": "TLSv1.0", "TLS1_1": "TLSv1.1", "TLS1_2": "TLSv1.2", "TLS1_3": "TLSv1.3",
+}
+
+// testsslCurves names the curves testssl.sh reports by size, "ECDH 253"
+var testsslCurves = map[string]string{
+	"253": "x25519", "256": "secp256r1", "384": "secp384r1", "448": "x448", "521": "secp521r1",
+}
+
+// testsslFinding is a finding of a testssl.sh JSON report. The flat report
+// (--jsonfile) is a list of them carrying the target; the pretty report
+// (--jsonfile-pretty) groups them in sections of each scanned target.
+type testsslFinding struct {
+	ID       string `json:"id"`
+	IP       string `json:"ip"`
+	Port     string `json:"port"`
+	Severity string `json:"severity"`
+	Finding  string `json:"finding"`
+}
+
+// testsslTarget collects the findings of a host and port
+type testsslTarget struct {
+	host     string
+	port     string
+	findings []testsslFinding
+}
+
+// parseTestSSL reads a flat or pretty testssl.sh JSON report; every
+// scanned host and port is a row
+func parseTestSSL(data []byte) ([]Row, error) {
+	var targets []*testsslTarget
+	index := make(map[string]*testsslTarget)
+	add := func(host, port string, finding testsslFinding) {
+		key := host + " " + port
+		target := index[key]
+		if target == nil {
+			target = &testsslTarget{host: host, port: port}
+			index[key] = target
+			targets = append(targets, target)
+		}
+		target.findings = append(target.findings, finding)
+	}
+
+	var flat []testsslFinding
+	if err := json.Unmarshal(data, &flat); err == nil {
+		for _, finding := range flat {
+			add(finding.IP, finding.Port, finding)
+		}
+	} else {
+		var pretty struct {
+			ScanResult []map[string]json.RawMessage `json:"scanResult"`
+		}
+		if err := json.Unmarshal(data, &pretty); err != nil {
+			return nil, fmt.Errorf("invalid testssl.sh JSON: %w", err)
+		}
+		if pretty.ScanResult == nil {
+			return nil, errors.New("invalid testssl.sh JSON: no findings or scanResult")
+		}
+		for _, result := range pretty.ScanResult {
+			var host, ip, port string
+			json.Unmarshal(result["targetHost"], &host)
+			json.Unmarshal(result["ip"], &ip)
+			json.Unmarshal(result["port"], &port)
+			if host != "" && ip != "" && host != ip {
+				host += "/" + ip
+			} else if host == "" {
+				host = ip
+			}
+			sections := make([]string, 0, len(result))
+			for name := range result {
+				sections = append(sections, name)
+			}
+			sort.Strings(sections)
+			for _, name := range sections {
+				var findings []testsslFinding
+				if json.Unmarshal(result[name], &findings) != nil {
+					continue
+				}
+				for _, finding := range findings {
+					if finding.ID != "" {
+						add(host, port, finding)
+					}
+				}
+			}
+		}
+	}
+
+	rows := make([]Row, 0, len(targets))
+	for _, target := range targets {
+		// The findings of the whole run, such as the engine problems, carry
+		// no target
+		if target.host == "" {
+			continue
+		}
+		rows = append(rows, testsslRow(target))
+	}
+	return rows, nil
+}
+
+// testsslRow turns the findings of a target into a row. Cipher suites are
+// read from the per-cipher findings, or from the cipher order lists when
+// the scan ran without them; offered versions with neither are recorded
+// without a suite.
+func testsslRow(target *testsslTarget) Row {
+	row := Row{}
+	row.Hostname, row.IPAddress = splitHost(target.host)
+	if port, err := strconv.Atoi(target.port); err == nil {
+		row.Port = port
+	} else if target.port != "" {
+		row.Err = fmt.Errorf("invalid port %q", target.port)
+		return row
+	}
+
+	var versions []string
+	known := make(map[string]bool)
+	perCipher := make(map[string][]models.CryptoImplementationInput)
+	listed := make(map[string][]models.CryptoImplementationInput)
+	seen := func(version string) string {
+		version = tlsVersion(version)
+		if !known[version] {
+			known[version] = true
+			versions = append(versions, version)
+		}
+		return version
+	}
+	for _, finding := range target.findings {
+		text := strings.TrimSpace(finding.Finding)
+		switch {
+		case finding.ID == "scanProblem" && finding.Severity == "FATAL":
+			row.Err = fmt.Errorf("testssl.sh: %s", text)
+			return row
+		case testsslProtocols[finding.ID] != "":
+			if strings.HasPrefix(text, "offered") {
+				seen(testsslProtocols[finding.ID])
+			}
+		case testsslCipherID.MatchString(finding.ID):
+			match := testsslCipherID.FindStringSubmatch(finding.ID)
+			version := seen(match[1])
+			perCipher[version] = append(perCipher[version], testsslCipher(version, match[2], text))
+		case testsslCipherListID.MatchString(finding.ID):
+			version := seen(testsslCipherListID.FindStringSubmatch(finding.ID)[1])
+			for _, name := range strings.Fields(text) {
+				listed[version] = append(listed[version], tlsImplementation(FormatTestSSL, version, name, ""))
+			}
+		case testsslCertID.MatchString(finding.ID):
+			certificates, err := parsePEM(text)
+			if err != nil {
+				row.Err = err
+				return row
+			}
+			row.Certificates = append(row.Certificates, certificates...)
+		}
+	}
+
+	for _, version := range versions {
+		switch {
+		case len(perCipher[version]) > 0:
+			row.Implementations = append(row.Implementations, perCipher[version]...)
+		case len(listed[version]) > 0:
+			row.Implementations = append(row.Implementations, listed[version]...)
+		default:
+			row.Implementations = append(row.Implementations, tlsImplementation(FormatTestSSL, version, "", ""))
+		}
+	}
+	return row
+}
+
+// testsslCipher reads a per-cipher finding such as
+// "TLSv1.2 xc02f ECDHE-RSA-AES128-GCM-SHA256 ECDH 253 AESGCM 128 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+func testsslCipher(version, code, text string) models.CryptoImplementationInput {
+	fields := strings.Fields(text)
+	suite := ""
+	if entry, ok := algorithms.Lookup("0x"+code, algorithms.KindTLSCipherSuite); ok {
+		suite = entry.Name
+	} else if n := len(fields); n > 0 && (strings.HasPrefix(fields[n-1], "TLS_") || strings.HasPrefix(fields[n-1], "SSL_")) {
+		suite = fields[n-1]
+	} else if n > 2 {
+		suite = fields[2]
+	}
+
+	group := ""
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "ECDH" {
+			group = testsslCurves[fields[i+1]]
+			break
+		}
+	}
+	return tlsImplementation(FormatTestSSL, version, suite, group)
+}
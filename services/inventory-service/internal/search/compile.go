@@ -0,0 +1,461 @@
+package search
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AssetRiskScore is the risk score of the asset aliased a, the highest of
+// its crypto implementations
+const AssetRiskScore = `(SELECT COALESCE(MAX(risk_score), 0) FROM crypto_implementations
+	WHERE asset_id = a.id AND deleted_at IS NULL)`
+
+// RiskLevels are the risk score ranges of the risk levels, those of the
+// risk summary
+var RiskLevels = map[string][2]int{
+	"high":    {70, 100},
+	"medium":  {40, 69},
+	"low":     {1, 39},
+	"unknown": {0, 0},
+}
+
+// RiskLevelCondition is the SQL condition that the risk score expression
+// is of a risk level
+func RiskLevelCondition(expression, level string) (string, bool) {
+	scores, ok := RiskLevels[level]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s BETWEEN %d AND %d", expression, scores[0], scores[1]), true
+}
+
+// Field kinds, which select the operators and values of a field
+const (
+	kindText      = "text"      // : = != with * wildcards, ignoring case
+	kindTextList  = "text_list" // as text, matching any element
+	kindNumber    = "number"    // integers, all operators
+	kindVersion   = "version"   // dotted versions, all operators
+	kindTime      = "time"      // < <= > >= a date or a duration from now
+	kindBool      = "bool"      // : = != true or false
+	kindIP        = "ip"        // : = != an address or a network
+	kindTag       = "tag"       // : != a tag, or tag=value
+	kindRiskLevel = "risk_level"
+)
+
+// Field scopes. Crypto fields are those of an implementation of the asset
+// and the certificate it presents.
+const (
+	scopeAsset  = "asset"
+	scopeCrypto = "crypto"
+)
+
+// Field is a field of the query language
+type Field struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Scope       string `json:"scope"`
+	Description string `json:"description"`
+	column      string
+}
+
+// fields are the fields by name. Assets are aliased a, implementations ci
+// and their certificates c.
+var fields = map[string]*Field{}
+
+// aliases are other names of fields
+var aliases = map[string]string{
+	"host":             "hostname",
+	"asset_type":       "type",
+	"environment":      "env",
+	"business_unit":    "bu",
+	"protocol_version": "version",
+	"cipher_suite":     "cipher",
+	"key_exchange":     "kex",
+	"signature":        "sig",
+	"symmetric":        "enc",
+}
+
+func init() {
+	for _, field := range []*Field{
+		{Name: "hostname", Kind: kindText, Scope: scopeAsset, column: "a.hostname", Description: "Hostname"},
+		{Name: "ip", Kind: kindIP, Scope: scopeAsset, column: "a.ip_address", Description: "IP address, or a network such as 10.0.0.0/8"},
+		{Name: "type", Kind: kindText, Scope: scopeAsset, column: "a.asset_type::text", Description: "Asset type"},
+		{Name: "env", Kind: kindText, Scope: scopeAsset, column: "a.environment::text", Description: "Environment"},
+		{Name: "bu", Kind: kindText, Scope: scopeAsset, column: "a.business_unit", Description: "Business unit"},
+		{Name: "owner", Kind: kindText, Scope: scopeAsset, column: "a.owner_email", Description: "Owner email"},
+		{Name: "os", Kind: kindText, Scope: scopeAsset, column: "a.operating_system", Description: "Operating system"},
+		{Name: "description", Kind: kindText, Scope: scopeAsset, column: "a.description", Description: "Description"},
+		{Name: "tag", Kind: kindTag, Scope: scopeAsset, column: "a.tags", Description: "Tag set and not false, or tag=value"},
+		{Name: "risk", Kind: kindNumber, Scope: scopeAsset, column: AssetRiskScore, Description: "Asset risk score, the highest of its implementations"},
+		{Name: "risk_level", Kind: kindRiskLevel, Scope: scopeAsset, column: AssetRiskScore, Description: "Asset risk level: high, medium, low or unknown"},
+		{Name: "last_seen", Kind: kindTime, Scope: scopeAsset, column: "a.last_seen_at", Description: "Last seen"},
+		{Name: "discovered", Kind: kindTime, Scope: scopeAsset, column: "a.first_discovered_at", Description: "First discovered"},
+
+		{Name: "protocol", Kind: kindText, Scope: scopeCrypto, column: "ci.protocol::text", Description: "Protocol, such as TLS or SSH"},
+		{Name: "version", Kind: kindVersion, Scope: scopeCrypto, column: "ci.protocol_version", Description: "Protocol version"},
+		{Name: "cipher", Kind: kindText, Scope: scopeCrypto, column: "ci.cipher_suite", Description: "Cipher suite"},
+		{Name: "kex", Kind: kindText, Scope: scopeCrypto, column: "ci.key_exchange_algorithm", Description: "Key exchange algorithm"},
+		{Name: "sig", Kind: kindText, Scope: scopeCrypto, column: "ci.signature_algorithm", Description: "Signature algorithm"},
+		{Name: "enc", Kind: kindText, Scope: scopeCrypto, column: "ci.symmetric_encryption", Description: "Symmetric encryption"},
+		{Name: "hash", Kind: kindText, Scope: scopeCrypto, column: "ci.hash_algorithm", Description: "Hash algorithm"},
+		{Name: "key_size", Kind: kindNumber, Scope: scopeCrypto, column: "ci.key_size", Description: "Key size in bits"},
+		{Name: "port", Kind: kindNumber, Scope: scopeCrypto, column: "ci.port", Description: "Port"},
+		{Name: "discovery", Kind: kindText, Scope: scopeCrypto, column: "ci.discovery_method::text", Description: "Discovery method"},
+		{Name: "crypto.risk", Kind: kindNumber, Scope: scopeCrypto, column: "ci.risk_score", Description: "Implementation risk score"},
+		{Name: "crypto.risk_level", Kind: kindRiskLevel, Scope: scopeCrypto, column: "ci.risk_score", Description: "Implementation risk level"},
+
+		{Name: "cert.cn", Kind: kindText, Scope: scopeCrypto, column: "c.common_name", Description: "Certificate common name"},
+		{Name: "cert.san", Kind: kindTextList, Scope: scopeCrypto, column: "c.subject_alternative_names", Description: "Certificate subject alternative name"},
+		{Name: "cert.subject", Kind: kindText, Scope: scopeCrypto, column: "c.subject_dn", Description: "Certificate subject DN"},
+		{Name: "cert.issuer", Kind: kindText, Scope: scopeCrypto, column: "c.issuer_dn", Description: "Certificate issuer DN"},
+		{Name: "cert.serial", Kind: kindText, Scope: scopeCrypto, column: "c.serial_number", Description: "Certificate serial number"},
+		{Name: "cert.fingerprint", Kind: kindText, Scope: scopeCrypto, column: "c.fingerprint_sha256", Description: "Certificate SHA-256 fingerprint"},
+		{Name: "cert.expires", Kind: kindTime, Scope: scopeCrypto, column: "c.not_after", Description: "Certificate expiry"},
+		{Name: "cert.issued", Kind: kindTime, Scope: scopeCrypto, column: "c.not_before", Description: "Certificate start of validity"},
+		{Name: "cert.key_algorithm", Kind: kindText, Scope: scopeCrypto, column: "c.public_key_algorithm", Description: "Certificate public key algorithm"},
+		{Name: "cert.key_size", Kind: kindNumber, Scope: scopeCrypto, column: "c.public_key_size", Description: "Certificate public key size in bits"},
+		{Name: "cert.sig", Kind: kindText, Scope: scopeCrypto, column: "c.signature_algorithm", Description: "Certificate signature algorithm"},
+		{Name: "cert.self_signed", Kind: kindBool, Scope: scopeCrypto, column: "c.is_self_signed", Description: "Certificate is self-signed"},
+		{Name: "cert.ca", Kind: kindBool, Scope: scopeCrypto, column: "c.is_ca_certificate", Description: "Certificate is a CA certificate"},
+	} {
+		fields[field.Name] = field
+	}
+}
+
+// Fields returns the fields of the query language by name
+func Fields() []Field {
+	list := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		list = append(list, *field)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func lookupField(name string) (*Field, bool) {
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	field, ok := fields[name]
+	return field, ok
+}
+
+// Compile compiles a query to an SQL condition on the assets aliased a.
+// Values are never part of the SQL: arg adds each to the query arguments
+// and returns its placeholder.
+//
+// Crypto terms ANDed together must be matched by the same implementation,
+// so protocol:TLS AND version<1.2 finds assets serving TLS below 1.2, not
+// assets serving any TLS and anything below 1.2. Negated crypto terms hold
+// when no implementation matches: NOT cipher:*RC4* finds assets without
+// RC4.
+func Compile(query string, arg func(interface{}) string) (string, error) {
+	expression, err := parse(query)
+	if err != nil {
+		return "", err
+	}
+	return compileAsset(expression, arg)
+}
+
+// Validate reports whether a query compiles
+func Validate(query string) error {
+	_, err := Compile(query, func(interface{}) string { return "$1" })
+	return err
+}
+
+// compileAsset compiles a condition on the asset
+func compileAsset(n node, arg func(interface{}) string) (string, error) {
+	switch n := n.(type) {
+	case *andNode:
+		var crypto, conditions []string
+		for _, term := range n.terms {
+			scoped, err := cryptoScoped(term)
+			if err != nil {
+				return "", err
+			}
+			var condition string
+			if scoped {
+				condition, err = compileCrypto(term, arg)
+				crypto = append(crypto, condition)
+			} else {
+				condition, err = compileAsset(term, arg)
+				conditions = append(conditions, condition)
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		if len(crypto) > 0 {
+			conditions = append(conditions, implementationExists(strings.Join(crypto, " AND ")))
+		}
+		return "(" + strings.Join(conditions, " AND ") + ")", nil
+	case *orNode:
+		conditions := make([]string, len(n.terms))
+		for i, term := range n.terms {
+			condition, err := compileAsset(term, arg)
+			if err != nil {
+				return "", err
+			}
+			conditions[i] = condition
+		}
+		return "(" + strings.Join(conditions, " OR ") + ")", nil
+	case *notNode:
+		condition, err := compileAsset(n.term, arg)
+		if err != nil {
+			return "", err
+		}
+		// A term on a field the asset lacks is NULL; its negation holds
+		return "NOT COALESCE(" + condition + ", false)", nil
+	case *textNode:
+		pattern := arg("%" + escapeLike(n.text) + "%")
+		return fmt.Sprintf("(a.hostname ILIKE %s OR host(a.ip_address) ILIKE %s OR a.description ILIKE %s OR a.business_unit ILIKE %s)",
+			pattern, pattern, pattern, pattern), nil
+	case *termNode:
+		field, ok := lookupField(n.field)
+		if !ok {
+			return "", errorAt(n.position, "unknown field %s", n.field)
+		}
+		condition, err := compileTerm(field, n, arg)
+		if err != nil {
+			return "", err
+		}
+		if field.Scope == scopeCrypto {
+			return implementationExists(condition), nil
+		}
+		return condition, nil
+	}
+	return "", fmt.Errorf("unexpected query node %T", n)
+}
+
+// compileCrypto compiles a condition on one implementation of the asset
+func compileCrypto(n node, arg func(interface{}) string) (string, error) {
+	switch n := n.(type) {
+	case *andNode, *orNode:
+		var terms []node
+		separator := " AND "
+		if or, ok := n.(*orNode); ok {
+			terms, separator = or.terms, " OR "
+		} else {
+			terms = n.(*andNode).terms
+		}
+		conditions := make([]string, len(terms))
+		for i, term := range terms {
+			condition, err := compileCrypto(term, arg)
+			if err != nil {
+				return "", err
+			}
+			conditions[i] = condition
+		}
+		return "(" + strings.Join(conditions, separator) + ")", nil
+	case *termNode:
+		field, _ := lookupField(n.field)
+		return compileTerm(field, n, arg)
+	}
+	return "", fmt.Errorf("unexpected crypto query node %T", n)
+}
+
+// cryptoScoped reports whether a node only tests crypto fields without
+// negation, so it can be matched by a single implementation
+func cryptoScoped(n node) (bool, error) {
+	switch n := n.(type) {
+	case *andNode, *orNode:
+		terms := []node{}
+		if or, ok := n.(*orNode); ok {
+			terms = or.terms
+		} else {
+			terms = n.(*andNode).terms
+		}
+		for _, term := range terms {
+			if scoped, err := cryptoScoped(term); err != nil || !scoped {
+				return false, err
+			}
+		}
+		return true, nil
+	case *termNode:
+		field, ok := lookupField(n.field)
+		if !ok {
+			return false, errorAt(n.position, "unknown field %s", n.field)
+		}
+		return field.Scope == scopeCrypto, nil
+	}
+	return false, nil
+}
+
+// implementationExists is the condition that an implementation of the
+// asset matches condition
+func implementationExists(condition string) string {
+	return `EXISTS (SELECT 1 FROM crypto_implementations ci
+		LEFT JOIN certificates c ON c.id = ci.certificate_id
+		WHERE ci.asset_id = a.id AND ci.deleted_at IS NULL AND ` + condition + `)`
+}
+
+// versionPattern is the first dotted number of a version, as the risk
+// rules read versions
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// sslPattern marks SSL versions, which are ranked below TLS 1.0 by
+// comparing SSLv3 as 0.3
+var sslPattern = regexp.MustCompile(`(?i)^ssl`)
+
+// durationPattern is a time relative to now, such as 30d or -12h
+var durationPattern = regexp.MustCompile(`^([+-]?)(\d+)([hdwy])$`)
+
+var durationUnits = map[string]string{"h": "hours", "d": "days", "w": "weeks", "y": "years"}
+
+// compileTerm compiles a term on a field
+func compileTerm(field *Field, n *termNode, arg func(interface{}) string) (string, error) {
+	unsupported := func() (string, error) {
+		return "", errorAt(n.position, "operator %s is not supported by %s", n.op, field.Name)
+	}
+	negated := n.op == "!="
+	equality := n.op == ":" || n.op == "=" || negated
+	not := func(condition string) string {
+		if negated {
+			return fmt.Sprintf("(%s IS NULL OR NOT %s)", field.column, condition)
+		}
+		return condition
+	}
+
+	switch field.Kind {
+	case kindText, kindTextList:
+		if !equality {
+			return unsupported()
+		}
+		var condition string
+		if strings.Contains(n.value, "*") {
+			pattern := escapeLike(n.value)
+			condition = "%s ILIKE " + arg(strings.ReplaceAll(pattern, "*", "%"))
+		} else {
+			condition = "LOWER(%s) = LOWER(" + arg(n.value) + ")"
+		}
+		if field.Kind == kindTextList {
+			return not(fmt.Sprintf("EXISTS (SELECT 1 FROM unnest(%s) element WHERE "+condition+")", field.column, "element")), nil
+		}
+		return not("(" + fmt.Sprintf(condition, field.column) + ")"), nil
+
+	case kindNumber:
+		number, err := strconv.Atoi(n.value)
+		if err != nil {
+			return "", errorAt(n.position, "%s takes a whole number", field.Name)
+		}
+		if negated {
+			return not(fmt.Sprintf("(%s = %s)", field.column, arg(number))), nil
+		}
+		return fmt.Sprintf("%s %s %s", field.column, sqlOperator(n.op), arg(number)), nil
+
+	case kindVersion:
+		match := versionPattern.FindString(n.value)
+		if match == "" {
+			return "", errorAt(n.position, "%s takes a version such as 1.2", field.Name)
+		}
+		var version []int64
+		if sslPattern.MatchString(n.value) {
+			version = append(version, 0)
+		}
+		for _, part := range strings.Split(match, ".") {
+			number, err := strconv.ParseInt(part, 10, 32)
+			if err != nil {
+				return "", errorAt(n.position, "%s takes a version such as 1.2", field.Name)
+			}
+			version = append(version, number)
+		}
+		condition := fmt.Sprintf(`string_to_array(CASE WHEN %[1]s ~* '^ssl' THEN '0.' ELSE '' END || substring(%[1]s from '\d+(?:\.\d+)*'), '.')::int[] %[2]s %[3]s::int[]`,
+			field.column, sqlOperator(n.op), arg(pq.Array(version)))
+		if negated {
+			return fmt.Sprintf(`(%s IS NULL OR %s)`, field.column, condition), nil
+		}
+		return condition, nil
+
+	case kindTime:
+		if equality {
+			return unsupported()
+		}
+		if match := durationPattern.FindStringSubmatch(n.value); match != nil {
+			interval := match[1] + match[2] + " " + durationUnits[match[3]]
+			return fmt.Sprintf("%s %s NOW() + %s::interval", field.column, n.op, arg(interval)), nil
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if at, err := time.Parse(layout, n.value); err == nil {
+				return fmt.Sprintf("%s %s %s", field.column, n.op, arg(at)), nil
+			}
+		}
+		return "", errorAt(n.position, "%s takes a date, a timestamp or a duration from now such as 30d or -12h", field.Name)
+
+	case kindBool:
+		if !equality {
+			return unsupported()
+		}
+		var value bool
+		switch strings.ToLower(n.value) {
+		case "true", "yes":
+			value = true
+		case "false", "no":
+		default:
+			return "", errorAt(n.position, "%s takes true or false", field.Name)
+		}
+		if negated {
+			return fmt.Sprintf("%s IS DISTINCT FROM %s", field.column, arg(value)), nil
+		}
+		return fmt.Sprintf("%s = %s", field.column, arg(value)), nil
+
+	case kindIP:
+		if !equality {
+			return unsupported()
+		}
+		if _, _, err := net.ParseCIDR(n.value); err != nil && net.ParseIP(n.value) == nil {
+			return "", errorAt(n.position, "%s takes an IP address or a network", field.Name)
+		}
+		return not(fmt.Sprintf("(%s <<= %s::inet)", field.column, arg(n.value))), nil
+
+	case kindTag:
+		if n.op != ":" && n.op != "!=" {
+			return unsupported()
+		}
+		var condition string
+		if key, value, ok := strings.Cut(n.value, "="); ok {
+			condition = fmt.Sprintf("(LOWER(%s->>%s::text) = LOWER(%s))", field.column, arg(key), arg(value))
+		} else {
+			key := arg(n.value)
+			condition = fmt.Sprintf("(%s ? %s::text AND %s->%s::text NOT IN ('false'::jsonb, 'null'::jsonb))",
+				field.column, key, field.column, key)
+		}
+		if negated {
+			return "NOT COALESCE(" + condition + ", false)", nil
+		}
+		return condition, nil
+
+	case kindRiskLevel:
+		if !equality {
+			return unsupported()
+		}
+		condition, ok := RiskLevelCondition(field.column, strings.ToLower(n.value))
+		if !ok {
+			return "", errorAt(n.position, "%s takes high, medium, low or unknown", field.Name)
+		}
+		return not("(" + condition + ")"), nil
+	}
+	return "", fmt.Errorf("unexpected field kind %s", field.Kind)
+}
+
+// sqlOperator is the SQL operator of a comparison term
+func sqlOperator(op string) string {
+	switch op {
+	case ":":
+		return "="
+	case "!=":
+		return "<>"
+	}
+	return op
+}
+
+// escapeLike escapes the LIKE wildcards of text
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
@@ -0,0 +1,342 @@
+// Package search implements the inventory query language. A query is a
+// boolean expression of terms on the fields of assets, their crypto
+// implementations and the certificates those present:
+//
+//	protocol:TLS AND version<1.2 AND env:production AND cert.expires<30d AND NOT tag:exempt
+//
+// A term is a field, an operator and a value; a bare word or quoted phrase
+// matches an asset's hostname, IP address, description or business unit.
+// Terms combine with AND, OR, NOT and parentheses; adjacent terms without
+// an operator are ANDed, and NOT binds tighter than AND, which binds
+// tighter than OR. Queries compile to parameterized SQL conditions on the
+// assets (see Compile).
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxQueryLength bounds the length of a query
+	MaxQueryLength = 2000
+	// maxTerms bounds the number of terms of a query, and so its SQL
+	maxTerms = 64
+	// maxDepth bounds the nesting of parentheses
+	maxDepth = 16
+)
+
+// Error is a query that cannot be parsed or compiled. Position is the
+// byte offset of the offending text.
+type Error struct {
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at %d: %s", e.Position, e.Message)
+}
+
+func errorAt(position int, format string, args ...interface{}) *Error {
+	return &Error{Position: position, Message: fmt.Sprintf(format, args...)}
+}
+
+// Query nodes
+type (
+	andNode  struct{ terms []node }
+	orNode   struct{ terms []node }
+	notNode  struct{ term node }
+	termNode struct {
+		field    string
+		op       string
+		value    string
+		position int
+	}
+	textNode struct {
+		text     string
+		position int
+	}
+	node interface{}
+)
+
+// Token types
+const (
+	tokenEOF = iota
+	tokenLeft
+	tokenRight
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenTerm
+	tokenText
+)
+
+type token struct {
+	kind     int
+	position int
+	field    string
+	op       string
+	value    string
+}
+
+// operators are the term operators, longest first
+var operators = []string{"!=", "<=", ">=", ":", "=", "<", ">"}
+
+// lex splits a query into tokens
+func lex(query string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLeft, position: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRight, position: i})
+			i++
+		case c == '"':
+			text, end, err := quoted(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenText, position: i, value: text})
+			i = end
+		default:
+			start := i
+			for i < len(query) && isFieldChar(query[i], i == start) {
+				i++
+			}
+			op := ""
+			if i > start {
+				for _, candidate := range operators {
+					if strings.HasPrefix(query[i:], candidate) {
+						op = candidate
+						break
+					}
+				}
+			}
+			if op == "" {
+				i = wordEnd(query, start)
+				word := query[start:i]
+				switch strings.ToUpper(word) {
+				case "AND":
+					tokens = append(tokens, token{kind: tokenAnd, position: start})
+				case "OR":
+					tokens = append(tokens, token{kind: tokenOr, position: start})
+				case "NOT":
+					tokens = append(tokens, token{kind: tokenNot, position: start})
+				default:
+					tokens = append(tokens, token{kind: tokenText, position: start, value: word})
+				}
+				continue
+			}
+
+			field := strings.ToLower(query[start:i])
+			i += len(op)
+			var value string
+			if i < len(query) && query[i] == '"' {
+				text, end, err := quoted(query, i)
+				if err != nil {
+					return nil, err
+				}
+				value, i = text, end
+			} else {
+				end := wordEnd(query, i)
+				value, i = query[i:end], end
+			}
+			if value == "" {
+				return nil, errorAt(start, "missing value of %s", field)
+			}
+			tokens = append(tokens, token{kind: tokenTerm, position: start, field: field, op: op, value: value})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, position: len(query)}), nil
+}
+
+// isFieldChar reports whether c can be part of a field name. Names start
+// with a letter, so 2001:db8::1 is a word.
+func isFieldChar(c byte, first bool) bool {
+	if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && (c == '_' || c == '.' || (c >= '0' && c <= '9'))
+}
+
+// wordEnd returns the end of the word starting at start
+func wordEnd(query string, start int) int {
+	i := start
+	for i < len(query) && !strings.ContainsRune(" \t\r\n()", rune(query[i])) {
+		i++
+	}
+	return i
+}
+
+// quoted reads the quoted string starting at start, in which \" and \\
+// escape, and returns it with the offset following it
+func quoted(query string, start int) (string, int, error) {
+	var text strings.Builder
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if i+1 < len(query) {
+				i++
+			}
+			text.WriteByte(query[i])
+		case '"':
+			return text.String(), i + 1, nil
+		default:
+			text.WriteByte(query[i])
+		}
+	}
+	return "", 0, errorAt(start, "unterminated quote")
+}
+
+type parser struct {
+	tokens []token
+	next   int
+	terms  int
+	depth  int
+}
+
+// parse parses a query into its expression
+func parse(query string) (node, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, errorAt(0, "empty query")
+	}
+	if len(query) > MaxQueryLength {
+		return nil, errorAt(MaxQueryLength, "query is longer than %d characters", MaxQueryLength)
+	}
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expression, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorAt(t.position, "unexpected %s", describe(t))
+	}
+	return expression, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// or := and (OR and)*
+func (p *parser) or() (node, error) {
+	first, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	terms := []node{first}
+	for p.peek().kind == tokenOr {
+		p.take()
+		term, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &orNode{terms: terms}, nil
+}
+
+// and := unary ([AND] unary)*
+func (p *parser) and() (node, error) {
+	first, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	terms := []node{first}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.take()
+		case tokenNot, tokenLeft, tokenTerm, tokenText:
+		default:
+			if len(terms) == 1 {
+				return first, nil
+			}
+			return &andNode{terms: terms}, nil
+		}
+		term, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+}
+
+// unary := NOT unary | ( or ) | term | text
+func (p *parser) unary() (node, error) {
+	t := p.take()
+	switch t.kind {
+	case tokenNot:
+		term, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{term: term}, nil
+	case tokenLeft:
+		if p.depth++; p.depth > maxDepth {
+			return nil, errorAt(t.position, "parentheses are nested more than %d deep", maxDepth)
+		}
+		expression, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.take(); closing.kind != tokenRight {
+			return nil, errorAt(closing.position, "expected ) instead of %s", describe(closing))
+		}
+		p.depth--
+		return expression, nil
+	case tokenTerm, tokenText:
+		if p.terms++; p.terms > maxTerms {
+			return nil, errorAt(t.position, "query has more than %d terms", maxTerms)
+		}
+		if t.kind == tokenText {
+			return &textNode{text: t.value, position: t.position}, nil
+		}
+		return &termNode{field: t.field, op: t.op, value: t.value, position: t.position}, nil
+	default:
+		return nil, errorAt(t.position, "expected a term instead of %s", describe(t))
+	}
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenLeft:
+		return "("
+	case tokenRight:
+		return ")"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenTerm:
+		return t.field + t.op + t.value
+	default:
+		return fmt.Sprintf("%q", t.value)
+	}
+}
@@ -15,17 +15,29 @@ import (
 	"fmt"
 	"inventory-service/internal/database"
 	"inventory-service/internal/models"
+	"inventory-service/internal/search"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// cryptoImplementationColumns are the columns scanned into a CryptoImplementation
+const cryptoImplementationColumns = `
+			id, tenant_id, asset_id, protocol, protocol_version, cipher_suite,
+			key_exchange_algorithm, signature_algorithm, symmetric_encryption,
+			hash_algorithm, key_size, certificate_id, discovery_method,
+			confidence_score, source_sensor_id, raw_data, risk_score,
+			compliance_status, first_discovered_at, last_verified_at,
+			created_at, updated_at, deleted_at`
+
 type AssetService struct {
-	db *database.DB
+	db   *database.DB
+	risk *RiskService
 }
 
-func NewAssetService(db *database.DB) *AssetService {
-	return &AssetService{db: db}
+func NewAssetService(db *database.DB, risk *RiskService) *AssetService {
+	return &AssetService{db: db, risk: risk}
 }
 
 // GetAssets retrieves assets with filtering, pagination, and risk analysis.
@@ -37,120 +49,135 @@ func NewAssetService(db *database.DB) *AssetService {
 // - Pagination support for large datasets
 // - Sorting by risk score, discovery date, or custom fields
 func (s *AssetService) GetAssets(tenantID uuid.UUID, filters models.AssetFilters) ([]models.Asset, int, error) {
-	// Build the base query with tenant isolation and risk scoring
-	// Note: Cast JSONB fields to text to avoid Go scanning issues with PostgreSQL JSONB types
-	baseQuery := `
-		SELECT 
-			a.id, a.tenant_id, a.hostname, a.ip_address, a.port, a.asset_type,
-			a.operating_system, a.environment, a.business_unit, a.owner_email,
-			a.description, a.tags::text, a.metadata::text, a.first_discovered_at, a.last_seen_at,
-			a.created_at, a.updated_at, a.deleted_at,
-			COALESCE(MAX(ci.risk_score), 0) as highest_risk
-		FROM network_assets a
-		LEFT JOIN crypto_implementations ci ON a.id = ci.asset_id AND ci.deleted_at IS NULL
-		WHERE a.tenant_id = $1 AND a.deleted_at IS NULL
-	`
-
-	countQuery := `
-		SELECT COUNT(DISTINCT a.id)
-		FROM network_assets a
-		LEFT JOIN crypto_implementations ci ON a.id = ci.asset_id AND ci.deleted_at IS NULL
-		WHERE a.tenant_id = $1 AND a.deleted_at IS NULL
-	`
-
 	args := []interface{}{tenantID}
-	argCount := 1
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
 	whereConditions := []string{}
 
 	// Add search filter
 	if filters.Search != "" {
-		argCount++
+		pattern := arg("%" + filters.Search + "%")
 		whereConditions = append(whereConditions, fmt.Sprintf(`(
-			a.hostname ILIKE $%d OR 
-			a.ip_address::text ILIKE $%d OR 
-			a.description ILIKE $%d OR
-			a.business_unit ILIKE $%d
-		)`, argCount, argCount, argCount, argCount))
-		searchPattern := "%" + filters.Search + "%"
-		args = append(args, searchPattern)
+			a.hostname ILIKE %s OR 
+			a.ip_address::text ILIKE %s OR 
+			a.description ILIKE %s OR
+			a.business_unit ILIKE %s
+		)`, pattern, pattern, pattern, pattern))
 	}
 
 	// Add asset type filter
 	if len(filters.AssetType) > 0 {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf(`a.asset_type = ANY($%d)`, argCount))
-		args = append(args, filters.AssetType)
+		whereConditions = append(whereConditions, "a.asset_type::text = ANY("+arg(pq.Array(filters.AssetType))+")")
 	}
 
 	// Add environment filter
 	if len(filters.Environment) > 0 {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf(`a.environment = ANY($%d)`, argCount))
-		args = append(args, filters.Environment)
+		whereConditions = append(whereConditions, "a.environment::text = ANY("+arg(pq.Array(filters.Environment))+")")
 	}
 
-	// Add protocol filter
+	// Add protocol filter: assets with an implementation of the protocols
 	if len(filters.Protocol) > 0 {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf(`ci.protocol = ANY($%d)`, argCount))
-		args = append(args, filters.Protocol)
+		whereConditions = append(whereConditions, `EXISTS (SELECT 1 FROM crypto_implementations ci
+			WHERE ci.asset_id = a.id AND ci.deleted_at IS NULL AND ci.protocol = ANY(`+arg(pq.Array(filters.Protocol))+`))`)
 	}
 
 	// Add business unit filter
 	if len(filters.BusinessUnit) > 0 {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf(`a.business_unit = ANY($%d)`, argCount))
-		args = append(args, filters.BusinessUnit)
+		whereConditions = append(whereConditions, "a.business_unit = ANY("+arg(pq.Array(filters.BusinessUnit))+")")
+	}
+
+	// Add risk level filter, in the query so that pages and totals count
+	// only matching assets
+	if len(filters.RiskLevel) > 0 {
+		levels := []string{}
+		for _, level := range filters.RiskLevel {
+			if condition, ok := search.RiskLevelCondition(search.AssetRiskScore, level); ok {
+				levels = append(levels, condition)
+			}
+		}
+		if len(levels) == 0 {
+			levels = append(levels, "FALSE")
+		}
+		whereConditions = append(whereConditions, "("+strings.Join(levels, " OR ")+")")
 	}
 
-	// Apply where conditions
-	if len(whereConditions) > 0 {
-		whereClause := " AND " + strings.Join(whereConditions, " AND ")
-		baseQuery += whereClause
-		countQuery += whereClause
+	return s.listAssets(assetListing{
+		conditions: whereConditions,
+		args:       args,
+		sortBy:     filters.SortBy,
+		sortOrder:  filters.SortOrder,
+		page:       filters.Page,
+		pageSize:   filters.PageSize,
+	})
+}
+
+// assetListing is a page of the assets of the tenant ($1 of args) matching
+// conditions on network_assets a
+type assetListing struct {
+	conditions []string
+	args       []interface{}
+	sortBy     string
+	sortOrder  string
+	page       int
+	pageSize   int
+}
+
+// listAssets returns a page of assets with their risk scores, and the total
+// number matching
+func (s *AssetService) listAssets(listing assetListing) ([]models.Asset, int, error) {
+	where := "a.tenant_id = $1 AND a.deleted_at IS NULL"
+	if len(listing.conditions) > 0 {
+		where += " AND " + strings.Join(listing.conditions, " AND ")
 	}
 
 	// Get total count
 	var total int
-	err := s.db.Get(&total, countQuery, args...)
+	err := s.db.Get(&total, "SELECT COUNT(*) FROM network_assets a WHERE "+where, listing.args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get assets count: %w", err)
 	}
 
-	// Add GROUP BY and sorting
-	baseQuery += " GROUP BY a.id, a.tenant_id, a.hostname, a.ip_address, a.port, a.asset_type, a.operating_system, a.environment, a.business_unit, a.owner_email, a.description, a.tags, a.metadata, a.first_discovered_at, a.last_seen_at, a.created_at, a.updated_at, a.deleted_at"
-
 	// Add sorting
 	sortBy := "a.hostname"
-	if filters.SortBy != "" {
-		switch filters.SortBy {
-		case "hostname", "ip_address", "asset_type", "environment", "created_at", "last_seen_at":
-			sortBy = "a." + filters.SortBy
-		case "risk_score":
-			sortBy = "highest_risk"
-		}
+	switch listing.sortBy {
+	case "hostname", "ip_address", "asset_type", "environment", "created_at", "last_seen_at":
+		sortBy = "a." + listing.sortBy
+	case "risk_score":
+		sortBy = "highest_risk"
 	}
 
 	sortOrder := "ASC"
-	if filters.SortOrder == "desc" {
+	if listing.sortOrder == "desc" {
 		sortOrder = "DESC"
 	}
 
-	baseQuery += fmt.Sprintf(" ORDER BY %s %s", sortBy, sortOrder)
-
 	// Add pagination
-	if filters.Page < 1 {
-		filters.Page = 1
+	if listing.page < 1 {
+		listing.page = 1
 	}
-	if filters.PageSize < 1 {
-		filters.PageSize = 20
+	if listing.pageSize < 1 {
+		listing.pageSize = 20
 	}
+	offset := (listing.page - 1) * listing.pageSize
 
-	offset := (filters.Page - 1) * filters.PageSize
-	baseQuery += fmt.Sprintf(" LIMIT %d OFFSET %d", filters.PageSize, offset)
+	// Build the query with risk scoring
+	// Note: Cast JSONB fields to text to avoid Go scanning issues with PostgreSQL JSONB types
+	query := fmt.Sprintf(`
+		SELECT 
+			a.id, a.tenant_id, a.hostname, a.ip_address, a.port, a.asset_type,
+			a.operating_system, a.environment, a.business_unit, a.owner_email,
+			a.description, a.tags::text, a.metadata::text, a.first_discovered_at, a.last_seen_at,
+			a.created_at, a.updated_at, a.deleted_at,
+			%s as highest_risk
+		FROM network_assets a
+		WHERE %s
+		ORDER BY %s %s, a.id
+		LIMIT %d OFFSET %d`, search.AssetRiskScore, where, sortBy, sortOrder, listing.pageSize, offset)
 
 	// Execute query
-	rows, err := s.db.Query(baseQuery, args...)
+	rows, err := s.db.Query(query, listing.args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to query assets: %w", err)
 	}
@@ -183,7 +210,7 @@ func (s *AssetService) GetAssets(tenantID uuid.UUID, filters models.AssetFilters
 		} else {
 			asset.Tags = make(map[string]interface{})
 		}
-		
+
 		if metadataText != "" {
 			if err := json.Unmarshal([]byte(metadataText), &asset.Metadata); err != nil {
 				// If JSON parsing fails, initialize as empty map to prevent nil pointer errors
@@ -202,19 +229,8 @@ func (s *AssetService) GetAssets(tenantID uuid.UUID, filters models.AssetFilters
 
 		assets = append(assets, asset)
 	}
-
-	// Apply risk level filter (post-query filtering since it's calculated)
-	if len(filters.RiskLevel) > 0 {
-		filteredAssets := []models.Asset{}
-		for _, asset := range assets {
-			for _, riskLevel := range filters.RiskLevel {
-				if asset.RiskLevel == riskLevel {
-					filteredAssets = append(filteredAssets, asset)
-					break
-				}
-			}
-		}
-		assets = filteredAssets
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read assets: %w", err)
 	}
 
 	return assets, total, nil
@@ -279,13 +295,7 @@ func (s *AssetService) GetAssetByID(tenantID, assetID uuid.UUID) (*models.Asset,
 // GetCryptoImplementations retrieves crypto implementations for an asset
 func (s *AssetService) GetCryptoImplementations(tenantID, assetID uuid.UUID) ([]models.CryptoImplementation, error) {
 	query := `
-		SELECT 
-			id, tenant_id, asset_id, protocol, protocol_version, cipher_suite,
-			key_exchange_algorithm, signature_algorithm, symmetric_encryption,
-			hash_algorithm, key_size, certificate_id, discovery_method,
-			confidence_score, source_sensor_id, raw_data, risk_score,
-			compliance_status, first_discovered_at, last_verified_at,
-			created_at, updated_at, deleted_at
+		SELECT ` + cryptoImplementationColumns + `
 		FROM crypto_implementations 
 		WHERE asset_id = $1 AND tenant_id = $2 AND deleted_at IS NULL
 		ORDER BY risk_score DESC, created_at DESC
@@ -297,10 +307,9 @@ func (s *AssetService) GetCryptoImplementations(tenantID, assetID uuid.UUID) ([]
 		return nil, fmt.Errorf("failed to get crypto implementations: %w", err)
 	}
 
-	// Add risk analysis for each implementation
-	for i := range cryptoImpls {
-		cryptoImpls[i].RiskLevel = models.GetRiskLevel(cryptoImpls[i].RiskScore)
-		cryptoImpls[i].RiskFactors = s.AnalyzeCryptoRisk(&cryptoImpls[i])
+	// Explain each score with the rules that make it up
+	if err := s.risk.explain(tenantID, cryptoImpls); err != nil {
+		return nil, err
 	}
 
 	return cryptoImpls, nil
@@ -330,56 +339,3 @@ func (s *AssetService) GetRiskSummary(tenantID uuid.UUID) (*models.RiskSummary,
 
 	return &summary, nil
 }
-
-// AnalyzeCryptoRisk analyzes crypto implementation and returns risk factors
-func (s *AssetService) AnalyzeCryptoRisk(crypto *models.CryptoImplementation) []string {
-	var riskFactors []string
-
-	// Analyze cipher suite
-	if crypto.CipherSuite != nil {
-		cipherSuite := strings.ToUpper(*crypto.CipherSuite)
-		if strings.Contains(cipherSuite, "RC4") ||
-			strings.Contains(cipherSuite, "DES") ||
-			strings.Contains(cipherSuite, "MD5") {
-			riskFactors = append(riskFactors, "Weak cipher suite")
-		}
-	}
-
-	// Analyze protocol version
-	if crypto.ProtocolVersion != nil {
-		version := *crypto.ProtocolVersion
-		switch crypto.Protocol {
-		case "TLS":
-			if version < "1.2" {
-				riskFactors = append(riskFactors, "Outdated TLS version")
-			}
-		case "SSH":
-			if version < "2.0" {
-				riskFactors = append(riskFactors, "Outdated SSH version")
-			}
-		}
-	}
-
-	// Analyze key size
-	if crypto.KeySize != nil {
-		keySize := *crypto.KeySize
-		if keySize < 2048 {
-			riskFactors = append(riskFactors, "Weak key size")
-		}
-	}
-
-	// Analyze hash algorithm
-	if crypto.HashAlgorithm != nil {
-		hash := strings.ToUpper(*crypto.HashAlgorithm)
-		if strings.Contains(hash, "MD5") || strings.Contains(hash, "SHA1") {
-			riskFactors = append(riskFactors, "Weak hash algorithm")
-		}
-	}
-
-	// Check confidence score
-	if crypto.ConfidenceScore < 0.7 {
-		riskFactors = append(riskFactors, "Low confidence detection")
-	}
-
-	return riskFactors
-}
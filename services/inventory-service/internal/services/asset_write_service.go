@@ -0,0 +1,617 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inventory-service/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrAssetNotFound is returned for assets that do not exist, are deleted
+	// or belong to another tenant
+	ErrAssetNotFound = errors.New("asset not found")
+	// ErrCryptoImplementationNotFound is returned for implementations that
+	// do not exist, are deleted or belong to another asset or tenant
+	ErrCryptoImplementationNotFound = errors.New("crypto implementation not found")
+	// ErrPreconditionFailed is returned when a record changed since the
+	// client read the ETag it sent
+	ErrPreconditionFailed = errors.New("record was modified since it was read")
+)
+
+// DuplicateCryptoImplementationError is returned when the asset already has
+// an implementation on the same port with the same protocol, version and
+// cipher suite
+type DuplicateCryptoImplementationError struct {
+	ExistingID uuid.UUID
+}
+
+func (e *DuplicateCryptoImplementationError) Error() string {
+	return fmt.Sprintf("crypto implementation %s already records this protocol on the port", e.ExistingID)
+}
+
+// cryptoIdentityIndex enforces the identity of implementations
+const cryptoIdentityIndex = "idx_crypto_implementations_identity"
+
+// CreateAsset stores a new asset of the tenant and returns its ID
+func (s *AssetService) CreateAsset(tenantID uuid.UUID, input *models.AssetInput, source models.ChangeSource) (uuid.UUID, error) {
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		return uuid.Nil, err
+	}
+	return s.insertAsset(tenantID, input, source)
+}
+
+// ReplaceAsset sets every writable field of an asset. A non-empty ifMatch
+// must be the asset's current ETag.
+func (s *AssetService) ReplaceAsset(tenantID, assetID uuid.UUID, ifMatch string, input *models.AssetInput, source models.ChangeSource) error {
+	return s.updateAsset(tenantID, assetID, ifMatch, source, func(current *models.AssetInput) error {
+		*current = *input
+		return nil
+	})
+}
+
+// PatchAsset applies a partial JSON document to an asset: fields present in
+// it are set, null clears them, and tags and metadata are merged key by key
+// with null removing a key. A non-empty ifMatch must be the asset's current
+// ETag.
+func (s *AssetService) PatchAsset(tenantID, assetID uuid.UUID, ifMatch string, patch []byte, source models.ChangeSource) error {
+	return s.updateAsset(tenantID, assetID, ifMatch, source, func(current *models.AssetInput) error {
+		return decodeStrict(patch, current)
+	})
+}
+
+// DeleteAsset soft deletes an asset together with its crypto
+// implementations. A non-empty ifMatch must be the asset's current ETag.
+func (s *AssetService) DeleteAsset(tenantID, assetID uuid.UUID, ifMatch string, source models.ChangeSource) error {
+	tx, err := beginChange(s.db, source)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockAsset(tx, tenantID, assetID, ifMatch); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE network_assets SET deleted_at = NOW() WHERE id = $1 AND tenant_id = $2`, assetID, tenantID); err != nil {
+		return fmt.Errorf("failed to delete asset: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE crypto_implementations SET deleted_at = NOW()
+		WHERE asset_id = $1 AND tenant_id = $2 AND deleted_at IS NULL`,
+		assetID, tenantID,
+	); err != nil {
+		return fmt.Errorf("failed to delete crypto implementations: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit asset deletion: %w", err)
+	}
+	return nil
+}
+
+// BulkUpsertAssets creates or replaces many assets. Every item is stored on
+// its own, so invalid or conflicting items are reported without failing the
+// others. Items with an ID replace that asset; other items replace the
+// asset with the same IP address, or hostname when there is none, and port,
+// and are created when there is no such asset.
+func (s *AssetService) BulkUpsertAssets(tenantID uuid.UUID, items []json.RawMessage, source models.ChangeSource) *models.BulkAssetResponse {
+	response := &models.BulkAssetResponse{Results: make([]models.BulkAssetResult, 0, len(items))}
+	for index, raw := range items {
+		result := s.upsertAsset(tenantID, raw, source)
+		result.Index = index
+		switch result.Status {
+		case models.BulkCreated:
+			response.Created++
+		case models.BulkUpdated:
+			response.Updated++
+		default:
+			response.Failed++
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response
+}
+
+func (s *AssetService) upsertAsset(tenantID uuid.UUID, raw json.RawMessage, source models.ChangeSource) models.BulkAssetResult {
+	failed := func(err error) models.BulkAssetResult {
+		result := models.BulkAssetResult{Status: models.BulkFailed, Error: err.Error()}
+		var validation *models.ValidationError
+		if errors.As(err, &validation) {
+			result.Field = validation.Field
+		}
+		return result
+	}
+
+	var item models.BulkAssetItem
+	if err := decodeStrict(raw, &item); err != nil {
+		return failed(err)
+	}
+	item.AssetInput.Normalize()
+	if err := item.AssetInput.Validate(); err != nil {
+		return failed(err)
+	}
+
+	status := models.BulkUpdated
+	if item.ID == nil {
+		existing, err := s.findAssetByKey(tenantID, &item.AssetInput)
+		if err != nil {
+			return failed(err)
+		}
+		if existing == nil {
+			id, err := s.insertAsset(tenantID, &item.AssetInput, source)
+			if err != nil {
+				return failed(err)
+			}
+			item.ID = &id
+			status = models.BulkCreated
+		} else {
+			item.ID = existing
+		}
+	}
+	if status == models.BulkUpdated {
+		if err := s.ReplaceAsset(tenantID, *item.ID, item.ETag, &item.AssetInput, source); err != nil {
+			result := failed(err)
+			result.ID = item.ID
+			return result
+		}
+	}
+
+	var updatedAt time.Time
+	if err := s.db.Get(&updatedAt, `SELECT updated_at FROM network_assets WHERE id = $1`, *item.ID); err != nil {
+		return failed(fmt.Errorf("failed to read asset: %w", err))
+	}
+	return models.BulkAssetResult{Status: status, ID: item.ID, ETag: models.ETag(updatedAt)}
+}
+
+// findAssetByKey returns the oldest asset of the tenant with the input's IP
+// address, or hostname when it has none, and port
+func (s *AssetService) findAssetByKey(tenantID uuid.UUID, input *models.AssetInput) (*uuid.UUID, error) {
+	var query string
+	var key string
+	if input.IPAddress != nil {
+		query = `ip_address = $2::inet`
+		key = *input.IPAddress
+	} else {
+		query = `LOWER(hostname) = LOWER($2)`
+		key = *input.Hostname
+	}
+
+	var id uuid.UUID
+	err := s.db.Get(&id, `
+		SELECT id FROM network_assets
+		WHERE tenant_id = $1 AND deleted_at IS NULL AND `+query+` AND port IS NOT DISTINCT FROM $3
+		ORDER BY created_at, id
+		LIMIT 1`,
+		tenantID, key, input.Port,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up asset: %w", err)
+	}
+	return &id, nil
+}
+
+// updateAsset locks an asset, checks its ETag, lets apply change its
+// writable fields and stores them
+func (s *AssetService) updateAsset(tenantID, assetID uuid.UUID, ifMatch string, source models.ChangeSource, apply func(*models.AssetInput) error) error {
+	tx, err := beginChange(s.db, source)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	input, err := lockAsset(tx, tenantID, assetID, ifMatch)
+	if err != nil {
+		return err
+	}
+	if err := apply(input); err != nil {
+		return err
+	}
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	tags, metadata, err := marshalAssetMaps(input)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE network_assets SET
+			hostname = $3, ip_address = $4, port = $5, asset_type = $6, operating_system = $7,
+			environment = $8, business_unit = $9, owner_email = $10, description = $11,
+			tags = $12, metadata = $13
+		WHERE id = $1 AND tenant_id = $2`,
+		assetID, tenantID, input.Hostname, input.IPAddress, input.Port, input.AssetType, input.OperatingSystem,
+		input.Environment, input.BusinessUnit, input.OwnerEmail, input.Description, tags, metadata,
+	); err != nil {
+		return fmt.Errorf("failed to update asset: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit asset update: %w", err)
+	}
+	return nil
+}
+
+func (s *AssetService) insertAsset(tenantID uuid.UUID, input *models.AssetInput, source models.ChangeSource) (uuid.UUID, error) {
+	tags, metadata, err := marshalAssetMaps(input)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var id uuid.UUID
+	err = withChange(s.db, source, func(tx *sqlx.Tx) error {
+		return tx.Get(&id, `
+			INSERT INTO network_assets (
+				tenant_id, hostname, ip_address, port, asset_type, operating_system,
+				environment, business_unit, owner_email, description, tags, metadata
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			RETURNING id`,
+			tenantID, input.Hostname, input.IPAddress, input.Port, input.AssetType, input.OperatingSystem,
+			input.Environment, input.BusinessUnit, input.OwnerEmail, input.Description, tags, metadata,
+		)
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create asset: %w", err)
+	}
+	return id, nil
+}
+
+// lockAsset reads the writable fields of an asset for update and checks the
+// client's ETag against it
+func lockAsset(tx *sqlx.Tx, tenantID, assetID uuid.UUID, ifMatch string) (*models.AssetInput, error) {
+	var input models.AssetInput
+	var tagsText, metadataText string
+	var updatedAt time.Time
+	err := tx.QueryRow(`
+		SELECT hostname, host(ip_address), port, asset_type, operating_system, environment,
+			business_unit, owner_email, description, COALESCE(tags, '{}')::text, COALESCE(metadata, '{}')::text,
+			updated_at
+		FROM network_assets
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		FOR UPDATE`,
+		assetID, tenantID,
+	).Scan(
+		&input.Hostname, &input.IPAddress, &input.Port, &input.AssetType, &input.OperatingSystem, &input.Environment,
+		&input.BusinessUnit, &input.OwnerEmail, &input.Description, &tagsText, &metadataText,
+		&updatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset: %w", err)
+	}
+	if ifMatch != "" && ifMatch != models.ETag(updatedAt) {
+		return nil, ErrPreconditionFailed
+	}
+
+	if err := json.Unmarshal([]byte(tagsText), &input.Tags); err != nil {
+		input.Tags = make(map[string]interface{})
+	}
+	if err := json.Unmarshal([]byte(metadataText), &input.Metadata); err != nil {
+		input.Metadata = make(map[string]interface{})
+	}
+	return &input, nil
+}
+
+// CreateCryptoImplementation records a crypto implementation of a tenant's
+// asset and returns its ID
+func (s *AssetService) CreateCryptoImplementation(tenantID, assetID uuid.UUID, input *models.CryptoImplementationInput, source models.ChangeSource) (uuid.UUID, error) {
+	return s.createCryptoImplementation(tenantID, assetID, nil, input, source)
+}
+
+// createCryptoImplementation records an implementation served on a port of
+// the asset, or on none when port is nil
+func (s *AssetService) createCryptoImplementation(tenantID, assetID uuid.UUID, port *int, input *models.CryptoImplementationInput, source models.ChangeSource) (uuid.UUID, error) {
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		return uuid.Nil, err
+	}
+
+	tx, err := beginChange(s.db, source)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback()
+
+	// The asset is locked so it cannot be deleted while the implementation is added
+	var locked int
+	err = tx.Get(&locked, `
+		SELECT 1 FROM network_assets
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		FOR SHARE`,
+		assetID, tenantID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrAssetNotFound
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to read asset: %w", err)
+	}
+	if err := checkCryptoReferences(tx, tenantID, input); err != nil {
+		return uuid.Nil, err
+	}
+
+	confidence := 1.0
+	if input.ConfidenceScore != nil {
+		confidence = *input.ConfidenceScore
+	}
+	risk, breakdown, ruleVersion, err := s.risk.scoreInput(tx, tenantID, input)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var id uuid.UUID
+	err = tx.Get(&id, `
+		INSERT INTO crypto_implementations (
+			tenant_id, asset_id, protocol, protocol_version, cipher_suite, key_exchange_algorithm,
+			signature_algorithm, symmetric_encryption, hash_algorithm, key_size, certificate_id,
+			discovery_method, confidence_score, source_sensor_id, raw_data, risk_score, compliance_status,
+			risk_breakdown, risk_rule_version, risk_evaluated_at, port
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, NOW(), $20)
+		RETURNING id`,
+		tenantID, assetID, input.Protocol, input.ProtocolVersion, input.CipherSuite, input.KeyExchangeAlgorithm,
+		input.SignatureAlgorithm, input.SymmetricEncryption, input.HashAlgorithm, input.KeySize, input.CertificateID,
+		input.DiscoveryMethod, confidence, input.SourceSensorID, nullJSON(input.RawData), risk, string(input.ComplianceStatus),
+		breakdown, ruleVersion, port,
+	)
+	if err != nil {
+		return uuid.Nil, s.cryptoImplementationWriteError(err, "create", tenantID, assetID, uuid.Nil, port, input)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit crypto implementation: %w", err)
+	}
+	return id, nil
+}
+
+// ReplaceCryptoImplementation sets every writable field of an
+// implementation. A non-empty ifMatch must be its current ETag.
+func (s *AssetService) ReplaceCryptoImplementation(tenantID, assetID, cryptoID uuid.UUID, ifMatch string, input *models.CryptoImplementationInput, source models.ChangeSource) error {
+	return s.updateCryptoImplementation(tenantID, assetID, cryptoID, ifMatch, source, func(current *models.CryptoImplementationInput) error {
+		*current = *input
+		return nil
+	})
+}
+
+// PatchCryptoImplementation applies a partial JSON document to an
+// implementation. A non-empty ifMatch must be its current ETag.
+func (s *AssetService) PatchCryptoImplementation(tenantID, assetID, cryptoID uuid.UUID, ifMatch string, patch []byte, source models.ChangeSource) error {
+	return s.updateCryptoImplementation(tenantID, assetID, cryptoID, ifMatch, source, func(current *models.CryptoImplementationInput) error {
+		return decodeStrict(patch, current)
+	})
+}
+
+// DeleteCryptoImplementation soft deletes an implementation. A non-empty
+// ifMatch must be its current ETag.
+func (s *AssetService) DeleteCryptoImplementation(tenantID, assetID, cryptoID uuid.UUID, ifMatch string, source models.ChangeSource) error {
+	tx, err := beginChange(s.db, source)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockCryptoImplementation(tx, tenantID, assetID, cryptoID, ifMatch); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE crypto_implementations SET deleted_at = NOW() WHERE id = $1`, cryptoID); err != nil {
+		return fmt.Errorf("failed to delete crypto implementation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit crypto implementation deletion: %w", err)
+	}
+	return nil
+}
+
+func (s *AssetService) updateCryptoImplementation(tenantID, assetID, cryptoID uuid.UUID, ifMatch string, source models.ChangeSource, apply func(*models.CryptoImplementationInput) error) error {
+	tx, err := beginChange(s.db, source)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	input, err := lockCryptoImplementation(tx, tenantID, assetID, cryptoID, ifMatch)
+	if err != nil {
+		return err
+	}
+	if err := apply(input); err != nil {
+		return err
+	}
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if err := checkCryptoReferences(tx, tenantID, input); err != nil {
+		return err
+	}
+
+	confidence := 1.0
+	if input.ConfidenceScore != nil {
+		confidence = *input.ConfidenceScore
+	}
+	risk, breakdown, ruleVersion, err := s.risk.scoreInput(tx, tenantID, input)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE crypto_implementations SET
+			protocol = $2, protocol_version = $3, cipher_suite = $4, key_exchange_algorithm = $5,
+			signature_algorithm = $6, symmetric_encryption = $7, hash_algorithm = $8, key_size = $9,
+			certificate_id = $10, discovery_method = $11, confidence_score = $12, source_sensor_id = $13,
+			raw_data = $14, risk_score = $15, compliance_status = $16,
+			risk_breakdown = $17, risk_rule_version = $18, risk_evaluated_at = NOW()
+		WHERE id = $1`,
+		cryptoID, input.Protocol, input.ProtocolVersion, input.CipherSuite, input.KeyExchangeAlgorithm,
+		input.SignatureAlgorithm, input.SymmetricEncryption, input.HashAlgorithm, input.KeySize,
+		input.CertificateID, input.DiscoveryMethod, confidence, input.SourceSensorID,
+		nullJSON(input.RawData), risk, string(input.ComplianceStatus), breakdown, ruleVersion,
+	); err != nil {
+		var port *int
+		if lookupErr := s.db.Get(&port, `SELECT port FROM crypto_implementations WHERE id = $1`, cryptoID); lookupErr != nil {
+			return fmt.Errorf("failed to update crypto implementation: %w", err)
+		}
+		return s.cryptoImplementationWriteError(err, "update", tenantID, assetID, cryptoID, port, input)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit crypto implementation update: %w", err)
+	}
+	return nil
+}
+
+// cryptoImplementationWriteError maps a violation of the implementation
+// identity to a *DuplicateCryptoImplementationError naming the
+// implementation already recorded. selfID is the implementation being
+// updated, if any.
+func (s *AssetService) cryptoImplementationWriteError(err error, action string, tenantID, assetID, selfID uuid.UUID, port *int, input *models.CryptoImplementationInput) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" || pqErr.Constraint != cryptoIdentityIndex {
+		return fmt.Errorf("failed to %s crypto implementation: %w", action, err)
+	}
+
+	// The transaction is aborted, so the implementation is looked up outside it
+	var existingID uuid.UUID
+	lookupErr := s.db.Get(&existingID, `
+		SELECT id FROM crypto_implementations
+		WHERE tenant_id = $1 AND asset_id = $2 AND id <> $3 AND deleted_at IS NULL
+		  AND COALESCE(port, 0) = COALESCE($4::integer, 0) AND protocol = $5
+		  AND COALESCE(protocol_version, '') = COALESCE($6::text, '')
+		  AND COALESCE(cipher_suite, '') = COALESCE($7::text, '')`,
+		tenantID, assetID, selfID, port, input.Protocol, input.ProtocolVersion, input.CipherSuite,
+	)
+	if lookupErr != nil {
+		return fmt.Errorf("failed to %s crypto implementation: %w", action, err)
+	}
+	return &DuplicateCryptoImplementationError{ExistingID: existingID}
+}
+
+// lockCryptoImplementation reads the writable fields of an implementation
+// for update and checks the client's ETag against it
+func lockCryptoImplementation(tx *sqlx.Tx, tenantID, assetID, cryptoID uuid.UUID, ifMatch string) (*models.CryptoImplementationInput, error) {
+	var input models.CryptoImplementationInput
+	var rawData sql.NullString
+	var compliance string
+	var updatedAt time.Time
+	err := tx.QueryRow(`
+		SELECT protocol, protocol_version, cipher_suite, key_exchange_algorithm, signature_algorithm,
+			symmetric_encryption, hash_algorithm, key_size, certificate_id, discovery_method,
+			confidence_score, source_sensor_id, raw_data::text, risk_score,
+			COALESCE(compliance_status, '{}')::text, updated_at
+		FROM crypto_implementations
+		WHERE id = $1 AND asset_id = $2 AND tenant_id = $3 AND deleted_at IS NULL
+		FOR UPDATE`,
+		cryptoID, assetID, tenantID,
+	).Scan(
+		&input.Protocol, &input.ProtocolVersion, &input.CipherSuite, &input.KeyExchangeAlgorithm, &input.SignatureAlgorithm,
+		&input.SymmetricEncryption, &input.HashAlgorithm, &input.KeySize, &input.CertificateID, &input.DiscoveryMethod,
+		&input.ConfidenceScore, &input.SourceSensorID, &rawData, &input.RiskScore,
+		&compliance, &updatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCryptoImplementationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crypto implementation: %w", err)
+	}
+	if ifMatch != "" && ifMatch != models.ETag(updatedAt) {
+		return nil, ErrPreconditionFailed
+	}
+
+	if rawData.Valid {
+		input.RawData = json.RawMessage(rawData.String)
+	}
+	input.ComplianceStatus = json.RawMessage(compliance)
+	return &input, nil
+}
+
+// checkCryptoReferences makes sure the certificate and sensor an
+// implementation refers to belong to the tenant
+func checkCryptoReferences(tx *sqlx.Tx, tenantID uuid.UUID, input *models.CryptoImplementationInput) error {
+	if input.CertificateID != nil {
+		var exists bool
+		if err := tx.Get(&exists, `SELECT EXISTS(SELECT 1 FROM certificates WHERE id = $1 AND tenant_id = $2)`,
+			*input.CertificateID, tenantID); err != nil {
+			return fmt.Errorf("failed to read certificate: %w", err)
+		}
+		if !exists {
+			return &models.ValidationError{Field: "certificate_id", Message: "certificate not found"}
+		}
+	}
+	if input.SourceSensorID != nil {
+		var exists bool
+		if err := tx.Get(&exists, `SELECT EXISTS(SELECT 1 FROM sensors WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL)`,
+			*input.SourceSensorID, tenantID); err != nil {
+			return fmt.Errorf("failed to read sensor: %w", err)
+		}
+		if !exists {
+			return &models.ValidationError{Field: "source_sensor_id", Message: "sensor not found"}
+		}
+	}
+	return nil
+}
+
+// GetCryptoImplementation retrieves one crypto implementation of an asset
+func (s *AssetService) GetCryptoImplementation(tenantID, assetID, cryptoID uuid.UUID) (*models.CryptoImplementation, error) {
+	var impl models.CryptoImplementation
+	err := s.db.Get(&impl, `
+		SELECT `+cryptoImplementationColumns+`
+		FROM crypto_implementations
+		WHERE id = $1 AND asset_id = $2 AND tenant_id = $3 AND deleted_at IS NULL`,
+		cryptoID, assetID, tenantID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCryptoImplementationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get crypto implementation: %w", err)
+	}
+
+	impls := []models.CryptoImplementation{impl}
+	if err := s.risk.explain(tenantID, impls); err != nil {
+		return nil, err
+	}
+	return &impls[0], nil
+}
+
+// decodeStrict decodes a JSON document into out, rejecting unknown fields
+// so misspelled ones are not silently ignored
+func decodeStrict(data []byte, out interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return &models.ValidationError{Field: "body", Message: err.Error()}
+	}
+	return nil
+}
+
+func marshalAssetMaps(input *models.AssetInput) (string, string, error) {
+	tags, err := json.Marshal(input.Tags)
+	if err != nil {
+		return "", "", &models.ValidationError{Field: "tags", Message: err.Error()}
+	}
+	metadata, err := json.Marshal(input.Metadata)
+	if err != nil {
+		return "", "", &models.ValidationError{Field: "metadata", Message: err.Error()}
+	}
+	return string(tags), string(metadata), nil
+}
+
+func nullJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"inventory-service/internal/cbom"
+	"inventory-service/internal/database"
+	"inventory-service/internal/models"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CBOMService exports a tenant's cryptographic inventory as a CycloneDX
+// CBOM and imports CBOMs produced by other tools
+type CBOMService struct {
+	db     *database.DB
+	assets *AssetService
+}
+
+func NewCBOMService(db *database.DB, assets *AssetService) *CBOMService {
+	return &CBOMService{db: db, assets: assets}
+}
+
+// ExportCBOM streams the CBOM of the tenant's assets matching the filters
+// to out. The inventory is read twice, once for the components and once
+// for the dependencies, from one snapshot, so memory does not grow with
+// the tenant. Nothing is written to out before the first query succeeded.
+func (s *CBOMService) ExportCBOM(ctx context.Context, tenantID uuid.UUID, filters models.CBOMFilters, out io.Writer) error {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	metadata := &cbom.Metadata{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Tools: &cbom.Tools{Components: []cbom.Component{
+			{Type: "application", Name: "inventory-service"},
+		}},
+		Properties: []cbom.Property{{Name: cbom.PropertyPrefix + "tenant_id", Value: tenantID.String()}},
+	}
+	for _, value := range filters.BusinessUnit {
+		metadata.Properties = append(metadata.Properties, cbom.Property{Name: cbom.PropertyPrefix + "filter:business_unit", Value: value})
+	}
+	for _, value := range filters.Environment {
+		metadata.Properties = append(metadata.Properties, cbom.Property{Name: cbom.PropertyPrefix + "filter:environment", Value: value})
+	}
+
+	builder := cbom.NewBuilder()
+	writer := cbom.NewWriter(out, metadata)
+	err = s.eachCBOMAsset(tx, tenantID, filters, func(asset *cbom.Asset) error {
+		for _, component := range builder.AssetComponents(asset) {
+			if err := writer.WriteComponent(&component); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.eachCBOMCertificate(tx, tenantID, filters, func(certificate *models.Certificate) error {
+		for _, component := range builder.CertificateComponents(certificate) {
+			if err := writer.WriteComponent(&component); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, component := range builder.Algorithms() {
+		if err := writer.WriteComponent(&component); err != nil {
+			return err
+		}
+	}
+
+	err = s.eachCBOMAsset(tx, tenantID, filters, func(asset *cbom.Asset) error {
+		for _, dependency := range builder.AssetDependencies(asset) {
+			if err := writer.WriteDependency(&dependency); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.eachCBOMCertificate(tx, tenantID, filters, func(certificate *models.Certificate) error {
+		for _, dependency := range builder.CertificateDependencies(certificate) {
+			if err := writer.WriteDependency(&dependency); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// cbomAssetConditions selects the live assets of a tenant matching the
+// filters; the tenant is $1
+func cbomAssetConditions(filters models.CBOMFilters) (string, []interface{}) {
+	conditions := []string{"a.tenant_id = $1", "a.deleted_at IS NULL"}
+	var args []interface{}
+	if len(filters.BusinessUnit) > 0 {
+		args = append(args, pq.Array(filters.BusinessUnit))
+		conditions = append(conditions, fmt.Sprintf("a.business_unit = ANY($%d)", len(args)+1))
+	}
+	if len(filters.Environment) > 0 {
+		args = append(args, pq.Array(filters.Environment))
+		conditions = append(conditions, fmt.Sprintf("a.environment::text = ANY($%d)", len(args)+1))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// eachCBOMAsset calls fn with each asset matching the filters and the
+// endpoints of its live implementations, in ID order
+func (s *CBOMService) eachCBOMAsset(tx *sqlx.Tx, tenantID uuid.UUID, filters models.CBOMFilters, fn func(*cbom.Asset) error) error {
+	conditions, args := cbomAssetConditions(filters)
+	rows, err := tx.Query(`
+		SELECT a.id, a.hostname, host(a.ip_address), a.port, a.asset_type, a.operating_system, a.environment,
+			a.business_unit, a.owner_email, a.description,
+			ci.id, ci.protocol, ci.protocol_version, ci.cipher_suite, ci.key_exchange_algorithm,
+			ci.signature_algorithm, ci.symmetric_encryption, ci.hash_algorithm, ci.key_size, ci.certificate_id,
+			ci.discovery_method, ci.confidence_score, ci.risk_score, COALESCE(ci.port, a.port)
+		FROM network_assets a
+		LEFT JOIN crypto_implementations ci ON ci.asset_id = a.id AND ci.deleted_at IS NULL
+		WHERE `+conditions+`
+		ORDER BY a.id, ci.id`,
+		append([]interface{}{tenantID}, args...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query assets: %w", err)
+	}
+	defer rows.Close()
+
+	var current *cbom.Asset
+	for rows.Next() {
+		var asset cbom.Asset
+		var endpoint cbom.Endpoint
+		var implementationID uuid.NullUUID
+		var protocol, discoveryMethod sql.NullString
+		var confidence sql.NullFloat64
+		var riskScore sql.NullInt64
+		if err := rows.Scan(
+			&asset.ID, &asset.Hostname, &asset.IPAddress, &asset.Port, &asset.AssetType, &asset.OperatingSystem,
+			&asset.Environment, &asset.BusinessUnit, &asset.OwnerEmail, &asset.Description,
+			&implementationID, &protocol, &endpoint.ProtocolVersion, &endpoint.CipherSuite,
+			&endpoint.KeyExchangeAlgorithm, &endpoint.SignatureAlgorithm, &endpoint.SymmetricEncryption,
+			&endpoint.HashAlgorithm, &endpoint.KeySize, &endpoint.CertificateID,
+			&discoveryMethod, &confidence, &riskScore, &endpoint.Port,
+		); err != nil {
+			return fmt.Errorf("failed to scan asset: %w", err)
+		}
+
+		if current == nil || current.ID != asset.ID {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return err
+				}
+			}
+			asset.TenantID = tenantID
+			current = &asset
+		}
+		if implementationID.Valid {
+			endpoint.ID = implementationID.UUID
+			endpoint.TenantID = tenantID
+			endpoint.AssetID = current.ID
+			endpoint.Protocol = protocol.String
+			endpoint.DiscoveryMethod = discoveryMethod.String
+			endpoint.ConfidenceScore = confidence.Float64
+			endpoint.RiskScore = int(riskScore.Int64)
+			current.Endpoints = append(current.Endpoints, endpoint)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read assets: %w", err)
+	}
+	if current != nil {
+		return fn(current)
+	}
+	return nil
+}
+
+// eachCBOMCertificate calls fn with each certificate an asset matching the
+// filters presents, or with every certificate of the tenant when nothing
+// is filtered, in ID order
+func (s *CBOMService) eachCBOMCertificate(tx *sqlx.Tx, tenantID uuid.UUID, filters models.CBOMFilters, fn func(*models.Certificate) error) error {
+	query := `SELECT ` + certificateColumns + ` FROM certificates c WHERE c.tenant_id = $1`
+	var args []interface{}
+	if !filters.Empty() {
+		var conditions string
+		conditions, args = cbomAssetConditions(filters)
+		query += `
+			AND EXISTS (
+				SELECT 1 FROM crypto_implementations ci
+				JOIN network_assets a ON a.id = ci.asset_id
+				WHERE ci.certificate_id = c.id AND ci.deleted_at IS NULL AND ` + conditions + `)`
+	}
+	rows, err := tx.Query(query+` ORDER BY c.id`, append([]interface{}{tenantID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to query certificates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		certificate, err := scanCertificate(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan certificate: %w", err)
+		}
+		if err := fn(certificate); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read certificates: %w", err)
+	}
+	return nil
+}
+
+// ImportCBOM loads a CycloneDX document into the tenant's inventory.
+// Device components become assets, matched by address or hostname and port
+// as a bulk upsert does; the protocols they depend on become crypto
+// implementations, one per cipher suite; certificates are matched by their
+// SHA-256 fingerprint. Protocols no device depends on are recorded on the
+// asset fallbackAssetID when it is given. Every record is stored on its
+// own, so what cannot be stored is reported without failing the rest.
+func (s *CBOMService) ImportCBOM(tenantID uuid.UUID, document *cbom.BOM, fallbackAssetID *uuid.UUID, source models.ChangeSource) (*models.CBOMImportResult, error) {
+	if fallbackAssetID != nil {
+		var exists bool
+		err := s.db.Get(&exists, `
+			SELECT EXISTS (SELECT 1 FROM network_assets WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL)`,
+			*fallbackAssetID, tenantID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read asset: %w", err)
+		}
+		if !exists {
+			return nil, ErrAssetNotFound
+		}
+	}
+
+	plan := cbom.NewPlan(document)
+	result := &models.CBOMImportResult{SerialNumber: document.SerialNumber}
+
+	certificates := make(map[string]uuid.UUID, len(plan.Certificates))
+	for i := range plan.Certificates {
+		certificate := &plan.Certificates[i]
+		id, created, err := s.importCertificate(tenantID, certificate, source)
+		if err != nil {
+			plan.Skip(certificate.Ref, err.Error())
+			continue
+		}
+		if created {
+			result.CertificatesCreated++
+		} else {
+			result.CertificatesMatched++
+		}
+		if certificate.Ref != "" {
+			certificates[certificate.Ref] = id
+		}
+	}
+
+	for i := range plan.Assets {
+		asset := &plan.Assets[i]
+		asset.Input.Normalize()
+		if err := asset.Input.Validate(); err != nil {
+			plan.Skip(asset.Ref, err.Error())
+			continue
+		}
+		id, err := s.assets.findAssetByKey(tenantID, &asset.Input)
+		if err != nil {
+			plan.Skip(asset.Ref, err.Error())
+			continue
+		}
+		if id != nil {
+			result.AssetsMatched++
+		} else {
+			created, err := s.assets.insertAsset(tenantID, &asset.Input, source)
+			if err != nil {
+				plan.Skip(asset.Ref, err.Error())
+				continue
+			}
+			id = &created
+			result.AssetsCreated++
+		}
+		for j := range asset.Implementations {
+			s.importImplementation(tenantID, *id, &asset.Implementations[j], certificates, plan, result, source)
+		}
+	}
+
+	for i := range plan.Unattached {
+		implementation := &plan.Unattached[i]
+		if fallbackAssetID == nil {
+			plan.Skip(implementation.Ref, "no network asset depends on the protocol")
+			continue
+		}
+		s.importImplementation(tenantID, *fallbackAssetID, implementation, certificates, plan, result, source)
+	}
+
+	result.Skipped = plan.Skipped
+	return result, nil
+}
+
+// importCertificate stores a certificate unless the tenant has one with the
+// same fingerprint, and returns its ID and whether it was created
+func (s *CBOMService) importCertificate(tenantID uuid.UUID, certificate *cbom.PlannedCertificate, source models.ChangeSource) (uuid.UUID, bool, error) {
+	var id uuid.UUID
+	err := withChange(s.db, source, func(tx *sqlx.Tx) error {
+		return tx.Get(&id, `
+			INSERT INTO certificates (
+				tenant_id, serial_number, subject_dn, issuer_dn, common_name, signature_algorithm,
+				public_key_algorithm, public_key_size, not_before, not_after, fingerprint_sha1, fingerprint_sha256
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			ON CONFLICT (tenant_id, fingerprint_sha256) DO NOTHING
+			RETURNING id`,
+			tenantID, certificate.SerialNumber, certificate.SubjectDN, certificate.IssuerDN, certificate.CommonName,
+			certificate.SignatureAlgorithm, certificate.PublicKeyAlgorithm, certificate.PublicKeySize,
+			certificate.NotBefore, certificate.NotAfter, certificate.FingerprintSHA1, certificate.FingerprintSHA256,
+		)
+	})
+	if err == nil {
+		return id, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, false, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	err = s.db.Get(&id, `SELECT id FROM certificates WHERE tenant_id = $1 AND fingerprint_sha256 = $2`,
+		tenantID, certificate.FingerprintSHA256)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to look up certificate: %w", err)
+	}
+	return id, false, nil
+}
+
+// importImplementation records an implementation on an asset unless the
+// asset already has a live one with the same protocol, version and cipher
+// suite
+func (s *CBOMService) importImplementation(tenantID, assetID uuid.UUID, implementation *cbom.PlannedImplementation,
+	certificates map[string]uuid.UUID, plan *cbom.Plan, result *models.CBOMImportResult, source models.ChangeSource) {
+	input := implementation.Input
+	if id, ok := certificates[implementation.CertificateRef]; ok {
+		input.CertificateID = &id
+	}
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		plan.Skip(implementation.Ref, err.Error())
+		return
+	}
+
+	var exists bool
+	err := s.db.Get(&exists, `
+		SELECT EXISTS (
+			SELECT 1 FROM crypto_implementations
+			WHERE tenant_id = $1 AND asset_id = $2 AND deleted_at IS NULL AND protocol::text = $3
+				AND protocol_version IS NOT DISTINCT FROM $4 AND cipher_suite IS NOT DISTINCT FROM $5)`,
+		tenantID, assetID, input.Protocol, input.ProtocolVersion, input.CipherSuite,
+	)
+	if err != nil {
+		plan.Skip(implementation.Ref, fmt.Sprintf("failed to look up crypto implementation: %v", err))
+		return
+	}
+	if exists {
+		result.ImplementationsExisting++
+		return
+	}
+	if _, err := s.assets.CreateCryptoImplementation(tenantID, assetID, &input, source); err != nil {
+		plan.Skip(implementation.Ref, err.Error())
+		return
+	}
+	result.ImplementationsCreated++
+}
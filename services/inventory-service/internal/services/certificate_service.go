@@ -0,0 +1,639 @@
+package services
+
+import (
+	"bytes"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"inventory-service/internal/algorithms"
+	"inventory-service/internal/database"
+	"inventory-service/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrCertificateNotFound is returned for certificates missing from the tenant's inventory
+var ErrCertificateNotFound = errors.New("certificate not found")
+
+// maxChainLength bounds the chain walk; real chains rarely exceed four certificates
+const maxChainLength = 10
+
+// certificateColumns are the columns scanned by scanCertificate. endpoint_count
+// counts the live asset ports presenting the certificate.
+const certificateColumns = `
+			c.id, c.tenant_id, c.serial_number, c.subject_dn, c.issuer_dn, c.common_name,
+			c.subject_alternative_names, c.signature_algorithm, c.public_key_algorithm,
+			c.public_key_size, c.not_before, c.not_after, c.fingerprint_sha1,
+			c.fingerprint_sha256, c.subject_key_id, c.authority_key_id, c.is_self_signed,
+			c.is_ca_certificate, c.key_usage, c.extended_key_usage, c.certificate_pem,
+			c.created_at, c.updated_at,
+			(SELECT COUNT(DISTINCT (ci.asset_id, ci.port))
+			 FROM crypto_implementations ci
+			 JOIN network_assets a ON a.id = ci.asset_id AND a.deleted_at IS NULL
+			 WHERE ci.certificate_id = c.id AND ci.deleted_at IS NULL) AS endpoint_count`
+
+// certificateSortColumns are the columns certificates can be sorted by
+var certificateSortColumns = map[string]string{
+	"common_name":          "c.common_name",
+	"issuer_dn":            "c.issuer_dn",
+	"not_after":            "c.not_after",
+	"not_before":           "c.not_before",
+	"public_key_size":      "c.public_key_size",
+	"signature_algorithm":  "c.signature_algorithm",
+	"public_key_algorithm": "c.public_key_algorithm",
+	"created_at":           "c.created_at",
+	"endpoint_count":       "endpoint_count",
+}
+
+type CertificateService struct {
+	db *database.DB
+}
+
+func NewCertificateService(db *database.DB) *CertificateService {
+	return &CertificateService{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCertificate scans a row selected with certificateColumns and derives
+// its status and missing key identifiers
+func scanCertificate(row rowScanner) (*models.Certificate, error) {
+	var certificate models.Certificate
+	var sans, keyUsage, extKeyUsage []sql.NullString
+	err := row.Scan(
+		&certificate.ID, &certificate.TenantID, &certificate.SerialNumber, &certificate.SubjectDN,
+		&certificate.IssuerDN, &certificate.CommonName, pq.Array(&sans), &certificate.SignatureAlgorithm,
+		&certificate.PublicKeyAlgorithm, &certificate.PublicKeySize, &certificate.NotBefore,
+		&certificate.NotAfter, &certificate.FingerprintSHA1, &certificate.FingerprintSHA256,
+		&certificate.SubjectKeyID, &certificate.AuthorityKeyID, &certificate.IsSelfSigned,
+		&certificate.IsCA, pq.Array(&keyUsage), pq.Array(&extKeyUsage), &certificate.PEM,
+		&certificate.CreatedAt, &certificate.UpdatedAt, &certificate.EndpointCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	certificate.SubjectAlternativeNames = nonNullStrings(sans)
+	certificate.KeyUsage = nonNullStrings(keyUsage)
+	certificate.ExtendedKeyUsage = nonNullStrings(extKeyUsage)
+	fillKeyIdentifiers(&certificate)
+	certificate.SetStatus(time.Now())
+	return &certificate, nil
+}
+
+// fillKeyIdentifiers parses the subject and authority key identifiers from the
+// stored PEM of certificates recorded before they were collected
+func fillKeyIdentifiers(certificate *models.Certificate) {
+	if (certificate.SubjectKeyID != nil && certificate.AuthorityKeyID != nil) || certificate.PEM == nil {
+		return
+	}
+	block, _ := pem.Decode([]byte(*certificate.PEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return
+	}
+	if certificate.SubjectKeyID == nil && len(parsed.SubjectKeyId) > 0 {
+		ski := hex.EncodeToString(parsed.SubjectKeyId)
+		certificate.SubjectKeyID = &ski
+	}
+	if certificate.AuthorityKeyID == nil && len(parsed.AuthorityKeyId) > 0 {
+		aki := hex.EncodeToString(parsed.AuthorityKeyId)
+		certificate.AuthorityKeyID = &aki
+	}
+	if !certificate.IsSelfSigned {
+		certificate.IsSelfSigned = bytes.Equal(parsed.RawSubject, parsed.RawIssuer) &&
+			parsed.CheckSignature(parsed.SignatureAlgorithm, parsed.RawTBSCertificate, parsed.Signature) == nil
+	}
+}
+
+func nonNullStrings(values []sql.NullString) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value.Valid {
+			result = append(result, value.String)
+		}
+	}
+	return result
+}
+
+// ListCertificates returns a page of the tenant's certificates matching the
+// filters, with the total number of matches
+func (s *CertificateService) ListCertificates(tenantID uuid.UUID, filters models.CertificateFilters) ([]models.Certificate, int, error) {
+	args := []interface{}{tenantID}
+	conditions := []string{"c.tenant_id = $1"}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filters.Search != "" {
+		pattern := arg("%" + filters.Search + "%")
+		conditions = append(conditions, fmt.Sprintf(`(
+			c.common_name ILIKE %[1]s OR
+			c.subject_dn ILIKE %[1]s OR
+			array_to_string(c.subject_alternative_names, ' ') ILIKE %[1]s
+		)`, pattern))
+	}
+	if filters.Issuer != "" {
+		conditions = append(conditions, "c.issuer_dn ILIKE "+arg("%"+filters.Issuer+"%"))
+	}
+	if len(filters.KeyAlgorithm) > 0 {
+		conditions = append(conditions, fmt.Sprintf("c.public_key_algorithm = ANY(%s)", arg(pq.Array(filters.KeyAlgorithm))))
+	}
+	if len(filters.KeySize) > 0 {
+		conditions = append(conditions, fmt.Sprintf("c.public_key_size = ANY(%s)", arg(pq.Array(filters.KeySize))))
+	}
+	if filters.MinKeySize > 0 {
+		conditions = append(conditions, "c.public_key_size >= "+arg(filters.MinKeySize))
+	}
+	if filters.MaxKeySize > 0 {
+		conditions = append(conditions, "c.public_key_size <= "+arg(filters.MaxKeySize))
+	}
+	if len(filters.SignatureAlgorithm) > 0 {
+		conditions = append(conditions, fmt.Sprintf("c.signature_algorithm = ANY(%s)", arg(pq.Array(filters.SignatureAlgorithm))))
+	}
+	if filters.SelfSigned != nil {
+		conditions = append(conditions, "c.is_self_signed = "+arg(*filters.SelfSigned))
+	}
+	if filters.CA != nil {
+		conditions = append(conditions, "c.is_ca_certificate = "+arg(*filters.CA))
+	}
+	if filters.ExpiringWithinDays != nil {
+		conditions = append(conditions, fmt.Sprintf(
+			"c.not_after > NOW() AND c.not_after <= NOW() + make_interval(days => %s)", arg(*filters.ExpiringWithinDays)))
+	}
+	if filters.Expired != nil {
+		if *filters.Expired {
+			conditions = append(conditions, "c.not_after <= NOW()")
+		} else {
+			conditions = append(conditions, "(c.not_after IS NULL OR c.not_after > NOW())")
+		}
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.Get(&total, "SELECT COUNT(*) FROM certificates c WHERE "+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get certificates count: %w", err)
+	}
+
+	sortBy, ok := certificateSortColumns[filters.SortBy]
+	if !ok {
+		sortBy = "c.not_after"
+	}
+	sortOrder := "ASC"
+	if filters.SortOrder == "desc" {
+		sortOrder = "DESC"
+	}
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM certificates c
+		WHERE %s
+		ORDER BY %s %s NULLS LAST, c.id
+		LIMIT %d OFFSET %d`,
+		certificateColumns, where, sortBy, sortOrder, filters.PageSize, (filters.Page-1)*filters.PageSize)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query certificates: %w", err)
+	}
+	defer rows.Close()
+
+	certificates := []models.Certificate{}
+	for rows.Next() {
+		certificate, err := scanCertificate(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan certificate: %w", err)
+		}
+		// The PEM is only returned by the detail view
+		certificate.PEM = nil
+		certificates = append(certificates, *certificate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read certificates: %w", err)
+	}
+
+	return certificates, total, nil
+}
+
+// getCertificate loads one certificate of the tenant
+func (s *CertificateService) getCertificate(tenantID, certificateID uuid.UUID) (*models.Certificate, error) {
+	row := s.db.QueryRow(`
+		SELECT `+certificateColumns+`
+		FROM certificates c
+		WHERE c.id = $1 AND c.tenant_id = $2`, certificateID, tenantID)
+	certificate, err := scanCertificate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCertificateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+	return certificate, nil
+}
+
+// GetCertificate returns a certificate with the asset ports presenting it
+func (s *CertificateService) GetCertificate(tenantID, certificateID uuid.UUID) (*models.CertificateDetail, error) {
+	certificate, err := s.getCertificate(tenantID, certificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT a.id, a.hostname, host(a.ip_address), COALESCE(ci.port, a.port), a.environment,
+			a.owner_email, ci.id, ci.protocol, ci.protocol_version, ci.last_verified_at
+		FROM crypto_implementations ci
+		JOIN network_assets a ON a.id = ci.asset_id AND a.deleted_at IS NULL
+		WHERE ci.certificate_id = $1 AND ci.tenant_id = $2 AND ci.deleted_at IS NULL
+		ORDER BY a.hostname NULLS LAST, a.ip_address, ci.port`, certificateID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificate endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	detail := &models.CertificateDetail{Certificate: *certificate, Endpoints: []models.CertificateEndpoint{}}
+	for rows.Next() {
+		var endpoint models.CertificateEndpoint
+		if err := rows.Scan(&endpoint.AssetID, &endpoint.Hostname, &endpoint.IPAddress, &endpoint.Port,
+			&endpoint.Environment, &endpoint.OwnerEmail, &endpoint.ImplementationID, &endpoint.Protocol,
+			&endpoint.ProtocolVersion, &endpoint.LastVerifiedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate endpoint: %w", err)
+		}
+		endpoint.ExpiredInUse = certificate.NotAfter != nil && !endpoint.LastVerifiedAt.Before(*certificate.NotAfter)
+		detail.Endpoints = append(detail.Endpoints, endpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read certificate endpoints: %w", err)
+	}
+
+	return detail, nil
+}
+
+// GetCertificateChain walks from a certificate up to its root through the
+// issuing certificates in the tenant's inventory. Issuers are matched by
+// subject key identifier against the authority key identifier, falling back
+// to the issuer DN when either identifier is unknown.
+func (s *CertificateService) GetCertificateChain(tenantID, certificateID uuid.UUID) (*models.CertificateChain, error) {
+	certificate, err := s.getCertificate(tenantID, certificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &models.CertificateChain{}
+	visited := map[uuid.UUID]bool{certificate.ID: true}
+	linkedBy := ""
+	for {
+		chain.Chain = append(chain.Chain, models.ChainLink{LinkedBy: linkedBy, Certificate: certificate})
+		if isChainRoot(certificate) {
+			chain.Complete = true
+			break
+		}
+		if len(chain.Chain) == maxChainLength {
+			chain.Broken = models.ChainTooLong
+			break
+		}
+
+		candidates, err := s.issuerCandidates(tenantID, certificate)
+		if err != nil {
+			return nil, err
+		}
+		issuer, method := selectIssuer(certificate, candidates)
+		if issuer == nil {
+			chain.Broken = models.ChainIssuerNotFound
+			break
+		}
+		if visited[issuer.ID] {
+			chain.Broken = models.ChainLoop
+			break
+		}
+		visited[issuer.ID] = true
+		certificate, linkedBy = issuer, method
+	}
+
+	for i := range chain.Chain {
+		switch {
+		case i == 0:
+			chain.Chain[i].Position = models.ChainPositionLeaf
+		case i == len(chain.Chain)-1 && chain.Complete:
+			chain.Chain[i].Position = models.ChainPositionRoot
+		default:
+			chain.Chain[i].Position = models.ChainPositionIntermediate
+		}
+	}
+	// A lone self-signed certificate is its own root
+	if len(chain.Chain) == 1 && chain.Complete && chain.Chain[0].Certificate.IsCA {
+		chain.Chain[0].Position = models.ChainPositionRoot
+	}
+	for i := range chain.Chain {
+		chain.Chain[i].Certificate.PEM = nil
+	}
+
+	return chain, nil
+}
+
+// issuerCandidates loads the certificates that may have issued certificate:
+// those whose subject is its issuer or whose key identifier is its authority
+// key identifier
+func (s *CertificateService) issuerCandidates(tenantID uuid.UUID, certificate *models.Certificate) ([]*models.Certificate, error) {
+	authorityKeyID := ""
+	if certificate.AuthorityKeyID != nil {
+		authorityKeyID = *certificate.AuthorityKeyID
+	}
+	rows, err := s.db.Query(`
+		SELECT `+certificateColumns+`
+		FROM certificates c
+		WHERE c.tenant_id = $1 AND c.id <> $2 AND (
+			(md5(c.subject_dn) = md5($3) AND c.subject_dn = $3) OR
+			($4 <> '' AND c.subject_key_id = $4)
+		)
+		ORDER BY c.not_after DESC NULLS LAST
+		LIMIT 50`, tenantID, certificate.ID, certificate.IssuerDN, authorityKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query issuer certificates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []*models.Certificate
+	for rows.Next() {
+		candidate, err := scanCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan issuer certificate: %w", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read issuer certificates: %w", err)
+	}
+	return candidates, nil
+}
+
+// isChainRoot reports whether certificate ends its chain: it is self-signed,
+// or names itself as issuer with its own key
+func isChainRoot(certificate *models.Certificate) bool {
+	if certificate.IsSelfSigned {
+		return true
+	}
+	if certificate.SubjectDN != certificate.IssuerDN {
+		return false
+	}
+	return certificate.AuthorityKeyID == nil || certificate.SubjectKeyID == nil ||
+		strings.EqualFold(*certificate.AuthorityKeyID, *certificate.SubjectKeyID)
+}
+
+// selectIssuer picks the issuer of certificate among candidates, ordered by
+// preference. A key identifier match wins; a DN match is only accepted when
+// the identifiers cannot tell it apart, so a re-keyed CA with the same name
+// is not mistaken for the issuer.
+func selectIssuer(certificate *models.Certificate, candidates []*models.Certificate) (*models.Certificate, string) {
+	authorityKeyID := certificate.AuthorityKeyID
+	for _, candidate := range candidates {
+		if authorityKeyID != nil && candidate.SubjectKeyID != nil &&
+			strings.EqualFold(*authorityKeyID, *candidate.SubjectKeyID) {
+			return candidate, models.ChainLinkKeyID
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate.SubjectDN != certificate.IssuerDN {
+			continue
+		}
+		if authorityKeyID == nil || candidate.SubjectKeyID == nil {
+			return candidate, models.ChainLinkIssuerDN
+		}
+	}
+	return nil, ""
+}
+
+// GetCertificateSummary counts the tenant's certificates by expiry, strength
+// and issuer
+func (s *CertificateService) GetCertificateSummary(tenantID uuid.UUID) (*models.CertificateSummary, error) {
+	summary := &models.CertificateSummary{GeneratedAt: time.Now()}
+	err := s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE c.not_after <= NOW()),
+			COUNT(*) FILTER (WHERE c.not_after <= NOW() AND EXISTS (
+				SELECT 1 FROM crypto_implementations ci
+				JOIN network_assets a ON a.id = ci.asset_id AND a.deleted_at IS NULL
+				WHERE ci.certificate_id = c.id AND ci.deleted_at IS NULL)),
+			COUNT(*) FILTER (WHERE c.not_after > NOW() AND c.not_after <= NOW() + INTERVAL '7 days'),
+			COUNT(*) FILTER (WHERE c.not_after > NOW() AND c.not_after <= NOW() + INTERVAL '30 days'),
+			COUNT(*) FILTER (WHERE c.not_after > NOW() AND c.not_after <= NOW() + INTERVAL '60 days'),
+			COUNT(*) FILTER (WHERE c.not_after > NOW() AND c.not_after <= NOW() + INTERVAL '90 days'),
+			COUNT(*) FILTER (WHERE c.is_self_signed),
+			COUNT(*) FILTER (WHERE c.is_ca_certificate),
+			COUNT(*) FILTER (WHERE c.public_key_algorithm IN ('RSA', 'DSA') AND c.public_key_size < 2048)
+		FROM certificates c
+		WHERE c.tenant_id = $1`, tenantID).Scan(
+		&summary.Total, &summary.Expired, &summary.ExpiredInUse, &summary.ExpiringWithin7Days,
+		&summary.ExpiringWithin30Days, &summary.ExpiringWithin60Days, &summary.ExpiringWithin90Days,
+		&summary.SelfSigned, &summary.CA, &summary.WeakKeys,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate summary: %w", err)
+	}
+
+	if summary.ByKeyAlgorithm, err = s.countCertificatesBy(tenantID,
+		"COALESCE(c.public_key_algorithm, 'unknown') || COALESCE('-' || c.public_key_size, '')", 0); err != nil {
+		return nil, err
+	}
+	if summary.BySignatureAlgorithm, err = s.countCertificatesBy(tenantID, "COALESCE(c.signature_algorithm, 'unknown')", 0); err != nil {
+		return nil, err
+	}
+	for name, count := range summary.BySignatureAlgorithm {
+		if weakSignature(name) {
+			summary.WeakSignatures += count
+		}
+	}
+	if summary.ByIssuer, err = s.countCertificatesBy(tenantID, "c.issuer_dn", 10); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// weakSignature reports whether the algorithm knowledge base deprecates a
+// certificate signature algorithm; names it does not know are weak when
+// they mention MD5 or SHA-1
+func weakSignature(name string) bool {
+	if entry, ok := algorithms.Lookup(name, algorithms.KindSignatureScheme); ok {
+		return entry.Deprecated
+	}
+	name = strings.ToLower(name)
+	return strings.Contains(name, "md5") || strings.Contains(name, "sha1")
+}
+
+// countCertificatesBy counts the tenant's certificates grouped by a column
+// expression, keeping the limit largest groups when limit is positive
+func (s *CertificateService) countCertificatesBy(tenantID uuid.UUID, expression string, limit int) (map[string]int, error) {
+	query := fmt.Sprintf(`
+		SELECT %s AS grouping, COUNT(*)
+		FROM certificates c
+		WHERE c.tenant_id = $1
+		GROUP BY grouping
+		ORDER BY COUNT(*) DESC`, expression)
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := s.db.Query(query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count certificates: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate count: %w", err)
+		}
+		counts[key] = count
+	}
+	return counts, rows.Err()
+}
+
+// ListReminders returns a page of the tenant's certificate expiry reminders,
+// certificates served after they expired first and then by expiry, with
+// the total number matching the filters
+func (s *CertificateService) ListReminders(tenantID uuid.UUID, filters models.CertificateReminderFilters) ([]models.CertificateReminder, int, error) {
+	args := []interface{}{tenantID}
+	conditions := []string{"r.tenant_id = $1"}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch filters.Status {
+	case "", models.ReminderStatusOpen:
+		conditions = append(conditions, "r.status = 'open'")
+	case models.ReminderStatusClosed:
+		conditions = append(conditions, "r.status = 'closed'")
+	}
+	if filters.OwnerEmail != "" {
+		conditions = append(conditions, "LOWER(COALESCE(a.owner_email, r.owner_email)) = LOWER("+arg(filters.OwnerEmail)+")")
+	}
+	if filters.AssetID != "" {
+		conditions = append(conditions, "r.asset_id = "+arg(filters.AssetID)+"::uuid")
+	}
+	if filters.CertificateID != "" {
+		conditions = append(conditions, "r.certificate_id = "+arg(filters.CertificateID)+"::uuid")
+	}
+	if filters.ExpiredInUse != nil {
+		conditions = append(conditions, "r.expired_in_use = "+arg(*filters.ExpiredInUse))
+	}
+	where := strings.Join(conditions, " AND ")
+	from := `
+		FROM certificate_expiry_reminders r
+		JOIN certificates c ON c.id = r.certificate_id
+		JOIN network_assets a ON a.id = r.asset_id
+		WHERE ` + where
+
+	var total int
+	if err := s.db.Get(&total, "SELECT COUNT(*)"+from, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get reminders count: %w", err)
+	}
+
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT r.id, r.certificate_id, c.common_name, c.fingerprint_sha256, c.not_after, r.asset_id, a.hostname,
+			host(a.ip_address), r.port, COALESCE(a.owner_email, r.owner_email), r.status, r.threshold_days,
+			r.expired_in_use, r.notified_at, r.closed_at, r.close_reason, r.renewed_by_certificate_id, r.created_at
+		%s
+		ORDER BY r.expired_in_use DESC, c.not_after ASC NULLS LAST, r.id
+		LIMIT %d OFFSET %d`, from, filters.PageSize, (filters.Page-1)*filters.PageSize), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query reminders: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	reminders := []models.CertificateReminder{}
+	for rows.Next() {
+		var reminder models.CertificateReminder
+		if err := rows.Scan(&reminder.ID, &reminder.CertificateID, &reminder.CommonName, &reminder.FingerprintSHA256,
+			&reminder.NotAfter, &reminder.AssetID, &reminder.Hostname, &reminder.IPAddress, &reminder.Port,
+			&reminder.OwnerEmail, &reminder.Status, &reminder.ThresholdDays, &reminder.ExpiredInUse,
+			&reminder.NotifiedAt, &reminder.ClosedAt, &reminder.CloseReason, &reminder.RenewedByCertificateID,
+			&reminder.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan reminder: %w", err)
+		}
+		if reminder.NotAfter != nil {
+			days := int(reminder.NotAfter.Sub(now).Hours() / 24)
+			reminder.DaysUntilExpiry = &days
+		}
+		reminders = append(reminders, reminder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read reminders: %w", err)
+	}
+
+	return reminders, total, nil
+}
+
+// GetCertificateHistory returns the lifecycle events of a certificate, the
+// most recent first
+func (s *CertificateService) GetCertificateHistory(tenantID, certificateID uuid.UUID) ([]models.CertificateHistoryEntry, error) {
+	var exists bool
+	err := s.db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM certificates WHERE id = $1 AND tenant_id = $2)`,
+		certificateID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+	if !exists {
+		return nil, ErrCertificateNotFound
+	}
+
+	rows, err := s.db.Query(`
+		SELECT h.id, h.certificate_id, h.event_type, h.related_certificate_id, related.fingerprint_sha256,
+			h.asset_id, h.port, h.details::text, h.created_at
+		FROM certificate_history h
+		LEFT JOIN certificates related ON related.id = h.related_certificate_id
+		WHERE h.certificate_id = $1 AND h.tenant_id = $2
+		ORDER BY h.created_at DESC`, certificateID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificate history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []models.CertificateHistoryEntry{}
+	for rows.Next() {
+		var entry models.CertificateHistoryEntry
+		var detailsText string
+		if err := rows.Scan(&entry.ID, &entry.CertificateID, &entry.EventType, &entry.RelatedCertificateID,
+			&entry.RelatedFingerprint, &entry.AssetID, &entry.Port, &detailsText, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate history: %w", err)
+		}
+		if err := json.Unmarshal([]byte(detailsText), &entry.Details); err != nil || entry.Details == nil {
+			entry.Details = make(map[string]interface{})
+		}
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read certificate history: %w", err)
+	}
+
+	return history, nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"inventory-service/internal/database"
+	"inventory-service/internal/models"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// HistoryService reads the change history of the inventory. The history is
+// written by database triggers (see 24-inventory-history.sql) on every
+// change, whichever service makes it; writers only name its source.
+type HistoryService struct {
+	db *database.DB
+}
+
+func NewHistoryService(db *database.DB) *HistoryService {
+	return &HistoryService{db: db}
+}
+
+// AssetHistory returns the changes of an asset and its crypto
+// implementations, the most recent first. The history of deleted assets
+// stays available.
+func (s *HistoryService) AssetHistory(tenantID, assetID uuid.UUID, filters models.ChangeFilters) ([]models.InventoryChange, int, error) {
+	var exists bool
+	err := s.db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM network_assets WHERE id = $1 AND tenant_id = $2)`,
+		assetID, tenantID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read asset: %w", err)
+	}
+	if !exists {
+		return nil, 0, ErrAssetNotFound
+	}
+	filters.AssetID = assetID.String()
+	return s.ListChanges(tenantID, filters)
+}
+
+// ListChanges returns the tenant's inventory changes matching the filters,
+// the most recent first, and the total number matching them
+func (s *HistoryService) ListChanges(tenantID uuid.UUID, filters models.ChangeFilters) ([]models.InventoryChange, int, error) {
+	args := []interface{}{tenantID}
+	conditions := []string{"tenant_id = $1"}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filters.EntityType != "" {
+		conditions = append(conditions, "entity_type = "+arg(filters.EntityType))
+	}
+	if filters.EntityID != "" {
+		conditions = append(conditions, "entity_id = "+arg(filters.EntityID)+"::uuid")
+	}
+	if filters.AssetID != "" {
+		conditions = append(conditions, "asset_id = "+arg(filters.AssetID)+"::uuid")
+	}
+	if filters.Source != "" {
+		conditions = append(conditions, "source = "+arg(filters.Source))
+	}
+	if filters.Field != "" {
+		conditions = append(conditions, "changes ? "+arg(filters.Field))
+	}
+	if filters.Since != "" {
+		conditions = append(conditions, "changed_at >= "+arg(filters.Since)+"::timestamptz")
+	}
+	if filters.Until != "" {
+		conditions = append(conditions, "changed_at < "+arg(filters.Until)+"::timestamptz")
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.Get(&total, "SELECT COUNT(*) FROM inventory_changes WHERE "+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get changes count: %w", err)
+	}
+
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT id, entity_type, entity_id, asset_id, version, operation, changes::text, source, changed_by,
+			reference, changed_at
+		FROM inventory_changes
+		WHERE %s
+		ORDER BY changed_at DESC, version DESC, id
+		LIMIT %d OFFSET %d`, where, filters.PageSize, (filters.Page-1)*filters.PageSize), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.InventoryChange{}
+	for rows.Next() {
+		var change models.InventoryChange
+		var fields string
+		if err := rows.Scan(&change.ID, &change.EntityType, &change.EntityID, &change.AssetID, &change.Version,
+			&change.Operation, &fields, &change.Source, &change.ChangedBy, &change.Reference,
+			&change.ChangedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan change: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fields), &change.Changes); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read changes: %w", err)
+	}
+
+	return changes, total, nil
+}
+
+// beginChange begins a transaction whose inventory changes the history
+// attributes to source
+func beginChange(db *database.DB, source models.ChangeSource) (*sqlx.Tx, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	changedBy := ""
+	if source.UserID != nil {
+		changedBy = source.UserID.String()
+	}
+	if _, err := tx.Exec(`
+		SELECT set_config('inventory.change_source', $1, true), set_config('inventory.changed_by', $2, true),
+			set_config('inventory.change_reference', $3, true)`,
+		source.Source, changedBy, source.Reference,
+	); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to set change source: %w", err)
+	}
+	return tx, nil
+}
+
+// withChange runs fn in a transaction begun by beginChange, committing it
+// when fn succeeds
+func withChange(db *database.DB, source models.ChangeSource, fn func(tx *sqlx.Tx) error) error {
+	tx, err := beginChange(db, source)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"fmt"
+	"inventory-service/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// PermissionService checks the tenant permissions granted to users by the
+// RBAC schema
+type PermissionService struct {
+	db *database.DB
+}
+
+func NewPermissionService(db *database.DB) *PermissionService {
+	return &PermissionService{db: db}
+}
+
+// UserHasPermission reports whether a user holds a permission, such as
+// assets.update, in a tenant
+func (s *PermissionService) UserHasPermission(userID, tenantID uuid.UUID, permission string) (bool, error) {
+	var granted bool
+	if err := s.db.Get(&granted, `SELECT user_has_permission($1, $2, $3)`, userID, tenantID, permission); err != nil {
+		return false, fmt.Errorf("failed to check permission: %w", err)
+	}
+	return granted, nil
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inventory-service/internal/database"
+	"inventory-service/internal/models"
+	"inventory-service/internal/risk"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrRiskRuleVersionNotFound is returned for rule versions the tenant
+	// never published
+	ErrRiskRuleVersionNotFound = errors.New("risk rule version not found")
+	// ErrRiskRuleVersionConflict is returned when another version was
+	// published at the same time
+	ErrRiskRuleVersionConflict = errors.New("another risk rule version was published concurrently")
+)
+
+// recomputeBatch is how many implementations are rescored per query
+const recomputeBatch = 500
+
+// staleRisk selects implementations whose score was computed with other
+// rules than $2, or before they last changed
+const staleRisk = `(risk_rule_version IS DISTINCT FROM $2 OR risk_evaluated_at IS NULL OR risk_evaluated_at < updated_at)`
+
+// RiskService scores crypto implementations with the default risk rules
+// adjusted by each tenant's overrides, and keeps the stored scores current
+type RiskService struct {
+	db       *database.DB
+	defaults *risk.RuleSet
+	wake     chan struct{}
+}
+
+func NewRiskService(db *database.DB) *RiskService {
+	return &RiskService{
+		db:       db,
+		defaults: risk.DefaultRules(),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// tenantRules are the merged rules of a tenant and their version
+type tenantRules struct {
+	version string
+	rules   []risk.Rule
+}
+
+// rulesFor returns the rules a tenant's implementations are scored with
+func (s *RiskService) rulesFor(q sqlx.Queryer, tenantID uuid.UUID) (*tenantRules, *models.RiskRuleSet, error) {
+	version := fmt.Sprintf("default-v%d", s.defaults.Version)
+	overrides, err := s.latestRuleSet(q, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if overrides == nil {
+		return &tenantRules{version: version, rules: risk.Merge(s.defaults, nil)}, nil, nil
+	}
+	version += fmt.Sprintf("/tenant-v%d", overrides.Version)
+	return &tenantRules{version: version, rules: risk.Merge(s.defaults, overrides.Document)}, overrides, nil
+}
+
+func (s *RiskService) latestRuleSet(q sqlx.Queryer, tenantID uuid.UUID) (*models.RiskRuleSet, error) {
+	rows, err := q.Query(`
+		SELECT version, format, source, document::text, comment, created_by, created_at
+		FROM risk_rule_sets
+		WHERE tenant_id = $1
+		ORDER BY version DESC
+		LIMIT 1`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get risk rules: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanRuleSet(rows)
+}
+
+func scanRuleSet(row rowScanner) (*models.RiskRuleSet, error) {
+	var ruleSet models.RiskRuleSet
+	var document string
+	if err := row.Scan(&ruleSet.Version, &ruleSet.Format, &ruleSet.Source, &document, &ruleSet.Comment,
+		&ruleSet.CreatedBy, &ruleSet.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to scan risk rules: %w", err)
+	}
+	ruleSet.Document = &risk.TenantRules{}
+	if err := json.Unmarshal([]byte(document), ruleSet.Document); err != nil {
+		return nil, fmt.Errorf("failed to decode risk rules version %d: %w", ruleSet.Version, err)
+	}
+	return &ruleSet, nil
+}
+
+// GetRules returns the merged rules of a tenant, with its current overrides
+func (s *RiskService) GetRules(tenantID uuid.UUID) (*models.RiskRules, error) {
+	rules, overrides, err := s.rulesFor(s.db, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.RiskRules{
+		RuleVersion:    rules.version,
+		DefaultVersion: s.defaults.Version,
+		Rules:          rules.rules,
+		Overrides:      overrides,
+	}
+	if overrides != nil {
+		result.TenantVersion = &overrides.Version
+	}
+	return result, nil
+}
+
+// ListRuleVersions returns the tenant's override versions, the latest first
+func (s *RiskService) ListRuleVersions(tenantID uuid.UUID) ([]models.RiskRuleSet, error) {
+	rows, err := s.db.Query(`
+		SELECT version, format, comment, created_by, created_at
+		FROM risk_rule_sets
+		WHERE tenant_id = $1
+		ORDER BY version DESC`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk rule versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []models.RiskRuleSet{}
+	for rows.Next() {
+		var ruleSet models.RiskRuleSet
+		if err := rows.Scan(&ruleSet.Version, &ruleSet.Format, &ruleSet.Comment, &ruleSet.CreatedBy, &ruleSet.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan risk rule version: %w", err)
+		}
+		versions = append(versions, ruleSet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read risk rule versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetRuleVersion returns a version of the tenant's overrides
+func (s *RiskService) GetRuleVersion(tenantID uuid.UUID, version int) (*models.RiskRuleSet, error) {
+	ruleSet, err := scanRuleSet(s.db.QueryRow(`
+		SELECT version, format, source, document::text, comment, created_by, created_at
+		FROM risk_rule_sets
+		WHERE tenant_id = $1 AND version = $2`, tenantID, version))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRiskRuleVersionNotFound
+	}
+	return ruleSet, err
+}
+
+// PublishRules validates an override document and stores it as the
+// tenant's next version. Stored scores are recomputed in the background.
+func (s *RiskService) PublishRules(tenantID, userID uuid.UUID, source []byte, format string, comment *string) (*models.RiskRuleSet, error) {
+	document, err := risk.ParseTenantRules(source, format, s.defaults)
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode risk rules: %w", err)
+	}
+
+	ruleSet := models.RiskRuleSet{
+		Format:    format,
+		Source:    string(source),
+		Document:  document,
+		Comment:   comment,
+		CreatedBy: &userID,
+	}
+	err = s.db.QueryRow(`
+		INSERT INTO risk_rule_sets (tenant_id, version, document, source, format, comment, created_by)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6
+		FROM risk_rule_sets WHERE tenant_id = $1
+		RETURNING version, created_at`,
+		tenantID, string(canonical), ruleSet.Source, format, comment, userID,
+	).Scan(&ruleSet.Version, &ruleSet.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrRiskRuleVersionConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store risk rules: %w", err)
+	}
+
+	s.Notify()
+	return &ruleSet, nil
+}
+
+// RestoreRuleVersion publishes an earlier version of the tenant's overrides
+// again as its next version
+func (s *RiskService) RestoreRuleVersion(tenantID, userID uuid.UUID, version int) (*models.RiskRuleSet, error) {
+	previous, err := s.GetRuleVersion(tenantID, version)
+	if err != nil {
+		return nil, err
+	}
+	comment := fmt.Sprintf("Restored version %d", version)
+	return s.PublishRules(tenantID, userID, []byte(previous.Source), previous.Format, &comment)
+}
+
+// GetBreakdown returns the stored score of an implementation and the rules
+// that make it up
+func (s *RiskService) GetBreakdown(tenantID, assetID, cryptoID uuid.UUID) (*models.RiskBreakdown, error) {
+	rules, _, err := s.rulesFor(s.db, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := models.RiskBreakdown{CryptoImplementationID: cryptoID}
+	var factors sql.NullString
+	err = s.db.QueryRow(`
+		SELECT risk_score, risk_breakdown::text, risk_rule_version, risk_evaluated_at, `+staleRisk+`
+		FROM crypto_implementations
+		WHERE id = $1 AND asset_id = $3 AND tenant_id = $4 AND deleted_at IS NULL`,
+		cryptoID, rules.version, assetID, tenantID,
+	).Scan(&breakdown.RiskScore, &factors, &breakdown.RuleVersion, &breakdown.EvaluatedAt, &breakdown.Stale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCryptoImplementationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get risk breakdown: %w", err)
+	}
+
+	breakdown.RiskLevel = models.GetRiskLevel(breakdown.RiskScore)
+	breakdown.Factors = decodeFactors(factors)
+	return &breakdown, nil
+}
+
+// explain sets the risk factors of implementations read from the database
+// to the titles of the rules in their stored breakdowns. Implementations
+// not scored yet are scored on the fly.
+func (s *RiskService) explain(tenantID uuid.UUID, impls []models.CryptoImplementation) error {
+	if len(impls) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(impls))
+	for i := range impls {
+		ids[i] = impls[i].ID
+	}
+	rows, err := s.db.Query(`
+		SELECT id, risk_breakdown::text FROM crypto_implementations
+		WHERE id = ANY($1) AND tenant_id = $2 AND risk_breakdown IS NOT NULL`,
+		pq.Array(ids), tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to get risk breakdowns: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[uuid.UUID][]risk.Factor, len(impls))
+	for rows.Next() {
+		var id uuid.UUID
+		var factors sql.NullString
+		if err := rows.Scan(&id, &factors); err != nil {
+			return fmt.Errorf("failed to scan risk breakdown: %w", err)
+		}
+		stored[id] = decodeFactors(factors)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read risk breakdowns: %w", err)
+	}
+
+	var rules *tenantRules
+	for i := range impls {
+		impl := &impls[i]
+		factors, ok := stored[impl.ID]
+		if !ok {
+			if rules == nil {
+				if rules, _, err = s.rulesFor(s.db, tenantID); err != nil {
+					return err
+				}
+			}
+			result := risk.Evaluate(rules.rules, implementationSubject(impl))
+			impl.RiskScore = result.Score
+			factors = result.Factors
+		}
+		impl.RiskLevel = models.GetRiskLevel(impl.RiskScore)
+		impl.RiskFactors = make([]string, 0, len(factors))
+		for _, factor := range factors {
+			impl.RiskFactors = append(impl.RiskFactors, factor.Title)
+		}
+	}
+	return nil
+}
+
+// scoreInput scores an implementation being written, returning the values
+// of its risk_score, risk_breakdown and risk_rule_version columns
+func (s *RiskService) scoreInput(tx *sqlx.Tx, tenantID uuid.UUID, input *models.CryptoImplementationInput) (int, string, string, error) {
+	rules, _, err := s.rulesFor(tx, tenantID)
+	if err != nil {
+		return 0, "", "", err
+	}
+	confidence := 1.0
+	if input.ConfidenceScore != nil {
+		confidence = *input.ConfidenceScore
+	}
+	result := risk.Evaluate(rules.rules, &risk.Subject{
+		Protocol:             input.Protocol,
+		ProtocolVersion:      input.ProtocolVersion,
+		CipherSuite:          input.CipherSuite,
+		KeyExchangeAlgorithm: input.KeyExchangeAlgorithm,
+		SignatureAlgorithm:   input.SignatureAlgorithm,
+		SymmetricEncryption:  input.SymmetricEncryption,
+		HashAlgorithm:        input.HashAlgorithm,
+		KeySize:              input.KeySize,
+		DiscoveryMethod:      input.DiscoveryMethod,
+		ConfidenceScore:      confidence,
+	})
+	factors, err := json.Marshal(result.Factors)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to encode risk breakdown: %w", err)
+	}
+	return result.Score, string(factors), rules.version, nil
+}
+
+func implementationSubject(impl *models.CryptoImplementation) *risk.Subject {
+	return &risk.Subject{
+		Protocol:             impl.Protocol,
+		ProtocolVersion:      impl.ProtocolVersion,
+		CipherSuite:          impl.CipherSuite,
+		KeyExchangeAlgorithm: impl.KeyExchangeAlgorithm,
+		SignatureAlgorithm:   impl.SignatureAlgorithm,
+		SymmetricEncryption:  impl.SymmetricEncryption,
+		HashAlgorithm:        impl.HashAlgorithm,
+		KeySize:              impl.KeySize,
+		DiscoveryMethod:      impl.DiscoveryMethod,
+		ConfidenceScore:      impl.ConfidenceScore,
+	}
+}
+
+func decodeFactors(text sql.NullString) []risk.Factor {
+	factors := []risk.Factor{}
+	if text.Valid {
+		if err := json.Unmarshal([]byte(text.String), &factors); err != nil || factors == nil {
+			factors = []risk.Factor{}
+		}
+	}
+	return factors
+}
+
+// Notify wakes the recompute loop, after rules changed
+func (s *RiskService) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run recomputes stale scores at every interval and whenever rules change,
+// until ctx is done. Implementations discovered or changed by sensors are
+// picked up on the next pass.
+func (s *RiskService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RecomputeAll(ctx); err != nil {
+			log.Printf("❌ Risk score recompute failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// RecomputeAll rescores the stale implementations of every tenant
+func (s *RiskService) RecomputeAll(ctx context.Context) error {
+	var tenants []uuid.UUID
+	if err := s.db.Select(&tenants, `
+		SELECT DISTINCT tenant_id FROM crypto_implementations WHERE deleted_at IS NULL`); err != nil {
+		return fmt.Errorf("failed to list tenants: %w", err)
+	}
+	for _, tenantID := range tenants {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, err := s.Recompute(ctx, tenantID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recompute rescores the tenant's stale implementations and returns how
+// many scores were stored
+func (s *RiskService) Recompute(ctx context.Context, tenantID uuid.UUID) (int, error) {
+	rules, _, err := s.rulesFor(s.db, tenantID)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	after := uuid.Nil
+	for ctx.Err() == nil {
+		var impls []models.CryptoImplementation
+		if err := s.db.Select(&impls, `
+			SELECT `+cryptoImplementationColumns+`
+			FROM crypto_implementations
+			WHERE tenant_id = $1 AND deleted_at IS NULL AND id > $3 AND `+staleRisk+`
+			ORDER BY id
+			LIMIT $4`,
+			tenantID, rules.version, after, recomputeBatch,
+		); err != nil {
+			return updated, fmt.Errorf("failed to list stale risk scores: %w", err)
+		}
+
+		for i := range impls {
+			impl := &impls[i]
+			result := risk.Evaluate(rules.rules, implementationSubject(impl))
+			factors, err := json.Marshal(result.Factors)
+			if err != nil {
+				return updated, fmt.Errorf("failed to encode risk breakdown: %w", err)
+			}
+			// An implementation changed meanwhile stays stale for the next pass
+			res, err := s.db.Exec(`
+				UPDATE crypto_implementations
+				SET risk_score = $2, risk_breakdown = $3, risk_rule_version = $4, risk_evaluated_at = NOW()
+				WHERE id = $1 AND updated_at = $5`,
+				impl.ID, result.Score, string(factors), rules.version, impl.UpdatedAt)
+			if err != nil {
+				return updated, fmt.Errorf("failed to store risk score: %w", err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				updated++
+			}
+		}
+
+		if len(impls) < recomputeBatch {
+			break
+		}
+		after = impls[len(impls)-1].ID
+	}
+	return updated, nil
+}
@@ -0,0 +1,541 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inventory-service/internal/database"
+	"inventory-service/internal/models"
+	"inventory-service/internal/scans"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Limits and intervals of scan imports
+const (
+	// scanImportMaxAttempts is how often an import is run before it is
+	// marked failed
+	scanImportMaxAttempts = 3
+	// scanImportStaleAfter is when an import left in processing is claimed
+	// again
+	scanImportStaleAfter = 30 * time.Minute
+	// scanImportPollInterval is how often the worker looks for imports when
+	// not woken
+	scanImportPollInterval = 15 * time.Second
+)
+
+var (
+	// ErrScanImportNotFound is returned for an import job the tenant does not have
+	ErrScanImportNotFound = errors.New("scan import not found")
+	// ErrInvalidScan is returned for an upload that is not a readable scan report
+	ErrInvalidScan = errors.New("invalid scan report")
+)
+
+// scanImportColumns are the columns of a job as read by scanImport
+const scanImportColumns = `id, tenant_id, format, filename, dry_run, status, attempts, row_count, imported_count,
+	failed_count, summary::text, last_error, created_by, created_at, started_at, completed_at`
+
+// ScanImportService imports the reports of external scanners into the
+// inventory. Uploads are stored as jobs and imported by a background
+// worker; a job interrupted by a restart is claimed again, and importing a
+// report twice leaves the inventory unchanged.
+type ScanImportService struct {
+	db     *database.DB
+	assets *AssetService
+	wake   chan struct{}
+}
+
+func NewScanImportService(db *database.DB, assets *AssetService) *ScanImportService {
+	return &ScanImportService{
+		db:     db,
+		assets: assets,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Submit stores a scan report as a pending import and wakes the worker.
+// An empty format is detected from the report, which is read once up front
+// so an unreadable upload is refused rather than failing in the background.
+func (s *ScanImportService) Submit(tenantID, userID uuid.UUID, format string, filename *string, dryRun bool, payload []byte) (*models.ScanImport, error) {
+	if format == "" {
+		detected, err := scans.DetectFormat(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidScan, err)
+		}
+		format = detected
+	}
+	rows, err := scans.Parse(format, payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScan, err)
+	}
+
+	job, err := scanImport(s.db.QueryRow(`
+		INSERT INTO scan_imports (tenant_id, created_by, format, filename, dry_run, row_count, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+scanImportColumns,
+		tenantID, userID, format, filename, dryRun, len(rows), payload,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scan import: %w", err)
+	}
+	job.Errors = []models.ScanImportRowError{}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// ListImports returns a page of the tenant's imports, newest first, and
+// their total. Row errors are only returned by GetImport.
+func (s *ScanImportService) ListImports(tenantID uuid.UUID, filters models.ScanImportFilters) ([]models.ScanImport, int, error) {
+	conditions := "tenant_id = $1"
+	args := []interface{}{tenantID}
+	if filters.Status != "" {
+		args = append(args, filters.Status)
+		conditions += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+
+	var total int
+	if err := s.db.Get(&total, `SELECT COUNT(*) FROM scan_imports WHERE `+conditions, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count scan imports: %w", err)
+	}
+
+	args = append(args, filters.PageSize, (filters.Page-1)*filters.PageSize)
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT `+scanImportColumns+`
+		FROM scan_imports
+		WHERE `+conditions+`
+		ORDER BY created_at DESC, id
+		LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query scan imports: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.ScanImport{}
+	for rows.Next() {
+		job, err := scanImport(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan scan import: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read scan imports: %w", err)
+	}
+	return jobs, total, nil
+}
+
+// GetImport returns an import of the tenant with its row errors
+func (s *ScanImportService) GetImport(tenantID, importID uuid.UUID) (*models.ScanImport, error) {
+	var rowErrors string
+	row := s.db.QueryRow(`
+		SELECT `+scanImportColumns+`, row_errors::text
+		FROM scan_imports
+		WHERE id = $1 AND tenant_id = $2`,
+		importID, tenantID,
+	)
+	job, err := scanImport(row, &rowErrors)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScanImportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan import: %w", err)
+	}
+	if err := json.Unmarshal([]byte(rowErrors), &job.Errors); err != nil {
+		return nil, fmt.Errorf("failed to decode scan import errors: %w", err)
+	}
+	return job, nil
+}
+
+// Run imports stored reports until ctx is done. The worker wakes up when
+// a report is submitted and polls for reports stored by other replicas or
+// left behind by a crashed worker.
+func (s *ScanImportService) Run(ctx context.Context) {
+	ticker := time.NewTicker(scanImportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			job, err := s.claim()
+			if err != nil {
+				log.Printf("❌ Failed to claim scan import: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			if err := s.process(job); err != nil {
+				log.Printf("❌ Scan import %s failed (attempt %d): %v", job.ID, job.Attempts, err)
+				if err := s.fail(job.ID, err); err != nil {
+					log.Printf("❌ %v", err)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks the oldest pending import as processing and returns it with
+// its report, or nil when there is none
+func (s *ScanImportService) claim() (*models.ScanImport, error) {
+	staleSeconds := int(scanImportStaleAfter / time.Second)
+	_, err := s.db.Exec(`
+		UPDATE scan_imports
+		SET status = 'failed', last_error = 'import did not finish', completed_at = NOW(), payload = NULL
+		WHERE status = 'processing' AND attempts >= $2 AND started_at < NOW() - $1 * INTERVAL '1 second'`,
+		staleSeconds, scanImportMaxAttempts,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fail abandoned scan imports: %w", err)
+	}
+
+	var payload []byte
+	job, err := scanImport(s.db.QueryRow(`
+		WITH claimable AS (
+			SELECT id AS claimable_id FROM scan_imports
+			WHERE status = 'pending'
+			   OR (status = 'processing' AND attempts < $2 AND started_at < NOW() - $1 * INTERVAL '1 second')
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE scan_imports
+		SET status = 'processing', started_at = NOW(), attempts = attempts + 1
+		FROM claimable
+		WHERE id = claimable_id
+		RETURNING `+scanImportColumns+`, payload`,
+		staleSeconds, scanImportMaxAttempts,
+	), &payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scan import: %w", err)
+	}
+	job.Payload = payload
+	return job, nil
+}
+
+// process imports the rows of a claimed report and completes the job.
+// Rows that cannot be imported are recorded with the reason.
+func (s *ScanImportService) process(job *models.ScanImport) error {
+	rows, err := scans.Parse(job.Format, job.Payload)
+	if err != nil {
+		return err
+	}
+
+	importer := &scanImporter{
+		service: s,
+		job:     job,
+		source: models.ChangeSource{
+			Source:    models.ChangeSourceImport,
+			UserID:    job.CreatedBy,
+			Reference: "scan_import:" + job.ID.String(),
+		},
+		assets:       make(map[string]*uuid.UUID),
+		certificates: make(map[string]*uuid.UUID),
+	}
+	job.Errors = []models.ScanImportRowError{}
+	job.ImportedCount = 0
+	for i := range rows {
+		row := &rows[i]
+		err := row.Err
+		if err == nil {
+			err = importer.importRow(row)
+		}
+		if err != nil {
+			job.Errors = append(job.Errors, models.ScanImportRowError{Row: i + 1, Target: row.Target(), Reason: err.Error()})
+			continue
+		}
+		job.ImportedCount++
+	}
+	job.RowCount = len(rows)
+	job.FailedCount = len(job.Errors)
+
+	summary, err := json.Marshal(job.Summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode scan import summary: %w", err)
+	}
+	rowErrors, err := json.Marshal(job.Errors)
+	if err != nil {
+		return fmt.Errorf("failed to encode scan import errors: %w", err)
+	}
+	if _, err := s.db.Exec(`
+		UPDATE scan_imports
+		SET status = 'completed', row_count = $2, imported_count = $3, failed_count = $4, summary = $5,
+		    row_errors = $6, last_error = NULL, payload = NULL, completed_at = NOW()
+		WHERE id = $1`,
+		job.ID, job.RowCount, job.ImportedCount, job.FailedCount, summary, rowErrors,
+	); err != nil {
+		return fmt.Errorf("failed to complete scan import: %w", err)
+	}
+
+	mode := ""
+	if job.DryRun {
+		mode = " (dry run)"
+	}
+	log.Printf("📥 Imported %s scan %s%s: %d rows, %d failed", job.Format, job.ID, mode, job.RowCount, job.FailedCount)
+	return nil
+}
+
+// fail records an import error. The job is returned to pending for another
+// attempt unless it has used them up, in which case it is marked failed.
+func (s *ScanImportService) fail(importID uuid.UUID, cause error) error {
+	_, err := s.db.Exec(`
+		UPDATE scan_imports
+		SET status = CASE WHEN attempts >= $3 THEN 'failed' ELSE 'pending' END,
+		    last_error = $2,
+		    payload = CASE WHEN attempts >= $3 THEN NULL ELSE payload END,
+		    completed_at = CASE WHEN attempts >= $3 THEN NOW() END
+		WHERE id = $1`,
+		importID, cause.Error(), scanImportMaxAttempts,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record scan import failure: %w", err)
+	}
+	return nil
+}
+
+// scanImporter writes the rows of one report, remembering the assets and
+// certificates it resolved. In a dry run nothing is written: assets and
+// certificates the import would create are remembered without an ID.
+type scanImporter struct {
+	service      *ScanImportService
+	job          *models.ScanImport
+	source       models.ChangeSource
+	assets       map[string]*uuid.UUID
+	certificates map[string]*uuid.UUID
+}
+
+// importRow records a row's asset, certificates and implementations. The
+// leaf certificate is linked to the implementations and recorded against
+// the endpoint.
+func (im *scanImporter) importRow(row *scans.Row) error {
+	tenantID := im.job.TenantID
+
+	assetID, err := im.asset(row)
+	if err != nil {
+		return err
+	}
+
+	var leafID *uuid.UUID
+	for i, certificate := range row.Certificates {
+		id, err := im.certificate(certificate)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			leafID = id
+		}
+	}
+
+	var port *int
+	if row.Port != 0 {
+		port = &row.Port
+	}
+	for i := range row.Implementations {
+		input := row.Implementations[i]
+		input.CertificateID = leafID
+		if err := im.implementation(assetID, port, &input); err != nil {
+			return err
+		}
+	}
+
+	if leafID != nil && assetID != nil && !im.job.DryRun {
+		_, err := im.service.db.Exec(`
+			INSERT INTO certificate_endpoints (tenant_id, certificate_id, asset_id, port, first_seen_at, last_seen_at)
+			VALUES ($1, $2, $3, $4, NOW(), NOW())
+			ON CONFLICT (certificate_id, asset_id, port) DO UPDATE SET last_seen_at = EXCLUDED.last_seen_at`,
+			tenantID, *leafID, *assetID, row.Port,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record certificate endpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// asset resolves the asset of a row's host, creating it unless it exists.
+// It returns nil for an asset a dry run would create.
+func (im *scanImporter) asset(row *scans.Row) (*uuid.UUID, error) {
+	input := row.Asset(im.job.Format)
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	key := strings.ToLower(row.IPAddress + "|" + row.Hostname)
+	if id, ok := im.assets[key]; ok {
+		return id, nil
+	}
+
+	id, err := im.service.assets.findAssetByKey(im.job.TenantID, &input)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case id != nil:
+		im.job.Summary.AssetsMatched++
+	case im.job.DryRun:
+		im.job.Summary.AssetsCreated++
+	default:
+		created, err := im.service.assets.insertAsset(im.job.TenantID, &input, im.source)
+		if err != nil {
+			return nil, err
+		}
+		id = &created
+		im.job.Summary.AssetsCreated++
+	}
+	im.assets[key] = id
+	return id, nil
+}
+
+// certificate stores a certificate unless the tenant has one with the same
+// fingerprint, completing a stored one that lacks the PEM. It returns nil
+// for a certificate a dry run would create.
+func (im *scanImporter) certificate(certificate *scans.Certificate) (*uuid.UUID, error) {
+	if id, ok := im.certificates[certificate.FingerprintSHA256]; ok {
+		return id, nil
+	}
+
+	var id *uuid.UUID
+	if im.job.DryRun {
+		var existing uuid.UUID
+		err := im.service.db.Get(&existing, `SELECT id FROM certificates WHERE tenant_id = $1 AND fingerprint_sha256 = $2`,
+			im.job.TenantID, certificate.FingerprintSHA256)
+		switch {
+		case err == nil:
+			id = &existing
+			im.job.Summary.CertificatesMatched++
+		case errors.Is(err, sql.ErrNoRows):
+			im.job.Summary.CertificatesCreated++
+		default:
+			return nil, fmt.Errorf("failed to look up certificate: %w", err)
+		}
+		im.certificates[certificate.FingerprintSHA256] = id
+		return id, nil
+	}
+
+	var stored uuid.UUID
+	var created bool
+	err := withChange(im.service.db, im.source, func(tx *sqlx.Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO certificates (tenant_id, serial_number, subject_dn, issuer_dn, common_name,
+			                          subject_alternative_names, signature_algorithm, public_key_algorithm,
+			                          public_key_size, not_before, not_after, fingerprint_sha1, fingerprint_sha256,
+			                          certificate_pem, is_self_signed, is_ca_certificate, key_usage, extended_key_usage,
+			                          subject_key_id, authority_key_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+			ON CONFLICT (tenant_id, fingerprint_sha256) DO UPDATE SET
+				certificate_pem = COALESCE(certificates.certificate_pem, EXCLUDED.certificate_pem),
+				subject_key_id = COALESCE(certificates.subject_key_id, EXCLUDED.subject_key_id),
+				authority_key_id = COALESCE(certificates.authority_key_id, EXCLUDED.authority_key_id)
+			RETURNING id, (xmax = 0)`,
+			im.job.TenantID, nullText(certificate.SerialNumber), certificate.SubjectDN, certificate.IssuerDN,
+			nullText(certificate.CommonName), pq.Array(certificate.SubjectAlternativeNames),
+			nullText(certificate.SignatureAlgorithm), nullText(certificate.PublicKeyAlgorithm),
+			certificate.PublicKeySize, certificate.NotBefore, certificate.NotAfter, certificate.FingerprintSHA1,
+			certificate.FingerprintSHA256, certificate.PEM, certificate.IsSelfSigned, certificate.IsCA,
+			pq.Array(certificate.KeyUsage), pq.Array(certificate.ExtendedKeyUsage),
+			nullText(certificate.SubjectKeyID), nullText(certificate.AuthorityKeyID),
+		).Scan(&stored, &created)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store certificate %s: %w", certificate.FingerprintSHA256, err)
+	}
+	if created {
+		im.job.Summary.CertificatesCreated++
+	} else {
+		im.job.Summary.CertificatesMatched++
+	}
+	im.certificates[certificate.FingerprintSHA256] = &stored
+	return &stored, nil
+}
+
+// implementation records an implementation on the asset's port unless it
+// already has a live one with the same protocol, version and cipher suite,
+// in which case that one is marked verified by the scan
+func (im *scanImporter) implementation(assetID *uuid.UUID, port *int, input *models.CryptoImplementationInput) error {
+	if assetID == nil {
+		im.job.Summary.ImplementationsCreated++
+		return nil
+	}
+
+	var existing uuid.UUID
+	err := im.service.db.Get(&existing, `
+		SELECT id FROM crypto_implementations
+		WHERE tenant_id = $1 AND asset_id = $2 AND deleted_at IS NULL AND COALESCE(port, 0) = COALESCE($3, 0)
+			AND protocol::text = $4 AND COALESCE(protocol_version, '') = COALESCE($5, '')
+			AND COALESCE(cipher_suite, '') = COALESCE($6, '')`,
+		im.job.TenantID, *assetID, port, input.Protocol, input.ProtocolVersion, input.CipherSuite,
+	)
+	switch {
+	case err == nil:
+		im.job.Summary.ImplementationsExisting++
+		if im.job.DryRun {
+			return nil
+		}
+		err := withChange(im.service.db, im.source, func(tx *sqlx.Tx) error {
+			_, err := tx.Exec(`
+				UPDATE crypto_implementations
+				SET last_verified_at = NOW(), certificate_id = COALESCE($2, certificate_id)
+				WHERE id = $1`,
+				existing, input.CertificateID,
+			)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to verify crypto implementation: %w", err)
+		}
+		return nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("failed to look up crypto implementation: %w", err)
+	}
+
+	if !im.job.DryRun {
+		if _, err := im.service.assets.createCryptoImplementation(im.job.TenantID, *assetID, port, input, im.source); err != nil {
+			return err
+		}
+	}
+	im.job.Summary.ImplementationsCreated++
+	return nil
+}
+
+// scanImport reads a job from its scanImportColumns, followed by extra
+// columns
+func scanImport(row rowScanner, extra ...interface{}) (*models.ScanImport, error) {
+	var job models.ScanImport
+	var summary string
+	dest := append([]interface{}{
+		&job.ID, &job.TenantID, &job.Format, &job.Filename, &job.DryRun, &job.Status, &job.Attempts,
+		&job.RowCount, &job.ImportedCount, &job.FailedCount, &summary, &job.LastError, &job.CreatedBy,
+		&job.CreatedAt, &job.StartedAt, &job.CompletedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(summary), &job.Summary); err != nil {
+		return nil, fmt.Errorf("failed to decode scan import summary: %w", err)
+	}
+	return &job, nil
+}
+
+// nullText converts an empty string to SQL NULL
+func nullText(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
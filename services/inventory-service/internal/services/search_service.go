@@ -0,0 +1,230 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"inventory-service/internal/database"
+	"inventory-service/internal/models"
+	"inventory-service/internal/search"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSavedSearchNotFound = errors.New("saved search not found")
+	ErrSavedSearchExists   = errors.New("a saved search with this name already exists")
+)
+
+// maxFacetValues bounds the values counted per facet, the most common first
+const maxFacetValues = 20
+
+// riskLevelOrder lists the risk levels from the highest
+var riskLevelOrder = []string{"high", "medium", "low", "unknown"}
+
+// SearchService runs inventory queries and keeps users' saved searches
+type SearchService struct {
+	db     *database.DB
+	assets *AssetService
+}
+
+func NewSearchService(db *database.DB, assets *AssetService) *SearchService {
+	return &SearchService{db: db, assets: assets}
+}
+
+// Search returns a page of the tenant's assets matching the query, the
+// total number matching and the facet counts of all of them. Queries that
+// do not compile return a *search.Error.
+func (s *SearchService) Search(tenantID uuid.UUID, filters models.SearchFilters) ([]models.Asset, int, models.SearchFacets, error) {
+	args := []interface{}{tenantID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	condition, err := search.Compile(filters.Query, arg)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	assets, total, err := s.assets.listAssets(assetListing{
+		conditions: []string{condition},
+		args:       args,
+		sortBy:     filters.SortBy,
+		sortOrder:  filters.SortOrder,
+		page:       filters.Page,
+		pageSize:   filters.PageSize,
+	})
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	facets, err := s.facets("a.tenant_id = $1 AND a.deleted_at IS NULL AND "+condition, args)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return assets, total, facets, nil
+}
+
+// facets counts the assets matching where by facet value
+func (s *SearchService) facets(where string, args []interface{}) (models.SearchFacets, error) {
+	riskLevel := "CASE"
+	for _, level := range riskLevelOrder {
+		condition, _ := search.RiskLevelCondition("risk", level)
+		riskLevel += fmt.Sprintf(" WHEN %s THEN '%s'", condition, level)
+	}
+	riskLevel += " END"
+
+	rows, err := s.db.Query(`
+		SELECT 'asset_type', a.asset_type::text, COUNT(*)
+		FROM network_assets a WHERE `+where+` GROUP BY 2
+		UNION ALL
+		SELECT 'environment', a.environment::text, COUNT(*)
+		FROM network_assets a WHERE `+where+` AND a.environment IS NOT NULL GROUP BY 2
+		UNION ALL
+		SELECT 'business_unit', a.business_unit, COUNT(*)
+		FROM network_assets a WHERE `+where+` AND a.business_unit IS NOT NULL GROUP BY 2
+		UNION ALL
+		SELECT 'risk_level', `+riskLevel+`, COUNT(*)
+		FROM (SELECT `+search.AssetRiskScore+` AS risk FROM network_assets a WHERE `+where+`) scores GROUP BY 2
+		UNION ALL
+		SELECT 'protocol', impl.protocol, COUNT(DISTINCT a.id)
+		FROM network_assets a
+		JOIN crypto_implementations impl ON impl.asset_id = a.id AND impl.deleted_at IS NULL
+		WHERE `+where+` GROUP BY 2
+		ORDER BY 1, 3 DESC, 2`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count facets: %w", err)
+	}
+	defer rows.Close()
+
+	facets := models.SearchFacets{
+		"asset_type":    {},
+		"environment":   {},
+		"business_unit": {},
+		"risk_level":    {},
+		"protocol":      {},
+	}
+	for rows.Next() {
+		var facet string
+		var count models.FacetCount
+		if err := rows.Scan(&facet, &count.Value, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan facet: %w", err)
+		}
+		if len(facets[facet]) < maxFacetValues {
+			facets[facet] = append(facets[facet], count)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read facets: %w", err)
+	}
+	return facets, nil
+}
+
+// ListSavedSearches returns the user's saved searches by name
+func (s *SearchService) ListSavedSearches(tenantID, userID uuid.UUID) ([]models.SavedSearch, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, description, query, created_at, updated_at
+		FROM saved_searches
+		WHERE tenant_id = $1 AND user_id = $2
+		ORDER BY name`, tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		var saved models.SavedSearch
+		if err := rows.Scan(&saved.ID, &saved.Name, &saved.Description, &saved.Query, &saved.CreatedAt,
+			&saved.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %w", err)
+		}
+		searches = append(searches, saved)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read saved searches: %w", err)
+	}
+	return searches, nil
+}
+
+// GetSavedSearch returns a saved search of the user
+func (s *SearchService) GetSavedSearch(tenantID, userID, searchID uuid.UUID) (*models.SavedSearch, error) {
+	var saved models.SavedSearch
+	err := s.db.QueryRow(`
+		SELECT id, name, description, query, created_at, updated_at
+		FROM saved_searches
+		WHERE id = $1 AND tenant_id = $2 AND user_id = $3`, searchID, tenantID, userID,
+	).Scan(&saved.ID, &saved.Name, &saved.Description, &saved.Query, &saved.CreatedAt, &saved.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSavedSearchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved search: %w", err)
+	}
+	return &saved, nil
+}
+
+// CreateSavedSearch saves a query for the user. Queries that do not
+// compile return a *search.Error.
+func (s *SearchService) CreateSavedSearch(tenantID, userID uuid.UUID, input *models.SavedSearchInput) (*models.SavedSearch, error) {
+	if err := search.Validate(input.Query); err != nil {
+		return nil, err
+	}
+
+	saved := &models.SavedSearch{Name: input.Name, Description: input.Description, Query: input.Query}
+	err := s.db.QueryRow(`
+		INSERT INTO saved_searches (tenant_id, user_id, name, description, query)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		tenantID, userID, input.Name, input.Description, input.Query,
+	).Scan(&saved.ID, &saved.CreatedAt, &saved.UpdatedAt)
+	if err != nil {
+		return nil, savedSearchWriteError(err, "create")
+	}
+	return saved, nil
+}
+
+// ReplaceSavedSearch replaces a saved search of the user
+func (s *SearchService) ReplaceSavedSearch(tenantID, userID, searchID uuid.UUID, input *models.SavedSearchInput) (*models.SavedSearch, error) {
+	if err := search.Validate(input.Query); err != nil {
+		return nil, err
+	}
+
+	saved := &models.SavedSearch{ID: searchID, Name: input.Name, Description: input.Description, Query: input.Query}
+	err := s.db.QueryRow(`
+		UPDATE saved_searches SET name = $4, description = $5, query = $6
+		WHERE id = $1 AND tenant_id = $2 AND user_id = $3
+		RETURNING created_at, updated_at`,
+		searchID, tenantID, userID, input.Name, input.Description, input.Query,
+	).Scan(&saved.CreatedAt, &saved.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSavedSearchNotFound
+	}
+	if err != nil {
+		return nil, savedSearchWriteError(err, "update")
+	}
+	return saved, nil
+}
+
+// DeleteSavedSearch deletes a saved search of the user
+func (s *SearchService) DeleteSavedSearch(tenantID, userID, searchID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM saved_searches WHERE id = $1 AND tenant_id = $2 AND user_id = $3`,
+		searchID, tenantID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+// savedSearchWriteError maps a duplicate name to ErrSavedSearchExists
+func savedSearchWriteError(err error, action string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrSavedSearchExists
+	}
+	return fmt.Errorf("failed to %s saved search: %w", action, err)
+}
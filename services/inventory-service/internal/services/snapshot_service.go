@@ -0,0 +1,556 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inventory-service/internal/database"
+	"inventory-service/internal/models"
+	"inventory-service/internal/risk"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrSnapshotExists   = errors.New("a snapshot with this name already exists")
+)
+
+// SnapshotService names points in time of the inventory and compares the
+// inventory between two of them. The inventory as of a time is rebuilt
+// from the change history: the latest version of each record changed by
+// then, unless that version was deleted by then.
+type SnapshotService struct {
+	db *database.DB
+}
+
+func NewSnapshotService(db *database.DB) *SnapshotService {
+	return &SnapshotService{db: db}
+}
+
+// weakAlgorithmKinds are the algorithms of an implementation checked
+// against the knowledge base, by their risk.Subject name
+var weakAlgorithmKinds = []string{"cipher", "key_exchange", "signature", "hash"}
+
+// liveStates is a subquery of the states of the tenant's ($1) records of
+// an entity type as of at: entity_id, asset_id and state
+func liveStates(entityType, at string) string {
+	return fmt.Sprintf(`(
+		SELECT entity_id, asset_id, state FROM (
+			SELECT DISTINCT ON (entity_id) entity_id, asset_id, state
+			FROM inventory_changes
+			WHERE tenant_id = $1 AND entity_type = '%s' AND changed_at <= %s
+			ORDER BY entity_id, version DESC
+		) latest
+		WHERE state->>'deleted_at' IS NULL OR (state->>'deleted_at')::timestamptz > %s
+	)`, entityType, at, at)
+}
+
+// CreateSnapshot names the inventory as of input.TakenAt, now by default
+func (s *SnapshotService) CreateSnapshot(tenantID, userID uuid.UUID, input *models.SnapshotInput) (*models.InventorySnapshot, error) {
+	tx, err := s.beginRead()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var now time.Time
+	if err := tx.Get(&now, `SELECT NOW()`); err != nil {
+		return nil, fmt.Errorf("failed to read time: %w", err)
+	}
+	takenAt := now
+	if input.TakenAt != nil {
+		if input.TakenAt.After(now) {
+			return nil, &models.ValidationError{Field: "taken_at", Message: "cannot be in the future"}
+		}
+		takenAt = *input.TakenAt
+	}
+
+	summary, err := snapshotSummary(tx, tenantID, takenAt)
+	if err != nil {
+		return nil, err
+	}
+	// The snapshot is read in a read-only transaction, so it is written
+	// once that is done with
+	tx.Rollback()
+
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode summary: %w", err)
+	}
+	snapshot := &models.InventorySnapshot{
+		Name:        input.Name,
+		Description: input.Description,
+		TakenAt:     takenAt,
+		Summary:     *summary,
+		CreatedBy:   &userID,
+	}
+	err = s.db.QueryRow(`
+		INSERT INTO inventory_snapshots (tenant_id, name, description, taken_at, summary, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		tenantID, input.Name, input.Description, takenAt, string(encoded), userID,
+	).Scan(&snapshot.ID, &snapshot.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrSnapshotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots returns the tenant's snapshots, the latest first, and
+// their total number
+func (s *SnapshotService) ListSnapshots(tenantID uuid.UUID, filters models.SnapshotFilters) ([]models.InventorySnapshot, int, error) {
+	var total int
+	if err := s.db.Get(&total, `SELECT COUNT(*) FROM inventory_snapshots WHERE tenant_id = $1`, tenantID); err != nil {
+		return nil, 0, fmt.Errorf("failed to get snapshots count: %w", err)
+	}
+
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	}
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT id, name, description, taken_at, summary::text, created_by, created_at
+		FROM inventory_snapshots
+		WHERE tenant_id = $1
+		ORDER BY taken_at DESC, name
+		LIMIT %d OFFSET %d`, filters.PageSize, (filters.Page-1)*filters.PageSize), tenantID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []models.InventorySnapshot{}
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	return snapshots, total, nil
+}
+
+// GetSnapshot returns a snapshot of the tenant
+func (s *SnapshotService) GetSnapshot(tenantID, snapshotID uuid.UUID) (*models.InventorySnapshot, error) {
+	snapshot, err := scanSnapshot(s.db.QueryRow(`
+		SELECT id, name, description, taken_at, summary::text, created_by, created_at
+		FROM inventory_snapshots
+		WHERE id = $1 AND tenant_id = $2`, snapshotID, tenantID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSnapshotNotFound
+	}
+	return snapshot, err
+}
+
+// DeleteSnapshot deletes a snapshot of the tenant. The history it names is
+// kept.
+func (s *SnapshotService) DeleteSnapshot(tenantID, snapshotID uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM inventory_snapshots WHERE id = $1 AND tenant_id = $2`, snapshotID, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrSnapshotNotFound
+	}
+	return nil
+}
+
+// Diff compares the tenant's inventory between two points, each a
+// snapshot ID or an RFC 3339 timestamp; to defaults to now. from must be
+// before to.
+func (s *SnapshotService) Diff(tenantID uuid.UUID, from, to string) (*models.InventoryDiff, error) {
+	tx, err := s.beginRead()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var now time.Time
+	if err := tx.Get(&now, `SELECT NOW()`); err != nil {
+		return nil, fmt.Errorf("failed to read time: %w", err)
+	}
+	fromPoint, err := s.diffPoint(tx, tenantID, "from", from, now)
+	if err != nil {
+		return nil, err
+	}
+	toPoint := &models.DiffPoint{At: now}
+	if to != "" {
+		if toPoint, err = s.diffPoint(tx, tenantID, "to", to, now); err != nil {
+			return nil, err
+		}
+	}
+	if !fromPoint.At.Before(toPoint.At) {
+		return nil, &models.ValidationError{Field: "from", Message: "must be before to"}
+	}
+
+	diff := &models.InventoryDiff{From: *fromPoint, To: *toPoint}
+	older, newer := fromPoint.At, toPoint.At
+	if diff.Assets.Added, err = addedAssets(tx, tenantID, older, newer); err != nil {
+		return nil, err
+	}
+	if diff.Assets.Removed, err = addedAssets(tx, tenantID, newer, older); err != nil {
+		return nil, err
+	}
+	if diff.CryptoImplementations.Added, err = addedImplementations(tx, tenantID, older, newer); err != nil {
+		return nil, err
+	}
+	if diff.CryptoImplementations.Removed, err = addedImplementations(tx, tenantID, newer, older); err != nil {
+		return nil, err
+	}
+	if err := riskChanges(tx, tenantID, older, newer, &diff.CryptoImplementations); err != nil {
+		return nil, err
+	}
+	if diff.NewWeakAlgorithms, err = newWeakAlgorithms(tx, tenantID, older, newer); err != nil {
+		return nil, err
+	}
+	if diff.Certificates.Added, err = addedCertificates(tx, tenantID, older, newer); err != nil {
+		return nil, err
+	}
+	if diff.Certificates.Expired, err = expiredCertificates(tx, tenantID, older, newer); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// beginRead begins a read-only transaction, so all reads of the history
+// see the same changes
+func (s *SnapshotService) beginRead() (*sqlx.Tx, error) {
+	tx, err := s.db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// diffPoint resolves a side of a diff, a snapshot ID or a timestamp not in
+// the future
+func (s *SnapshotService) diffPoint(tx *sqlx.Tx, tenantID uuid.UUID, field, value string, now time.Time) (*models.DiffPoint, error) {
+	if value == "" {
+		return nil, &models.ValidationError{Field: field, Message: "is required"}
+	}
+	if id, err := uuid.Parse(value); err == nil {
+		point := &models.DiffPoint{SnapshotID: &id}
+		err := tx.QueryRow(`SELECT name, taken_at FROM inventory_snapshots WHERE id = $1 AND tenant_id = $2`,
+			id, tenantID).Scan(&point.Name, &point.At)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSnapshotNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		return point, nil
+	}
+
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, &models.ValidationError{Field: field, Message: "must be a snapshot ID or an RFC 3339 timestamp"}
+	}
+	if at.After(now) {
+		return nil, &models.ValidationError{Field: field, Message: "cannot be in the future"}
+	}
+	return &models.DiffPoint{At: at}, nil
+}
+
+// snapshotSummary counts the tenant's inventory as of at
+func snapshotSummary(tx *sqlx.Tx, tenantID uuid.UUID, at time.Time) (*models.SnapshotSummary, error) {
+	var summary models.SnapshotSummary
+	err := tx.QueryRow(`
+		WITH assets AS `+liveStates(models.EntityAsset, "$2")+`,
+		implementations AS `+liveStates(models.EntityCryptoImplementation, "$2")+`,
+		certificates AS `+liveStates(models.EntityCertificate, "$2")+`
+		SELECT
+			(SELECT COUNT(*) FROM assets),
+			(SELECT COUNT(*) FROM implementations),
+			(SELECT COUNT(*) FROM implementations WHERE (state->>'risk_score')::int >= 70),
+			(SELECT COUNT(*) FROM implementations WHERE (state->>'risk_score')::int BETWEEN 40 AND 69),
+			(SELECT COUNT(*) FROM implementations WHERE (state->>'risk_score')::int BETWEEN 1 AND 39),
+			(SELECT COUNT(*) FROM certificates),
+			(SELECT COUNT(*) FROM certificates WHERE (state->>'not_after')::timestamptz <= $2)`,
+		tenantID, at,
+	).Scan(&summary.Assets, &summary.CryptoImplementations, &summary.HighRiskImplementations,
+		&summary.MediumRiskImplementations, &summary.LowRiskImplementations, &summary.Certificates,
+		&summary.ExpiredCertificates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize inventory: %w", err)
+	}
+
+	weak, err := weakAlgorithms(tx, tenantID, at)
+	if err != nil {
+		return nil, err
+	}
+	summary.WeakAlgorithms = len(weak)
+	return &summary, nil
+}
+
+// weakAlgorithms returns the algorithms deprecated by the knowledge base
+// that the tenant's crypto implementations used as of at, by kind and name
+func weakAlgorithms(tx *sqlx.Tx, tenantID uuid.UUID, at time.Time) (map[string]*models.WeakAlgorithm, error) {
+	rows, err := tx.Query(`
+		SELECT asset_id, state->>'protocol', state->>'cipher_suite', state->>'key_exchange_algorithm',
+			state->>'signature_algorithm', state->>'symmetric_encryption', state->>'hash_algorithm', COUNT(*)
+		FROM `+liveStates(models.EntityCryptoImplementation, "$2")+` implementations
+		GROUP BY 1, 2, 3, 4, 5, 6, 7`,
+		tenantID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query algorithms: %w", err)
+	}
+	defer rows.Close()
+
+	weak := map[string]*models.WeakAlgorithm{}
+	assets := map[string]map[uuid.UUID]bool{}
+	for rows.Next() {
+		var assetID uuid.UUID
+		var count int
+		subject := &risk.Subject{}
+		if err := rows.Scan(&assetID, &subject.Protocol, &subject.CipherSuite, &subject.KeyExchangeAlgorithm,
+			&subject.SignatureAlgorithm, &subject.SymmetricEncryption, &subject.HashAlgorithm, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan algorithms: %w", err)
+		}
+		for _, kind := range weakAlgorithmKinds {
+			entry := subject.Algorithm(kind)
+			if entry == nil || !entry.Deprecated {
+				continue
+			}
+			key := entry.Kind + "/" + entry.Name
+			if weak[key] == nil {
+				weak[key] = &models.WeakAlgorithm{Kind: entry.Kind, Name: entry.Name}
+				assets[key] = map[uuid.UUID]bool{}
+			}
+			weak[key].Implementations += count
+			assets[key][assetID] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read algorithms: %w", err)
+	}
+	for key, algorithm := range weak {
+		algorithm.Assets = len(assets[key])
+	}
+	return weak, nil
+}
+
+// newWeakAlgorithms returns the weak algorithms in use at newer and not at
+// older, the most used first
+func newWeakAlgorithms(tx *sqlx.Tx, tenantID uuid.UUID, older, newer time.Time) ([]models.WeakAlgorithm, error) {
+	before, err := weakAlgorithms(tx, tenantID, older)
+	if err != nil {
+		return nil, err
+	}
+	after, err := weakAlgorithms(tx, tenantID, newer)
+	if err != nil {
+		return nil, err
+	}
+
+	added := []models.WeakAlgorithm{}
+	for key, algorithm := range after {
+		if before[key] == nil {
+			added = append(added, *algorithm)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool {
+		if added[i].Implementations != added[j].Implementations {
+			return added[i].Implementations > added[j].Implementations
+		}
+		if added[i].Kind != added[j].Kind {
+			return added[i].Kind < added[j].Kind
+		}
+		return added[i].Name < added[j].Name
+	})
+	return added, nil
+}
+
+// addedAssets returns the assets present at newer and not at older, as of
+// newer. With the times swapped it returns the removed assets.
+func addedAssets(tx *sqlx.Tx, tenantID uuid.UUID, older, newer time.Time) ([]models.DiffAsset, error) {
+	rows, err := tx.Query(`
+		SELECT n.entity_id, n.state->>'hostname', host((n.state->>'ip_address')::inet), (n.state->>'port')::int,
+			n.state->>'asset_type', n.state->>'environment', n.state->>'business_unit'
+		FROM `+liveStates(models.EntityAsset, "$3")+` n
+		LEFT JOIN `+liveStates(models.EntityAsset, "$2")+` o ON o.entity_id = n.entity_id
+		WHERE o.entity_id IS NULL
+		ORDER BY n.state->>'hostname', n.state->>'ip_address', n.entity_id`,
+		tenantID, older, newer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query asset changes: %w", err)
+	}
+	defer rows.Close()
+
+	assets := []models.DiffAsset{}
+	for rows.Next() {
+		var asset models.DiffAsset
+		if err := rows.Scan(&asset.ID, &asset.Hostname, &asset.IPAddress, &asset.Port, &asset.AssetType,
+			&asset.Environment, &asset.BusinessUnit); err != nil {
+			return nil, fmt.Errorf("failed to scan asset change: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read asset changes: %w", err)
+	}
+	return assets, nil
+}
+
+// diffImplementationColumns are the columns scanned by
+// scanDiffImplementations, from the states n
+const diffImplementationColumns = `n.entity_id, n.asset_id, (n.state->>'port')::int, n.state->>'protocol',
+	n.state->>'protocol_version', n.state->>'cipher_suite', COALESCE((n.state->>'risk_score')::int, 0)`
+
+// addedImplementations returns the crypto implementations present at newer
+// and not at older, as of newer. With the times swapped it returns the
+// removed implementations.
+func addedImplementations(tx *sqlx.Tx, tenantID uuid.UUID, older, newer time.Time) ([]models.DiffImplementation, error) {
+	rows, err := tx.Query(`
+		SELECT `+diffImplementationColumns+`, NULL::int
+		FROM `+liveStates(models.EntityCryptoImplementation, "$3")+` n
+		LEFT JOIN `+liveStates(models.EntityCryptoImplementation, "$2")+` o ON o.entity_id = n.entity_id
+		WHERE o.entity_id IS NULL
+		ORDER BY n.asset_id, (n.state->>'port')::int, n.state->>'protocol', n.entity_id`,
+		tenantID, older, newer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query crypto implementation changes: %w", err)
+	}
+	return scanDiffImplementations(rows)
+}
+
+// riskChanges fills in the crypto implementations present at older and
+// newer whose risk score went up or down, the largest change first
+func riskChanges(tx *sqlx.Tx, tenantID uuid.UUID, older, newer time.Time, diff *models.ImplementationDiff) error {
+	rows, err := tx.Query(`
+		SELECT `+diffImplementationColumns+`, COALESCE((o.state->>'risk_score')::int, 0)
+		FROM `+liveStates(models.EntityCryptoImplementation, "$3")+` n
+		JOIN `+liveStates(models.EntityCryptoImplementation, "$2")+` o ON o.entity_id = n.entity_id
+		WHERE COALESCE((n.state->>'risk_score')::int, 0) <> COALESCE((o.state->>'risk_score')::int, 0)
+		ORDER BY ABS(COALESCE((n.state->>'risk_score')::int, 0) - COALESCE((o.state->>'risk_score')::int, 0)) DESC,
+			n.entity_id`,
+		tenantID, older, newer)
+	if err != nil {
+		return fmt.Errorf("failed to query risk changes: %w", err)
+	}
+	implementations, err := scanDiffImplementations(rows)
+	if err != nil {
+		return err
+	}
+
+	diff.RiskIncreased = []models.DiffImplementation{}
+	diff.RiskDecreased = []models.DiffImplementation{}
+	for _, impl := range implementations {
+		if impl.RiskScore > *impl.PreviousRiskScore {
+			diff.RiskIncreased = append(diff.RiskIncreased, impl)
+		} else {
+			diff.RiskDecreased = append(diff.RiskDecreased, impl)
+		}
+	}
+	return nil
+}
+
+// scanDiffImplementations reads and closes rows of
+// diffImplementationColumns and a previous risk score
+func scanDiffImplementations(rows *sql.Rows) ([]models.DiffImplementation, error) {
+	defer rows.Close()
+
+	implementations := []models.DiffImplementation{}
+	for rows.Next() {
+		var impl models.DiffImplementation
+		if err := rows.Scan(&impl.ID, &impl.AssetID, &impl.Port, &impl.Protocol, &impl.ProtocolVersion,
+			&impl.CipherSuite, &impl.RiskScore, &impl.PreviousRiskScore); err != nil {
+			return nil, fmt.Errorf("failed to scan crypto implementation change: %w", err)
+		}
+		implementations = append(implementations, impl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read crypto implementation changes: %w", err)
+	}
+	return implementations, nil
+}
+
+// diffCertificateColumns are the columns scanned by scanDiffCertificates,
+// from the states n
+const diffCertificateColumns = `n.entity_id, n.state->>'common_name', n.state->>'subject_dn',
+	n.state->>'issuer_dn', (n.state->>'not_after')::timestamptz, n.state->>'fingerprint_sha256'`
+
+// addedCertificates returns the certificates present at newer and not at
+// older
+func addedCertificates(tx *sqlx.Tx, tenantID uuid.UUID, older, newer time.Time) ([]models.DiffCertificate, error) {
+	rows, err := tx.Query(`
+		SELECT `+diffCertificateColumns+`
+		FROM `+liveStates(models.EntityCertificate, "$3")+` n
+		LEFT JOIN `+liveStates(models.EntityCertificate, "$2")+` o ON o.entity_id = n.entity_id
+		WHERE o.entity_id IS NULL
+		ORDER BY (n.state->>'not_after')::timestamptz, n.entity_id`,
+		tenantID, older, newer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificate changes: %w", err)
+	}
+	return scanDiffCertificates(rows)
+}
+
+// expiredCertificates returns the certificates present at newer that
+// expired after older and by newer
+func expiredCertificates(tx *sqlx.Tx, tenantID uuid.UUID, older, newer time.Time) ([]models.DiffCertificate, error) {
+	rows, err := tx.Query(`
+		SELECT `+diffCertificateColumns+`
+		FROM `+liveStates(models.EntityCertificate, "$3")+` n
+		WHERE (n.state->>'not_after')::timestamptz > $2 AND (n.state->>'not_after')::timestamptz <= $3
+		ORDER BY (n.state->>'not_after')::timestamptz, n.entity_id`,
+		tenantID, older, newer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired certificates: %w", err)
+	}
+	return scanDiffCertificates(rows)
+}
+
+// scanDiffCertificates reads and closes rows of diffCertificateColumns
+func scanDiffCertificates(rows *sql.Rows) ([]models.DiffCertificate, error) {
+	defer rows.Close()
+
+	certificates := []models.DiffCertificate{}
+	for rows.Next() {
+		var certificate models.DiffCertificate
+		if err := rows.Scan(&certificate.ID, &certificate.CommonName, &certificate.SubjectDN, &certificate.IssuerDN,
+			&certificate.NotAfter, &certificate.FingerprintSHA256); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate change: %w", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read certificate changes: %w", err)
+	}
+	return certificates, nil
+}
+
+// snapshotScanner is a row of snapshot columns
+type snapshotScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSnapshot(row snapshotScanner) (*models.InventorySnapshot, error) {
+	var snapshot models.InventorySnapshot
+	var summary string
+	if err := row.Scan(&snapshot.ID, &snapshot.Name, &snapshot.Description, &snapshot.TakenAt, &summary,
+		&snapshot.CreatedBy, &snapshot.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+	}
+	if err := json.Unmarshal([]byte(summary), &snapshot.Summary); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot summary: %w", err)
+	}
+	return &snapshot, nil
+}
@@ -25,8 +25,20 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Sensor manager providing sensors and network topology
+	sensorManagerURL := os.Getenv("SENSOR_MANAGER_URL")
+	if sensorManagerURL == "" {
+		sensorManagerURL = "http://sensor-manager:8080"
+	}
+
+	// Inventory service providing the certificate inventory
+	inventoryURL := os.Getenv("INVENTORY_SERVICE_URL")
+	if inventoryURL == "" {
+		inventoryURL = "http://inventory-service:8082"
+	}
+
 	// Initialize handlers for report generation and management
-	handler := handlers.NewHandler()
+	handler := handlers.NewHandler(sensorManagerURL, inventoryURL)
 
 	// Initialize Gin router with default middleware
 	router := gin.Default()
@@ -60,7 +72,11 @@ func main() {
 		// These provide sample data for demonstration purposes
 		api.GET("/reports/demo/crypto-summary", handler.GetCryptoSummary)
 		api.GET("/reports/demo/compliance-status", handler.GetComplianceStatus)
-		api.GET("/reports/demo/network-topology", handler.GetNetworkTopology)
+
+		// Network topology from the sensor manager, for the caller's tenant
+		api.GET("/reports/network-topology", handler.GetNetworkTopology)
+		api.GET("/reports/demo/network-topology", handler.GetNetworkTopology) // Kept for existing clients
+		api.GET("/reports/certificate-audit", handler.GetCertificateAudit)
 	}
 
 	// Setup HTTP server with configuration
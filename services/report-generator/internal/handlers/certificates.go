@@ -0,0 +1,260 @@
+// Package handlers provides HTTP handlers for the report generator service.
+// This file contains the certificate audit data, fetched from the
+// certificate inventory of the inventory service.
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultAuditExpiryDays is the expiry horizon of the certificate audit
+	defaultAuditExpiryDays = 90
+	// maxAuditCertificates is how many certificates each audit section lists
+	maxAuditCertificates = 500
+)
+
+// GetCertificateAudit returns the certificate audit of the caller's tenant:
+// certificate counts, and the certificates expiring within ?days= (default
+// 90), expired, self-signed or with weak keys.
+func (h *Handler) GetCertificateAudit(c *gin.Context) {
+	parameters := map[string]interface{}{}
+	if days := c.Query("days"); days != "" {
+		parameters["days"] = days
+	}
+
+	data, err := h.certificateAuditData(c.Request.Context(), c.GetHeader("Authorization"), parameters)
+	if err != nil {
+		log.Printf("❌ Failed to fetch certificate audit: %v", err)
+		c.JSON(statusCode(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, data)
+}
+
+// certificateAuditData builds the certificate audit report from the
+// inventory service. Numbers are float64 as in any decoded JSON, which is
+// what the formatters expect.
+func (h *Handler) certificateAuditData(ctx context.Context, authorization string, parameters map[string]interface{}) (map[string]interface{}, error) {
+	days := fmt.Sprint(defaultAuditExpiryDays)
+	if value, ok := parameters["days"]; ok {
+		days = fmt.Sprint(value)
+	}
+
+	var summary struct {
+		Summary map[string]interface{} `json:"summary"`
+	}
+	if err := h.inventory.get(ctx, authorization, "/certificates/summary", nil, &summary); err != nil {
+		return nil, err
+	}
+
+	sections := []struct {
+		name  string
+		query url.Values
+	}{
+		{"expiring", url.Values{"expiring_within_days": {days}, "sort_by": {"not_after"}}},
+		{"expired", url.Values{"expired": {"true"}, "sort_by": {"endpoint_count"}, "sort_order": {"desc"}}},
+		{"self_signed", url.Values{"self_signed": {"true"}, "ca": {"false"}, "sort_by": {"endpoint_count"}, "sort_order": {"desc"}}},
+		{"weak_keys", url.Values{"key_algorithm": {"RSA", "DSA"}, "max_key_size": {"2047"}, "sort_by": {"public_key_size"}}},
+	}
+	data := map[string]interface{}{
+		"summary":     summary.Summary,
+		"expiry_days": days,
+	}
+	pages := make(map[string][]map[string]interface{}, len(sections))
+	for _, section := range sections {
+		section.query.Set("page_size", fmt.Sprint(maxAuditCertificates))
+		var page struct {
+			Certificates []map[string]interface{} `json:"certificates"`
+		}
+		if err := h.inventory.get(ctx, authorization, "/certificates", section.query, &page); err != nil {
+			return nil, err
+		}
+		pages[section.name] = page.Certificates
+	}
+
+	// Judge signature algorithms with the inventory's algorithm knowledge
+	// base, whatever library spelled them
+	bySignature, _ := summary.Summary["by_signature_algorithm"].(map[string]interface{})
+	seen := make(map[string]bool, len(bySignature))
+	names := make([]string, 0, len(bySignature))
+	for name := range bySignature {
+		seen[name] = true
+		names = append(names, name)
+	}
+	for _, certificates := range pages {
+		for _, certificate := range certificates {
+			if name, ok := certificate["signature_algorithm"].(string); ok && name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	var resolved struct {
+		Algorithms map[string]*signatureAlgorithm `json:"algorithms"`
+	}
+	if len(names) > 0 {
+		request := map[string]interface{}{"names": names, "kinds": []string{"signature_scheme"}}
+		if err := h.inventory.post(ctx, authorization, "/algorithms/resolve", request, &resolved); err != nil {
+			return nil, err
+		}
+	}
+
+	quantumVulnerable := 0.0
+	for name, count := range bySignature {
+		if algorithm := resolved.Algorithms[name]; algorithm != nil && algorithm.QuantumVulnerable {
+			if n, ok := count.(float64); ok {
+				quantumVulnerable += n
+			}
+		}
+	}
+	data["quantum_vulnerable_signatures"] = quantumVulnerable
+
+	for _, section := range sections {
+		certificates := make([]interface{}, 0, len(pages[section.name]))
+		for _, certificate := range pages[section.name] {
+			certificates = append(certificates, auditCertificate(certificate, resolved.Algorithms))
+		}
+		data[section.name] = certificates
+	}
+	return data, nil
+}
+
+// signatureAlgorithm is what the algorithm knowledge base says of a
+// certificate signature algorithm
+type signatureAlgorithm struct {
+	Name              string `json:"name"`
+	StrengthBits      int    `json:"strength_bits"`
+	Deprecated        bool   `json:"deprecated"`
+	QuantumVulnerable bool   `json:"quantum_vulnerable"`
+}
+
+// auditCertificate keeps the fields of a certificate the audit reports on,
+// with what the knowledge base says of its signature algorithm when it
+// knows it
+func auditCertificate(certificate map[string]interface{}, signatures map[string]*signatureAlgorithm) map[string]interface{} {
+	name, _ := certificate["common_name"].(string)
+	if name == "" {
+		name, _ = certificate["subject_dn"].(string)
+	}
+	key, _ := certificate["public_key_algorithm"].(string)
+	if size, ok := certificate["public_key_size"].(float64); ok {
+		key = fmt.Sprintf("%s-%.0f", key, size)
+	}
+	audited := map[string]interface{}{
+		"id":                  certificate["id"],
+		"name":                name,
+		"issuer":              certificate["issuer_dn"],
+		"not_after":           certificate["not_after"],
+		"days_until_expiry":   certificate["days_until_expiry"],
+		"status":              certificate["status"],
+		"key":                 key,
+		"signature_algorithm": certificate["signature_algorithm"],
+		"endpoints":           certificate["endpoint_count"],
+		"fingerprint_sha256":  certificate["fingerprint_sha256"],
+	}
+	if signature, _ := certificate["signature_algorithm"].(string); signatures[signature] != nil {
+		algorithm := signatures[signature]
+		audited["signature_strength_bits"] = float64(algorithm.StrengthBits)
+		audited["signature_deprecated"] = algorithm.Deprecated
+		audited["quantum_vulnerable"] = algorithm.QuantumVulnerable
+	}
+	return audited
+}
+
+// certificateAuditSections are the certificate lists of the audit, in
+// report order
+var certificateAuditSections = []struct {
+	key   string
+	title string
+}{
+	{"expired", "EXPIRED CERTIFICATES"},
+	{"expiring", "EXPIRING CERTIFICATES"},
+	{"weak_keys", "WEAK KEYS"},
+	{"self_signed", "SELF-SIGNED CERTIFICATES"},
+}
+
+// formatCertificateAuditAsText formats certificate audit data as text
+func (h *Handler) formatCertificateAuditAsText(data interface{}) string {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		return "Invalid data format\n"
+	}
+
+	content := "CERTIFICATE AUDIT REPORT\n"
+	content += strings.Repeat("-", 30) + "\n\n"
+
+	if summary, ok := dataMap["summary"].(map[string]interface{}); ok {
+		content += "SUMMARY:\n"
+		content += fmt.Sprintf("  Total Certificates: %.0f\n", summary["total"])
+		content += fmt.Sprintf("  Expired: %.0f (%.0f still in use)\n", summary["expired"], summary["expired_in_use"])
+		content += fmt.Sprintf("  Expiring within 30 Days: %.0f\n", summary["expiring_within_30_days"])
+		content += fmt.Sprintf("  Expiring within 90 Days: %.0f\n", summary["expiring_within_90_days"])
+		content += fmt.Sprintf("  Self-Signed: %.0f\n", summary["self_signed"])
+		content += fmt.Sprintf("  Weak Keys: %.0f\n", summary["weak_keys"])
+		content += fmt.Sprintf("  Weak Signatures: %.0f\n", summary["weak_signatures"])
+		content += fmt.Sprintf("  Quantum-Vulnerable Signatures: %.0f\n", dataMap["quantum_vulnerable_signatures"])
+		content += "\n"
+	}
+
+	for _, section := range certificateAuditSections {
+		certificates, ok := dataMap[section.key].([]interface{})
+		if !ok || len(certificates) == 0 {
+			continue
+		}
+		content += section.title + ":\n"
+		for _, entry := range certificates {
+			if certificate, ok := entry.(map[string]interface{}); ok {
+				content += fmt.Sprintf("  %s\n", certificate["name"])
+				content += fmt.Sprintf("    Issuer: %s\n", certificate["issuer"])
+				content += fmt.Sprintf("    Expires: %s (%s)\n", certificate["not_after"], certificate["status"])
+				signature := fmt.Sprint(certificate["signature_algorithm"])
+				if deprecated, _ := certificate["signature_deprecated"].(bool); deprecated {
+					signature += " (deprecated)"
+				}
+				content += fmt.Sprintf("    Key: %s, Signature: %s\n", certificate["key"], signature)
+				content += fmt.Sprintf("    Endpoints: %.0f\n", certificate["endpoints"])
+			}
+		}
+		content += "\n"
+	}
+
+	return content
+}
+
+// formatCertificateAuditAsCSV formats certificate audit data as CSV
+func (h *Handler) formatCertificateAuditAsCSV(data interface{}) string {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		return "Invalid data format\n"
+	}
+
+	var content strings.Builder
+	content.WriteString("Finding,Certificate,Issuer,Expires,Status,Key,Signature Algorithm,Endpoints,SHA-256 Fingerprint\n")
+	for _, section := range certificateAuditSections {
+		certificates, _ := dataMap[section.key].([]interface{})
+		for _, entry := range certificates {
+			if certificate, ok := entry.(map[string]interface{}); ok {
+				content.WriteString(fmt.Sprintf("%s,%s,%s,%v,%v,%v,%v,%.0f,%v\n",
+					section.key,
+					csvField(fmt.Sprint(certificate["name"])),
+					csvField(fmt.Sprint(certificate["issuer"])),
+					certificate["not_after"], certificate["status"], certificate["key"],
+					certificate["signature_algorithm"], certificate["endpoints"], certificate["fingerprint_sha256"]))
+			}
+		}
+	}
+
+	return content.String()
+}
+
+// csvField quotes a CSV field; distinguished names contain commas
+func csvField(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
@@ -0,0 +1,115 @@
+// Package handlers provides HTTP handlers for the report generator service.
+// This file contains the client used to read report data from the other
+// services of the platform.
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// serviceTimeout bounds each request to another service
+const serviceTimeout = 30 * time.Second
+
+// serviceClient reads a tenant's data from another service of the platform,
+// authenticated as the caller of the report generator
+type serviceClient struct {
+	name       string
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newServiceClient(name, baseURL string) *serviceClient {
+	return &serviceClient{
+		name:       name,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: serviceTimeout},
+	}
+}
+
+// get fetches an API path of the service and decodes the JSON response
+// into out
+func (s *serviceClient) get(ctx context.Context, authorization, path string, query url.Values, out interface{}) error {
+	endpoint := s.baseURL + "/api/v1" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return s.do(req, authorization, out)
+}
+
+// post sends body as JSON to an API path of the service that only reads
+// data, such as a batch lookup, and decodes the JSON response into out
+func (s *serviceClient) post(ctx context.Context, authorization, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/api/v1"+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return s.do(req, authorization, out)
+}
+
+func (s *serviceClient) do(req *http.Request, authorization string, out interface{}) error {
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s unreachable: %w", s.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		var apiError struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiError) == nil && apiError.Error != "" {
+			return &serviceError{service: s.name, status: resp.StatusCode, message: apiError.Error}
+		}
+		return &serviceError{service: s.name, status: resp.StatusCode, message: strings.TrimSpace(string(body))}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", s.name, err)
+	}
+	return nil
+}
+
+// serviceError is an error response of another service
+type serviceError struct {
+	service string
+	status  int
+	message string
+}
+
+func (e *serviceError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.service, e.status, e.message)
+}
+
+// statusCode returns the status the report generator answers with when
+// fetching from another service failed: authentication and validation
+// errors are passed on, anything else is a bad gateway
+func statusCode(err error) int {
+	if apiErr, ok := err.(*serviceError); ok {
+		switch apiErr.status {
+		case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+			return apiErr.status
+		}
+	}
+	return http.StatusBadGateway
+}
@@ -15,6 +15,12 @@ type Handler struct {
 	// In-memory storage for demo purposes
 	// In production, this would be replaced with database operations
 	reports map[string]*Report
+
+	// sensorManager provides the sensors and network topology
+	sensorManager *serviceClient
+
+	// inventory provides the certificate inventory
+	inventory *serviceClient
 }
 
 // Report represents a generated report with metadata and content.
@@ -41,10 +47,14 @@ type ReportTemplate struct {
 }
 
 // NewHandler creates a new handler instance with initialized storage.
-// This function sets up the handler with empty in-memory storage for demo purposes.
-func NewHandler() *Handler {
+// This function sets up the handler with empty in-memory storage for demo purposes
+// and reads sensor and topology data from the sensor manager at sensorManagerURL
+// and certificates from the inventory service at inventoryURL.
+func NewHandler(sensorManagerURL, inventoryURL string) *Handler {
 	return &Handler{
-		reports: make(map[string]*Report),
+		reports:       make(map[string]*Report),
+		sensorManager: newServiceClient("sensor manager", sensorManagerURL),
+		inventory:     newServiceClient("inventory service", inventoryURL),
 	}
 }
 
@@ -3,7 +3,9 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -55,8 +57,9 @@ func (h *Handler) GenerateReport(c *gin.Context) {
 	h.reports[reportID] = report
 
 	// Start asynchronous report generation
-	// In production, this would queue the job in a message queue system
-	go h.generateReportAsync(reportID, req.Type, req.Format)
+	// In production, this would queue the job in a message queue system.
+	// Reports built from other services fetch as the caller.
+	go h.generateReportAsync(reportID, req.Type, req.Format, c.GetHeader("Authorization"), req.Parameters)
 
 	// Return 202 Accepted with report ID for tracking
 	c.JSON(202, gin.H{
@@ -70,7 +73,7 @@ func (h *Handler) GenerateReport(c *gin.Context) {
 // In production, this would be handled by a background job processor
 // that queries the database for crypto inventory data and generates
 // the appropriate report content.
-func (h *Handler) generateReportAsync(reportID, reportType, format string) {
+func (h *Handler) generateReportAsync(reportID, reportType, format, authorization string, parameters map[string]interface{}) {
 	// Simulate processing time for realistic demo experience
 	// In production, this would be the actual report generation time
 	time.Sleep(2 * time.Second)
@@ -90,9 +93,33 @@ func (h *Handler) generateReportAsync(reportID, reportType, format string) {
 	case "compliance_status":
 		data = h.generateComplianceStatusData()
 	case "network_topology":
-		data = h.generateNetworkTopologyData()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*serviceTimeout)
+		topology, err := h.networkTopologyData(ctx, authorization, parameters)
+		cancel()
+		if err != nil {
+			log.Printf("❌ Failed to generate network topology report %s: %v", reportID, err)
+			now := time.Now()
+			report.Status = "failed"
+			report.CompletedAt = &now
+			report.Data = map[string]interface{}{"error": err.Error()}
+			return
+		}
+		data = topology
 	case "risk_assessment":
 		data = h.generateRiskAssessmentData()
+	case "certificate_audit":
+		ctx, cancel := context.WithTimeout(context.Background(), 3*serviceTimeout)
+		audit, err := h.certificateAuditData(ctx, authorization, parameters)
+		cancel()
+		if err != nil {
+			log.Printf("❌ Failed to generate certificate audit report %s: %v", reportID, err)
+			now := time.Now()
+			report.Status = "failed"
+			report.CompletedAt = &now
+			report.Data = map[string]interface{}{"error": err.Error()}
+			return
+		}
+		data = audit
 	default:
 		// Fallback for unknown report types
 		data = map[string]interface{}{
@@ -240,6 +267,8 @@ func (h *Handler) formatReportAsText(report *Report) string {
 		content += h.formatNetworkTopologyAsText(report.Data)
 	case "risk_assessment":
 		content += h.formatRiskAssessmentAsText(report.Data)
+	case "certificate_audit":
+		content += h.formatCertificateAuditAsText(report.Data)
 	default:
 		content += "Report data:\n"
 		content += fmt.Sprintf("%+v\n", report.Data)
@@ -265,6 +294,8 @@ func (h *Handler) formatReportAsCSV(report *Report) string {
 		content.WriteString(h.formatNetworkTopologyAsCSV(report.Data))
 	case "risk_assessment":
 		content.WriteString(h.formatRiskAssessmentAsCSV(report.Data))
+	case "certificate_audit":
+		content.WriteString(h.formatCertificateAuditAsCSV(report.Data))
 	default:
 		content.WriteString("Data\n")
 		content.WriteString(fmt.Sprintf("%+v\n", report.Data))
@@ -338,11 +369,24 @@ func (h *Handler) GetComplianceStatus(c *gin.Context) {
 	c.JSON(200, data)
 }
 
-// GetNetworkTopology returns network topology data for demo purposes.
-// This endpoint provides sample sensor and network coverage data
-// for demonstrating the network monitoring capabilities.
+// GetNetworkTopology returns the network topology of the caller's tenant:
+// its sensors, the coverage of the subnets holding known assets and the
+// topology graph, as observed by sensors over ?days= (default 7).
 func (h *Handler) GetNetworkTopology(c *gin.Context) {
-	data := h.generateNetworkTopologyData()
+	parameters := map[string]interface{}{}
+	if days := c.Query("days"); days != "" {
+		parameters["days"] = days
+	}
+	if sensorID := c.Query("sensor_id"); sensorID != "" {
+		parameters["sensor_id"] = sensorID
+	}
+
+	data, err := h.networkTopologyData(c.Request.Context(), c.GetHeader("Authorization"), parameters)
+	if err != nil {
+		log.Printf("❌ Failed to fetch network topology: %v", err)
+		c.JSON(statusCode(err), gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(200, data)
 }
 
@@ -442,55 +486,6 @@ func (h *Handler) generateComplianceStatusData() map[string]interface{} {
 	}
 }
 
-// generateNetworkTopologyData creates sample network topology data.
-// This includes sensor information, network coverage, and discovery statistics
-// that would be collected from deployed network sensors.
-func (h *Handler) generateNetworkTopologyData() map[string]interface{} {
-	return map[string]interface{}{
-		"sensors": []map[string]interface{}{
-			{
-				"id":          "sensor-dc01",
-				"name":        "Datacenter Core",
-				"status":      "active",
-				"location":    "Primary DC - Rack 1",
-				"interfaces":  []string{"eth0", "eth1"},
-				"discoveries": 456,
-				"last_seen":   "2024-12-15T10:30:00Z",
-			},
-			{
-				"id":          "sensor-dc02",
-				"name":        "Datacenter Edge",
-				"status":      "active",
-				"location":    "Primary DC - Rack 2",
-				"interfaces":  []string{"eth0"},
-				"discoveries": 234,
-				"last_seen":   "2024-12-15T10:29:45Z",
-			},
-			{
-				"id":          "sensor-cloud01",
-				"name":        "Cloud Instance",
-				"status":      "active",
-				"location":    "AWS us-east-1",
-				"interfaces":  []string{"ens3"},
-				"discoveries": 123,
-				"last_seen":   "2024-12-15T10:28:30Z",
-			},
-		},
-		"coverage": map[string]interface{}{
-			"total_networks":      12,
-			"monitored_networks":  10,
-			"coverage_percentage": 83.3,
-			"uncovered_networks":  []string{"192.168.100.0/24", "10.0.50.0/24"},
-		},
-		"discoveries_by_network": map[string]interface{}{
-			"192.168.1.0/24": 456,
-			"192.168.2.0/24": 234,
-			"10.0.1.0/24":    123,
-			"172.16.1.0/24":  89,
-		},
-	}
-}
-
 // generateRiskAssessmentData creates sample risk assessment data.
 // This includes overall risk scores, critical findings, and prioritized
 // recommendations based on security analysis of the crypto inventory.
@@ -686,6 +681,12 @@ func (h *Handler) formatNetworkTopologyAsText(data interface{}) string {
 		content += fmt.Sprintf("  Total Networks: %.0f\n", coverage["total_networks"])
 		content += fmt.Sprintf("  Monitored Networks: %.0f\n", coverage["monitored_networks"])
 		content += fmt.Sprintf("  Coverage Percentage: %.1f%%\n", coverage["coverage_percentage"])
+		if uncovered, ok := coverage["uncovered_networks"].([]interface{}); ok && len(uncovered) > 0 {
+			content += "  Uncovered Networks:\n"
+			for _, network := range uncovered {
+				content += fmt.Sprintf("    %s\n", network)
+			}
+		}
 		content += "\n"
 	}
 
@@ -697,7 +698,7 @@ func (h *Handler) formatNetworkTopologyAsText(data interface{}) string {
 				content += fmt.Sprintf("  %s (%s)\n", sensorMap["name"], sensorMap["id"])
 				content += fmt.Sprintf("    Status: %s\n", sensorMap["status"])
 				content += fmt.Sprintf("    Location: %s\n", sensorMap["location"])
-				content += fmt.Sprintf("    Discoveries: %.0f\n", sensorMap["discoveries"])
+				content += fmt.Sprintf("    Subnets Seen: %.0f\n", sensorMap["subnets"])
 				content += fmt.Sprintf("    Last Seen: %s\n", sensorMap["last_seen"])
 				content += "\n"
 			}
@@ -715,14 +716,14 @@ func (h *Handler) formatNetworkTopologyAsCSV(data interface{}) string {
 	}
 
 	var content strings.Builder
-	content.WriteString("Sensor ID,Name,Status,Location,Discoveries,Last Seen\n")
+	content.WriteString("Sensor ID,Name,Status,Location,Subnets Seen,Last Seen\n")
 
 	if sensors, ok := dataMap["sensors"].([]interface{}); ok {
 		for _, sensor := range sensors {
 			if sensorMap, ok := sensor.(map[string]interface{}); ok {
 				content.WriteString(fmt.Sprintf("%s,%s,%s,%s,%.0f,%s\n",
 					sensorMap["id"], sensorMap["name"], sensorMap["status"],
-					sensorMap["location"], sensorMap["discoveries"], sensorMap["last_seen"]))
+					sensorMap["location"], sensorMap["subnets"], sensorMap["last_seen"]))
 			}
 		}
 	}
@@ -0,0 +1,112 @@
+// Package handlers provides HTTP handlers for the report generator service.
+// This file contains the network topology data, fetched from the sensor
+// manager's coverage model and topology graph.
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// maxTopologySensors is how many sensors the topology report lists
+const maxTopologySensors = 500
+
+// networkTopologyData builds the network topology report from the sensor
+// manager: the tenant's sensors with the subnets they see, the coverage of
+// the subnets holding known assets and the topology graph. Numbers are
+// float64 as in any decoded JSON, which is what the formatters expect.
+func (h *Handler) networkTopologyData(ctx context.Context, authorization string, parameters map[string]interface{}) (map[string]interface{}, error) {
+	query := url.Values{}
+	if days, ok := parameters["days"]; ok {
+		query.Set("days", fmt.Sprint(days))
+	}
+	if sensorID, ok := parameters["sensor_id"].(string); ok && sensorID != "" {
+		query.Set("sensor_id", sensorID)
+	}
+
+	var coverage map[string]interface{}
+	if err := h.sensorManager.get(ctx, authorization, "/admin/topology/coverage", query, &coverage); err != nil {
+		return nil, err
+	}
+	var graph map[string]interface{}
+	if err := h.sensorManager.get(ctx, authorization, "/admin/topology/graph", query, &graph); err != nil {
+		return nil, err
+	}
+	var fleet struct {
+		Sensors []map[string]interface{} `json:"sensors"`
+	}
+	sensorQuery := url.Values{"page_size": {fmt.Sprint(maxTopologySensors)}}
+	if err := h.sensorManager.get(ctx, authorization, "/admin/sensors", sensorQuery, &fleet); err != nil {
+		return nil, err
+	}
+
+	// Subnets seen per sensor, from the coverage model
+	sensorSubnets := make(map[string][]string)
+	subnets, _ := coverage["subnets"].([]interface{})
+	for _, entry := range subnets {
+		subnet, _ := entry.(map[string]interface{})
+		cidr, _ := subnet["cidr"].(string)
+		seenBy, _ := subnet["sensors"].([]interface{})
+		for _, s := range seenBy {
+			if sensor, ok := s.(map[string]interface{}); ok {
+				id, _ := sensor["id"].(string)
+				sensorSubnets[id] = append(sensorSubnets[id], cidr)
+			}
+		}
+	}
+
+	sensors := make([]interface{}, 0, len(fleet.Sensors))
+	for _, sensor := range fleet.Sensors {
+		id, _ := sensor["id"].(string)
+		if wanted := query.Get("sensor_id"); wanted != "" && id != wanted {
+			continue
+		}
+		location, _ := sensor["hostname"].(string)
+		if location == "" {
+			location, _ = sensor["ip_address"].(string)
+		}
+		lastSeen, _ := sensor["last_heartbeat_at"].(string)
+		if lastSeen == "" {
+			lastSeen = "never"
+		}
+		seen := sensorSubnets[id]
+		sort.Strings(seen)
+		sensors = append(sensors, map[string]interface{}{
+			"id":           id,
+			"name":         sensor["name"],
+			"status":       sensor["status"],
+			"health_state": sensor["health_state"],
+			"location":     location,
+			"interfaces":   sensor["network_interfaces"],
+			"subnets":      float64(len(seen)),
+			"networks":     seen,
+			"last_seen":    lastSeen,
+		})
+	}
+
+	summary, _ := coverage["summary"].(map[string]interface{})
+	uncoveredNetworks := []interface{}{}
+	uncovered, _ := coverage["uncovered"].([]interface{})
+	for _, entry := range uncovered {
+		if subnet, ok := entry.(map[string]interface{}); ok {
+			uncoveredNetworks = append(uncoveredNetworks, subnet["cidr"])
+		}
+	}
+
+	return map[string]interface{}{
+		"sensors": sensors,
+		"coverage": map[string]interface{}{
+			"total_networks":      summary["asset_subnets"],
+			"monitored_networks":  summary["covered_subnets"],
+			"observed_networks":   summary["observed_subnets"],
+			"coverage_percentage": summary["coverage_percentage"],
+			"uncovered_networks":  uncoveredNetworks,
+		},
+		"networks":  coverage["subnets"],
+		"uncovered": coverage["uncovered"],
+		"graph":     graph,
+		"since":     coverage["since"],
+	}, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
 	"net/http"
 	"os"
@@ -9,59 +10,296 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/broker"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/captures"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/certexpiry"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/commands"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/config"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/database"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/fleethealth"
 	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/handlers"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/ingest"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/netpolicy"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/pki"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/releases"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/rollouts"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/sensorconfig"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/webhooks"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// Load configuration
+	cfg := config.Load()
+
 	// Set Gin mode
-	if os.Getenv("ENV") == "production" {
+	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Initialize database
+	db, err := database.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	repo := repository.New(db)
+
+	if cfg.Environment == "production" && cfg.JWTSecret == "dev-secret-key-change-in-production" {
+		log.Fatal("JWT_SECRET must be set in production")
+	}
+
+	// Initialize the internal sensor CA
+	if cfg.Environment == "production" && cfg.CAEncryptionKey == "dev-sensor-ca-key-change-in-production" {
+		log.Fatal("CA_ENCRYPTION_KEY must be set in production")
+	}
+	caKey, err := pki.DeriveEncryptionKey(cfg.CAEncryptionKey)
+	if err != nil {
+		log.Fatalf("Invalid CA encryption key: %v", err)
+	}
+	caManager, err := pki.NewManager(repo, pki.Options{
+		EncryptionKey:       caKey,
+		KeyAlgorithm:        cfg.CAKeyAlgorithm,
+		CertificateValidity: cfg.SensorCertificateTTL,
+		PublicURL:           cfg.ControlPlaneURL,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize sensor CA: %v", err)
+	}
+
+	// Initialize the command queue and its expiry worker
+	commandService := commands.NewService(repo, broker.NewCommandBroker())
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go commandService.Run(workerCtx)
+
+	// Initialize discovery ingestion into the inventory
+	ingestService := ingest.NewService(repo)
+	go ingestService.Run(workerCtx)
+
+	// Initialize sensor configuration profiles, pushed via the command queue
+	configService := sensorconfig.NewService(repo, commandService, cfg.ControlPlaneURL)
+
+	// Initialize webhook delivery of tenant events
+	webhookNetworks, err := netpolicy.ParseNetworks(cfg.WebhookAllowedNetworks)
+	if err != nil {
+		log.Fatalf("Invalid WEBHOOK_ALLOWED_NETWORKS: %v", err)
+	}
+	dispatcher := webhooks.NewDispatcher(repo, webhookNetworks)
+	go dispatcher.Run(workerCtx)
+
+	// Initialize fleet health evaluation, alerting through tenant webhooks
+	healthService := fleethealth.NewService(repo, dispatcher, fleethealth.Thresholds{
+		DegradedMissed:  cfg.HealthDegradedMissed,
+		OfflineMissed:   cfg.HealthOfflineMissed,
+		MaxDropRate:     cfg.HealthMaxDropRate,
+		MaxBacklog:      cfg.HealthMaxBacklog,
+		MaxStorageUsage: cfg.HealthMaxStorageUsage,
+	}, cfg.HealthEvalInterval)
+	go healthService.Run(workerCtx)
+
+	// Initialize the certificate expiry reminders
+	expiryService := certexpiry.NewService(repo, dispatcher, cfg.CertExpiryThresholds, cfg.CertExpiryScanInterval)
+	go expiryService.Run(workerCtx)
+
+	// Initialize the sensor release catalog and staged rollouts of releases
+	catalog, err := releases.NewCatalog(repo, cfg.ReleaseStoragePath, cfg.ReleaseSigningPublicKeys)
+	if err != nil {
+		log.Fatalf("Failed to initialize release catalog: %v", err)
+	}
+	rolloutService := rollouts.NewService(repo, commandService, dispatcher)
+	go rolloutService.Run(workerCtx)
+
+	// Initialize diagnostic packet captures and the purge of expired ones
+	captureService, err := captures.NewService(repo, commandService, cfg.CaptureStoragePath, cfg.CapturePrivateKey,
+		cfg.CaptureRetention)
+	if err != nil {
+		log.Fatalf("Failed to initialize diagnostic captures: %v", err)
+	}
+	go captureService.Run(workerCtx)
+
 	// Initialize handlers
-	handler := handlers.NewHandler()
+	handler := handlers.NewHandler(cfg, repo, caManager, commandService, ingestService, configService, dispatcher,
+		catalog, rolloutService, captureService)
+
+	// Trusting every address would let any client choose its own IP
+	trustedProxies, err := netpolicy.ParseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	for _, prefix := range trustedProxies {
+		if prefix.Bits() == 0 {
+			log.Fatal("TRUSTED_PROXIES cannot include every address")
+		}
+	}
+	if cfg.ClientCertHeader != "" && len(trustedProxies) == 0 {
+		log.Printf("🔐 CLIENT_CERT_HEADER is ignored until TRUSTED_PROXIES lists the TLS-terminating proxy")
+	}
 
 	// Initialize router
 	router := gin.Default()
+	// Client addresses are resolved against TRUSTED_PROXIES by the handlers;
+	// gin must not take X-Forwarded-For from arbitrary peers
+	if err := router.SetTrustedProxies(nil); err != nil {
+		log.Fatalf("Failed to configure trusted proxies: %v", err)
+	}
 
 	// CORS middleware
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Encoding", "Authorization", "Idempotency-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// Accept gzip/zstd compressed batches from sensors
+	router.Use(handlers.DecompressBody())
+
 	// Health check
 	router.GET("/health", handler.Health)
 
 	// API routes
 	api := router.Group("/api/v1")
 	{
-		// Registration management
-		api.POST("/sensors/pending", handler.CreatePendingSensor)
-		api.GET("/sensors/pending", handler.GetPendingSensors)
-		api.DELETE("/sensors/pending/:key", handler.DeletePendingSensor)
+		// Operator routes, authenticated by tenant JWT. Reads are open to
+		// every tenant user, changes require a tenant permission.
+		tenant := api.Group("")
+		tenant.Use(handler.TenantAuth())
+		{
+			canCreate := handler.RequirePermission(handlers.PermissionSensorsCreate)
+			canUpdate := handler.RequirePermission(handlers.PermissionSensorsUpdate)
+			canDelete := handler.RequirePermission(handlers.PermissionSensorsDelete)
+			canManage := handler.RequirePermission(handlers.PermissionSensorsManage)
+			canConfigure := handler.RequirePermission(handlers.PermissionSettingsUpdate)
+
+			// Registration management
+			tenant.POST("/sensors/pending", canCreate, handler.CreatePendingSensor)
+			tenant.GET("/sensors/pending", handler.GetPendingSensors)
+			tenant.POST("/sensors/pending/:key/revoke", canUpdate, handler.RevokePendingSensor)
+			tenant.DELETE("/sensors/pending/:key", canDelete, handler.DeletePendingSensor)
+			tenant.GET("/admin/registration-attempts", handler.ListRegistrationAttempts)
+
+			// Registration settings of the tenant
+			tenant.GET("/admin/settings", handler.GetAdminSettings)
+			tenant.PUT("/admin/settings", canConfigure, handler.UpdateAdminSettings)
+
+			// Fleet management
+			tenant.GET("/admin/sensors", handler.ListSensors)
+			tenant.GET("/admin/sensors/:sensor_id", handler.GetSensor)
+			tenant.PATCH("/admin/sensors/:sensor_id", canUpdate, handler.UpdateSensor)
+			tenant.PUT("/admin/sensors/:sensor_id/tags", canUpdate, handler.SetSensorTags)
+			tenant.POST("/admin/sensors/:sensor_id/decommission", canDelete, handler.DecommissionSensor)
+			tenant.DELETE("/admin/sensors/:sensor_id", canDelete, handler.DeleteSensor)
+			tenant.GET("/admin/sensors/:sensor_id/discovery-batches", handler.ListDiscoveryBatches)
+
+			// Fleet health
+			tenant.GET("/admin/fleet/health", handler.GetFleetHealth)
+			tenant.GET("/admin/sensors/:sensor_id/health-transitions", handler.ListHealthTransitions)
+
+			// Network topology and sensor coverage
+			tenant.GET("/admin/topology/coverage", handler.GetTopologyCoverage)
+			tenant.GET("/admin/topology/graph", handler.GetTopologyGraph)
+
+			// Webhooks and their delivery log
+			tenant.GET("/admin/webhook-event-types", handler.ListWebhookEventTypes)
+			tenant.POST("/admin/webhooks", canConfigure, handler.CreateWebhook)
+			tenant.GET("/admin/webhooks", handler.ListWebhooks)
+			tenant.GET("/admin/webhooks/:webhook_id", handler.GetWebhook)
+			tenant.PUT("/admin/webhooks/:webhook_id", canConfigure, handler.UpdateWebhook)
+			tenant.DELETE("/admin/webhooks/:webhook_id", canConfigure, handler.DeleteWebhook)
+			tenant.POST("/admin/webhooks/:webhook_id/test", canConfigure, handler.TestWebhook)
+			tenant.GET("/admin/webhooks/:webhook_id/deliveries", handler.ListWebhookDeliveries)
+			tenant.GET("/admin/webhook-deliveries", handler.ListWebhookDeliveries)
+			tenant.GET("/admin/webhook-deliveries/:delivery_id", handler.GetWebhookDelivery)
+			tenant.POST("/admin/webhook-deliveries/:delivery_id/redeliver", canConfigure, handler.RedeliverWebhookDelivery)
 
-		// Admin settings
-		api.GET("/admin/settings", handler.GetAdminSettings)
-		api.PUT("/admin/settings", handler.UpdateAdminSettings)
+			// Sensor groups and configuration profiles
+			tenant.POST("/admin/config-profiles", canManage, handler.CreateConfigProfile)
+			tenant.GET("/admin/config-profiles", handler.ListConfigProfiles)
+			tenant.GET("/admin/config-profiles/:profile_id", handler.GetConfigProfile)
+			tenant.PATCH("/admin/config-profiles/:profile_id", canManage, handler.UpdateConfigProfile)
+			tenant.DELETE("/admin/config-profiles/:profile_id", canManage, handler.DeleteConfigProfile)
+			tenant.GET("/admin/config-profiles/:profile_id/versions", handler.ListConfigProfileVersions)
+			tenant.GET("/admin/config-profiles/:profile_id/versions/:version", handler.GetConfigProfileVersion)
+			tenant.POST("/admin/sensor-groups", canManage, handler.CreateSensorGroup)
+			tenant.GET("/admin/sensor-groups", handler.ListSensorGroups)
+			tenant.GET("/admin/sensor-groups/:group_id", handler.GetSensorGroup)
+			tenant.PATCH("/admin/sensor-groups/:group_id", canManage, handler.UpdateSensorGroup)
+			tenant.DELETE("/admin/sensor-groups/:group_id", canManage, handler.DeleteSensorGroup)
+			tenant.PUT("/admin/sensors/:sensor_id/group", canManage, handler.SetSensorGroup)
+			tenant.PUT("/admin/sensors/:sensor_id/config-overrides", canManage, handler.SetSensorConfigOverrides)
+			tenant.GET("/admin/sensors/:sensor_id/config", handler.GetSensorConfigState)
+
+			// Operator command issuing and history
+			tenant.POST("/admin/sensors/:sensor_id/commands", canManage, handler.EnqueueCommand)
+			tenant.GET("/admin/sensors/:sensor_id/commands", handler.ListSensorCommands)
+			tenant.POST("/admin/commands", canManage, handler.EnqueueGroupCommand)
+			tenant.GET("/admin/commands", handler.ListCommands)
+			tenant.GET("/admin/commands/:command_id", handler.GetCommand)
+
+			// Sensor certificate management
+			tenant.GET("/admin/sensors/:sensor_id/certificates", handler.ListSensorCertificates)
+			tenant.POST("/admin/sensors/:sensor_id/certificates/revoke", canManage, handler.RevokeSensorCertificates)
+
+			// Sensor releases and staged rollouts
+			tenant.GET("/admin/releases", handler.ListReleases)
+			tenant.GET("/admin/releases/:release_id", handler.GetRelease)
+			tenant.GET("/admin/rollouts", handler.ListRollouts)
+			tenant.GET("/admin/rollouts/:rollout_id", handler.GetRollout)
+			rollout := tenant.Group("")
+			rollout.Use(handler.RequirePermission(handlers.PermissionRollouts))
+			{
+				rollout.POST("/admin/rollouts", handler.CreateRollout)
+				rollout.POST("/admin/rollouts/:rollout_id/pause", handler.PauseRollout)
+				rollout.POST("/admin/rollouts/:rollout_id/resume", handler.ResumeRollout)
+				rollout.POST("/admin/rollouts/:rollout_id/cancel", handler.CancelRollout)
+			}
+
+			// Diagnostic packet captures
+			diagnostics := tenant.Group("")
+			diagnostics.Use(handler.RequirePermission(handlers.PermissionDiagnostics))
+			{
+				diagnostics.POST("/admin/sensors/:sensor_id/captures", handler.RequestCapture)
+				diagnostics.GET("/admin/captures", handler.ListCaptures)
+				diagnostics.GET("/admin/captures/:capture_id", handler.GetCapture)
+				diagnostics.GET("/admin/captures/:capture_id/download", handler.DownloadCapture)
+				diagnostics.DELETE("/admin/captures/:capture_id", handler.DeleteCapture)
+			}
+		}
+
+		// Release publishing by the build pipeline, authenticated by token
+		publish := api.Group("/releases")
+		publish.Use(handler.ReleasePublishAuth())
+		{
+			publish.POST("", handler.PublishRelease)
+			publish.PUT("/:release_id/artifacts/:platform", handler.UploadReleaseArtifact)
+			publish.POST("/:release_id/yank", handler.YankRelease)
+		}
+
+		// Public PKI endpoints (CA certificate and CRL)
+		api.GET("/pki/tenants/:tenant_id/ca.crt", handler.GetTenantCACertificate)
+		api.GET("/pki/tenants/:tenant_id/crl", handler.GetTenantCRL)
 
 		// Sensor registration
 		api.POST("/sensors/register", handler.RegisterSensor)
 
-		// Sensor-specific routes
+		// Sensor-specific routes, authenticated by client certificate
 		sensors := api.Group("/sensors/:sensor_id")
+		sensors.Use(handler.SensorAuth())
 		{
 			// Outbound-only communication endpoints
 			sensors.POST("/heartbeat", handler.Heartbeat)
-			sensors.GET("/commands", handler.PollCommands)
+			sensors.GET("/commands", handler.PollCommands) // supports long-polling via ?wait=30s
 			sensors.POST("/commands/:command_id/ack", handler.AcknowledgeCommand)
 			sensors.GET("/webhook-config", handler.GetWebhookConfig)
+			sensors.GET("/releases/:version/:platform", handler.DownloadRelease)
+			sensors.PUT("/captures/:capture_id", handler.UploadCapture)
+			sensors.POST("/topology", handler.SubmitTopology)
 
 			// Discovery submission
 			sensors.POST("/discoveries", handler.SubmitDiscoveries)
@@ -76,21 +314,31 @@ func main() {
 	}
 
 	// Setup server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := cfg.Port
 
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: router,
+		// Client certificates are requested but verified per tenant CA by
+		// the SensorAuth middleware, since each tenant has its own CA
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ClientAuth: tls.RequestClientCert,
+		},
 	}
 
 	// Start server
 	go func() {
 		log.Printf("🚀 Sensor Manager starting on port %s", port)
 		log.Printf("📡 Ready to manage network sensors")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.TLSCertFile != "" {
+			log.Printf("🔐 Serving TLS with client certificate authentication")
+			err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -101,6 +349,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down sensor-manager...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
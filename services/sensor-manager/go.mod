@@ -5,7 +5,10 @@ go 1.21
 require (
 	github.com/gin-contrib/cors v1.4.0
 	github.com/gin-gonic/gin v1.9.1
+	github.com/golang-jwt/jwt/v5 v5.0.0
 	github.com/google/uuid v1.3.1
+	github.com/klauspost/compress v1.17.0
+	github.com/lib/pq v1.10.9
 )
 
 require (
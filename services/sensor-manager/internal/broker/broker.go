@@ -0,0 +1,64 @@
+// Package broker wakes up sensors that are long-polling for commands.
+// Sensors only ever make outbound requests, so a command reaches a sensor
+// either on its next heartbeat or immediately if the sensor has an
+// outstanding long-poll request. Commands themselves live in the persistent
+// command queue; the broker only signals that new ones are available.
+// Signals are in-process, so with several replicas a sensor long-polling a
+// different replica picks the command up when its poll times out.
+package broker
+
+import (
+	"sync"
+)
+
+// CommandBroker tracks the long-poll requests currently waiting on each sensor
+type CommandBroker struct {
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+}
+
+// NewCommandBroker creates a new command broker
+func NewCommandBroker() *CommandBroker {
+	return &CommandBroker{
+		waiters: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe registers interest in new commands for a sensor. The returned
+// channel is closed when a command is queued; the cancel function must be
+// called once the caller stops waiting. Callers should check for pending
+// commands after subscribing to avoid missing a command queued in between.
+func (b *CommandBroker) Subscribe(sensorID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+
+	b.mu.Lock()
+	if b.waiters[sensorID] == nil {
+		b.waiters[sensorID] = make(map[chan struct{}]struct{})
+	}
+	b.waiters[sensorID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if waiters, ok := b.waiters[sensorID]; ok {
+			delete(waiters, ch)
+			if len(waiters) == 0 {
+				delete(b.waiters, sensorID)
+			}
+		}
+	}
+
+	return ch, cancel
+}
+
+// Notify wakes every long-poll request waiting on a sensor
+func (b *CommandBroker) Notify(sensorID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.waiters[sensorID] {
+		close(ch)
+	}
+	delete(b.waiters, sensorID)
+}
@@ -0,0 +1,355 @@
+// Package captures implements on-demand diagnostic packet captures. An
+// operator asks a sensor for a short capture matching a BPF expression; the
+// request is delivered as a diagnostic_capture command carrying the
+// sensor-manager's capture public key. The sensor keeps only protocol
+// handshakes, seals the pcap to that key and uploads it. Sealed pcaps are
+// stored as uploaded, opened only when an authorized user downloads them,
+// and deleted once their retention ends.
+package captures
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/commands"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/google/uuid"
+)
+
+// CommandType is the command that asks a sensor for a capture
+const CommandType = "diagnostic_capture"
+
+// Limits and defaults of capture requests
+const (
+	DefaultDuration = 30 * time.Second
+	MaxDuration     = 5 * time.Minute
+	DefaultMaxBytes = 10 << 20
+	MinMaxBytes     = 4 << 10
+	MaxMaxBytes     = 50 << 20
+	maxFilterLength = 1024
+
+	// sealOverhead is the size the sealing adds to a pcap: the ephemeral
+	// public key, the nonce and the GCM tag
+	sealOverhead = 32 + 12 + 16
+	// sealContext prefixes the key derivation input; sensors use the same
+	sealContext = "crypto-sensor-capture:v1"
+
+	// uploadGrace is how long after the capture ends the sensor has to
+	// upload it before the command is redelivered
+	uploadGrace = 2 * time.Minute
+	// purgeInterval is how often expired captures are deleted
+	purgeInterval = time.Hour
+)
+
+var (
+	// ErrNoKey is returned when no capture key is configured
+	ErrNoKey = errors.New("no capture key configured")
+	// ErrInvalidRequest is wrapped by capture request validation errors
+	ErrInvalidRequest = errors.New("invalid capture request")
+	// ErrTooLarge is returned when an upload exceeds the capture's byte limit
+	ErrTooLarge = errors.New("capture exceeds its byte limit")
+	// ErrInvalidUpload is returned when an upload was not sealed for the
+	// capture with the configured key
+	ErrInvalidUpload = errors.New("capture is not sealed with the capture key")
+)
+
+// Request describes a capture to take
+type Request struct {
+	Interface   string
+	BPFFilter   string
+	Duration    time.Duration
+	MaxBytes    int64
+	Reason      string
+	RequestedBy string
+}
+
+// Validate checks the request and fills in defaults
+func (r *Request) Validate() error {
+	r.BPFFilter = strings.TrimSpace(r.BPFFilter)
+	if r.BPFFilter == "" {
+		return fmt.Errorf("%w: bpf_filter is required", ErrInvalidRequest)
+	}
+	if len(r.BPFFilter) > maxFilterLength || strings.IndexFunc(r.BPFFilter, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w: bpf_filter must be a single line of at most %d characters", ErrInvalidRequest, maxFilterLength)
+	}
+	if len(r.Interface) > 100 {
+		return fmt.Errorf("%w: interface name is too long", ErrInvalidRequest)
+	}
+
+	if r.Duration == 0 {
+		r.Duration = DefaultDuration
+	}
+	if r.Duration < time.Second || r.Duration > MaxDuration {
+		return fmt.Errorf("%w: duration_seconds must be between 1 and %d", ErrInvalidRequest, int(MaxDuration/time.Second))
+	}
+
+	if r.MaxBytes == 0 {
+		r.MaxBytes = DefaultMaxBytes
+	}
+	if r.MaxBytes < MinMaxBytes || r.MaxBytes > MaxMaxBytes {
+		return fmt.Errorf("%w: max_bytes must be between %d and %d", ErrInvalidRequest, MinMaxBytes, MaxMaxBytes)
+	}
+	return nil
+}
+
+// Service requests, stores and serves diagnostic captures
+type Service struct {
+	repo           *repository.Repository
+	commandService *commands.Service
+	dir            string
+	key            *ecdh.PrivateKey
+	retention      time.Duration
+}
+
+// NewService creates a capture service storing sealed pcaps in dir for
+// retention. encodedKey is the base64 X25519 private key captures are
+// sealed to; when empty, captures cannot be requested.
+func NewService(repo *repository.Repository, commandService *commands.Service, dir, encodedKey string, retention time.Duration) (*Service, error) {
+	s := &Service{
+		repo:           repo,
+		commandService: commandService,
+		dir:            dir,
+		retention:      retention,
+	}
+	if encodedKey == "" {
+		return s, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, errors.New("capture key is not base64")
+	}
+	s.key, err = ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture key: %w", err)
+	}
+	return s, nil
+}
+
+// Request asks a sensor of a tenant for a capture
+func (s *Service) Request(tenantID, sensorID string, req Request) (*models.DiagnosticCapture, error) {
+	if s.key == nil {
+		return nil, ErrNoKey
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	capture := &models.DiagnosticCapture{
+		TenantID:        tenantID,
+		SensorID:        sensorID,
+		Interface:       req.Interface,
+		BPFFilter:       req.BPFFilter,
+		DurationSeconds: int(req.Duration / time.Second),
+		MaxBytes:        req.MaxBytes,
+		Reason:          req.Reason,
+		RequestedBy:     req.RequestedBy,
+		ExpiresAt:       time.Now().Add(s.retention),
+	}
+	if err := s.repo.CreateDiagnosticCapture(capture); err != nil {
+		return nil, err
+	}
+
+	records, err := s.commandService.Enqueue(tenantID, []string{sensorID}, commands.Spec{
+		Type:     CommandType,
+		Priority: 5,
+		Payload: map[string]interface{}{
+			"capture_id":       capture.ID,
+			"interface":        capture.Interface,
+			"bpf_filter":       capture.BPFFilter,
+			"duration_seconds": capture.DurationSeconds,
+			"max_bytes":        capture.MaxBytes,
+			"public_key":       base64.StdEncoding.EncodeToString(s.key.PublicKey().Bytes()),
+		},
+		RequiresAck: true,
+		AckTimeout:  req.Duration + uploadGrace,
+		CreatedBy:   req.RequestedBy,
+	})
+	if err != nil {
+		if deleteErr := s.repo.DeleteDiagnosticCapture(tenantID, capture.ID); deleteErr != nil {
+			log.Printf("❌ Failed to remove capture %s: %v", capture.ID, deleteErr)
+		}
+		return nil, err
+	}
+
+	capture.CommandID = records[0].ID
+	if err := s.repo.SetDiagnosticCaptureCommand(capture.ID, capture.CommandID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDiagnosticCapture(tenantID, capture.ID)
+}
+
+// Upload stores the sealed pcap a sensor uploads for one of its captures.
+// The upload is opened once to check it was sealed for this capture with
+// the capture key, then stored sealed.
+func (s *Service) Upload(sensorID, captureID string, packets int, body io.Reader) (*models.DiagnosticCapture, error) {
+	if s.key == nil {
+		return nil, ErrNoKey
+	}
+	capture, err := s.repo.GetSensorDiagnosticCapture(sensorID, captureID)
+	if err != nil {
+		return nil, err
+	}
+	if capture.UploadedAt != nil {
+		return nil, repository.ErrConflict
+	}
+
+	limit := capture.MaxBytes + sealOverhead
+	sealed, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive capture: %w", err)
+	}
+	if int64(len(sealed)) > limit {
+		return nil, ErrTooLarge
+	}
+	if _, err := s.open(capture.ID, sealed); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create capture store: %w", err)
+	}
+	storageKey := uuid.New().String()
+	if err := os.WriteFile(filepath.Join(s.dir, storageKey), sealed, 0600); err != nil {
+		return nil, fmt.Errorf("failed to store capture: %w", err)
+	}
+
+	digest := sha256.Sum256(sealed)
+	err = s.repo.CompleteDiagnosticCapture(capture.ID, storageKey, int64(len(sealed)), hex.EncodeToString(digest[:]),
+		packets, time.Now().Add(s.retention))
+	if err != nil {
+		os.Remove(filepath.Join(s.dir, storageKey))
+		return nil, err
+	}
+	return s.repo.GetDiagnosticCapture(capture.TenantID, capture.ID)
+}
+
+// Open returns an uploaded capture of a tenant and its pcap
+func (s *Service) Open(tenantID, captureID string) (*models.DiagnosticCapture, []byte, error) {
+	if s.key == nil {
+		return nil, nil, ErrNoKey
+	}
+	capture, err := s.repo.GetDiagnosticCapture(tenantID, captureID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if capture.StorageKey == "" {
+		return capture, nil, repository.ErrNotFound
+	}
+
+	sealed, err := os.ReadFile(filepath.Join(s.dir, filepath.Base(capture.StorageKey)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read capture: %w", err)
+	}
+	pcap, err := s.open(capture.ID, sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return capture, pcap, nil
+}
+
+// Delete removes a capture of a tenant and its pcap
+func (s *Service) Delete(tenantID, captureID string) error {
+	capture, err := s.repo.GetDiagnosticCapture(tenantID, captureID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteDiagnosticCapture(tenantID, captureID); err != nil {
+		return err
+	}
+	s.removeFile(capture.StorageKey)
+	return nil
+}
+
+// Run periodically deletes the pcaps of expired captures until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		s.purge()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge deletes the pcaps of captures past their retention. The capture
+// records stay for the audit trail.
+func (s *Service) purge() {
+	expired, err := s.repo.ExpireDiagnosticCaptures()
+	if err != nil {
+		log.Printf("❌ Failed to expire diagnostic captures: %v", err)
+		return
+	}
+	for _, storageKey := range expired {
+		s.removeFile(storageKey)
+	}
+	if len(expired) > 0 {
+		log.Printf("🧹 Deleted %d expired diagnostic captures", len(expired))
+	}
+}
+
+func (s *Service) removeFile(storageKey string) {
+	if storageKey == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(s.dir, filepath.Base(storageKey))); err != nil && !os.IsNotExist(err) {
+		log.Printf("❌ Failed to delete capture file %s: %v", storageKey, err)
+	}
+}
+
+// open decrypts a sealed pcap: an ephemeral X25519 public key, a nonce and
+// the AES-256-GCM ciphertext, authenticated with the capture ID so a pcap
+// cannot be passed off as another capture
+func (s *Service) open(captureID string, sealed []byte) ([]byte, error) {
+	if len(sealed) < sealOverhead {
+		return nil, ErrInvalidUpload
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed[:32])
+	if err != nil {
+		return nil, ErrInvalidUpload
+	}
+	shared, err := s.key.ECDH(ephemeral)
+	if err != nil {
+		return nil, ErrInvalidUpload
+	}
+
+	kdf := sha256.New()
+	kdf.Write([]byte(sealContext))
+	kdf.Write(shared)
+	kdf.Write(ephemeral.Bytes())
+	kdf.Write(s.key.PublicKey().Bytes())
+
+	block, err := aes.NewCipher(kdf.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := sealed[32 : 32+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, sealed[32+aead.NonceSize():], []byte(captureID))
+	if err != nil {
+		return nil, ErrInvalidUpload
+	}
+	return plain, nil
+}
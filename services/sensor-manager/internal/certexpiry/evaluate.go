@@ -0,0 +1,126 @@
+package certexpiry
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// DefaultThresholds are the days before expiry owners are reminded at
+var DefaultThresholds = []int{60, 30, 14, 7, 1}
+
+// NormalizeThresholds returns the positive thresholds, without duplicates,
+// largest first, or DefaultThresholds when there are none
+func NormalizeThresholds(days []int) []int {
+	seen := make(map[int]bool)
+	thresholds := []int{}
+	for _, day := range days {
+		if day > 0 && !seen[day] {
+			seen[day] = true
+			thresholds = append(thresholds, day)
+		}
+	}
+	if len(thresholds) == 0 {
+		return append([]int(nil), DefaultThresholds...)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(thresholds)))
+	return thresholds
+}
+
+// Threshold returns the threshold a certificate expiring at notAfter has
+// reached at now: the smallest one no further away than its expiry, 0 once
+// it expired, or -1 before it reaches the largest. thresholds are largest
+// first.
+func Threshold(notAfter, now time.Time, thresholds []int) int {
+	left := notAfter.Sub(now)
+	if left <= 0 {
+		return 0
+	}
+	for i := len(thresholds) - 1; i >= 0; i-- {
+		if left <= time.Duration(thresholds[i])*24*time.Hour {
+			return thresholds[i]
+		}
+	}
+	return -1
+}
+
+// DaysUntilExpiry returns the whole days left before notAfter, negative
+// once it passed
+func DaysUntilExpiry(notAfter, now time.Time) int {
+	return int(math.Floor(notAfter.Sub(now).Hours() / 24))
+}
+
+// Notice decides whether a served certificate is notified on at now. It
+// returns the reminder's new threshold, whether the certificate was served
+// after it expired, the event to publish, or "" when the owner was already
+// told.
+func Notice(served *models.ServedCertificate, now time.Time, thresholds []int) (int, bool, string) {
+	threshold := Threshold(served.NotAfter, now, thresholds)
+	if threshold < 0 {
+		return 0, false, ""
+	}
+	expiredInUse := served.ExpiredInUse || (threshold == 0 && !served.LastSeenAt.Before(served.NotAfter))
+
+	switch {
+	case served.ReminderID == "":
+	case threshold < served.ReminderThreshold:
+	case expiredInUse && !served.ExpiredInUse:
+	default:
+		return 0, false, ""
+	}
+
+	if threshold == 0 {
+		return threshold, expiredInUse, models.EventCertificateExpired
+	}
+	return threshold, expiredInUse, models.EventCertificateExpiring
+}
+
+// FindRenewal returns the certificate that renewed reminder's certificate
+// on its endpoint: one presenting the same names that expires later. The
+// candidates are those the endpoint presented since, most recent first.
+func FindRenewal(reminder *models.ExpiryReminder, candidates []*models.EndpointCertificate) *models.EndpointCertificate {
+	names := certificateNames(reminder.SubjectAlternativeNames, reminder.CommonName)
+	for _, candidate := range candidates {
+		if candidate.FingerprintSHA256 == reminder.FingerprintSHA256 || !candidate.NotAfter.After(reminder.NotAfter) {
+			continue
+		}
+		if sameNames(names, certificateNames(candidate.SubjectAlternativeNames, candidate.CommonName)) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// certificateNames returns the names a certificate is valid for, lowercase
+// and sorted: its SANs, or its common name when it has none
+func certificateNames(sans []string, commonName string) []string {
+	if len(sans) == 0 && commonName != "" {
+		sans = []string{commonName}
+	}
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, name := range sans {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) == 0 || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+// Package certexpiry reminds asset owners of expiring certificates. A
+// background scheduler walks the certificates served on the tenants'
+// endpoints and, as each crosses an expiry threshold, opens or advances a
+// reminder for the certificate and endpoint and publishes a webhook event
+// addressed to the asset owner. Certificates still served after they
+// expired are flagged and alerted on once more. Reminders close when the
+// endpoint presents a renewal, a certificate for the same names with a new
+// fingerprint, or stops presenting the certificate; renewals are recorded
+// in the history of both certificates. Reminders only change if no other
+// replica changed them first, so each notice is sent once.
+package certexpiry
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/webhooks"
+)
+
+const (
+	// scanBatch is how many certificates or reminders are loaded per query
+	scanBatch = 500
+	// expiredLookback is how long after their expiry certificates nobody
+	// was seen serving are still notified on as expired
+	expiredLookback = 7 * 24 * time.Hour
+)
+
+// Service schedules certificate expiry notices in the background
+type Service struct {
+	repo       *repository.Repository
+	webhooks   *webhooks.Dispatcher
+	thresholds []int
+	interval   time.Duration
+}
+
+// NewService creates a new expiry scheduler that scans every interval and
+// notifies at the given days before expiry
+func NewService(repo *repository.Repository, dispatcher *webhooks.Dispatcher, thresholds []int, interval time.Duration) *Service {
+	return &Service{
+		repo:       repo,
+		webhooks:   dispatcher,
+		thresholds: NormalizeThresholds(thresholds),
+		interval:   interval,
+	}
+}
+
+// Run scans at startup and then periodically until ctx is cancelled
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Scan(ctx); err != nil {
+			log.Printf("❌ Certificate expiry scan failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan closes the reminders of renewed or dropped certificates, then
+// notifies on the certificates that crossed a threshold since the last scan
+func (s *Service) Scan(ctx context.Context) error {
+	if err := s.closeReminders(ctx); err != nil {
+		return err
+	}
+	return s.notifyExpiries(ctx, time.Now())
+}
+
+// closeReminders walks the open reminders and closes those whose
+// certificate was renewed on, or is no longer presented by, its endpoint
+func (s *Service) closeReminders(ctx context.Context) error {
+	afterID := ""
+	for {
+		reminders, err := s.repo.ListOpenReminders(afterID, scanBatch)
+		if err != nil {
+			return err
+		}
+		for _, reminder := range reminders {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := s.checkRenewal(reminder); err != nil {
+				log.Printf("❌ Failed to check renewal of certificate %s: %v", reminder.CertificateID, err)
+			}
+		}
+		if len(reminders) < scanBatch {
+			return nil
+		}
+		afterID = reminders[len(reminders)-1].ID
+	}
+}
+
+func (s *Service) checkRenewal(reminder *models.ExpiryReminder) error {
+	var seenAfter time.Time
+	if reminder.LastSeenAt != nil {
+		seenAfter = *reminder.LastSeenAt
+	}
+	candidates, err := s.repo.ListEndpointCertificates(reminder.AssetID, reminder.Port, reminder.CertificateID, seenAfter)
+	if err != nil {
+		return err
+	}
+
+	if renewal := FindRenewal(reminder, candidates); renewal != nil {
+		alert := models.CertificateRenewalAlert{
+			CertificateID:           reminder.CertificateID,
+			FingerprintSHA256:       reminder.FingerprintSHA256,
+			RenewedByCertificateID:  renewal.CertificateID,
+			RenewedByFingerprint:    renewal.FingerprintSHA256,
+			CommonName:              reminder.CommonName,
+			SubjectAlternativeNames: reminder.SubjectAlternativeNames,
+			PreviousNotAfter:        reminder.NotAfter,
+			NotAfter:                renewal.NotAfter,
+			AssetID:                 reminder.AssetID,
+			Hostname:                reminder.Hostname,
+			IPAddress:               reminder.IPAddress,
+			Port:                    reminder.Port,
+			OwnerEmail:              reminder.OwnerEmail,
+			DetectedAt:              time.Now(),
+		}
+		closed, err := s.repo.CloseRenewedReminder(reminder, renewal, alert)
+		if err != nil || !closed {
+			return err
+		}
+		log.Printf("🔄 Certificate %s renewed by %s on asset %s port %d",
+			reminder.CertificateID, renewal.CertificateID, reminder.AssetID, reminder.Port)
+		s.webhooks.Notify()
+		return nil
+	}
+
+	if !reminder.Served {
+		_, err := s.repo.CloseUnservedReminder(reminder)
+		return err
+	}
+	return nil
+}
+
+// notifyExpiries walks the served certificates nearing expiry and notifies
+// on those that crossed a threshold
+func (s *Service) notifyExpiries(ctx context.Context, now time.Time) error {
+	horizon := now.Add(time.Duration(s.thresholds[0]) * 24 * time.Hour)
+	var after *models.ServedCertificate
+	for {
+		batch, err := s.repo.ListServedCertificates(horizon, now.Add(-expiredLookback), after, scanBatch)
+		if err != nil {
+			return err
+		}
+		for _, served := range batch {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := s.notify(served, now); err != nil {
+				log.Printf("❌ Failed to notify expiry of certificate %s: %v", served.CertificateID, err)
+			}
+		}
+		if len(batch) < scanBatch {
+			return nil
+		}
+		after = batch[len(batch)-1]
+	}
+}
+
+func (s *Service) notify(served *models.ServedCertificate, now time.Time) error {
+	threshold, expiredInUse, event := Notice(served, now, s.thresholds)
+	if event == "" {
+		return nil
+	}
+
+	alert := models.CertificateExpiryAlert{
+		CertificateID:           served.CertificateID,
+		FingerprintSHA256:       served.FingerprintSHA256,
+		CommonName:              served.CommonName,
+		SubjectAlternativeNames: served.SubjectAlternativeNames,
+		NotAfter:                served.NotAfter,
+		DaysUntilExpiry:         DaysUntilExpiry(served.NotAfter, now),
+		ThresholdDays:           threshold,
+		Expired:                 threshold == 0,
+		StillServed:             expiredInUse,
+		AssetID:                 served.AssetID,
+		Hostname:                served.Hostname,
+		IPAddress:               served.IPAddress,
+		Port:                    served.Port,
+		OwnerEmail:              served.OwnerEmail,
+		LastSeenAt:              served.LastSeenAt,
+	}
+	notified, err := s.repo.RecordExpiryNotice(served, threshold, expiredInUse, event, alert)
+	if err != nil || !notified {
+		return err
+	}
+	log.Printf("⏰ Certificate %s on asset %s port %d: %s (%d days left)",
+		served.CertificateID, served.AssetID, served.Port, event, alert.DaysUntilExpiry)
+	s.webhooks.Notify()
+	return nil
+}
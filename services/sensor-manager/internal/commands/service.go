@@ -0,0 +1,246 @@
+// Package commands implements the sensor command queue. Operators enqueue
+// commands for individual sensors or groups of sensors; sensors receive them
+// over their outbound channel (heartbeat or long-poll) and report back.
+// Each command moves through queued -> delivered -> acknowledged ->
+// succeeded / failed / expired, and every transition is recorded.
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/broker"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Defaults and limits for enqueued commands
+const (
+	DefaultTTL        = time.Hour
+	MaxTTL            = 7 * 24 * time.Hour
+	DefaultAttempts   = 3
+	MaxAttempts       = 10
+	DefaultAckTimeout = 2 * time.Minute
+	MaxAckTimeout     = time.Hour
+
+	// deliveryBatchSize caps how many commands one response carries
+	deliveryBatchSize = 50
+	// expiryInterval is how often expired commands are swept
+	expiryInterval = 30 * time.Second
+)
+
+// ValidTypes lists the command types sensors understand
+var ValidTypes = map[string]bool{
+	"update_config":      true,
+	"restart":            true,
+	"stop":               true,
+	"start_capture":      true,
+	"stop_capture":       true,
+	"upgrade":            true, // issued by release rollouts
+	"diagnostic_capture": true, // issued by capture requests
+}
+
+// ErrNoTargets is returned when a group enqueue matches no active sensors
+var ErrNoTargets = errors.New("no active sensors match the target")
+
+// Spec describes a command to enqueue
+type Spec struct {
+	Type        string
+	Priority    int
+	Payload     map[string]interface{}
+	RequiresAck bool
+	TTL         time.Duration
+	MaxAttempts int
+	AckTimeout  time.Duration
+	CreatedBy   string
+}
+
+// Validate checks the spec and fills in defaults
+func (s *Spec) Validate() error {
+	if !ValidTypes[s.Type] {
+		return fmt.Errorf("unknown command type: %s", s.Type)
+	}
+
+	if s.TTL == 0 {
+		s.TTL = DefaultTTL
+	}
+	if s.TTL < time.Minute || s.TTL > MaxTTL {
+		return fmt.Errorf("ttl must be between 1m and %s", MaxTTL)
+	}
+
+	if s.MaxAttempts == 0 {
+		s.MaxAttempts = DefaultAttempts
+	}
+	if s.MaxAttempts < 1 || s.MaxAttempts > MaxAttempts {
+		return fmt.Errorf("max_attempts must be between 1 and %d", MaxAttempts)
+	}
+
+	if s.AckTimeout == 0 {
+		s.AckTimeout = DefaultAckTimeout
+	}
+	if s.AckTimeout < 10*time.Second || s.AckTimeout > MaxAckTimeout {
+		return fmt.Errorf("ack_timeout must be between 10s and %s", MaxAckTimeout)
+	}
+
+	if s.Payload == nil {
+		s.Payload = map[string]interface{}{}
+	}
+	return nil
+}
+
+// Service manages the command queue
+type Service struct {
+	repo   *repository.Repository
+	broker *broker.CommandBroker
+}
+
+// NewService creates a new command service
+func NewService(repo *repository.Repository, commandBroker *broker.CommandBroker) *Service {
+	return &Service{
+		repo:   repo,
+		broker: commandBroker,
+	}
+}
+
+// Enqueue queues a command for each of the given sensors of a tenant. When
+// more than one sensor is targeted the commands share a batch ID. Sensors
+// with an open long-poll request are woken up immediately.
+func (s *Service) Enqueue(tenantID string, sensorIDs []string, spec Spec) ([]*models.CommandRecord, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if len(sensorIDs) == 0 {
+		return nil, ErrNoTargets
+	}
+
+	var batchID string
+	if len(sensorIDs) > 1 {
+		batchID = uuid.New().String()
+	}
+
+	expiresAt := time.Now().Add(spec.TTL)
+	records := make([]*models.CommandRecord, 0, len(sensorIDs))
+	for _, sensorID := range sensorIDs {
+		records = append(records, &models.CommandRecord{
+			TenantID:          tenantID,
+			SensorID:          sensorID,
+			BatchID:           batchID,
+			Type:              spec.Type,
+			Priority:          spec.Priority,
+			Payload:           spec.Payload,
+			RequiresAck:       spec.RequiresAck,
+			MaxAttempts:       spec.MaxAttempts,
+			AckTimeoutSeconds: int(spec.AckTimeout / time.Second),
+			ExpiresAt:         expiresAt,
+			CreatedBy:         spec.CreatedBy,
+		})
+	}
+
+	if err := s.repo.EnqueueCommands(records); err != nil {
+		return nil, err
+	}
+
+	for _, sensorID := range sensorIDs {
+		s.broker.Notify(sensorID)
+	}
+	return records, nil
+}
+
+// EnqueueGroup queues a command for every active sensor of a tenant that is
+// in sensorIDs (when given) and carries tag (when given)
+func (s *Service) EnqueueGroup(tenantID string, sensorIDs []string, tag string, spec Spec) ([]*models.CommandRecord, error) {
+	targets, err := s.repo.ListCommandTargets(tenantID, sensorIDs, tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, ErrNoTargets
+	}
+	return s.Enqueue(tenantID, targets, spec)
+}
+
+// Deliver returns the commands to hand to a sensor now and marks them
+// delivered
+func (s *Service) Deliver(sensorID string) ([]models.Command, error) {
+	records, err := s.repo.DeliverCommands(sensorID, deliveryBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make([]models.Command, 0, len(records))
+	for _, record := range records {
+		commands = append(commands, record.ToCommand())
+	}
+	return commands, nil
+}
+
+// Wait long-polls for commands: it returns pending commands immediately, or
+// blocks until a command is queued, wait elapses or ctx is done
+func (s *Service) Wait(ctx context.Context, sensorID string, wait time.Duration) ([]models.Command, error) {
+	commands, err := s.Deliver(sensorID)
+	if err != nil || len(commands) > 0 || wait <= 0 {
+		return commands, err
+	}
+
+	notify, unsubscribe := s.broker.Subscribe(sensorID)
+	defer unsubscribe()
+
+	// Re-check after subscribing so a command queued in between is not missed
+	commands, err = s.Deliver(sensorID)
+	if err != nil || len(commands) > 0 {
+		return commands, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-notify:
+		return s.Deliver(sensorID)
+	case <-timer.C:
+		return commands, nil
+	case <-ctx.Done():
+		// Sensor went away; anything queued stays for the next poll
+		return nil, ctx.Err()
+	}
+}
+
+// Report records an acknowledgement or result reported by a sensor
+func (s *Service) Report(sensorID string, result *models.CommandResult) (*models.CommandRecord, error) {
+	// Older sensors acknowledge without a status
+	if result.Status == "" {
+		result.Status = models.CommandStatusAcknowledged
+	}
+	return s.repo.RecordCommandResult(sensorID, result)
+}
+
+// Release wakes any long-poll a sensor holds, e.g. after it was decommissioned
+func (s *Service) Release(sensorID string) {
+	s.broker.Notify(sensorID)
+}
+
+// Run periodically expires commands past their TTL until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.repo.ExpireCommands()
+			if err != nil {
+				log.Printf("❌ Command expiry failed: %v", err)
+				continue
+			}
+			if changed > 0 {
+				log.Printf("⏰ Expired or failed %d unanswered commands", changed)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package fleethealth
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+const (
+	// defaultReportingInterval applies to sensors whose configuration does
+	// not state one
+	defaultReportingInterval = 60 * time.Second
+	// Heartbeat silence below these floors never changes a sensor's state,
+	// so sensors with short reporting intervals are not flagged over a
+	// single slow request
+	minDegradedAfter = 2 * time.Minute
+	minOfflineAfter  = 5 * time.Minute
+	// minDropSample is the fewest packets a heartbeat must account for
+	// before its drop rate is judged
+	minDropSample = 1000
+)
+
+// Metrics sensors report in their heartbeats, per reporting interval
+// unless noted otherwise
+const (
+	metricPacketsReceived = "packets_received"
+	metricPacketsDropped  = "packets_dropped"
+	metricBacklog         = "discovery_backlog"  // discoveries awaiting upload
+	metricStorageUsed     = "storage_used_bytes" // size of local storage
+)
+
+// Thresholds decide when a sensor is degraded or offline
+type Thresholds struct {
+	DegradedMissed  int     // missed heartbeats before a sensor is degraded
+	OfflineMissed   int     // missed heartbeats before a sensor is offline
+	MaxDropRate     float64 // share of packets dropped
+	MaxBacklog      int64   // discoveries awaiting upload
+	MaxStorageUsage float64 // share of the storage limit in use
+}
+
+// Evaluate derives a sensor's health state from its last heartbeat and the
+// metrics reported with it. A sensor that never sent a heartbeat stays
+// unknown until it is overdue enough to count as offline.
+func Evaluate(sensor *models.RegisteredSensor, now time.Time, thresholds Thresholds) (string, []models.HealthReason) {
+	var config models.SensorConfig
+	json.Unmarshal(sensor.Configuration, &config)
+	interval := time.Duration(config.ReportingInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultReportingInterval
+	}
+
+	degradedAfter := time.Duration(thresholds.DegradedMissed) * interval
+	if degradedAfter < minDegradedAfter {
+		degradedAfter = minDegradedAfter
+	}
+	offlineAfter := time.Duration(thresholds.OfflineMissed) * interval
+	if offlineAfter < minOfflineAfter {
+		offlineAfter = minOfflineAfter
+	}
+	if offlineAfter <= degradedAfter {
+		offlineAfter = degradedAfter + interval
+	}
+
+	// Sensors that never sent a heartbeat are measured from registration
+	reference := sensor.CreatedAt
+	if sensor.RegisteredAt != nil {
+		reference = *sensor.RegisteredAt
+	}
+	if sensor.LastHeartbeatAt != nil {
+		reference = *sensor.LastHeartbeatAt
+	}
+	silence := now.Sub(reference)
+
+	if silence >= offlineAfter {
+		return models.HealthStateOffline, []models.HealthReason{{
+			Code:      models.HealthReasonHeartbeatLost,
+			Message:   silenceMessage(sensor, reference, offlineAfter),
+			Threshold: offlineAfter.Seconds(),
+		}}
+	}
+	if sensor.LastHeartbeatAt == nil {
+		return models.HealthStateUnknown, []models.HealthReason{}
+	}
+
+	reasons := []models.HealthReason{}
+	if silence >= degradedAfter {
+		reasons = append(reasons, models.HealthReason{
+			Code:      models.HealthReasonHeartbeatOverdue,
+			Message:   silenceMessage(sensor, reference, degradedAfter),
+			Threshold: degradedAfter.Seconds(),
+		})
+	}
+	reasons = append(reasons, metricReasons(sensor.LastHealth, config, thresholds)...)
+
+	if len(reasons) > 0 {
+		return models.HealthStateDegraded, reasons
+	}
+	return models.HealthStateHealthy, reasons
+}
+
+// metricReasons checks the status and metrics of a heartbeat against the
+// thresholds. Metrics a sensor does not report are not checked.
+func metricReasons(lastHealth json.RawMessage, config models.SensorConfig, thresholds Thresholds) []models.HealthReason {
+	var health models.SensorHealth
+	if len(lastHealth) == 0 || json.Unmarshal(lastHealth, &health) != nil {
+		return nil
+	}
+
+	var reasons []models.HealthReason
+	if health.Status == models.SensorStatusError {
+		reasons = append(reasons, models.HealthReason{
+			Code:    models.HealthReasonSensorError,
+			Message: "sensor reports an error state",
+			Value:   float64(health.Errors),
+		})
+	}
+
+	received, hasReceived := metricFloat(health.Metrics, metricPacketsReceived)
+	dropped, hasDropped := metricFloat(health.Metrics, metricPacketsDropped)
+	if hasReceived && hasDropped && thresholds.MaxDropRate > 0 && received+dropped >= minDropSample {
+		if rate := dropped / (received + dropped); rate > thresholds.MaxDropRate {
+			reasons = append(reasons, models.HealthReason{
+				Code:      models.HealthReasonPacketDrops,
+				Message:   fmt.Sprintf("%.1f%% of packets dropped in the last reporting interval", rate*100),
+				Value:     rate,
+				Threshold: thresholds.MaxDropRate,
+			})
+		}
+	}
+
+	if backlog, ok := metricFloat(health.Metrics, metricBacklog); ok && thresholds.MaxBacklog > 0 && backlog > float64(thresholds.MaxBacklog) {
+		reasons = append(reasons, models.HealthReason{
+			Code:      models.HealthReasonStorageBacklog,
+			Message:   fmt.Sprintf("%.0f discoveries waiting to be uploaded", backlog),
+			Value:     backlog,
+			Threshold: float64(thresholds.MaxBacklog),
+		})
+	}
+
+	limit := float64(config.StorageConfig.MaxStorageSize)
+	if used, ok := metricFloat(health.Metrics, metricStorageUsed); ok && limit > 0 && thresholds.MaxStorageUsage > 0 {
+		if usage := used / limit; usage > thresholds.MaxStorageUsage {
+			reasons = append(reasons, models.HealthReason{
+				Code:      models.HealthReasonStorageUsage,
+				Message:   fmt.Sprintf("local storage %.0f%% full", usage*100),
+				Value:     usage,
+				Threshold: thresholds.MaxStorageUsage,
+			})
+		}
+	}
+	return reasons
+}
+
+// silenceMessage describes missing heartbeats. It names the time of the
+// last heartbeat rather than the elapsed time, so the reason stays the same
+// between evaluations.
+func silenceMessage(sensor *models.RegisteredSensor, reference time.Time, threshold time.Duration) string {
+	if sensor.LastHeartbeatAt == nil {
+		return fmt.Sprintf("no heartbeat since registration at %s (threshold %s)", reference.UTC().Format(time.RFC3339), threshold)
+	}
+	return fmt.Sprintf("no heartbeat since %s (threshold %s)", reference.UTC().Format(time.RFC3339), threshold)
+}
+
+// metricFloat reads a non-negative number from heartbeat metrics
+func metricFloat(metrics map[string]interface{}, key string) (float64, bool) {
+	value, ok := metrics[key].(float64)
+	if !ok || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
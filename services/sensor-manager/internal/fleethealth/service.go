@@ -0,0 +1,140 @@
+// Package fleethealth watches the health of the sensor fleet. A background
+// evaluator periodically derives each sensor's health state (healthy,
+// degraded or offline) from its heartbeats and the metrics it reports,
+// stores the state with the reasons for it and records every transition.
+// Transitions are published as webhook events. The stored state is
+// only changed if no other replica changed it first, so each transition is
+// recorded and alerted once.
+package fleethealth
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/webhooks"
+)
+
+// evaluationBatch is how many sensors are loaded per query while walking
+// the fleet
+const evaluationBatch = 500
+
+// Service evaluates fleet health in the background
+type Service struct {
+	repo       *repository.Repository
+	webhooks   *webhooks.Dispatcher
+	thresholds Thresholds
+	interval   time.Duration
+}
+
+// NewService creates a new fleet health service that evaluates the fleet
+// every interval
+func NewService(repo *repository.Repository, dispatcher *webhooks.Dispatcher, thresholds Thresholds, interval time.Duration) *Service {
+	return &Service{
+		repo:       repo,
+		webhooks:   dispatcher,
+		thresholds: thresholds,
+		interval:   interval,
+	}
+}
+
+// Run evaluates the fleet periodically until ctx is cancelled
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.EvaluateFleet(ctx); err != nil {
+				log.Printf("❌ Fleet health evaluation failed: %v", err)
+			}
+		}
+	}
+}
+
+// EvaluateFleet evaluates every active sensor once
+func (s *Service) EvaluateFleet(ctx context.Context) error {
+	afterID := ""
+	for {
+		sensors, err := s.repo.ListHealthCandidates(afterID, evaluationBatch)
+		if err != nil {
+			return err
+		}
+		for _, sensor := range sensors {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := s.evaluate(sensor, time.Now()); err != nil {
+				log.Printf("❌ Failed to evaluate health of sensor %s: %v", sensor.ID, err)
+			}
+		}
+		if len(sensors) < evaluationBatch {
+			return nil
+		}
+		afterID = sensors[len(sensors)-1].ID
+	}
+}
+
+// evaluate stores a sensor's health when its state or reasons changed and
+// alerts on state transitions
+func (s *Service) evaluate(sensor *models.RegisteredSensor, now time.Time) error {
+	state, reasons := Evaluate(sensor, now, s.thresholds)
+	if state == sensor.HealthState && equalReasons(reasons, sensor.HealthReasons) {
+		return nil
+	}
+
+	transition, ok, err := s.repo.UpdateSensorHealth(sensor.ID, sensor.HealthState, state, reasons)
+	if err != nil || !ok || transition == nil {
+		return err
+	}
+
+	log.Printf("⏰ Sensor %s health changed from %s to %s", sensor.ID, transition.FromState, transition.ToState)
+
+	event := alertEvent(transition.FromState, transition.ToState)
+	if event == "" {
+		return nil
+	}
+	alert := models.HealthAlert{
+		SensorID:      sensor.ID,
+		SensorName:    sensor.Name,
+		PreviousState: transition.FromState,
+		State:         transition.ToState,
+		Reasons:       transition.Reasons,
+		OccurredAt:    transition.CreatedAt,
+	}
+	return s.webhooks.Publish(sensor.TenantID, sensor.ID, event, alert)
+}
+
+// alertEvent returns the webhook event announcing a transition, or "" when
+// the transition is not alerted on: a new sensor reporting in for the first
+// time is not news
+func alertEvent(from, to string) string {
+	switch to {
+	case models.HealthStateDegraded:
+		return models.EventSensorDegraded
+	case models.HealthStateOffline:
+		return models.EventSensorOffline
+	case models.HealthStateHealthy:
+		if from == models.HealthStateDegraded || from == models.HealthStateOffline {
+			return models.EventSensorRecovered
+		}
+	}
+	return ""
+}
+
+func equalReasons(a, b []models.HealthReason) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the handlers for diagnostic packet captures: operators
+// holding the diagnostics permission request and download them, sensors
+// upload the sealed pcaps.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/captures"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PermissionDiagnostics is the tenant permission needed to request and
+// download diagnostic captures
+const PermissionDiagnostics = "sensors.diagnostics"
+
+// HeaderCapturePackets carries the number of packets in an uploaded capture
+const HeaderCapturePackets = "X-Capture-Packets"
+
+// RequestCaptureRequest represents a request for a diagnostic capture
+type RequestCaptureRequest struct {
+	Interface       string `json:"interface"` // default: the sensor's first capture interface
+	BPFFilter       string `json:"bpf_filter" binding:"required"`
+	DurationSeconds int    `json:"duration_seconds"` // default 30, at most 300
+	MaxBytes        int64  `json:"max_bytes"`        // default 10 MiB, at most 50 MiB
+	Reason          string `json:"reason"`
+}
+
+// RequestCapture asks a sensor for a diagnostic capture. The sensor takes it
+// when it receives the command and uploads it once done.
+func (h *Handler) RequestCapture(c *gin.Context) {
+	var req RequestCaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+	if sensor.Decommissioned() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sensor has been decommissioned"})
+		return
+	}
+
+	capture, err := h.captures.Request(sensor.TenantID, sensor.ID, captures.Request{
+		Interface:   req.Interface,
+		BPFFilter:   req.BPFFilter,
+		Duration:    time.Duration(req.DurationSeconds) * time.Second,
+		MaxBytes:    req.MaxBytes,
+		Reason:      req.Reason,
+		RequestedBy: c.GetString("user_id"),
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, capture)
+	case errors.Is(err, captures.ErrNoKey):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Diagnostic captures are not configured"})
+	case errors.Is(err, captures.ErrInvalidRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.respondRepoError(c, err, "Sensor not found")
+	}
+}
+
+// ListCaptures returns the tenant's diagnostic captures, optionally only
+// those of one sensor
+func (h *Handler) ListCaptures(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	sensorID := c.Query("sensor_id")
+	if sensorID != "" {
+		if _, err := uuid.Parse(sensorID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor ID"})
+			return
+		}
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	list, total, err := h.repo.ListDiagnosticCaptures(tenantID, sensorID, page, pageSize)
+	if err != nil {
+		h.respondRepoError(c, err, "Captures not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"captures": list,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		},
+	})
+}
+
+// GetCapture returns a diagnostic capture
+func (h *Handler) GetCapture(c *gin.Context) {
+	tenantID, captureID, ok := h.captureParams(c)
+	if !ok {
+		return
+	}
+	capture, err := h.repo.GetDiagnosticCapture(tenantID, captureID)
+	if err != nil {
+		h.respondRepoError(c, err, "Capture not found")
+		return
+	}
+	c.JSON(http.StatusOK, capture)
+}
+
+// DownloadCapture returns the pcap of an uploaded diagnostic capture
+func (h *Handler) DownloadCapture(c *gin.Context) {
+	tenantID, captureID, ok := h.captureParams(c)
+	if !ok {
+		return
+	}
+
+	capture, pcap, err := h.captures.Open(tenantID, captureID)
+	switch {
+	case err == nil:
+	case errors.Is(err, captures.ErrNoKey):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Diagnostic captures are not configured"})
+		return
+	case errors.Is(err, repository.ErrNotFound) && capture != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "Capture is " + capture.Status})
+		return
+	default:
+		h.respondRepoError(c, err, "Capture not found")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "capture-"+capture.ID+".pcap"))
+	c.Data(http.StatusOK, "application/vnd.tcpdump.pcap", pcap)
+}
+
+// DeleteCapture deletes a diagnostic capture and its pcap
+func (h *Handler) DeleteCapture(c *gin.Context) {
+	tenantID, captureID, ok := h.captureParams(c)
+	if !ok {
+		return
+	}
+	if err := h.captures.Delete(tenantID, captureID); err != nil {
+		h.respondRepoError(c, err, "Capture not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UploadCapture receives the sealed pcap of a capture from the sensor it
+// was requested from
+func (h *Handler) UploadCapture(c *gin.Context) {
+	sensor := h.loadSensor(c)
+	if sensor == nil {
+		return
+	}
+	captureID := c.Param("capture_id")
+	if _, err := uuid.Parse(captureID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Capture not found"})
+		return
+	}
+	packets, _ := strconv.Atoi(c.GetHeader(HeaderCapturePackets))
+
+	capture, err := h.captures.Upload(sensor.ID, captureID, packets, c.Request.Body)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, gin.H{"id": capture.ID, "status": capture.Status, "size": capture.Size})
+	case errors.Is(err, captures.ErrNoKey):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Diagnostic captures are not configured"})
+	case errors.Is(err, captures.ErrInvalidUpload):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, captures.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Capture was uploaded already"})
+	default:
+		h.respondRepoError(c, err, "Capture not found")
+	}
+}
+
+// captureParams returns the tenant and the :capture_id path parameter. It
+// writes an error response and returns false when either is invalid.
+func (h *Handler) captureParams(c *gin.Context) (string, string, bool) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return "", "", false
+	}
+	captureID := c.Param("capture_id")
+	if _, err := uuid.Parse(captureID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Capture not found"})
+		return "", "", false
+	}
+	return tenantID, captureID, true
+}
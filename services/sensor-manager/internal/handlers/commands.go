@@ -0,0 +1,255 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the operator-facing handlers for issuing commands to
+// sensors and querying command history. Commands are queued and picked up
+// by the sensor over its outbound channel (heartbeat or long-poll).
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/captures"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/commands"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/rollouts"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/sensorconfig"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EnqueueCommandRequest represents an operator request to queue a command
+type EnqueueCommandRequest struct {
+	Type              string                 `json:"type" binding:"required"`
+	Priority          int                    `json:"priority"`
+	Payload           map[string]interface{} `json:"payload"`
+	RequiresAck       bool                   `json:"requires_ack"`
+	TTLSeconds        int                    `json:"ttl_seconds"`         // default 1 hour
+	MaxAttempts       int                    `json:"max_attempts"`        // default 3
+	AckTimeoutSeconds int                    `json:"ack_timeout_seconds"` // default 120
+}
+
+// EnqueueGroupCommandRequest represents a request to queue a command for a
+// group of sensors, selected by ID and/or tag
+type EnqueueGroupCommandRequest struct {
+	EnqueueCommandRequest
+	SensorIDs []string `json:"sensor_ids"`
+	Tag       string   `json:"tag"`
+}
+
+// spec converts the request to a command spec
+func (r *EnqueueCommandRequest) spec() commands.Spec {
+	return commands.Spec{
+		Type:        r.Type,
+		Priority:    r.Priority,
+		Payload:     r.Payload,
+		RequiresAck: r.RequiresAck,
+		TTL:         time.Duration(r.TTLSeconds) * time.Second,
+		MaxAttempts: r.MaxAttempts,
+		AckTimeout:  time.Duration(r.AckTimeoutSeconds) * time.Second,
+	}
+}
+
+// errConfigCommand is returned for operator-issued update_config commands,
+// which would bypass configuration versioning
+var errConfigCommand = errors.New("update_config is issued from configuration profiles, groups and sensor overrides")
+
+// errUpgradeCommand is returned for operator-issued upgrade commands, which
+// would bypass staged rollouts
+var errUpgradeCommand = errors.New("upgrade is issued from release rollouts")
+
+// errCaptureCommand is returned for operator-issued diagnostic_capture
+// commands, which need the capture key and permission of capture requests
+var errCaptureCommand = errors.New("diagnostic_capture is issued from capture requests")
+
+// validateOperatorSpec checks a command spec issued by an operator
+func validateOperatorSpec(spec *commands.Spec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	if spec.Type == sensorconfig.CommandType {
+		return errConfigCommand
+	}
+	if spec.Type == rollouts.CommandType {
+		return errUpgradeCommand
+	}
+	if spec.Type == captures.CommandType {
+		return errCaptureCommand
+	}
+	return nil
+}
+
+// EnqueueCommand queues a command for a sensor. A sensor with an open
+// long-poll request receives it immediately.
+func (h *Handler) EnqueueCommand(c *gin.Context) {
+	var req EnqueueCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spec := req.spec()
+	if err := validateOperatorSpec(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+	if sensor.Decommissioned() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sensor has been decommissioned"})
+		return
+	}
+
+	records, err := h.commandService.Enqueue(sensor.TenantID, []string{sensor.ID}, spec)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "queued",
+		"command": records[0],
+	})
+}
+
+// EnqueueGroupCommand queues a command for every active sensor matching the
+// given sensor IDs and/or tag. The commands share a batch ID that can be
+// used to follow the rollout in the command history.
+func (h *Handler) EnqueueGroupCommand(c *gin.Context) {
+	var req EnqueueGroupCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.SensorIDs) == 0 && req.Tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sensor_ids or tag is required"})
+		return
+	}
+	for _, sensorID := range req.SensorIDs {
+		if _, err := uuid.Parse(sensorID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor ID: " + sensorID})
+			return
+		}
+	}
+
+	spec := req.spec()
+	if err := validateOperatorSpec(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	records, err := h.commandService.EnqueueGroup(tenantID, req.SensorIDs, req.Tag, spec)
+	if err != nil {
+		if errors.Is(err, commands.ErrNoTargets) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No active sensors match the target"})
+			return
+		}
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":   "queued",
+		"batch_id": records[0].BatchID,
+		"count":    len(records),
+		"commands": records,
+	})
+}
+
+// ListCommands returns the tenant's command history
+func (h *Handler) ListCommands(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	filters := commandFilters(c)
+	filters.SensorID = c.Query("sensor_id")
+	if filters.SensorID != "" {
+		if _, err := uuid.Parse(filters.SensorID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor ID"})
+			return
+		}
+	}
+	if filters.BatchID != "" {
+		if _, err := uuid.Parse(filters.BatchID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+			return
+		}
+	}
+
+	h.respondCommands(c, tenantID, filters)
+}
+
+// ListSensorCommands returns the command history of a sensor
+func (h *Handler) ListSensorCommands(c *gin.Context) {
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	filters := commandFilters(c)
+	filters.SensorID = sensor.ID
+	h.respondCommands(c, sensor.TenantID, filters)
+}
+
+// GetCommand returns a command with its full state transition history
+func (h *Handler) GetCommand(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	commandID := c.Param("command_id")
+	if _, err := uuid.Parse(commandID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Command not found"})
+		return
+	}
+
+	command, err := h.repo.GetCommand(tenantID, commandID)
+	if err != nil {
+		h.respondRepoError(c, err, "Command not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"command": command})
+}
+
+func (h *Handler) respondCommands(c *gin.Context, tenantID string, filters models.CommandFilters) {
+	records, total, err := h.repo.ListCommands(tenantID, filters)
+	if err != nil {
+		h.respondRepoError(c, err, "Commands not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"commands": records,
+		"pagination": gin.H{
+			"page":      filters.Page,
+			"page_size": filters.PageSize,
+			"total":     total,
+		},
+	})
+}
+
+// commandFilters reads the common command history query parameters
+func commandFilters(c *gin.Context) models.CommandFilters {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	return models.CommandFilters{
+		BatchID:  c.Query("batch_id"),
+		Status:   c.Query("status"),
+		Type:     c.Query("type"),
+		Page:     page,
+		PageSize: pageSize,
+	}
+}
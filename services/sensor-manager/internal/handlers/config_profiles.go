@@ -0,0 +1,578 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the operator-facing handlers for configuration
+// profiles, sensor groups and per-sensor configuration overrides. Changes
+// that affect the configuration of sensors are pushed to them right away.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/sensorconfig"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateConfigProfileRequest represents a request to create a profile
+type CreateConfigProfileRequest struct {
+	Name        string                `json:"name" binding:"required"`
+	Description string                `json:"description"`
+	Settings    models.ConfigSettings `json:"settings"`
+	ChangeNote  string                `json:"change_note"`
+}
+
+// UpdateConfigProfileRequest represents a request to change a profile. New
+// settings replace the previous ones as the next version.
+type UpdateConfigProfileRequest struct {
+	Name        *string                `json:"name"`
+	Description *string                `json:"description"`
+	Settings    *models.ConfigSettings `json:"settings"`
+	ChangeNote  string                 `json:"change_note"`
+}
+
+// SensorGroupRequest represents a request to create a sensor group
+type SensorGroupRequest struct {
+	Name           string `json:"name" binding:"required"`
+	Description    string `json:"description"`
+	ProfileID      string `json:"profile_id"`
+	ProfileVersion *int   `json:"profile_version"` // pinned version; unset or 0 follows the current one
+}
+
+// UpdateSensorGroupRequest represents a request to change a sensor group.
+// An empty profile_id removes the profile and a profile_version of 0
+// unpins the group.
+type UpdateSensorGroupRequest struct {
+	Name           *string `json:"name"`
+	Description    *string `json:"description"`
+	ProfileID      *string `json:"profile_id"`
+	ProfileVersion *int    `json:"profile_version"`
+}
+
+// SetSensorGroupRequest represents a request to move a sensor into a group;
+// an empty group_id removes it from its group
+type SetSensorGroupRequest struct {
+	GroupID string `json:"group_id"`
+}
+
+// SetConfigOverridesRequest represents a request to replace a sensor's
+// configuration overrides
+type SetConfigOverridesRequest struct {
+	Overrides models.ConfigSettings `json:"overrides"`
+}
+
+// CreateConfigProfile creates a configuration profile at version 1
+func (h *Handler) CreateConfigProfile(c *gin.Context) {
+	var req CreateConfigProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must not be empty"})
+		return
+	}
+	if err := h.configService.ValidateProfile(&req.Settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	profile := &models.ConfigProfile{
+		TenantID:    tenantID,
+		Name:        name,
+		Description: req.Description,
+		Settings:    req.Settings,
+		CreatedBy:   c.GetString("user_id"),
+	}
+	if err := h.repo.CreateConfigProfile(profile, req.ChangeNote); err != nil {
+		h.respondRepoError(c, err, "Configuration profile not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profile": profile})
+}
+
+// ListConfigProfiles returns the tenant's configuration profiles
+func (h *Handler) ListConfigProfiles(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	profiles, err := h.repo.ListConfigProfiles(tenantID)
+	if err != nil {
+		h.respondRepoError(c, err, "Configuration profiles not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+// GetConfigProfile returns a configuration profile with its current settings
+func (h *Handler) GetConfigProfile(c *gin.Context) {
+	tenantID, profileID, ok := h.profileParams(c)
+	if !ok {
+		return
+	}
+
+	profile, err := h.repo.GetConfigProfile(tenantID, profileID)
+	if err != nil {
+		h.respondRepoError(c, err, "Configuration profile not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profile": profile})
+}
+
+// UpdateConfigProfile renames or describes a profile and, when settings are
+// given, stores them as a new version. Sensors in groups that follow the
+// profile's current version receive the new configuration.
+func (h *Handler) UpdateConfigProfile(c *gin.Context) {
+	var req UpdateConfigProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must not be empty"})
+			return
+		}
+		req.Name = &name
+	}
+	if req.Settings != nil {
+		if err := h.configService.ValidateProfile(req.Settings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tenantID, profileID, ok := h.profileParams(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetString("user_id")
+	profile, err := h.repo.UpdateConfigProfile(tenantID, profileID, req.Name, req.Description, req.Settings, req.ChangeNote, userID)
+	if err != nil {
+		h.respondRepoError(c, err, "Configuration profile not found")
+		return
+	}
+
+	pushed := 0
+	if req.Settings != nil {
+		if pushed, err = h.configService.PushProfile(profile.ID, userID); err != nil {
+			log.Printf("❌ Failed to push profile %s version %d: %v", profile.ID, profile.CurrentVersion, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profile": profile, "sensors_updated": pushed})
+}
+
+// DeleteConfigProfile deletes a profile that no group uses
+func (h *Handler) DeleteConfigProfile(c *gin.Context) {
+	tenantID, profileID, ok := h.profileParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteConfigProfile(tenantID, profileID); err != nil {
+		if errors.Is(err, repository.ErrInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Configuration profile is assigned to sensor groups"})
+			return
+		}
+		h.respondRepoError(c, err, "Configuration profile not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Configuration profile deleted"})
+}
+
+// ListConfigProfileVersions returns the version history of a profile
+func (h *Handler) ListConfigProfileVersions(c *gin.Context) {
+	tenantID, profileID, ok := h.profileParams(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.repo.ListConfigProfileVersions(tenantID, profileID)
+	if err != nil {
+		h.respondRepoError(c, err, "Configuration profile not found")
+		return
+	}
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration profile not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// GetConfigProfileVersion returns one version of a profile
+func (h *Handler) GetConfigProfileVersion(c *gin.Context) {
+	tenantID, profileID, ok := h.profileParams(c)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Profile version not found"})
+		return
+	}
+
+	profileVersion, err := h.repo.GetConfigProfileVersion(tenantID, profileID, version)
+	if err != nil {
+		h.respondRepoError(c, err, "Profile version not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"version": profileVersion})
+}
+
+// CreateSensorGroup creates a sensor group, optionally with a profile
+func (h *Handler) CreateSensorGroup(c *gin.Context) {
+	var req SensorGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must not be empty"})
+		return
+	}
+
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	group := &models.SensorGroup{
+		TenantID:       tenantID,
+		Name:           name,
+		Description:    req.Description,
+		ProfileID:      req.ProfileID,
+		ProfileVersion: req.ProfileVersion,
+	}
+	if !h.checkGroupProfile(c, group) {
+		return
+	}
+
+	if err := h.repo.CreateSensorGroup(group); err != nil {
+		h.respondRepoError(c, err, "Sensor group not found")
+		return
+	}
+
+	created, err := h.repo.GetSensorGroup(tenantID, group.ID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor group not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"group": created})
+}
+
+// ListSensorGroups returns the tenant's sensor groups
+func (h *Handler) ListSensorGroups(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	groups, err := h.repo.ListSensorGroups(tenantID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor groups not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+// GetSensorGroup returns a sensor group
+func (h *Handler) GetSensorGroup(c *gin.Context) {
+	group := h.loadSensorGroup(c)
+	if group == nil {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"group": group})
+}
+
+// UpdateSensorGroup changes a group's name, description, profile or pinned
+// version. A changed profile or version is pushed to the group's sensors.
+func (h *Handler) UpdateSensorGroup(c *gin.Context) {
+	var req UpdateSensorGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group := h.loadSensorGroup(c)
+	if group == nil {
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must not be empty"})
+			return
+		}
+		group.Name = name
+	}
+	if req.Description != nil {
+		group.Description = *req.Description
+	}
+	if req.ProfileID != nil && *req.ProfileID != group.ProfileID {
+		// A pin refers to a version of the previous profile
+		group.ProfileID = *req.ProfileID
+		group.ProfileVersion = nil
+	}
+	if req.ProfileVersion != nil {
+		group.ProfileVersion = req.ProfileVersion
+	}
+	if !h.checkGroupProfile(c, group) {
+		return
+	}
+
+	if err := h.repo.UpdateSensorGroup(group); err != nil {
+		h.respondRepoError(c, err, "Sensor group not found")
+		return
+	}
+
+	userID := c.GetString("user_id")
+	pushed, err := h.configService.PushGroup(group.ID, userID)
+	if err != nil {
+		log.Printf("❌ Failed to push configuration of group %s: %v", group.ID, err)
+	}
+
+	updated, err := h.repo.GetSensorGroup(group.TenantID, group.ID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor group not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"group": updated, "sensors_updated": pushed})
+}
+
+// DeleteSensorGroup deletes a group. Its sensors fall back to their
+// built-in defaults and overrides, which is pushed to them.
+func (h *Handler) DeleteSensorGroup(c *gin.Context) {
+	group := h.loadSensorGroup(c)
+	if group == nil {
+		return
+	}
+
+	sensorIDs, err := h.repo.ListGroupSensorIDs(group.ID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor group not found")
+		return
+	}
+	if err := h.repo.DeleteSensorGroup(group.TenantID, group.ID); err != nil {
+		h.respondRepoError(c, err, "Sensor group not found")
+		return
+	}
+
+	pushed, err := h.configService.Push(sensorIDs, c.GetString("user_id"))
+	if err != nil {
+		log.Printf("❌ Failed to push configuration after deleting group %s: %v", group.ID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Sensor group deleted", "sensors_updated": pushed})
+}
+
+// SetSensorGroup moves a sensor into a group, or out of its group, and
+// pushes the resulting configuration
+func (h *Handler) SetSensorGroup(c *gin.Context) {
+	var req SetSensorGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.GroupID != "" {
+		if _, err := uuid.Parse(req.GroupID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_id"})
+			return
+		}
+	}
+
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	updated, err := h.repo.SetSensorGroup(sensor.TenantID, sensor.ID, req.GroupID)
+	if err != nil {
+		// The update matches nothing when the group is not the tenant's
+		h.respondRepoError(c, err, "Sensor or sensor group not found")
+		return
+	}
+
+	h.respondWithPushedSensor(c, updated)
+}
+
+// SetSensorConfigOverrides replaces the settings a sensor overrides on top
+// of its group's profile and pushes the resulting configuration
+func (h *Handler) SetSensorConfigOverrides(c *gin.Context) {
+	var req SetConfigOverridesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := sensorconfig.Validate(&req.Overrides); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	// Reject overrides that conflict with the layers below them before
+	// storing anything
+	sensor.ConfigOverrides = req.Overrides
+	if _, err := h.configService.Resolve(sensor); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.repo.SetSensorConfigOverrides(sensor.TenantID, sensor.ID, req.Overrides)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+
+	h.respondWithPushedSensor(c, updated)
+}
+
+// GetSensorConfigState returns a sensor's configuration layers, the desired
+// configuration and whether the sensor has applied it
+func (h *Handler) GetSensorConfigState(c *gin.Context) {
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	desired, err := h.configService.Resolve(sensor)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	desired.ConfigVersion = sensor.ConfigVersion
+
+	c.JSON(http.StatusOK, gin.H{"config": models.SensorConfigState{
+		SensorID:             sensor.ID,
+		GroupID:              sensor.GroupID,
+		Overrides:            sensor.ConfigOverrides,
+		Desired:              desired,
+		AppliedConfigVersion: sensor.AppliedVersion,
+		ConfigAppliedAt:      sensor.ConfigAppliedAt,
+		InSync:               sensor.AppliedVersion != nil && *sensor.AppliedVersion >= sensor.ConfigVersion,
+	}})
+}
+
+// respondWithPushedSensor pushes a changed sensor's configuration and
+// responds with the sensor
+func (h *Handler) respondWithPushedSensor(c *gin.Context, sensor *models.RegisteredSensor) {
+	pushed := 0
+	if !sensor.Decommissioned() {
+		var err error
+		if pushed, err = h.configService.Push([]string{sensor.ID}, c.GetString("user_id")); err != nil {
+			log.Printf("❌ Failed to push configuration to sensor %s: %v", sensor.ID, err)
+		}
+	}
+
+	// Reload to include the new config version
+	updated, err := h.repo.GetTenantSensor(sensor.TenantID, sensor.ID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sensor": updated, "config_pushed": pushed > 0})
+}
+
+// checkGroupProfile verifies that a group's profile and pinned version
+// belong to its tenant. A pinned version of 0 is cleared. It writes an
+// error response and returns false when they do not.
+func (h *Handler) checkGroupProfile(c *gin.Context, group *models.SensorGroup) bool {
+	if group.ProfileVersion != nil && *group.ProfileVersion == 0 {
+		group.ProfileVersion = nil
+	}
+
+	if group.ProfileID == "" {
+		if group.ProfileVersion != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "profile_version requires a profile_id"})
+			return false
+		}
+		return true
+	}
+	if _, err := uuid.Parse(group.ProfileID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile_id"})
+		return false
+	}
+
+	var err error
+	if group.ProfileVersion != nil {
+		_, err = h.repo.GetConfigProfileVersion(group.TenantID, group.ProfileID, *group.ProfileVersion)
+	} else {
+		_, err = h.repo.GetConfigProfile(group.TenantID, group.ProfileID)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		message := "Configuration profile not found"
+		if group.ProfileVersion != nil {
+			message = fmt.Sprintf("Configuration profile version %d not found", *group.ProfileVersion)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return false
+	}
+	if err != nil {
+		h.respondRepoError(c, err, "Configuration profile not found")
+		return false
+	}
+	return true
+}
+
+// profileParams returns the tenant and the :profile_id path parameter. It
+// writes an error response and returns false when either is invalid.
+func (h *Handler) profileParams(c *gin.Context) (string, string, bool) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return "", "", false
+	}
+
+	profileID := c.Param("profile_id")
+	if _, err := uuid.Parse(profileID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration profile not found"})
+		return "", "", false
+	}
+	return tenantID, profileID, true
+}
+
+// loadSensorGroup loads the tenant's group named by the :group_id path
+// parameter. It writes an error response and returns nil when not found.
+func (h *Handler) loadSensorGroup(c *gin.Context) *models.SensorGroup {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return nil
+	}
+
+	groupID := c.Param("group_id")
+	if _, err := uuid.Parse(groupID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor group not found"})
+		return nil
+	}
+
+	group, err := h.repo.GetSensorGroup(tenantID, groupID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor group not found")
+		return nil
+	}
+	return group
+}
@@ -0,0 +1,201 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the operator-facing fleet management handlers for
+// listing, inspecting, renaming, tagging, decommissioning and deleting
+// registered sensors.
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UpdateSensorRequest represents a request to rename or describe a sensor
+type UpdateSensorRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// SetSensorTagsRequest represents a request to replace a sensor's tags
+type SetSensorTagsRequest struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+// ListSensors returns the tenant's registered sensors
+func (h *Handler) ListSensors(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	filters := models.SensorFilters{
+		Status:                c.Query("status"),
+		Profile:               c.Query("profile"),
+		Tag:                   c.Query("tag"),
+		GroupID:               c.Query("group_id"),
+		Search:                c.Query("search"),
+		IncludeDecommissioned: c.Query("include_decommissioned") == "true",
+		Page:                  page,
+		PageSize:              pageSize,
+	}
+
+	sensors, total, err := h.repo.ListSensors(tenantID, filters)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensors not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sensors": sensors,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		},
+	})
+}
+
+// GetSensor returns a single registered sensor
+func (h *Handler) GetSensor(c *gin.Context) {
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sensor": sensor})
+}
+
+// UpdateSensor renames a sensor and/or changes its description
+func (h *Handler) UpdateSensor(c *gin.Context) {
+	var req UpdateSensorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must not be empty"})
+			return
+		}
+		req.Name = &name
+	}
+
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	updated, err := h.repo.UpdateSensorDetails(sensor.TenantID, sensor.ID, req.Name, req.Description)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sensor": updated})
+}
+
+// SetSensorTags replaces the tags of a sensor
+func (h *Handler) SetSensorTags(c *gin.Context) {
+	var req SetSensorTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	updated, err := h.repo.SetSensorTags(sensor.TenantID, sensor.ID, normalizeTags(req.Tags))
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sensor": updated})
+}
+
+// DecommissionSensor retires a sensor. Its record and history are kept, but
+// its certificates are revoked and it can no longer heartbeat, poll or
+// submit data.
+func (h *Handler) DecommissionSensor(c *gin.Context) {
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	updated, err := h.repo.DecommissionSensor(sensor.TenantID, sensor.ID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+
+	h.revokeAll(sensor.TenantID, sensor.ID, revocationCessationOfOperation)
+
+	// Release any long-poll the sensor still holds open
+	h.commandService.Release(sensor.ID)
+
+	c.JSON(http.StatusOK, gin.H{"sensor": updated})
+}
+
+// DeleteSensor removes a sensor from the fleet
+func (h *Handler) DeleteSensor(c *gin.Context) {
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	if err := h.repo.DeleteSensor(sensor.TenantID, sensor.ID); err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+
+	h.revokeAll(sensor.TenantID, sensor.ID, revocationCessationOfOperation)
+	h.commandService.Release(sensor.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sensor deleted successfully"})
+}
+
+// loadTenantSensor loads the sensor named by :sensor_id for an operator
+// endpoint, scoped to the current tenant. It writes an error response and
+// returns nil when the sensor cannot be found.
+func (h *Handler) loadTenantSensor(c *gin.Context) *models.RegisteredSensor {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return nil
+	}
+
+	sensorID := c.Param("sensor_id")
+	if _, err := uuid.Parse(sensorID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return nil
+	}
+
+	sensor, err := h.repo.GetTenantSensor(tenantID, sensorID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return nil
+	}
+	return sensor
+}
+
+// normalizeTags trims, de-duplicates and drops empty tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
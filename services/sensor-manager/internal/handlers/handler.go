@@ -1,17 +1,103 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/captures"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/commands"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/config"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/ingest"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/pki"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/releases"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/rollouts"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/sensorconfig"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/webhooks"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-// Handler contains all the handler functions
+// Handler contains all the handler functions. It holds no mutable state of
+// its own, so a single instance is safe to share across requests.
 type Handler struct {
-	// Add any dependencies here (database, services, etc.)
+	config         *config.Config
+	repo           *repository.Repository
+	pki            *pki.Manager
+	commandService *commands.Service
+	ingestService  *ingest.Service
+	configService  *sensorconfig.Service
+	webhooks       *webhooks.Dispatcher
+	releases       *releases.Catalog
+	rollouts       *rollouts.Service
+	captures       *captures.Service
 }
 
 // NewHandler creates a new handler instance
-func NewHandler() *Handler {
-	return &Handler{}
+func NewHandler(cfg *config.Config, repo *repository.Repository, caManager *pki.Manager, commandService *commands.Service, ingestService *ingest.Service, configService *sensorconfig.Service, dispatcher *webhooks.Dispatcher, catalog *releases.Catalog, rolloutService *rollouts.Service, captureService *captures.Service) *Handler {
+	return &Handler{
+		config:         cfg,
+		repo:           repo,
+		pki:            caManager,
+		commandService: commandService,
+		ingestService:  ingestService,
+		configService:  configService,
+		webhooks:       dispatcher,
+		releases:       catalog,
+		rollouts:       rolloutService,
+		captures:       captureService,
+	}
+}
+
+// tenantID returns the tenant of the authenticated user, as set by
+// TenantAuth. It writes an error response and returns false when the request
+// carries no tenant.
+func (h *Handler) tenantID(c *gin.Context) (string, bool) {
+	tenantID := c.GetString("tenant_id")
+	if tenantID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Tenant authentication required"})
+		return "", false
+	}
+	return tenantID, true
+}
+
+// loadSensor loads the sensor named by the :sensor_id path parameter for a
+// sensor-facing endpoint. Unknown sensors get 404 and decommissioned sensors
+// 403; in both cases an error response is written and nil returned.
+func (h *Handler) loadSensor(c *gin.Context) *models.RegisteredSensor {
+	sensorID := c.Param("sensor_id")
+	if _, err := uuid.Parse(sensorID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sensor not found"})
+		return nil
+	}
+
+	sensor, err := h.repo.GetSensor(sensorID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return nil
+	}
+	if sensor.Decommissioned() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sensor has been decommissioned"})
+		return nil
+	}
+	return sensor
+}
+
+// respondRepoError maps repository errors to HTTP responses
+func (h *Handler) respondRepoError(c *gin.Context, err error, notFoundMessage string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFoundMessage})
+	case errors.Is(err, repository.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "A record with the same name already exists"})
+	case errors.Is(err, repository.ErrInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "The record is still in use"})
+	default:
+		log.Printf("❌ Database error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
 }
 
 // Health handles health check requests
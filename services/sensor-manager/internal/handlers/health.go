@@ -0,0 +1,84 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the operator-facing handlers for fleet health.
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetFleetHealth returns the health state of the tenant's sensors with the
+// reasons for each, least healthy first, and the number of sensors per
+// state
+func (h *Handler) GetFleetHealth(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	state := c.Query("state")
+	switch state {
+	case "", models.HealthStateUnknown, models.HealthStateHealthy, models.HealthStateDegraded, models.HealthStateOffline:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be one of unknown, healthy, degraded, offline"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	sensors, total, err := h.repo.ListFleetHealth(tenantID, models.FleetHealthFilters{
+		State:    state,
+		GroupID:  c.Query("group_id"),
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		h.respondRepoError(c, err, "Sensors not found")
+		return
+	}
+
+	summary, err := h.repo.CountFleetHealth(tenantID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensors not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary": summary,
+		"sensors": sensors,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		},
+	})
+}
+
+// ListHealthTransitions returns a sensor's health state changes, newest
+// first
+func (h *Handler) ListHealthTransitions(c *gin.Context) {
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	transitions, total, err := h.repo.ListHealthTransitions(sensor.TenantID, sensor.ID, page, pageSize)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transitions": transitions,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		},
+	})
+}
@@ -0,0 +1,38 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the operator-facing handlers for following discovery
+// ingestion, so rejected discoveries and failed batches can be diagnosed.
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDiscoveryBatches returns the discovery batches a sensor submitted,
+// newest first, with their ingestion status and rejected discoveries
+func (h *Handler) ListDiscoveryBatches(c *gin.Context) {
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+
+	batches, total, err := h.repo.ListDiscoveryBatches(sensor.TenantID, sensor.ID, page, pageSize)
+	if err != nil {
+		h.respondRepoError(c, err, "Discovery batches not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"batches": batches,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		},
+	})
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/netpolicy"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/pki"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxDecompressedBody bounds how large a decoded request body may grow,
+// protecting the service from decompression bombs
+const maxDecompressedBody = 64 * 1024 * 1024
+
+// DecompressBody transparently decodes request bodies that sensors send with
+// Content-Encoding gzip or zstd, so handlers can bind JSON as usual.
+func DecompressBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		var decoded io.ReadCloser
+		switch encoding {
+		case "gzip":
+			reader, err := gzip.NewReader(c.Request.Body)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid gzip body"})
+				return
+			}
+			decoded = reader
+		case "zstd":
+			reader, err := zstd.NewReader(c.Request.Body)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid zstd body"})
+				return
+			}
+			decoded = reader.IOReadCloser()
+		default:
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported content encoding: " + encoding})
+			return
+		}
+		defer decoded.Close()
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, decoded, maxDecompressedBody)
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.ContentLength = -1
+		c.Next()
+	}
+}
+
+// SensorAuth authenticates sensor API calls by client certificate. The
+// certificate must have been issued by the tenant CA to the sensor named in
+// the request path and must not be revoked. Requests without a certificate
+// are rejected when client certificates are required and passed through
+// otherwise (development without TLS).
+func (h *Handler) SensorAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		certificate, err := h.clientCertificate(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid client certificate"})
+			return
+		}
+
+		if certificate == nil {
+			if h.config.RequireClientCert {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Client certificate required"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		record, err := h.pki.VerifySensorCertificate(certificate)
+		if err != nil {
+			if errors.Is(err, pki.ErrCertificateRevoked) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Client certificate has been revoked"})
+				return
+			}
+			log.Printf("❌ Rejected sensor certificate %s: %v", pki.SerialString(certificate.SerialNumber), err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid client certificate"})
+			return
+		}
+
+		if sensorID := c.Param("sensor_id"); sensorID != "" && sensorID != record.SensorID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Client certificate does not belong to this sensor"})
+			return
+		}
+
+		c.Set("sensor_id", record.SensorID)
+		c.Set("sensor_tenant_id", record.TenantID)
+		c.Next()
+	}
+}
+
+// ReleasePublishAuth authenticates the build pipeline publishing sensor
+// releases with the configured bearer token. Publishing is disabled while no
+// token is configured. The token only lets the pipeline upload binaries;
+// sensors install nothing that is not signed with a release key.
+func (h *Handler) ReleasePublishAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.config.ReleasePublishToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Release publishing is disabled"})
+			return
+		}
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.config.ReleasePublishToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid publish token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// TenantAuth authenticates operator API calls with an access token issued by
+// the auth service. The token's tenant scopes every request, so keys, sensors
+// and commands of other tenants are never visible.
+func (h *Handler) TenantAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" || tokenString == c.GetHeader("Authorization") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
+			return
+		}
+
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			return []byte(h.config.JWTSecret), nil
+		}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		// Refresh tokens only buy new access tokens
+		if tokenType, _ := claims["type"].(string); tokenType != "" && tokenType != "access" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Access token required"})
+			return
+		}
+
+		userID, _ := claims["user_id"].(string)
+		tenantID, _ := claims["tenant_id"].(string)
+		if _, err := uuid.Parse(userID); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+			return
+		}
+		if _, err := uuid.Parse(tenantID); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Tenant ID not found in token"})
+			return
+		}
+
+		role, _ := claims["role"].(string)
+		c.Set("user_id", userID)
+		c.Set("tenant_id", tenantID)
+		c.Set("user_role", role)
+		c.Next()
+	}
+}
+
+// Tenant permissions operator routes require
+const (
+	PermissionSensorsCreate  = "sensors.create"  // issue registration keys
+	PermissionSensorsUpdate  = "sensors.update"  // edit sensors and revoke registration keys
+	PermissionSensorsDelete  = "sensors.delete"  // decommission and delete sensors and keys
+	PermissionSensorsManage  = "sensors.manage"  // configure the fleet and command sensors
+	PermissionSettingsUpdate = "settings.update" // change registration settings and webhooks
+)
+
+// RequirePermission rejects operator API calls of users without the given
+// tenant permission. It runs after TenantAuth.
+func (h *Handler) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, err := h.repo.UserHasPermission(c.GetString("user_id"), c.GetString("tenant_id"), permission)
+		if err != nil {
+			log.Printf("❌ Permission check failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		if !granted {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Permission " + permission + " required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientCertificate returns the client certificate of the request, either
+// from the TLS connection or, behind a TLS-terminating proxy, from the
+// configured header carrying the URL-escaped PEM certificate. The header is
+// only honored when the peer is one of the trusted proxies; anyone else
+// could put any certificate in it.
+func (h *Handler) clientCertificate(c *gin.Context) (*x509.Certificate, error) {
+	if c.Request.TLS != nil && len(c.Request.TLS.PeerCertificates) > 0 {
+		return c.Request.TLS.PeerCertificates[0], nil
+	}
+
+	if h.config.ClientCertHeader == "" {
+		return nil, nil
+	}
+	value := c.GetHeader(h.config.ClientCertHeader)
+	if value == "" {
+		return nil, nil
+	}
+	peer, err := netip.ParseAddrPort(c.Request.RemoteAddr)
+	if err != nil || !netpolicy.Contains(h.trustedProxies(), peer.Addr()) {
+		return nil, nil
+	}
+
+	unescaped, err := url.QueryUnescape(value)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(unescaped))
+	if block == nil {
+		return nil, errors.New("client certificate header is not PEM")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
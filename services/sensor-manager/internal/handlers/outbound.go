@@ -5,10 +5,19 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/ingest"
 	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // Heartbeat handles sensor heartbeat and returns commands (outbound-only).
@@ -29,88 +38,201 @@ func (h *Handler) Heartbeat(c *gin.Context) {
 		return
 	}
 
-	// TODO: Update sensor health in database
-	// TODO: Check for pending commands for this sensor
-	// TODO: Generate commands based on sensor state
+	if !h.recordHealth(c, &health) {
+		return
+	}
 
-	// For now, return empty commands
-	commands := models.SensorCommands{
-		SensorID: sensorID,
-		Commands: []models.Command{},
+	// Deliver any commands queued since the last heartbeat
+	pending, err := h.commandService.Deliver(sensorID)
+	if err != nil {
+		log.Printf("❌ Failed to deliver commands to sensor %s: %v", sensorID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load commands"})
+		return
 	}
 
-	c.JSON(http.StatusOK, commands)
+	c.JSON(http.StatusOK, models.SensorCommands{
+		SensorID: sensorID,
+		Commands: pending,
+	})
 }
 
+// maxPollWait caps how long a long-poll request is held open. It stays well
+// below typical proxy and load balancer idle timeouts.
+const maxPollWait = 60 * time.Second
+
+var errInvalidWait = errors.New("wait must be a duration such as 30s or a number of seconds")
+
 // PollCommands handles sensor polling for commands (outbound-only).
 // Sensors can poll this endpoint to check for pending commands without
-// requiring the control plane to initiate connections.
+// requiring the control plane to initiate connections. With ?wait=30s (or
+// ?wait=30) the request is held open until a command is queued or the wait
+// elapses, so commands reach the sensor within seconds of being issued.
 func (h *Handler) PollCommands(c *gin.Context) {
+	if h.loadSensor(c) == nil {
+		return
+	}
+
 	sensorID := c.Param("sensor_id")
 
-	// TODO: Retrieve pending commands for this sensor from database
-	// TODO: Mark commands as delivered
-	// TODO: Generate new commands based on sensor state
+	wait, err := parsePollWait(c.Query("wait"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pending, err := h.commandService.Wait(c.Request.Context(), sensorID, wait)
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			// Sensor went away; anything queued stays for the next poll
+			return
+		}
+		log.Printf("❌ Failed to deliver commands to sensor %s: %v", sensorID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load commands"})
+		return
+	}
 
-	// For now, return empty commands
-	commands := models.SensorCommands{
+	c.JSON(http.StatusOK, models.SensorCommands{
 		SensorID: sensorID,
-		Commands: []models.Command{},
+		Commands: pending,
+	})
+}
+
+// parsePollWait parses the long-poll wait parameter as a duration ("30s") or
+// a number of seconds ("30"), capped at maxPollWait
+func parsePollWait(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, errInvalidWait
+		}
+		wait = time.Duration(seconds) * time.Second
 	}
 
-	c.JSON(http.StatusOK, commands)
+	if wait < 0 {
+		return 0, errInvalidWait
+	}
+	if wait > maxPollWait {
+		wait = maxPollWait
+	}
+	return wait, nil
 }
 
-// AcknowledgeCommand handles command acknowledgments from sensors.
-// This allows sensors to confirm they have received and processed commands,
-// enabling proper command lifecycle management.
+// AcknowledgeCommand handles command acknowledgments and results from
+// sensors. A sensor reports "acknowledged" when it receives a command and
+// "succeeded" or "failed" with a result once it has executed it, enabling
+// proper command lifecycle management.
 func (h *Handler) AcknowledgeCommand(c *gin.Context) {
+	if h.loadSensor(c) == nil {
+		return
+	}
+
 	sensorID := c.Param("sensor_id")
+	commandID := c.Param("command_id")
 
-	var response models.CommandResponse
-	if err := c.ShouldBindJSON(&response); err != nil {
+	var result models.CommandResult
+	if err := c.ShouldBindJSON(&result); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Validate sensor ID
-	if response.SensorID != sensorID {
+	if result.SensorID != sensorID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Sensor ID mismatch"})
 		return
 	}
+	if result.CommandID != "" && result.CommandID != commandID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Command ID mismatch"})
+		return
+	}
+	if _, err := uuid.Parse(commandID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Command not found"})
+		return
+	}
+	result.CommandID = commandID
+
+	command, err := h.commandService.Report(sensorID, &result)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Command cannot be reported as " + result.Status})
+			return
+		}
+		h.respondRepoError(c, err, "Command not found")
+		return
+	}
 
-	// TODO: Update command status in database
-	// TODO: Process command response
-	// TODO: Generate follow-up commands if needed
+	if err := h.configService.RecordResult(command); err != nil {
+		log.Printf("❌ Failed to record applied configuration of sensor %s: %v", sensorID, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"message": "Command acknowledgment received",
+		"status":         "success",
+		"message":        "Command acknowledgment received",
+		"command_status": command.Status,
 	})
 }
 
-// GetWebhookConfig returns webhook configuration for sensors
+// GetWebhookConfig returns the webhook events a sensor's activity feeds:
+// the union of the events subscribed to by the enabled tenant webhooks that
+// cover the sensor. Deliveries are made by the control plane, so the
+// webhooks' URLs and secrets are not handed out.
 func (h *Handler) GetWebhookConfig(c *gin.Context) {
-	sensorID := c.Param("sensor_id")
+	sensor := h.loadSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	endpoints, err := h.repo.ListSensorWebhookEndpoints(sensor.TenantID, sensor.ID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
 
-	// TODO: Load webhook configuration from database
-	// For now, return disabled webhook config
 	webhookConfig := models.WebhookConfig{
-		SensorID:   sensorID, // Use sensorID to avoid unused variable warning
-		Enabled:    false,
-		WebhookURL: "",
-		Secret:     "",
-		Events:     []string{},
-		RetryCount: 3,
-		Timeout:    30,
+		SensorID: sensor.ID,
+		Enabled:  len(endpoints) > 0,
+		Events:   []string{},
+	}
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		events := endpoint.Events
+		if len(events) == 0 {
+			// Subscribed to everything
+			events = make([]string, 0, len(models.WebhookEventTypes))
+			for event := range models.WebhookEventTypes {
+				events = append(events, event)
+			}
+		}
+		for _, event := range events {
+			if !seen[event] {
+				seen[event] = true
+				webhookConfig.Events = append(webhookConfig.Events, event)
+			}
+		}
+		if endpoint.RetryCount > webhookConfig.RetryCount {
+			webhookConfig.RetryCount = endpoint.RetryCount
+		}
+		if endpoint.Timeout > webhookConfig.Timeout {
+			webhookConfig.Timeout = endpoint.Timeout
+		}
 	}
+	sort.Strings(webhookConfig.Events)
 
 	c.JSON(http.StatusOK, webhookConfig)
 }
 
-// SubmitAirGappedExport handles air-gapped export submissions
+// SubmitAirGappedExport handles air-gapped export submissions. The exported
+// discoveries go through the same ingestion pipeline as discovery batches,
+// keyed on the export ID.
 func (h *Handler) SubmitAirGappedExport(c *gin.Context) {
-	sensorID := c.Param("sensor_id")
+	sensor := h.loadSensor(c)
+	if sensor == nil {
+		return
+	}
 
 	var export models.AirGappedExport
 	if err := c.ShouldBindJSON(&export); err != nil {
@@ -119,26 +241,44 @@ func (h *Handler) SubmitAirGappedExport(c *gin.Context) {
 	}
 
 	// Validate sensor ID
-	if export.SensorID != sensorID {
+	if export.SensorID != sensor.ID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Sensor ID mismatch"})
 		return
 	}
 
 	// TODO: Validate export signature and checksum
-	// TODO: Decrypt and process export data
-	// TODO: Store discoveries in database
+	batch := models.DiscoveryBatch{
+		SensorID:    export.SensorID,
+		Discoveries: export.Data,
+		BatchID:     export.ExportID,
+	}
+	record, created, ok := h.submitBatch(c, sensor, models.BatchSourceExport, &batch)
+	if !ok {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "success",
-		"message":   "Air-gapped export received",
-		"export_id": export.ExportID,
-		"records":   len(export.Data),
+	status, message := http.StatusAccepted, "Air-gapped export accepted for ingestion"
+	if !created {
+		status, message = http.StatusOK, "Air-gapped export already received"
+	}
+	c.JSON(status, gin.H{
+		"status":           "success",
+		"message":          message,
+		"export_id":        export.ExportID,
+		"records":          len(export.Data),
+		"ingestion_status": record.Status,
 	})
 }
 
-// SubmitDiscoveries handles submission of discovery batches from sensors
+// SubmitDiscoveries handles submission of discovery batches from sensors.
+// Batches are validated and stored for the ingestion worker, which writes
+// them to the inventory. A batch submitted again with the same batch ID (or
+// Idempotency-Key) is acknowledged without being ingested twice.
 func (h *Handler) SubmitDiscoveries(c *gin.Context) {
-	sensorID := c.Param("sensor_id")
+	sensor := h.loadSensor(c)
+	if sensor == nil {
+		return
+	}
 
 	var batch models.DiscoveryBatch
 	if err := c.ShouldBindJSON(&batch); err != nil {
@@ -147,21 +287,55 @@ func (h *Handler) SubmitDiscoveries(c *gin.Context) {
 	}
 
 	// Validate sensor ID
-	if batch.SensorID != sensorID {
+	if batch.SensorID != sensor.ID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Sensor ID mismatch"})
 		return
 	}
 
-	// TODO: Persist discoveries to database and enqueue processing jobs
+	record, created, ok := h.submitBatch(c, sensor, models.BatchSourceBatch, &batch)
+	if !ok {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":   "success",
-		"message":  "Discoveries received",
-		"count":    len(batch.Discoveries),
-		"batch_id": batch.BatchID,
+	status, message := http.StatusAccepted, "Discoveries accepted for ingestion"
+	if !created {
+		status, message = http.StatusOK, "Batch already received"
+	}
+	c.JSON(status, gin.H{
+		"status":           "success",
+		"message":          message,
+		"count":            len(batch.Discoveries),
+		"batch_id":         batch.BatchID,
+		"ingestion_status": record.Status,
 	})
 }
 
+// submitBatch validates a batch and stores it for ingestion. The
+// Idempotency-Key header stands in for a missing batch ID and must match it
+// otherwise. It writes an error response and returns false on failure.
+func (h *Handler) submitBatch(c *gin.Context, sensor *models.RegisteredSensor, source string, batch *models.DiscoveryBatch) (*models.DiscoveryBatchRecord, bool, bool) {
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		if batch.BatchID == "" {
+			batch.BatchID = key
+		} else if batch.BatchID != key {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key does not match batch ID"})
+			return nil, false, false
+		}
+	}
+
+	if err := ingest.ValidateBatch(batch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false, false
+	}
+
+	record, created, err := h.ingestService.Submit(sensor.TenantID, sensor.ID, source, batch.BatchID, batch.Discoveries)
+	if err != nil {
+		h.respondRepoError(c, err, "Batch not found")
+		return nil, false, false
+	}
+	return record, created, true
+}
+
 // ReportHealth handles legacy health reports from sensors
 func (h *Handler) ReportHealth(c *gin.Context) {
 	sensorID := c.Param("sensor_id")
@@ -177,7 +351,9 @@ func (h *Handler) ReportHealth(c *gin.Context) {
 		return
 	}
 
-	// TODO: Store health status and update last seen timestamp
+	if !h.recordHealth(c, &health) {
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -185,36 +361,75 @@ func (h *Handler) ReportHealth(c *gin.Context) {
 	})
 }
 
-// GetSensorConfig returns configuration for a sensor (legacy endpoint)
+// GetSensorConfig returns the current configuration version of a sensor
+// (legacy endpoint; configuration changes are pushed with update_config)
 func (h *Handler) GetSensorConfig(c *gin.Context) {
-	sensorID := c.Param("sensor_id")
+	sensor := h.loadSensor(c)
+	if sensor == nil {
+		return
+	}
 
-	// TODO: Load sensor-specific configuration from database
-	// For now, return a sensible default config
-	config := models.SensorConfig{
-		ControlPlaneURL:   "https://crypto-inventory.company.com",
-		ReportingInterval: 60,
-		StorageConfig: models.StorageConfig{
-			MaxStorageSize: 10 * 1024 * 1024 * 1024, // 10 GB
-			RotationSize:   512 * 1024 * 1024,       // 512 MB
-			RetentionDays:  7,
-			EncryptionKey:  "",
-		},
-		CaptureConfig: models.CaptureConfig{
-			Interfaces:       []string{"eth0"},
-			ActiveProbing:    false,
-			NetworkDiscovery: false,
-			MaxConnections:   1000,
-			TimeoutSeconds:   30,
-		},
-		Features: map[string]bool{
-			"tls_analysis":         true,
-			"ssh_analysis":         true,
-			"certificate_analysis": true,
-		},
-	}
-
-	_ = sensorID // avoid unused warning for now until tied to DB lookup
+	// Sensors registered before configuration was persisted get their
+	// resolved configuration
+	if sensor.ConfigVersion == 0 {
+		config, err := h.configService.Resolve(sensor)
+		if err != nil {
+			log.Printf("❌ Failed to resolve configuration for sensor %s: %v", sensor.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve sensor configuration"})
+			return
+		}
+		c.JSON(http.StatusOK, config)
+		return
+	}
+
+	var config models.VersionedSensorConfig
+	if err := json.Unmarshal(sensor.Configuration, &config); err != nil {
+		log.Printf("❌ Invalid stored configuration for sensor %s: %v", sensor.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid stored sensor configuration"})
+		return
+	}
 
 	c.JSON(http.StatusOK, config)
 }
+
+// recordHealth persists a health report and refreshes the sensor's last
+// heartbeat. The config version the sensor reports is reconciled with the
+// desired one. It writes an error response and returns false on failure.
+func (h *Handler) recordHealth(c *gin.Context, health *models.SensorHealth) bool {
+	sensor := h.loadSensor(c)
+	if sensor == nil {
+		return false
+	}
+
+	healthJSON, err := json.Marshal(health)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid health report"})
+		return false
+	}
+
+	// Sensors report the version they run, which changes with upgrades
+	version, _ := health.Metrics["sensor_version"].(string)
+	if err := h.repo.RecordHeartbeat(health.SensorID, sensorStatus(health.Status), version, healthJSON); err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return false
+	}
+
+	// A configuration push that went missing is re-queued here, so it is
+	// delivered with this heartbeat
+	if err := h.configService.Reconcile(sensor, health); err != nil {
+		log.Printf("❌ Failed to reconcile configuration of sensor %s: %v", sensor.ID, err)
+	}
+	return true
+}
+
+// sensorStatus maps a status reported by a sensor onto the sensor_status
+// enum. Anything a sensor reports that is not a known state counts as active,
+// since the sensor is evidently running.
+func sensorStatus(reported string) string {
+	switch reported {
+	case models.SensorStatusActive, models.SensorStatusInactive, models.SensorStatusError, models.SensorStatusMaintenance:
+		return reported
+	default:
+		return models.SensorStatusActive
+	}
+}
@@ -0,0 +1,144 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains handlers for the internal sensor CA: publishing the
+// tenant CA certificate and CRL, and listing and revoking the client
+// certificates issued to a sensor.
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RFC 5280 CRLReason codes used by sensor-manager
+const (
+	revocationUnspecified          = 0
+	revocationKeyCompromise        = 1
+	revocationSuperseded           = 4
+	revocationCessationOfOperation = 5
+)
+
+// revocationReasons maps API reason names to CRLReason codes
+var revocationReasons = map[string]int{
+	"unspecified":            revocationUnspecified,
+	"key_compromise":         revocationKeyCompromise,
+	"superseded":             revocationSuperseded,
+	"cessation_of_operation": revocationCessationOfOperation,
+}
+
+// RevokeCertificatesRequest represents a request to revoke a sensor's certificates
+type RevokeCertificatesRequest struct {
+	Reason string `json:"reason"`
+}
+
+// GetTenantCACertificate returns the PEM encoded sensor CA of a tenant
+func (h *Handler) GetTenantCACertificate(c *gin.Context) {
+	tenantID, ok := h.pkiTenantID(c)
+	if !ok {
+		return
+	}
+
+	authority, err := h.pki.Authority(tenantID)
+	if err != nil {
+		log.Printf("❌ Failed to load CA for tenant %s: %v", tenantID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load certificate authority"})
+		return
+	}
+	c.Data(http.StatusOK, "application/x-pem-file", []byte(authority.CertificatePEM))
+}
+
+// GetTenantCRL returns the DER encoded certificate revocation list of a tenant
+func (h *Handler) GetTenantCRL(c *gin.Context) {
+	tenantID, ok := h.pkiTenantID(c)
+	if !ok {
+		return
+	}
+
+	crl, err := h.pki.CRL(tenantID)
+	if err != nil {
+		log.Printf("❌ Failed to generate CRL for tenant %s: %v", tenantID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CRL"})
+		return
+	}
+	c.Data(http.StatusOK, "application/pkix-crl", crl)
+}
+
+// ListSensorCertificates returns the certificates issued to a sensor
+func (h *Handler) ListSensorCertificates(c *gin.Context) {
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	certificates, err := h.repo.ListSensorCertificates(sensor.ID)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"certificates": certificates})
+}
+
+// RevokeSensorCertificates revokes every active certificate of a sensor,
+// cutting it off from the sensor API until it is registered again
+func (h *Handler) RevokeSensorCertificates(c *gin.Context) {
+	var req RevokeCertificatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason := revocationUnspecified
+	if req.Reason != "" {
+		code, ok := revocationReasons[req.Reason]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown revocation reason: " + req.Reason})
+			return
+		}
+		reason = code
+	}
+
+	sensor := h.loadTenantSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	revoked, err := h.repo.RevokeSensorCertificates(sensor.TenantID, sensor.ID, reason)
+	if err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+// revokeAll revokes a sensor's certificates as part of another operation,
+// logging rather than failing the request on error
+func (h *Handler) revokeAll(tenantID, sensorID string, reason int) {
+	if _, err := h.repo.RevokeSensorCertificates(tenantID, sensorID, reason); err != nil {
+		log.Printf("❌ Failed to revoke certificates of sensor %s: %v", sensorID, err)
+	}
+}
+
+// pkiTenantID validates the :tenant_id path parameter of the public PKI endpoints
+func (h *Handler) pkiTenantID(c *gin.Context) (string, bool) {
+	tenantID := c.Param("tenant_id")
+	if _, err := uuid.Parse(tenantID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return "", false
+	}
+
+	// Only serve CAs that exist; never create one for an arbitrary ID
+	if _, err := h.repo.GetCertificateAuthority(tenantID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		} else {
+			h.respondRepoError(c, err, "Tenant not found")
+		}
+		return "", false
+	}
+	return tenantID, true
+}
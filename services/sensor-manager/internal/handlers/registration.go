@@ -1,20 +1,28 @@
 // Package handlers provides HTTP handlers for the sensor-manager service.
 // This file contains handlers for sensor registration and pending sensor management,
-// including registration key generation, IP validation, and mTLS certificate creation.
+// including tenant-scoped registration keys, network binding with a
+// registration audit log, and issuing mTLS client certificates from the
+// tenant's sensor CA.
 package handlers
 
 import (
 	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"crypto/x509/pkix"
+	"crypto/sha256"
 	"encoding/hex"
-	"encoding/pem"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"math/big"
+	"log"
 	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/netpolicy"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -34,115 +42,178 @@ type RegistrationRequest struct {
 
 // RegistrationResponse represents the response to a registration request
 type RegistrationResponse struct {
-	SensorID          string          `json:"sensor_id"`
-	RegistrationKey   string          `json:"registration_key"`
-	ClientCert        string          `json:"client_cert"`
-	ClientKey         string          `json:"client_key"`
-	ServerCACert      string          `json:"server_ca_cert"`
-	ControlPlaneURL   string          `json:"control_plane_url"`
-	ReportingInterval int             `json:"reporting_interval"`
-	Features          map[string]bool `json:"features"`
-	Message           string          `json:"message"`
+	SensorID          string                       `json:"sensor_id"`
+	RegistrationKey   string                       `json:"registration_key"`
+	ClientCert        string                       `json:"client_cert"`
+	ClientKey         string                       `json:"client_key"`
+	ServerCACert      string                       `json:"server_ca_cert"`
+	ControlPlaneURL   string                       `json:"control_plane_url"`
+	ReportingInterval int                          `json:"reporting_interval"`
+	Features          map[string]bool              `json:"features"`
+	Config            models.VersionedSensorConfig `json:"config"`
+	Message           string                       `json:"message"`
 }
 
-// PendingSensor represents a pending sensor registration
-type PendingSensor struct {
-	ID                string    `json:"id"`
-	RegistrationKey   string    `json:"registration_key"`
-	Name              string    `json:"name"`
-	IPAddress         string    `json:"ip_address"`
-	Tags              []string  `json:"tags"`
-	Profile           string    `json:"profile"`
-	NetworkInterfaces []string  `json:"network_interfaces"`
-	CreatedAt         time.Time `json:"created_at"`
-	ExpiresAt         time.Time `json:"expires_at"`
-	Status            string    `json:"status"` // pending, used, expired
-}
+// Reasons a registration is rejected besides address mismatches, recorded
+// in the registration audit log
+const (
+	reasonUnknownKey   = "unknown_key"
+	reasonKeyRevoked   = "key_revoked"
+	reasonKeyExpired   = "key_expired"
+	reasonKeyExhausted = "key_exhausted"
+	reasonNameConflict = "sensor_name_conflict"
+)
 
-// AdminSettings represents admin configuration
-type AdminSettings struct {
-	KeyExpirationMinutes int  `json:"key_expiration_minutes"`
-	MaxPendingSensors    int  `json:"max_pending_sensors"`
-	RequireIPValidation  bool `json:"require_ip_validation"`
-}
+// maxForwardedForLength bounds the X-Forwarded-For header kept in the audit log
+const maxForwardedForLength = 1024
 
-// In-memory storage for demonstration (in production, use database)
-var (
-	pendingSensors = make(map[string]*PendingSensor)
-	adminSettings  = AdminSettings{
-		KeyExpirationMinutes: 60,
-		MaxPendingSensors:    50,
-		RequireIPValidation:  true,
-	}
+// Bounds for registration keys
+const (
+	maxSensorsPerKey     = 10000
+	maxKeyExpiration     = 30 * 24 * time.Hour
+	minKeyExpiration     = 5 * time.Minute
+	registrationKeyBytes = 16
 )
 
-// CreatePendingSensor creates a new pending sensor registration
-// This endpoint is called from the web UI to generate a registration key
-// for a sensor that will be installed later. The key is time-limited
-// and bound to a specific IP address for security.
+// CreatePendingSensorRequest represents a request to issue a registration key
+type CreatePendingSensorRequest struct {
+	Name              string   `json:"name" binding:"required"`    // Human-readable key or sensor name
+	AllowedCIDRs      []string `json:"allowed_cidrs"`              // Networks sensors must register from
+	AllowedCIDR       string   `json:"allowed_cidr"`               // Single network, shorthand for allowed_cidrs
+	IPAddress         string   `json:"ip_address"`                 // Single address, shorthand for a host network
+	MaxSensors        int      `json:"max_sensors"`                // Sensors the key may register (default 1)
+	ExpiresInMinutes  int      `json:"expires_in_minutes"`         // Overrides the configured key lifetime
+	Tags              []string `json:"tags"`                       // Optional tags for grouping
+	Profile           string   `json:"profile" binding:"required"` // Deployment profile
+	NetworkInterfaces []string `json:"network_interfaces"`         // Interfaces to monitor
+	Description       string   `json:"description"`                // Optional description
+	GroupID           string   `json:"group_id"`                   // Group registered sensors join
+}
+
+// CreatePendingSensor issues a registration key for the caller's tenant.
+// This endpoint is called from the web UI to generate a key for sensors that
+// will be installed later. The key is time-limited, may register up to
+// max_sensors sensors and is bound to a network. Only its hash is stored, so
+// the key is returned exactly once.
 func (h *Handler) CreatePendingSensor(c *gin.Context) {
-	var req struct {
-		Name              string   `json:"name" binding:"required"`       // Human-readable sensor name
-		IPAddress         string   `json:"ip_address" binding:"required"` // IP address for validation
-		Tags              []string `json:"tags"`                          // Optional tags for grouping
-		Profile           string   `json:"profile" binding:"required"`    // Deployment profile
-		NetworkInterfaces []string `json:"network_interfaces"`            // Interfaces to monitor
-		Description       string   `json:"description"`                   // Optional description
+	var req CreatePendingSensorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	networks := req.AllowedCIDRs
+	for _, network := range []string{req.AllowedCIDR, req.IPAddress} {
+		if network != "" {
+			networks = append(networks, network)
+		}
+	}
+	allowedCIDRs, err := netpolicy.ParseNetworks(networks)
+	if err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validate IP address format
-	if net.ParseIP(req.IPAddress) == nil {
-		c.JSON(400, gin.H{"error": "Invalid IP address format"})
+	if req.MaxSensors == 0 {
+		req.MaxSensors = 1
+	}
+	if req.MaxSensors < 1 || req.MaxSensors > maxSensorsPerKey {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("max_sensors must be between 1 and %d", maxSensorsPerKey)})
+		return
+	}
+
+	settings, err := h.repo.GetAdminSettings(tenantID)
+	if err != nil {
+		h.respondRepoError(c, err, "Settings not found")
 		return
 	}
 
-	// Check if we've reached the maximum pending sensors
-	if len(pendingSensors) >= adminSettings.MaxPendingSensors {
-		c.JSON(400, gin.H{"error": "Maximum pending sensors reached"})
+	if settings.RequireIPValidation && len(allowedCIDRs) == 0 {
+		c.JSON(400, gin.H{"error": "allowed_cidrs is required when IP validation is enabled"})
 		return
 	}
 
+	if req.GroupID != "" {
+		if _, err := uuid.Parse(req.GroupID); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid group_id"})
+			return
+		}
+		if _, err := h.repo.GetSensorGroup(tenantID, req.GroupID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				c.JSON(400, gin.H{"error": "Sensor group not found"})
+				return
+			}
+			h.respondRepoError(c, err, "Sensor group not found")
+			return
+		}
+	}
+
+	lifetime := time.Duration(settings.KeyExpirationMinutes) * time.Minute
+	if req.ExpiresInMinutes != 0 {
+		lifetime = time.Duration(req.ExpiresInMinutes) * time.Minute
+		if lifetime < minKeyExpiration || lifetime > maxKeyExpiration {
+			c.JSON(400, gin.H{"error": "expires_in_minutes must be between 5 and 43200"})
+			return
+		}
+	}
+
 	// Generate unique registration key
-	keySuffix := make([]byte, 3)
-	rand.Read(keySuffix)
-	key := fmt.Sprintf("REG-tenant-123-%s-%s",
+	keySuffix := make([]byte, registrationKeyBytes)
+	if _, err := rand.Read(keySuffix); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to generate registration key"})
+		return
+	}
+	key := fmt.Sprintf("REG-%s-%s-%s",
+		tenantID[:8],
 		time.Now().Format("20060102"),
 		hex.EncodeToString(keySuffix))
 
-	// Create pending sensor
-	pendingSensor := &PendingSensor{
-		ID:                uuid.New().String(),
-		RegistrationKey:   key,
+	pendingSensor := &models.PendingSensor{
+		TenantID:          tenantID,
+		KeyPrefix:         key[:len(key)-2*registrationKeyBytes+4],
 		Name:              req.Name,
-		IPAddress:         req.IPAddress,
-		Tags:              req.Tags,
+		Description:       req.Description,
+		AllowedCIDRs:      netpolicy.Strings(allowedCIDRs),
+		Tags:              nonNilStrings(req.Tags),
 		Profile:           req.Profile,
-		NetworkInterfaces: req.NetworkInterfaces,
-		CreatedAt:         time.Now(),
-		ExpiresAt:         time.Now().Add(time.Duration(adminSettings.KeyExpirationMinutes) * time.Minute),
-		Status:            "pending",
+		NetworkInterfaces: nonNilStrings(req.NetworkInterfaces),
+		GroupID:           req.GroupID,
+		MaxSensors:        req.MaxSensors,
+		CreatedBy:         c.GetString("user_id"),
+		ExpiresAt:         time.Now().Add(lifetime),
 	}
 
-	pendingSensors[key] = pendingSensor
+	// The limit check and insert happen atomically in the repository
+	if err := h.repo.CreatePendingSensor(pendingSensor, hashRegistrationKey(key), settings.MaxPendingSensors); err != nil {
+		if errors.Is(err, repository.ErrLimitReached) {
+			c.JSON(400, gin.H{"error": "Maximum pending sensors reached"})
+			return
+		}
+		h.respondRepoError(c, err, "Pending sensor not found")
+		return
+	}
+	pendingSensor.RegistrationKey = key
 
 	c.JSON(200, gin.H{
 		"pending_sensor": pendingSensor,
 		"installation_command": fmt.Sprintf(
-			"sudo ./install-sensor.sh --key %s --ip %s --name %s --profile %s",
-			key, req.IPAddress, req.Name, req.Profile,
+			"sudo ./install-sensor.sh --key %s --name %s --profile %s",
+			key, req.Name, req.Profile,
 		),
 	})
 }
 
 // RegisterSensor handles sensor registration with IP validation
 // This endpoint is called by the sensor during installation to register
-// with the control plane. It validates the registration key, checks IP
-// address binding, and returns mTLS certificates for secure communication.
+// with the control plane. It validates the registration key, checks that the
+// sensor registers from one of the key's networks, and returns mTLS
+// certificates for secure communication. The sensor is created in the tenant
+// that issued the key. Every attempt is recorded in the registration audit log.
 func (h *Handler) RegisterSensor(c *gin.Context) {
 	var req RegistrationRequest
 
@@ -151,118 +222,215 @@ func (h *Handler) RegisterSensor(c *gin.Context) {
 		return
 	}
 
+	attempt, clientIP := h.registrationAttempt(c, &req)
+
 	// Validate IP address format
-	if net.ParseIP(req.IPAddress) == nil {
-		c.JSON(400, gin.H{"error": "Invalid IP address format"})
+	if _, err := netpolicy.ParseAddr(req.IPAddress); err != nil {
+		h.rejectRegistration(c, attempt, netpolicy.ReasonInvalidDeclaredIP, "Invalid IP address format")
 		return
 	}
 
 	// Check if registration key exists and is valid
-	pendingSensor, exists := pendingSensors[req.RegistrationKey]
-	if !exists {
-		c.JSON(400, gin.H{"error": "Invalid or expired registration key"})
+	keyHash := hashRegistrationKey(req.RegistrationKey)
+	pendingSensor, err := h.repo.GetPendingSensorByKeyHash(keyHash)
+	if errors.Is(err, repository.ErrNotFound) {
+		h.rejectRegistration(c, attempt, reasonUnknownKey, "Invalid or expired registration key")
 		return
 	}
-
-	// Check if key has expired
-	if time.Now().After(pendingSensor.ExpiresAt) {
-		pendingSensor.Status = "expired"
-		c.JSON(400, gin.H{"error": "Registration key has expired"})
+	if err != nil {
+		h.respondRepoError(c, err, "Invalid or expired registration key")
 		return
 	}
+	attempt.TenantID = pendingSensor.TenantID
+	attempt.RegistrationKeyID = pendingSensor.ID
+	attempt.KeyPrefix = pendingSensor.KeyPrefix
+	attempt.AllowedCIDRs = pendingSensor.AllowedCIDRs
 
-	// Check if key has already been used
-	if pendingSensor.Status == "used" {
-		c.JSON(400, gin.H{"error": "Registration key has already been used"})
+	switch pendingSensor.Status {
+	case models.PendingStatusRevoked:
+		h.rejectRegistration(c, attempt, reasonKeyRevoked, "Registration key has been revoked")
 		return
-	}
-
-	// Validate IP address matches the pending sensor
-	if adminSettings.RequireIPValidation && pendingSensor.IPAddress != req.IPAddress {
-		c.JSON(400, gin.H{"error": "IP address does not match the registered IP address"})
+	case models.PendingStatusExpired:
+		h.rejectRegistration(c, attempt, reasonKeyExpired, "Registration key has expired")
+		return
+	case models.PendingStatusUsed:
+		h.rejectRegistration(c, attempt, reasonKeyExhausted, "Registration key has reached its sensor limit")
 		return
 	}
 
-	// Additional IP validation: Check if the requesting IP matches the registered IP
-	clientIP := c.ClientIP()
-	if adminSettings.RequireIPValidation && clientIP != req.IPAddress {
-		// Check if the client IP is in the same subnet as the registered IP
-		if !isIPInSameSubnet(clientIP, req.IPAddress) {
-			c.JSON(400, gin.H{"error": "Requesting IP address does not match the registered IP address"})
+	// Both the address the sensor declares and the address the request
+	// comes from must be inside one of the key's networks. A key bound to
+	// networks is always enforced; the tenant's IP validation setting only
+	// decides whether keys must be bound when they are issued.
+	if len(pendingSensor.AllowedCIDRs) > 0 {
+		allowed, err := netpolicy.ParseNetworks(pendingSensor.AllowedCIDRs)
+		if err != nil {
+			log.Printf("❌ Registration key %s has invalid networks: %v", pendingSensor.KeyPrefix, err)
+			c.JSON(500, gin.H{"error": "Failed to validate IP address"})
+			return
+		}
+		if err := netpolicy.CheckRegistration(allowed, req.IPAddress, clientIP); err != nil {
+			mismatch, _ := netpolicy.AsMismatch(err)
+			attempt.Detail = mismatch.Detail
+			h.rejectRegistration(c, attempt, mismatch.Reason,
+				"IP address is outside the networks the registration key is bound to")
 			return
 		}
 	}
 
-	// Mark key as used
-	pendingSensor.Status = "used"
+	// Tags chosen by the operator when the key was issued take precedence
+	tags := pendingSensor.Tags
+	if len(tags) == 0 {
+		tags = nonNilStrings(req.Tags)
+	}
 
-	// Generate mTLS certificates
-	caCertPEM, caKeyPEM, err := h.generateCACertificate()
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to generate CA certificate"})
-		return
+	sensor := &models.RegisteredSensor{
+		TenantID:          pendingSensor.TenantID,
+		Name:              req.Name,
+		Description:       req.Description,
+		Platform:          req.Platform,
+		Version:           req.Version,
+		Profile:           req.Profile,
+		IPAddress:         req.IPAddress,
+		NetworkInterfaces: nonNilStrings(req.NetworkInterfaces),
+		Tags:              tags,
 	}
 
-	sensorCertPEM, sensorKeyPEM, err := h.generateSensorCertificate(req.Name, caCertPEM, caKeyPEM)
+	// Issue the client certificate from the tenant CA. The sensor ID is
+	// embedded in the certificate, so it is assigned before the record exists.
+	sensor.ID = uuid.New().String()
+	issued, err := h.pki.IssueSensorCertificate(sensor.TenantID, sensor.ID, sensor.Name)
 	if err != nil {
+		log.Printf("❌ Failed to issue certificate for sensor %s: %v", req.Name, err)
 		c.JSON(500, gin.H{"error": "Failed to generate sensor certificate"})
 		return
 	}
 
-	// Create sensor configuration based on profile
-	features := getProfileFeatures(req.Profile)
-	reportingInterval := getProfileReportingInterval(req.Profile)
+	// Claim a use of the key, create the sensor record with the first
+	// version of its configuration (built from the key's group) and record
+	// its certificate atomically
+	if err := h.repo.RegisterSensor(keyHash, sensor, &issued.Record, h.configService.Initial); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrKeyUnavailable):
+			h.rejectRegistration(c, attempt, reasonKeyExhausted, "Registration key is no longer available")
+		case errors.Is(err, repository.ErrConflict):
+			h.rejectRegistration(c, attempt, reasonNameConflict, "A sensor with this name already exists")
+		default:
+			h.respondRepoError(c, err, "Invalid or expired registration key")
+		}
+		return
+	}
 
-	// Create sensor record (in production, save to database)
-	sensorID := uuid.New().String()
+	attempt.SensorID = sensor.ID
+	attempt.Outcome = models.RegistrationAccepted
+	h.recordRegistrationAttempt(attempt)
+	log.Printf("✅ Sensor %s registered as %s with key %s from %s", req.Name, sensor.ID, pendingSensor.KeyPrefix, attempt.ClientIP)
+
+	var sensorConfig models.VersionedSensorConfig
+	if err := json.Unmarshal(sensor.Configuration, &sensorConfig); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to build sensor configuration"})
+		return
+	}
 
 	response := RegistrationResponse{
-		SensorID:          sensorID,
+		SensorID:          sensor.ID,
 		RegistrationKey:   req.RegistrationKey,
-		ClientCert:        string(sensorCertPEM),
-		ClientKey:         string(sensorKeyPEM),
-		ServerCACert:      string(caCertPEM),
-		ControlPlaneURL:   "https://crypto-inventory.company.com",
-		ReportingInterval: reportingInterval,
-		Features:          features,
+		ClientCert:        issued.CertificatePEM,
+		ClientKey:         issued.PrivateKeyPEM,
+		ServerCACert:      h.serverCACertificate(),
+		ControlPlaneURL:   sensorConfig.ControlPlaneURL,
+		ReportingInterval: sensorConfig.ReportingInterval,
+		Features:          sensorConfig.Features,
+		Config:            sensorConfig,
 		Message:           "Sensor registered successfully",
 	}
 
 	c.JSON(200, response)
 }
 
-// GetPendingSensors returns all pending sensor registrations
+// GetPendingSensors returns the registration keys of the caller's tenant
 func (h *Handler) GetPendingSensors(c *gin.Context) {
-	var sensors []*PendingSensor
-	for _, sensor := range pendingSensors {
-		// Check if expired
-		if time.Now().After(sensor.ExpiresAt) && sensor.Status == "pending" {
-			sensor.Status = "expired"
-		}
-		sensors = append(sensors, sensor)
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	sensors, err := h.repo.ListPendingSensors(tenantID)
+	if err != nil {
+		h.respondRepoError(c, err, "Pending sensors not found")
+		return
 	}
 	c.JSON(200, gin.H{"pending_sensors": sensors})
 }
 
-// DeletePendingSensor deletes a pending sensor registration
+// RevokePendingSensor revokes a registration key so it cannot register any
+// further sensors
+func (h *Handler) RevokePendingSensor(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	keyID := c.Param("key")
+	if _, err := uuid.Parse(keyID); err != nil {
+		c.JSON(404, gin.H{"error": "Pending sensor not found"})
+		return
+	}
+
+	pendingSensor, err := h.repo.RevokePendingSensor(tenantID, keyID)
+	if err != nil {
+		h.respondRepoError(c, err, "Pending sensor not found")
+		return
+	}
+
+	log.Printf("🔐 Registration key %s revoked", pendingSensor.KeyPrefix)
+	c.JSON(200, gin.H{"pending_sensor": pendingSensor})
+}
+
+// DeletePendingSensor deletes a registration key
 func (h *Handler) DeletePendingSensor(c *gin.Context) {
-	key := c.Param("key")
-	if _, exists := pendingSensors[key]; !exists {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	keyID := c.Param("key")
+	if _, err := uuid.Parse(keyID); err != nil {
 		c.JSON(404, gin.H{"error": "Pending sensor not found"})
 		return
 	}
-	delete(pendingSensors, key)
+
+	if err := h.repo.DeletePendingSensor(tenantID, keyID); err != nil {
+		h.respondRepoError(c, err, "Pending sensor not found")
+		return
+	}
 	c.JSON(200, gin.H{"message": "Pending sensor deleted successfully"})
 }
 
-// GetAdminSettings returns current admin settings
+// GetAdminSettings returns the registration settings of the caller's tenant
 func (h *Handler) GetAdminSettings(c *gin.Context) {
-	c.JSON(200, adminSettings)
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	settings, err := h.repo.GetAdminSettings(tenantID)
+	if err != nil {
+		h.respondRepoError(c, err, "Settings not found")
+		return
+	}
+	c.JSON(200, settings)
 }
 
-// UpdateAdminSettings updates admin settings
+// UpdateAdminSettings updates the registration settings of the caller's
+// tenant
 func (h *Handler) UpdateAdminSettings(c *gin.Context) {
-	var newSettings AdminSettings
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	var newSettings models.AdminSettings
 	if err := c.ShouldBindJSON(&newSettings); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
@@ -279,167 +447,144 @@ func (h *Handler) UpdateAdminSettings(c *gin.Context) {
 		return
 	}
 
-	adminSettings = newSettings
+	if err := h.repo.UpdateAdminSettings(tenantID, c.GetString("user_id"), newSettings); err != nil {
+		h.respondRepoError(c, err, "Settings not found")
+		return
+	}
 	c.JSON(200, gin.H{"message": "Admin settings updated successfully"})
 }
 
-// Helper functions
-
-func isIPInSameSubnet(ip1, ip2 string) bool {
-	// Simple check - in production, implement proper subnet validation
-	// For now, just check if they're the same
-	return ip1 == ip2
-}
-
-func getProfileFeatures(profile string) map[string]bool {
-	features := map[string]bool{
-		"tls_analysis":         true,
-		"ssh_analysis":         true,
-		"certificate_analysis": true,
-		"active_probing":       false,
-		"network_discovery":    false,
-		"air_gapped_export":    false,
-	}
-
-	switch profile {
-	case "datacenter_host":
-		features["active_probing"] = true
-		features["network_discovery"] = true
-	case "cloud_instance":
-		features["active_probing"] = true
-	case "end_user_machine":
-		// Minimal features
-	case "air_gapped":
-		features["air_gapped_export"] = true
-		features["active_probing"] = false
-		features["network_discovery"] = false
-	}
-
-	return features
-}
-
-func getProfileReportingInterval(profile string) int {
-	switch profile {
-	case "datacenter_host":
-		return 30 // 30 seconds
-	case "cloud_instance":
-		return 60 // 1 minute
-	case "end_user_machine":
-		return 300 // 5 minutes
-	case "air_gapped":
-		return 3600 // 1 hour
-	default:
-		return 60
+// ListRegistrationAttempts returns the tenant's registration audit log,
+// newest first, optionally filtered by registration key and outcome
+func (h *Handler) ListRegistrationAttempts(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
 	}
-}
 
-// generateCACertificate generates a CA certificate for mTLS
-func (h *Handler) generateCACertificate() (string, string, error) {
-	// Generate private key
-	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Create CA certificate template
-	caTemplate := x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			Organization:  []string{"Crypto Inventory CA"},
-			Country:       []string{"US"},
-			Province:      []string{""},
-			Locality:      []string{"San Francisco"},
-			StreetAddress: []string{""},
-			PostalCode:    []string{""},
-		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0), // 10 years
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
-		BasicConstraintsValid: true,
-		IsCA:                  true,
+	filters := repository.RegistrationAttemptFilters{
+		RegistrationKeyID: c.Query("key_id"),
+		Outcome:           c.Query("outcome"),
 	}
+	if filters.RegistrationKeyID != "" {
+		if _, err := uuid.Parse(filters.RegistrationKeyID); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid key_id"})
+			return
+		}
+	}
+	if filters.Outcome != "" && filters.Outcome != models.RegistrationAccepted && filters.Outcome != models.RegistrationRejected {
+		c.JSON(400, gin.H{"error": "outcome must be accepted or rejected"})
+		return
+	}
+	filters.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filters.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "50"))
 
-	// Create CA certificate
-	caCertDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, &caKey.PublicKey, caKey)
+	attempts, total, err := h.repo.ListRegistrationAttempts(tenantID, filters)
 	if err != nil {
-		return "", "", err
+		h.respondRepoError(c, err, "Registration attempts not found")
+		return
 	}
 
-	// Encode CA certificate
-	caCertPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: caCertDER,
+	c.JSON(200, gin.H{
+		"attempts": attempts,
+		"pagination": gin.H{
+			"page":      filters.Page,
+			"page_size": filters.PageSize,
+			"total":     total,
+		},
 	})
+}
 
-	// Encode CA private key
-	caKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(caKey),
-	})
+// Helper functions
 
-	return string(caCertPEM), string(caKeyPEM), nil
+// hashRegistrationKey returns the hex SHA-256 of a registration key, which
+// is what is stored and looked up
+func hashRegistrationKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
 }
 
-// generateSensorCertificate generates a client certificate for a sensor
-func (h *Handler) generateSensorCertificate(sensorName, caCertPEM, caKeyPEM string) (string, string, error) {
-	// Parse CA certificate
-	caBlock, _ := pem.Decode([]byte(caCertPEM))
-	caCert, err := x509.ParseCertificate(caBlock.Bytes)
-	if err != nil {
-		return "", "", err
+// registrationAttempt starts the audit log entry of a registration request
+// and resolves the address the request comes from, honoring X-Forwarded-For
+// only from the configured trusted proxies
+func (h *Handler) registrationAttempt(c *gin.Context, req *RegistrationRequest) (*models.RegistrationAttempt, netip.Addr) {
+	attempt := &models.RegistrationAttempt{
+		SensorName:   req.Name,
+		DeclaredIP:   req.IPAddress,
+		ForwardedFor: strings.Join(c.Request.Header.Values("X-Forwarded-For"), ", "),
+		AllowedCIDRs: []string{},
+	}
+	if len(attempt.ForwardedFor) > maxForwardedForLength {
+		attempt.ForwardedFor = attempt.ForwardedFor[:maxForwardedForLength]
+	}
+	if host, _, err := net.SplitHostPort(c.Request.RemoteAddr); err == nil {
+		attempt.RemoteAddr = host
 	}
 
-	// Parse CA private key
-	caKeyBlock, _ := pem.Decode([]byte(caKeyPEM))
-	caKey, err := x509.ParsePKCS1PrivateKey(caKeyBlock.Bytes)
-	if err != nil {
-		return "", "", err
+	clientIP, err := netpolicy.ClientIP(c.Request.RemoteAddr, c.Request.Header.Values("X-Forwarded-For"), h.trustedProxies())
+	if err == nil {
+		attempt.ClientIP = clientIP.String()
 	}
+	return attempt, clientIP
+}
 
-	// Generate sensor private key
-	sensorKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Create sensor certificate template
-	sensorTemplate := x509.Certificate{
-		SerialNumber: big.NewInt(2),
-		Subject: pkix.Name{
-			Organization:  []string{"Crypto Inventory Sensor"},
-			Country:       []string{"US"},
-			Province:      []string{""},
-			Locality:      []string{"San Francisco"},
-			StreetAddress: []string{""},
-			PostalCode:    []string{""},
-			CommonName:    sensorName,
-		},
-		NotBefore:   time.Now(),
-		NotAfter:    time.Now().AddDate(1, 0, 0), // 1 year
-		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
-		IPAddresses: []net.IP{},
-		DNSNames:    []string{sensorName},
+// rejectRegistration records a rejected registration attempt and responds
+// with the reason
+func (h *Handler) rejectRegistration(c *gin.Context, attempt *models.RegistrationAttempt, reason, message string) {
+	attempt.Outcome = models.RegistrationRejected
+	attempt.Reason = reason
+	if attempt.Detail == "" {
+		attempt.Detail = message
 	}
+	h.recordRegistrationAttempt(attempt)
+	log.Printf("🔐 Rejected registration of %s from %s: %s", attempt.SensorName, attempt.ClientIP, attempt.Detail)
 
-	// Create sensor certificate
-	sensorCertDER, err := x509.CreateCertificate(rand.Reader, &sensorTemplate, caCert, &sensorKey.PublicKey, caKey)
+	status := 400
+	if reason == reasonNameConflict {
+		status = 409
+	}
+	c.JSON(status, gin.H{"error": message, "reason": reason})
+}
+
+// trustedProxies returns the configured proxies whose forwarded headers are
+// honored. They are validated at startup.
+func (h *Handler) trustedProxies() []netip.Prefix {
+	trusted, err := netpolicy.ParseNetworks(h.config.TrustedProxies)
 	if err != nil {
-		return "", "", err
+		log.Printf("❌ Ignoring invalid trusted proxies: %v", err)
+		return nil
 	}
+	return trusted
+}
 
-	// Encode sensor certificate
-	sensorCertPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: sensorCertDER,
-	})
+// recordRegistrationAttempt writes an audit log entry. A failure is logged
+// but does not fail the registration.
+func (h *Handler) recordRegistrationAttempt(attempt *models.RegistrationAttempt) {
+	if err := h.repo.RecordRegistrationAttempt(attempt); err != nil {
+		log.Printf("❌ Failed to record registration attempt: %v", err)
+	}
+}
 
-	// Encode sensor private key
-	sensorKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(sensorKey),
-	})
+// nonNilStrings returns an empty slice instead of nil so arrays are stored
+// and serialized as [] rather than NULL
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
 
-	return string(sensorCertPEM), string(sensorKeyPEM), nil
+// serverCACertificate returns the CA bundle sensors should use to verify the
+// control plane's TLS certificate. Sensors fall back to the system roots
+// when it is empty.
+func (h *Handler) serverCACertificate() string {
+	if h.config.ServerCAFile == "" {
+		return ""
+	}
+	data, err := os.ReadFile(h.config.ServerCAFile)
+	if err != nil {
+		log.Printf("❌ Failed to read server CA bundle: %v", err)
+		return ""
+	}
+	return string(data)
 }
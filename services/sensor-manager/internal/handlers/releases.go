@@ -0,0 +1,383 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the handlers for the sensor release catalog, used by
+// the build pipeline to publish releases and by sensors to download them,
+// and the operator-facing handlers for staged rollouts.
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/commands"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/releases"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/rollouts"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PermissionRollouts is the tenant permission needed to start and steer
+// staged rollouts, which replace the binary every sensor runs
+const PermissionRollouts = "sensors.rollouts"
+
+// HeaderReleaseSignature carries the base64 Ed25519 signature of an
+// uploaded release binary
+const HeaderReleaseSignature = "X-Release-Signature"
+
+// PublishReleaseRequest represents a request to add a release to the catalog
+type PublishReleaseRequest struct {
+	Version     string `json:"version" binding:"required"`
+	Notes       string `json:"notes"`
+	PublishedBy string `json:"published_by"`
+}
+
+// YankReleaseRequest represents a request to withdraw a release
+type YankReleaseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CreateRolloutRequest represents a request to roll a release out in stages
+type CreateRolloutRequest struct {
+	ReleaseID   string                `json:"release_id" binding:"required"`
+	Stages      []models.RolloutStage `json:"stages" binding:"required"`
+	MaxFailures int                   `json:"max_failures"` // failed upgrades tolerated before pausing
+	SoakSeconds int                   `json:"soak_seconds"` // wait after each stage before the next
+}
+
+// PauseRolloutRequest represents a request to pause a rollout
+type PauseRolloutRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ResumeRolloutRequest represents a request to resume a paused rollout.
+// Failed sensors are retried unless retry_failed is false, in which case
+// they are skipped.
+type ResumeRolloutRequest struct {
+	RetryFailed *bool `json:"retry_failed"`
+}
+
+// PublishRelease adds a release to the catalog; its binaries are uploaded
+// separately
+func (h *Handler) PublishRelease(c *gin.Context) {
+	var req PublishReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !releases.ValidVersion(req.Version) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a semantic version such as 1.4.0"})
+		return
+	}
+
+	release := &models.SensorRelease{Version: req.Version, Notes: req.Notes, PublishedBy: req.PublishedBy}
+	if err := h.repo.CreateSensorRelease(release); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A release with this version already exists"})
+			return
+		}
+		h.respondRepoError(c, err, "Release not found")
+		return
+	}
+	c.JSON(http.StatusCreated, release)
+}
+
+// UploadReleaseArtifact stores the binary of a release for a platform. The
+// request body is the binary and the signature is sent in the
+// X-Release-Signature header.
+func (h *Handler) UploadReleaseArtifact(c *gin.Context) {
+	release := h.loadRelease(c)
+	if release == nil {
+		return
+	}
+	platform := c.Param("platform")
+	if !releases.ValidPlatform(platform) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "platform must be of the form os-arch, e.g. linux-amd64"})
+		return
+	}
+	if release.YankedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Release was yanked"})
+		return
+	}
+
+	artifact, err := h.releases.AddArtifact(release, platform, c.GetHeader(HeaderReleaseSignature), c.Request.Body)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, artifact)
+	case errors.Is(err, releases.ErrNoSigningKeys):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No release signing keys are configured"})
+	case errors.Is(err, releases.ErrInvalidSignature), errors.Is(err, releases.ErrEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, releases.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "The release already has a binary for this platform"})
+	default:
+		h.respondRepoError(c, err, "Release not found")
+	}
+}
+
+// YankRelease withdraws a release: it is no longer offered or served, and
+// its running rollouts are paused
+func (h *Handler) YankRelease(c *gin.Context) {
+	var req YankReleaseRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	release := h.loadRelease(c)
+	if release == nil {
+		return
+	}
+	if err := h.repo.YankSensorRelease(release.ID, req.Reason); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Release was already yanked"})
+			return
+		}
+		h.respondRepoError(c, err, "Release not found")
+		return
+	}
+
+	release, err := h.repo.GetSensorRelease(release.ID)
+	if err != nil {
+		h.respondRepoError(c, err, "Release not found")
+		return
+	}
+	c.JSON(http.StatusOK, release)
+}
+
+// ListReleases returns the release catalog, newest first. Yanked releases
+// are included with ?include_yanked=true.
+func (h *Handler) ListReleases(c *gin.Context) {
+	includeYanked, _ := strconv.ParseBool(c.DefaultQuery("include_yanked", "false"))
+	catalog, err := h.repo.ListSensorReleases(includeYanked)
+	if err != nil {
+		h.respondRepoError(c, err, "Releases not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"releases": catalog, "count": len(catalog)})
+}
+
+// GetRelease returns a release with its binaries
+func (h *Handler) GetRelease(c *gin.Context) {
+	release := h.loadRelease(c)
+	if release == nil {
+		return
+	}
+	c.JSON(http.StatusOK, release)
+}
+
+// DownloadRelease streams the binary of a release for a platform to a
+// sensor. The sensor verifies the digest and signature itself.
+func (h *Handler) DownloadRelease(c *gin.Context) {
+	if h.loadSensor(c) == nil {
+		return
+	}
+
+	artifact, file, err := h.releases.Open(c.Param("version"), c.Param("platform"))
+	if err != nil {
+		h.respondRepoError(c, err, "Release binary not found")
+		return
+	}
+	defer file.Close()
+
+	c.DataFromReader(http.StatusOK, artifact.Size, "application/octet-stream", file, map[string]string{
+		"X-Release-SHA256":     artifact.SHA256,
+		HeaderReleaseSignature: artifact.Signature,
+	})
+}
+
+// CreateRollout starts a staged rollout of a release to the tenant's
+// sensors
+func (h *Handler) CreateRollout(c *gin.Context) {
+	var req CreateRolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := uuid.Parse(req.ReleaseID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid release ID"})
+		return
+	}
+	for _, stage := range req.Stages {
+		if stage.GroupID != "" {
+			if _, err := uuid.Parse(stage.GroupID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+				return
+			}
+		}
+		for _, id := range stage.SensorIDs {
+			if _, err := uuid.Parse(id); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor ID"})
+				return
+			}
+		}
+	}
+
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	rollout, err := h.rollouts.Create(tenantID, rollouts.Request{
+		ReleaseID:   req.ReleaseID,
+		Stages:      req.Stages,
+		MaxFailures: req.MaxFailures,
+		SoakSeconds: req.SoakSeconds,
+		CreatedBy:   c.GetString("user_id"),
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, rollout)
+	case errors.Is(err, rollouts.ErrInvalidRollout):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, rollouts.ErrReleaseYanked):
+		c.JSON(http.StatusConflict, gin.H{"error": "Release was yanked"})
+	case errors.Is(err, commands.ErrNoTargets):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Some of the sensors are being upgraded by another rollout"})
+	default:
+		h.respondRepoError(c, err, "Release not found")
+	}
+}
+
+// ListRollouts returns the tenant's rollouts, newest first
+func (h *Handler) ListRollouts(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.RolloutStatusRunning, models.RolloutStatusPaused, models.RolloutStatusCompleted, models.RolloutStatusCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of running, paused, completed, cancelled"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	list, total, err := h.repo.ListSensorRollouts(tenantID, status, page, pageSize)
+	if err != nil {
+		h.respondRepoError(c, err, "Rollouts not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rollouts": list,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		},
+	})
+}
+
+// GetRollout returns a rollout with the upgrade state of each sensor
+func (h *Handler) GetRollout(c *gin.Context) {
+	tenantID, rolloutID, ok := h.rolloutParams(c)
+	if !ok {
+		return
+	}
+	rollout, err := h.repo.GetSensorRollout(tenantID, rolloutID)
+	if err != nil {
+		h.respondRepoError(c, err, "Rollout not found")
+		return
+	}
+	c.JSON(http.StatusOK, rollout)
+}
+
+// PauseRollout pauses a running rollout
+func (h *Handler) PauseRollout(c *gin.Context) {
+	var req PauseRolloutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tenantID, rolloutID, ok := h.rolloutParams(c)
+	if !ok {
+		return
+	}
+	rollout, err := h.rollouts.Pause(tenantID, rolloutID, req.Reason)
+	h.respondRollout(c, rollout, err, "Only running rollouts can be paused")
+}
+
+// ResumeRollout resumes a paused rollout
+func (h *Handler) ResumeRollout(c *gin.Context) {
+	var req ResumeRolloutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	retryFailed := req.RetryFailed == nil || *req.RetryFailed
+
+	tenantID, rolloutID, ok := h.rolloutParams(c)
+	if !ok {
+		return
+	}
+	rollout, err := h.rollouts.Resume(tenantID, rolloutID, retryFailed)
+	h.respondRollout(c, rollout, err, "Only paused rollouts can be resumed")
+}
+
+// CancelRollout cancels a running or paused rollout
+func (h *Handler) CancelRollout(c *gin.Context) {
+	tenantID, rolloutID, ok := h.rolloutParams(c)
+	if !ok {
+		return
+	}
+	rollout, err := h.rollouts.Cancel(tenantID, rolloutID)
+	h.respondRollout(c, rollout, err, "The rollout already ended")
+}
+
+// loadRelease loads the release named by the :release_id path parameter.
+// It writes an error response and returns nil when there is none.
+func (h *Handler) loadRelease(c *gin.Context) *models.SensorRelease {
+	releaseID := c.Param("release_id")
+	if _, err := uuid.Parse(releaseID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Release not found"})
+		return nil
+	}
+	release, err := h.repo.GetSensorRelease(releaseID)
+	if err != nil {
+		h.respondRepoError(c, err, "Release not found")
+		return nil
+	}
+	return release
+}
+
+// rolloutParams returns the tenant and the :rollout_id path parameter
+func (h *Handler) rolloutParams(c *gin.Context) (string, string, bool) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return "", "", false
+	}
+	rolloutID := c.Param("rollout_id")
+	if _, err := uuid.Parse(rolloutID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rollout not found"})
+		return "", "", false
+	}
+	return tenantID, rolloutID, true
+}
+
+// respondRollout writes the outcome of a rollout status change
+func (h *Handler) respondRollout(c *gin.Context, rollout *models.SensorRollout, err error, invalidMessage string) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, rollout)
+	case errors.Is(err, repository.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": invalidMessage})
+	default:
+		h.respondRepoError(c, err, "Rollout not found")
+	}
+}
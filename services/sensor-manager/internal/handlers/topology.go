@@ -0,0 +1,149 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the handlers for the network topology: sensors report
+// the subnets, VLANs, gateways and flows they observe, operators read the
+// coverage model and topology graph built from them.
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/topology"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultTopologyDays and maxTopologyDays bound how far back topology
+	// observations count
+	defaultTopologyDays = 7
+	maxTopologyDays     = 30
+	// maxTopologyAssets caps the assets placed in the topology graph
+	maxTopologyAssets = 2000
+)
+
+// SubmitTopology receives the topology a sensor observed during a window
+func (h *Handler) SubmitTopology(c *gin.Context) {
+	sensor := h.loadSensor(c)
+	if sensor == nil {
+		return
+	}
+
+	var report models.TopologyReport
+	if err := c.ShouldBindJSON(&report); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if report.SensorID != sensor.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sensor ID mismatch"})
+		return
+	}
+	if err := topology.Validate(&report); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.RecordTopology(sensor.TenantID, sensor.ID, &report); err != nil {
+		h.respondRepoError(c, err, "Sensor not found")
+		return
+	}
+
+	log.Printf("🗺️ Topology from sensor %s: %d subnets, %d gateways, %d flows",
+		sensor.ID, len(report.Subnets), len(report.Gateways), len(report.Flows))
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"subnets":  len(report.Subnets),
+		"vlans":    len(report.VLANs),
+		"gateways": len(report.Gateways),
+		"flows":    len(report.Flows),
+	})
+}
+
+// GetTopologyCoverage returns which subnets the tenant's sensors see and
+// which subnets of known assets no sensor sees
+func (h *Handler) GetTopologyCoverage(c *gin.Context) {
+	tenantID, filters, ok := h.topologyFilters(c)
+	if !ok {
+		return
+	}
+
+	subnets, err := h.repo.ListSensorSubnets(tenantID, filters)
+	if err != nil {
+		h.respondRepoError(c, err, "Topology not found")
+		return
+	}
+	assets, err := h.repo.ListAssetSubnets(tenantID)
+	if err != nil {
+		h.respondRepoError(c, err, "Topology not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, topology.Coverage(subnets, assets, filters.Since))
+}
+
+// GetTopologyGraph returns the network topology as a graph of sensors,
+// subnets, gateways and assets, connected by the flows sensors observed
+func (h *Handler) GetTopologyGraph(c *gin.Context) {
+	tenantID, filters, ok := h.topologyFilters(c)
+	if !ok {
+		return
+	}
+
+	input := topology.GraphInput{Since: filters.Since}
+	var err error
+	if input.Subnets, err = h.repo.ListSensorSubnets(tenantID, filters); err != nil {
+		h.respondRepoError(c, err, "Topology not found")
+		return
+	}
+	if input.Gateways, err = h.repo.ListSensorGateways(tenantID, filters); err != nil {
+		h.respondRepoError(c, err, "Topology not found")
+		return
+	}
+	if input.Flows, err = h.repo.ListSensorFlows(tenantID, filters); err != nil {
+		h.respondRepoError(c, err, "Topology not found")
+		return
+	}
+	if c.DefaultQuery("include_assets", "true") != "false" {
+		input.Assets, input.AssetsTruncated, err = h.repo.ListTopologyAssets(tenantID, maxTopologyAssets)
+		if err != nil {
+			h.respondRepoError(c, err, "Topology not found")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, topology.Graph(input))
+}
+
+// topologyFilters returns the tenant and the ?days= and ?sensor_id=
+// filters. It writes an error response and returns false when either is
+// invalid.
+func (h *Handler) topologyFilters(c *gin.Context) (string, models.TopologyFilters, bool) {
+	var filters models.TopologyFilters
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return "", filters, false
+	}
+
+	days := defaultTopologyDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxTopologyDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 30"})
+			return "", filters, false
+		}
+		days = parsed
+	}
+	filters.Since = time.Now().AddDate(0, 0, -days)
+
+	filters.SensorID = c.Query("sensor_id")
+	if filters.SensorID != "" {
+		if _, err := uuid.Parse(filters.SensorID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor ID"})
+			return "", filters, false
+		}
+	}
+	return tenantID, filters, true
+}
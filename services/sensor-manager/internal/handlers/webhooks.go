@@ -0,0 +1,390 @@
+// Package handlers provides HTTP handlers for the sensor-manager service.
+// This file contains the operator-facing handlers for tenant webhooks and
+// their delivery log.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/webhooks"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Webhook delivery bounds
+const (
+	defaultWebhookRetries = 3
+	maxWebhookRetries     = 10
+	defaultWebhookTimeout = 10 // seconds
+	maxWebhookTimeout     = 60 // seconds
+	maxWebhookDescription = 255
+)
+
+// WebhookRequest represents a request to create or replace a webhook. An
+// empty secret on update keeps the stored one; no events subscribes to all.
+type WebhookRequest struct {
+	Description string   `json:"description"`
+	SensorID    string   `json:"sensor_id"` // limits the webhook to events about one sensor
+	Enabled     *bool    `json:"enabled"`
+	WebhookURL  string   `json:"webhook_url" binding:"required"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events"`
+	RetryCount  *int     `json:"retry_count"`
+	Timeout     *int     `json:"timeout"` // seconds per attempt
+}
+
+// ListWebhookEventTypes returns the events webhooks may subscribe to
+func (h *Handler) ListWebhookEventTypes(c *gin.Context) {
+	types := make([]gin.H, 0, len(models.WebhookEventTypes))
+	for event, description := range models.WebhookEventTypes {
+		types = append(types, gin.H{"type": event, "description": description})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i]["type"].(string) < types[j]["type"].(string) })
+	c.JSON(http.StatusOK, gin.H{"event_types": types})
+}
+
+// CreateWebhook registers a webhook for tenant events
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	webhook := &models.WebhookEndpoint{TenantID: tenantID, CreatedBy: c.GetString("user_id")}
+	if !h.applyWebhookRequest(c, webhook, &req) {
+		return
+	}
+	if err := h.repo.CreateWebhookEndpoint(webhook); err != nil {
+		h.respondRepoError(c, err, "Webhook not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": redactWebhook(webhook)})
+}
+
+// ListWebhooks returns the tenant's webhooks
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.repo.ListWebhookEndpoints(tenantID)
+	if err != nil {
+		h.respondRepoError(c, err, "Webhooks not found")
+		return
+	}
+	for _, webhook := range webhooks {
+		redactWebhook(webhook)
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+// GetWebhook returns a single webhook
+func (h *Handler) GetWebhook(c *gin.Context) {
+	webhook := h.loadWebhook(c)
+	if webhook == nil {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhook": redactWebhook(webhook)})
+}
+
+// UpdateWebhook replaces a webhook's settings
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhook := h.loadWebhook(c)
+	if webhook == nil {
+		return
+	}
+	webhook.Secret = ""
+	if !h.applyWebhookRequest(c, webhook, &req) {
+		return
+	}
+	if err := h.repo.UpdateWebhookEndpoint(webhook); err != nil {
+		h.respondRepoError(c, err, "Webhook not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": redactWebhook(webhook)})
+}
+
+// DeleteWebhook removes a webhook together with its delivery log
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	webhook := h.loadWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	if err := h.repo.DeleteWebhookEndpoint(webhook.TenantID, webhook.ID); err != nil {
+		h.respondRepoError(c, err, "Webhook not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// TestWebhook sends a webhook.test event to a webhook right away and
+// returns the outcome of the attempt. Disabled webhooks can be tested too.
+func (h *Handler) TestWebhook(c *gin.Context) {
+	webhook := h.loadWebhook(c)
+	if webhook == nil {
+		return
+	}
+
+	delivery, err := h.webhooks.SendTest(c.Request.Context(), webhook, c.GetString("user_id"))
+	if err != nil {
+		h.respondRepoError(c, err, "Webhook not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"delivered": delivery.Status == models.DeliveryStatusSucceeded,
+		"delivery":  delivery,
+	})
+}
+
+// ListWebhookDeliveries returns the tenant's delivery log, newest first.
+// Deliveries can be filtered by webhook, status and event type; the
+// dead-letter store is the deliveries with status dead_letter.
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	endpointID := c.Param("webhook_id")
+	if endpointID == "" {
+		endpointID = c.Query("webhook_id")
+	}
+	if endpointID != "" {
+		if _, err := uuid.Parse(endpointID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		if _, err := h.repo.GetWebhookEndpoint(tenantID, endpointID); err != nil {
+			h.respondRepoError(c, err, "Webhook not found")
+			return
+		}
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.DeliveryStatusPending, models.DeliveryStatusDelivering, models.DeliveryStatusRetrying,
+		models.DeliveryStatusSucceeded, models.DeliveryStatusDeadLetter:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of pending, delivering, retrying, succeeded, dead_letter"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	deliveries, total, err := h.repo.ListWebhookDeliveries(tenantID, models.WebhookDeliveryFilters{
+		EndpointID: endpointID,
+		Status:     status,
+		EventType:  c.Query("event_type"),
+		Page:       page,
+		PageSize:   pageSize,
+	})
+	if err != nil {
+		h.respondRepoError(c, err, "Deliveries not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"pagination": gin.H{
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		},
+	})
+}
+
+// GetWebhookDelivery returns a delivery with every attempt made and the
+// event that was delivered
+func (h *Handler) GetWebhookDelivery(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	deliveryID := c.Param("delivery_id")
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	delivery, err := h.repo.GetWebhookDelivery(tenantID, deliveryID)
+	if err != nil {
+		h.respondRepoError(c, err, "Delivery not found")
+		return
+	}
+	payload, err := h.repo.GetWebhookEventPayload(tenantID, delivery.EventID)
+	if err != nil {
+		h.respondRepoError(c, err, "Delivery not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery, "payload": payload})
+}
+
+// RedeliverWebhookDelivery queues a dead-lettered or succeeded delivery for
+// delivery again, with a fresh set of retries
+func (h *Handler) RedeliverWebhookDelivery(c *gin.Context) {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return
+	}
+
+	deliveryID := c.Param("delivery_id")
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	delivery, err := h.webhooks.Redeliver(tenantID, deliveryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Delivery is still in progress"})
+			return
+		}
+		h.respondRepoError(c, err, "Delivery not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
+
+// applyWebhookRequest validates a webhook request and copies it onto the
+// webhook. It writes an error response and returns false when the request
+// is invalid.
+func (h *Handler) applyWebhookRequest(c *gin.Context, webhook *models.WebhookEndpoint, req *WebhookRequest) bool {
+	if err := h.validateWebhookURL(c.Request.Context(), req.WebhookURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if len(req.Description) > maxWebhookDescription {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("description must be at most %d characters", maxWebhookDescription)})
+		return false
+	}
+
+	events := []string{}
+	seen := make(map[string]bool)
+	for _, event := range req.Events {
+		if _, ok := models.WebhookEventTypes[event]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown event %q", event)})
+			return false
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+
+	retries, timeout := defaultWebhookRetries, defaultWebhookTimeout
+	if req.RetryCount != nil {
+		retries = *req.RetryCount
+	}
+	if req.Timeout != nil {
+		timeout = *req.Timeout
+	}
+	if retries < 0 || retries > maxWebhookRetries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("retry_count must be between 0 and %d", maxWebhookRetries)})
+		return false
+	}
+	if timeout < 1 || timeout > maxWebhookTimeout {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("timeout must be between 1 and %d seconds", maxWebhookTimeout)})
+		return false
+	}
+
+	if req.SensorID != "" {
+		if _, err := uuid.Parse(req.SensorID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sensor not found"})
+			return false
+		}
+		if _, err := h.repo.GetTenantSensor(webhook.TenantID, req.SensorID); err != nil {
+			h.respondRepoError(c, err, "Sensor not found")
+			return false
+		}
+	}
+
+	webhook.Description = req.Description
+	webhook.SensorID = req.SensorID
+	webhook.Enabled = req.Enabled == nil || *req.Enabled
+	webhook.WebhookURL = req.WebhookURL
+	if req.Secret != "" {
+		webhook.Secret = req.Secret
+	}
+	webhook.Events = events
+	webhook.RetryCount = retries
+	webhook.Timeout = timeout
+	return true
+}
+
+// validateWebhookURL accepts absolute http(s) URLs of hosts on public
+// addresses or WEBHOOK_ALLOWED_NETWORKS; production deployments only
+// deliver over HTTPS
+func (h *Handler) validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("webhook_url must be an absolute http or https URL")
+	}
+	if u.Scheme == "http" && h.config.Environment == "production" {
+		return fmt.Errorf("webhook_url must use https")
+	}
+	if u.User != nil {
+		return fmt.Errorf("webhook_url must not contain credentials; use a secret instead")
+	}
+	if err := h.webhooks.CheckURL(ctx, raw); err != nil {
+		if errors.Is(err, webhooks.ErrForbiddenAddress) {
+			return fmt.Errorf("webhook_url must not point to a loopback, link-local or private address")
+		}
+		return fmt.Errorf("webhook_url host cannot be resolved")
+	}
+	return nil
+}
+
+// loadWebhook loads the webhook named by :webhook_id for the current
+// tenant. It writes an error response and returns nil when the webhook
+// cannot be found.
+func (h *Handler) loadWebhook(c *gin.Context) *models.WebhookEndpoint {
+	tenantID, ok := h.tenantID(c)
+	if !ok {
+		return nil
+	}
+
+	webhookID := c.Param("webhook_id")
+	if _, err := uuid.Parse(webhookID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil
+	}
+
+	webhook, err := h.repo.GetWebhookEndpoint(tenantID, webhookID)
+	if err != nil {
+		h.respondRepoError(c, err, "Webhook not found")
+		return nil
+	}
+	return webhook
+}
+
+// redactWebhook clears the secret before a webhook is returned
+func redactWebhook(webhook *models.WebhookEndpoint) *models.WebhookEndpoint {
+	webhook.Secret = ""
+	return webhook
+}
@@ -0,0 +1,185 @@
+package ingest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// Raw metadata keys sensors use to report presented certificates: either a
+// PEM chain (leaf first) or a single PEM certificate
+const (
+	metadataCertificateChain = "certificate_chain"
+	metadataCertificatePEM   = "certificate_pem"
+)
+
+// signatureAlgorithms maps Go signature algorithm names onto the naming used
+// in the inventory
+var signatureAlgorithms = map[x509.SignatureAlgorithm]string{
+	x509.MD5WithRSA:       "MD5withRSA",
+	x509.SHA1WithRSA:      "SHA1withRSA",
+	x509.SHA256WithRSA:    "SHA256withRSA",
+	x509.SHA384WithRSA:    "SHA384withRSA",
+	x509.SHA512WithRSA:    "SHA512withRSA",
+	x509.SHA256WithRSAPSS: "RSA-PSS",
+	x509.SHA384WithRSAPSS: "RSA-PSS",
+	x509.SHA512WithRSAPSS: "RSA-PSS",
+	x509.ECDSAWithSHA1:    "SHA1withECDSA",
+	x509.ECDSAWithSHA256:  "SHA256withECDSA",
+	x509.ECDSAWithSHA384:  "SHA384withECDSA",
+	x509.ECDSAWithSHA512:  "SHA512withECDSA",
+	x509.PureEd25519:      "Ed25519",
+}
+
+var keyUsages = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+var extKeyUsages = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "any",
+	x509.ExtKeyUsageServerAuth:      "serverAuth",
+	x509.ExtKeyUsageClientAuth:      "clientAuth",
+	x509.ExtKeyUsageCodeSigning:     "codeSigning",
+	x509.ExtKeyUsageEmailProtection: "emailProtection",
+	x509.ExtKeyUsageTimeStamping:    "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:     "OCSPSigning",
+}
+
+// metadataCertificates parses the certificates reported in a discovery's raw
+// metadata, leaf first. It returns nil when none were reported.
+func metadataCertificates(metadata map[string]interface{}) ([]*models.ObservedCertificate, error) {
+	var encoded []string
+	switch chain := metadata[metadataCertificateChain].(type) {
+	case nil:
+	case []interface{}:
+		for _, entry := range chain {
+			text, ok := entry.(string)
+			if !ok {
+				return nil, errors.New("certificate_chain must be a list of PEM strings")
+			}
+			encoded = append(encoded, text)
+		}
+	default:
+		return nil, errors.New("certificate_chain must be a list of PEM strings")
+	}
+	if len(encoded) == 0 {
+		if text, ok := metadata[metadataCertificatePEM].(string); ok && text != "" {
+			encoded = append(encoded, text)
+		}
+	}
+
+	certificates := make([]*models.ObservedCertificate, 0, len(encoded))
+	for _, text := range encoded {
+		block, _ := pem.Decode([]byte(text))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, errors.New("certificate is not a PEM certificate")
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %v", err)
+		}
+		certificates = append(certificates, observedCertificate(certificate))
+	}
+	return certificates, nil
+}
+
+// observedCertificate extracts the inventory fields of a certificate
+func observedCertificate(certificate *x509.Certificate) *models.ObservedCertificate {
+	sha1Sum := sha1.Sum(certificate.Raw)
+	sha256Sum := sha256.Sum256(certificate.Raw)
+
+	observed := &models.ObservedCertificate{
+		SerialNumber:       serialNumber(certificate),
+		SubjectDN:          certificate.Subject.String(),
+		IssuerDN:           certificate.Issuer.String(),
+		CommonName:         certificate.Subject.CommonName,
+		PublicKeyAlgorithm: certificate.PublicKeyAlgorithm.String(),
+		PublicKeySize:      publicKeySize(certificate.PublicKey),
+		NotBefore:          certificate.NotBefore,
+		NotAfter:           certificate.NotAfter,
+		FingerprintSHA1:    hex.EncodeToString(sha1Sum[:]),
+		FingerprintSHA256:  hex.EncodeToString(sha256Sum[:]),
+		SubjectKeyID:       hex.EncodeToString(certificate.SubjectKeyId),
+		AuthorityKeyID:     hex.EncodeToString(certificate.AuthorityKeyId),
+		PEM:                string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})),
+		IsCA:               certificate.IsCA,
+	}
+
+	if name, ok := signatureAlgorithms[certificate.SignatureAlgorithm]; ok {
+		observed.SignatureAlgorithm = name
+	} else {
+		observed.SignatureAlgorithm = certificate.SignatureAlgorithm.String()
+	}
+
+	observed.SubjectAlternativeNames = append(observed.SubjectAlternativeNames, certificate.DNSNames...)
+	for _, ip := range certificate.IPAddresses {
+		observed.SubjectAlternativeNames = append(observed.SubjectAlternativeNames, ip.String())
+	}
+	observed.SubjectAlternativeNames = append(observed.SubjectAlternativeNames, certificate.EmailAddresses...)
+	for _, uri := range certificate.URIs {
+		observed.SubjectAlternativeNames = append(observed.SubjectAlternativeNames, uri.String())
+	}
+
+	// CheckSignatureFrom would require the CA flag, which self-signed leaf
+	// certificates usually lack
+	observed.IsSelfSigned = bytes.Equal(certificate.RawSubject, certificate.RawIssuer) &&
+		certificate.CheckSignature(certificate.SignatureAlgorithm, certificate.RawTBSCertificate, certificate.Signature) == nil
+
+	for _, usage := range keyUsages {
+		if certificate.KeyUsage&usage.usage != 0 {
+			observed.KeyUsage = append(observed.KeyUsage, usage.name)
+		}
+	}
+	for _, usage := range certificate.ExtKeyUsage {
+		if name, ok := extKeyUsages[usage]; ok {
+			observed.ExtendedKeyUsage = append(observed.ExtendedKeyUsage, name)
+		}
+	}
+	return observed
+}
+
+// serialNumber formats a serial number as colon-separated hex bytes
+func serialNumber(certificate *x509.Certificate) string {
+	raw := certificate.SerialNumber.Bytes()
+	parts := make([]string, len(raw))
+	for i, b := range raw {
+		parts[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.Join(parts, ":")
+}
+
+// publicKeySize returns the key size in bits, or 0 when unknown
+func publicKeySize(key interface{}) int {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
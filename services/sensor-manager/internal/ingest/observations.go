@@ -0,0 +1,271 @@
+package ingest
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// Column limits of the inventory schema
+const (
+	maxVersionLen  = 20
+	maxCipherLen   = 255
+	maxHostnameLen = 255
+
+	// maxClients caps how many client addresses are kept per implementation
+	maxClients = 50
+	// maxClockSkew is how far in the future a discovery timestamp may be
+	maxClockSkew = 5 * time.Minute
+)
+
+// protocols maps protocol names reported by sensors onto the protocol_type enum
+var protocols = map[string]string{
+	"TLS":       "TLS",
+	"SSL":       "TLS",
+	"HTTPS":     "TLS",
+	"DTLS":      "TLS",
+	"SSH":       "SSH",
+	"IPSEC":     "IPSec",
+	"IKE":       "IPSec",
+	"VPN":       "VPN",
+	"OPENVPN":   "VPN",
+	"WIREGUARD": "VPN",
+	"DATABASE":  "Database",
+	"API":       "API",
+}
+
+// Raw metadata keys carrying the server name of an endpoint, in order of
+// preference
+var hostnameKeys = []string{"server_name", "sni", "hostname"}
+
+// Observations validates the discoveries of a batch and aggregates the valid
+// ones into one observation per implementation, so a busy endpoint seen
+// thousands of times in a batch is written once. Invalid discoveries are
+// returned as rejections and do not affect the rest of the batch.
+func Observations(discoveries []models.CryptoDiscovery) ([]*models.EndpointObservation, []models.DiscoveryRejection) {
+	byKey := make(map[string]*models.EndpointObservation)
+	clients := make(map[string]map[string]bool)
+	rejections := []models.DiscoveryRejection{}
+
+	for i := range discoveries {
+		discovery := &discoveries[i]
+		observation, err := observe(discovery)
+		if err != nil {
+			rejections = append(rejections, models.DiscoveryRejection{
+				Index:       i,
+				DiscoveryID: discovery.ID,
+				Reason:      err.Error(),
+			})
+			continue
+		}
+
+		key := strings.Join([]string{
+			observation.IPAddress, strings.ToLower(observation.Hostname), fmt.Sprint(observation.Port),
+			observation.Protocol, observation.Version, observation.CipherSuite,
+		}, "|")
+
+		existing, ok := byKey[key]
+		if !ok {
+			byKey[key] = observation
+			clients[key] = make(map[string]bool)
+			existing = observation
+		} else {
+			merge(existing, observation)
+		}
+
+		if ip := net.ParseIP(discovery.SourceIP); ip != nil && len(clients[key]) < maxClients {
+			clients[key][ip.String()] = true
+		}
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	observations := make([]*models.EndpointObservation, 0, len(keys))
+	for _, key := range keys {
+		observation := byKey[key]
+		if len(clients[key]) > 0 {
+			addresses := make([]string, 0, len(clients[key]))
+			for address := range clients[key] {
+				addresses = append(addresses, address)
+			}
+			sort.Strings(addresses)
+			observation.RawData["clients"] = addresses
+		}
+		observation.Weaknesses = Weaknesses(observation)
+		observations = append(observations, observation)
+	}
+	return observations, rejections
+}
+
+// observe validates a single discovery and converts it to an observation
+func observe(discovery *models.CryptoDiscovery) (*models.EndpointObservation, error) {
+	ip := net.ParseIP(strings.TrimSpace(discovery.DestIP))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid dest_ip %q", discovery.DestIP)
+	}
+	if ip.IsUnspecified() || ip.IsMulticast() {
+		return nil, fmt.Errorf("dest_ip %s is not a host address", ip)
+	}
+
+	if discovery.Port < 1 || discovery.Port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", discovery.Port)
+	}
+
+	protocol, ok := protocols[strings.ToUpper(strings.TrimSpace(discovery.Protocol))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported protocol %q", discovery.Protocol)
+	}
+
+	method := strings.ToLower(discovery.DiscoveryMethod)
+	switch method {
+	case "":
+		method = "passive"
+	case "passive", "active":
+	default:
+		return nil, fmt.Errorf("invalid discovery_method %q", discovery.DiscoveryMethod)
+	}
+
+	if discovery.Confidence < 0 || discovery.Confidence > 1 {
+		return nil, fmt.Errorf("confidence %v is outside 0..1", discovery.Confidence)
+	}
+
+	cipherSuite := strings.TrimSpace(discovery.CipherSuite)
+	if len(cipherSuite) > maxCipherLen {
+		return nil, fmt.Errorf("cipher_suite exceeds %d characters", maxCipherLen)
+	}
+
+	hostname := ""
+	for _, key := range hostnameKeys {
+		if value, ok := discovery.RawMetadata[key].(string); ok && value != "" {
+			hostname = strings.TrimSuffix(strings.TrimSpace(value), ".")
+			break
+		}
+	}
+	if len(hostname) > maxHostnameLen {
+		return nil, fmt.Errorf("hostname exceeds %d characters", maxHostnameLen)
+	}
+
+	certificates, err := metadataCertificates(discovery.RawMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := discovery.Timestamp
+	if seen.IsZero() {
+		seen = discovery.CreatedAt
+	}
+	if now := time.Now(); seen.IsZero() || seen.After(now.Add(maxClockSkew)) {
+		seen = now
+	}
+
+	version, software := normalizeVersion(protocol, discovery.Version)
+
+	observation := &models.EndpointObservation{
+		IPAddress:       ip.String(),
+		Hostname:        hostname,
+		Port:            discovery.Port,
+		Protocol:        protocol,
+		Version:         version,
+		CipherSuite:     cipherSuite,
+		DiscoveryMethod: method,
+		Confidence:      discovery.Confidence,
+		FirstSeen:       seen,
+		LastSeen:        seen,
+		Certificates:    certificates,
+		RawData:         map[string]interface{}{"observations": 1},
+	}
+	if software != "" {
+		observation.RawData["software"] = software
+	}
+	if handshake, ok := discovery.RawMetadata["handshake_type"].(string); ok && handshake != "" {
+		observation.RawData["handshake_types"] = []string{handshake}
+	}
+	return observation, nil
+}
+
+// merge folds another observation of the same implementation into existing
+func merge(existing, other *models.EndpointObservation) {
+	if other.FirstSeen.Before(existing.FirstSeen) {
+		existing.FirstSeen = other.FirstSeen
+	}
+	if other.LastSeen.After(existing.LastSeen) {
+		existing.LastSeen = other.LastSeen
+		if len(other.Certificates) > 0 {
+			existing.Certificates = other.Certificates
+		}
+	}
+	if len(existing.Certificates) == 0 {
+		existing.Certificates = other.Certificates
+	}
+	if other.Confidence > existing.Confidence {
+		existing.Confidence = other.Confidence
+	}
+	if other.DiscoveryMethod == "active" {
+		existing.DiscoveryMethod = "active"
+	}
+
+	existing.RawData["observations"] = existing.RawData["observations"].(int) + 1
+	if software, ok := other.RawData["software"]; ok {
+		existing.RawData["software"] = software
+	}
+	if handshakes, ok := other.RawData["handshake_types"].([]string); ok {
+		known, _ := existing.RawData["handshake_types"].([]string)
+		for _, handshake := range handshakes {
+			if !containsString(known, handshake) {
+				known = append(known, handshake)
+			}
+		}
+		existing.RawData["handshake_types"] = known
+	}
+}
+
+// normalizeVersion converts a reported protocol version to the short form
+// stored in the inventory ("TLS 1.2" -> "1.2"). For SSH the software part of
+// the identification string is split off ("2.0-OpenSSH_9.6" -> "2.0").
+func normalizeVersion(protocol, version string) (string, string) {
+	version = strings.TrimSpace(version)
+	software := ""
+
+	switch protocol {
+	case "TLS":
+		if strings.HasPrefix(strings.ToLower(version), "unknown") {
+			return "", ""
+		}
+		for _, prefix := range []string{"TLSv", "TLS ", "SSLv", "SSL "} {
+			if strings.HasPrefix(version, prefix) {
+				version = strings.TrimSpace(version[len(prefix):])
+				if strings.HasPrefix(prefix, "SSL") {
+					version = "SSL " + version
+				}
+				break
+			}
+		}
+	case "SSH":
+		version = strings.TrimPrefix(version, "SSH-")
+		if i := strings.IndexByte(version, '-'); i >= 0 {
+			version, software = version[:i], version[i+1:]
+		}
+	}
+
+	if len(version) > maxVersionLen {
+		version = version[:maxVersionLen]
+	}
+	return version, software
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
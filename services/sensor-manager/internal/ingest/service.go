@@ -0,0 +1,189 @@
+// Package ingest turns discovery batches submitted by sensors into inventory
+// records. Submitted batches are stored as-is, which makes submissions
+// idempotent on the batch ID, and a background worker later validates each
+// batch and upserts the assets, certificates and crypto implementations it
+// describes. Processing is idempotent as well, so a batch interrupted by a
+// restart is simply processed again.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+)
+
+// Limits and intervals for batch ingestion
+const (
+	MaxBatchSize  = 10000
+	MaxBatchIDLen = 100
+
+	// claimBatchSize caps how many batches a worker claims at once
+	claimBatchSize = 10
+	// maxAttempts is how often a batch is processed before it is marked failed
+	maxAttempts = 5
+	// staleAfter is when a batch left in processing is claimed again
+	staleAfter = 10 * time.Minute
+	// pollInterval is how often the worker looks for batches when not woken
+	pollInterval = 15 * time.Second
+	// retention is how long completed batches are kept for idempotency
+	retention = 30 * 24 * time.Hour
+	// purgeInterval is how often expired batches are purged
+	purgeInterval = time.Hour
+)
+
+// Service accepts discovery batches and ingests them in the background
+type Service struct {
+	repo *repository.Repository
+	wake chan struct{}
+}
+
+// NewService creates a new ingestion service
+func NewService(repo *repository.Repository) *Service {
+	return &Service{
+		repo: repo,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// ValidateBatch checks the structure of a submitted batch before it is
+// accepted. Individual discoveries are validated during ingestion, where
+// invalid ones are rejected without failing the rest of the batch.
+func ValidateBatch(batch *models.DiscoveryBatch) error {
+	if batch.BatchID == "" {
+		return errors.New("batch_id is required")
+	}
+	if len(batch.BatchID) > MaxBatchIDLen {
+		return fmt.Errorf("batch_id must be at most %d characters", MaxBatchIDLen)
+	}
+	if len(batch.Discoveries) == 0 {
+		return errors.New("batch contains no discoveries")
+	}
+	if len(batch.Discoveries) > MaxBatchSize {
+		return fmt.Errorf("batch exceeds %d discoveries", MaxBatchSize)
+	}
+	if batch.Count != 0 && batch.Count != len(batch.Discoveries) {
+		return fmt.Errorf("count %d does not match %d discoveries", batch.Count, len(batch.Discoveries))
+	}
+	for i := range batch.Discoveries {
+		if id := batch.Discoveries[i].SensorID; id != "" && id != batch.SensorID {
+			return fmt.Errorf("discovery %d belongs to another sensor", i)
+		}
+	}
+	return nil
+}
+
+// Submit stores a batch for ingestion and wakes the worker. It returns the
+// stored record and whether the batch is new; a batch submitted again with
+// the same ID returns the original record.
+func (s *Service) Submit(tenantID, sensorID, source, batchID string, discoveries []models.CryptoDiscovery) (*models.DiscoveryBatchRecord, bool, error) {
+	record := &models.DiscoveryBatchRecord{
+		TenantID:    tenantID,
+		SensorID:    sensorID,
+		BatchID:     batchID,
+		Source:      source,
+		Discoveries: discoveries,
+	}
+
+	created, err := s.repo.StoreDiscoveryBatch(record)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		s.notify()
+	}
+	return record, created, nil
+}
+
+// Run processes stored batches until ctx is done. The worker wakes up when
+// a batch is submitted and polls periodically for batches stored by other
+// replicas or left behind by a crashed worker.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+
+	s.drain(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+			s.drain(ctx)
+		case <-ticker.C:
+			s.drain(ctx)
+		case <-purge.C:
+			purged, err := s.repo.PurgeDiscoveryBatches(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("❌ Discovery batch purge failed: %v", err)
+			} else if purged > 0 {
+				log.Printf("🧹 Purged %d ingested discovery batches", purged)
+			}
+		}
+	}
+}
+
+// notify wakes the worker without blocking
+func (s *Service) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// drain processes batches until none are left to claim
+func (s *Service) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		batches, err := s.repo.ClaimDiscoveryBatches(claimBatchSize, staleAfter, maxAttempts)
+		if err != nil {
+			log.Printf("❌ Failed to claim discovery batches: %v", err)
+			return
+		}
+		if len(batches) == 0 {
+			return
+		}
+
+		for _, batch := range batches {
+			if err := s.process(batch); err != nil {
+				log.Printf("❌ Failed to ingest batch %s from sensor %s (attempt %d): %v",
+					batch.BatchID, batch.SensorID, batch.Attempts, err)
+				if err := s.repo.FailDiscoveryBatch(batch.ID, err, maxAttempts); err != nil {
+					log.Printf("❌ %v", err)
+				}
+			}
+		}
+	}
+}
+
+// process validates a claimed batch and writes its observations to the
+// inventory
+func (s *Service) process(batch *models.DiscoveryBatchRecord) error {
+	observations, rejections := Observations(batch.Discoveries)
+
+	created := 0
+	for _, observation := range observations {
+		isNew, err := s.repo.IngestObservation(batch.TenantID, batch.SensorID, observation)
+		if err != nil {
+			return err
+		}
+		if isNew {
+			created++
+		}
+	}
+
+	batch.Rejections = rejections
+	batch.RejectedCount = len(rejections)
+	batch.AcceptedCount = len(batch.Discoveries) - len(rejections)
+	if err := s.repo.CompleteDiscoveryBatch(batch); err != nil {
+		return err
+	}
+
+	log.Printf("📥 Ingested batch %s from sensor %s: %d discoveries, %d endpoints (%d new), %d rejected",
+		batch.BatchID, batch.SensorID, batch.AcceptedCount, len(observations), created, batch.RejectedCount)
+	return nil
+}
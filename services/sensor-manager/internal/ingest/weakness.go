@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"strings"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// minRSAKeySize is the smallest RSA key not reported as weak
+const minRSAKeySize = 2048
+
+// weakCipherMarkers are cipher suite name fragments that make a suite weak,
+// with the weakness reported for each
+var weakCipherMarkers = []struct {
+	marker   string
+	weakness string
+}{
+	{"NULL", "null cipher"},
+	{"EXPORT", "export-grade cipher"},
+	{"ANON", "anonymous key exchange"},
+	{"RC4", "RC4 cipher"},
+	{"3DES", "3DES cipher"},
+	{"DES_CBC", "DES cipher"},
+	{"DES-CBC", "DES cipher"},
+	{"MD5", "MD5 MAC"},
+}
+
+// Weaknesses returns the well-known weaknesses of an observed
+// implementation: deprecated protocol versions, broken cipher suites and a
+// leaf certificate with a short RSA key or a broken signature hash. It is a
+// coarse first check for alerting; the risk analysis scores implementations
+// in full.
+func Weaknesses(observation *models.EndpointObservation) []string {
+	weaknesses := []string{}
+
+	switch observation.Protocol {
+	case "TLS":
+		switch {
+		case strings.HasPrefix(observation.Version, "SSL"):
+			weaknesses = append(weaknesses, observation.Version+" protocol")
+		case observation.Version == "1.0" || observation.Version == "1.1":
+			weaknesses = append(weaknesses, "TLS "+observation.Version+" protocol")
+		}
+	case "SSH":
+		if strings.HasPrefix(observation.Version, "1.") && observation.Version != "1.99" {
+			weaknesses = append(weaknesses, "SSH "+observation.Version+" protocol")
+		}
+	}
+
+	suite := strings.ToUpper(observation.CipherSuite)
+	for _, weak := range weakCipherMarkers {
+		if strings.Contains(suite, weak.marker) && !containsString(weaknesses, weak.weakness) {
+			// 3DES contains DES; report it once
+			if weak.weakness == "DES cipher" && strings.Contains(suite, "3DES") {
+				continue
+			}
+			weaknesses = append(weaknesses, weak.weakness)
+		}
+	}
+
+	if len(observation.Certificates) > 0 {
+		leaf := observation.Certificates[0]
+		if leaf.PublicKeyAlgorithm == "RSA" && leaf.PublicKeySize > 0 && leaf.PublicKeySize < minRSAKeySize {
+			weaknesses = append(weaknesses, "short RSA key")
+		}
+		signature := strings.ToUpper(leaf.SignatureAlgorithm)
+		if strings.HasPrefix(signature, "MD5") || strings.HasPrefix(signature, "SHA1") {
+			weaknesses = append(weaknesses, "weak certificate signature")
+		}
+	}
+	return weaknesses
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Diagnostic capture statuses. A capture is pending until the sensor
+// uploads it, and failed when its command failed or expired first.
+const (
+	CaptureStatusPending   = "pending"
+	CaptureStatusAvailable = "available"
+	CaptureStatusFailed    = "failed"
+	CaptureStatusExpired   = "expired" // the uploaded pcap was deleted
+)
+
+// DiagnosticCapture is an on-demand, redacted packet capture of a sensor
+type DiagnosticCapture struct {
+	ID              string     `json:"id"`
+	TenantID        string     `json:"tenant_id"`
+	SensorID        string     `json:"sensor_id"`
+	SensorName      string     `json:"sensor_name"`
+	CommandID       string     `json:"command_id,omitempty"`
+	Status          string     `json:"status"`
+	Error           string     `json:"error,omitempty"`
+	Interface       string     `json:"interface,omitempty"`
+	BPFFilter       string     `json:"bpf_filter"`
+	DurationSeconds int        `json:"duration_seconds"`
+	MaxBytes        int64      `json:"max_bytes"`
+	Reason          string     `json:"reason,omitempty"`
+	RequestedBy     string     `json:"requested_by,omitempty"`
+	Size            int64      `json:"size,omitempty"` // of the sealed upload
+	SHA256          string     `json:"sha256,omitempty"`
+	Packets         int        `json:"packets,omitempty"`
+	StorageKey      string     `json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UploadedAt      *time.Time `json:"uploaded_at,omitempty"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+}
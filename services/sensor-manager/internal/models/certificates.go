@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// Certificate expiry reminder statuses and why a reminder was closed
+const (
+	ReminderStatusOpen      = "open"
+	ReminderStatusClosed    = "closed"
+	ReminderClosedRenewed   = "renewed"          // a renewed certificate is served instead
+	ReminderClosedNotServed = "no_longer_served" // the endpoint stopped presenting the certificate
+)
+
+// Certificate history event types
+const (
+	CertificateHistoryExpiryNotified = "expiry_notified"
+	CertificateHistoryExpiredInUse   = "expired_in_use"
+	CertificateHistoryRenewed        = "renewed"    // replaced by the related certificate
+	CertificateHistoryRenewalOf      = "renewal_of" // replaced the related certificate
+	CertificateHistoryNoLongerServed = "no_longer_served"
+)
+
+// ServedCertificate is a certificate presented on an endpoint (an asset
+// and port), with the open expiry reminder for it if any
+type ServedCertificate struct {
+	TenantID                string
+	CertificateID           string
+	FingerprintSHA256       string
+	CommonName              string
+	SubjectAlternativeNames []string
+	NotAfter                time.Time
+	AssetID                 string
+	Hostname                string
+	IPAddress               string
+	Port                    int
+	OwnerEmail              string
+	LastSeenAt              time.Time // last time the endpoint was seen presenting it
+
+	ReminderID        string // empty when no reminder is open
+	ReminderThreshold int
+	ExpiredInUse      bool
+}
+
+// ExpiryReminder is an open expiry reminder checked for renewal
+type ExpiryReminder struct {
+	ID                      string
+	TenantID                string
+	CertificateID           string
+	FingerprintSHA256       string
+	CommonName              string
+	SubjectAlternativeNames []string
+	NotAfter                time.Time
+	AssetID                 string
+	Hostname                string
+	IPAddress               string
+	Port                    int
+	OwnerEmail              string
+	LastSeenAt              *time.Time // last time the endpoint was seen presenting the certificate
+	Served                  bool       // a live crypto implementation of the endpoint still links it
+}
+
+// EndpointCertificate is a certificate an endpoint was seen presenting
+type EndpointCertificate struct {
+	CertificateID           string
+	FingerprintSHA256       string
+	CommonName              string
+	SubjectAlternativeNames []string
+	NotAfter                time.Time
+	LastSeenAt              time.Time
+}
+
+// CertificateExpiryAlert is the payload of certificate.expiring and
+// certificate.expired events, one per certificate and endpoint, addressed
+// to the asset owner
+type CertificateExpiryAlert struct {
+	CertificateID           string    `json:"certificate_id"`
+	FingerprintSHA256       string    `json:"fingerprint_sha256"`
+	CommonName              string    `json:"common_name,omitempty"`
+	SubjectAlternativeNames []string  `json:"subject_alternative_names"`
+	NotAfter                time.Time `json:"not_after"`
+	DaysUntilExpiry         int       `json:"days_until_expiry"` // negative once expired
+	ThresholdDays           int       `json:"threshold_days"`    // 0 once expired
+	Expired                 bool      `json:"expired"`
+	StillServed             bool      `json:"still_served"` // seen on the endpoint after it expired
+	AssetID                 string    `json:"asset_id"`
+	Hostname                string    `json:"hostname,omitempty"`
+	IPAddress               string    `json:"ip_address,omitempty"`
+	Port                    int       `json:"port,omitempty"`
+	OwnerEmail              string    `json:"owner_email,omitempty"`
+	LastSeenAt              time.Time `json:"last_seen_at"`
+}
+
+// CertificateRenewalAlert is the payload of certificate.renewed events
+type CertificateRenewalAlert struct {
+	CertificateID           string    `json:"certificate_id"`
+	FingerprintSHA256       string    `json:"fingerprint_sha256"`
+	RenewedByCertificateID  string    `json:"renewed_by_certificate_id"`
+	RenewedByFingerprint    string    `json:"renewed_by_fingerprint_sha256"`
+	CommonName              string    `json:"common_name,omitempty"`
+	SubjectAlternativeNames []string  `json:"subject_alternative_names"`
+	PreviousNotAfter        time.Time `json:"previous_not_after"`
+	NotAfter                time.Time `json:"not_after"`
+	AssetID                 string    `json:"asset_id"`
+	Hostname                string    `json:"hostname,omitempty"`
+	IPAddress               string    `json:"ip_address,omitempty"`
+	Port                    int       `json:"port,omitempty"`
+	OwnerEmail              string    `json:"owner_email,omitempty"`
+	DetectedAt              time.Time `json:"detected_at"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Command lifecycle statuses
+const (
+	CommandStatusQueued       = "queued"
+	CommandStatusDelivered    = "delivered"
+	CommandStatusAcknowledged = "acknowledged"
+	CommandStatusSucceeded    = "succeeded"
+	CommandStatusFailed       = "failed"
+	CommandStatusExpired      = "expired"
+)
+
+// CommandRecord is a command stored in the command queue together with its
+// delivery state and outcome
+type CommandRecord struct {
+	ID                string                 `json:"id"`
+	TenantID          string                 `json:"tenant_id"`
+	SensorID          string                 `json:"sensor_id"`
+	BatchID           string                 `json:"batch_id,omitempty"`
+	Type              string                 `json:"type"`
+	Priority          int                    `json:"priority"`
+	Payload           map[string]interface{} `json:"payload"`
+	RequiresAck       bool                   `json:"requires_ack"`
+	Status            string                 `json:"status"`
+	Attempts          int                    `json:"attempts"`
+	MaxAttempts       int                    `json:"max_attempts"`
+	AckTimeoutSeconds int                    `json:"ack_timeout_seconds"`
+	ExpiresAt         time.Time              `json:"expires_at"`
+	DeliveredAt       *time.Time             `json:"delivered_at,omitempty"`
+	AcknowledgedAt    *time.Time             `json:"acknowledged_at,omitempty"`
+	CompletedAt       *time.Time             `json:"completed_at,omitempty"`
+	Result            json.RawMessage        `json:"result,omitempty"`
+	Error             string                 `json:"error,omitempty"`
+	CreatedBy         string                 `json:"created_by,omitempty"`
+	CreatedAt         time.Time              `json:"created_at"`
+	UpdatedAt         time.Time              `json:"updated_at"`
+	Events            []CommandEvent         `json:"events,omitempty"`
+}
+
+// Terminal reports whether the command has reached a final state
+func (c *CommandRecord) Terminal() bool {
+	switch c.Status {
+	case CommandStatusSucceeded, CommandStatusFailed, CommandStatusExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// ToCommand converts the record to the wire format delivered to sensors
+func (c *CommandRecord) ToCommand() Command {
+	return Command{
+		ID:          c.ID,
+		Type:        c.Type,
+		Priority:    c.Priority,
+		Payload:     c.Payload,
+		RequiresAck: c.RequiresAck,
+	}
+}
+
+// CommandEvent records a single state transition of a command
+type CommandEvent struct {
+	Status    string    `json:"status"`
+	Attempt   int       `json:"attempt"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CommandResult is reported by a sensor when it acknowledges a command or
+// finishes executing it. Status is acknowledged, succeeded or failed.
+type CommandResult struct {
+	CommandID string                 `json:"command_id"`
+	SensorID  string                 `json:"sensor_id"`
+	Status    string                 `json:"status"`
+	Result    map[string]interface{} `json:"result,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// CommandFilters represents filters for querying command history
+type CommandFilters struct {
+	SensorID string
+	BatchID  string
+	Status   string
+	Type     string
+	Page     int
+	PageSize int
+}
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// ConfigSettings is a partial sensor configuration. Profiles define it and
+// sensors override it; fields left unset are inherited from the layer below
+// (built-in defaults <- profile <- sensor overrides).
+type ConfigSettings struct {
+	ReportingInterval *int             `json:"reporting_interval,omitempty"` // seconds
+	Interfaces        []string         `json:"interfaces,omitempty"`
+	PortMap           map[string][]int `json:"port_map,omitempty"` // protocol (tls, ssh) -> ports
+	Features          map[string]bool  `json:"features,omitempty"`
+	Storage           *StorageLimits   `json:"storage,omitempty"`
+	Capture           *CaptureLimits   `json:"capture,omitempty"`
+}
+
+// StorageLimits bounds the sensor's local encrypted storage
+type StorageLimits struct {
+	MaxStorageSize *int64 `json:"max_storage_size,omitempty"` // bytes
+	RotationSize   *int64 `json:"rotation_size,omitempty"`    // bytes
+	RetentionDays  *int   `json:"retention_days,omitempty"`
+}
+
+// CaptureLimits bounds packet capture and active probing
+type CaptureLimits struct {
+	MaxConnections *int `json:"max_connections,omitempty"`
+	TimeoutSeconds *int `json:"timeout_seconds,omitempty"`
+}
+
+// ConfigProfile is a tenant-defined, versioned sensor configuration
+type ConfigProfile struct {
+	ID             string         `json:"id"`
+	TenantID       string         `json:"tenant_id"`
+	Name           string         `json:"name"`
+	Description    string         `json:"description,omitempty"`
+	CurrentVersion int            `json:"current_version"`
+	Settings       ConfigSettings `json:"settings"` // settings of the current version
+	GroupCount     int            `json:"group_count"`
+	CreatedBy      string         `json:"created_by,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// ConfigProfileVersion is an immutable version of a profile's settings
+type ConfigProfileVersion struct {
+	ProfileID  string         `json:"profile_id"`
+	Version    int            `json:"version"`
+	Settings   ConfigSettings `json:"settings"`
+	ChangeNote string         `json:"change_note,omitempty"`
+	CreatedBy  string         `json:"created_by,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// SensorGroup assigns a configuration profile to a set of sensors
+type SensorGroup struct {
+	ID             string    `json:"id"`
+	TenantID       string    `json:"tenant_id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description,omitempty"`
+	ProfileID      string    `json:"profile_id,omitempty"`
+	ProfileName    string    `json:"profile_name,omitempty"`
+	ProfileVersion *int      `json:"profile_version,omitempty"` // pinned version; nil follows the current one
+	SensorCount    int       `json:"sensor_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// VersionedSensorConfig is the effective configuration served to a sensor
+// and pushed with update_config commands
+type VersionedSensorConfig struct {
+	SensorConfig
+	PortMap        map[string][]int `json:"port_map"`
+	ConfigVersion  int              `json:"config_version"`
+	GroupID        string           `json:"group_id,omitempty"`
+	ProfileID      string           `json:"profile_id,omitempty"`
+	ProfileVersion int              `json:"profile_version,omitempty"`
+}
+
+// SensorConfigState describes a sensor's configuration layers and whether
+// the sensor has applied the latest version
+type SensorConfigState struct {
+	SensorID             string                `json:"sensor_id"`
+	GroupID              string                `json:"group_id,omitempty"`
+	Overrides            ConfigSettings        `json:"overrides"`
+	Desired              VersionedSensorConfig `json:"desired"`
+	AppliedConfigVersion *int                  `json:"applied_config_version,omitempty"`
+	ConfigAppliedAt      *time.Time            `json:"config_applied_at,omitempty"`
+	InSync               bool                  `json:"in_sync"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Sensor health states, derived by the fleet health evaluator
+const (
+	HealthStateUnknown  = "unknown" // registered but never evaluated
+	HealthStateHealthy  = "healthy"
+	HealthStateDegraded = "degraded"
+	HealthStateOffline  = "offline"
+)
+
+// Health reason codes
+const (
+	HealthReasonHeartbeatOverdue = "heartbeat_overdue" // heartbeats late; degraded
+	HealthReasonHeartbeatLost    = "heartbeat_lost"    // heartbeats stopped; offline
+	HealthReasonPacketDrops      = "packet_drop_rate"
+	HealthReasonStorageBacklog   = "storage_backlog"
+	HealthReasonStorageUsage     = "storage_usage"
+	HealthReasonSensorError      = "sensor_error" // the sensor reports itself in error
+)
+
+// HealthReason explains why a sensor is in its health state
+type HealthReason struct {
+	Code      string  `json:"code"`
+	Message   string  `json:"message"`
+	Value     float64 `json:"value,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// SensorHealthState is a sensor's entry in the fleet health listing
+type SensorHealthState struct {
+	SensorID        string         `json:"sensor_id"`
+	Name            string         `json:"name"`
+	GroupID         string         `json:"group_id,omitempty"`
+	Status          string         `json:"status"` // as last reported by the sensor
+	State           string         `json:"state"`
+	Reasons         []HealthReason `json:"reasons"`
+	StateChangedAt  *time.Time     `json:"state_changed_at,omitempty"`
+	LastHeartbeatAt *time.Time     `json:"last_heartbeat_at,omitempty"`
+}
+
+// FleetHealthFilters represents filters for the fleet health listing
+type FleetHealthFilters struct {
+	State    string
+	GroupID  string
+	Page     int
+	PageSize int
+}
+
+// HealthTransition records a change of a sensor's health state
+type HealthTransition struct {
+	ID        string         `json:"id"`
+	TenantID  string         `json:"tenant_id"`
+	SensorID  string         `json:"sensor_id"`
+	FromState string         `json:"from_state"`
+	ToState   string         `json:"to_state"`
+	Reasons   []HealthReason `json:"reasons"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// HealthAlert is the payload of the sensor health events delivered to
+// webhooks when a sensor's health state changes
+type HealthAlert struct {
+	SensorID      string         `json:"sensor_id"`
+	SensorName    string         `json:"sensor_name"`
+	PreviousState string         `json:"previous_state"`
+	State         string         `json:"state"`
+	Reasons       []HealthReason `json:"reasons"`
+	OccurredAt    time.Time      `json:"occurred_at"`
+}
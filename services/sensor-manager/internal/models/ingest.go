@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Discovery batch ingestion statuses
+const (
+	BatchStatusPending    = "pending"
+	BatchStatusProcessing = "processing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusFailed     = "failed"
+)
+
+// Discovery batch sources
+const (
+	BatchSourceBatch  = "batch"
+	BatchSourceExport = "export"
+)
+
+// DiscoveryBatchRecord is a discovery batch stored for ingestion together
+// with its processing state
+type DiscoveryBatchRecord struct {
+	ID             string               `json:"id"`
+	TenantID       string               `json:"tenant_id"`
+	SensorID       string               `json:"sensor_id"`
+	BatchID        string               `json:"batch_id"`
+	Source         string               `json:"source"`
+	Status         string               `json:"status"`
+	DiscoveryCount int                  `json:"discovery_count"`
+	AcceptedCount  int                  `json:"accepted_count"`
+	RejectedCount  int                  `json:"rejected_count"`
+	Attempts       int                  `json:"attempts"`
+	Rejections     []DiscoveryRejection `json:"rejections,omitempty"`
+	LastError      string               `json:"last_error,omitempty"`
+	ReceivedAt     time.Time            `json:"received_at"`
+	StartedAt      *time.Time           `json:"started_at,omitempty"`
+	CompletedAt    *time.Time           `json:"completed_at,omitempty"`
+
+	// Discoveries is only loaded when a batch is claimed for processing
+	Discoveries []CryptoDiscovery `json:"-"`
+}
+
+// DiscoveryRejection explains why a discovery in a batch was not ingested
+type DiscoveryRejection struct {
+	Index       int    `json:"index"`
+	DiscoveryID string `json:"discovery_id,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+// EndpointObservation aggregates the discoveries of one batch that describe
+// the same implementation: one protocol, version and cipher suite served on
+// one port of one host
+type EndpointObservation struct {
+	IPAddress       string
+	Hostname        string
+	Port            int
+	Protocol        string // protocol_type enum value
+	Version         string
+	CipherSuite     string
+	DiscoveryMethod string // discovery_method enum value
+	Confidence      float64
+	FirstSeen       time.Time
+	LastSeen        time.Time
+	Certificates    []*ObservedCertificate // leaf first
+	RawData         map[string]interface{}
+	Weaknesses      []string // well-known weaknesses, alerted on when the implementation is new
+}
+
+// ObservedCertificate is a certificate presented by an observed endpoint
+type ObservedCertificate struct {
+	SerialNumber            string
+	SubjectDN               string
+	IssuerDN                string
+	CommonName              string
+	SubjectAlternativeNames []string
+	SignatureAlgorithm      string
+	PublicKeyAlgorithm      string
+	PublicKeySize           int
+	NotBefore               time.Time
+	NotAfter                time.Time
+	FingerprintSHA1         string
+	FingerprintSHA256       string
+	SubjectKeyID            string // hex, empty when absent
+	AuthorityKeyID          string // hex, empty when absent
+	PEM                     string
+	IsSelfSigned            bool
+	IsCA                    bool
+	KeyUsage                []string
+	ExtendedKeyUsage        []string
+}
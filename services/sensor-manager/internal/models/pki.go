@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// CertificateAuthorityRecord is a tenant CA as stored in the database. The
+// private key is only ever stored encrypted.
+type CertificateAuthorityRecord struct {
+	TenantID            string
+	KeyAlgorithm        string
+	CertificatePEM      string
+	EncryptedPrivateKey []byte
+	NotBefore           time.Time
+	NotAfter            time.Time
+}
+
+// SensorCertificate describes a client certificate issued to a sensor
+type SensorCertificate struct {
+	SerialNumber      string     `json:"serial_number"`
+	TenantID          string     `json:"tenant_id"`
+	SensorID          string     `json:"sensor_id"`
+	FingerprintSHA256 string     `json:"fingerprint_sha256"`
+	NotBefore         time.Time  `json:"not_before"`
+	NotAfter          time.Time  `json:"not_after"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	RevocationReason  *int       `json:"revocation_reason,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// Revoked reports whether the certificate has been revoked
+func (c *SensorCertificate) Revoked() bool {
+	return c.RevokedAt != nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Registration key statuses
+const (
+	PendingStatusPending = "pending"
+	PendingStatusUsed    = "used" // all sensors the key allows have registered
+	PendingStatusExpired = "expired"
+	PendingStatusRevoked = "revoked"
+)
+
+// Registered sensor statuses (sensor_status enum)
+const (
+	SensorStatusActive      = "active"
+	SensorStatusInactive    = "inactive"
+	SensorStatusError       = "error"
+	SensorStatusMaintenance = "maintenance"
+)
+
+// PendingSensor represents a registration key issued for sensors that will
+// be installed later. Only a hash of the key is stored; RegistrationKey is
+// set once, in the response to the request that issued it.
+type PendingSensor struct {
+	ID                string     `json:"id"`
+	TenantID          string     `json:"tenant_id"`
+	RegistrationKey   string     `json:"registration_key,omitempty"`
+	KeyPrefix         string     `json:"key_prefix"` // identifies the key without revealing it
+	Name              string     `json:"name"`
+	Description       string     `json:"description,omitempty"`
+	AllowedCIDRs      []string   `json:"allowed_cidrs"` // networks sensors must register from
+	Tags              []string   `json:"tags"`
+	Profile           string     `json:"profile"`
+	NetworkInterfaces []string   `json:"network_interfaces"`
+	GroupID           string     `json:"group_id,omitempty"` // group registered sensors join
+	MaxSensors        int        `json:"max_sensors"`
+	UsedCount         int        `json:"used_count"`
+	CreatedBy         string     `json:"created_by,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	Status            string     `json:"status"` // pending, used, expired, revoked
+	UsedAt            *time.Time `json:"used_at,omitempty"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AdminSettings represents a tenant's registration settings
+type AdminSettings struct {
+	KeyExpirationMinutes int  `json:"key_expiration_minutes"`
+	MaxPendingSensors    int  `json:"max_pending_sensors"`
+	RequireIPValidation  bool `json:"require_ip_validation"` // registration keys must be bound to networks
+}
+
+// Registration attempt outcomes
+const (
+	RegistrationAccepted = "accepted"
+	RegistrationRejected = "rejected"
+)
+
+// RegistrationAttempt is an entry of the registration audit log
+type RegistrationAttempt struct {
+	ID                string    `json:"id"`
+	TenantID          string    `json:"tenant_id,omitempty"`
+	RegistrationKeyID string    `json:"registration_key_id,omitempty"`
+	SensorID          string    `json:"sensor_id,omitempty"`
+	KeyPrefix         string    `json:"key_prefix,omitempty"`
+	SensorName        string    `json:"sensor_name,omitempty"`
+	DeclaredIP        string    `json:"declared_ip,omitempty"`   // address the sensor reported
+	ClientIP          string    `json:"client_ip,omitempty"`     // address the request was observed from
+	RemoteAddr        string    `json:"remote_addr,omitempty"`   // TCP peer, e.g. a load balancer
+	ForwardedFor      string    `json:"forwarded_for,omitempty"` // X-Forwarded-For as received
+	AllowedCIDRs      []string  `json:"allowed_cidrs"`
+	Outcome           string    `json:"outcome"`          // accepted or rejected
+	Reason            string    `json:"reason,omitempty"` // machine-readable rejection reason
+	Detail            string    `json:"detail,omitempty"`
+	AttemptedAt       time.Time `json:"attempted_at"`
+}
+
+// RegisteredSensor represents a sensor stored in the sensors table
+type RegisteredSensor struct {
+	ID                string          `json:"id"`
+	TenantID          string          `json:"tenant_id"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description,omitempty"`
+	Platform          string          `json:"platform,omitempty"`
+	Version           string          `json:"version,omitempty"`
+	Profile           string          `json:"profile,omitempty"`
+	IPAddress         string          `json:"ip_address,omitempty"`
+	Hostname          string          `json:"hostname,omitempty"`
+	NetworkInterfaces []string        `json:"network_interfaces"`
+	Tags              []string        `json:"tags"`
+	Status            string          `json:"status"`
+	RegistrationKeyID string          `json:"registration_key_id,omitempty"`
+	GroupID           string          `json:"group_id,omitempty"`
+	ConfigOverrides   ConfigSettings  `json:"config_overrides"`
+	Configuration     json.RawMessage `json:"configuration,omitempty"`
+	ConfigVersion     int             `json:"config_version"`
+	AppliedVersion    *int            `json:"applied_config_version,omitempty"`
+	ConfigAppliedAt   *time.Time      `json:"config_applied_at,omitempty"`
+	LastHealth        json.RawMessage `json:"last_health,omitempty"`
+	LastHeartbeatAt   *time.Time      `json:"last_heartbeat_at,omitempty"`
+	HealthState       string          `json:"health_state"`
+	HealthReasons     []HealthReason  `json:"health_reasons"`
+	HealthChangedAt   *time.Time      `json:"health_changed_at,omitempty"`
+	RegisteredAt      *time.Time      `json:"registered_at,omitempty"`
+	DecommissionedAt  *time.Time      `json:"decommissioned_at,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+// Decommissioned reports whether the sensor has been retired from the fleet
+func (s *RegisteredSensor) Decommissioned() bool {
+	return s.DecommissionedAt != nil
+}
+
+// SensorFilters represents filters for listing registered sensors
+type SensorFilters struct {
+	Status                string
+	Profile               string
+	Tag                   string
+	GroupID               string
+	Search                string
+	IncludeDecommissioned bool
+	Page                  int
+	PageSize              int
+}
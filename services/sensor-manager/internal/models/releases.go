@@ -0,0 +1,96 @@
+package models
+
+import "time"
+
+// Rollout statuses
+const (
+	RolloutStatusRunning   = "running"
+	RolloutStatusPaused    = "paused"
+	RolloutStatusCompleted = "completed"
+	RolloutStatusCancelled = "cancelled"
+)
+
+// Rollout target statuses
+const (
+	RolloutTargetPending   = "pending"
+	RolloutTargetUpgrading = "upgrading"
+	RolloutTargetSucceeded = "succeeded"
+	RolloutTargetFailed    = "failed"
+	RolloutTargetSkipped   = "skipped"
+)
+
+// SensorRelease is a sensor software version in the release catalog
+type SensorRelease struct {
+	ID          string             `json:"id"`
+	Version     string             `json:"version"`
+	Notes       string             `json:"notes,omitempty"`
+	PublishedBy string             `json:"published_by,omitempty"`
+	Artifacts   []*ReleaseArtifact `json:"artifacts"`
+	CreatedAt   time.Time          `json:"created_at"`
+	YankedAt    *time.Time         `json:"yanked_at,omitempty"`
+	YankReason  string             `json:"yank_reason,omitempty"`
+}
+
+// ReleaseArtifact is the signed binary of a release for one platform
+type ReleaseArtifact struct {
+	ReleaseID  string    `json:"release_id"`
+	Platform   string    `json:"platform"` // GOOS-GOARCH, e.g. linux-amd64
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256"`
+	Signature  string    `json:"signature"` // base64 Ed25519 signature
+	StorageKey string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RolloutStage selects the sensors of one rollout stage: the sensors of a
+// group, listed sensors, or every sensor of the tenant not in an earlier
+// stage
+type RolloutStage struct {
+	Name         string   `json:"name,omitempty"`
+	GroupID      string   `json:"group_id,omitempty"`
+	SensorIDs    []string `json:"sensor_ids,omitempty"`
+	AllRemaining bool     `json:"all_remaining,omitempty"`
+}
+
+// SensorRollout is a staged upgrade of a tenant's sensors to a release
+type SensorRollout struct {
+	ID               string           `json:"id"`
+	TenantID         string           `json:"tenant_id"`
+	ReleaseID        string           `json:"release_id"`
+	Version          string           `json:"version"`
+	Status           string           `json:"status"`
+	Stages           []RolloutStage   `json:"stages"`
+	CurrentStage     int              `json:"current_stage"`
+	MaxFailures      int              `json:"max_failures"`
+	SoakSeconds      int              `json:"soak_seconds"`
+	PauseReason      string           `json:"pause_reason,omitempty"`
+	Progress         map[string]int   `json:"progress"` // target status -> count
+	Targets          []*RolloutTarget `json:"targets,omitempty"`
+	CreatedBy        string           `json:"created_by,omitempty"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	StageCompletedAt *time.Time       `json:"stage_completed_at,omitempty"`
+	CompletedAt      *time.Time       `json:"completed_at,omitempty"`
+}
+
+// RolloutTarget is a sensor upgraded by a rollout
+type RolloutTarget struct {
+	SensorID    string     `json:"sensor_id"`
+	SensorName  string     `json:"sensor_name"`
+	Stage       int        `json:"stage"`
+	Status      string     `json:"status"`
+	CommandID   string     `json:"command_id,omitempty"`
+	FromVersion string     `json:"from_version,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// RolloutPausedAlert is the payload of a sensor.rollout_paused event
+type RolloutPausedAlert struct {
+	RolloutID string `json:"rollout_id"`
+	Version   string `json:"version"`
+	Stage     int    `json:"stage"`
+	Failed    int    `json:"failed"`
+	Reason    string `json:"reason"`
+}
@@ -0,0 +1,200 @@
+package models
+
+import "time"
+
+// Sources of topology observations
+const (
+	TopologySourceInterface  = "interface"  // address of a capture interface
+	TopologySourceObserved   = "observed"   // seen in captured traffic
+	TopologySourceConfigured = "configured" // from the sensor configuration
+	TopologySourceRoute      = "route"      // from the sensor host's routing table
+)
+
+// TopologyReport is what a sensor observed during one reporting window:
+// subnets, VLAN tags, gateways and flows between subnets. Counters cover
+// the window only.
+type TopologyReport struct {
+	SensorID    string            `json:"sensor_id" binding:"required"`
+	WindowStart time.Time         `json:"window_start"`
+	WindowEnd   time.Time         `json:"window_end"`
+	Subnets     []ObservedSubnet  `json:"subnets"`
+	VLANs       []ObservedVLAN    `json:"vlans"`
+	Gateways    []ObservedGateway `json:"gateways"`
+	Flows       []ObservedFlow    `json:"flows"`
+}
+
+// ObservedSubnet is a subnet on a sensor's interface or in its traffic
+type ObservedSubnet struct {
+	CIDR      string `json:"cidr"`
+	VLAN      int    `json:"vlan"`
+	Source    string `json:"source"`
+	Interface string `json:"interface"`
+	Hosts     int    `json:"hosts"`
+	Packets   int64  `json:"packets"`
+}
+
+// ObservedVLAN is an 802.1Q tag a sensor saw or is configured with
+type ObservedVLAN struct {
+	ID      int    `json:"id"`
+	Source  string `json:"source"`
+	Packets int64  `json:"packets"`
+}
+
+// ObservedGateway is a router next to a sensor, known by IP or MAC address
+type ObservedGateway struct {
+	IP        string `json:"ip"`
+	MAC       string `json:"mac"`
+	VLAN      int    `json:"vlan"`
+	Source    string `json:"source"`
+	Interface string `json:"interface"`
+	Subnets   int    `json:"subnets"`
+}
+
+// ObservedFlow is encrypted traffic from one subnet to a service port in
+// another
+type ObservedFlow struct {
+	SrcCIDR  string `json:"src_cidr"`
+	DstCIDR  string `json:"dst_cidr"`
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+	VLAN     int    `json:"vlan"`
+	Packets  int64  `json:"packets"`
+}
+
+// SensorSubnet is a stored subnet observation of a sensor
+type SensorSubnet struct {
+	SensorID    string    `json:"sensor_id"`
+	SensorName  string    `json:"sensor_name"`
+	CIDR        string    `json:"cidr"`
+	VLAN        int       `json:"vlan"`
+	Source      string    `json:"source"`
+	Interface   string    `json:"interface,omitempty"`
+	Hosts       int       `json:"hosts"`
+	Packets     int64     `json:"packets"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// SensorGateway is a stored gateway observation of a sensor
+type SensorGateway struct {
+	SensorID   string    `json:"sensor_id"`
+	SensorName string    `json:"sensor_name"`
+	IP         string    `json:"ip,omitempty"`
+	MAC        string    `json:"mac,omitempty"`
+	VLAN       int       `json:"vlan"`
+	Source     string    `json:"source"`
+	Interface  string    `json:"interface,omitempty"`
+	Subnets    int       `json:"subnets"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// SensorFlow is a stored flow observation of a sensor
+type SensorFlow struct {
+	SensorID   string    `json:"sensor_id"`
+	SrcCIDR    string    `json:"src_cidr"`
+	DstCIDR    string    `json:"dst_cidr"`
+	Protocol   string    `json:"protocol"`
+	Port       int       `json:"port"`
+	VLAN       int       `json:"vlan"`
+	Packets    int64     `json:"packets"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// TopologyAsset is a network asset with an address, placed in the
+// topology by its /24 or /64 subnet
+type TopologyAsset struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname,omitempty"`
+	IP       string `json:"ip"`
+	Subnet   string `json:"subnet"`
+}
+
+// AssetSubnet is a subnet holding known assets
+type AssetSubnet struct {
+	CIDR   string `json:"cidr"`
+	Assets int    `json:"assets"`
+}
+
+// TopologyFilters selects the observations the topology is built from
+type TopologyFilters struct {
+	Since    time.Time // observations last seen before are left out
+	SensorID string
+}
+
+// CoverageSensor is a sensor seeing a subnet
+type CoverageSensor struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SubnetCoverage tells which sensors see a subnet and how many known
+// assets it holds
+type SubnetCoverage struct {
+	CIDR       string           `json:"cidr"`
+	VLANs      []int            `json:"vlans"`
+	Sensors    []CoverageSensor `json:"sensors"`
+	Assets     int              `json:"assets"`
+	Covered    bool             `json:"covered"`
+	LastSeenAt *time.Time       `json:"last_seen_at,omitempty"`
+}
+
+// CoverageSummary sums up the coverage of known asset subnets
+type CoverageSummary struct {
+	Sensors            int     `json:"sensors"`          // sensors reporting topology
+	ObservedSubnets    int     `json:"observed_subnets"` // subnets seen by any sensor
+	AssetSubnets       int     `json:"asset_subnets"`    // /24 and /64 subnets holding assets
+	CoveredSubnets     int     `json:"covered_subnets"`
+	UncoveredSubnets   int     `json:"uncovered_subnets"`
+	CoveragePercentage float64 `json:"coverage_percentage"` // of asset subnets
+}
+
+// TopologyCoverage is the sensor coverage model of a tenant
+type TopologyCoverage struct {
+	Summary CoverageSummary `json:"summary"`
+	// Subnets seen by sensors, with the assets they hold
+	Subnets []SubnetCoverage `json:"subnets"`
+	// Asset subnets no sensor sees
+	Uncovered []SubnetCoverage `json:"uncovered"`
+	Since     time.Time        `json:"since"`
+}
+
+// Topology graph node and edge types
+const (
+	TopologyNodeSensor  = "sensor"
+	TopologyNodeSubnet  = "subnet"
+	TopologyNodeGateway = "gateway"
+	TopologyNodeAsset   = "asset"
+
+	TopologyEdgeObserves = "observes"  // sensor -> subnet
+	TopologyEdgeGateway  = "gateway"   // gateway -> sensor next to it
+	TopologyEdgeMember   = "member_of" // asset -> subnet
+	TopologyEdgeFlow     = "flow"      // subnet -> subnet
+)
+
+// TopologyNode is a sensor, subnet, gateway or asset in the topology graph
+type TopologyNode struct {
+	ID    string                 `json:"id"` // <type>:<identifier>
+	Type  string                 `json:"type"`
+	Label string                 `json:"label"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// TopologyEdge connects two nodes of the topology graph. Flow edges carry
+// the packets, protocols and ports observed between two subnets.
+type TopologyEdge struct {
+	Source    string   `json:"source"`
+	Target    string   `json:"target"`
+	Type      string   `json:"type"`
+	Packets   int64    `json:"packets,omitempty"`
+	Protocols []string `json:"protocols,omitempty"`
+	Ports     []int    `json:"ports,omitempty"`
+}
+
+// TopologyGraph is the network topology as seen by a tenant's sensors
+type TopologyGraph struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+	// AssetsTruncated is set when not every asset is part of the graph
+	AssetsTruncated bool      `json:"assets_truncated"`
+	Since           time.Time `json:"since"`
+}
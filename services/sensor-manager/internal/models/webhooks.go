@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types
+const (
+	EventSensorDegraded         = "sensor.degraded"
+	EventSensorOffline          = "sensor.offline"
+	EventSensorRecovered        = "sensor.recovered"
+	EventRolloutPaused          = "sensor.rollout_paused"    // a release rollout paused on failures
+	EventWeakCryptoDetected     = "crypto.weak_detected"     // a new implementation uses weak crypto
+	EventCertificateExpiring    = "certificate.expiring"     // a certificate crosses an expiry threshold
+	EventCertificateExpired     = "certificate.expired"      // a certificate expired on an endpoint
+	EventCertificateRenewed     = "certificate.renewed"      // a renewed certificate replaced one on an endpoint
+	EventComplianceScoreDropped = "compliance.score_dropped" // published by the compliance engine through publish_webhook_event
+	EventWebhookTest            = "webhook.test"             // sent on request to a single endpoint
+)
+
+// WebhookEventTypes describes the events webhooks may subscribe to
+var WebhookEventTypes = map[string]string{
+	EventSensorDegraded:         "A sensor became degraded",
+	EventSensorOffline:          "A sensor stopped sending heartbeats",
+	EventSensorRecovered:        "A degraded or offline sensor is healthy again",
+	EventRolloutPaused:          "A sensor release rollout paused after upgrades failed",
+	EventWeakCryptoDetected:     "A newly discovered implementation uses weak cryptography",
+	EventCertificateExpiring:    "A certificate is about to expire",
+	EventCertificateExpired:     "A certificate expired, or is still served after it expired",
+	EventCertificateRenewed:     "An expiring certificate was replaced by its renewal",
+	EventComplianceScoreDropped: "A compliance score dropped",
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryStatusPending    = "pending"
+	DeliveryStatusDelivering = "delivering"
+	DeliveryStatusRetrying   = "retrying"
+	DeliveryStatusSucceeded  = "succeeded"
+	DeliveryStatusDeadLetter = "dead_letter" // ran out of attempts
+)
+
+// WebhookEndpoint is a tenant webhook notified of events. The secret is
+// never returned once stored; HasSecret tells whether one is set.
+type WebhookEndpoint struct {
+	ID          string `json:"id"`
+	TenantID    string `json:"tenant_id"`
+	Description string `json:"description,omitempty"`
+	WebhookConfig
+	HasSecret bool      `json:"has_secret"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookEvent is an event published for delivery. It is also the envelope
+// posted to endpoints, with the event's own payload as data.
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	TenantID  string          `json:"tenant_id"`
+	SensorID  string          `json:"sensor_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookDelivery is the delivery of an event to one endpoint
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	TenantID       string     `json:"tenant_id"`
+	EndpointID     string     `json:"webhook_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	MaxAttempts    int        `json:"max_attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty"`
+
+	// Attempts made so far, loaded for a single delivery
+	AttemptLog []*WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookDeliveryAttempt records one attempt of a delivery
+type WebhookDeliveryAttempt struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int64     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// WebhookDeliveryFilters represents filters for the delivery log
+type WebhookDeliveryFilters struct {
+	EndpointID string
+	Status     string
+	EventType  string
+	Page       int
+	PageSize   int
+}
+
+// WeakCryptoAlert is the payload of crypto.weak_detected events
+type WeakCryptoAlert struct {
+	ImplementationID string    `json:"implementation_id"`
+	AssetID          string    `json:"asset_id"`
+	SensorID         string    `json:"sensor_id"`
+	IPAddress        string    `json:"ip_address,omitempty"`
+	Hostname         string    `json:"hostname,omitempty"`
+	Port             int       `json:"port"`
+	Protocol         string    `json:"protocol"`
+	Version          string    `json:"version,omitempty"`
+	CipherSuite      string    `json:"cipher_suite,omitempty"`
+	Weaknesses       []string  `json:"weaknesses"`
+	DetectedAt       time.Time `json:"detected_at"`
+}
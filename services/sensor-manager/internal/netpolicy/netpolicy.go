@@ -0,0 +1,201 @@
+// Package netpolicy decides where a request really comes from and whether
+// an address is inside the networks a registration key is bound to.
+//
+// Addresses are compared as netip values: IPv4-mapped IPv6 addresses
+// (::ffff:10.0.0.1) match IPv4 networks, zones are ignored, and an IPv6
+// network never matches an IPv4 address by accident of string prefixes.
+package netpolicy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// MaxNetworks bounds the networks a key or the trusted proxy list may hold
+const MaxNetworks = 64
+
+// maxForwardedHops bounds how many X-Forwarded-For entries are examined
+const maxForwardedHops = 32
+
+// Reasons a registration address check fails, recorded in the audit log
+const (
+	ReasonInvalidDeclaredIP = "invalid_declared_ip"
+	ReasonInvalidClientIP   = "invalid_client_ip"
+	ReasonDeclaredOutside   = "declared_ip_outside_allowed_networks"
+	ReasonClientOutside     = "client_ip_outside_allowed_networks"
+)
+
+// MismatchError explains why an address check failed
+type MismatchError struct {
+	Reason string
+	Detail string
+}
+
+func (e *MismatchError) Error() string {
+	return e.Detail
+}
+
+// ParseNetworks parses CIDRs or single addresses (taken as host networks)
+// and returns them in canonical, deduplicated form
+func ParseNetworks(values []string) ([]netip.Prefix, error) {
+	if len(values) > MaxNetworks {
+		return nil, fmt.Errorf("at most %d networks are allowed", MaxNetworks)
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(values))
+	seen := make(map[netip.Prefix]bool, len(values))
+	for _, value := range values {
+		prefix, err := ParseNetwork(value)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes, nil
+}
+
+// ParseNetwork parses a CIDR or a single address. Host bits are masked off,
+// and IPv4-mapped IPv6 networks are converted to IPv4.
+func ParseNetwork(value string) (netip.Prefix, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		addr, err := ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q", value)
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid network %q", value)
+	}
+	if prefix.Addr().Is4In6() {
+		// ::ffff:0:0/96 and narrower map onto IPv4 networks
+		if prefix.Bits() < 96 {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q", value)
+		}
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
+
+// ParseAddr parses an IP address, dropping any zone and unmapping
+// IPv4-mapped IPv6 addresses
+func ParseAddr(value string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(value))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.WithZone("").Unmap(), nil
+}
+
+// Strings formats networks for storage and responses
+func Strings(prefixes []netip.Prefix) []string {
+	values := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		values[i] = prefix.String()
+	}
+	return values
+}
+
+// Contains reports whether addr is inside any of the networks
+func Contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.WithZone("").Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address a request originates from. The peer address
+// is used unless it is a trusted proxy, in which case X-Forwarded-For is
+// walked from the right, skipping further trusted proxies, and the first
+// untrusted hop is the client. Entries left of that hop are client-supplied
+// and never trusted.
+func ClientIP(remoteAddr string, forwardedFor []string, trusted []netip.Prefix) (netip.Addr, error) {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	peer, err := ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid remote address %q", remoteAddr)
+	}
+	if !Contains(trusted, peer) {
+		return peer, nil
+	}
+
+	hops := forwardedHops(forwardedFor)
+	client := peer
+	for i := len(hops) - 1; i >= 0 && len(hops)-i <= maxForwardedHops; i-- {
+		addr, err := ParseAddr(hops[i])
+		if err != nil {
+			// A malformed hop breaks the chain; the last trusted proxy is the
+			// best known origin
+			return client, nil
+		}
+		client = addr
+		if !Contains(trusted, addr) {
+			break
+		}
+	}
+	return client, nil
+}
+
+// forwardedHops splits X-Forwarded-For header values into hops, in order
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// CheckRegistration verifies that both the address a sensor declares and the
+// address its request was observed from are inside the allowed networks. It
+// returns a *MismatchError describing the first failed check.
+func CheckRegistration(allowed []netip.Prefix, declared string, client netip.Addr) error {
+	declaredAddr, err := ParseAddr(declared)
+	if err != nil {
+		return &MismatchError{
+			Reason: ReasonInvalidDeclaredIP,
+			Detail: fmt.Sprintf("declared IP %q is not a valid address", declared),
+		}
+	}
+	if !client.IsValid() {
+		return &MismatchError{Reason: ReasonInvalidClientIP, Detail: "client IP could not be determined"}
+	}
+	if !Contains(allowed, declaredAddr) {
+		return &MismatchError{
+			Reason: ReasonDeclaredOutside,
+			Detail: fmt.Sprintf("declared IP %s is outside %s", declaredAddr, strings.Join(Strings(allowed), ", ")),
+		}
+	}
+	if !Contains(allowed, client) {
+		return &MismatchError{
+			Reason: ReasonClientOutside,
+			Detail: fmt.Sprintf("client IP %s is outside %s", client, strings.Join(Strings(allowed), ", ")),
+		}
+	}
+	return nil
+}
+
+// AsMismatch returns the mismatch behind err, if any
+func AsMismatch(err error) (*MismatchError, bool) {
+	var mismatch *MismatchError
+	ok := errors.As(err, &mismatch)
+	return mismatch, ok
+}
@@ -0,0 +1,228 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// SensorURIPrefix prefixes the sensor ID in the SAN URI of sensor certificates
+const SensorURIPrefix = "urn:crypto-inventory:sensor:"
+
+// caValidity is the lifetime of a tenant CA
+const caValidity = 10 * 365 * 24 * time.Hour
+
+// crlValidity is how long a generated CRL is valid before clients refetch it
+const crlValidity = 24 * time.Hour
+
+// Authority is a tenant's certificate authority with its decrypted key
+type Authority struct {
+	TenantID       string
+	Certificate    *x509.Certificate
+	CertificatePEM string
+	signer         crypto.Signer
+}
+
+// IssuedCertificate is a freshly issued sensor certificate and private key
+type IssuedCertificate struct {
+	CertificatePEM string
+	PrivateKeyPEM  string
+	Record         models.SensorCertificate
+}
+
+// newAuthority creates a self-signed CA for a tenant
+func newAuthority(tenantID, alg string) (*Authority, error) {
+	signer, err := generateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       []string{"Crypto Inventory"},
+			OrganizationalUnit: []string{"Sensor CA"},
+			CommonName:         "Crypto Inventory Sensor CA " + tenantID,
+		},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	return &Authority{
+		TenantID:       tenantID,
+		Certificate:    certificate,
+		CertificatePEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		signer:         signer,
+	}, nil
+}
+
+// IssueSensorCertificate issues a client certificate for a sensor. The
+// sensor ID is carried in the SAN URI so the certificate can be mapped back
+// to the sensor, and the serial number is random.
+func (a *Authority) IssueSensorCertificate(sensorID, sensorName, alg string, validity time.Duration, crlURL string) (*IssuedCertificate, error) {
+	key, err := generateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	sensorURI, err := url.Parse(SensorURIPrefix + sensorID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sensor ID: %w", err)
+	}
+
+	now := time.Now()
+	notAfter := now.Add(validity)
+	if notAfter.After(a.Certificate.NotAfter) {
+		notAfter = a.Certificate.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       []string{"Crypto Inventory"},
+			OrganizationalUnit: []string{"Sensor"},
+			CommonName:         sensorName,
+		},
+		NotBefore:   now.Add(-5 * time.Minute),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		URIs:        []*url.URL{sensorURI},
+	}
+	if crlURL != "" {
+		template.CRLDistributionPoints = []string{crlURL}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.Certificate, key.Public(), a.signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sensor certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sensor key: %w", err)
+	}
+
+	fingerprint := sha256.Sum256(der)
+	return &IssuedCertificate{
+		CertificatePEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		PrivateKeyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		Record: models.SensorCertificate{
+			SerialNumber:      SerialString(serial),
+			TenantID:          a.TenantID,
+			SensorID:          sensorID,
+			FingerprintSHA256: hex.EncodeToString(fingerprint[:]),
+			NotBefore:         template.NotBefore,
+			NotAfter:          notAfter,
+		},
+	}, nil
+}
+
+// Verify checks that a client certificate chains to this CA and is valid
+// for client authentication at the current time
+func (a *Authority) Verify(certificate *x509.Certificate) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(a.Certificate)
+
+	_, err := certificate.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// CreateCRL creates a DER encoded CRL listing the given revoked certificates
+func (a *Authority) CreateCRL(revoked []*models.SensorCertificate) ([]byte, error) {
+	entries := make([]x509.RevocationListEntry, 0, len(revoked))
+	for _, certificate := range revoked {
+		serial, ok := new(big.Int).SetString(certificate.SerialNumber, 16)
+		if !ok || certificate.RevokedAt == nil {
+			continue
+		}
+		entry := x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: *certificate.RevokedAt,
+		}
+		if certificate.RevocationReason != nil {
+			entry.ReasonCode = *certificate.RevocationReason
+		}
+		entries = append(entries, entry)
+	}
+
+	now := time.Now()
+	template := &x509.RevocationList{
+		RevokedCertificateEntries: entries,
+		Number:                    big.NewInt(now.Unix()),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlValidity),
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, a.Certificate, a.signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CRL: %w", err)
+	}
+	return der, nil
+}
+
+// SensorIDFromCertificate extracts the sensor ID from a sensor certificate's
+// SAN URI
+func SensorIDFromCertificate(certificate *x509.Certificate) (string, error) {
+	for _, uri := range certificate.URIs {
+		if value := uri.String(); strings.HasPrefix(value, SensorURIPrefix) {
+			return strings.TrimPrefix(value, SensorURIPrefix), nil
+		}
+	}
+	return "", errors.New("certificate has no sensor URI")
+}
+
+// SerialString formats a certificate serial number as lowercase hex
+func SerialString(serial *big.Int) string {
+	return fmt.Sprintf("%x", serial)
+}
+
+// randomSerial returns a random positive 128-bit serial number
+func randomSerial() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serial, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	// Zero is not a valid serial number
+	return serial.Add(serial, big.NewInt(1)), nil
+}
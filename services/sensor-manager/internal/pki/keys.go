@@ -0,0 +1,96 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Supported key algorithms for CA and sensor keys
+const (
+	AlgorithmECDSAP256 = "ecdsa-p256"
+	AlgorithmEd25519   = "ed25519"
+)
+
+// IsSupportedAlgorithm reports whether alg is a supported key algorithm
+func IsSupportedAlgorithm(alg string) bool {
+	return alg == AlgorithmECDSAP256 || alg == AlgorithmEd25519
+}
+
+// generateKey creates a new private key for the given algorithm
+func generateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case AlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", alg)
+	}
+}
+
+// DeriveEncryptionKey turns the configured CA encryption secret into an
+// AES-256 key. A base64 encoded 32-byte value is used as is; any other
+// value is treated as a passphrase and hashed.
+func DeriveEncryptionKey(secret string) ([]byte, error) {
+	if secret == "" {
+		return nil, errors.New("CA encryption key is not configured")
+	}
+	if key, err := base64.StdEncoding.DecodeString(secret); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:], nil
+}
+
+// seal encrypts plaintext with AES-256-GCM. The nonce is prepended to the
+// ciphertext and additionalData binds the ciphertext to its owner, so a key
+// copied to another tenant's row fails to decrypt.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a value produced by seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
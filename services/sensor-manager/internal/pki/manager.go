@@ -0,0 +1,225 @@
+// Package pki implements the sensor-manager's internal certificate
+// authority. Each tenant gets one long-lived CA whose private key is stored
+// encrypted in the database; sensor client certificates are issued from it
+// at registration and verified against it on every sensor API call.
+package pki
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+)
+
+var (
+	// ErrUnknownCertificate is returned for certificates this CA never issued
+	ErrUnknownCertificate = errors.New("certificate was not issued by sensor-manager")
+	// ErrCertificateRevoked is returned for revoked certificates
+	ErrCertificateRevoked = errors.New("certificate has been revoked")
+)
+
+// Options configures a Manager
+type Options struct {
+	EncryptionKey       []byte        // AES-256 key protecting CA private keys
+	KeyAlgorithm        string        // algorithm for new CA and sensor keys
+	CertificateValidity time.Duration // lifetime of sensor certificates
+	PublicURL           string        // base URL used in CRL distribution points
+}
+
+// Manager loads, creates and caches tenant CAs
+type Manager struct {
+	repo    *repository.Repository
+	options Options
+
+	mu          sync.Mutex
+	authorities map[string]*Authority
+}
+
+// NewManager creates a new CA manager
+func NewManager(repo *repository.Repository, options Options) (*Manager, error) {
+	if len(options.EncryptionKey) != 32 {
+		return nil, errors.New("CA encryption key must be 32 bytes")
+	}
+	if !IsSupportedAlgorithm(options.KeyAlgorithm) {
+		return nil, fmt.Errorf("unsupported key algorithm %q", options.KeyAlgorithm)
+	}
+	if options.CertificateValidity <= 0 {
+		options.CertificateValidity = 365 * 24 * time.Hour
+	}
+
+	return &Manager{
+		repo:        repo,
+		options:     options,
+		authorities: make(map[string]*Authority),
+	}, nil
+}
+
+// Authority returns the CA of a tenant, creating it on first use
+func (m *Manager) Authority(tenantID string) (*Authority, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if authority, ok := m.authorities[tenantID]; ok {
+		return authority, nil
+	}
+
+	record, err := m.repo.GetCertificateAuthority(tenantID)
+	if errors.Is(err, repository.ErrNotFound) {
+		record, err = m.createAuthority(tenantID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	authority, err := m.decodeAuthority(record)
+	if err != nil {
+		return nil, err
+	}
+	m.authorities[tenantID] = authority
+	return authority, nil
+}
+
+// IssueSensorCertificate issues a client certificate for a sensor from its
+// tenant's CA
+func (m *Manager) IssueSensorCertificate(tenantID, sensorID, sensorName string) (*IssuedCertificate, error) {
+	authority, err := m.Authority(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return authority.IssueSensorCertificate(sensorID, sensorName, m.options.KeyAlgorithm,
+		m.options.CertificateValidity, m.CRLURL(tenantID))
+}
+
+// VerifySensorCertificate authenticates a client certificate presented by a
+// sensor. It must carry a sensor URI, be a certificate this service issued
+// to that sensor, chain to the tenant CA and not be revoked.
+func (m *Manager) VerifySensorCertificate(certificate *x509.Certificate) (*models.SensorCertificate, error) {
+	sensorID, err := SensorIDFromCertificate(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := m.repo.GetSensorCertificate(SerialString(certificate.SerialNumber))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUnknownCertificate
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.SensorID != sensorID {
+		return nil, ErrUnknownCertificate
+	}
+
+	authority, err := m.Authority(record.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authority.Verify(certificate); err != nil {
+		return nil, fmt.Errorf("certificate verification failed: %w", err)
+	}
+
+	if record.Revoked() {
+		return nil, ErrCertificateRevoked
+	}
+	return record, nil
+}
+
+// CRL returns the current DER encoded CRL of a tenant
+func (m *Manager) CRL(tenantID string) ([]byte, error) {
+	authority, err := m.Authority(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := m.repo.ListRevokedCertificates(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return authority.CreateCRL(revoked)
+}
+
+// CRLURL returns the CRL distribution point of a tenant
+func (m *Manager) CRLURL(tenantID string) string {
+	if m.options.PublicURL == "" {
+		return ""
+	}
+	return strings.TrimRight(m.options.PublicURL, "/") + "/api/v1/pki/tenants/" + tenantID + "/crl"
+}
+
+// createAuthority generates and stores a new tenant CA. If another replica
+// stored one concurrently, that one is returned instead.
+func (m *Manager) createAuthority(tenantID string) (*models.CertificateAuthorityRecord, error) {
+	authority, err := newAuthority(tenantID, m.options.KeyAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(authority.signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode CA key: %w", err)
+	}
+	encryptedKey, err := seal(m.options.EncryptionKey, keyDER, []byte(tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt CA key: %w", err)
+	}
+
+	record := &models.CertificateAuthorityRecord{
+		TenantID:            tenantID,
+		KeyAlgorithm:        m.options.KeyAlgorithm,
+		CertificatePEM:      authority.CertificatePEM,
+		EncryptedPrivateKey: encryptedKey,
+		NotBefore:           authority.Certificate.NotBefore,
+		NotAfter:            authority.Certificate.NotAfter,
+	}
+
+	created, err := m.repo.CreateCertificateAuthority(record)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return m.repo.GetCertificateAuthority(tenantID)
+	}
+
+	log.Printf("🔐 Created sensor CA for tenant %s (%s)", tenantID, m.options.KeyAlgorithm)
+	return record, nil
+}
+
+// decodeAuthority parses a stored CA and decrypts its private key
+func (m *Manager) decodeAuthority(record *models.CertificateAuthorityRecord) (*Authority, error) {
+	block, _ := pem.Decode([]byte(record.CertificatePEM))
+	if block == nil {
+		return nil, errors.New("stored CA certificate is not valid PEM")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored CA certificate: %w", err)
+	}
+
+	keyDER, err := open(m.options.EncryptionKey, record.EncryptedPrivateKey, []byte(record.TenantID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt CA key: %w", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("stored CA key cannot sign")
+	}
+
+	return &Authority{
+		TenantID:       record.TenantID,
+		Certificate:    certificate,
+		CertificatePEM: record.CertificatePEM,
+		signer:         signer,
+	}, nil
+}
@@ -0,0 +1,173 @@
+// Package releases maintains the sensor release catalog. The build pipeline
+// publishes a release per version and uploads one binary per platform,
+// signed with an offline Ed25519 release key. The catalog only accepts
+// binaries whose signature verifies against the configured release keys,
+// stores them content-addressed on disk and serves them to sensors, which
+// verify the signature again against the keys they pin before installing.
+package releases
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+)
+
+// MaxBinarySize caps the size of an uploaded release binary
+const MaxBinarySize = 256 << 20
+
+// signatureContext prefixes every signed release message; sensors build
+// the same message
+const signatureContext = "crypto-sensor-release:v1"
+
+var (
+	// ErrNoSigningKeys is returned when no release keys are configured
+	ErrNoSigningKeys = errors.New("no release signing keys configured")
+	// ErrInvalidSignature is returned when a binary's signature does not
+	// verify against any release key
+	ErrInvalidSignature = errors.New("release signature does not match any release key")
+	// ErrTooLarge is returned when an uploaded binary exceeds MaxBinarySize
+	ErrTooLarge = errors.New("release binary is too large")
+	// ErrEmpty is returned when an uploaded binary is empty
+	ErrEmpty = errors.New("release binary is empty")
+)
+
+var (
+	versionPattern  = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+([-+][0-9A-Za-z.-]+)?$`)
+	platformPattern = regexp.MustCompile(`^[a-z0-9]+-[a-z0-9]+$`)
+)
+
+// ValidVersion reports whether a version is a semantic version
+func ValidVersion(version string) bool {
+	return len(version) <= 50 && versionPattern.MatchString(version)
+}
+
+// ValidPlatform reports whether a platform is of the form GOOS-GOARCH
+func ValidPlatform(platform string) bool {
+	return len(platform) <= 50 && platformPattern.MatchString(platform)
+}
+
+// SignedMessage returns the message release keys sign for a binary. It
+// binds the digest to the version and platform, so a validly signed binary
+// cannot be offered as another version or for another platform.
+func SignedMessage(version, platform, digest string) []byte {
+	return []byte(signatureContext + "\n" + version + "\n" + platform + "\n" + strings.ToLower(digest))
+}
+
+// Catalog stores release binaries and their metadata
+type Catalog struct {
+	repo *repository.Repository
+	dir  string
+	keys []ed25519.PublicKey
+}
+
+// NewCatalog creates a catalog storing binaries in dir and accepting
+// binaries signed with one of the given base64 Ed25519 public keys
+func NewCatalog(repo *repository.Repository, dir string, encodedKeys []string) (*Catalog, error) {
+	keys := make([]ed25519.PublicKey, 0, len(encodedKeys))
+	for _, encoded := range encodedKeys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid release signing key %q", encoded)
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return &Catalog{repo: repo, dir: dir, keys: keys}, nil
+}
+
+// AddArtifact stores the binary of a release for a platform. The body is
+// streamed to disk while it is hashed, and only recorded once its
+// signature verifies.
+func (c *Catalog) AddArtifact(release *models.SensorRelease, platform, signature string, body io.Reader) (*models.ReleaseArtifact, error) {
+	if len(c.keys) == 0 {
+		return nil, ErrNoSigningKeys
+	}
+	rawSignature, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(rawSignature) != ed25519.SignatureSize {
+		return nil, ErrInvalidSignature
+	}
+
+	if err := os.MkdirAll(c.dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create release store: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(body, MaxBinarySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive release binary: %w", err)
+	}
+	if size > MaxBinarySize {
+		return nil, ErrTooLarge
+	}
+	if size == 0 {
+		return nil, ErrEmpty
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	if !c.verify(SignedMessage(release.Version, platform, digest), rawSignature) {
+		return nil, ErrInvalidSignature
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	// Content-addressed, so an identical binary is stored once
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, digest)); err != nil {
+		return nil, fmt.Errorf("failed to store release binary: %w", err)
+	}
+
+	artifact := &models.ReleaseArtifact{
+		ReleaseID:  release.ID,
+		Platform:   platform,
+		Size:       size,
+		SHA256:     digest,
+		Signature:  signature,
+		StorageKey: digest,
+	}
+	if err := c.repo.CreateReleaseArtifact(artifact); err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
+
+// Open returns the artifact of a version for a platform and its binary.
+// The caller closes the file.
+func (c *Catalog) Open(version, platform string) (*models.ReleaseArtifact, *os.File, error) {
+	artifact, err := c.repo.GetReleaseArtifact(version, platform)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(filepath.Join(c.dir, filepath.Base(artifact.StorageKey)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open release binary: %w", err)
+	}
+	return artifact, file, nil
+}
+
+func (c *Catalog) verify(message, signature []byte) bool {
+	for _, key := range c.keys {
+		if ed25519.Verify(key, message, signature) {
+			return true
+		}
+	}
+	return false
+}
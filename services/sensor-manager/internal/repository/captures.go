@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// captureColumns selects a capture; its status follows the command until
+// the pcap is uploaded
+const captureColumns = `
+	d.id, d.tenant_id, d.sensor_id, s.name, COALESCE(d.command_id::text, ''),
+	CASE
+		WHEN d.storage_key IS NOT NULL THEN 'available'
+		WHEN d.uploaded_at IS NOT NULL THEN 'expired'
+		WHEN cmd.status IN ('failed', 'expired') THEN 'failed'
+		ELSE 'pending'
+	END,
+	CASE WHEN d.uploaded_at IS NULL THEN COALESCE(cmd.error, '') ELSE '' END,
+	COALESCE(d.interface_name, ''), d.bpf_filter, d.duration_seconds, d.max_bytes, COALESCE(d.reason, ''),
+	COALESCE(d.requested_by::text, ''), COALESCE(d.size_bytes, 0), COALESCE(d.sha256, ''), COALESCE(d.packets, 0),
+	COALESCE(d.storage_key, ''), d.created_at, d.uploaded_at, d.expires_at`
+
+const captureFrom = `
+	FROM diagnostic_captures d
+	JOIN sensors s ON s.id = d.sensor_id
+	LEFT JOIN sensor_commands cmd ON cmd.id = d.command_id`
+
+// CreateDiagnosticCapture records a requested capture
+func (r *Repository) CreateDiagnosticCapture(capture *models.DiagnosticCapture) error {
+	err := r.db.QueryRow(`
+		INSERT INTO diagnostic_captures (tenant_id, sensor_id, interface_name, bpf_filter, duration_seconds,
+		                                 max_bytes, reason, requested_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`,
+		capture.TenantID, capture.SensorID, nullString(capture.Interface), capture.BPFFilter,
+		capture.DurationSeconds, capture.MaxBytes, nullString(capture.Reason), nullString(capture.RequestedBy),
+		capture.ExpiresAt,
+	).Scan(&capture.ID, &capture.CreatedAt)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to create diagnostic capture: %w", err)
+	}
+	return nil
+}
+
+// SetDiagnosticCaptureCommand records the command delivering a capture
+func (r *Repository) SetDiagnosticCaptureCommand(captureID, commandID string) error {
+	result, err := r.db.Exec(`UPDATE diagnostic_captures SET command_id = $2 WHERE id = $1`, captureID, commandID)
+	if err != nil {
+		return fmt.Errorf("failed to set capture command: %w", err)
+	}
+	return expectRows(result)
+}
+
+// GetDiagnosticCapture returns a capture of a tenant
+func (r *Repository) GetDiagnosticCapture(tenantID, captureID string) (*models.DiagnosticCapture, error) {
+	return r.getDiagnosticCapture(`d.tenant_id = $1 AND d.id = $2`, tenantID, captureID)
+}
+
+// GetSensorDiagnosticCapture returns a capture requested from a sensor
+func (r *Repository) GetSensorDiagnosticCapture(sensorID, captureID string) (*models.DiagnosticCapture, error) {
+	return r.getDiagnosticCapture(`d.sensor_id = $1 AND d.id = $2`, sensorID, captureID)
+}
+
+func (r *Repository) getDiagnosticCapture(condition string, args ...interface{}) (*models.DiagnosticCapture, error) {
+	capture, err := scanDiagnosticCapture(r.db.QueryRow(`SELECT `+captureColumns+captureFrom+` WHERE `+condition, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get diagnostic capture: %w", err)
+	}
+	return capture, nil
+}
+
+// ListDiagnosticCaptures returns the captures of a tenant, newest first,
+// optionally only those of one sensor
+func (r *Repository) ListDiagnosticCaptures(tenantID, sensorID string, page, pageSize int) ([]*models.DiagnosticCapture, int, error) {
+	conditions := []string{"d.tenant_id = $1"}
+	args := []interface{}{tenantID}
+	if sensorID != "" {
+		args = append(args, sensorID)
+		conditions = append(conditions, fmt.Sprintf("d.sensor_id = $%d", len(args)))
+	}
+	where := ` WHERE ` + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM diagnostic_captures d`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count diagnostic captures: %w", err)
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := r.db.Query(`SELECT `+captureColumns+captureFrom+where+
+		fmt.Sprintf(` ORDER BY d.created_at DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list diagnostic captures: %w", err)
+	}
+	defer rows.Close()
+
+	captures := []*models.DiagnosticCapture{}
+	for rows.Next() {
+		capture, err := scanDiagnosticCapture(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan diagnostic capture: %w", err)
+		}
+		captures = append(captures, capture)
+	}
+	return captures, total, rows.Err()
+}
+
+// CompleteDiagnosticCapture records the upload of a capture. ErrConflict is
+// returned when the capture was uploaded already.
+func (r *Repository) CompleteDiagnosticCapture(captureID, storageKey string, size int64, digest string, packets int, expiresAt time.Time) error {
+	result, err := r.db.Exec(`
+		UPDATE diagnostic_captures
+		SET storage_key = $2, size_bytes = $3, sha256 = $4, packets = $5, uploaded_at = NOW(), expires_at = $6
+		WHERE id = $1 AND uploaded_at IS NULL`,
+		captureID, storageKey, size, digest, packets, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete diagnostic capture: %w", err)
+	}
+	if err := expectRows(result); err == ErrNotFound {
+		return ErrConflict
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteDiagnosticCapture removes a capture of a tenant
+func (r *Repository) DeleteDiagnosticCapture(tenantID, captureID string) error {
+	result, err := r.db.Exec(`DELETE FROM diagnostic_captures WHERE tenant_id = $1 AND id = $2`, tenantID, captureID)
+	if err != nil {
+		return fmt.Errorf("failed to delete diagnostic capture: %w", err)
+	}
+	return expectRows(result)
+}
+
+// ExpireDiagnosticCaptures forgets the stored pcaps of captures past their
+// retention and returns their storage keys, so the files can be deleted
+func (r *Repository) ExpireDiagnosticCaptures() ([]string, error) {
+	rows, err := r.db.Query(`
+		UPDATE diagnostic_captures d SET storage_key = NULL
+		FROM diagnostic_captures old
+		WHERE d.id = old.id AND d.storage_key IS NOT NULL AND d.expires_at <= NOW()
+		RETURNING old.storage_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire diagnostic captures: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan diagnostic capture: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func scanDiagnosticCapture(row scanner) (*models.DiagnosticCapture, error) {
+	var capture models.DiagnosticCapture
+	var uploadedAt sql.NullTime
+	err := row.Scan(
+		&capture.ID, &capture.TenantID, &capture.SensorID, &capture.SensorName, &capture.CommandID,
+		&capture.Status, &capture.Error,
+		&capture.Interface, &capture.BPFFilter, &capture.DurationSeconds, &capture.MaxBytes, &capture.Reason,
+		&capture.RequestedBy, &capture.Size, &capture.SHA256, &capture.Packets,
+		&capture.StorageKey, &capture.CreatedAt, &uploadedAt, &capture.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	capture.UploadedAt = nullTime(uploadedAt)
+	return &capture, nil
+}
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/lib/pq"
+)
+
+// ListServedCertificates returns up to limit certificates expiring before
+// horizon together with the endpoints serving them, ordered by certificate,
+// asset and port and starting after the given one (nil for the first
+// batch), so every served certificate can be walked in batches. Certificates
+// that expired before expiredSince are skipped unless they have an open
+// reminder or were seen after they expired, so old certificates nobody
+// serves any more are not notified on. Endpoints where the certificate was
+// renewed are skipped too: implementations not observed since the renewal
+// still link the old certificate.
+func (r *Repository) ListServedCertificates(horizon, expiredSince time.Time, after *models.ServedCertificate, limit int) ([]*models.ServedCertificate, error) {
+	afterCertificate, afterAsset, afterPort := "00000000-0000-0000-0000-000000000000", "00000000-0000-0000-0000-000000000000", -1
+	if after != nil {
+		afterCertificate, afterAsset, afterPort = after.CertificateID, after.AssetID, after.Port
+	}
+
+	rows, err := r.db.Query(`
+		SELECT c.tenant_id, c.id, c.fingerprint_sha256, COALESCE(c.common_name, ''), c.subject_alternative_names,
+		       c.not_after, a.id, COALESCE(a.hostname, ''), COALESCE(host(a.ip_address), ''), COALESCE(ci.port, 0),
+		       COALESCE(a.owner_email, ''), MAX(ci.last_verified_at),
+		       COALESCE(r.id::text, ''), COALESCE(r.threshold_days, 0), COALESCE(r.expired_in_use, FALSE)
+		FROM crypto_implementations ci
+		JOIN certificates c ON c.id = ci.certificate_id AND c.not_after IS NOT NULL
+		JOIN network_assets a ON a.id = ci.asset_id AND a.deleted_at IS NULL
+		LEFT JOIN certificate_expiry_reminders r
+		       ON r.certificate_id = c.id AND r.asset_id = a.id AND r.port = COALESCE(ci.port, 0) AND r.status = 'open'
+		WHERE ci.deleted_at IS NULL AND c.not_after <= $1
+		  AND (c.not_after > $2 OR ci.last_verified_at >= c.not_after OR r.id IS NOT NULL)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM certificate_expiry_reminders renewed
+		      WHERE renewed.certificate_id = c.id AND renewed.asset_id = a.id
+		        AND renewed.port = COALESCE(ci.port, 0) AND renewed.close_reason = 'renewed')
+		  AND (c.id, a.id, COALESCE(ci.port, 0)) > ($3::uuid, $4::uuid, $5::integer)
+		GROUP BY c.id, a.id, COALESCE(ci.port, 0), r.id
+		ORDER BY c.id, a.id, COALESCE(ci.port, 0)
+		LIMIT $6`,
+		horizon, expiredSince, afterCertificate, afterAsset, afterPort, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list served certificates: %w", err)
+	}
+	defer rows.Close()
+
+	served := []*models.ServedCertificate{}
+	for rows.Next() {
+		var certificate models.ServedCertificate
+		if err := rows.Scan(&certificate.TenantID, &certificate.CertificateID, &certificate.FingerprintSHA256,
+			&certificate.CommonName, pq.Array(&certificate.SubjectAlternativeNames), &certificate.NotAfter,
+			&certificate.AssetID, &certificate.Hostname, &certificate.IPAddress, &certificate.Port,
+			&certificate.OwnerEmail, &certificate.LastSeenAt, &certificate.ReminderID,
+			&certificate.ReminderThreshold, &certificate.ExpiredInUse); err != nil {
+			return nil, fmt.Errorf("failed to scan served certificate: %w", err)
+		}
+		served = append(served, &certificate)
+	}
+	return served, rows.Err()
+}
+
+// RecordExpiryNotice opens or advances the expiry reminder of a served
+// certificate to threshold, records the notice in the certificate's history
+// and publishes the event in the same transaction. It returns false when
+// another scheduler changed the reminder first, so each notice is sent once.
+func (r *Repository) RecordExpiryNotice(served *models.ServedCertificate, threshold int, expiredInUse bool, eventType string, alert interface{}) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if served.ReminderID == "" {
+		result, err = tx.Exec(`
+			INSERT INTO certificate_expiry_reminders (tenant_id, certificate_id, asset_id, port, owner_email,
+			                                          threshold_days, expired_in_use)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (certificate_id, asset_id, port) WHERE status = 'open' DO NOTHING`,
+			served.TenantID, served.CertificateID, served.AssetID, served.Port, nullString(served.OwnerEmail),
+			threshold, expiredInUse,
+		)
+	} else {
+		result, err = tx.Exec(`
+			UPDATE certificate_expiry_reminders
+			SET threshold_days = $2, expired_in_use = $3, owner_email = $4, notified_at = NOW()
+			WHERE id = $1 AND status = 'open' AND threshold_days = $5 AND expired_in_use = $6`,
+			served.ReminderID, threshold, expiredInUse, nullString(served.OwnerEmail),
+			served.ReminderThreshold, served.ExpiredInUse,
+		)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record expiry reminder: %w", err)
+	}
+	if changed, err := result.RowsAffected(); err != nil || changed == 0 {
+		return false, err
+	}
+
+	historyEvent := models.CertificateHistoryExpiryNotified
+	if expiredInUse && !served.ExpiredInUse {
+		historyEvent = models.CertificateHistoryExpiredInUse
+	}
+	if err := recordCertificateHistory(tx, served.TenantID, served.CertificateID, historyEvent, "",
+		served.AssetID, served.Port, alert); err != nil {
+		return false, err
+	}
+	if _, err := publishWebhookEvent(tx, served.TenantID, "", eventType, alert); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit expiry notice: %w", err)
+	}
+	return true, nil
+}
+
+// ListOpenReminders returns up to limit open expiry reminders ordered by ID
+// and starting after afterID, with when the endpoint last presented the
+// certificate and whether it still does
+func (r *Repository) ListOpenReminders(afterID string, limit int) ([]*models.ExpiryReminder, error) {
+	rows, err := r.db.Query(`
+		SELECT r.id, r.tenant_id, r.certificate_id, c.fingerprint_sha256, COALESCE(c.common_name, ''),
+		       c.subject_alternative_names, c.not_after, r.asset_id, COALESCE(a.hostname, ''),
+		       COALESCE(host(a.ip_address), ''), r.port, COALESCE(a.owner_email, r.owner_email, ''),
+		       GREATEST(
+		           (SELECT MAX(e.last_seen_at) FROM certificate_endpoints e
+		            WHERE e.certificate_id = r.certificate_id AND e.asset_id = r.asset_id AND e.port = r.port),
+		           (SELECT MAX(ci.last_verified_at) FROM crypto_implementations ci
+		            WHERE ci.certificate_id = r.certificate_id AND ci.asset_id = r.asset_id
+		              AND COALESCE(ci.port, 0) = r.port)),
+		       a.deleted_at IS NULL AND EXISTS (
+		           SELECT 1 FROM crypto_implementations ci
+		           WHERE ci.certificate_id = r.certificate_id AND ci.asset_id = r.asset_id
+		             AND COALESCE(ci.port, 0) = r.port AND ci.deleted_at IS NULL)
+		FROM certificate_expiry_reminders r
+		JOIN certificates c ON c.id = r.certificate_id
+		JOIN network_assets a ON a.id = r.asset_id
+		WHERE r.status = 'open' AND ($1 = '' OR r.id::text > $1)
+		ORDER BY r.id::text
+		LIMIT $2`,
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiry reminders: %w", err)
+	}
+	defer rows.Close()
+
+	reminders := []*models.ExpiryReminder{}
+	for rows.Next() {
+		var reminder models.ExpiryReminder
+		var lastSeen sql.NullTime
+		if err := rows.Scan(&reminder.ID, &reminder.TenantID, &reminder.CertificateID, &reminder.FingerprintSHA256,
+			&reminder.CommonName, pq.Array(&reminder.SubjectAlternativeNames), &reminder.NotAfter,
+			&reminder.AssetID, &reminder.Hostname, &reminder.IPAddress, &reminder.Port, &reminder.OwnerEmail,
+			&lastSeen, &reminder.Served); err != nil {
+			return nil, fmt.Errorf("failed to scan expiry reminder: %w", err)
+		}
+		if lastSeen.Valid {
+			reminder.LastSeenAt = &lastSeen.Time
+		}
+		reminders = append(reminders, &reminder)
+	}
+	return reminders, rows.Err()
+}
+
+// ListEndpointCertificates returns the certificates other than
+// certificateID an endpoint was seen presenting after seenAfter, the most
+// recently seen first
+func (r *Repository) ListEndpointCertificates(assetID string, port int, certificateID string, seenAfter time.Time) ([]*models.EndpointCertificate, error) {
+	rows, err := r.db.Query(`
+		SELECT c.id, c.fingerprint_sha256, COALESCE(c.common_name, ''), c.subject_alternative_names, c.not_after,
+		       MAX(seen.last_seen_at)
+		FROM (
+			SELECT certificate_id, last_seen_at FROM certificate_endpoints
+			WHERE asset_id = $1 AND port = $2
+			UNION ALL
+			SELECT certificate_id, last_verified_at FROM crypto_implementations
+			WHERE asset_id = $1 AND COALESCE(port, 0) = $2 AND certificate_id IS NOT NULL AND deleted_at IS NULL
+		) seen
+		JOIN certificates c ON c.id = seen.certificate_id AND c.not_after IS NOT NULL
+		WHERE seen.certificate_id <> $3 AND seen.last_seen_at > $4
+		GROUP BY c.id
+		ORDER BY MAX(seen.last_seen_at) DESC`,
+		assetID, port, certificateID, seenAfter,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list endpoint certificates: %w", err)
+	}
+	defer rows.Close()
+
+	certificates := []*models.EndpointCertificate{}
+	for rows.Next() {
+		var certificate models.EndpointCertificate
+		if err := rows.Scan(&certificate.CertificateID, &certificate.FingerprintSHA256, &certificate.CommonName,
+			pq.Array(&certificate.SubjectAlternativeNames), &certificate.NotAfter, &certificate.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan endpoint certificate: %w", err)
+		}
+		certificates = append(certificates, &certificate)
+	}
+	return certificates, rows.Err()
+}
+
+// CloseRenewedReminder closes an expiry reminder whose certificate was
+// replaced on the endpoint by renewal, records the renewal in the history
+// of both certificates and publishes the event in the same transaction. It
+// returns false when the reminder was already closed.
+func (r *Repository) CloseRenewedReminder(reminder *models.ExpiryReminder, renewal *models.EndpointCertificate, alert interface{}) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if closed, err := closeReminder(tx, reminder.ID, models.ReminderClosedRenewed, renewal.CertificateID); err != nil || !closed {
+		return false, err
+	}
+	if err := recordCertificateHistory(tx, reminder.TenantID, reminder.CertificateID, models.CertificateHistoryRenewed,
+		renewal.CertificateID, reminder.AssetID, reminder.Port, alert); err != nil {
+		return false, err
+	}
+	if err := recordCertificateHistory(tx, reminder.TenantID, renewal.CertificateID, models.CertificateHistoryRenewalOf,
+		reminder.CertificateID, reminder.AssetID, reminder.Port, alert); err != nil {
+		return false, err
+	}
+	if _, err := publishWebhookEvent(tx, reminder.TenantID, "", models.EventCertificateRenewed, alert); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit certificate renewal: %w", err)
+	}
+	return true, nil
+}
+
+// CloseUnservedReminder closes an expiry reminder whose endpoint no longer
+// presents the certificate and records it in the certificate's history
+func (r *Repository) CloseUnservedReminder(reminder *models.ExpiryReminder) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if closed, err := closeReminder(tx, reminder.ID, models.ReminderClosedNotServed, ""); err != nil || !closed {
+		return false, err
+	}
+	details := map[string]interface{}{"reminder_id": reminder.ID}
+	if err := recordCertificateHistory(tx, reminder.TenantID, reminder.CertificateID, models.CertificateHistoryNoLongerServed,
+		"", reminder.AssetID, reminder.Port, details); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit reminder: %w", err)
+	}
+	return true, nil
+}
+
+func closeReminder(tx *sql.Tx, reminderID, reason, renewedBy string) (bool, error) {
+	result, err := tx.Exec(`
+		UPDATE certificate_expiry_reminders
+		SET status = 'closed', closed_at = NOW(), close_reason = $2, renewed_by_certificate_id = $3
+		WHERE id = $1 AND status = 'open'`,
+		reminderID, reason, nullString(renewedBy),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to close expiry reminder: %w", err)
+	}
+	closed, err := result.RowsAffected()
+	return closed > 0, err
+}
+
+func recordCertificateHistory(tx *sql.Tx, tenantID, certificateID, eventType, relatedCertificateID, assetID string, port int, details interface{}) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode certificate history: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO certificate_history (tenant_id, certificate_id, event_type, related_certificate_id, asset_id, port, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		tenantID, certificateID, eventType, nullString(relatedCertificateID), nullString(assetID), port, encoded,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record certificate history: %w", err)
+	}
+	return nil
+}
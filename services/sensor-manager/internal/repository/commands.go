@@ -0,0 +1,467 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/lib/pq"
+)
+
+// ErrInvalidTransition is returned when a command cannot move to the
+// requested state from its current one
+var ErrInvalidTransition = errors.New("invalid command state transition")
+
+const commandColumns = `
+	id, tenant_id, sensor_id, COALESCE(batch_id::text, ''), command_type, priority,
+	COALESCE(payload, '{}'), requires_ack, status, attempts, max_attempts, ack_timeout_seconds,
+	expires_at, delivered_at, acknowledged_at, completed_at, result, COALESCE(error, ''),
+	COALESCE(created_by, ''), created_at, updated_at`
+
+// EnqueueCommands stores new commands in the queued state in one transaction
+func (r *Repository) EnqueueCommands(commands []*models.CommandRecord) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, command := range commands {
+		payload, err := json.Marshal(command.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode command payload: %w", err)
+		}
+
+		err = tx.QueryRow(`
+			INSERT INTO sensor_commands (tenant_id, sensor_id, batch_id, command_type, priority, payload,
+			                             requires_ack, status, max_attempts, ack_timeout_seconds, expires_at, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, 'queued', $8, $9, $10, $11)
+			RETURNING id, status, created_at, updated_at`,
+			command.TenantID, command.SensorID, nullString(command.BatchID), command.Type, command.Priority,
+			payload, command.RequiresAck, command.MaxAttempts, command.AckTimeoutSeconds, command.ExpiresAt,
+			nullString(command.CreatedBy),
+		).Scan(&command.ID, &command.Status, &command.CreatedAt, &command.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue command: %w", err)
+		}
+
+		if err := insertCommandEvent(tx, command.ID, models.CommandStatusQueued, 0, ""); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeliverCommands marks up to limit deliverable commands of a sensor as
+// delivered and returns them, highest priority first. A command is
+// deliverable when it is queued, or when it was delivered but neither
+// acknowledged nor completed within its ack timeout and has attempts left.
+// Rows are claimed with SKIP LOCKED, so a heartbeat and a concurrent
+// long-poll never receive the same command.
+func (r *Repository) DeliverCommands(sensorID string, limit int) ([]*models.CommandRecord, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		WITH deliverable AS (
+			SELECT id AS deliverable_id FROM sensor_commands
+			WHERE sensor_id = $1
+			  AND expires_at > NOW()
+			  AND attempts < max_attempts
+			  AND (status = 'queued'
+			       OR (status = 'delivered' AND delivered_at + ack_timeout_seconds * INTERVAL '1 second' < NOW()))
+			ORDER BY priority DESC, created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE sensor_commands
+		SET status = 'delivered', delivered_at = NOW(), attempts = attempts + 1, updated_at = NOW()
+		FROM deliverable
+		WHERE id = deliverable_id
+		RETURNING `+commandColumns,
+		sensorID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deliver commands: %w", err)
+	}
+
+	commands := []*models.CommandRecord{}
+	for rows.Next() {
+		command, err := scanCommand(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan command: %w", err)
+		}
+		commands = append(commands, command)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to deliver commands: %w", err)
+	}
+
+	for _, command := range commands {
+		if err := insertCommandEvent(tx, command.ID, models.CommandStatusDelivered, command.Attempts, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit delivery: %w", err)
+	}
+
+	// UPDATE ... RETURNING does not preserve the CTE ordering
+	sort.SliceStable(commands, func(i, j int) bool {
+		if commands[i].Priority != commands[j].Priority {
+			return commands[i].Priority > commands[j].Priority
+		}
+		return commands[i].CreatedAt.Before(commands[j].CreatedAt)
+	})
+	return commands, nil
+}
+
+// RecordCommandResult applies a sensor's report to a command:
+//   - acknowledged moves a delivered command to acknowledged
+//   - succeeded completes it
+//   - failed completes it, or requeues it while attempts remain
+//
+// Repeating the report that produced the current state is a no-op, so
+// sensors can safely retry reports.
+func (r *Repository) RecordCommandResult(sensorID string, report *models.CommandResult) (*models.CommandRecord, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRow(`SELECT `+commandColumns+` FROM sensor_commands WHERE id = $1 AND sensor_id = $2 FOR UPDATE`,
+		report.CommandID, sensorID)
+	command, err := scanCommand(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load command: %w", err)
+	}
+
+	if command.Status == report.Status ||
+		(report.Status == models.CommandStatusAcknowledged && command.Terminal() && command.Status != models.CommandStatusExpired) {
+		return command, nil
+	}
+	if command.Status != models.CommandStatusDelivered && command.Status != models.CommandStatusAcknowledged {
+		return nil, ErrInvalidTransition
+	}
+
+	var result []byte
+	if report.Result != nil {
+		if result, err = json.Marshal(report.Result); err != nil {
+			return nil, fmt.Errorf("failed to encode command result: %w", err)
+		}
+	}
+
+	status, detail := report.Status, report.Error
+	switch report.Status {
+	case models.CommandStatusAcknowledged:
+		if command.Status != models.CommandStatusDelivered {
+			return nil, ErrInvalidTransition
+		}
+		_, err = tx.Exec(`
+			UPDATE sensor_commands SET status = 'acknowledged', acknowledged_at = NOW(), updated_at = NOW()
+			WHERE id = $1`, command.ID)
+	case models.CommandStatusSucceeded:
+		_, err = tx.Exec(`
+			UPDATE sensor_commands
+			SET status = 'succeeded', acknowledged_at = COALESCE(acknowledged_at, NOW()), completed_at = NOW(),
+			    result = $2, error = NULL, updated_at = NOW()
+			WHERE id = $1`, command.ID, result)
+	case models.CommandStatusFailed:
+		if command.Attempts < command.MaxAttempts {
+			// Retry on the next delivery; the failure is kept in the event log
+			if err := insertCommandEvent(tx, command.ID, models.CommandStatusFailed, command.Attempts, report.Error); err != nil {
+				return nil, err
+			}
+			status, detail = models.CommandStatusQueued, "retrying after failure"
+			_, err = tx.Exec(`
+				UPDATE sensor_commands SET status = 'queued', result = $2, error = $3, updated_at = NOW()
+				WHERE id = $1`, command.ID, result, nullString(report.Error))
+		} else {
+			_, err = tx.Exec(`
+				UPDATE sensor_commands
+				SET status = 'failed', completed_at = NOW(), result = $2, error = $3, updated_at = NOW()
+				WHERE id = $1`, command.ID, result, nullString(report.Error))
+		}
+	default:
+		return nil, ErrInvalidTransition
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update command: %w", err)
+	}
+
+	if err := insertCommandEvent(tx, command.ID, status, command.Attempts, detail); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit command result: %w", err)
+	}
+
+	return r.GetCommand(command.TenantID, command.ID)
+}
+
+// ExpireCommands moves commands past their TTL to expired, and delivered
+// commands that exhausted their attempts without any response to failed.
+// It returns the number of commands changed.
+func (r *Repository) ExpireCommands() (int64, error) {
+	var expired, exhausted int64
+	err := r.db.QueryRow(`
+		WITH changed AS (
+			UPDATE sensor_commands
+			SET status = 'expired', completed_at = NOW(), updated_at = NOW()
+			WHERE status IN ('queued', 'delivered', 'acknowledged') AND expires_at <= NOW()
+			RETURNING id, attempts
+		), logged AS (
+			INSERT INTO sensor_command_events (command_id, status, attempt, detail)
+			SELECT id, 'expired', attempts, 'command TTL elapsed' FROM changed
+		)
+		SELECT COUNT(*) FROM changed`,
+	).Scan(&expired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire commands: %w", err)
+	}
+
+	err = r.db.QueryRow(`
+		WITH changed AS (
+			UPDATE sensor_commands
+			SET status = 'failed', completed_at = NOW(), error = 'no response from sensor', updated_at = NOW()
+			WHERE status = 'delivered' AND attempts >= max_attempts
+			  AND delivered_at + ack_timeout_seconds * INTERVAL '1 second' < NOW()
+			RETURNING id, attempts
+		), logged AS (
+			INSERT INTO sensor_command_events (command_id, status, attempt, detail)
+			SELECT id, 'failed', attempts, 'no response from sensor' FROM changed
+		)
+		SELECT COUNT(*) FROM changed`,
+	).Scan(&exhausted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail unanswered commands: %w", err)
+	}
+
+	return expired + exhausted, nil
+}
+
+// SupersedeCommands expires a sensor's commands of the given type that have
+// not been delivered yet, recording detail as the reason. It returns the
+// number of commands expired.
+func (r *Repository) SupersedeCommands(sensorID, commandType, detail string) (int64, error) {
+	var superseded int64
+	err := r.db.QueryRow(`
+		WITH changed AS (
+			UPDATE sensor_commands
+			SET status = 'expired', completed_at = NOW(), updated_at = NOW()
+			WHERE sensor_id = $1 AND command_type = $2 AND status = 'queued'
+			RETURNING id, attempts
+		), logged AS (
+			INSERT INTO sensor_command_events (command_id, status, attempt, detail)
+			SELECT id, 'expired', attempts, $3 FROM changed
+		)
+		SELECT COUNT(*) FROM changed`,
+		sensorID, commandType, detail,
+	).Scan(&superseded)
+	if err != nil {
+		return 0, fmt.Errorf("failed to supersede commands: %w", err)
+	}
+	return superseded, nil
+}
+
+// HasRecentCommand reports whether a sensor has a command of the given type
+// that is still queued or in flight, or that was created after since
+func (r *Repository) HasRecentCommand(sensorID, commandType string, since time.Time) (bool, error) {
+	var active bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM sensor_commands
+			WHERE sensor_id = $1 AND command_type = $2
+			  AND ((status IN ('queued', 'delivered', 'acknowledged') AND expires_at > NOW()) OR created_at > $3)
+		)`,
+		sensorID, commandType, since,
+	).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("failed to check active commands: %w", err)
+	}
+	return active, nil
+}
+
+// GetCommand returns a command of a tenant including its event history
+func (r *Repository) GetCommand(tenantID, commandID string) (*models.CommandRecord, error) {
+	row := r.db.QueryRow(`SELECT `+commandColumns+` FROM sensor_commands WHERE id = $1 AND tenant_id = $2`,
+		commandID, tenantID)
+	command, err := scanCommand(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get command: %w", err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT status, attempt, COALESCE(detail, ''), created_at
+		FROM sensor_command_events WHERE command_id = $1 ORDER BY id`,
+		commandID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get command events: %w", err)
+	}
+	defer rows.Close()
+
+	command.Events = []models.CommandEvent{}
+	for rows.Next() {
+		var event models.CommandEvent
+		if err := rows.Scan(&event.Status, &event.Attempt, &event.Detail, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan command event: %w", err)
+		}
+		command.Events = append(command.Events, event)
+	}
+	return command, rows.Err()
+}
+
+// ListCommands returns a page of a tenant's command history, newest first,
+// and the total number of matching commands
+func (r *Repository) ListCommands(tenantID string, filters models.CommandFilters) ([]*models.CommandRecord, int, error) {
+	conditions := []string{"tenant_id = $1"}
+	args := []interface{}{tenantID}
+
+	if filters.SensorID != "" {
+		args = append(args, filters.SensorID)
+		conditions = append(conditions, fmt.Sprintf("sensor_id = $%d", len(args)))
+	}
+	if filters.BatchID != "" {
+		args = append(args, filters.BatchID)
+		conditions = append(conditions, fmt.Sprintf("batch_id = $%d", len(args)))
+	}
+	if filters.Status != "" {
+		args = append(args, filters.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filters.Type != "" {
+		args = append(args, filters.Type)
+		conditions = append(conditions, fmt.Sprintf("command_type = $%d", len(args)))
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sensor_commands WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count commands: %w", err)
+	}
+
+	page, pageSize := filters.Page, filters.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := r.db.Query(fmt.Sprintf(`
+		SELECT %s FROM sensor_commands WHERE %s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d`, commandColumns, where, len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list commands: %w", err)
+	}
+	defer rows.Close()
+
+	commands := []*models.CommandRecord{}
+	for rows.Next() {
+		command, err := scanCommand(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan command: %w", err)
+		}
+		commands = append(commands, command)
+	}
+	return commands, total, rows.Err()
+}
+
+// ListCommandTargets returns the IDs of a tenant's active sensors, limited
+// to the given IDs and/or tag when set
+func (r *Repository) ListCommandTargets(tenantID string, sensorIDs []string, tag string) ([]string, error) {
+	conditions := []string{"tenant_id = $1", "deleted_at IS NULL", "decommissioned_at IS NULL"}
+	args := []interface{}{tenantID}
+
+	if len(sensorIDs) > 0 {
+		args = append(args, pq.Array(sensorIDs))
+		conditions = append(conditions, fmt.Sprintf("id = ANY($%d::uuid[])", len(args)))
+	}
+	if tag != "" {
+		args = append(args, tag)
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(tags)", len(args)))
+	}
+
+	rows, err := r.db.Query(`SELECT id FROM sensors WHERE `+strings.Join(conditions, " AND ")+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve command targets: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func insertCommandEvent(tx *sql.Tx, commandID, status string, attempt int, detail string) error {
+	_, err := tx.Exec(`
+		INSERT INTO sensor_command_events (command_id, status, attempt, detail)
+		VALUES ($1, $2, $3, $4)`,
+		commandID, status, attempt, nullString(detail),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record command event: %w", err)
+	}
+	return nil
+}
+
+func scanCommand(row scanner) (*models.CommandRecord, error) {
+	var command models.CommandRecord
+	var payload, result []byte
+	var deliveredAt, acknowledgedAt, completedAt sql.NullTime
+	err := row.Scan(
+		&command.ID, &command.TenantID, &command.SensorID, &command.BatchID, &command.Type, &command.Priority,
+		&payload, &command.RequiresAck, &command.Status, &command.Attempts, &command.MaxAttempts,
+		&command.AckTimeoutSeconds, &command.ExpiresAt, &deliveredAt, &acknowledgedAt, &completedAt,
+		&result, &command.Error, &command.CreatedBy, &command.CreatedAt, &command.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &command.Payload); err != nil {
+			return nil, fmt.Errorf("invalid command payload: %w", err)
+		}
+	}
+	if command.Payload == nil {
+		command.Payload = map[string]interface{}{}
+	}
+	if len(result) > 0 {
+		command.Result = json.RawMessage(result)
+	}
+	command.DeliveredAt = nullTime(deliveredAt)
+	command.AcknowledgedAt = nullTime(acknowledgedAt)
+	command.CompletedAt = nullTime(completedAt)
+	return &command, nil
+}
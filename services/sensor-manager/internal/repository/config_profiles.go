@@ -0,0 +1,415 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+const configProfileColumns = `
+	p.id, p.tenant_id, p.name, COALESCE(p.description, ''), p.current_version, v.settings,
+	(SELECT COUNT(*) FROM sensor_groups g WHERE g.profile_id = p.id),
+	COALESCE(p.created_by::text, ''), p.created_at, p.updated_at`
+
+const configProfileFrom = `
+	FROM sensor_config_profiles p
+	JOIN sensor_config_profile_versions v ON v.profile_id = p.id AND v.version = p.current_version`
+
+const sensorGroupColumns = `
+	g.id, g.tenant_id, g.name, COALESCE(g.description, ''), COALESCE(g.profile_id::text, ''),
+	COALESCE(p.name, ''), g.profile_version,
+	(SELECT COUNT(*) FROM sensors s WHERE s.group_id = g.id AND s.deleted_at IS NULL),
+	g.created_at, g.updated_at`
+
+const sensorGroupFrom = `
+	FROM sensor_groups g
+	LEFT JOIN sensor_config_profiles p ON p.id = g.profile_id`
+
+// CreateConfigProfile stores a new profile together with its first version
+func (r *Repository) CreateConfigProfile(profile *models.ConfigProfile, changeNote string) error {
+	settings, err := json.Marshal(profile.Settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile settings: %w", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO sensor_config_profiles (tenant_id, name, description, current_version, created_by)
+		VALUES ($1, $2, $3, 1, $4)
+		RETURNING id, current_version, created_at, updated_at`,
+		profile.TenantID, profile.Name, nullString(profile.Description), nullString(profile.CreatedBy),
+	).Scan(&profile.ID, &profile.CurrentVersion, &profile.CreatedAt, &profile.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to create configuration profile: %w", err)
+	}
+
+	if err := insertProfileVersion(tx, profile.ID, 1, settings, changeNote, profile.CreatedBy); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetConfigProfile returns a tenant's profile with the settings of its
+// current version
+func (r *Repository) GetConfigProfile(tenantID, profileID string) (*models.ConfigProfile, error) {
+	row := r.db.QueryRow(`SELECT `+configProfileColumns+configProfileFrom+` WHERE p.id = $1 AND p.tenant_id = $2`,
+		profileID, tenantID)
+	profile, err := scanConfigProfile(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configuration profile: %w", err)
+	}
+	return profile, nil
+}
+
+// ListConfigProfiles returns all profiles of a tenant ordered by name
+func (r *Repository) ListConfigProfiles(tenantID string) ([]*models.ConfigProfile, error) {
+	rows, err := r.db.Query(`SELECT `+configProfileColumns+configProfileFrom+` WHERE p.tenant_id = $1 ORDER BY p.name`,
+		tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configuration profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := []*models.ConfigProfile{}
+	for rows.Next() {
+		profile, err := scanConfigProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan configuration profile: %w", err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+// UpdateConfigProfile changes a profile's name and description when set.
+// New settings are stored as the next version, which becomes current.
+func (r *Repository) UpdateConfigProfile(tenantID, profileID string, name, description *string, settings *models.ConfigSettings, changeNote, createdBy string) (*models.ConfigProfile, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the profile so concurrent updates get consecutive versions
+	var version int
+	err = tx.QueryRow(`
+		SELECT current_version FROM sensor_config_profiles WHERE id = $1 AND tenant_id = $2 FOR UPDATE`,
+		profileID, tenantID,
+	).Scan(&version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock configuration profile: %w", err)
+	}
+
+	if settings != nil {
+		encoded, err := json.Marshal(settings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode profile settings: %w", err)
+		}
+		version++
+		if err := insertProfileVersion(tx, profileID, version, encoded, changeNote, createdBy); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sensor_config_profiles
+		SET name = COALESCE($2, name), description = COALESCE($3, description), current_version = $4
+		WHERE id = $1`,
+		profileID, name, description, version,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, fmt.Errorf("failed to update configuration profile: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit configuration profile: %w", err)
+	}
+	return r.GetConfigProfile(tenantID, profileID)
+}
+
+// DeleteConfigProfile removes a profile and its versions. Profiles still
+// assigned to a group cannot be deleted.
+func (r *Repository) DeleteConfigProfile(tenantID, profileID string) error {
+	result, err := r.db.Exec(`DELETE FROM sensor_config_profiles WHERE id = $1 AND tenant_id = $2`, profileID, tenantID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrInUse
+		}
+		return fmt.Errorf("failed to delete configuration profile: %w", err)
+	}
+	return expectRows(result)
+}
+
+// ListConfigProfileVersions returns all versions of a tenant's profile,
+// newest first
+func (r *Repository) ListConfigProfileVersions(tenantID, profileID string) ([]*models.ConfigProfileVersion, error) {
+	rows, err := r.db.Query(`
+		SELECT v.profile_id, v.version, v.settings, COALESCE(v.change_note, ''), COALESCE(v.created_by::text, ''), v.created_at
+		FROM sensor_config_profile_versions v
+		JOIN sensor_config_profiles p ON p.id = v.profile_id
+		WHERE v.profile_id = $1 AND p.tenant_id = $2
+		ORDER BY v.version DESC`,
+		profileID, tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profile versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*models.ConfigProfileVersion{}
+	for rows.Next() {
+		version, err := scanProfileVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan profile version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// GetConfigProfileVersion returns one version of a tenant's profile
+func (r *Repository) GetConfigProfileVersion(tenantID, profileID string, version int) (*models.ConfigProfileVersion, error) {
+	row := r.db.QueryRow(`
+		SELECT v.profile_id, v.version, v.settings, COALESCE(v.change_note, ''), COALESCE(v.created_by::text, ''), v.created_at
+		FROM sensor_config_profile_versions v
+		JOIN sensor_config_profiles p ON p.id = v.profile_id
+		WHERE v.profile_id = $1 AND v.version = $2 AND p.tenant_id = $3`,
+		profileID, version, tenantID,
+	)
+	profileVersion, err := scanProfileVersion(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile version: %w", err)
+	}
+	return profileVersion, nil
+}
+
+// CreateSensorGroup stores a new sensor group. The profile and pinned
+// version must have been checked to belong to the group's tenant.
+func (r *Repository) CreateSensorGroup(group *models.SensorGroup) error {
+	err := r.db.QueryRow(`
+		INSERT INTO sensor_groups (tenant_id, name, description, profile_id, profile_version)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		group.TenantID, group.Name, nullString(group.Description), nullString(group.ProfileID), group.ProfileVersion,
+	).Scan(&group.ID, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to create sensor group: %w", err)
+	}
+	return nil
+}
+
+// GetSensorGroup returns a tenant's sensor group
+func (r *Repository) GetSensorGroup(tenantID, groupID string) (*models.SensorGroup, error) {
+	row := r.db.QueryRow(`SELECT `+sensorGroupColumns+sensorGroupFrom+` WHERE g.id = $1 AND g.tenant_id = $2`,
+		groupID, tenantID)
+	group, err := scanSensorGroup(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor group: %w", err)
+	}
+	return group, nil
+}
+
+// ListSensorGroups returns all sensor groups of a tenant ordered by name
+func (r *Repository) ListSensorGroups(tenantID string) ([]*models.SensorGroup, error) {
+	rows, err := r.db.Query(`SELECT `+sensorGroupColumns+sensorGroupFrom+` WHERE g.tenant_id = $1 ORDER BY g.name`,
+		tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []*models.SensorGroup{}
+	for rows.Next() {
+		group, err := scanSensorGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor group: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// UpdateSensorGroup stores a group's name, description, profile and pinned
+// version
+func (r *Repository) UpdateSensorGroup(group *models.SensorGroup) error {
+	err := r.db.QueryRow(`
+		UPDATE sensor_groups
+		SET name = $3, description = $4, profile_id = $5, profile_version = $6
+		WHERE id = $1 AND tenant_id = $2
+		RETURNING updated_at`,
+		group.ID, group.TenantID, group.Name, nullString(group.Description), nullString(group.ProfileID),
+		group.ProfileVersion,
+	).Scan(&group.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to update sensor group: %w", err)
+	}
+	return nil
+}
+
+// DeleteSensorGroup removes a group; its sensors and registration keys are
+// left without a group
+func (r *Repository) DeleteSensorGroup(tenantID, groupID string) error {
+	result, err := r.db.Exec(`DELETE FROM sensor_groups WHERE id = $1 AND tenant_id = $2`, groupID, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sensor group: %w", err)
+	}
+	return expectRows(result)
+}
+
+// GetGroupProfileVersion returns the profile version a group's sensors
+// inherit: the pinned version, or the profile's current one. It returns nil
+// when the group has no profile.
+func (r *Repository) GetGroupProfileVersion(groupID string) (*models.ConfigProfileVersion, error) {
+	row := r.db.QueryRow(`
+		SELECT v.profile_id, v.version, v.settings, COALESCE(v.change_note, ''), COALESCE(v.created_by::text, ''), v.created_at
+		FROM sensor_groups g
+		JOIN sensor_config_profiles p ON p.id = g.profile_id
+		JOIN sensor_config_profile_versions v
+		  ON v.profile_id = p.id AND v.version = COALESCE(g.profile_version, p.current_version)
+		WHERE g.id = $1`,
+		groupID,
+	)
+	version, err := scanProfileVersion(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve group profile: %w", err)
+	}
+	return version, nil
+}
+
+// ListGroupSensorIDs returns the active sensors of a group
+func (r *Repository) ListGroupSensorIDs(groupID string) ([]string, error) {
+	return r.querySensorIDs(`
+		SELECT id FROM sensors
+		WHERE group_id = $1 AND deleted_at IS NULL AND decommissioned_at IS NULL
+		ORDER BY name`,
+		groupID,
+	)
+}
+
+// ListProfileSensorIDs returns the active sensors of groups that follow the
+// current version of a profile
+func (r *Repository) ListProfileSensorIDs(profileID string) ([]string, error) {
+	return r.querySensorIDs(`
+		SELECT s.id FROM sensors s
+		JOIN sensor_groups g ON g.id = s.group_id
+		WHERE g.profile_id = $1 AND g.profile_version IS NULL
+		  AND s.deleted_at IS NULL AND s.decommissioned_at IS NULL
+		ORDER BY s.name`,
+		profileID,
+	)
+}
+
+func (r *Repository) querySensorIDs(query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func insertProfileVersion(tx *sql.Tx, profileID string, version int, settings []byte, changeNote, createdBy string) error {
+	_, err := tx.Exec(`
+		INSERT INTO sensor_config_profile_versions (profile_id, version, settings, change_note, created_by)
+		VALUES ($1, $2, $3, $4, $5)`,
+		profileID, version, settings, nullString(changeNote), nullString(createdBy),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store profile version: %w", err)
+	}
+	return nil
+}
+
+func scanConfigProfile(row scanner) (*models.ConfigProfile, error) {
+	var profile models.ConfigProfile
+	var settings []byte
+	err := row.Scan(
+		&profile.ID, &profile.TenantID, &profile.Name, &profile.Description, &profile.CurrentVersion, &settings,
+		&profile.GroupCount, &profile.CreatedBy, &profile.CreatedAt, &profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(settings, &profile.Settings); err != nil {
+		return nil, fmt.Errorf("invalid profile settings: %w", err)
+	}
+	return &profile, nil
+}
+
+func scanProfileVersion(row scanner) (*models.ConfigProfileVersion, error) {
+	var version models.ConfigProfileVersion
+	var settings []byte
+	err := row.Scan(
+		&version.ProfileID, &version.Version, &settings, &version.ChangeNote, &version.CreatedBy, &version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(settings, &version.Settings); err != nil {
+		return nil, fmt.Errorf("invalid profile settings: %w", err)
+	}
+	return &version, nil
+}
+
+func scanSensorGroup(row scanner) (*models.SensorGroup, error) {
+	var group models.SensorGroup
+	var profileVersion sql.NullInt64
+	err := row.Scan(
+		&group.ID, &group.TenantID, &group.Name, &group.Description, &group.ProfileID, &group.ProfileName,
+		&profileVersion, &group.SensorCount, &group.CreatedAt, &group.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if profileVersion.Valid {
+		version := int(profileVersion.Int64)
+		group.ProfileVersion = &version
+	}
+	return &group, nil
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+const batchColumns = `
+	id, tenant_id, sensor_id, batch_id, source, status, discovery_count, accepted_count,
+	rejected_count, attempts, COALESCE(rejections, '[]'), COALESCE(last_error, ''),
+	received_at, started_at, completed_at`
+
+// StoreDiscoveryBatch stores a submitted batch in the pending state. Batches
+// are unique per sensor and batch ID: when the batch was already stored the
+// existing record is returned and created is false, so a retried submission
+// is never ingested twice.
+func (r *Repository) StoreDiscoveryBatch(batch *models.DiscoveryBatchRecord) (created bool, err error) {
+	payload, err := json.Marshal(batch.Discoveries)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode discoveries: %w", err)
+	}
+
+	row := r.db.QueryRow(`
+		INSERT INTO discovery_batches (tenant_id, sensor_id, batch_id, source, status, discovery_count, payload)
+		VALUES ($1, $2, $3, $4, 'pending', $5, $6)
+		ON CONFLICT (sensor_id, batch_id) DO NOTHING
+		RETURNING `+batchColumns,
+		batch.TenantID, batch.SensorID, batch.BatchID, batch.Source, len(batch.Discoveries), payload,
+	)
+	stored, err := scanDiscoveryBatch(row)
+	if err == sql.ErrNoRows {
+		existing, err := r.getDiscoveryBatch(batch.SensorID, batch.BatchID)
+		if err != nil {
+			return false, err
+		}
+		*batch = *existing
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to store discovery batch: %w", err)
+	}
+
+	stored.Discoveries = batch.Discoveries
+	*batch = *stored
+	return true, nil
+}
+
+// ClaimDiscoveryBatches marks up to limit batches as processing and returns
+// them with their discoveries, oldest first. Pending batches are claimable,
+// as are batches left in processing for longer than staleAfter by a worker
+// that died. Stale batches that already used up maxAttempts are marked
+// failed instead. Rows are claimed with SKIP LOCKED, so concurrent workers
+// never process the same batch.
+func (r *Repository) ClaimDiscoveryBatches(limit int, staleAfter time.Duration, maxAttempts int) ([]*models.DiscoveryBatchRecord, error) {
+	staleSeconds := int(staleAfter / time.Second)
+
+	_, err := r.db.Exec(`
+		UPDATE discovery_batches
+		SET status = 'failed', last_error = 'processing did not finish', completed_at = NOW()
+		WHERE status = 'processing' AND attempts >= $2 AND started_at < NOW() - $1 * INTERVAL '1 second'`,
+		staleSeconds, maxAttempts,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fail abandoned discovery batches: %w", err)
+	}
+
+	rows, err := r.db.Query(`
+		WITH claimable AS (
+			SELECT id AS claimable_id FROM discovery_batches
+			WHERE status = 'pending'
+			   OR (status = 'processing' AND attempts < $3 AND started_at < NOW() - $2 * INTERVAL '1 second')
+			ORDER BY received_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE discovery_batches
+		SET status = 'processing', started_at = NOW(), attempts = attempts + 1
+		FROM claimable
+		WHERE id = claimable_id
+		RETURNING `+batchColumns+`, COALESCE(payload, '[]')`,
+		limit, staleSeconds, maxAttempts,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim discovery batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []*models.DiscoveryBatchRecord{}
+	for rows.Next() {
+		var payload []byte
+		batch, err := scanDiscoveryBatch(rows, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan discovery batch: %w", err)
+		}
+		if err := json.Unmarshal(payload, &batch.Discoveries); err != nil {
+			return nil, fmt.Errorf("invalid payload in discovery batch %s: %w", batch.ID, err)
+		}
+		batches = append(batches, batch)
+	}
+	return batches, rows.Err()
+}
+
+// CompleteDiscoveryBatch records the outcome of processing a batch and drops
+// its payload, which is no longer needed once the inventory is updated
+func (r *Repository) CompleteDiscoveryBatch(batch *models.DiscoveryBatchRecord) error {
+	rejections, err := json.Marshal(batch.Rejections)
+	if err != nil {
+		return fmt.Errorf("failed to encode rejections: %w", err)
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE discovery_batches
+		SET status = 'completed', accepted_count = $2, rejected_count = $3, rejections = $4,
+		    last_error = NULL, payload = NULL, completed_at = NOW()
+		WHERE id = $1`,
+		batch.ID, batch.AcceptedCount, batch.RejectedCount, rejections,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete discovery batch: %w", err)
+	}
+	return expectRows(result)
+}
+
+// FailDiscoveryBatch records a processing error. The batch is returned to
+// pending for another attempt unless it has used up maxAttempts, in which
+// case it is marked failed and its payload kept for inspection.
+func (r *Repository) FailDiscoveryBatch(batchID string, cause error, maxAttempts int) error {
+	result, err := r.db.Exec(`
+		UPDATE discovery_batches
+		SET status = CASE WHEN attempts >= $3 THEN 'failed' ELSE 'pending' END,
+		    last_error = $2,
+		    completed_at = CASE WHEN attempts >= $3 THEN NOW() END
+		WHERE id = $1`,
+		batchID, cause.Error(), maxAttempts,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record discovery batch failure: %w", err)
+	}
+	return expectRows(result)
+}
+
+// PurgeDiscoveryBatches deletes completed batches received before the cutoff
+func (r *Repository) PurgeDiscoveryBatches(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM discovery_batches WHERE status = 'completed' AND received_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge discovery batches: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ListDiscoveryBatches returns a page of a sensor's discovery batches,
+// newest first, and the total number of batches
+func (r *Repository) ListDiscoveryBatches(tenantID, sensorID string, page, pageSize int) ([]*models.DiscoveryBatchRecord, int, error) {
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM discovery_batches WHERE tenant_id = $1 AND sensor_id = $2`,
+		tenantID, sensorID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count discovery batches: %w", err)
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+batchColumns+` FROM discovery_batches
+		WHERE tenant_id = $1 AND sensor_id = $2
+		ORDER BY received_at DESC
+		LIMIT $3 OFFSET $4`,
+		tenantID, sensorID, pageSize, (page-1)*pageSize,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list discovery batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []*models.DiscoveryBatchRecord{}
+	for rows.Next() {
+		batch, err := scanDiscoveryBatch(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan discovery batch: %w", err)
+		}
+		batches = append(batches, batch)
+	}
+	return batches, total, rows.Err()
+}
+
+func (r *Repository) getDiscoveryBatch(sensorID, batchID string) (*models.DiscoveryBatchRecord, error) {
+	row := r.db.QueryRow(`SELECT `+batchColumns+` FROM discovery_batches WHERE sensor_id = $1 AND batch_id = $2`,
+		sensorID, batchID)
+	batch, err := scanDiscoveryBatch(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discovery batch: %w", err)
+	}
+	return batch, nil
+}
+
+// scanDiscoveryBatch scans batchColumns followed by any extra destinations
+func scanDiscoveryBatch(row scanner, extra ...interface{}) (*models.DiscoveryBatchRecord, error) {
+	var batch models.DiscoveryBatchRecord
+	var rejections []byte
+	var startedAt, completedAt sql.NullTime
+	dest := []interface{}{
+		&batch.ID, &batch.TenantID, &batch.SensorID, &batch.BatchID, &batch.Source, &batch.Status,
+		&batch.DiscoveryCount, &batch.AcceptedCount, &batch.RejectedCount, &batch.Attempts,
+		&rejections, &batch.LastError, &batch.ReceivedAt, &startedAt, &completedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(rejections, &batch.Rejections); err != nil {
+		return nil, fmt.Errorf("invalid rejections: %w", err)
+	}
+	batch.StartedAt = nullTime(startedAt)
+	batch.CompletedAt = nullTime(completedAt)
+	return &batch, nil
+}
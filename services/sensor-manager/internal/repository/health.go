@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+const healthTransitionColumns = `id, tenant_id, sensor_id, from_state, to_state, reasons, created_at`
+
+// ListHealthCandidates returns up to limit sensors the fleet health
+// evaluator watches, ordered by ID and starting after afterID, so the whole
+// fleet can be walked in batches. Deleted and decommissioned sensors are
+// not watched.
+func (r *Repository) ListHealthCandidates(afterID string, limit int) ([]*models.RegisteredSensor, error) {
+	rows, err := r.db.Query(`
+		SELECT `+sensorColumns+` FROM sensors
+		WHERE deleted_at IS NULL AND decommissioned_at IS NULL AND ($1 = '' OR id::text > $1)
+		ORDER BY id::text
+		LIMIT $2`,
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors for health evaluation: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []*models.RegisteredSensor{}
+	for rows.Next() {
+		sensor, err := scanSensor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor: %w", err)
+		}
+		sensors = append(sensors, sensor)
+	}
+	return sensors, rows.Err()
+}
+
+// UpdateSensorHealth stores a sensor's evaluated health state and reasons,
+// provided the stored state is still fromState. When the state changes the
+// transition is recorded in the same transaction and returned. It returns
+// false when another evaluator changed the state first, so a transition is
+// recorded (and alerted on) only once.
+func (r *Repository) UpdateSensorHealth(sensorID, fromState, toState string, reasons []models.HealthReason) (*models.HealthTransition, bool, error) {
+	encoded, err := json.Marshal(reasons)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode health reasons: %w", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tenantID string
+	err = tx.QueryRow(`
+		UPDATE sensors
+		SET health_state = $3, health_reasons = $4,
+		    health_changed_at = CASE WHEN health_state <> $3 THEN NOW() ELSE health_changed_at END
+		WHERE id = $1 AND health_state = $2 AND deleted_at IS NULL
+		RETURNING tenant_id`,
+		sensorID, fromState, toState, encoded,
+	).Scan(&tenantID)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update sensor health: %w", err)
+	}
+
+	var transition *models.HealthTransition
+	if fromState != toState {
+		row := tx.QueryRow(`
+			INSERT INTO sensor_health_transitions (tenant_id, sensor_id, from_state, to_state, reasons)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+healthTransitionColumns,
+			tenantID, sensorID, fromState, toState, encoded,
+		)
+		if transition, err = scanHealthTransition(row); err != nil {
+			return nil, false, fmt.Errorf("failed to record health transition: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit health update: %w", err)
+	}
+	return transition, true, nil
+}
+
+// ListFleetHealth returns a page of the health states of a tenant's active
+// sensors, the least healthy first, and the total number matching the
+// filters
+func (r *Repository) ListFleetHealth(tenantID string, filters models.FleetHealthFilters) ([]*models.SensorHealthState, int, error) {
+	where := `WHERE tenant_id = $1 AND deleted_at IS NULL AND decommissioned_at IS NULL
+		AND ($2 = '' OR health_state = $2)
+		AND ($3 = '' OR group_id::text = $3)`
+	args := []interface{}{tenantID, filters.State, filters.GroupID}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sensors `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sensors: %w", err)
+	}
+
+	page, pageSize := filters.Page, filters.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := r.db.Query(`
+		SELECT id, name, COALESCE(group_id::text, ''), status, health_state, health_reasons,
+		       health_changed_at, last_heartbeat_at
+		FROM sensors `+where+`
+		ORDER BY CASE health_state WHEN 'offline' THEN 0 WHEN 'degraded' THEN 1 WHEN 'unknown' THEN 2 ELSE 3 END, name
+		LIMIT $4 OFFSET $5`,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list fleet health: %w", err)
+	}
+	defer rows.Close()
+
+	states := []*models.SensorHealthState{}
+	for rows.Next() {
+		var state models.SensorHealthState
+		var reasons []byte
+		var changedAt, heartbeatAt sql.NullTime
+		if err := rows.Scan(&state.SensorID, &state.Name, &state.GroupID, &state.Status, &state.State, &reasons,
+			&changedAt, &heartbeatAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan sensor health: %w", err)
+		}
+		if err := json.Unmarshal(reasons, &state.Reasons); err != nil {
+			return nil, 0, fmt.Errorf("invalid health reasons: %w", err)
+		}
+		state.StateChangedAt = nullTime(changedAt)
+		state.LastHeartbeatAt = nullTime(heartbeatAt)
+		states = append(states, &state)
+	}
+	return states, total, rows.Err()
+}
+
+// CountFleetHealth returns the number of a tenant's active sensors in each
+// health state
+func (r *Repository) CountFleetHealth(tenantID string) (map[string]int, error) {
+	counts := map[string]int{
+		models.HealthStateHealthy:  0,
+		models.HealthStateDegraded: 0,
+		models.HealthStateOffline:  0,
+		models.HealthStateUnknown:  0,
+	}
+
+	rows, err := r.db.Query(`
+		SELECT health_state, COUNT(*) FROM sensors
+		WHERE tenant_id = $1 AND deleted_at IS NULL AND decommissioned_at IS NULL
+		GROUP BY health_state`,
+		tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count fleet health: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan fleet health count: %w", err)
+		}
+		counts[state] = count
+	}
+	return counts, rows.Err()
+}
+
+// ListHealthTransitions returns a page of a sensor's health transitions,
+// newest first, and the total number of transitions
+func (r *Repository) ListHealthTransitions(tenantID, sensorID string, page, pageSize int) ([]*models.HealthTransition, int, error) {
+	var total int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM sensor_health_transitions WHERE tenant_id = $1 AND sensor_id = $2`,
+		tenantID, sensorID,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count health transitions: %w", err)
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+healthTransitionColumns+` FROM sensor_health_transitions
+		WHERE tenant_id = $1 AND sensor_id = $2
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`,
+		tenantID, sensorID, pageSize, (page-1)*pageSize,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list health transitions: %w", err)
+	}
+	defer rows.Close()
+
+	transitions := []*models.HealthTransition{}
+	for rows.Next() {
+		transition, err := scanHealthTransition(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan health transition: %w", err)
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions, total, rows.Err()
+}
+
+func scanHealthTransition(row scanner) (*models.HealthTransition, error) {
+	var transition models.HealthTransition
+	var reasons []byte
+	err := row.Scan(&transition.ID, &transition.TenantID, &transition.SensorID, &transition.FromState,
+		&transition.ToState, &reasons, &transition.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(reasons, &transition.Reasons); err != nil {
+		return nil, fmt.Errorf("invalid health reasons: %w", err)
+	}
+	return &transition, nil
+}
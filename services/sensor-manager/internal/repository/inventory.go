@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/lib/pq"
+)
+
+// IngestObservation writes one endpoint observation to the inventory in a
+// single transaction: the asset is resolved or created, presented
+// certificates are upserted by fingerprint and the crypto implementation is
+// upserted on (asset, port, protocol, version, cipher). The leaf certificate
+// is also recorded against the endpoint (asset and port), which keeps the
+// certificates it served before a renewal. Timestamps only move forward, so
+// replaying an observation leaves the inventory unchanged.
+// A new implementation with known weaknesses publishes a
+// crypto.weak_detected webhook event. It returns whether a new
+// implementation was created.
+func (r *Repository) IngestObservation(tenantID, sensorID string, observation *models.EndpointObservation) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The inventory history attributes the changes to the sensor
+	if _, err := tx.Exec(`
+		SELECT set_config('inventory.change_source', 'sensor', true), set_config('inventory.change_reference', $1, true)`,
+		"sensor:"+sensorID,
+	); err != nil {
+		return false, fmt.Errorf("failed to set change source: %w", err)
+	}
+
+	assetID, err := resolveAsset(tx, tenantID, sensorID, observation)
+	if err != nil {
+		return false, err
+	}
+
+	var certificateID sql.NullString
+	for i, certificate := range observation.Certificates {
+		id, err := upsertCertificate(tx, tenantID, certificate)
+		if err != nil {
+			return false, err
+		}
+		if i == 0 {
+			certificateID = sql.NullString{String: id, Valid: true}
+		}
+	}
+
+	rawData, err := json.Marshal(observation.RawData)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode raw data: %w", err)
+	}
+
+	var implementationID string
+	var created bool
+	err = tx.QueryRow(`
+		INSERT INTO crypto_implementations (tenant_id, asset_id, port, protocol, protocol_version, cipher_suite,
+		                                    certificate_id, discovery_method, confidence_score, source_sensor_id,
+		                                    raw_data, first_discovered_at, last_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (tenant_id, asset_id, (COALESCE(port, 0)), protocol,
+		             (COALESCE(protocol_version, '')), (COALESCE(cipher_suite, '')))
+		WHERE deleted_at IS NULL
+		DO UPDATE SET
+			certificate_id = COALESCE(EXCLUDED.certificate_id, crypto_implementations.certificate_id),
+			confidence_score = GREATEST(crypto_implementations.confidence_score, EXCLUDED.confidence_score),
+			source_sensor_id = EXCLUDED.source_sensor_id,
+			raw_data = COALESCE(crypto_implementations.raw_data, '{}') || EXCLUDED.raw_data,
+			first_discovered_at = LEAST(crypto_implementations.first_discovered_at, EXCLUDED.first_discovered_at),
+			last_verified_at = GREATEST(crypto_implementations.last_verified_at, EXCLUDED.last_verified_at)
+		RETURNING id, (xmax = 0)`,
+		tenantID, assetID, nullInt(observation.Port), observation.Protocol, nullString(observation.Version),
+		nullString(observation.CipherSuite), certificateID, observation.DiscoveryMethod,
+		math.Round(observation.Confidence*100)/100, sensorID, rawData, observation.FirstSeen, observation.LastSeen,
+	).Scan(&implementationID, &created)
+	if err != nil {
+		return false, fmt.Errorf("failed to upsert crypto implementation: %w", err)
+	}
+
+	if certificateID.Valid {
+		_, err = tx.Exec(`
+			INSERT INTO certificate_endpoints (tenant_id, certificate_id, asset_id, port, first_seen_at, last_seen_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (certificate_id, asset_id, port) DO UPDATE SET
+				first_seen_at = LEAST(certificate_endpoints.first_seen_at, EXCLUDED.first_seen_at),
+				last_seen_at = GREATEST(certificate_endpoints.last_seen_at, EXCLUDED.last_seen_at)`,
+			tenantID, certificateID, assetID, observation.Port, observation.FirstSeen, observation.LastSeen,
+		)
+		if err != nil {
+			return false, fmt.Errorf("failed to record certificate endpoint: %w", err)
+		}
+	}
+
+	// Weak crypto is announced once, when the implementation is first seen,
+	// and in the same transaction so the event is never lost or duplicated
+	if created && len(observation.Weaknesses) > 0 {
+		alert := models.WeakCryptoAlert{
+			ImplementationID: implementationID,
+			AssetID:          assetID,
+			SensorID:         sensorID,
+			IPAddress:        observation.IPAddress,
+			Hostname:         observation.Hostname,
+			Port:             observation.Port,
+			Protocol:         observation.Protocol,
+			Version:          observation.Version,
+			CipherSuite:      observation.CipherSuite,
+			Weaknesses:       observation.Weaknesses,
+			DetectedAt:       observation.FirstSeen,
+		}
+		if _, err := publishWebhookEvent(tx, tenantID, sensorID, models.EventWeakCryptoDetected, alert); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit observation: %w", err)
+	}
+	return created, nil
+}
+
+// resolveAsset returns the asset an observation belongs to, creating it when
+// needed. Assets are matched by IP address first, preferring one with the
+// observed hostname, and otherwise by hostname among assets without an
+// address. Advisory locks on the identifiers serialize concurrent ingestion
+// of the same host so it is only created once.
+func resolveAsset(tx *sql.Tx, tenantID, sensorID string, observation *models.EndpointObservation) (string, error) {
+	for _, key := range []string{observation.IPAddress, observation.Hostname} {
+		if key == "" {
+			continue
+		}
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, tenantID+":asset:"+key); err != nil {
+			return "", fmt.Errorf("failed to lock asset: %w", err)
+		}
+	}
+
+	var assetID string
+	var err error
+	if observation.IPAddress != "" {
+		err = tx.QueryRow(`
+			SELECT id FROM network_assets
+			WHERE tenant_id = $1 AND ip_address = $2::inet AND deleted_at IS NULL
+			ORDER BY (LOWER(hostname) = LOWER($3)) DESC NULLS LAST, created_at
+			LIMIT 1`,
+			tenantID, observation.IPAddress, observation.Hostname,
+		).Scan(&assetID)
+	}
+	if (observation.IPAddress == "" || err == sql.ErrNoRows) && observation.Hostname != "" {
+		err = tx.QueryRow(`
+			SELECT id FROM network_assets
+			WHERE tenant_id = $1 AND LOWER(hostname) = LOWER($2) AND ip_address IS NULL AND deleted_at IS NULL
+			ORDER BY created_at
+			LIMIT 1`,
+			tenantID, observation.Hostname,
+		).Scan(&assetID)
+	}
+
+	switch {
+	case err == nil:
+		_, err = tx.Exec(`
+			UPDATE network_assets
+			SET last_seen_at = GREATEST(last_seen_at, $2),
+			    hostname = COALESCE(hostname, $3),
+			    ip_address = COALESCE(ip_address, $4::inet)
+			WHERE id = $1`,
+			assetID, observation.LastSeen, nullString(observation.Hostname), nullString(observation.IPAddress),
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to refresh asset: %w", err)
+		}
+		return assetID, nil
+	case err != sql.ErrNoRows:
+		return "", fmt.Errorf("failed to resolve asset: %w", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO network_assets (tenant_id, hostname, ip_address, asset_type, metadata, first_discovered_at, last_seen_at)
+		VALUES ($1, $2, $3::inet, 'server', jsonb_build_object('discovered_by_sensor', $4::text), $5, $6)
+		RETURNING id`,
+		tenantID, nullString(observation.Hostname), nullString(observation.IPAddress), sensorID,
+		observation.FirstSeen, observation.LastSeen,
+	).Scan(&assetID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create asset: %w", err)
+	}
+	return assetID, nil
+}
+
+// upsertCertificate stores a certificate by its SHA-256 fingerprint and
+// returns its ID. Certificates are immutable, so an existing row is only
+// completed with the PEM and key identifiers when it was stored without them.
+func upsertCertificate(tx *sql.Tx, tenantID string, certificate *models.ObservedCertificate) (string, error) {
+	var id string
+	err := tx.QueryRow(`
+		INSERT INTO certificates (tenant_id, serial_number, subject_dn, issuer_dn, common_name,
+		                          subject_alternative_names, signature_algorithm, public_key_algorithm,
+		                          public_key_size, not_before, not_after, fingerprint_sha1, fingerprint_sha256,
+		                          certificate_pem, is_self_signed, is_ca_certificate, key_usage, extended_key_usage,
+		                          subject_key_id, authority_key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+		ON CONFLICT (tenant_id, fingerprint_sha256) DO UPDATE SET
+			certificate_pem = COALESCE(certificates.certificate_pem, EXCLUDED.certificate_pem),
+			subject_key_id = COALESCE(certificates.subject_key_id, EXCLUDED.subject_key_id),
+			authority_key_id = COALESCE(certificates.authority_key_id, EXCLUDED.authority_key_id)
+		RETURNING id`,
+		tenantID, nullString(certificate.SerialNumber), certificate.SubjectDN, certificate.IssuerDN,
+		nullString(certificate.CommonName), pq.Array(certificate.SubjectAlternativeNames),
+		nullString(certificate.SignatureAlgorithm), nullString(certificate.PublicKeyAlgorithm),
+		nullInt(certificate.PublicKeySize), nullTimeValue(certificate.NotBefore), nullTimeValue(certificate.NotAfter),
+		nullString(certificate.FingerprintSHA1), certificate.FingerprintSHA256, nullString(certificate.PEM),
+		certificate.IsSelfSigned, certificate.IsCA, pq.Array(certificate.KeyUsage),
+		pq.Array(certificate.ExtendedKeyUsage), nullString(certificate.SubjectKeyID), nullString(certificate.AuthorityKeyID),
+	).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to upsert certificate %s: %w", certificate.FingerprintSHA256, err)
+	}
+	return id, nil
+}
+
+// nullInt converts a zero integer to SQL NULL
+func nullInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
+
+// nullTimeValue converts a zero time to SQL NULL
+func nullTimeValue(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
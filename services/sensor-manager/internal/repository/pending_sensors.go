@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/lib/pq"
+)
+
+const pendingSensorColumns = `
+	id, tenant_id, key_prefix, name, COALESCE(description, ''), allowed_cidrs::text[],
+	COALESCE(tags, '{}'), profile, COALESCE(network_interfaces, '{}'), COALESCE(group_id::text, ''),
+	max_sensors, used_count,
+	COALESCE(created_by::text, ''),
+	CASE
+		WHEN revoked_at IS NOT NULL THEN 'revoked'
+		WHEN status = 'pending' AND expires_at <= NOW() THEN 'expired'
+		ELSE status
+	END,
+	created_at, expires_at, used_at, revoked_at`
+
+// CreatePendingSensor stores a new registration key. The limit on active
+// keys is checked and the row inserted in one transaction, serialized per
+// tenant, so concurrent requests cannot exceed maxPending.
+func (r *Repository) CreatePendingSensor(pending *models.PendingSensor, keyHash string, maxPending int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, "pending_sensors:"+pending.TenantID); err != nil {
+		return fmt.Errorf("failed to lock pending sensors: %w", err)
+	}
+
+	var count int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM pending_sensors
+		WHERE tenant_id = $1 AND status = 'pending' AND revoked_at IS NULL AND expires_at > NOW()`,
+		pending.TenantID,
+	).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count pending sensors: %w", err)
+	}
+	if count >= maxPending {
+		return ErrLimitReached
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO pending_sensors (tenant_id, key_hash, key_prefix, name, description, allowed_cidrs,
+		                             tags, profile, network_interfaces, group_id, max_sensors, created_by, status,
+		                             expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6::cidr[], $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id, created_at`,
+		pending.TenantID, keyHash, pending.KeyPrefix, pending.Name, nullString(pending.Description),
+		pq.Array(pending.AllowedCIDRs), pq.Array(pending.Tags), pending.Profile,
+		pq.Array(pending.NetworkInterfaces), nullString(pending.GroupID), pending.MaxSensors, nullString(pending.CreatedBy),
+		models.PendingStatusPending, pending.ExpiresAt,
+	).Scan(&pending.ID, &pending.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to create pending sensor: %w", err)
+	}
+	pending.Status = models.PendingStatusPending
+
+	return tx.Commit()
+}
+
+// GetPendingSensorByKeyHash returns the registration key with the given hash
+func (r *Repository) GetPendingSensorByKeyHash(keyHash string) (*models.PendingSensor, error) {
+	row := r.db.QueryRow(`SELECT `+pendingSensorColumns+` FROM pending_sensors WHERE key_hash = $1`, keyHash)
+	pending, err := scanPendingSensor(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending sensor: %w", err)
+	}
+	return pending, nil
+}
+
+// ListPendingSensors returns all pending registrations for a tenant, newest first
+func (r *Repository) ListPendingSensors(tenantID string) ([]*models.PendingSensor, error) {
+	rows, err := r.db.Query(`
+		SELECT `+pendingSensorColumns+`
+		FROM pending_sensors
+		WHERE tenant_id = $1
+		ORDER BY created_at DESC`,
+		tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending sensors: %w", err)
+	}
+	defer rows.Close()
+
+	pendingSensors := []*models.PendingSensor{}
+	for rows.Next() {
+		pending, err := scanPendingSensor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pending sensor: %w", err)
+		}
+		pendingSensors = append(pendingSensors, pending)
+	}
+	return pendingSensors, rows.Err()
+}
+
+// RevokePendingSensor revokes a registration key of a tenant so it cannot
+// register further sensors. Sensors it already registered are unaffected.
+func (r *Repository) RevokePendingSensor(tenantID, id string) (*models.PendingSensor, error) {
+	row := r.db.QueryRow(`
+		UPDATE pending_sensors SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE tenant_id = $1 AND id = $2
+		RETURNING `+pendingSensorColumns,
+		tenantID, id,
+	)
+	pending, err := scanPendingSensor(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke pending sensor: %w", err)
+	}
+	return pending, nil
+}
+
+// DeletePendingSensor removes a registration key of a tenant
+func (r *Repository) DeletePendingSensor(tenantID, id string) error {
+	result, err := r.db.Exec(`DELETE FROM pending_sensors WHERE tenant_id = $1 AND id = $2`, tenantID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete pending sensor: %w", err)
+	}
+	return expectRows(result)
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPendingSensor(row scanner) (*models.PendingSensor, error) {
+	var pending models.PendingSensor
+	var usedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&pending.ID, &pending.TenantID, &pending.KeyPrefix, &pending.Name, &pending.Description,
+		pq.Array(&pending.AllowedCIDRs), pq.Array(&pending.Tags), &pending.Profile, pq.Array(&pending.NetworkInterfaces),
+		&pending.GroupID, &pending.MaxSensors, &pending.UsedCount, &pending.CreatedBy, &pending.Status,
+		&pending.CreatedAt, &pending.ExpiresAt, &usedAt, &revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	pending.UsedAt = nullTime(usedAt)
+	pending.RevokedAt = nullTime(revokedAt)
+	return &pending, nil
+}
@@ -0,0 +1,14 @@
+package repository
+
+import "fmt"
+
+// UserHasPermission reports whether a user holds a tenant permission
+// through one of their active roles in the tenant
+func (r *Repository) UserHasPermission(userID, tenantID, permission string) (bool, error) {
+	var granted bool
+	err := r.db.QueryRow(`SELECT user_has_permission($1, $2, $3)`, userID, tenantID, permission).Scan(&granted)
+	if err != nil {
+		return false, fmt.Errorf("failed to check permission: %w", err)
+	}
+	return granted, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+const sensorCertificateColumns = `
+	serial_number, tenant_id, sensor_id, fingerprint_sha256, not_before, not_after,
+	revoked_at, revocation_reason, created_at`
+
+// GetCertificateAuthority returns the stored CA of a tenant
+func (r *Repository) GetCertificateAuthority(tenantID string) (*models.CertificateAuthorityRecord, error) {
+	var record models.CertificateAuthorityRecord
+	err := r.db.QueryRow(`
+		SELECT tenant_id, key_algorithm, certificate_pem, encrypted_private_key, not_before, not_after
+		FROM sensor_certificate_authorities WHERE tenant_id = $1`,
+		tenantID,
+	).Scan(&record.TenantID, &record.KeyAlgorithm, &record.CertificatePEM, &record.EncryptedPrivateKey,
+		&record.NotBefore, &record.NotAfter)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate authority: %w", err)
+	}
+	return &record, nil
+}
+
+// CreateCertificateAuthority stores a tenant CA unless one already exists.
+// It reports whether the given record was stored; when two replicas race to
+// create a CA, only the first one wins and the loser must reload.
+func (r *Repository) CreateCertificateAuthority(record *models.CertificateAuthorityRecord) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO sensor_certificate_authorities
+			(tenant_id, key_algorithm, certificate_pem, encrypted_private_key, not_before, not_after)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id) DO NOTHING`,
+		record.TenantID, record.KeyAlgorithm, record.CertificatePEM, record.EncryptedPrivateKey,
+		record.NotBefore, record.NotAfter,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create certificate authority: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// GetSensorCertificate returns an issued certificate by serial number
+func (r *Repository) GetSensorCertificate(serialNumber string) (*models.SensorCertificate, error) {
+	row := r.db.QueryRow(`SELECT `+sensorCertificateColumns+` FROM sensor_certificates WHERE serial_number = $1`, serialNumber)
+	certificate, err := scanSensorCertificate(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor certificate: %w", err)
+	}
+	return certificate, nil
+}
+
+// ListSensorCertificates returns all certificates issued to a sensor, newest first
+func (r *Repository) ListSensorCertificates(sensorID string) ([]*models.SensorCertificate, error) {
+	return r.querySensorCertificates(`
+		SELECT `+sensorCertificateColumns+` FROM sensor_certificates
+		WHERE sensor_id = $1 ORDER BY created_at DESC`,
+		sensorID,
+	)
+}
+
+// ListRevokedCertificates returns the revoked certificates of a tenant that
+// have not yet expired, which is what a CRL must list
+func (r *Repository) ListRevokedCertificates(tenantID string) ([]*models.SensorCertificate, error) {
+	return r.querySensorCertificates(`
+		SELECT `+sensorCertificateColumns+` FROM sensor_certificates
+		WHERE tenant_id = $1 AND revoked_at IS NOT NULL AND not_after > NOW()
+		ORDER BY revoked_at`,
+		tenantID,
+	)
+}
+
+// RevokeSensorCertificates revokes every unrevoked certificate of a sensor
+// and returns how many were revoked
+func (r *Repository) RevokeSensorCertificates(tenantID, sensorID string, reason int) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE sensor_certificates SET revoked_at = NOW(), revocation_reason = $3
+		WHERE tenant_id = $1 AND sensor_id = $2 AND revoked_at IS NULL`,
+		tenantID, sensorID, reason,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sensor certificates: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *Repository) querySensorCertificates(query string, args ...interface{}) ([]*models.SensorCertificate, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor certificates: %w", err)
+	}
+	defer rows.Close()
+
+	certificates := []*models.SensorCertificate{}
+	for rows.Next() {
+		certificate, err := scanSensorCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor certificate: %w", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, rows.Err()
+}
+
+// insertSensorCertificate records an issued certificate
+func insertSensorCertificate(tx *sql.Tx, certificate *models.SensorCertificate) error {
+	err := tx.QueryRow(`
+		INSERT INTO sensor_certificates
+			(serial_number, tenant_id, sensor_id, fingerprint_sha256, not_before, not_after)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`,
+		certificate.SerialNumber, certificate.TenantID, certificate.SensorID,
+		certificate.FingerprintSHA256, certificate.NotBefore, certificate.NotAfter,
+	).Scan(&certificate.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record sensor certificate: %w", err)
+	}
+	return nil
+}
+
+func scanSensorCertificate(row scanner) (*models.SensorCertificate, error) {
+	var certificate models.SensorCertificate
+	var revokedAt sql.NullTime
+	var reason sql.NullInt64
+	err := row.Scan(
+		&certificate.SerialNumber, &certificate.TenantID, &certificate.SensorID, &certificate.FingerprintSHA256,
+		&certificate.NotBefore, &certificate.NotAfter, &revokedAt, &reason, &certificate.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	certificate.RevokedAt = nullTime(revokedAt)
+	if reason.Valid {
+		code := int(reason.Int64)
+		certificate.RevocationReason = &code
+	}
+	return &certificate, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/lib/pq"
+)
+
+const registrationAttemptColumns = `
+	id, COALESCE(tenant_id::text, ''), COALESCE(registration_key_id::text, ''), COALESCE(sensor_id::text, ''),
+	COALESCE(key_prefix, ''), COALESCE(sensor_name, ''), COALESCE(host(declared_ip), ''),
+	COALESCE(host(client_ip), ''), COALESCE(host(remote_addr), ''), COALESCE(forwarded_for, ''),
+	allowed_cidrs::text[], outcome, COALESCE(reason, ''), COALESCE(detail, ''), attempted_at`
+
+// RegistrationAttemptFilters narrows the registration audit log
+type RegistrationAttemptFilters struct {
+	RegistrationKeyID string
+	Outcome           string
+	Page              int
+	PageSize          int
+}
+
+// RecordRegistrationAttempt appends an entry to the registration audit log.
+// Addresses that are not valid IPs are stored as NULL.
+func (r *Repository) RecordRegistrationAttempt(attempt *models.RegistrationAttempt) error {
+	err := r.db.QueryRow(`
+		INSERT INTO sensor_registration_attempts (tenant_id, registration_key_id, sensor_id, key_prefix,
+		                                          sensor_name, declared_ip, client_ip, remote_addr, forwarded_for,
+		                                          allowed_cidrs, outcome, reason, detail)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::cidr[], $11, $12, $13)
+		RETURNING id, attempted_at`,
+		nullString(attempt.TenantID), nullString(attempt.RegistrationKeyID), nullString(attempt.SensorID),
+		nullString(attempt.KeyPrefix), nullString(attempt.SensorName), nullIP(attempt.DeclaredIP), nullIP(attempt.ClientIP),
+		nullIP(attempt.RemoteAddr), nullString(attempt.ForwardedFor), pq.Array(nonNil(attempt.AllowedCIDRs)),
+		attempt.Outcome, nullString(attempt.Reason), nullString(attempt.Detail),
+	).Scan(&attempt.ID, &attempt.AttemptedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record registration attempt: %w", err)
+	}
+	return nil
+}
+
+// ListRegistrationAttempts returns a page of a tenant's registration audit
+// log, newest first, and the total number of matching entries
+func (r *Repository) ListRegistrationAttempts(tenantID string, filters RegistrationAttemptFilters) ([]*models.RegistrationAttempt, int, error) {
+	where := `WHERE tenant_id = $1
+		AND ($2 = '' OR registration_key_id::text = $2)
+		AND ($3 = '' OR outcome = $3)`
+	args := []interface{}{tenantID, filters.RegistrationKeyID, filters.Outcome}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sensor_registration_attempts `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count registration attempts: %w", err)
+	}
+
+	page, pageSize := filters.Page, filters.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := r.db.Query(`
+		SELECT `+registrationAttemptColumns+` FROM sensor_registration_attempts `+where+`
+		ORDER BY attempted_at DESC
+		LIMIT $4 OFFSET $5`,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list registration attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []*models.RegistrationAttempt{}
+	for rows.Next() {
+		var attempt models.RegistrationAttempt
+		err := rows.Scan(
+			&attempt.ID, &attempt.TenantID, &attempt.RegistrationKeyID, &attempt.SensorID, &attempt.KeyPrefix,
+			&attempt.SensorName, &attempt.DeclaredIP, &attempt.ClientIP, &attempt.RemoteAddr,
+			&attempt.ForwardedFor, pq.Array(&attempt.AllowedCIDRs), &attempt.Outcome, &attempt.Reason,
+			&attempt.Detail, &attempt.AttemptedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan registration attempt: %w", err)
+		}
+		attempts = append(attempts, &attempt)
+	}
+	return attempts, total, rows.Err()
+}
+
+// nullIP converts anything but a valid IP address to SQL NULL
+func nullIP(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: net.ParseIP(s) != nil}
+}
+
+// nonNil returns an empty slice instead of nil, so arrays are stored as '{}'
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
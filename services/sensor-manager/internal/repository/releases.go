@@ -0,0 +1,738 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/lib/pq"
+)
+
+const releaseColumns = `
+	id, version, COALESCE(notes, ''), COALESCE(published_by, ''), created_at, yanked_at, COALESCE(yank_reason, '')`
+
+const rolloutColumns = `
+	r.id, r.tenant_id, r.release_id, rel.version, r.status, r.stages, r.current_stage, r.max_failures,
+	r.soak_seconds, COALESCE(r.pause_reason, ''), COALESCE(r.created_by::text, ''), r.created_at, r.updated_at,
+	r.stage_completed_at, r.completed_at`
+
+const rolloutFrom = `
+	FROM sensor_rollouts r
+	JOIN sensor_releases rel ON rel.id = r.release_id`
+
+// CreateSensorRelease adds a release to the catalog. ErrConflict is
+// returned when the version exists.
+func (r *Repository) CreateSensorRelease(release *models.SensorRelease) error {
+	err := r.db.QueryRow(`
+		INSERT INTO sensor_releases (version, notes, published_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		release.Version, nullString(release.Notes), nullString(release.PublishedBy),
+	).Scan(&release.ID, &release.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to create release: %w", err)
+	}
+	release.Artifacts = []*models.ReleaseArtifact{}
+	return nil
+}
+
+// GetSensorRelease returns a release with its artifacts
+func (r *Repository) GetSensorRelease(releaseID string) (*models.SensorRelease, error) {
+	return r.getSensorRelease(`id = $1`, releaseID)
+}
+
+// GetSensorReleaseByVersion returns the release of a version with its
+// artifacts
+func (r *Repository) GetSensorReleaseByVersion(version string) (*models.SensorRelease, error) {
+	return r.getSensorRelease(`version = $1`, version)
+}
+
+func (r *Repository) getSensorRelease(condition string, arg interface{}) (*models.SensorRelease, error) {
+	release, err := scanSensorRelease(r.db.QueryRow(`SELECT `+releaseColumns+` FROM sensor_releases WHERE `+condition, arg))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+
+	artifacts, err := r.listReleaseArtifacts([]string{release.ID})
+	if err != nil {
+		return nil, err
+	}
+	release.Artifacts = artifacts[release.ID]
+	return release, nil
+}
+
+// ListSensorReleases returns the catalog, newest first. Yanked releases
+// are left out unless includeYanked is set.
+func (r *Repository) ListSensorReleases(includeYanked bool) ([]*models.SensorRelease, error) {
+	query := `SELECT ` + releaseColumns + ` FROM sensor_releases`
+	if !includeYanked {
+		query += ` WHERE yanked_at IS NULL`
+	}
+	rows, err := r.db.Query(query + ` ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	defer rows.Close()
+
+	releases := []*models.SensorRelease{}
+	ids := []string{}
+	for rows.Next() {
+		release, err := scanSensorRelease(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan release: %w", err)
+		}
+		releases = append(releases, release)
+		ids = append(ids, release.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	artifacts, err := r.listReleaseArtifacts(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, release := range releases {
+		release.Artifacts = artifacts[release.ID]
+	}
+	return releases, nil
+}
+
+// YankSensorRelease withdraws a release. Running rollouts of the release
+// are paused, so no further sensors are upgraded to it.
+func (r *Repository) YankSensorRelease(releaseID, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE sensor_releases SET yanked_at = NOW(), yank_reason = $2
+		WHERE id = $1 AND yanked_at IS NULL`,
+		releaseID, nullString(reason),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to yank release: %w", err)
+	}
+	if err := expectRows(result); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sensor_rollouts SET status = 'paused', pause_reason = 'release was yanked'
+		WHERE release_id = $1 AND status = 'running'`,
+		releaseID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to pause rollouts of yanked release: %w", err)
+	}
+	return tx.Commit()
+}
+
+// CreateReleaseArtifact records the binary of a release for a platform.
+// Artifacts are immutable: ErrConflict is returned when the platform
+// already has one, and ErrNotFound when the release does not exist or was
+// yanked.
+func (r *Repository) CreateReleaseArtifact(artifact *models.ReleaseArtifact) error {
+	err := r.db.QueryRow(`
+		INSERT INTO sensor_release_artifacts (release_id, platform, size_bytes, sha256, signature, storage_key)
+		SELECT id, $2, $3, $4, $5, $6 FROM sensor_releases WHERE id = $1 AND yanked_at IS NULL
+		RETURNING created_at`,
+		artifact.ReleaseID, artifact.Platform, artifact.Size, artifact.SHA256, artifact.Signature, artifact.StorageKey,
+	).Scan(&artifact.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to create release artifact: %w", err)
+	}
+	return nil
+}
+
+// GetReleaseArtifact returns the artifact of a version for a platform.
+// Artifacts of yanked releases are not served.
+func (r *Repository) GetReleaseArtifact(version, platform string) (*models.ReleaseArtifact, error) {
+	artifact := &models.ReleaseArtifact{}
+	err := r.db.QueryRow(`
+		SELECT a.release_id, a.platform, a.size_bytes, a.sha256, a.signature, a.storage_key, a.created_at
+		FROM sensor_release_artifacts a
+		JOIN sensor_releases rel ON rel.id = a.release_id
+		WHERE rel.version = $1 AND a.platform = $2 AND rel.yanked_at IS NULL`,
+		version, platform,
+	).Scan(&artifact.ReleaseID, &artifact.Platform, &artifact.Size, &artifact.SHA256, &artifact.Signature,
+		&artifact.StorageKey, &artifact.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release artifact: %w", err)
+	}
+	return artifact, nil
+}
+
+func (r *Repository) listReleaseArtifacts(releaseIDs []string) (map[string][]*models.ReleaseArtifact, error) {
+	artifacts := make(map[string][]*models.ReleaseArtifact, len(releaseIDs))
+	for _, id := range releaseIDs {
+		artifacts[id] = []*models.ReleaseArtifact{}
+	}
+	if len(releaseIDs) == 0 {
+		return artifacts, nil
+	}
+
+	rows, err := r.db.Query(`
+		SELECT release_id, platform, size_bytes, sha256, signature, storage_key, created_at
+		FROM sensor_release_artifacts
+		WHERE release_id = ANY($1::uuid[])
+		ORDER BY platform`,
+		pq.Array(releaseIDs),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list release artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		artifact := &models.ReleaseArtifact{}
+		if err := rows.Scan(&artifact.ReleaseID, &artifact.Platform, &artifact.Size, &artifact.SHA256,
+			&artifact.Signature, &artifact.StorageKey, &artifact.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan release artifact: %w", err)
+		}
+		artifacts[artifact.ReleaseID] = append(artifacts[artifact.ReleaseID], artifact)
+	}
+	return artifacts, rows.Err()
+}
+
+// CreateSensorRollout stores a rollout with its targets, given per stage.
+// Sensors already running the release version are skipped. ErrConflict is
+// returned when a target is still being upgraded by another rollout.
+func (r *Repository) CreateSensorRollout(rollout *models.SensorRollout, stageTargets [][]string) error {
+	stages, err := json.Marshal(rollout.Stages)
+	if err != nil {
+		return fmt.Errorf("failed to encode rollout stages: %w", err)
+	}
+	all := []string{}
+	for _, ids := range stageTargets {
+		all = append(all, ids...)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var busy bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM sensor_rollout_targets t
+			JOIN sensor_rollouts r ON r.id = t.rollout_id
+			WHERE t.sensor_id = ANY($1::uuid[]) AND r.status IN ('running', 'paused')
+			  AND t.status IN ('pending', 'upgrading')
+		)`,
+		pq.Array(all),
+	).Scan(&busy)
+	if err != nil {
+		return fmt.Errorf("failed to check active rollouts: %w", err)
+	}
+	if busy {
+		return ErrConflict
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO sensor_rollouts (tenant_id, release_id, status, stages, max_failures, soak_seconds, created_by)
+		VALUES ($1, $2, 'running', $3, $4, $5, $6)
+		RETURNING id, status, current_stage, created_at, updated_at`,
+		rollout.TenantID, rollout.ReleaseID, stages, rollout.MaxFailures, rollout.SoakSeconds,
+		nullString(rollout.CreatedBy),
+	).Scan(&rollout.ID, &rollout.Status, &rollout.CurrentStage, &rollout.CreatedAt, &rollout.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create rollout: %w", err)
+	}
+
+	for stage, ids := range stageTargets {
+		_, err := tx.Exec(`
+			INSERT INTO sensor_rollout_targets (rollout_id, sensor_id, stage, status, from_version)
+			SELECT $1, s.id, $3, CASE WHEN s.version = $4 THEN 'skipped' ELSE 'pending' END, s.version
+			FROM sensors s
+			WHERE s.id = ANY($2::uuid[]) AND s.tenant_id = $5`,
+			rollout.ID, pq.Array(ids), stage, rollout.Version, rollout.TenantID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store rollout targets: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSensorRollout returns a tenant's rollout with its progress and targets
+func (r *Repository) GetSensorRollout(tenantID, rolloutID string) (*models.SensorRollout, error) {
+	rollout, err := scanSensorRollout(r.db.QueryRow(`SELECT `+rolloutColumns+rolloutFrom+` WHERE r.id = $1 AND r.tenant_id = $2`,
+		rolloutID, tenantID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rollout: %w", err)
+	}
+
+	progress, err := r.rolloutProgress([]string{rollout.ID})
+	if err != nil {
+		return nil, err
+	}
+	rollout.Progress = progress[rollout.ID]
+
+	rows, err := r.db.Query(`
+		SELECT t.sensor_id, s.name, t.stage, t.status, COALESCE(t.command_id::text, ''),
+		       COALESCE(t.from_version, ''), COALESCE(t.error, ''), t.started_at, t.completed_at
+		FROM sensor_rollout_targets t
+		JOIN sensors s ON s.id = t.sensor_id
+		WHERE t.rollout_id = $1
+		ORDER BY t.stage, s.name`,
+		rollout.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rollout targets: %w", err)
+	}
+	defer rows.Close()
+
+	rollout.Targets = []*models.RolloutTarget{}
+	for rows.Next() {
+		target := &models.RolloutTarget{}
+		var startedAt, completedAt sql.NullTime
+		if err := rows.Scan(&target.SensorID, &target.SensorName, &target.Stage, &target.Status, &target.CommandID,
+			&target.FromVersion, &target.Error, &startedAt, &completedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rollout target: %w", err)
+		}
+		target.StartedAt = nullTime(startedAt)
+		target.CompletedAt = nullTime(completedAt)
+		rollout.Targets = append(rollout.Targets, target)
+	}
+	return rollout, rows.Err()
+}
+
+// ListSensorRollouts returns a page of a tenant's rollouts, newest first,
+// optionally limited to one status, and the total number of matches
+func (r *Repository) ListSensorRollouts(tenantID, status string, page, pageSize int) ([]*models.SensorRollout, int, error) {
+	conditions := []string{"r.tenant_id = $1"}
+	args := []interface{}{tenantID}
+	if status != "" {
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("r.status = $%d", len(args)))
+	}
+	where := ` WHERE ` + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sensor_rollouts r`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count rollouts: %w", err)
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := r.db.Query(`SELECT `+rolloutColumns+rolloutFrom+where+
+		fmt.Sprintf(` ORDER BY r.created_at DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list rollouts: %w", err)
+	}
+	defer rows.Close()
+
+	rollouts := []*models.SensorRollout{}
+	ids := []string{}
+	for rows.Next() {
+		rollout, err := scanSensorRollout(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan rollout: %w", err)
+		}
+		rollouts = append(rollouts, rollout)
+		ids = append(ids, rollout.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	progress, err := r.rolloutProgress(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, rollout := range rollouts {
+		rollout.Progress = progress[rollout.ID]
+	}
+	return rollouts, total, nil
+}
+
+// ListRunningRollouts returns the running rollouts of all tenants, for the
+// rollout service to advance
+func (r *Repository) ListRunningRollouts() ([]*models.SensorRollout, error) {
+	rows, err := r.db.Query(`SELECT ` + rolloutColumns + rolloutFrom + ` WHERE r.status = 'running' ORDER BY r.created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running rollouts: %w", err)
+	}
+	defer rows.Close()
+
+	rollouts := []*models.SensorRollout{}
+	for rows.Next() {
+		rollout, err := scanSensorRollout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rollout: %w", err)
+		}
+		rollouts = append(rollouts, rollout)
+	}
+	return rollouts, rows.Err()
+}
+
+// RolloutStageCounts returns the number of targets per status of a
+// rollout stage, and the number of failed targets over all stages
+func (r *Repository) RolloutStageCounts(rolloutID string, stage int) (map[string]int, int, error) {
+	rows, err := r.db.Query(`
+		SELECT status, COUNT(*) FILTER (WHERE stage = $2), COUNT(*)
+		FROM sensor_rollout_targets
+		WHERE rollout_id = $1
+		GROUP BY status`,
+		rolloutID, stage,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count rollout targets: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	failed := 0
+	for rows.Next() {
+		var status string
+		var inStage, total int
+		if err := rows.Scan(&status, &inStage, &total); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan rollout target count: %w", err)
+		}
+		counts[status] = inStage
+		if status == models.RolloutTargetFailed {
+			failed = total
+		}
+	}
+	return counts, failed, rows.Err()
+}
+
+// SyncRolloutTargets settles upgrading targets from their upgrade
+// commands: a succeeded command succeeds the target and a failed or
+// expired one fails it. A target also succeeds once its sensor reports the
+// release version, in case the result report was lost. It returns the
+// number of targets settled.
+func (r *Repository) SyncRolloutTargets() (int64, error) {
+	statements := []string{
+		`UPDATE sensor_rollout_targets t
+		 SET status = CASE WHEN c.status = 'succeeded' THEN 'succeeded' ELSE 'failed' END,
+		     error = CASE WHEN c.status = 'succeeded' THEN NULL
+		                  ELSE COALESCE(NULLIF(c.error, ''), 'upgrade command ' || c.status) END,
+		     completed_at = COALESCE(c.completed_at, NOW())
+		 FROM sensor_commands c
+		 WHERE t.status = 'upgrading' AND c.id = t.command_id
+		   AND c.status IN ('succeeded', 'failed', 'expired')`,
+		`UPDATE sensor_rollout_targets t
+		 SET status = 'succeeded', error = NULL, completed_at = NOW()
+		 FROM sensor_rollouts r, sensor_releases rel, sensors s
+		 WHERE t.status = 'upgrading' AND r.id = t.rollout_id AND rel.id = r.release_id
+		   AND s.id = t.sensor_id AND s.version = rel.version`,
+		`UPDATE sensor_rollout_targets
+		 SET status = 'failed', error = 'upgrade command was removed', completed_at = NOW()
+		 WHERE status = 'upgrading' AND command_id IS NULL AND started_at < NOW() - INTERVAL '10 minutes'`,
+	}
+
+	var settled int64
+	for _, statement := range statements {
+		result, err := r.db.Exec(statement)
+		if err != nil {
+			return settled, fmt.Errorf("failed to sync rollout targets: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return settled, fmt.Errorf("failed to sync rollout targets: %w", err)
+		}
+		settled += affected
+	}
+	return settled, nil
+}
+
+// ClaimRolloutTargets moves the pending targets of a running rollout's
+// stage to upgrading and returns their sensor IDs. A rollout paused or
+// cancelled in the meantime has none claimed.
+func (r *Repository) ClaimRolloutTargets(rolloutID string, stage int) ([]string, error) {
+	return r.querySensorIDs(`
+		UPDATE sensor_rollout_targets t
+		SET status = 'upgrading', started_at = NOW(), error = NULL, completed_at = NULL
+		FROM sensor_rollouts r
+		WHERE t.rollout_id = $1 AND t.stage = $2 AND t.status = 'pending'
+		  AND r.id = t.rollout_id AND r.status = 'running' AND r.current_stage = $2
+		RETURNING t.sensor_id`,
+		rolloutID, stage)
+}
+
+// SetRolloutTargetCommand records the upgrade command sent to a target
+func (r *Repository) SetRolloutTargetCommand(rolloutID, sensorID, commandID string) error {
+	result, err := r.db.Exec(`
+		UPDATE sensor_rollout_targets SET command_id = $3
+		WHERE rollout_id = $1 AND sensor_id = $2`,
+		rolloutID, sensorID, commandID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record rollout command: %w", err)
+	}
+	return expectRows(result)
+}
+
+// FailRolloutTargets fails upgrading targets whose upgrade could not be
+// started
+func (r *Repository) FailRolloutTargets(rolloutID string, sensorIDs []string, reason string) error {
+	_, err := r.db.Exec(`
+		UPDATE sensor_rollout_targets SET status = 'failed', error = $3, completed_at = NOW()
+		WHERE rollout_id = $1 AND sensor_id = ANY($2::uuid[]) AND status = 'upgrading'`,
+		rolloutID, pq.Array(sensorIDs), reason,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fail rollout targets: %w", err)
+	}
+	return nil
+}
+
+// CompleteRolloutStage records when the current stage of a running rollout
+// finished, unless already recorded
+func (r *Repository) CompleteRolloutStage(rolloutID string, stage int) error {
+	_, err := r.db.Exec(`
+		UPDATE sensor_rollouts SET stage_completed_at = NOW()
+		WHERE id = $1 AND current_stage = $2 AND status = 'running' AND stage_completed_at IS NULL`,
+		rolloutID, stage,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete rollout stage: %w", err)
+	}
+	return nil
+}
+
+// AdvanceRollout moves a running rollout from stage to the next one, or
+// completes it after the last stage. It reports false when the rollout
+// changed in the meantime.
+func (r *Repository) AdvanceRollout(rolloutID string, stage int, last bool) (bool, error) {
+	query := `
+		UPDATE sensor_rollouts SET current_stage = current_stage + 1, stage_completed_at = NULL
+		WHERE id = $1 AND current_stage = $2 AND status = 'running'`
+	if last {
+		query = `
+			UPDATE sensor_rollouts SET status = 'completed', completed_at = NOW()
+			WHERE id = $1 AND current_stage = $2 AND status = 'running'`
+	}
+	result, err := r.db.Exec(query, rolloutID, stage)
+	if err != nil {
+		return false, fmt.Errorf("failed to advance rollout: %w", err)
+	}
+	if err := expectRows(result); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// PauseSensorRollout pauses a running rollout. ErrInvalidTransition is
+// returned when the rollout is not running.
+func (r *Repository) PauseSensorRollout(tenantID, rolloutID, reason string) error {
+	return r.transitionRollout(tenantID, rolloutID, `
+		UPDATE sensor_rollouts SET status = 'paused', pause_reason = $3
+		WHERE id = $1 AND tenant_id = $2 AND status = 'running'`,
+		reason)
+}
+
+// ResumeSensorRollout resumes a paused rollout. Targets that failed are
+// retried when retryFailed is set and skipped otherwise, so they no longer
+// count towards the failure limit. ErrInvalidTransition is returned when
+// the rollout is not paused.
+func (r *Repository) ResumeSensorRollout(tenantID, rolloutID string, retryFailed bool) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE sensor_rollouts SET status = 'running', pause_reason = NULL
+		WHERE id = $1 AND tenant_id = $2 AND status = 'paused'`,
+		rolloutID, tenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to resume rollout: %w", err)
+	}
+	if err := r.expectTransition(result, tenantID, rolloutID); err != nil {
+		return err
+	}
+
+	status := models.RolloutTargetSkipped
+	if retryFailed {
+		status = models.RolloutTargetPending
+	}
+	_, err = tx.Exec(`
+		UPDATE sensor_rollout_targets
+		SET status = $2, command_id = NULL, started_at = NULL, completed_at = NULL
+		WHERE rollout_id = $1 AND status = 'failed'`,
+		rolloutID, status,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed rollout targets: %w", err)
+	}
+	return tx.Commit()
+}
+
+// CancelSensorRollout cancels a running or paused rollout and skips its
+// pending targets. It returns the sensors still being upgraded, whose
+// upgrade commands the caller may withdraw. ErrInvalidTransition is
+// returned when the rollout already ended.
+func (r *Repository) CancelSensorRollout(tenantID, rolloutID string) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE sensor_rollouts SET status = 'cancelled', completed_at = NOW()
+		WHERE id = $1 AND tenant_id = $2 AND status IN ('running', 'paused')`,
+		rolloutID, tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel rollout: %w", err)
+	}
+	if err := r.expectTransition(result, tenantID, rolloutID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sensor_rollout_targets SET status = 'skipped'
+		WHERE rollout_id = $1 AND status = 'pending'`,
+		rolloutID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to skip rollout targets: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT sensor_id FROM sensor_rollout_targets
+		WHERE rollout_id = $1 AND status = 'upgrading'`,
+		rolloutID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upgrading targets: %w", err)
+	}
+	defer rows.Close()
+
+	upgrading := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor ID: %w", err)
+		}
+		upgrading = append(upgrading, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	return upgrading, tx.Commit()
+}
+
+// transitionRollout runs a conditional status update of a tenant's rollout
+func (r *Repository) transitionRollout(tenantID, rolloutID, query string, args ...interface{}) error {
+	result, err := r.db.Exec(query, append([]interface{}{rolloutID, tenantID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to update rollout: %w", err)
+	}
+	return r.expectTransition(result, tenantID, rolloutID)
+}
+
+// expectTransition tells a rollout that does not exist (ErrNotFound) from
+// one whose status does not allow the change (ErrInvalidTransition)
+func (r *Repository) expectTransition(result sql.Result, tenantID, rolloutID string) error {
+	if err := expectRows(result); err != ErrNotFound {
+		return err
+	}
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sensor_rollouts WHERE id = $1 AND tenant_id = $2)`,
+		rolloutID, tenantID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to get rollout: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrInvalidTransition
+}
+
+// rolloutProgress counts the targets of rollouts per status
+func (r *Repository) rolloutProgress(rolloutIDs []string) (map[string]map[string]int, error) {
+	progress := make(map[string]map[string]int, len(rolloutIDs))
+	for _, id := range rolloutIDs {
+		progress[id] = map[string]int{}
+	}
+	if len(rolloutIDs) == 0 {
+		return progress, nil
+	}
+
+	rows, err := r.db.Query(`
+		SELECT rollout_id, status, COUNT(*)
+		FROM sensor_rollout_targets
+		WHERE rollout_id = ANY($1::uuid[])
+		GROUP BY rollout_id, status`,
+		pq.Array(rolloutIDs),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count rollout targets: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, status string
+		var count int
+		if err := rows.Scan(&id, &status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan rollout target count: %w", err)
+		}
+		progress[id][status] = count
+	}
+	return progress, rows.Err()
+}
+
+func scanSensorRelease(row scanner) (*models.SensorRelease, error) {
+	release := &models.SensorRelease{}
+	var yankedAt sql.NullTime
+	if err := row.Scan(&release.ID, &release.Version, &release.Notes, &release.PublishedBy, &release.CreatedAt,
+		&yankedAt, &release.YankReason); err != nil {
+		return nil, err
+	}
+	release.YankedAt = nullTime(yankedAt)
+	return release, nil
+}
+
+func scanSensorRollout(row scanner) (*models.SensorRollout, error) {
+	rollout := &models.SensorRollout{}
+	var stages []byte
+	var stageCompletedAt, completedAt sql.NullTime
+	if err := row.Scan(&rollout.ID, &rollout.TenantID, &rollout.ReleaseID, &rollout.Version, &rollout.Status,
+		&stages, &rollout.CurrentStage, &rollout.MaxFailures, &rollout.SoakSeconds, &rollout.PauseReason,
+		&rollout.CreatedBy, &rollout.CreatedAt, &rollout.UpdatedAt, &stageCompletedAt, &completedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stages, &rollout.Stages); err != nil {
+		return nil, fmt.Errorf("invalid rollout stages: %w", err)
+	}
+	rollout.StageCompletedAt = nullTime(stageCompletedAt)
+	rollout.CompletedAt = nullTime(completedAt)
+	return rollout, nil
+}
@@ -0,0 +1,76 @@
+// Package repository persists sensor-manager state in PostgreSQL. All
+// methods are safe for concurrent use; consistency between concurrent
+// requests is enforced by the database rather than in-process locks.
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a write violates a uniqueness constraint
+	ErrConflict = errors.New("record already exists")
+	// ErrKeyUnavailable is returned when a registration key was already used
+	// or expired by the time it was claimed
+	ErrKeyUnavailable = errors.New("registration key is no longer available")
+	// ErrLimitReached is returned when a configured limit would be exceeded
+	ErrLimitReached = errors.New("limit reached")
+	// ErrInUse is returned when a record cannot be deleted because other
+	// records still reference it
+	ErrInUse = errors.New("record is still in use")
+)
+
+// Repository provides access to sensor-manager data
+type Repository struct {
+	db *sql.DB
+}
+
+// New creates a new repository backed by the given database
+func New(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// expectRows returns ErrNotFound when a write statement matched no rows
+func expectRows(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a PostgreSQL
+// foreign_key_violation
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// nullString converts an empty string to SQL NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime converts a nullable timestamp column to a pointer
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
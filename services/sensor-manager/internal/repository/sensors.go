@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/lib/pq"
+)
+
+const sensorColumns = `
+	id, tenant_id, name, COALESCE(description, ''), COALESCE(platform, ''), COALESCE(version, ''),
+	COALESCE(profile, ''), COALESCE(host(ip_address), ''), COALESCE(hostname, ''),
+	COALESCE(network_interfaces, '{}'), COALESCE(tags, '{}'), status,
+	COALESCE(registration_key_id::text, ''), COALESCE(group_id::text, ''), config_overrides,
+	COALESCE(configuration, '{}'), config_version, applied_config_version, config_applied_at,
+	COALESCE(last_health, '{}'), last_heartbeat_at, health_state, health_reasons, health_changed_at, registered_at, decommissioned_at, created_at, updated_at`
+
+// RegisterSensor claims one use of a registration key, creates the sensor
+// in the key's tenant and records its client certificate in one
+// transaction. The sensor ID must be set by the caller since it is embedded
+// in the certificate. ErrKeyUnavailable is returned when the key was used
+// up, revoked or expired by the time it was claimed. buildConfiguration,
+// when set, produces the sensor's initial configuration (version 1) after
+// the key's tenant and group are known.
+func (r *Repository) RegisterSensor(keyHash string, sensor *models.RegisteredSensor, certificate *models.SensorCertificate,
+	buildConfiguration func(*models.RegisteredSensor) (json.RawMessage, error)) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE pending_sensors
+		SET used_count = used_count + 1,
+		    status = CASE WHEN used_count + 1 >= max_sensors THEN 'used' ELSE status END,
+		    used_at = NOW()
+		WHERE key_hash = $1 AND status = 'pending' AND revoked_at IS NULL
+		  AND expires_at > NOW() AND used_count < max_sensors
+		RETURNING id, tenant_id, COALESCE(group_id::text, '')`,
+		keyHash,
+	).Scan(&sensor.RegistrationKeyID, &sensor.TenantID, &sensor.GroupID)
+	if err == sql.ErrNoRows {
+		return ErrKeyUnavailable
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim registration key: %w", err)
+	}
+
+	// The configuration is built from the key's group, so it is only known
+	// once the key has been claimed
+	if buildConfiguration != nil {
+		if sensor.Configuration, err = buildConfiguration(sensor); err != nil {
+			return err
+		}
+		sensor.ConfigVersion = 1
+	}
+	configuration := sensor.Configuration
+	if len(configuration) == 0 {
+		configuration = json.RawMessage(`{}`)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO sensors (id, tenant_id, name, sensor_type, description, platform, version, profile,
+		                     ip_address, network_interfaces, tags, configuration, config_version, status,
+		                     registration_key_id, group_id, registered_at)
+		VALUES ($1, $2, $3, 'network', $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
+		RETURNING id, status, registered_at, created_at, updated_at`,
+		sensor.ID, sensor.TenantID, sensor.Name, nullString(sensor.Description), nullString(sensor.Platform),
+		nullString(sensor.Version), nullString(sensor.Profile), nullString(sensor.IPAddress),
+		pq.Array(sensor.NetworkInterfaces), pq.Array(sensor.Tags), []byte(configuration), sensor.ConfigVersion,
+		models.SensorStatusInactive, sensor.RegistrationKeyID, nullString(sensor.GroupID),
+	).Scan(&sensor.ID, &sensor.Status, &sensor.RegisteredAt, &sensor.CreatedAt, &sensor.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to create sensor: %w", err)
+	}
+
+	if certificate != nil {
+		if err := insertSensorCertificate(tx, certificate); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSensor returns a registered sensor by ID. Deleted sensors are not found.
+func (r *Repository) GetSensor(sensorID string) (*models.RegisteredSensor, error) {
+	row := r.db.QueryRow(`SELECT `+sensorColumns+` FROM sensors WHERE id = $1 AND deleted_at IS NULL`, sensorID)
+	sensor, err := scanSensor(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor: %w", err)
+	}
+	return sensor, nil
+}
+
+// GetTenantSensor returns a registered sensor that belongs to the given tenant
+func (r *Repository) GetTenantSensor(tenantID, sensorID string) (*models.RegisteredSensor, error) {
+	sensor, err := r.GetSensor(sensorID)
+	if err != nil {
+		return nil, err
+	}
+	if sensor.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	return sensor, nil
+}
+
+// ListSensors returns a page of a tenant's sensors and the total number of
+// sensors matching the filters
+func (r *Repository) ListSensors(tenantID string, filters models.SensorFilters) ([]*models.RegisteredSensor, int, error) {
+	conditions := []string{"tenant_id = $1", "deleted_at IS NULL"}
+	args := []interface{}{tenantID}
+
+	if !filters.IncludeDecommissioned {
+		conditions = append(conditions, "decommissioned_at IS NULL")
+	}
+	if filters.Status != "" {
+		args = append(args, filters.Status)
+		conditions = append(conditions, fmt.Sprintf("status::text = $%d", len(args)))
+	}
+	if filters.Profile != "" {
+		args = append(args, filters.Profile)
+		conditions = append(conditions, fmt.Sprintf("profile = $%d", len(args)))
+	}
+	if filters.Tag != "" {
+		args = append(args, filters.Tag)
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(tags)", len(args)))
+	}
+	if filters.GroupID != "" {
+		args = append(args, filters.GroupID)
+		conditions = append(conditions, fmt.Sprintf("group_id::text = $%d", len(args)))
+	}
+	if filters.Search != "" {
+		args = append(args, "%"+filters.Search+"%")
+		conditions = append(conditions, fmt.Sprintf("(name ILIKE $%d OR description ILIKE $%d OR host(ip_address) LIKE $%d)", len(args), len(args), len(args)))
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sensors WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sensors: %w", err)
+	}
+
+	page, pageSize := filters.Page, filters.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := r.db.Query(fmt.Sprintf(`
+		SELECT %s FROM sensors WHERE %s
+		ORDER BY name
+		LIMIT $%d OFFSET $%d`, sensorColumns, where, len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []*models.RegisteredSensor{}
+	for rows.Next() {
+		sensor, err := scanSensor(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan sensor: %w", err)
+		}
+		sensors = append(sensors, sensor)
+	}
+	return sensors, total, rows.Err()
+}
+
+// UpdateSensorDetails changes a sensor's name and/or description. Nil
+// arguments leave the corresponding column unchanged.
+func (r *Repository) UpdateSensorDetails(tenantID, sensorID string, name, description *string) (*models.RegisteredSensor, error) {
+	row := r.db.QueryRow(`
+		UPDATE sensors
+		SET name = COALESCE($3, name), description = COALESCE($4, description)
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		RETURNING `+sensorColumns,
+		sensorID, tenantID, name, description,
+	)
+	return r.scanUpdatedSensor(row)
+}
+
+// SetSensorTags replaces the tags of a sensor
+func (r *Repository) SetSensorTags(tenantID, sensorID string, tags []string) (*models.RegisteredSensor, error) {
+	row := r.db.QueryRow(`
+		UPDATE sensors SET tags = $3
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		RETURNING `+sensorColumns,
+		sensorID, tenantID, pq.Array(tags),
+	)
+	return r.scanUpdatedSensor(row)
+}
+
+// DecommissionSensor retires a sensor. A decommissioned sensor keeps its
+// history but is no longer accepted on the sensor-facing endpoints.
+func (r *Repository) DecommissionSensor(tenantID, sensorID string) (*models.RegisteredSensor, error) {
+	row := r.db.QueryRow(`
+		UPDATE sensors
+		SET status = 'inactive', decommissioned_at = COALESCE(decommissioned_at, NOW())
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		RETURNING `+sensorColumns,
+		sensorID, tenantID,
+	)
+	return r.scanUpdatedSensor(row)
+}
+
+// DeleteSensor soft-deletes a sensor. Discoveries that reference it are kept.
+func (r *Repository) DeleteSensor(tenantID, sensorID string) error {
+	result, err := r.db.Exec(`
+		UPDATE sensors SET deleted_at = NOW(), status = 'inactive'
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`,
+		sensorID, tenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete sensor: %w", err)
+	}
+	return expectRows(result)
+}
+
+// RecordHeartbeat stores the latest health report of an active sensor and
+// refreshes its last heartbeat and, when reported, the software version it
+// runs. ErrNotFound is returned for unknown, deleted or decommissioned
+// sensors.
+func (r *Repository) RecordHeartbeat(sensorID, status, version string, health json.RawMessage) error {
+	result, err := r.db.Exec(`
+		UPDATE sensors
+		SET last_heartbeat_at = NOW(), last_health = $2, status = $3,
+		    version = COALESCE(NULLIF($4, ''), version)
+		WHERE id = $1 AND deleted_at IS NULL AND decommissioned_at IS NULL`,
+		sensorID, []byte(health), status, version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+	return expectRows(result)
+}
+
+// SetSensorConfiguration stores the configuration served to a sensor. When
+// it differs from the stored one (ignoring config_version) the sensor's
+// config_version is bumped and written into the stored document. It returns
+// the sensor's config version and whether it changed.
+func (r *Repository) SetSensorConfiguration(sensorID string, configuration json.RawMessage) (int, bool, error) {
+	var version int
+	err := r.db.QueryRow(`
+		UPDATE sensors
+		SET config_version = config_version + 1,
+		    configuration = $2::jsonb || jsonb_build_object('config_version', config_version + 1)
+		WHERE id = $1 AND deleted_at IS NULL
+		  AND COALESCE(configuration, '{}') - 'config_version' IS DISTINCT FROM $2::jsonb - 'config_version'
+		RETURNING config_version`,
+		sensorID, []byte(configuration),
+	).Scan(&version)
+	if err == nil {
+		return version, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("failed to store sensor configuration: %w", err)
+	}
+
+	// Unchanged, or the sensor does not exist
+	err = r.db.QueryRow(`SELECT config_version FROM sensors WHERE id = $1 AND deleted_at IS NULL`, sensorID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, false, ErrNotFound
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get sensor configuration version: %w", err)
+	}
+	return version, false, nil
+}
+
+// RecordAppliedConfigVersion stores the configuration version a sensor
+// reports as applied
+func (r *Repository) RecordAppliedConfigVersion(sensorID string, version int) error {
+	result, err := r.db.Exec(`
+		UPDATE sensors
+		SET config_applied_at = CASE WHEN applied_config_version IS DISTINCT FROM $2 THEN NOW() ELSE config_applied_at END,
+		    applied_config_version = $2
+		WHERE id = $1 AND deleted_at IS NULL`,
+		sensorID, version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record applied configuration: %w", err)
+	}
+	return expectRows(result)
+}
+
+// SetSensorGroup moves a sensor into a group of the same tenant, or out of
+// any group when groupID is empty
+func (r *Repository) SetSensorGroup(tenantID, sensorID, groupID string) (*models.RegisteredSensor, error) {
+	row := r.db.QueryRow(`
+		UPDATE sensors SET group_id = $3
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		  AND ($3::uuid IS NULL OR EXISTS (SELECT 1 FROM sensor_groups WHERE id = $3 AND tenant_id = $2))
+		RETURNING `+sensorColumns,
+		sensorID, tenantID, nullString(groupID),
+	)
+	return r.scanUpdatedSensor(row)
+}
+
+// SetSensorConfigOverrides replaces a sensor's configuration overrides
+func (r *Repository) SetSensorConfigOverrides(tenantID, sensorID string, overrides models.ConfigSettings) (*models.RegisteredSensor, error) {
+	encoded, err := json.Marshal(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode configuration overrides: %w", err)
+	}
+	row := r.db.QueryRow(`
+		UPDATE sensors SET config_overrides = $3
+		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		RETURNING `+sensorColumns,
+		sensorID, tenantID, encoded,
+	)
+	return r.scanUpdatedSensor(row)
+}
+
+func (r *Repository) scanUpdatedSensor(row *sql.Row) (*models.RegisteredSensor, error) {
+	sensor, err := scanSensor(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, fmt.Errorf("failed to update sensor: %w", err)
+	}
+	return sensor, nil
+}
+
+func scanSensor(row scanner) (*models.RegisteredSensor, error) {
+	var sensor models.RegisteredSensor
+	var overrides, configuration, lastHealth, healthReasons []byte
+	var appliedVersion sql.NullInt64
+	var configAppliedAt, lastHeartbeatAt, healthChangedAt, registeredAt, decommissionedAt sql.NullTime
+	err := row.Scan(
+		&sensor.ID, &sensor.TenantID, &sensor.Name, &sensor.Description, &sensor.Platform, &sensor.Version,
+		&sensor.Profile, &sensor.IPAddress, &sensor.Hostname,
+		pq.Array(&sensor.NetworkInterfaces), pq.Array(&sensor.Tags), &sensor.Status,
+		&sensor.RegistrationKeyID, &sensor.GroupID, &overrides,
+		&configuration, &sensor.ConfigVersion, &appliedVersion, &configAppliedAt,
+		&lastHealth, &lastHeartbeatAt, &sensor.HealthState, &healthReasons, &healthChangedAt, &registeredAt, &decommissionedAt, &sensor.CreatedAt, &sensor.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(overrides, &sensor.ConfigOverrides); err != nil {
+		return nil, fmt.Errorf("invalid configuration overrides: %w", err)
+	}
+	if err := json.Unmarshal(healthReasons, &sensor.HealthReasons); err != nil {
+		return nil, fmt.Errorf("invalid health reasons: %w", err)
+	}
+	if appliedVersion.Valid {
+		version := int(appliedVersion.Int64)
+		sensor.AppliedVersion = &version
+	}
+	sensor.ConfigAppliedAt = nullTime(configAppliedAt)
+	sensor.Configuration = json.RawMessage(configuration)
+	sensor.LastHealth = json.RawMessage(lastHealth)
+	sensor.LastHeartbeatAt = nullTime(lastHeartbeatAt)
+	sensor.HealthChangedAt = nullTime(healthChangedAt)
+	sensor.RegisteredAt = nullTime(registeredAt)
+	sensor.DecommissionedAt = nullTime(decommissionedAt)
+	return &sensor, nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// defaultAdminSettings are used until a tenant administrator saves settings
+var defaultAdminSettings = models.AdminSettings{
+	KeyExpirationMinutes: 60,
+	MaxPendingSensors:    50,
+	RequireIPValidation:  true,
+}
+
+// GetAdminSettings returns the registration settings of a tenant
+func (r *Repository) GetAdminSettings(tenantID string) (models.AdminSettings, error) {
+	var settings models.AdminSettings
+	err := r.db.QueryRow(`
+		SELECT key_expiration_minutes, max_pending_sensors, require_ip_validation
+		FROM sensor_registration_settings WHERE tenant_id = $1`, tenantID,
+	).Scan(&settings.KeyExpirationMinutes, &settings.MaxPendingSensors, &settings.RequireIPValidation)
+	if err == sql.ErrNoRows {
+		return defaultAdminSettings, nil
+	}
+	if err != nil {
+		return models.AdminSettings{}, fmt.Errorf("failed to get admin settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdateAdminSettings stores the registration settings of a tenant
+func (r *Repository) UpdateAdminSettings(tenantID, userID string, settings models.AdminSettings) error {
+	_, err := r.db.Exec(`
+		INSERT INTO sensor_registration_settings (tenant_id, key_expiration_minutes, max_pending_sensors,
+		                                          require_ip_validation, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (tenant_id) DO UPDATE SET
+			key_expiration_minutes = EXCLUDED.key_expiration_minutes,
+			max_pending_sensors = EXCLUDED.max_pending_sensors,
+			require_ip_validation = EXCLUDED.require_ip_validation,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()`,
+		tenantID, settings.KeyExpirationMinutes, settings.MaxPendingSensors, settings.RequireIPValidation,
+		nullString(userID),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update admin settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// topologyRetention is how long topology observations not seen again are
+// kept
+const topologyRetention = "30 days"
+
+// assetSubnet is the SQL expression placing an asset's address in its /24
+// or /64 subnet, the granularity sensors aggregate traffic to
+const assetSubnet = `network(set_masklen(a.ip_address, CASE WHEN family(a.ip_address) = 4 THEN 24 ELSE 64 END))`
+
+// RecordTopology stores a sensor's topology report: observations seen
+// before add up their counters, new ones are inserted. Observations of the
+// sensor not seen for the retention period are removed.
+func (r *Repository) RecordTopology(tenantID, sensorID string, report *models.TopologyReport) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	subnets, err := tx.Prepare(`
+		INSERT INTO sensor_subnets (sensor_id, tenant_id, cidr, vlan_id, source, interface_name, hosts, packets)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (sensor_id, cidr, vlan_id, source) DO UPDATE SET
+			interface_name = EXCLUDED.interface_name,
+			hosts = GREATEST(sensor_subnets.hosts, EXCLUDED.hosts),
+			packets = sensor_subnets.packets + EXCLUDED.packets,
+			last_seen_at = NOW()`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare subnet upsert: %w", err)
+	}
+	defer subnets.Close()
+	for _, subnet := range report.Subnets {
+		if _, err := subnets.Exec(sensorID, tenantID, subnet.CIDR, subnet.VLAN, subnet.Source,
+			nullString(subnet.Interface), subnet.Hosts, subnet.Packets); err != nil {
+			return fmt.Errorf("failed to record subnet %s: %w", subnet.CIDR, err)
+		}
+	}
+
+	vlans, err := tx.Prepare(`
+		INSERT INTO sensor_vlans (sensor_id, tenant_id, vlan_id, source, packets)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (sensor_id, vlan_id, source) DO UPDATE SET
+			packets = sensor_vlans.packets + EXCLUDED.packets,
+			last_seen_at = NOW()`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare VLAN upsert: %w", err)
+	}
+	defer vlans.Close()
+	for _, vlan := range report.VLANs {
+		if _, err := vlans.Exec(sensorID, tenantID, vlan.ID, vlan.Source, vlan.Packets); err != nil {
+			return fmt.Errorf("failed to record VLAN %d: %w", vlan.ID, err)
+		}
+	}
+
+	gateways, err := tx.Prepare(`
+		INSERT INTO sensor_gateways (sensor_id, tenant_id, ip_address, mac_address, vlan_id, source, interface_name, subnets)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (sensor_id, ip_address, mac_address, vlan_id, source) DO UPDATE SET
+			interface_name = EXCLUDED.interface_name,
+			subnets = GREATEST(sensor_gateways.subnets, EXCLUDED.subnets),
+			last_seen_at = NOW()`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare gateway upsert: %w", err)
+	}
+	defer gateways.Close()
+	for _, gateway := range report.Gateways {
+		if _, err := gateways.Exec(sensorID, tenantID, gateway.IP, gateway.MAC, gateway.VLAN, gateway.Source,
+			nullString(gateway.Interface), gateway.Subnets); err != nil {
+			return fmt.Errorf("failed to record gateway: %w", err)
+		}
+	}
+
+	flows, err := tx.Prepare(`
+		INSERT INTO sensor_flows (sensor_id, tenant_id, src_cidr, dst_cidr, protocol, port, vlan_id, packets)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (sensor_id, src_cidr, dst_cidr, protocol, port, vlan_id) DO UPDATE SET
+			packets = sensor_flows.packets + EXCLUDED.packets,
+			last_seen_at = NOW()`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare flow upsert: %w", err)
+	}
+	defer flows.Close()
+	for _, flow := range report.Flows {
+		if _, err := flows.Exec(sensorID, tenantID, flow.SrcCIDR, flow.DstCIDR, flow.Protocol, flow.Port,
+			flow.VLAN, flow.Packets); err != nil {
+			return fmt.Errorf("failed to record flow: %w", err)
+		}
+	}
+
+	for _, table := range []string{"sensor_subnets", "sensor_vlans", "sensor_gateways", "sensor_flows"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE sensor_id = $1 AND last_seen_at < NOW() - INTERVAL '`+topologyRetention+`'`, sensorID); err != nil {
+			return fmt.Errorf("failed to purge stale topology: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit topology: %w", err)
+	}
+	return nil
+}
+
+// ListSensorSubnets returns the subnets the tenant's sensors observed since
+// the filter's time, optionally of one sensor
+func (r *Repository) ListSensorSubnets(tenantID string, filters models.TopologyFilters) ([]*models.SensorSubnet, error) {
+	rows, err := r.db.Query(`
+		SELECT ss.sensor_id, s.name, ss.cidr::text, ss.vlan_id, ss.source, ss.interface_name,
+			ss.hosts, ss.packets, ss.first_seen_at, ss.last_seen_at
+		FROM sensor_subnets ss
+		JOIN sensors s ON s.id = ss.sensor_id AND s.deleted_at IS NULL
+		WHERE ss.tenant_id = $1 AND ss.last_seen_at >= $2 AND ($3 = '' OR ss.sensor_id::text = $3)
+		ORDER BY ss.cidr, ss.vlan_id, s.name`,
+		tenantID, filters.Since, filters.SensorID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor subnets: %w", err)
+	}
+	defer rows.Close()
+
+	subnets := []*models.SensorSubnet{}
+	for rows.Next() {
+		var subnet models.SensorSubnet
+		var iface sql.NullString
+		if err := rows.Scan(&subnet.SensorID, &subnet.SensorName, &subnet.CIDR, &subnet.VLAN, &subnet.Source, &iface,
+			&subnet.Hosts, &subnet.Packets, &subnet.FirstSeenAt, &subnet.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor subnet: %w", err)
+		}
+		subnet.Interface = iface.String
+		subnets = append(subnets, &subnet)
+	}
+	return subnets, rows.Err()
+}
+
+// ListSensorGateways returns the gateways the tenant's sensors observed
+// since the filter's time, optionally of one sensor
+func (r *Repository) ListSensorGateways(tenantID string, filters models.TopologyFilters) ([]*models.SensorGateway, error) {
+	rows, err := r.db.Query(`
+		SELECT sg.sensor_id, s.name, sg.ip_address, sg.mac_address, sg.vlan_id, sg.source, sg.interface_name,
+			sg.subnets, sg.last_seen_at
+		FROM sensor_gateways sg
+		JOIN sensors s ON s.id = sg.sensor_id AND s.deleted_at IS NULL
+		WHERE sg.tenant_id = $1 AND sg.last_seen_at >= $2 AND ($3 = '' OR sg.sensor_id::text = $3)
+		ORDER BY sg.ip_address, sg.mac_address`,
+		tenantID, filters.Since, filters.SensorID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor gateways: %w", err)
+	}
+	defer rows.Close()
+
+	gateways := []*models.SensorGateway{}
+	for rows.Next() {
+		var gateway models.SensorGateway
+		var iface sql.NullString
+		if err := rows.Scan(&gateway.SensorID, &gateway.SensorName, &gateway.IP, &gateway.MAC, &gateway.VLAN, &gateway.Source, &iface,
+			&gateway.Subnets, &gateway.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor gateway: %w", err)
+		}
+		gateway.Interface = iface.String
+		gateways = append(gateways, &gateway)
+	}
+	return gateways, rows.Err()
+}
+
+// ListSensorFlows returns the flows the tenant's sensors observed since the
+// filter's time, optionally of one sensor
+func (r *Repository) ListSensorFlows(tenantID string, filters models.TopologyFilters) ([]*models.SensorFlow, error) {
+	rows, err := r.db.Query(`
+		SELECT sf.sensor_id, sf.src_cidr::text, sf.dst_cidr::text, sf.protocol, sf.port, sf.vlan_id,
+			sf.packets, sf.last_seen_at
+		FROM sensor_flows sf
+		JOIN sensors s ON s.id = sf.sensor_id AND s.deleted_at IS NULL
+		WHERE sf.tenant_id = $1 AND sf.last_seen_at >= $2 AND ($3 = '' OR sf.sensor_id::text = $3)
+		ORDER BY sf.src_cidr, sf.dst_cidr, sf.port`,
+		tenantID, filters.Since, filters.SensorID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor flows: %w", err)
+	}
+	defer rows.Close()
+
+	flows := []*models.SensorFlow{}
+	for rows.Next() {
+		var flow models.SensorFlow
+		if err := rows.Scan(&flow.SensorID, &flow.SrcCIDR, &flow.DstCIDR, &flow.Protocol, &flow.Port, &flow.VLAN,
+			&flow.Packets, &flow.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor flow: %w", err)
+		}
+		flows = append(flows, &flow)
+	}
+	return flows, rows.Err()
+}
+
+// ListAssetSubnets returns the /24 and /64 subnets holding the tenant's
+// network assets, with the number of assets in each
+func (r *Repository) ListAssetSubnets(tenantID string) ([]models.AssetSubnet, error) {
+	rows, err := r.db.Query(`
+		SELECT `+assetSubnet+`::text AS cidr, COUNT(*)
+		FROM network_assets a
+		WHERE a.tenant_id = $1 AND a.deleted_at IS NULL AND a.ip_address IS NOT NULL
+		GROUP BY 1
+		ORDER BY 1`,
+		tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list asset subnets: %w", err)
+	}
+	defer rows.Close()
+
+	subnets := []models.AssetSubnet{}
+	for rows.Next() {
+		var subnet models.AssetSubnet
+		if err := rows.Scan(&subnet.CIDR, &subnet.Assets); err != nil {
+			return nil, fmt.Errorf("failed to scan asset subnet: %w", err)
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, rows.Err()
+}
+
+// ListTopologyAssets returns up to limit of the tenant's network assets with
+// an address, and whether there are more
+func (r *Repository) ListTopologyAssets(tenantID string, limit int) ([]models.TopologyAsset, bool, error) {
+	rows, err := r.db.Query(`
+		SELECT a.id, a.hostname, host(a.ip_address), `+assetSubnet+`::text
+		FROM network_assets a
+		WHERE a.tenant_id = $1 AND a.deleted_at IS NULL AND a.ip_address IS NOT NULL
+		ORDER BY a.ip_address, a.id
+		LIMIT $2`,
+		tenantID, limit+1,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list topology assets: %w", err)
+	}
+	defer rows.Close()
+
+	assets := []models.TopologyAsset{}
+	for rows.Next() {
+		var asset models.TopologyAsset
+		var hostname sql.NullString
+		if err := rows.Scan(&asset.ID, &hostname, &asset.IP, &asset.Subnet); err != nil {
+			return nil, false, fmt.Errorf("failed to scan topology asset: %w", err)
+		}
+		asset.Hostname = hostname.String
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(assets) > limit {
+		return assets[:limit], true, nil
+	}
+	return assets, false, nil
+}
@@ -0,0 +1,516 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/lib/pq"
+)
+
+const webhookEndpointColumns = `
+	id, tenant_id, COALESCE(description, ''), COALESCE(sensor_id::text, ''), enabled, webhook_url,
+	COALESCE(secret, ''), events, retry_count, timeout_seconds, COALESCE(created_by::text, ''),
+	created_at, updated_at`
+
+const webhookDeliveryColumns = `
+	d.id, d.tenant_id, d.endpoint_id, d.event_id, d.event_type, d.status, d.attempts, d.max_attempts,
+	d.next_attempt_at, COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), d.created_at,
+	d.updated_at, d.delivered_at, d.dead_lettered_at`
+
+// ClaimedDelivery is a delivery claimed for an attempt, together with the
+// event and the endpoint it goes to
+type ClaimedDelivery struct {
+	Delivery *models.WebhookDelivery
+	Event    *models.WebhookEvent
+	Endpoint *models.WebhookEndpoint
+}
+
+// CreateWebhookEndpoint stores a new tenant webhook
+func (r *Repository) CreateWebhookEndpoint(endpoint *models.WebhookEndpoint) error {
+	row := r.db.QueryRow(`
+		INSERT INTO webhook_endpoints (tenant_id, description, sensor_id, enabled, webhook_url, secret, events,
+		                               retry_count, timeout_seconds, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+webhookEndpointColumns,
+		endpoint.TenantID, nullString(endpoint.Description), nullString(endpoint.SensorID), endpoint.Enabled,
+		endpoint.WebhookURL, nullString(endpoint.Secret), pq.Array(nonNil(endpoint.Events)), endpoint.RetryCount,
+		endpoint.Timeout, nullString(endpoint.CreatedBy),
+	)
+	created, err := scanWebhookEndpoint(row)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	*endpoint = *created
+	return nil
+}
+
+// GetWebhookEndpoint returns a tenant's webhook
+func (r *Repository) GetWebhookEndpoint(tenantID, endpointID string) (*models.WebhookEndpoint, error) {
+	row := r.db.QueryRow(`SELECT `+webhookEndpointColumns+` FROM webhook_endpoints WHERE id = $1 AND tenant_id = $2`,
+		endpointID, tenantID)
+	endpoint, err := scanWebhookEndpoint(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return endpoint, nil
+}
+
+// ListWebhookEndpoints returns a tenant's webhooks
+func (r *Repository) ListWebhookEndpoints(tenantID string) ([]*models.WebhookEndpoint, error) {
+	return r.queryWebhookEndpoints(`
+		SELECT `+webhookEndpointColumns+` FROM webhook_endpoints
+		WHERE tenant_id = $1
+		ORDER BY created_at`,
+		tenantID,
+	)
+}
+
+// ListSensorWebhookEndpoints returns the enabled webhooks of a tenant that
+// receive events about the given sensor
+func (r *Repository) ListSensorWebhookEndpoints(tenantID, sensorID string) ([]*models.WebhookEndpoint, error) {
+	return r.queryWebhookEndpoints(`
+		SELECT `+webhookEndpointColumns+` FROM webhook_endpoints
+		WHERE tenant_id = $1 AND enabled AND (sensor_id IS NULL OR sensor_id::text = $2)
+		ORDER BY created_at`,
+		tenantID, sensorID,
+	)
+}
+
+// UpdateWebhookEndpoint replaces a webhook's settings. An empty secret
+// keeps the stored one.
+func (r *Repository) UpdateWebhookEndpoint(endpoint *models.WebhookEndpoint) error {
+	row := r.db.QueryRow(`
+		UPDATE webhook_endpoints
+		SET description = $3, sensor_id = $4, enabled = $5, webhook_url = $6, secret = COALESCE($7, secret),
+		    events = $8, retry_count = $9, timeout_seconds = $10
+		WHERE id = $1 AND tenant_id = $2
+		RETURNING `+webhookEndpointColumns,
+		endpoint.ID, endpoint.TenantID, nullString(endpoint.Description), nullString(endpoint.SensorID),
+		endpoint.Enabled, endpoint.WebhookURL, nullString(endpoint.Secret), pq.Array(nonNil(endpoint.Events)),
+		endpoint.RetryCount, endpoint.Timeout,
+	)
+	updated, err := scanWebhookEndpoint(row)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	*endpoint = *updated
+	return nil
+}
+
+// DeleteWebhookEndpoint removes a tenant's webhook with its deliveries
+func (r *Repository) DeleteWebhookEndpoint(tenantID, endpointID string) error {
+	result, err := r.db.Exec(`DELETE FROM webhook_endpoints WHERE id = $1 AND tenant_id = $2`, endpointID, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return expectRows(result)
+}
+
+// PublishWebhookEvent stores an event for delivery to the tenant's
+// subscribed webhooks. sensorID is the sensor the event concerns, if any.
+func (r *Repository) PublishWebhookEvent(tenantID, sensorID, eventType string, data interface{}) (string, error) {
+	return publishWebhookEvent(r.db, tenantID, sensorID, eventType, data)
+}
+
+// DispatchWebhookEvents creates the deliveries of up to limit published
+// events, one per enabled endpoint that subscribes to the event: endpoints
+// without events subscribe to all, and endpoints limited to a sensor only
+// receive events about it. It returns the number of events dispatched.
+func (r *Repository) DispatchWebhookEvents(limit int) (int, error) {
+	var dispatched int
+	err := r.db.QueryRow(`
+		WITH claimed AS (
+			SELECT id FROM webhook_events
+			WHERE dispatched_at IS NULL
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), fanned_out AS (
+			INSERT INTO webhook_deliveries (tenant_id, endpoint_id, event_id, event_type, max_attempts)
+			SELECT e.tenant_id, w.id, e.id, e.event_type, w.retry_count + 1
+			FROM webhook_events e
+			JOIN claimed c ON c.id = e.id
+			JOIN webhook_endpoints w ON w.tenant_id = e.tenant_id AND w.enabled
+			 AND (w.sensor_id IS NULL OR w.sensor_id = e.sensor_id)
+			 AND (w.events = '{}' OR e.event_type = ANY(w.events))
+			ON CONFLICT (endpoint_id, event_id) DO NOTHING
+		), dispatched AS (
+			UPDATE webhook_events SET dispatched_at = NOW()
+			WHERE id IN (SELECT id FROM claimed)
+			RETURNING 1
+		)
+		SELECT COUNT(*) FROM dispatched`,
+		limit,
+	).Scan(&dispatched)
+	if err != nil {
+		return 0, fmt.Errorf("failed to dispatch webhook events: %w", err)
+	}
+	return dispatched, nil
+}
+
+// ClaimWebhookDeliveries claims up to limit deliveries that are due for an
+// attempt, oldest first, and counts the attempt. Deliveries left delivering
+// past their lock by a crashed dispatcher are claimed again. Rows are
+// claimed with SKIP LOCKED, so concurrent dispatchers never attempt the
+// same delivery.
+func (r *Repository) ClaimWebhookDeliveries(limit int, lock time.Duration) ([]*ClaimedDelivery, error) {
+	rows, err := r.db.Query(`
+		WITH claimable AS (
+			SELECT id AS claimable_id FROM webhook_deliveries
+			WHERE (status IN ('pending', 'retrying') AND next_attempt_at <= NOW())
+			   OR (status = 'delivering' AND locked_until < NOW())
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET status = 'delivering', attempts = d.attempts + 1, locked_until = NOW() + $2 * INTERVAL '1 second'
+		FROM claimable, webhook_events e, webhook_endpoints w
+		WHERE d.id = claimable_id AND e.id = d.event_id AND w.id = d.endpoint_id
+		RETURNING `+webhookDeliveryColumns+`,
+		          e.tenant_id, COALESCE(e.sensor_id::text, ''), e.payload, e.created_at,
+		          w.enabled, w.webhook_url, COALESCE(w.secret, ''), w.timeout_seconds`,
+		limit, int(lock/time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	claimed := []*ClaimedDelivery{}
+	for rows.Next() {
+		item, err := scanClaimedDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		claimed = append(claimed, item)
+	}
+	return claimed, rows.Err()
+}
+
+// CreateTestDelivery publishes a webhook.test event to a single endpoint
+// and returns its delivery, already claimed for its only attempt
+func (r *Repository) CreateTestDelivery(endpoint *models.WebhookEndpoint, data interface{}, lock time.Duration) (*ClaimedDelivery, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	eventID, err := publishWebhookEvent(tx, endpoint.TenantID, "", models.EventWebhookTest, data)
+	if err != nil {
+		return nil, err
+	}
+
+	row := tx.QueryRow(`
+		WITH marked AS (
+			UPDATE webhook_events SET dispatched_at = NOW() WHERE id = $2
+			RETURNING id, tenant_id, sensor_id, payload, created_at
+		), d AS (
+			INSERT INTO webhook_deliveries (tenant_id, endpoint_id, event_id, event_type, status, attempts,
+			                                max_attempts, locked_until)
+			SELECT tenant_id, $1, id, '`+models.EventWebhookTest+`', 'delivering', 1, 1, NOW() + $3 * INTERVAL '1 second'
+			FROM marked
+			RETURNING *
+		)
+		SELECT `+webhookDeliveryColumns+`,
+		       e.tenant_id, COALESCE(e.sensor_id::text, ''), e.payload, e.created_at,
+		       w.enabled, w.webhook_url, COALESCE(w.secret, ''), w.timeout_seconds
+		FROM d, marked e, webhook_endpoints w
+		WHERE w.id = d.endpoint_id`,
+		endpoint.ID, eventID, int(lock/time.Second),
+	)
+	claimed, err := scanClaimedDelivery(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create test delivery: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit test delivery: %w", err)
+	}
+	return claimed, nil
+}
+
+// FinishWebhookAttempt records an attempt of a claimed delivery and stores
+// the delivery's resulting status, next attempt and last result. The
+// delivery is only updated while it is still claimed for this attempt, so a
+// late result never overwrites a newer attempt's.
+func (r *Repository) FinishWebhookAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $3, next_attempt_at = COALESCE($4, next_attempt_at), locked_until = NULL,
+		    last_status_code = $5, last_error = $6,
+		    delivered_at = CASE WHEN $3 = 'succeeded' THEN NOW() ELSE delivered_at END,
+		    dead_lettered_at = CASE WHEN $3 = 'dead_letter' THEN NOW() ELSE NULL END
+		WHERE id = $1 AND status = 'delivering' AND attempts = $2`,
+		delivery.ID, attempt.Attempt, delivery.Status, delivery.NextAttemptAt,
+		nullInt(delivery.LastStatusCode), nullString(delivery.LastError),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	if err := expectRows(result); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5)`,
+		delivery.ID, attempt.Attempt, nullInt(attempt.StatusCode), nullString(attempt.Error), attempt.DurationMS,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RedeliverWebhookDelivery queues a finished delivery (dead-lettered or
+// succeeded) for delivery again, with as many attempts as its endpoint
+// allows. ErrInvalidTransition is returned for deliveries still in
+// progress.
+func (r *Repository) RedeliverWebhookDelivery(tenantID, deliveryID string) (*models.WebhookDelivery, error) {
+	row := r.db.QueryRow(`
+		UPDATE webhook_deliveries d
+		SET status = 'pending', next_attempt_at = NOW(), locked_until = NULL, dead_lettered_at = NULL,
+		    max_attempts = d.attempts + w.retry_count + 1
+		FROM webhook_endpoints w
+		WHERE d.id = $1 AND d.tenant_id = $2 AND w.id = d.endpoint_id
+		  AND d.status IN ('succeeded', 'dead_letter')
+		RETURNING `+webhookDeliveryColumns,
+		deliveryID, tenantID,
+	)
+	delivery, err := scanWebhookDelivery(row)
+	if err == sql.ErrNoRows {
+		if _, err := r.GetWebhookDelivery(tenantID, deliveryID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTransition
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// GetWebhookDelivery returns a tenant's delivery with its attempts
+func (r *Repository) GetWebhookDelivery(tenantID, deliveryID string) (*models.WebhookDelivery, error) {
+	row := r.db.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d WHERE d.id = $1 AND d.tenant_id = $2`,
+		deliveryID, tenantID)
+	delivery, err := scanWebhookDelivery(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT attempt, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY attempted_at`,
+		deliveryID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook attempts: %w", err)
+	}
+	defer rows.Close()
+
+	delivery.AttemptLog = []*models.WebhookDeliveryAttempt{}
+	for rows.Next() {
+		var attempt models.WebhookDeliveryAttempt
+		if err := rows.Scan(&attempt.Attempt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMS,
+			&attempt.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %w", err)
+		}
+		delivery.AttemptLog = append(delivery.AttemptLog, &attempt)
+	}
+	return delivery, rows.Err()
+}
+
+// GetWebhookEventPayload returns the payload of a tenant's event
+func (r *Repository) GetWebhookEventPayload(tenantID, eventID string) (json.RawMessage, error) {
+	var payload []byte
+	err := r.db.QueryRow(`SELECT payload FROM webhook_events WHERE id = $1 AND tenant_id = $2`, eventID, tenantID).Scan(&payload)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook event: %w", err)
+	}
+	return json.RawMessage(payload), nil
+}
+
+// ListWebhookDeliveries returns a page of a tenant's deliveries, newest
+// first, and the total number matching the filters
+func (r *Repository) ListWebhookDeliveries(tenantID string, filters models.WebhookDeliveryFilters) ([]*models.WebhookDelivery, int, error) {
+	where := `WHERE d.tenant_id = $1
+		AND ($2 = '' OR d.endpoint_id::text = $2)
+		AND ($3 = '' OR d.status = $3)
+		AND ($4 = '' OR d.event_type = $4)`
+	args := []interface{}{tenantID, filters.EndpointID, filters.Status, filters.EventType}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries d `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	page, pageSize := filters.Page, filters.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := r.db.Query(`
+		SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d `+where+`
+		ORDER BY d.created_at DESC
+		LIMIT $5 OFFSET $6`,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// PurgeWebhookEvents deletes events published before the given time, with
+// their deliveries, once none of the deliveries is still in progress.
+// Dead letters are kept until deadLetterBefore.
+func (r *Repository) PurgeWebhookEvents(before, deadLetterBefore time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM webhook_events e
+		WHERE e.dispatched_at IS NOT NULL AND e.created_at < $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM webhook_deliveries d
+			WHERE d.event_id = e.id
+			  AND (d.status IN ('pending', 'delivering', 'retrying')
+			       OR (d.status = 'dead_letter' AND d.dead_lettered_at >= $2))
+		  )`,
+		before, deadLetterBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge webhook events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *Repository) queryWebhookEndpoints(query string, args ...interface{}) ([]*models.WebhookEndpoint, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := []*models.WebhookEndpoint{}
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+// rowQuerier is implemented by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// publishWebhookEvent inserts an event into the webhook outbox, inside the
+// caller's transaction when given one
+func publishWebhookEvent(db rowQuerier, tenantID, sensorID, eventType string, data interface{}) (string, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	var eventID string
+	err = db.QueryRow(`
+		INSERT INTO webhook_events (tenant_id, event_type, sensor_id, payload)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`,
+		tenantID, eventType, nullString(sensorID), payload,
+	).Scan(&eventID)
+	if err != nil {
+		return "", fmt.Errorf("failed to publish %s event: %w", eventType, err)
+	}
+	return eventID, nil
+}
+
+func scanWebhookEndpoint(row scanner) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := row.Scan(&endpoint.ID, &endpoint.TenantID, &endpoint.Description, &endpoint.SensorID, &endpoint.Enabled,
+		&endpoint.WebhookURL, &endpoint.Secret, pq.Array(&endpoint.Events), &endpoint.RetryCount, &endpoint.Timeout,
+		&endpoint.CreatedBy, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.HasSecret = endpoint.Secret != ""
+	return &endpoint, nil
+}
+
+func scanWebhookDelivery(row scanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var nextAttemptAt, deliveredAt, deadLetteredAt sql.NullTime
+	dest := []interface{}{
+		&delivery.ID, &delivery.TenantID, &delivery.EndpointID, &delivery.EventID, &delivery.EventType,
+		&delivery.Status, &delivery.Attempts, &delivery.MaxAttempts, &nextAttemptAt, &delivery.LastStatusCode,
+		&delivery.LastError, &delivery.CreatedAt, &delivery.UpdatedAt, &deliveredAt, &deadLetteredAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	delivery.NextAttemptAt = nullTime(nextAttemptAt)
+	delivery.DeliveredAt = nullTime(deliveredAt)
+	delivery.DeadLetteredAt = nullTime(deadLetteredAt)
+	return &delivery, nil
+}
+
+func scanClaimedDelivery(row scanner) (*ClaimedDelivery, error) {
+	event := &models.WebhookEvent{}
+	endpoint := &models.WebhookEndpoint{}
+	var payload []byte
+	delivery, err := scanWebhookDelivery(row,
+		&event.TenantID, &event.SensorID, &payload, &event.CreatedAt,
+		&endpoint.Enabled, &endpoint.WebhookURL, &endpoint.Secret, &endpoint.Timeout,
+	)
+	if err != nil {
+		return nil, err
+	}
+	event.ID = delivery.EventID
+	event.Type = delivery.EventType
+	event.Data = json.RawMessage(payload)
+	endpoint.ID = delivery.EndpointID
+	endpoint.TenantID = delivery.TenantID
+	return &ClaimedDelivery{Delivery: delivery, Event: event, Endpoint: endpoint}, nil
+}
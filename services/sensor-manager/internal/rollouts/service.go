@@ -0,0 +1,370 @@
+// Package rollouts upgrades a tenant's sensors to a release in stages. The
+// sensors of each stage are resolved when the rollout is created. A
+// background loop sends upgrade commands to the sensors of the current
+// stage, settles them from the command results and the versions sensors
+// report, and moves on to the next stage once every sensor of the stage
+// finished and the soak time passed. A rollout pauses by itself when more
+// of its sensors failed to upgrade than it allows, and stays paused until
+// an operator resumes or cancels it.
+package rollouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/commands"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/webhooks"
+)
+
+// CommandType is the command that upgrades a sensor. It is only issued by
+// rollouts.
+const CommandType = "upgrade"
+
+const (
+	// pollInterval is how often running rollouts are advanced when nothing
+	// wakes the service earlier
+	pollInterval = 15 * time.Second
+	// commandTTL bounds how long a sensor may take to receive, install and
+	// confirm an upgrade
+	commandTTL = 24 * time.Hour
+	// maxStages bounds the stages of a rollout
+	maxStages = 20
+)
+
+var (
+	// ErrInvalidRollout is returned for rollout requests that cannot be
+	// carried out; the wrapping error says why
+	ErrInvalidRollout = errors.New("invalid rollout")
+	// ErrReleaseYanked is returned when rolling out a yanked release
+	ErrReleaseYanked = errors.New("release was yanked")
+)
+
+// Request describes a rollout to create
+type Request struct {
+	ReleaseID   string
+	Stages      []models.RolloutStage
+	MaxFailures int
+	SoakSeconds int
+	CreatedBy   string
+}
+
+// Service creates and advances rollouts
+type Service struct {
+	repo     *repository.Repository
+	commands *commands.Service
+	webhooks *webhooks.Dispatcher
+	wake     chan struct{}
+}
+
+// NewService creates a new rollout service
+func NewService(repo *repository.Repository, commandService *commands.Service, dispatcher *webhooks.Dispatcher) *Service {
+	return &Service{
+		repo:     repo,
+		commands: commandService,
+		webhooks: dispatcher,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Create resolves the sensors of every stage and starts the rollout. A
+// sensor listed in several stages is upgraded in the first of them.
+func (s *Service) Create(tenantID string, req Request) (*models.SensorRollout, error) {
+	if len(req.Stages) == 0 || len(req.Stages) > maxStages {
+		return nil, fmt.Errorf("%w: between 1 and %d stages are required", ErrInvalidRollout, maxStages)
+	}
+	if req.MaxFailures < 0 || req.SoakSeconds < 0 {
+		return nil, fmt.Errorf("%w: max_failures and soak_seconds must not be negative", ErrInvalidRollout)
+	}
+
+	release, err := s.repo.GetSensorRelease(req.ReleaseID)
+	if err != nil {
+		return nil, err
+	}
+	if release.YankedAt != nil {
+		return nil, ErrReleaseYanked
+	}
+	if len(release.Artifacts) == 0 {
+		return nil, fmt.Errorf("%w: release %s has no binaries", ErrInvalidRollout, release.Version)
+	}
+
+	seen := map[string]bool{}
+	stageTargets := make([][]string, len(req.Stages))
+	total := 0
+	for i, stage := range req.Stages {
+		ids, err := s.resolveStage(tenantID, i, stage)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				stageTargets[i] = append(stageTargets[i], id)
+			}
+		}
+		total += len(stageTargets[i])
+	}
+	if total == 0 {
+		return nil, commands.ErrNoTargets
+	}
+
+	rollout := &models.SensorRollout{
+		TenantID:    tenantID,
+		ReleaseID:   release.ID,
+		Version:     release.Version,
+		Stages:      req.Stages,
+		MaxFailures: req.MaxFailures,
+		SoakSeconds: req.SoakSeconds,
+		CreatedBy:   req.CreatedBy,
+	}
+	if err := s.repo.CreateSensorRollout(rollout, stageTargets); err != nil {
+		return nil, err
+	}
+	log.Printf("⬆️ Rollout %s of version %s created for %d sensors in %d stages",
+		rollout.ID, release.Version, total, len(req.Stages))
+
+	s.Notify()
+	return s.repo.GetSensorRollout(tenantID, rollout.ID)
+}
+
+// resolveStage returns the active sensors a stage selects
+func (s *Service) resolveStage(tenantID string, index int, stage models.RolloutStage) ([]string, error) {
+	selectors := 0
+	if stage.GroupID != "" {
+		selectors++
+	}
+	if len(stage.SensorIDs) > 0 {
+		selectors++
+	}
+	if stage.AllRemaining {
+		selectors++
+	}
+	if selectors != 1 {
+		return nil, fmt.Errorf("%w: stage %d must set exactly one of group_id, sensor_ids and all_remaining",
+			ErrInvalidRollout, index+1)
+	}
+
+	switch {
+	case stage.GroupID != "":
+		if _, err := s.repo.GetSensorGroup(tenantID, stage.GroupID); err != nil {
+			if err == repository.ErrNotFound {
+				return nil, fmt.Errorf("%w: stage %d: sensor group not found", ErrInvalidRollout, index+1)
+			}
+			return nil, err
+		}
+		return s.repo.ListGroupSensorIDs(stage.GroupID)
+	case len(stage.SensorIDs) > 0:
+		ids, err := s.repo.ListCommandTargets(tenantID, stage.SensorIDs, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) != len(stage.SensorIDs) {
+			return nil, fmt.Errorf("%w: stage %d lists unknown or inactive sensors", ErrInvalidRollout, index+1)
+		}
+		return ids, nil
+	default:
+		return s.repo.ListCommandTargets(tenantID, nil, "")
+	}
+}
+
+// Pause pauses a running rollout; sensors being upgraded finish their
+// upgrade
+func (s *Service) Pause(tenantID, rolloutID, reason string) (*models.SensorRollout, error) {
+	if reason == "" {
+		reason = "paused by operator"
+	}
+	if err := s.repo.PauseSensorRollout(tenantID, rolloutID, reason); err != nil {
+		return nil, err
+	}
+	return s.repo.GetSensorRollout(tenantID, rolloutID)
+}
+
+// Resume resumes a paused rollout, retrying or skipping the sensors that
+// failed to upgrade
+func (s *Service) Resume(tenantID, rolloutID string, retryFailed bool) (*models.SensorRollout, error) {
+	if err := s.repo.ResumeSensorRollout(tenantID, rolloutID, retryFailed); err != nil {
+		return nil, err
+	}
+	s.Notify()
+	return s.repo.GetSensorRollout(tenantID, rolloutID)
+}
+
+// Cancel ends a rollout. Upgrade commands not yet delivered are withdrawn;
+// sensors already installing the release finish their upgrade.
+func (s *Service) Cancel(tenantID, rolloutID string) (*models.SensorRollout, error) {
+	upgrading, err := s.repo.CancelSensorRollout(tenantID, rolloutID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sensorID := range upgrading {
+		if _, err := s.repo.SupersedeCommands(sensorID, CommandType, "rollout cancelled"); err != nil {
+			log.Printf("❌ Failed to withdraw upgrade command of sensor %s: %v", sensorID, err)
+		}
+	}
+	return s.repo.GetSensorRollout(tenantID, rolloutID)
+}
+
+// Notify wakes the service, e.g. after a rollout was created or resumed
+func (s *Service) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run advances running rollouts until ctx is cancelled
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.advanceAll(); err != nil {
+			log.Printf("❌ Rollout processing failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// advanceAll settles finished upgrades and advances every running rollout
+func (s *Service) advanceAll() error {
+	if _, err := s.repo.SyncRolloutTargets(); err != nil {
+		return err
+	}
+
+	rollouts, err := s.repo.ListRunningRollouts()
+	if err != nil {
+		return err
+	}
+	for _, rollout := range rollouts {
+		if err := s.advance(rollout); err != nil {
+			log.Printf("❌ Failed to advance rollout %s: %v", rollout.ID, err)
+		}
+	}
+	return nil
+}
+
+// advance takes one step of a running rollout: pause it on too many
+// failures, start the upgrades of the current stage, or move on once the
+// stage finished and soaked
+func (s *Service) advance(rollout *models.SensorRollout) error {
+	stage := rollout.CurrentStage
+	counts, failed, err := s.repo.RolloutStageCounts(rollout.ID, stage)
+	if err != nil {
+		return err
+	}
+
+	if failed > rollout.MaxFailures {
+		return s.pauseOnFailures(rollout, failed)
+	}
+
+	if counts[models.RolloutTargetPending] > 0 {
+		return s.startStage(rollout, stage)
+	}
+	if counts[models.RolloutTargetUpgrading] > 0 {
+		return nil
+	}
+
+	last := stage >= len(rollout.Stages)-1
+	if !last && rollout.SoakSeconds > 0 {
+		if rollout.StageCompletedAt == nil {
+			return s.repo.CompleteRolloutStage(rollout.ID, stage)
+		}
+		if time.Since(*rollout.StageCompletedAt) < time.Duration(rollout.SoakSeconds)*time.Second {
+			return nil
+		}
+	}
+
+	advanced, err := s.repo.AdvanceRollout(rollout.ID, stage, last)
+	if err != nil || !advanced {
+		return err
+	}
+	if last {
+		log.Printf("✅ Rollout %s of version %s completed", rollout.ID, rollout.Version)
+	} else {
+		log.Printf("⬆️ Rollout %s advanced to stage %d", rollout.ID, stage+2)
+		s.Notify()
+	}
+	return nil
+}
+
+// startStage sends upgrade commands to the pending sensors of a stage
+func (s *Service) startStage(rollout *models.SensorRollout, stage int) error {
+	release, err := s.repo.GetSensorRelease(rollout.ReleaseID)
+	if err != nil {
+		return err
+	}
+	sensorIDs, err := s.repo.ClaimRolloutTargets(rollout.ID, stage)
+	if err != nil || len(sensorIDs) == 0 {
+		return err
+	}
+
+	artifacts := map[string]interface{}{}
+	for _, artifact := range release.Artifacts {
+		artifacts[artifact.Platform] = map[string]interface{}{
+			"sha256":    artifact.SHA256,
+			"size":      artifact.Size,
+			"signature": artifact.Signature,
+		}
+	}
+
+	records, err := s.commands.Enqueue(rollout.TenantID, sensorIDs, commands.Spec{
+		Type: CommandType,
+		Payload: map[string]interface{}{
+			"release_id": release.ID,
+			"version":    release.Version,
+			"artifacts":  artifacts,
+			"rollout_id": rollout.ID,
+		},
+		// Acknowledged before the download starts, so a slow download is
+		// not mistaken for a lost delivery
+		RequiresAck: true,
+		TTL:         commandTTL,
+		CreatedBy:   "rollout:" + rollout.ID,
+	})
+	if err != nil {
+		if failErr := s.repo.FailRolloutTargets(rollout.ID, sensorIDs, "failed to send upgrade command"); failErr != nil {
+			log.Printf("❌ Failed to record rollout failures: %v", failErr)
+		}
+		return err
+	}
+
+	for _, record := range records {
+		if err := s.repo.SetRolloutTargetCommand(rollout.ID, record.SensorID, record.ID); err != nil {
+			log.Printf("❌ Failed to record upgrade command of sensor %s: %v", record.SensorID, err)
+		}
+	}
+	log.Printf("⬆️ Rollout %s stage %d: upgrading %d sensors to %s", rollout.ID, stage+1, len(records), release.Version)
+	return nil
+}
+
+// pauseOnFailures pauses a rollout that exceeded its failure limit and
+// alerts the tenant
+func (s *Service) pauseOnFailures(rollout *models.SensorRollout, failed int) error {
+	reason := fmt.Sprintf("%d sensors failed to upgrade, more than the %d allowed", failed, rollout.MaxFailures)
+	if err := s.repo.PauseSensorRollout(rollout.TenantID, rollout.ID, reason); err != nil {
+		if err == repository.ErrInvalidTransition {
+			// Paused or cancelled in the meantime
+			return nil
+		}
+		return err
+	}
+	log.Printf("⏸️ Rollout %s paused: %s", rollout.ID, reason)
+
+	return s.webhooks.Publish(rollout.TenantID, "", models.EventRolloutPaused, &models.RolloutPausedAlert{
+		RolloutID: rollout.ID,
+		Version:   rollout.Version,
+		Stage:     rollout.CurrentStage + 1,
+		Failed:    failed,
+		Reason:    reason,
+	})
+}
@@ -0,0 +1,247 @@
+// Package sensorconfig resolves the configuration each sensor runs with and
+// pushes changes to sensors. A sensor's configuration is layered: the
+// built-in defaults of its deployment profile, then the configuration
+// profile of its group (a pinned version or the current one), then the
+// sensor's own overrides. Whenever the resolved configuration changes the
+// sensor's config version is bumped and the configuration is pushed with an
+// update_config command; sensors report the version they have applied.
+package sensorconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/commands"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+)
+
+// CommandType is the command that carries configuration to sensors
+const CommandType = "update_config"
+
+const (
+	// pushTTL is how long an update_config command stays deliverable, so
+	// sensors that are offline for a while still receive it
+	pushTTL = 24 * time.Hour
+	// pushPriority puts configuration ahead of routine commands
+	pushPriority = 5
+	// repushInterval is how long a sensor reporting an outdated version
+	// waits before the configuration is pushed again
+	repushInterval = 15 * time.Minute
+)
+
+// Service resolves and pushes sensor configuration
+type Service struct {
+	repo            *repository.Repository
+	commands        *commands.Service
+	controlPlaneURL string
+}
+
+// NewService creates a new configuration service
+func NewService(repo *repository.Repository, commandService *commands.Service, controlPlaneURL string) *Service {
+	return &Service{
+		repo:            repo,
+		commands:        commandService,
+		controlPlaneURL: controlPlaneURL,
+	}
+}
+
+// ValidateProfile checks profile settings on their own and on top of the
+// built-in defaults they are layered onto
+func (s *Service) ValidateProfile(settings *models.ConfigSettings) error {
+	if err := Validate(settings); err != nil {
+		return err
+	}
+	config := Defaults(s.controlPlaneURL, "", nil)
+	Apply(&config, *settings)
+	return validatePortOverlap(config.PortMap)
+}
+
+// Resolve builds the configuration a sensor should run with. The config
+// version is left unset; it is assigned when the configuration is stored.
+func (s *Service) Resolve(sensor *models.RegisteredSensor) (models.VersionedSensorConfig, error) {
+	config := Defaults(s.controlPlaneURL, sensor.Profile, sensor.NetworkInterfaces)
+
+	if sensor.GroupID != "" {
+		version, err := s.repo.GetGroupProfileVersion(sensor.GroupID)
+		if err != nil {
+			return config, err
+		}
+		config.GroupID = sensor.GroupID
+		if version != nil {
+			Apply(&config, version.Settings)
+			config.ProfileID = version.ProfileID
+			config.ProfileVersion = version.Version
+		}
+	}
+
+	Apply(&config, sensor.ConfigOverrides)
+	if err := validatePortOverlap(config.PortMap); err != nil {
+		return config, fmt.Errorf("sensor %s: %w", sensor.ID, err)
+	}
+	return config, nil
+}
+
+// Initial returns the first configuration version of a sensor being
+// registered
+func (s *Service) Initial(sensor *models.RegisteredSensor) (json.RawMessage, error) {
+	config, err := s.Resolve(sensor)
+	if err != nil {
+		return nil, err
+	}
+	config.ConfigVersion = 1
+	return json.Marshal(config)
+}
+
+// Push re-resolves the configuration of the given sensors and pushes it to
+// those whose configuration changed. It returns the number of sensors that
+// were sent a new version. Sensors that fail to resolve are logged and
+// skipped, so one misconfigured sensor does not hold back the others.
+func (s *Service) Push(sensorIDs []string, createdBy string) (int, error) {
+	pushed := 0
+	for _, sensorID := range sensorIDs {
+		sensor, err := s.repo.GetSensor(sensorID)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return pushed, err
+		}
+		if sensor.Decommissioned() {
+			continue
+		}
+
+		ok, err := s.push(sensor, createdBy, false)
+		if err != nil {
+			log.Printf("❌ Failed to push configuration to sensor %s: %v", sensor.ID, err)
+			continue
+		}
+		if ok {
+			pushed++
+		}
+	}
+	return pushed, nil
+}
+
+// PushGroup pushes configuration changes to the sensors of a group
+func (s *Service) PushGroup(groupID, createdBy string) (int, error) {
+	sensorIDs, err := s.repo.ListGroupSensorIDs(groupID)
+	if err != nil {
+		return 0, err
+	}
+	return s.Push(sensorIDs, createdBy)
+}
+
+// PushProfile pushes a new profile version to the sensors of groups that
+// follow the profile's current version
+func (s *Service) PushProfile(profileID, createdBy string) (int, error) {
+	sensorIDs, err := s.repo.ListProfileSensorIDs(profileID)
+	if err != nil {
+		return 0, err
+	}
+	return s.Push(sensorIDs, createdBy)
+}
+
+// push stores a sensor's resolved configuration and queues an update_config
+// command when it changed, or always when force is set. Queued commands
+// carrying older versions are superseded.
+func (s *Service) push(sensor *models.RegisteredSensor, createdBy string, force bool) (bool, error) {
+	config, err := s.Resolve(sensor)
+	if err != nil {
+		return false, err
+	}
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	version, changed, err := s.repo.SetSensorConfiguration(sensor.ID, encoded)
+	if err != nil {
+		return false, err
+	}
+	if !changed && !force {
+		return false, nil
+	}
+	config.ConfigVersion = version
+
+	if _, err := s.repo.SupersedeCommands(sensor.ID, CommandType, fmt.Sprintf("superseded by config version %d", version)); err != nil {
+		return false, err
+	}
+
+	_, err = s.commands.Enqueue(sensor.TenantID, []string{sensor.ID}, commands.Spec{
+		Type:     CommandType,
+		Priority: pushPriority,
+		Payload: map[string]interface{}{
+			"config_version": version,
+			"config":         config,
+		},
+		RequiresAck: true,
+		TTL:         pushTTL,
+		CreatedBy:   createdBy,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("📋 Pushed config version %d to sensor %s", version, sensor.ID)
+	return true, nil
+}
+
+// Reconcile compares the config version a sensor reports in its heartbeat
+// with the desired one. The reported version is recorded, and a sensor
+// that is behind without a pending update_config gets the configuration
+// pushed again. Sensors that do not report a version are left alone.
+func (s *Service) Reconcile(sensor *models.RegisteredSensor, health *models.SensorHealth) error {
+	reported, ok := metricInt(health.Metrics, "config_version")
+	if !ok {
+		return nil
+	}
+
+	if sensor.AppliedVersion == nil || *sensor.AppliedVersion != reported {
+		if err := s.repo.RecordAppliedConfigVersion(sensor.ID, reported); err != nil {
+			return err
+		}
+	}
+
+	if sensor.ConfigVersion == 0 || reported >= sensor.ConfigVersion {
+		return nil
+	}
+	recent, err := s.repo.HasRecentCommand(sensor.ID, CommandType, time.Now().Add(-repushInterval))
+	if err != nil || recent {
+		return err
+	}
+
+	log.Printf("⏰ Sensor %s reports config version %d, expected %d", sensor.ID, reported, sensor.ConfigVersion)
+	_, err = s.push(sensor, "", true)
+	return err
+}
+
+// RecordResult records the config version a sensor reports after executing
+// an update_config command
+func (s *Service) RecordResult(command *models.CommandRecord) error {
+	if command.Type != CommandType || command.Status != models.CommandStatusSucceeded || len(command.Result) == 0 {
+		return nil
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(command.Result, &result); err != nil {
+		return nil
+	}
+	version, ok := metricInt(result, "config_version")
+	if !ok {
+		return nil
+	}
+	return s.repo.RecordAppliedConfigVersion(command.SensorID, version)
+}
+
+// metricInt reads a non-negative integer from a decoded JSON object
+func metricInt(values map[string]interface{}, key string) (int, bool) {
+	value, ok := values[key].(float64)
+	if !ok || value < 0 || value != float64(int(value)) {
+		return 0, false
+	}
+	return int(value), true
+}
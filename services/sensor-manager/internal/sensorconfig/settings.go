@@ -0,0 +1,253 @@
+package sensorconfig
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// Bounds for configuration settings
+const (
+	minReportingInterval = 10    // seconds
+	maxReportingInterval = 86400 // seconds
+	maxInterfaces        = 16
+	maxPortsPerProtocol  = 64
+	minStorageSize       = 1 << 20 // 1 MB
+	maxStorageSize       = 1 << 40 // 1 TB
+	maxRetentionDays     = 365
+	maxConnections       = 100000
+	maxTimeoutSeconds    = 300
+)
+
+// Features lists the feature flags sensors understand
+var Features = []string{
+	"tls_analysis",
+	"ssh_analysis",
+	"certificate_analysis",
+	"active_probing",
+	"network_discovery",
+	"air_gapped_export",
+}
+
+// Protocols lists the protocols a port map may assign ports to
+var Protocols = []string{"tls", "ssh"}
+
+// interfaceName matches Linux network interface names
+var interfaceName = regexp.MustCompile(`^[A-Za-z0-9_.:@-]{1,15}$`)
+
+// Validate checks partial settings as stored in a profile version or as
+// sensor overrides
+func Validate(settings *models.ConfigSettings) error {
+	if settings.ReportingInterval != nil {
+		if v := *settings.ReportingInterval; v < minReportingInterval || v > maxReportingInterval {
+			return fmt.Errorf("reporting_interval must be between %d and %d seconds", minReportingInterval, maxReportingInterval)
+		}
+	}
+
+	if len(settings.Interfaces) > maxInterfaces {
+		return fmt.Errorf("at most %d interfaces are allowed", maxInterfaces)
+	}
+	for _, iface := range settings.Interfaces {
+		if !interfaceName.MatchString(iface) {
+			return fmt.Errorf("invalid interface name %q", iface)
+		}
+	}
+
+	for protocol, ports := range settings.PortMap {
+		if !contains(Protocols, protocol) {
+			return fmt.Errorf("unknown protocol %q in port_map", protocol)
+		}
+		if len(ports) > maxPortsPerProtocol {
+			return fmt.Errorf("at most %d ports per protocol are allowed", maxPortsPerProtocol)
+		}
+		for _, port := range ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("invalid port %d in port_map", port)
+			}
+		}
+	}
+	if err := validatePortOverlap(settings.PortMap); err != nil {
+		return err
+	}
+
+	for feature := range settings.Features {
+		if !contains(Features, feature) {
+			return fmt.Errorf("unknown feature %q", feature)
+		}
+	}
+
+	if storage := settings.Storage; storage != nil {
+		if storage.MaxStorageSize != nil && (*storage.MaxStorageSize < minStorageSize || *storage.MaxStorageSize > maxStorageSize) {
+			return errors.New("storage.max_storage_size must be between 1 MB and 1 TB")
+		}
+		if storage.RotationSize != nil && *storage.RotationSize < minStorageSize {
+			return errors.New("storage.rotation_size must be at least 1 MB")
+		}
+		if storage.MaxStorageSize != nil && storage.RotationSize != nil && *storage.RotationSize > *storage.MaxStorageSize {
+			return errors.New("storage.rotation_size cannot exceed storage.max_storage_size")
+		}
+		if storage.RetentionDays != nil && (*storage.RetentionDays < 1 || *storage.RetentionDays > maxRetentionDays) {
+			return fmt.Errorf("storage.retention_days must be between 1 and %d", maxRetentionDays)
+		}
+	}
+
+	if capture := settings.Capture; capture != nil {
+		if capture.MaxConnections != nil && (*capture.MaxConnections < 1 || *capture.MaxConnections > maxConnections) {
+			return fmt.Errorf("capture.max_connections must be between 1 and %d", maxConnections)
+		}
+		if capture.TimeoutSeconds != nil && (*capture.TimeoutSeconds < 1 || *capture.TimeoutSeconds > maxTimeoutSeconds) {
+			return fmt.Errorf("capture.timeout_seconds must be between 1 and %d", maxTimeoutSeconds)
+		}
+	}
+	return nil
+}
+
+// validatePortOverlap rejects a port assigned to more than one protocol
+func validatePortOverlap(portMap map[string][]int) error {
+	owner := make(map[int]string)
+	protocols := make([]string, 0, len(portMap))
+	for protocol := range portMap {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	for _, protocol := range protocols {
+		for _, port := range portMap[protocol] {
+			if other, ok := owner[port]; ok && other != protocol {
+				return fmt.Errorf("port %d is assigned to both %s and %s", port, other, protocol)
+			}
+			owner[port] = protocol
+		}
+	}
+	return nil
+}
+
+// Apply layers partial settings onto a configuration. Interfaces and the
+// ports of each protocol are replaced as a whole; features and limits are
+// overridden individually.
+func Apply(config *models.VersionedSensorConfig, settings models.ConfigSettings) {
+	if settings.ReportingInterval != nil {
+		config.ReportingInterval = *settings.ReportingInterval
+	}
+	if len(settings.Interfaces) > 0 {
+		config.CaptureConfig.Interfaces = append([]string(nil), settings.Interfaces...)
+	}
+	for protocol, ports := range settings.PortMap {
+		config.PortMap[protocol] = append([]int(nil), ports...)
+	}
+	for feature, enabled := range settings.Features {
+		config.Features[feature] = enabled
+	}
+
+	if storage := settings.Storage; storage != nil {
+		if storage.MaxStorageSize != nil {
+			config.StorageConfig.MaxStorageSize = *storage.MaxStorageSize
+		}
+		if storage.RotationSize != nil {
+			config.StorageConfig.RotationSize = *storage.RotationSize
+		}
+		if storage.RetentionDays != nil {
+			config.StorageConfig.RetentionDays = *storage.RetentionDays
+		}
+	}
+	if capture := settings.Capture; capture != nil {
+		if capture.MaxConnections != nil {
+			config.CaptureConfig.MaxConnections = *capture.MaxConnections
+		}
+		if capture.TimeoutSeconds != nil {
+			config.CaptureConfig.TimeoutSeconds = *capture.TimeoutSeconds
+		}
+	}
+
+	// Capture flags follow their features
+	config.CaptureConfig.ActiveProbing = config.Features["active_probing"]
+	config.CaptureConfig.NetworkDiscovery = config.Features["network_discovery"]
+}
+
+// Defaults returns the built-in configuration of a deployment profile, the
+// bottom layer every sensor's configuration starts from
+func Defaults(controlPlaneURL, profile string, interfaces []string) models.VersionedSensorConfig {
+	features := profileFeatures(profile)
+	if len(interfaces) == 0 {
+		interfaces = []string{"eth0"}
+	}
+
+	return models.VersionedSensorConfig{
+		SensorConfig: models.SensorConfig{
+			ControlPlaneURL:   controlPlaneURL,
+			ReportingInterval: profileReportingInterval(profile),
+			StorageConfig: models.StorageConfig{
+				MaxStorageSize: 10 * 1024 * 1024 * 1024, // 10 GB
+				RotationSize:   512 * 1024 * 1024,       // 512 MB
+				RetentionDays:  7,
+				EncryptionKey:  "",
+			},
+			CaptureConfig: models.CaptureConfig{
+				Interfaces:       append([]string(nil), interfaces...),
+				ActiveProbing:    features["active_probing"],
+				NetworkDiscovery: features["network_discovery"],
+				MaxConnections:   1000,
+				TimeoutSeconds:   30,
+			},
+			Features: features,
+		},
+		PortMap: map[string][]int{
+			"tls": {443, 465, 587, 993, 995},
+			"ssh": {22},
+		},
+	}
+}
+
+func profileFeatures(profile string) map[string]bool {
+	features := map[string]bool{
+		"tls_analysis":         true,
+		"ssh_analysis":         true,
+		"certificate_analysis": true,
+		"active_probing":       false,
+		"network_discovery":    false,
+		"air_gapped_export":    false,
+	}
+
+	switch profile {
+	case "datacenter_host":
+		features["active_probing"] = true
+		features["network_discovery"] = true
+	case "cloud_instance":
+		features["active_probing"] = true
+	case "end_user_machine":
+		// Minimal features
+	case "air_gapped":
+		features["air_gapped_export"] = true
+		features["active_probing"] = false
+		features["network_discovery"] = false
+	}
+
+	return features
+}
+
+func profileReportingInterval(profile string) int {
+	switch profile {
+	case "datacenter_host":
+		return 30 // 30 seconds
+	case "cloud_instance":
+		return 60 // 1 minute
+	case "end_user_machine":
+		return 300 // 5 minutes
+	case "air_gapped":
+		return 3600 // 1 hour
+	default:
+		return 60
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
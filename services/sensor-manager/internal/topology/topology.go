@@ -0,0 +1,429 @@
+// Package topology builds the network topology and sensor coverage model
+// from the subnets, gateways and flows sensors report, and the tenant's
+// known network assets.
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+)
+
+// Bounds of one topology report, matching what sensors collect per window
+const (
+	MaxSubnets  = 5000
+	MaxVLANs    = 4094
+	MaxGateways = 2000
+	MaxFlows    = 10000
+)
+
+// ErrInvalidReport is returned for topology reports that cannot be stored
+var ErrInvalidReport = errors.New("invalid topology report")
+
+// Validate checks a topology report and normalizes its addresses, so the
+// same subnet or gateway is always stored the same way
+func Validate(report *models.TopologyReport) error {
+	if len(report.Subnets) > MaxSubnets || len(report.VLANs) > MaxVLANs ||
+		len(report.Gateways) > MaxGateways || len(report.Flows) > MaxFlows {
+		return fmt.Errorf("%w: at most %d subnets, %d gateways and %d flows", ErrInvalidReport, MaxSubnets, MaxGateways, MaxFlows)
+	}
+
+	for i := range report.Subnets {
+		subnet := &report.Subnets[i]
+		cidr, err := normalizeCIDR(subnet.CIDR)
+		if err != nil {
+			return err
+		}
+		subnet.CIDR = cidr
+		if err := validateVLAN(subnet.VLAN, true); err != nil {
+			return err
+		}
+		switch subnet.Source {
+		case models.TopologySourceInterface, models.TopologySourceObserved, models.TopologySourceConfigured:
+		default:
+			return fmt.Errorf("%w: unknown subnet source %q", ErrInvalidReport, subnet.Source)
+		}
+		if subnet.Hosts < 0 || subnet.Packets < 0 {
+			return fmt.Errorf("%w: negative counter for subnet %s", ErrInvalidReport, subnet.CIDR)
+		}
+	}
+
+	for _, vlan := range report.VLANs {
+		if err := validateVLAN(vlan.ID, false); err != nil {
+			return err
+		}
+		if vlan.Source != models.TopologySourceObserved && vlan.Source != models.TopologySourceConfigured {
+			return fmt.Errorf("%w: unknown VLAN source %q", ErrInvalidReport, vlan.Source)
+		}
+		if vlan.Packets < 0 {
+			return fmt.Errorf("%w: negative counter for VLAN %d", ErrInvalidReport, vlan.ID)
+		}
+	}
+
+	for i := range report.Gateways {
+		gateway := &report.Gateways[i]
+		if gateway.IP != "" {
+			ip := net.ParseIP(gateway.IP)
+			if ip == nil {
+				return fmt.Errorf("%w: invalid gateway address %q", ErrInvalidReport, gateway.IP)
+			}
+			gateway.IP = ip.String()
+		}
+		if gateway.MAC != "" {
+			mac, err := net.ParseMAC(gateway.MAC)
+			if err != nil {
+				return fmt.Errorf("%w: invalid gateway MAC %q", ErrInvalidReport, gateway.MAC)
+			}
+			gateway.MAC = mac.String()
+		}
+		if gateway.IP == "" && gateway.MAC == "" {
+			return fmt.Errorf("%w: gateway without address", ErrInvalidReport)
+		}
+		if err := validateVLAN(gateway.VLAN, true); err != nil {
+			return err
+		}
+		switch gateway.Source {
+		case models.TopologySourceRoute, models.TopologySourceObserved, models.TopologySourceConfigured:
+		default:
+			return fmt.Errorf("%w: unknown gateway source %q", ErrInvalidReport, gateway.Source)
+		}
+	}
+
+	for i := range report.Flows {
+		flow := &report.Flows[i]
+		src, err := normalizeCIDR(flow.SrcCIDR)
+		if err != nil {
+			return err
+		}
+		dst, err := normalizeCIDR(flow.DstCIDR)
+		if err != nil {
+			return err
+		}
+		flow.SrcCIDR, flow.DstCIDR = src, dst
+		flow.Protocol = strings.ToLower(flow.Protocol)
+		if flow.Protocol == "" || len(flow.Protocol) > 20 {
+			return fmt.Errorf("%w: invalid flow protocol %q", ErrInvalidReport, flow.Protocol)
+		}
+		if flow.Port < 1 || flow.Port > 65535 {
+			return fmt.Errorf("%w: invalid flow port %d", ErrInvalidReport, flow.Port)
+		}
+		if err := validateVLAN(flow.VLAN, true); err != nil {
+			return err
+		}
+		if flow.Packets < 0 {
+			return fmt.Errorf("%w: negative counter for flow %s -> %s", ErrInvalidReport, flow.SrcCIDR, flow.DstCIDR)
+		}
+	}
+	return nil
+}
+
+func normalizeCIDR(value string) (string, error) {
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid subnet %q", ErrInvalidReport, value)
+	}
+	return network.String(), nil
+}
+
+func validateVLAN(id int, untagged bool) error {
+	if (untagged && id == 0) || (id >= 1 && id <= 4094) {
+		return nil
+	}
+	return fmt.Errorf("%w: invalid VLAN %d", ErrInvalidReport, id)
+}
+
+// observedSubnet is a subnet seen by sensors, merged across sensors,
+// sources and VLANs
+type observedSubnet struct {
+	network  *net.IPNet
+	coverage *models.SubnetCoverage
+	sensors  map[string]bool
+	vlans    map[int]bool
+	hosts    int
+	packets  int64
+}
+
+// mergeSubnets merges the subnet observations of all sensors per CIDR
+func mergeSubnets(subnets []*models.SensorSubnet) []*observedSubnet {
+	byCIDR := make(map[string]*observedSubnet)
+	var merged []*observedSubnet
+	for _, subnet := range subnets {
+		entry, ok := byCIDR[subnet.CIDR]
+		if !ok {
+			_, network, err := net.ParseCIDR(subnet.CIDR)
+			if err != nil {
+				continue
+			}
+			entry = &observedSubnet{
+				network:  network,
+				coverage: &models.SubnetCoverage{CIDR: subnet.CIDR, VLANs: []int{}, Sensors: []models.CoverageSensor{}, Covered: true},
+				sensors:  make(map[string]bool),
+				vlans:    make(map[int]bool),
+			}
+			byCIDR[subnet.CIDR] = entry
+			merged = append(merged, entry)
+		}
+
+		if !entry.sensors[subnet.SensorID] {
+			entry.sensors[subnet.SensorID] = true
+			entry.coverage.Sensors = append(entry.coverage.Sensors, models.CoverageSensor{ID: subnet.SensorID, Name: subnet.SensorName})
+		}
+		if subnet.VLAN != 0 && !entry.vlans[subnet.VLAN] {
+			entry.vlans[subnet.VLAN] = true
+			entry.coverage.VLANs = append(entry.coverage.VLANs, subnet.VLAN)
+		}
+		if subnet.Hosts > entry.hosts {
+			entry.hosts = subnet.Hosts
+		}
+		entry.packets += subnet.Packets
+		if last := subnet.LastSeenAt; entry.coverage.LastSeenAt == nil || last.After(*entry.coverage.LastSeenAt) {
+			entry.coverage.LastSeenAt = &last
+		}
+	}
+	for _, entry := range merged {
+		sort.Ints(entry.coverage.VLANs)
+	}
+	return merged
+}
+
+// containing returns the observed subnets holding all of network
+func containing(observed []*observedSubnet, network *net.IPNet) []*observedSubnet {
+	var result []*observedSubnet
+	prefix, bits := network.Mask.Size()
+	for _, entry := range observed {
+		entryPrefix, entryBits := entry.network.Mask.Size()
+		if entryBits == bits && entryPrefix <= prefix && entry.network.Contains(network.IP) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Coverage tells which subnets the tenant's sensors see and which subnets
+// of known assets none of them does. An asset subnet is covered when a
+// subnet a sensor sees contains it.
+func Coverage(subnets []*models.SensorSubnet, assets []models.AssetSubnet, since time.Time) *models.TopologyCoverage {
+	observed := mergeSubnets(subnets)
+	coverage := &models.TopologyCoverage{
+		Subnets:   make([]models.SubnetCoverage, 0, len(observed)),
+		Uncovered: []models.SubnetCoverage{},
+		Since:     since,
+	}
+
+	for _, asset := range assets {
+		_, network, err := net.ParseCIDR(asset.CIDR)
+		if err != nil {
+			continue
+		}
+		coverage.Summary.AssetSubnets++
+		holders := containing(observed, network)
+		if len(holders) == 0 {
+			coverage.Uncovered = append(coverage.Uncovered, models.SubnetCoverage{
+				CIDR:    asset.CIDR,
+				VLANs:   []int{},
+				Sensors: []models.CoverageSensor{},
+				Assets:  asset.Assets,
+			})
+			continue
+		}
+		coverage.Summary.CoveredSubnets++
+		for _, holder := range holders {
+			holder.coverage.Assets += asset.Assets
+		}
+	}
+
+	sensors := make(map[string]bool)
+	for _, entry := range observed {
+		for id := range entry.sensors {
+			sensors[id] = true
+		}
+		coverage.Subnets = append(coverage.Subnets, *entry.coverage)
+	}
+	coverage.Summary.Sensors = len(sensors)
+	coverage.Summary.ObservedSubnets = len(observed)
+	coverage.Summary.UncoveredSubnets = len(coverage.Uncovered)
+	if coverage.Summary.AssetSubnets > 0 {
+		percentage := float64(coverage.Summary.CoveredSubnets) * 100 / float64(coverage.Summary.AssetSubnets)
+		coverage.Summary.CoveragePercentage = float64(int(percentage*10+0.5)) / 10
+	}
+
+	// Largest uncovered subnets first, they matter most
+	sort.SliceStable(coverage.Uncovered, func(i, j int) bool {
+		return coverage.Uncovered[i].Assets > coverage.Uncovered[j].Assets
+	})
+	return coverage
+}
+
+// GraphInput is what the topology graph is built from
+type GraphInput struct {
+	Subnets         []*models.SensorSubnet
+	Gateways        []*models.SensorGateway
+	Flows           []*models.SensorFlow
+	Assets          []models.TopologyAsset
+	AssetsTruncated bool
+	Since           time.Time
+}
+
+// graphBuilder collects nodes and edges without duplicates
+type graphBuilder struct {
+	graph *models.TopologyGraph
+	nodes map[string]int // node ID -> index
+	edges map[string]int // source|target|type -> index
+}
+
+func (b *graphBuilder) node(node models.TopologyNode) *models.TopologyNode {
+	if i, ok := b.nodes[node.ID]; ok {
+		return &b.graph.Nodes[i]
+	}
+	b.nodes[node.ID] = len(b.graph.Nodes)
+	b.graph.Nodes = append(b.graph.Nodes, node)
+	return &b.graph.Nodes[len(b.graph.Nodes)-1]
+}
+
+func (b *graphBuilder) edge(source, target, edgeType string) *models.TopologyEdge {
+	key := source + "|" + target + "|" + edgeType
+	if i, ok := b.edges[key]; ok {
+		return &b.graph.Edges[i]
+	}
+	b.edges[key] = len(b.graph.Edges)
+	b.graph.Edges = append(b.graph.Edges, models.TopologyEdge{Source: source, Target: target, Type: edgeType})
+	return &b.graph.Edges[len(b.graph.Edges)-1]
+}
+
+func subnetNode(cidr string) string { return models.TopologyNodeSubnet + ":" + cidr }
+func sensorNode(id string) string   { return models.TopologyNodeSensor + ":" + id }
+
+// Graph builds the topology graph: sensors observe subnets, gateways sit
+// next to sensors, assets belong to subnets and flows connect subnets.
+// Subnets holding assets no sensor sees are part of it, marked uncovered.
+func Graph(input GraphInput) *models.TopologyGraph {
+	b := &graphBuilder{
+		graph: &models.TopologyGraph{
+			Nodes:           []models.TopologyNode{},
+			Edges:           []models.TopologyEdge{},
+			AssetsTruncated: input.AssetsTruncated,
+			Since:           input.Since,
+		},
+		nodes: make(map[string]int),
+		edges: make(map[string]int),
+	}
+
+	observed := mergeSubnets(input.Subnets)
+	for _, entry := range observed {
+		b.node(models.TopologyNode{
+			ID:    subnetNode(entry.coverage.CIDR),
+			Type:  models.TopologyNodeSubnet,
+			Label: entry.coverage.CIDR,
+			Data: map[string]interface{}{
+				"covered": true,
+				"vlans":   entry.coverage.VLANs,
+				"hosts":   entry.hosts,
+				"packets": entry.packets,
+				"assets":  0,
+			},
+		})
+	}
+	for _, subnet := range input.Subnets {
+		b.node(models.TopologyNode{ID: sensorNode(subnet.SensorID), Type: models.TopologyNodeSensor, Label: subnet.SensorName})
+		b.edge(sensorNode(subnet.SensorID), subnetNode(subnet.CIDR), models.TopologyEdgeObserves)
+	}
+
+	for _, gateway := range input.Gateways {
+		address := gateway.IP
+		if address == "" {
+			address = gateway.MAC
+		}
+		id := models.TopologyNodeGateway + ":" + address
+		node := b.node(models.TopologyNode{
+			ID:    id,
+			Type:  models.TopologyNodeGateway,
+			Label: address,
+			Data:  map[string]interface{}{"ip": gateway.IP, "mac": gateway.MAC, "source": gateway.Source},
+		})
+		if gateway.VLAN != 0 {
+			node.Data["vlan"] = gateway.VLAN
+		}
+		// Sensors without subnets reported since still get a node
+		b.node(models.TopologyNode{ID: sensorNode(gateway.SensorID), Type: models.TopologyNodeSensor, Label: gateway.SensorName})
+		b.edge(id, sensorNode(gateway.SensorID), models.TopologyEdgeGateway)
+	}
+
+	for _, asset := range input.Assets {
+		_, network, err := net.ParseCIDR(asset.Subnet)
+		if err != nil {
+			continue
+		}
+		// Attach assets to the subnet node they fall in, or to their own
+		// subnet, uncovered when no sensor sees it
+		target := subnetNode(asset.Subnet)
+		if holders := containing(observed, network); len(holders) > 0 {
+			target = subnetNode(holders[0].coverage.CIDR)
+		} else {
+			b.node(models.TopologyNode{
+				ID:    target,
+				Type:  models.TopologyNodeSubnet,
+				Label: asset.Subnet,
+				Data:  map[string]interface{}{"covered": false, "assets": 0},
+			})
+		}
+		subnet := &b.graph.Nodes[b.nodes[target]]
+		subnet.Data["assets"] = subnet.Data["assets"].(int) + 1
+
+		label := asset.Hostname
+		if label == "" {
+			label = asset.IP
+		}
+		id := models.TopologyNodeAsset + ":" + asset.ID
+		b.node(models.TopologyNode{
+			ID:    id,
+			Type:  models.TopologyNodeAsset,
+			Label: label,
+			Data:  map[string]interface{}{"ip": asset.IP},
+		})
+		b.edge(id, target, models.TopologyEdgeMember)
+	}
+
+	for _, flow := range input.Flows {
+		for _, cidr := range []string{flow.SrcCIDR, flow.DstCIDR} {
+			b.node(models.TopologyNode{
+				ID:    subnetNode(cidr),
+				Type:  models.TopologyNodeSubnet,
+				Label: cidr,
+				Data:  map[string]interface{}{"covered": true, "assets": 0},
+			})
+		}
+		edge := b.edge(subnetNode(flow.SrcCIDR), subnetNode(flow.DstCIDR), models.TopologyEdgeFlow)
+		edge.Packets += flow.Packets
+		edge.Protocols = appendUnique(edge.Protocols, flow.Protocol)
+		edge.Ports = appendUniqueInt(edge.Ports, flow.Port)
+	}
+
+	for i := range b.graph.Edges {
+		sort.Strings(b.graph.Edges[i].Protocols)
+		sort.Ints(b.graph.Edges[i].Ports)
+	}
+	return b.graph
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func appendUniqueInt(values []int, value int) []int {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,363 @@
+// Package webhooks delivers tenant events to the webhooks tenants register.
+// Events are published to an outbox table, by this service or by any other
+// sharing the database, and fanned out into one delivery per subscribed
+// endpoint. Each delivery is a JSON POST signed with the endpoint's secret,
+// retried with exponential backoff up to the endpoint's retry count and
+// kept as a dead letter once it runs out of attempts. Deliveries are stored,
+// so they survive restarts, and claimed with row locks, so several replicas
+// can dispatch side by side.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/models"
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/repository"
+)
+
+// Headers set on every delivery
+const (
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderDelivery carries the delivery ID, which is the same for every
+	// attempt of a delivery so receivers can drop duplicates
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a dot and the body, keyed with the webhook's secret. It is
+	// only set when the webhook has a secret.
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// pollInterval is how often the outbox and the due deliveries are
+	// checked when nothing wakes the dispatcher earlier
+	pollInterval = 5 * time.Second
+	// dispatchBatch bounds the events fanned out and the deliveries claimed
+	// per query
+	dispatchBatch = 100
+	// claimLock is how long a claimed delivery stays claimed: longer than
+	// the slowest attempt
+	claimLock = 2 * time.Minute
+	// workers bounds the deliveries attempted concurrently
+	workers = 8
+	// initialBackoff is the wait before the first retry; it doubles with
+	// every further attempt up to maxBackoff
+	initialBackoff = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+	// defaultTimeout applies to webhooks stored without a timeout
+	defaultTimeout = 10 * time.Second
+	// purgeInterval, eventRetention and deadLetterRetention control how
+	// long delivered events and dead letters are kept
+	purgeInterval       = time.Hour
+	eventRetention      = 30 * 24 * time.Hour
+	deadLetterRetention = 90 * 24 * time.Hour
+)
+
+// Dispatcher delivers events to tenant webhooks
+type Dispatcher struct {
+	repo   *repository.Repository
+	client *http.Client
+	wake   chan struct{}
+	// allowedNetworks are internal networks receivers may be on despite
+	// the address checks, e.g. on-premises receivers
+	allowedNetworks []netip.Prefix
+}
+
+// NewDispatcher creates a new webhook dispatcher. Receivers on loopback,
+// link-local, private or unspecified addresses are refused unless they are
+// inside one of allowedNetworks.
+func NewDispatcher(repo *repository.Repository, allowedNetworks []netip.Prefix) *Dispatcher {
+	d := &Dispatcher{
+		repo:            repo,
+		wake:            make(chan struct{}, 1),
+		allowedNetworks: allowedNetworks,
+	}
+	// Addresses are checked when connecting rather than when resolving, so
+	// a host that resolves differently the second time cannot slip through.
+	// Deliveries bypass any HTTP proxy, which would be the only address
+	// checked.
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   d.checkDial,
+	}
+	d.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		// Receivers must answer directly; a redirect could leak the
+		// payload to another host
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// Publish stores an event for delivery to every enabled webhook of the
+// tenant that subscribes to it. sensorID is the sensor the event concerns,
+// if any; webhooks limited to another sensor do not receive it.
+func (d *Dispatcher) Publish(tenantID, sensorID, eventType string, data interface{}) error {
+	if _, err := d.repo.PublishWebhookEvent(tenantID, sensorID, eventType, data); err != nil {
+		return err
+	}
+	d.Notify()
+	return nil
+}
+
+// Notify wakes the dispatcher, e.g. after an event was published in
+// another transaction
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run fans out published events and attempts due deliveries until ctx is
+// cancelled. Attempts in flight when ctx is cancelled are abandoned and
+// claimed again once their lock expires.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+
+	for {
+		if err := d.dispatch(ctx); err != nil {
+			log.Printf("❌ Webhook dispatch failed: %v", err)
+		}
+
+		if time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			now := time.Now()
+			purged, err := d.repo.PurgeWebhookEvents(now.Add(-eventRetention), now.Add(-deadLetterRetention))
+			if err != nil {
+				log.Printf("❌ Failed to purge webhook events: %v", err)
+			} else if purged > 0 {
+				log.Printf("🧹 Purged %d delivered webhook events", purged)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// dispatch fans out the outbox and then works through the due deliveries,
+// batch by batch, until none is left
+func (d *Dispatcher) dispatch(ctx context.Context) error {
+	for {
+		dispatched, err := d.repo.DispatchWebhookEvents(dispatchBatch)
+		if err != nil {
+			return err
+		}
+		if dispatched < dispatchBatch {
+			break
+		}
+	}
+
+	for ctx.Err() == nil {
+		claimed, err := d.repo.ClaimWebhookDeliveries(dispatchBatch, claimLock)
+		if err != nil {
+			return err
+		}
+
+		jobs := make(chan *repository.ClaimedDelivery)
+		var wg sync.WaitGroup
+		for i := 0; i < workers && i < len(claimed); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for item := range jobs {
+					if _, err := d.attempt(ctx, item); err != nil {
+						log.Printf("❌ Failed to record webhook delivery %s: %v", item.Delivery.ID, err)
+					}
+				}
+			}()
+		}
+		for _, item := range claimed {
+			jobs <- item
+		}
+		close(jobs)
+		wg.Wait()
+
+		if len(claimed) < dispatchBatch {
+			return nil
+		}
+	}
+	return nil
+}
+
+// SendTest delivers a webhook.test event to a single endpoint right away,
+// in a single attempt, and returns the delivery with its attempt log
+func (d *Dispatcher) SendTest(ctx context.Context, endpoint *models.WebhookEndpoint, requestedBy string) (*models.WebhookDelivery, error) {
+	claimed, err := d.repo.CreateTestDelivery(endpoint, map[string]interface{}{
+		"message":      "This is a test event sent on request",
+		"webhook_id":   endpoint.ID,
+		"requested_by": requestedBy,
+	}, claimLock)
+	if err != nil {
+		return nil, err
+	}
+	// A disabled webhook still receives the test it was asked for
+	claimed.Endpoint.Enabled = true
+
+	attempt, err := d.attempt(ctx, claimed)
+	if err != nil {
+		return nil, err
+	}
+	claimed.Delivery.AttemptLog = []*models.WebhookDeliveryAttempt{attempt}
+	return claimed.Delivery, nil
+}
+
+// Redeliver queues a dead-lettered or succeeded delivery for delivery
+// again
+func (d *Dispatcher) Redeliver(tenantID, deliveryID string) (*models.WebhookDelivery, error) {
+	delivery, err := d.repo.RedeliverWebhookDelivery(tenantID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	d.Notify()
+	return delivery, nil
+}
+
+// attempt makes one attempt of a claimed delivery and records its outcome:
+// succeeded, retrying after a backoff or, once out of attempts, a dead
+// letter. Deliveries to disabled webhooks are dead-lettered without an
+// attempt being made.
+func (d *Dispatcher) attempt(ctx context.Context, item *repository.ClaimedDelivery) (*models.WebhookDeliveryAttempt, error) {
+	delivery := item.Delivery
+	attempt := &models.WebhookDeliveryAttempt{Attempt: delivery.Attempts, AttemptedAt: time.Now()}
+
+	if item.Endpoint.Enabled {
+		statusCode, err := d.post(ctx, item)
+		attempt.StatusCode = statusCode
+		attempt.DurationMS = time.Since(attempt.AttemptedAt).Milliseconds()
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+	} else {
+		attempt.Error = "webhook disabled"
+	}
+	if ctx.Err() != nil {
+		// Shutting down; the delivery is claimed again once its lock
+		// expires
+		return attempt, nil
+	}
+
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastError = attempt.Error
+	delivery.NextAttemptAt = nil
+	switch {
+	case attempt.Error == "":
+		delivery.Status = models.DeliveryStatusSucceeded
+	case !item.Endpoint.Enabled || delivery.Attempts >= delivery.MaxAttempts:
+		delivery.Status = models.DeliveryStatusDeadLetter
+		log.Printf("❌ Webhook %s failed to deliver %s after %d attempts: %s",
+			delivery.EndpointID, delivery.EventType, delivery.Attempts, attempt.Error)
+	default:
+		delivery.Status = models.DeliveryStatusRetrying
+		next := time.Now().Add(Backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := d.repo.FinishWebhookAttempt(delivery, attempt); err != nil {
+		if err == repository.ErrNotFound {
+			// The delivery was claimed again or deleted in the meantime
+			return attempt, nil
+		}
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// post makes a single delivery attempt and returns the receiver's status
+// code. Any 2xx response counts as delivered.
+func (d *Dispatcher) post(ctx context.Context, item *repository.ClaimedDelivery) (int, error) {
+	body, err := json.Marshal(item.Event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	timeout := time.Duration(item.Endpoint.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, item.Endpoint.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "crypto-inventory-sensor-manager")
+	req.Header.Set(HeaderEvent, item.Event.Type)
+	req.Header.Set(HeaderDelivery, item.Delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if item.Endpoint.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(item.Endpoint.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the wait after the given failed attempt: 30 seconds after
+// the first, doubling with every further attempt up to 6 hours, with 20%
+// jitter so retries to a recovering receiver are spread out
+func Backoff(attempt int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff)/5*2+1)) - backoff/5
+	return backoff + jitter
+}
+
+// Sign returns the signature header value of a payload, which receivers
+// recompute to verify a delivery
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+
+	"github.com/democorp/crypto-inventory/services/sensor-manager/internal/netpolicy"
+)
+
+// ErrForbiddenAddress is returned for receivers on addresses webhooks may
+// not be delivered to, which would let tenants reach internal services
+var ErrForbiddenAddress = errors.New("webhook receivers on loopback, link-local, private, shared or unspecified addresses are not allowed")
+
+// deniedNetworks are refused on top of the netip predicates: "this
+// network", carrier-grade NAT and benchmarking ranges, which commonly reach
+// internal services in cloud and container networks
+var deniedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// CheckURL resolves the host of a webhook URL and returns
+// ErrForbiddenAddress when any of its addresses is refused. The check is
+// repeated for every connection a delivery makes.
+func (d *Dispatcher) CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !d.permitted(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// checkDial is the dialer's Control hook: it runs after resolution, for
+// the address actually being connected to
+func (d *Dispatcher) checkDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %q: %w", address, err)
+	}
+	if !d.permitted(addrPort.Addr()) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// permitted reports whether webhooks may be delivered to addr
+func (d *Dispatcher) permitted(addr netip.Addr) bool {
+	addr = addr.WithZone("").Unmap()
+	if netpolicy.Contains(d.allowedNetworks, addr) {
+		return true
+	}
+	return !(addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsPrivate() || addr.IsUnspecified() ||
+		netpolicy.Contains(deniedNetworks, addr))
+}
@@ -97,6 +97,7 @@ const SensorRegistrationPage: React.FC = () => {
       case 'used':
         return 'bg-green-100 text-green-800';
       case 'expired':
+      case 'revoked':
         return 'bg-red-100 text-red-800';
       default:
         return 'bg-gray-100 text-gray-800';
@@ -107,14 +108,14 @@ const SensorRegistrationPage: React.FC = () => {
     const interfaces = sensor.network_interfaces.length > 0 ? sensor.network_interfaces.join(',') : 'eth0';
     const controlPlaneURL = 'https://crypto-inventory.company.com';
     return {
-      curlCommand: `curl -sSL https://sensors.crypto-inventory.com/install.sh | bash -s -- --key ${sensor.registration_key} --ip ${sensor.ip_address} --name "${sensor.name}" --profile ${sensor.profile}`,
+      curlCommand: `curl -sSL https://sensors.crypto-inventory.com/install.sh | bash -s -- --key ${sensor.registration_key ?? sensor.key_prefix} --ip <sensor-ip> --name "${sensor.name}" --profile ${sensor.profile}`,
       interactiveCommand: `curl -sSL ${controlPlaneURL}/scripts/install-sensor.sh | sudo bash -s -- --interactive`,
       manualCommand: `# Download and run manually
 curl -O ${controlPlaneURL}/scripts/install-sensor.sh
 chmod +x install-sensor.sh
 sudo ./install-sensor.sh \
-  --key ${sensor.registration_key} \
-  --ip ${sensor.ip_address} \
+  --key ${sensor.registration_key ?? sensor.key_prefix} \
+  --ip <sensor-ip> \
   --name ${sensor.name} \
   --profile ${sensor.profile} \
   --interfaces "${interfaces}" \
@@ -128,7 +129,7 @@ sudo ./install-sensor.sh \
       return;
     }
     try {
-      await sensorsApi.createPending({
+      const created = await sensorsApi.createPending({
         name: formData.name,
         ip_address: formData.ipAddress,
         profile: formData.profile,
@@ -137,7 +138,11 @@ sudo ./install-sensor.sh \
       });
       setShowAddSensor(false);
       setFormData({ name: '', ipAddress: '', tags: [], profile: 'datacenter_host', networkInterfaces: [], description: '' });
-      fetchPending();
+      // The full key is only returned once, so keep it on the new entry
+      const items = await sensorsApi.listPending();
+      setPendingSensors(items.map((item) =>
+        item.id === created.id ? { ...item, registration_key: created.registration_key } : item
+      ));
     } catch (e) {
       setError('Failed to create registration key');
     }
@@ -147,9 +152,9 @@ sudo ./install-sensor.sh \
     navigator.clipboard.writeText(text);
   };
 
-  const deletePendingSensor = async (idOrKey: string) => {
+  const deletePendingSensor = async (id: string) => {
     try {
-      await sensorsApi.deletePending(idOrKey);
+      await sensorsApi.deletePending(id);
       fetchPending();
     } catch (e) {
       setError('Failed to delete registration key');
@@ -228,16 +233,17 @@ sudo ./install-sensor.sh \
                         )}
                       </div>
                       <div className="mt-1 flex items-center text-sm text-gray-500 dark:text-gray-400">
-                        <p className="mr-4">IP: {sensor.ip_address}</p>
+                        <p className="mr-4">Network: {sensor.allowed_cidrs.length > 0 ? sensor.allowed_cidrs.join(', ') : 'any'}</p>
+                        <p className="mr-4">Sensors: {sensor.used_count}/{sensor.max_sensors}</p>
                         <p className="mr-4">Profile: {sensor.profile}</p>
                         <p className="mr-4">Interfaces: {sensor.network_interfaces.join(', ')}</p>
-                        <p>Key: {sensor.registration_key}</p>
+                        <p>Key: {sensor.registration_key ?? `${sensor.key_prefix}…`}</p>
                       </div>
                     </div>
                   </div>
                   <div className="flex items-center space-x-2">
                     <button
-                      onClick={() => copyToClipboard(sensor.registration_key)}
+                      onClick={() => copyToClipboard(sensor.registration_key ?? sensor.key_prefix)}
                       className="inline-flex items-center px-3 py-1 border border-gray-300 dark:border-gray-600 text-sm font-medium rounded-md text-gray-700 dark:text-gray-300 bg-white dark:bg-gray-700 hover:bg-gray-50 dark:hover:bg-gray-600"
                     >
                       <ClipboardDocumentIcon className="h-4 w-4 mr-1" />
@@ -251,7 +257,7 @@ sudo ./install-sensor.sh \
                       Copy Command
                     </button>
                     <button
-                      onClick={() => deletePendingSensor(sensor.registration_key)}
+                      onClick={() => deletePendingSensor(sensor.id)}
                       className="inline-flex items-center px-3 py-1 border border-red-300 dark:border-red-600 text-sm font-medium rounded-md text-red-700 dark:text-red-300 bg-white dark:bg-gray-700 hover:bg-red-50 dark:hover:bg-red-600"
                     >
                       <TrashIcon className="h-4 w-4 mr-1" />
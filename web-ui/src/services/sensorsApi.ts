@@ -6,7 +6,10 @@ const SENSOR_BASE = 'http://localhost:8085/api/v1';
 
 export interface PendingSensorPayload {
   name: string;
-  ip_address: string;
+  ip_address?: string;
+  allowed_cidrs?: string[];
+  max_sensors?: number;
+  expires_in_minutes?: number;
   profile: string;
   network_interfaces?: string[];
   tags?: string[];
@@ -15,13 +18,18 @@ export interface PendingSensorPayload {
 export interface PendingSensorItem {
   id: string;
   name: string;
-  ip_address: string;
+  allowed_cidrs: string[];
   profile: string;
   network_interfaces: string[];
-  registration_key: string;
+  // Only returned when the key is created; listings carry key_prefix
+  registration_key?: string;
+  key_prefix: string;
+  max_sensors: number;
+  used_count: number;
   created_at: string;
   expires_at: string;
-  status: 'pending' | 'expired' | 'used';
+  revoked_at?: string;
+  status: 'pending' | 'expired' | 'used' | 'revoked';
 }
 
 export interface SensorItem {
@@ -44,8 +52,12 @@ export const sensorsApi = {
     const res = await api.get(`${SENSOR_BASE}/sensors/pending`);
     return res.data.pending_sensors ?? res.data ?? [];
   },
-  async deletePending(key: string): Promise<void> {
-    await api.delete(`${SENSOR_BASE}/sensors/pending/${encodeURIComponent(key)}`);
+  async revokePending(id: string): Promise<PendingSensorItem> {
+    const res = await api.post(`${SENSOR_BASE}/sensors/pending/${encodeURIComponent(id)}/revoke`);
+    return res.data.pending_sensor ?? res.data;
+  },
+  async deletePending(id: string): Promise<void> {
+    await api.delete(`${SENSOR_BASE}/sensors/pending/${encodeURIComponent(id)}`);
   },
   async registerSensor(payload: { registration_key: string }): Promise<{ sensor: SensorItem }> {
     const res = await api.post(`${SENSOR_BASE}/sensors/register`, payload);